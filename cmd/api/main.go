@@ -2,16 +2,24 @@ package main
 
 import (
 	"context"
+	"io"
 	"log"
 	"os"
 	"os/signal"
 	"syscall"
 
 	"github.com/hibiken/asynq"
+	"github.com/redis/go-redis/v9"
 
 	"github.com/dharsanguruparan/VaultDrop/internal/api"
 	"github.com/dharsanguruparan/VaultDrop/internal/config"
 	"github.com/dharsanguruparan/VaultDrop/internal/database"
+	"github.com/dharsanguruparan/VaultDrop/internal/events"
+	"github.com/dharsanguruparan/VaultDrop/internal/idempotency"
+	"github.com/dharsanguruparan/VaultDrop/internal/keys"
+	"github.com/dharsanguruparan/VaultDrop/internal/maintenance"
+	"github.com/dharsanguruparan/VaultDrop/internal/notify"
+	"github.com/dharsanguruparan/VaultDrop/internal/progress"
 	"github.com/dharsanguruparan/VaultDrop/internal/repository"
 	"github.com/dharsanguruparan/VaultDrop/internal/s3storage"
 )
@@ -25,15 +33,34 @@ func main() {
 		log.Fatalf("load config: %v", err)
 	}
 
-	pool, err := database.Connect(ctx, cfg.DatabaseURL)
+	pool, err := database.Connect(ctx, cfg)
 	if err != nil {
 		log.Fatalf("connect database: %v", err)
 	}
 	defer pool.Close()
-	if err := database.EnsureSchema(ctx, pool); err != nil {
-		log.Fatalf("ensure schema: %v", err)
+	if err := database.CheckSchema(ctx, pool); err != nil {
+		log.Fatalf("check schema: %v", err)
+	}
+	// Read-only replicas may point at a hot standby, so they never run DDL.
+	if cfg.AutoMigrate && !cfg.ReadOnly {
+		if err := database.Migrate(ctx, pool); err != nil {
+			log.Fatalf("migrate database: %v", err)
+		}
+	}
+	// Without migrations, or after them, the schema must be the one this
+	// build expects.
+	if err := database.CheckSchemaCurrent(ctx, pool); err != nil {
+		log.Fatalf("check schema: %v", err)
 	}
 	repo := repository.NewDocumentRepository(pool)
+	replica, err := database.ConnectReplica(ctx, cfg)
+	if err != nil {
+		log.Fatalf("connect database: %v", err)
+	}
+	if replica != nil {
+		defer replica.Close()
+		repo.UseReplica(replica)
+	}
 
 	store, err := s3storage.New(cfg)
 	if err != nil {
@@ -50,7 +77,41 @@ func main() {
 	})
 	defer client.Close()
 
-	server := api.New(cfg, repo, store, client)
+	rdb := redis.NewClient(&redis.Options{
+		Addr:     cfg.RedisAddr,
+		Password: cfg.RedisPassword,
+		DB:       cfg.RedisDB,
+	})
+	defer rdb.Close()
+
+	hub := notify.NewHub(pool, cfg.StatusPollInterval)
+	go hub.Run(ctx)
+
+	publisher, err := events.New(cfg)
+	if err != nil {
+		log.Fatalf("VAULTDROP_EVENTS_DRIVER: %v", err)
+	}
+	defer publisher.Close()
+
+	server := api.New(cfg, repo, store, client, hub, progress.NewTracker(rdb))
+	server.UseEvents(publisher)
+	keyring, err := keys.FromConfig(cfg)
+	if err != nil {
+		log.Fatalf("VAULTDROP_MASTER_KEYS: %v", err)
+	}
+	server.UseKeys(keyring)
+	server.UseIdempotency(idempotency.NewStore(rdb, cfg.IdempotencyTTL))
+	server.Collect(func(w io.Writer) {
+		pools := []database.PoolStat{{Name: "primary", Stat: pool.Stat()}}
+		if replica != nil {
+			pools = append(pools, database.PoolStat{Name: "replica", Stat: replica.Stat()})
+		}
+		database.WritePoolMetrics(w, pools...)
+		repo.WriteReplicaMetrics(w)
+	})
+	if cfg.CleanupInterval > 0 {
+		go maintenance.SweepTemp(ctx, cfg.CleanupInterval, cfg.CleanupGrace, cfg.CleanupDryRun)
+	}
 	if err := server.Run(ctx); err != nil {
 		log.Printf("api server stopped: %v", err)
 		os.Exit(1)
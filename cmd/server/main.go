@@ -10,6 +10,8 @@ import (
 	"os/signal"
 	"syscall"
 
+	"github.com/redis/go-redis/v9"
+
 	"github.com/dharsanguruparan/VaultDrop/internal/config"
 	"github.com/dharsanguruparan/VaultDrop/internal/processing"
 	"github.com/dharsanguruparan/VaultDrop/internal/server"
@@ -26,21 +28,90 @@ func main() {
 	}
 	// Step 2: construct dependencies. In Go it's idiomatic to instantiate
 	// structs via constructors that return pointers.
-	store := storage.NewMemoryStore()
-	processor := processing.New(store, cfg.ProcessingPool)
+	var store storage.FileStore
+	memory := storage.NewMemoryStore()
+	if cfg.SQLitePath != "" {
+		db, err := storage.OpenSQLite(cfg.SQLitePath)
+		if err != nil {
+			log.Fatalf("open sqlite: %v", err)
+		}
+		defer db.Close()
+		store = db
+	} else {
+		store = memory
+	}
+	if cfg.SnapshotPath != "" {
+		// Restore records before the journal so requeued jobs find them.
+		n, err := memory.LoadSnapshot(cfg.SnapshotPath)
+		if err != nil {
+			log.Fatalf("load snapshot: %v", err)
+		}
+		log.Printf("restored %d records from %s", n, cfg.SnapshotPath)
+	}
+	var journal *processing.Journal
+	if cfg.ProcessingJournal != "" {
+		// The journal replays jobs left pending by the previous run.
+		journal, err = processing.OpenJournal(cfg.ProcessingJournal)
+		if err != nil {
+			log.Fatalf("open processing journal: %v", err)
+		}
+		defer journal.Close()
+	}
+	stages, err := processing.StagesByName(cfg.ProcessingStages)
+	if err != nil {
+		log.Fatalf("VAULTDROP_PROCESSING_STAGES: %v", err)
+	}
+	processor := processing.New(store, processing.Options{
+		Workers:     cfg.ProcessingPool,
+		QueueLen:    cfg.ProcessingQueue,
+		MaxAttempts: cfg.ProcessingMaxAttempts,
+		Stages:      stages,
+		Journal:     journal,
+	})
 	signer := signing.NewSigner(cfg.SigningSecret)
+	if cfg.SignedURLNonces {
+		// Spent nonces live in Redis so one-time links stay spent across
+		// restarts and replicas.
+		rdb := redis.NewClient(&redis.Options{
+			Addr:     cfg.RedisAddr,
+			Password: cfg.RedisPassword,
+			DB:       cfg.RedisDB,
+		})
+		defer rdb.Close()
+		signer.UseNonces(signing.NewRedisNonces(rdb))
+	}
 	// server.New wires together config + dependencies and prepares HTTP routes.
 	srv, err := server.New(cfg, store, processor, signer)
 	if err != nil {
 		log.Fatalf("init server: %v", err)
 	}
+	// Limits apply once the server has hooked up file removal, so records
+	// restored over the caps are evicted along with their files.
+	memory.SetLimits(storage.Limits{MaxRecords: cfg.StoreMaxRecords, MaxBytes: cfg.StoreMaxBytes})
 	// Step 3: create a context that cancels when SIGINT/SIGTERM arrive. Context
 	// is Go's mechanism for cancellation deadlines and propagation.
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
-	log.Printf("VaultDrop listening on %s", cfg.Address)
+	snapshotsDone := make(chan struct{})
+	if cfg.SnapshotPath != "" {
+		go func() {
+			defer close(snapshotsDone)
+			memory.RunSnapshots(ctx, cfg.SnapshotPath, cfg.SnapshotInterval)
+		}()
+	} else {
+		close(snapshotsDone)
+	}
+	scheme := "http"
+	if cfg.TLSEnabled() {
+		scheme = "https"
+	}
+	log.Printf("VaultDrop listening on %s (%s)", cfg.Address, scheme)
 	// Step 4: block until the HTTP server exits.
-	if err := srv.Serve(ctx); err != nil {
+	err = srv.Serve(ctx)
+	// Stop the snapshot loop and wait for its final write either way.
+	stop()
+	<-snapshotsDone
+	if err != nil {
 		log.Printf("server stopped: %v", err)
 		os.Exit(1)
 	}
@@ -0,0 +1,453 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/spf13/cobra"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/config"
+	"github.com/dharsanguruparan/VaultDrop/internal/database"
+	"github.com/dharsanguruparan/VaultDrop/internal/keys"
+	"github.com/dharsanguruparan/VaultDrop/internal/maintenance"
+	"github.com/dharsanguruparan/VaultDrop/internal/queue"
+	"github.com/dharsanguruparan/VaultDrop/internal/repository"
+	"github.com/dharsanguruparan/VaultDrop/internal/s3storage"
+	"github.com/dharsanguruparan/VaultDrop/internal/worker"
+)
+
+func newAdminCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "admin",
+		Short: "Operational maintenance commands",
+	}
+	cmd.AddCommand(newBackfillCmd(), newCountsCmd(), newRequeueCmd(), newPurgeCmd(), newRewrapKeysCmd(), newCleanupCmd())
+	return cmd
+}
+
+func newBackfillCmd() *cobra.Command {
+	var (
+		stage  string
+		rate   float64
+		batch  int
+		limit  int
+		dryRun bool
+	)
+	cmd := &cobra.Command{
+		Use:   "backfill --stage layout",
+		Short: "Enqueue a derived-artifact stage for completed documents that lack it",
+		Long: `Backfill walks completed documents in id order and enqueues the given
+pipeline stage for every one that has no artifact recorded for it, at most
+--rate tasks per second, so a new stage can be applied retroactively without
+flooding the queue. Documents whose text was purged are skipped. Re-running is
+safe: documents that got the artifact meanwhile are skipped, tasks still
+queued are not enqueued twice, and tasks that used up their retries are
+replaced, so their documents are tried again.
+
+The only derived-artifact stage the worker implements is layout, the
+layout-preserving text. There are no thumbnail, chunk or embedding stages.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !worker.HasStage(stage) {
+				available := strings.Join(worker.DerivedStages(), ", ")
+				if available == "" {
+					available = "none yet"
+				}
+				return fmt.Errorf("the worker does not implement stage %q (available: %s)", stage, available)
+			}
+			if rate <= 0 || batch <= 0 {
+				return fmt.Errorf("--rate and --batch must be positive")
+			}
+			applyProfileEnv()
+			cfg, err := config.Load()
+			if err != nil {
+				return err
+			}
+			ctx := cmd.Context()
+			repo, closeRepo, err := openRepository(ctx, cfg)
+			if err != nil {
+				return err
+			}
+			defer closeRepo()
+			queueClient := newQueueClient(cfg)
+			defer queueClient.Close()
+			// Archived tasks still hold their id and are replaced.
+			inspector := asynq.NewInspector(asynq.RedisClientOpt{Addr: cfg.RedisAddr, Password: cfg.RedisPassword, DB: cfg.RedisDB})
+			defer inspector.Close()
+
+			out := cmd.OutOrStdout()
+			ticker := time.NewTicker(time.Duration(float64(time.Second) / rate))
+			defer ticker.Stop()
+			var after string
+			var enqueued, pending, seen int
+			for limit == 0 || seen < limit {
+				ids, err := repo.ListMissingArtifacts(ctx, stage, after, batch)
+				if err != nil {
+					return err
+				}
+				if len(ids) == 0 {
+					break
+				}
+				for _, id := range ids {
+					if limit > 0 && seen >= limit {
+						break
+					}
+					seen++
+					after = id
+					if dryRun {
+						fmt.Fprintf(out, "would enqueue %s for %s\n", stage, id)
+						continue
+					}
+					select {
+					case <-ctx.Done():
+						return ctx.Err()
+					case <-ticker.C:
+					}
+					ok, err := queue.EnqueueDerive(ctx, queueClient, inspector, stage, queue.DerivePayload{DocumentID: id})
+					if err != nil {
+						return fmt.Errorf("after %d enqueued: %w", enqueued, err)
+					}
+					if ok {
+						enqueued++
+					} else {
+						pending++
+					}
+				}
+			}
+			if dryRun {
+				fmt.Fprintf(out, "%d document(s) missing %s\n", seen, stage)
+				return nil
+			}
+			fmt.Fprintf(out, "enqueued %d %s task(s), %d already queued\n", enqueued, stage, pending)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&stage, "stage", "", "Derived-artifact stage to backfill ("+strings.Join(worker.DerivedStages(), ", ")+")")
+	cmd.Flags().Float64Var(&rate, "rate", 10, "Maximum tasks enqueued per second")
+	cmd.Flags().IntVar(&batch, "batch", 200, "Documents fetched per query")
+	cmd.Flags().IntVar(&limit, "limit", 0, "Stop after this many documents (0 for all)")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "List the documents that would be enqueued")
+	_ = cmd.MarkFlagRequired("stage")
+	return cmd
+}
+
+func newCountsCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "counts",
+		Short: "Print the number of documents in each status",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			applyProfileEnv()
+			cfg, err := config.Load()
+			if err != nil {
+				return err
+			}
+			repo, closeRepo, err := openRepository(cmd.Context(), cfg)
+			if err != nil {
+				return err
+			}
+			defer closeRepo()
+			counts, err := repo.CountByStatus(cmd.Context())
+			if err != nil {
+				return err
+			}
+			out := cmd.OutOrStdout()
+			for _, status := range []repository.DocumentStatus{repository.StatusQueued, repository.StatusProcessing, repository.StatusCompleted, repository.StatusFailed, repository.StatusCancelled} {
+				fmt.Fprintf(out, "%-11s %d\n", status, counts[status])
+			}
+			return nil
+		},
+	}
+}
+
+func newRequeueCmd() *cobra.Command {
+	var (
+		within time.Duration
+		ids    []string
+		limit  int
+	)
+	cmd := &cobra.Command{
+		Use:   "requeue",
+		Short: "Move failed documents back to queued and enqueue extraction",
+		Long: `Requeue flips failed documents back to queued in one statement and enqueues
+an extract task for each, e.g. after fixing an outage that failed a batch.
+Documents whose raw upload was purged are skipped. A document whose task
+cannot be enqueued is marked failed again. --id restricts the run to the given
+documents, e.g. the ids GET /admin/failed lists for one error.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if within < 0 || limit < 0 {
+				return fmt.Errorf("--failed-within and --limit must not be negative")
+			}
+			applyProfileEnv()
+			cfg, err := config.Load()
+			if err != nil {
+				return err
+			}
+			ctx := cmd.Context()
+			repo, closeRepo, err := openRepository(ctx, cfg)
+			if err != nil {
+				return err
+			}
+			defer closeRepo()
+			queueClient := newQueueClient(cfg)
+			defer queueClient.Close()
+			var since time.Time
+			if within > 0 {
+				since = time.Now().Add(-within)
+			}
+			res, err := maintenance.Requeue(ctx, repo, queueClient, since, ids, limit)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "requeued %d document(s), %d could not be enqueued\n", res.Requeued, res.Failed)
+			return nil
+		},
+	}
+	cmd.Flags().DurationVar(&within, "failed-within", 0, "Only documents that failed this recently (0 for all)")
+	cmd.Flags().StringSliceVar(&ids, "id", nil, "Only these document ids (repeatable)")
+	cmd.Flags().IntVar(&limit, "limit", 0, "Requeue at most this many documents (0 for all)")
+	return cmd
+}
+
+func newPurgeCmd() *cobra.Command {
+	var (
+		olderThan time.Duration
+		statuses  []string
+		yes       bool
+	)
+	cmd := &cobra.Command{
+		Use:   "purge --older-than <duration> --yes",
+		Short: "Delete old completed, failed or cancelled documents and their objects",
+		Long: `Purge deletes every document created more than --older-than ago whose status
+is in --status, in one statement, then removes the raw and processed objects.
+Objects that cannot be removed are reported and left in the bucket.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if olderThan <= 0 {
+				return fmt.Errorf("--older-than must be positive")
+			}
+			var want []repository.DocumentStatus
+			for _, s := range statuses {
+				status := repository.DocumentStatus(strings.TrimSpace(s))
+				if !maintenance.Purgeable(status) {
+					return fmt.Errorf("cannot purge %q documents; use completed, failed or cancelled", s)
+				}
+				want = append(want, status)
+			}
+			if !yes {
+				return fmt.Errorf("refusing to delete documents older than %s without --yes", olderThan)
+			}
+			applyProfileEnv()
+			cfg, err := config.Load()
+			if err != nil {
+				return err
+			}
+			ctx := cmd.Context()
+			repo, closeRepo, err := openRepository(ctx, cfg)
+			if err != nil {
+				return err
+			}
+			defer closeRepo()
+			store, err := s3storage.New(cfg)
+			if err != nil {
+				return err
+			}
+			res, err := maintenance.Purge(ctx, repo, store, time.Now().Add(-olderThan), want)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "deleted %d document(s), %d object(s) could not be removed\n", res.Deleted, res.ObjectErrors)
+			return nil
+		},
+	}
+	cmd.Flags().DurationVar(&olderThan, "older-than", 0, "Delete documents created longer ago than this, e.g. 720h")
+	cmd.Flags().StringSliceVar(&statuses, "status", []string{"completed", "failed", "cancelled"}, "Statuses to purge")
+	cmd.Flags().BoolVar(&yes, "yes", false, "Confirm the deletion")
+	return cmd
+}
+
+func newCleanupCmd() *cobra.Command {
+	var (
+		grace  time.Duration
+		dryRun bool
+	)
+	cmd := &cobra.Command{
+		Use:   "cleanup [--grace <duration>] [--dry-run]",
+		Short: "Remove objects whose document is gone and report documents whose raw upload is",
+		Long: `Cleanup runs the worker's orphan cleanup once: objects under the API's prefixes
+last modified more than --grace ago whose document no longer exists are removed,
+and documents created more than --grace ago whose raw upload is missing are
+listed. Those documents are left alone. With --dry-run nothing is removed.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			applyProfileEnv()
+			cfg, err := config.Load()
+			if err != nil {
+				return err
+			}
+			if grace <= 0 {
+				grace = cfg.CleanupGrace
+			}
+			ctx := cmd.Context()
+			repo, closeRepo, err := openRepository(ctx, cfg)
+			if err != nil {
+				return err
+			}
+			defer closeRepo()
+			store, err := s3storage.New(cfg)
+			if err != nil {
+				return err
+			}
+			rep, err := maintenance.CleanOrphans(ctx, repo, store, time.Now().Add(-grace), dryRun)
+			if err != nil {
+				return err
+			}
+			out := cmd.OutOrStdout()
+			verb := "removed"
+			if dryRun {
+				verb = "would remove"
+			}
+			fmt.Fprintf(out, "%s %d raw object(s), %d bytes\n", verb, rep.Raw.Count, rep.Raw.Bytes)
+			fmt.Fprintf(out, "%s %d processed object(s), %d bytes\n", verb, rep.Processed.Count, rep.Processed.Bytes)
+			if rep.ObjectErrors > 0 {
+				fmt.Fprintf(out, "%d object(s) could not be removed\n", rep.ObjectErrors)
+			}
+			if rep.MissingRaw > 0 {
+				fmt.Fprintf(out, "%d document(s) are missing their raw upload:\n", rep.MissingRaw)
+				for _, id := range rep.MissingRawIDs {
+					fmt.Fprintf(out, "  %s\n", id)
+				}
+			}
+			return nil
+		},
+	}
+	cmd.Flags().DurationVar(&grace, "grace", 0, "Leave objects and documents younger than this alone (default VAULTDROP_CLEANUP_GRACE)")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Report without removing anything")
+	return cmd
+}
+
+func newRewrapKeysCmd() *cobra.Command {
+	var (
+		batch  int
+		dryRun bool
+	)
+	cmd := &cobra.Command{
+		Use:   "rewrap-keys",
+		Short: "Rewrap documents' data keys under the current master key",
+		Long: `Rewrap-keys finishes a master key rotation. Each encrypted raw upload has a
+data key of its own, stored with the document wrapped under a master key;
+this walks the documents whose key is wrapped under any master key other
+than VAULTDROP_MASTER_KEY_ID and rewraps it under that one. The objects are
+not touched. Rotate by adding the new key to VAULTDROP_MASTER_KEYS, pointing
+VAULTDROP_MASTER_KEY_ID at it on every process, running this, and removing
+the old key once no documents use it. Re-running is safe.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if batch <= 0 {
+				return fmt.Errorf("--batch must be positive")
+			}
+			applyProfileEnv()
+			cfg, err := config.Load()
+			if err != nil {
+				return err
+			}
+			keyring, err := keys.FromConfig(cfg)
+			if err != nil {
+				return err
+			}
+			if keyring == nil {
+				return fmt.Errorf("VAULTDROP_MASTER_KEYS is not set")
+			}
+			ctx := cmd.Context()
+			repo, closeRepo, err := openRepository(ctx, cfg)
+			if err != nil {
+				return err
+			}
+			defer closeRepo()
+
+			out := cmd.OutOrStdout()
+			var after string
+			var rewrapped, skipped, failed int
+			for {
+				stale, err := repo.ListStaleDataKeys(ctx, keyring.Current(), after, batch)
+				if err != nil {
+					return err
+				}
+				if len(stale) == 0 {
+					break
+				}
+				for _, k := range stale {
+					after = k.DocumentID
+					if dryRun {
+						fmt.Fprintf(out, "would rewrap %s from %s\n", k.DocumentID, k.Key.KeyID)
+						continue
+					}
+					next, err := keyring.Rewrap(k.DocumentID, k.Key)
+					if err != nil {
+						failed++
+						fmt.Fprintf(cmd.ErrOrStderr(), "rewrap %s: %v\n", k.DocumentID, err)
+						continue
+					}
+					ok, err := repo.ReplaceDataKey(ctx, k.DocumentID, k.Key, next)
+					if err != nil {
+						return fmt.Errorf("after %d rewrapped: %w", rewrapped, err)
+					}
+					if ok {
+						rewrapped++
+					} else {
+						skipped++
+					}
+				}
+			}
+			if !dryRun {
+				fmt.Fprintf(out, "rewrapped %d data key(s), %d changed meanwhile, %d failed\n", rewrapped, skipped, failed)
+			}
+			counts, err := repo.CountDataKeys(ctx)
+			if err != nil {
+				return err
+			}
+			ids := make([]string, 0, len(counts))
+			for id := range counts {
+				ids = append(ids, id)
+			}
+			sort.Strings(ids)
+			for _, id := range ids {
+				fmt.Fprintf(out, "%-16s %d\n", id, counts[id])
+			}
+			if failed > 0 {
+				return fmt.Errorf("%d data key(s) could not be rewrapped", failed)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().IntVar(&batch, "batch", 200, "Documents fetched per query")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "List the documents whose keys would be rewrapped")
+	return cmd
+}
+
+// openRepository connects to the database and checks the schema, for
+// commands that work on documents directly instead of through the API.
+func openRepository(ctx context.Context, cfg *config.Config) (*repository.DocumentRepository, func(), error) {
+	pool, err := database.Connect(ctx, cfg)
+	if err != nil {
+		return nil, nil, fmt.Errorf("connect database: %w", err)
+	}
+	if err := database.CheckSchemaCurrent(ctx, pool); err != nil {
+		pool.Close()
+		return nil, nil, err
+	}
+	return repository.NewDocumentRepository(pool), pool.Close, nil
+}
+
+func newQueueClient(cfg *config.Config) *asynq.Client {
+	return asynq.NewClient(asynq.RedisClientOpt{
+		Addr:     cfg.RedisAddr,
+		Password: cfg.RedisPassword,
+		DB:       cfg.RedisDB,
+	})
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/client"
+)
+
+var (
+	apiServer string
+	apiKey    string
+)
+
+func newAPICmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "api",
+		Short: "Talk to a running VaultDrop API",
+	}
+	cmd.PersistentFlags().StringVar(&apiServer, "server", defaultAPIServer, "Base URL of the VaultDrop API (overrides $VAULTDROP_SERVER and the profile)")
+	cmd.PersistentFlags().StringVar(&apiKey, "api-key", "", "API key sent as a bearer token (overrides $VAULTDROP_API_KEY and the profile)")
+	cmd.AddCommand(
+		newAPIUploadCmd(),
+		newAPIStatusCmd(),
+		newAPITextCmd(),
+		newAPIDownloadCmd(),
+	)
+	return cmd
+}
+
+func newAPIUploadCmd() *cobra.Command {
+	var wait bool
+	var quiet bool
+	cmd := &cobra.Command{
+		Use:   "upload <file>",
+		Short: "Upload a PDF and print the document id",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			c := client.New(apiServer, apiKey)
+			var progress client.ProgressFunc
+			if !quiet {
+				progress = progressBar(cmd.ErrOrStderr())
+			}
+			result, err := c.Upload(ctx, args[0], progress)
+			if !quiet {
+				fmt.Fprintln(cmd.ErrOrStderr())
+			}
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), result.ID)
+			if !wait {
+				return nil
+			}
+			doc, err := waitForDocument(ctx, c, result.ID)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.ErrOrStderr(), "status: %s\n", doc.Status)
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&wait, "wait", false, "Poll until processing finishes")
+	cmd.Flags().BoolVarP(&quiet, "quiet", "q", false, "Hide the progress bar")
+	return cmd
+}
+
+func newAPIStatusCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "status <id>",
+		Short: "Show document metadata",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			doc, err := client.New(apiServer, apiKey).Document(cmd.Context(), args[0])
+			if err != nil {
+				return err
+			}
+			enc := json.NewEncoder(cmd.OutOrStdout())
+			enc.SetIndent("", "  ")
+			return enc.Encode(doc)
+		},
+	}
+}
+
+func newAPITextCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "text <id>",
+		Short: "Print the extracted text",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			text, err := client.New(apiServer, apiKey).Text(cmd.Context(), args[0])
+			if errors.Is(err, client.ErrNotReady) {
+				return fmt.Errorf("document %s is still processing", args[0])
+			}
+			if err != nil {
+				return err
+			}
+			_, err = io.WriteString(cmd.OutOrStdout(), text)
+			return err
+		},
+	}
+}
+
+func newAPIDownloadCmd() *cobra.Command {
+	var output string
+	cmd := &cobra.Command{
+		Use:   "download <id>",
+		Short: "Download the processed .txt artifact",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dst := cmd.OutOrStdout()
+			if output != "" && output != "-" {
+				f, err := os.Create(output)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				dst = f
+			}
+			n, err := client.New(apiServer, apiKey).Download(cmd.Context(), args[0], dst)
+			if err != nil {
+				return err
+			}
+			if output != "" && output != "-" {
+				fmt.Fprintf(cmd.ErrOrStderr(), "wrote %d bytes to %s\n", n, output)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&output, "output", "o", "", "Write to a file instead of stdout")
+	return cmd
+}
+
+func waitForDocument(ctx context.Context, c *client.Client, id string) (*client.Document, error) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		doc, err := c.Document(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if doc.Status == "completed" || doc.Status == "failed" || doc.Status == "cancelled" {
+			return doc, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// progressBar renders a single-line upload progress bar.
+func progressBar(w io.Writer) client.ProgressFunc {
+	const width = 30
+	return func(sent, total int64) {
+		if total <= 0 {
+			return
+		}
+		filled := int(sent * width / total)
+		fmt.Fprintf(w, "\r[%s%s] %3d%% %s/%s",
+			strings.Repeat("#", filled), strings.Repeat(" ", width-filled),
+			sent*100/total, humanBytes(sent), humanBytes(total))
+	}
+}
+
+func humanBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+func envOr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/config"
+	"github.com/dharsanguruparan/VaultDrop/internal/repository"
+)
+
+func newExportCmd() *cobra.Command {
+	var (
+		format string
+		output string
+		batch  int
+	)
+	cmd := &cobra.Command{
+		Use:   "export --format jsonl [--output <file>]",
+		Short: "Dump every document row as JSON lines",
+		Long: `Export writes every row of the documents table, one JSON object per line in id
+order, for moving documents to another environment with vaultdrop import. Each
+line carries all columns: status, text, tags, metadata, retention and archive
+markers and the wrapped data key of encrypted uploads. Versions, attempts,
+artifacts and access statistics are not included, and neither are the objects
+themselves; copy the buckets separately, e.g. with mc mirror.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if format != "jsonl" {
+				return fmt.Errorf("unsupported --format %q; only jsonl is available", format)
+			}
+			if batch <= 0 {
+				return fmt.Errorf("--batch must be positive")
+			}
+			applyProfileEnv()
+			cfg, err := config.Load()
+			if err != nil {
+				return err
+			}
+			ctx := cmd.Context()
+			repo, closeRepo, err := openRepository(ctx, cfg)
+			if err != nil {
+				return err
+			}
+			defer closeRepo()
+
+			out := cmd.OutOrStdout()
+			var file *os.File
+			if output != "" && output != "-" {
+				if file, err = os.Create(output); err != nil {
+					return err
+				}
+				defer file.Close()
+				out = file
+			}
+			w := bufio.NewWriter(out)
+			enc := json.NewEncoder(w)
+			var after string
+			var count int
+			for {
+				recs, err := repo.DumpDocuments(ctx, after, batch)
+				if err != nil {
+					return fmt.Errorf("after %d document(s): %w", count, err)
+				}
+				if len(recs) == 0 {
+					break
+				}
+				for _, rec := range recs {
+					if err := enc.Encode(rec); err != nil {
+						return err
+					}
+				}
+				count += len(recs)
+				after = recs[len(recs)-1].ID
+			}
+			if err := w.Flush(); err != nil {
+				return err
+			}
+			if file != nil {
+				if err := file.Close(); err != nil {
+					return err
+				}
+				fmt.Fprintf(cmd.ErrOrStderr(), "exported %d document(s) to %s\n", count, output)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&format, "format", "jsonl", "Output format (jsonl)")
+	cmd.Flags().StringVarP(&output, "output", "o", "", "Write to this file instead of stdout")
+	cmd.Flags().IntVar(&batch, "batch", 500, "Documents fetched per query")
+	return cmd
+}
+
+func newImportCmd() *cobra.Command {
+	var (
+		overwrite bool
+		batch     int
+		dryRun    bool
+	)
+	cmd := &cobra.Command{
+		Use:   "import [file]",
+		Short: "Restore document rows written by vaultdrop export",
+		Long: `Import reads the JSON lines vaultdrop export writes, from the file or stdin,
+and inserts the documents, one transaction per --batch records. Documents whose
+id already exists are skipped, or replaced with --overwrite, so an interrupted
+import can be run again. Documents an erasure request removed are never
+imported again. A document whose object key belongs to another id stops the
+import. Documents that were queued or processing when they were
+exported have no task here and are imported as failed; reprocess them to
+extract them. Documents with an encrypted raw upload need the master key
+they were wrapped under in VAULTDROP_MASTER_KEYS. With --dry-run the records
+are only checked.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if batch <= 0 {
+				return fmt.Errorf("--batch must be positive")
+			}
+			in := cmd.InOrStdin()
+			if len(args) == 1 && args[0] != "-" {
+				f, err := os.Open(args[0])
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				in = f
+			}
+			dec := json.NewDecoder(bufio.NewReader(in))
+			dec.DisallowUnknownFields()
+			out := cmd.OutOrStdout()
+			if dryRun {
+				n, err := readRecords(dec, batch, func([]repository.DocumentRecord) error { return nil })
+				if err != nil {
+					return err
+				}
+				fmt.Fprintf(out, "%d document(s) would be imported\n", n)
+				return nil
+			}
+
+			applyProfileEnv()
+			cfg, err := config.Load()
+			if err != nil {
+				return err
+			}
+			ctx := cmd.Context()
+			repo, closeRepo, err := openRepository(ctx, cfg)
+			if err != nil {
+				return err
+			}
+			defer closeRepo()
+			var total repository.RestoreResult
+			_, err = readRecords(dec, batch, func(recs []repository.DocumentRecord) error {
+				res, err := repo.RestoreDocuments(ctx, recs, overwrite)
+				if err != nil {
+					return err
+				}
+				total.Inserted += res.Inserted
+				total.Updated += res.Updated
+				total.Skipped += res.Skipped
+				total.Failed += res.Failed
+				total.Erased += res.Erased
+				return nil
+			})
+			fmt.Fprintf(out, "inserted %d document(s), updated %d, skipped %d existing\n", total.Inserted, total.Updated, total.Skipped)
+			if total.Failed > 0 {
+				fmt.Fprintf(out, "%d document(s) were queued or processing and were imported as failed; reprocess them to extract them\n", total.Failed)
+			}
+			if total.Erased > 0 {
+				fmt.Fprintf(out, "%d document(s) were erased here and were not imported\n", total.Erased)
+			}
+			return err
+		},
+	}
+	cmd.Flags().BoolVar(&overwrite, "overwrite", false, "Replace documents whose id already exists")
+	cmd.Flags().IntVar(&batch, "batch", 500, "Documents restored per transaction")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Check the records without connecting to the database")
+	return cmd
+}
+
+// readRecords decodes and validates the records on dec and hands them to
+// flush in batches of size. It returns how many it read.
+func readRecords(dec *json.Decoder, size int, flush func([]repository.DocumentRecord) error) (int, error) {
+	var (
+		pending []repository.DocumentRecord
+		n       int
+	)
+	for {
+		var rec repository.DocumentRecord
+		err := dec.Decode(&rec)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return n, fmt.Errorf("record %d: %w", n+1, err)
+		}
+		if err := rec.Validate(); err != nil {
+			return n, fmt.Errorf("record %d: %w", n+1, err)
+		}
+		n++
+		pending = append(pending, rec)
+		if len(pending) == size {
+			if err := flush(pending); err != nil {
+				return n, err
+			}
+			pending = pending[:0]
+		}
+	}
+	if len(pending) > 0 {
+		if err := flush(pending); err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
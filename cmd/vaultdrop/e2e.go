@@ -0,0 +1,250 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/client"
+)
+
+// e2eProject isolates the smoke-test stack from the regular dev stack so
+// tearing it down (volumes included) never touches local data.
+const e2eProject = "vaultdrop-e2e"
+
+const defaultE2EPhrase = "VaultDrop end-to-end fixture"
+
+func newE2ECmd() *cobra.Command {
+	var (
+		reuse   bool
+		keep    bool
+		file    string
+		expect  string
+		timeout time.Duration
+		s3Dial  string
+	)
+	cmd := &cobra.Command{
+		Use:   "e2e",
+		Short: "Smoke-test the full stack: upload a PDF and verify the extracted text",
+		Long: `e2e starts the compose stack under its own project name, uploads a fixture
+PDF, waits for extraction to complete, checks the extracted text and the
+presigned processed-text URL, then tears the stack down again. It exits
+non-zero on the first failed step.
+
+Use --reuse to run against an already running API instead.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if reuse && !cmd.Flags().Changed("s3-dial") {
+				s3Dial = ""
+			}
+			ctx, cancel := context.WithTimeout(cmd.Context(), timeout)
+			defer cancel()
+			out := cmd.ErrOrStderr()
+
+			if !reuse {
+				composeArgs := []string{"compose", "-p", e2eProject, "-f", composeFile}
+				if !keep {
+					defer func() {
+						fmt.Fprintln(out, "==> tearing down stack")
+						// The run context may have expired; teardown gets its own.
+						downCtx, downCancel := context.WithTimeout(context.Background(), 2*time.Minute)
+						defer downCancel()
+						if err := runCommand(downCtx, "docker", append(composeArgs, "down", "-v")...); err != nil {
+							fmt.Fprintf(out, "teardown failed: %v\n", err)
+						}
+					}()
+				}
+				fmt.Fprintln(out, "==> starting stack")
+				if err := runCommand(ctx, "docker", append(composeArgs, "up", "-d", "--build")...); err != nil {
+					return fmt.Errorf("start stack: %w", err)
+				}
+			}
+
+			step := func(name string, fn func() error) error {
+				fmt.Fprintf(out, "==> %s\n", name)
+				if err := fn(); err != nil {
+					return fmt.Errorf("%s: %w", name, err)
+				}
+				return nil
+			}
+			c := client.New(apiServer, apiKey)
+
+			if err := step("waiting for API health", func() error {
+				return waitForHealthy(ctx, apiServer)
+			}); err != nil {
+				return err
+			}
+
+			path := file
+			if path == "" {
+				dir, err := os.MkdirTemp("", "vaultdrop-e2e-*")
+				if err != nil {
+					return err
+				}
+				defer os.RemoveAll(dir)
+				path = filepath.Join(dir, "fixture.pdf")
+				if err := os.WriteFile(path, fixturePDF(expect), 0o600); err != nil {
+					return err
+				}
+			}
+
+			var id string
+			if err := step("uploading "+filepath.Base(path), func() error {
+				result, err := c.Upload(ctx, path, nil)
+				if err != nil {
+					return err
+				}
+				id = result.ID
+				return nil
+			}); err != nil {
+				return err
+			}
+
+			if err := step("waiting for extraction of "+id, func() error {
+				doc, err := waitForDocument(ctx, c, id)
+				if err != nil {
+					return err
+				}
+				if doc.Status != "completed" {
+					reason := "no error message"
+					if doc.ErrorMessage != nil {
+						reason = *doc.ErrorMessage
+					}
+					return fmt.Errorf("document %s: %s", doc.Status, reason)
+				}
+				return nil
+			}); err != nil {
+				return err
+			}
+
+			var text string
+			if err := step("checking extracted text", func() error {
+				var err error
+				if text, err = c.Text(ctx, id); err != nil {
+					return err
+				}
+				if !strings.Contains(text, expect) {
+					return fmt.Errorf("text does not contain %q", expect)
+				}
+				return nil
+			}); err != nil {
+				return err
+			}
+
+			if err := step("checking presigned processed-text URL", func() error {
+				signed, err := c.ProcessedURL(ctx, id)
+				if err != nil {
+					return err
+				}
+				body, err := fetchPresigned(ctx, signed, s3Dial)
+				if err != nil {
+					return err
+				}
+				if string(body) != text {
+					return errors.New("processed artifact does not match the extracted text")
+				}
+				return nil
+			}); err != nil {
+				return err
+			}
+
+			fmt.Fprintln(cmd.OutOrStdout(), "e2e: all checks passed")
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&apiServer, "server", defaultAPIServer, "Base URL of the VaultDrop API (overrides $VAULTDROP_SERVER and the profile)")
+	cmd.Flags().StringVar(&apiKey, "api-key", "", "API key sent as a bearer token (overrides $VAULTDROP_API_KEY and the profile)")
+	cmd.Flags().BoolVar(&reuse, "reuse", false, "Test the API at --server instead of starting a stack")
+	cmd.Flags().BoolVar(&keep, "keep", false, "Leave the stack running afterwards")
+	cmd.Flags().StringVar(&file, "file", "", "PDF to upload instead of the generated fixture (set --expect to a phrase it contains)")
+	cmd.Flags().StringVar(&expect, "expect", defaultE2EPhrase, "Phrase the extracted text must contain")
+	cmd.Flags().DurationVar(&timeout, "timeout", 5*time.Minute, "Overall deadline including stack startup")
+	cmd.Flags().StringVar(&s3Dial, "s3-dial", "localhost:9000", "Address to connect to for presigned URLs, keeping the signed Host header (the API signs URLs for the compose-internal minio host); ignored with --reuse unless set")
+	return cmd
+}
+
+func waitForHealthy(ctx context.Context, baseURL string) error {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		res := probeAPI(ctx, baseURL)
+		if res.ok {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w (last error: %s)", ctx.Err(), res.detail)
+		case <-ticker.C:
+		}
+	}
+}
+
+// fetchPresigned GETs a presigned URL. When dial is set the TCP connection
+// goes there instead, while the Host header (part of the signature) still
+// names the original host.
+func fetchPresigned(ctx context.Context, signed, dial string) ([]byte, error) {
+	if _, err := url.Parse(signed); err != nil {
+		return nil, fmt.Errorf("invalid presigned url: %w", err)
+	}
+	httpClient := http.DefaultClient
+	if dial != "" {
+		dialer := &net.Dialer{Timeout: 5 * time.Second}
+		httpClient = &http.Client{Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				return dialer.DialContext(ctx, network, dial)
+			},
+		}}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, signed, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("presigned url answered %s", resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// fixturePDF builds a one-page PDF showing text in Helvetica, computing the
+// xref offsets so strict parsers accept it.
+func fixturePDF(text string) []byte {
+	escaped := strings.NewReplacer(`\`, `\\`, `(`, `\(`, `)`, `\)`).Replace(text)
+	stream := fmt.Sprintf("BT /F1 18 Tf 72 720 Td (%s) Tj ET", escaped)
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Resources << /Font << /F1 4 0 R >> >> /Contents 5 0 R >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(stream), stream),
+	}
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return buf.Bytes()
+}
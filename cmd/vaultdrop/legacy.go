@@ -0,0 +1,329 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+	"github.com/spf13/cobra"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/config"
+	"github.com/dharsanguruparan/VaultDrop/internal/database"
+	"github.com/dharsanguruparan/VaultDrop/internal/keys"
+	"github.com/dharsanguruparan/VaultDrop/internal/model"
+	"github.com/dharsanguruparan/VaultDrop/internal/queue"
+	"github.com/dharsanguruparan/VaultDrop/internal/repository"
+	"github.com/dharsanguruparan/VaultDrop/internal/s3storage"
+	"github.com/dharsanguruparan/VaultDrop/internal/storage"
+)
+
+func newLegacyCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "legacy",
+		Short: "Tools for moving off the standalone server",
+	}
+	cmd.AddCommand(newLegacyImportCmd())
+	return cmd
+}
+
+func newLegacyImportCmd() *cobra.Command {
+	var (
+		dir    string
+		owner  string
+		dryRun bool
+		remove bool
+	)
+	cmd := &cobra.Command{
+		Use:   "import",
+		Short: "Import the standalone server's uploads into object storage and queue them",
+		Long: `Import walks the standalone server's upload directory, uploads every PDF into
+the raw bucket, creates its document row and enqueues extraction, using the
+same VAULTDROP_* settings as the API (environment, then the active profile).
+
+Original file names come from the standalone server's records, read from
+VAULTDROP_SQLITE_PATH or, failing that, VAULTDROP_SNAPSHOT_PATH; stop the
+server first so they are complete. Files its scan rejected are skipped.
+Files with no record, or every file when neither setting is configured, are
+named legacy-<id>.pdf. The standalone server has no owners: documents
+belong to the --owner principal, or to nobody without it, which leaves them
+to admins once API keys are configured. Legacy ids are kept (as
+UUIDs), which makes the import idempotent: files already imported are
+skipped, and those still queued, say because enqueueing failed last time,
+are enqueued again. Documents an erasure request removed are not imported
+again. Files that are not PDFs are reported and left alone.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			applyProfileEnv()
+			cfg, err := config.Load()
+			if err != nil {
+				return err
+			}
+			ctx := cmd.Context()
+			pool, err := database.Connect(ctx, cfg)
+			if err != nil {
+				return fmt.Errorf("connect database: %w", err)
+			}
+			defer pool.Close()
+			if err := database.CheckSchemaCurrent(ctx, pool); err != nil {
+				return err
+			}
+			store, err := s3storage.New(cfg)
+			if err != nil {
+				return err
+			}
+			keyring, err := keys.FromConfig(cfg)
+			if err != nil {
+				return err
+			}
+			queueClient := asynq.NewClient(asynq.RedisClientOpt{
+				Addr:     cfg.RedisAddr,
+				Password: cfg.RedisPassword,
+				DB:       cfg.RedisDB,
+			})
+			defer queueClient.Close()
+			records, err := legacyRecords(cfg)
+			if err != nil {
+				return err
+			}
+			imp := &legacyImporter{
+				repo:    repository.NewDocumentRepository(pool),
+				records: records,
+				store:   store,
+				keys:    keyring,
+				queue:   queueClient,
+				owner:   owner,
+				dryRun:  dryRun,
+				remove:  remove,
+				out:     cmd.OutOrStdout(),
+			}
+			return imp.run(ctx, dir)
+		},
+	}
+	cmd.Flags().StringVar(&dir, "dir", filepath.Join(os.TempDir(), "vaultdrop"), "Standalone server upload directory")
+	cmd.Flags().StringVar(&owner, "owner", "", "Principal the imported documents belong to (default none, admins only)")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Report what would be imported without changing anything")
+	cmd.Flags().BoolVar(&remove, "remove", false, "Delete each local file after it was imported")
+	return cmd
+}
+
+type legacyImporter struct {
+	repo *repository.DocumentRepository
+	// records are the standalone server's file records by file id; nil when
+	// it kept none.
+	records map[string]model.FileRecord
+	store   *s3storage.Storage
+	keys    *keys.Keyring
+	queue   *asynq.Client
+	owner   string
+	dryRun  bool
+	remove  bool
+	out     io.Writer
+}
+
+func (imp *legacyImporter) run(ctx context.Context, dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("read %s: %w", dir, err)
+	}
+	var imported, skipped, failed int
+	for _, entry := range entries {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		docID, ok := legacyDocumentID(entry.Name())
+		if !entry.Type().IsRegular() || !ok {
+			continue
+		}
+		fileName := "legacy-" + entry.Name() + ".pdf"
+		if rec, ok := imp.records[entry.Name()]; ok {
+			if rec.Status == model.StatusRejected {
+				skipped++
+				fmt.Fprintf(imp.out, "skip  %s: rejected by the standalone server\n", entry.Name())
+				continue
+			}
+			if rec.Name != "" {
+				fileName = rec.Name
+			}
+		}
+		path := filepath.Join(dir, entry.Name())
+		status, err := imp.importFile(ctx, path, docID, fileName)
+		switch {
+		case err != nil:
+			failed++
+			fmt.Fprintf(imp.out, "FAIL  %s: %v\n", entry.Name(), err)
+		case status != "":
+			skipped++
+			fmt.Fprintf(imp.out, "skip  %s: %s\n", entry.Name(), status)
+		default:
+			imported++
+			fmt.Fprintf(imp.out, "ok    %s -> %s\n", entry.Name(), docID)
+		}
+	}
+	fmt.Fprintf(imp.out, "imported %d, skipped %d, failed %d\n", imported, skipped, failed)
+	if failed > 0 {
+		return fmt.Errorf("%d file(s) failed to import", failed)
+	}
+	return nil
+}
+
+// importFile returns a non-empty skip reason when the file is not imported.
+func (imp *legacyImporter) importFile(ctx context.Context, path, docID, fileName string) (string, error) {
+	// An erased document has no row, so Get alone would import it again.
+	if erased, err := imp.repo.IsErased(ctx, docID); err != nil {
+		return "", err
+	} else if erased {
+		return "erased", nil
+	}
+	if existing, err := imp.repo.Get(ctx, docID); err == nil {
+		return imp.requeue(ctx, existing)
+	} else if !errors.Is(err, repository.ErrNotFound) {
+		return "", err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	if info.Size() == 0 {
+		return "empty file", nil
+	}
+	sniff := make([]byte, 512)
+	n, err := io.ReadFull(f, sniff)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return "", err
+	}
+	if contentType := http.DetectContentType(sniff[:n]); contentType != "application/pdf" {
+		return "not a PDF (" + contentType + ")", nil
+	}
+	if imp.dryRun {
+		return "dry run", nil
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	digest := sha256.New()
+	if _, err := io.Copy(digest, f); err != nil {
+		return "", err
+	}
+	sum := hex.EncodeToString(digest.Sum(nil))
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	objectKey := fmt.Sprintf("uploads/%s/%s", docID, filepath.Base(fileName))
+	doc := &repository.Document{ID: docID, FileName: fileName, ObjectKey: objectKey, SHA256: sum, OwnerID: imp.owner}
+	var dataKey []byte
+	if imp.keys != nil {
+		key, wrapped, err := imp.keys.NewDataKey(docID)
+		if err != nil {
+			return "", err
+		}
+		dataKey, doc.DataKey = key, &wrapped
+	}
+	if err := imp.store.UploadRaw(ctx, objectKey, f, info.Size(), "application/pdf", sum, dataKey); err != nil {
+		return "", err
+	}
+	if err := imp.repo.Create(ctx, doc); err != nil {
+		return "", err
+	}
+	payload := queue.ExtractPayload{DocumentID: docID}
+	if _, err := queue.EnqueueExtract(ctx, imp.queue, payload); err != nil {
+		return "", err
+	}
+	if imp.remove {
+		f.Close()
+		if err := os.Remove(path); err != nil {
+			fmt.Fprintf(imp.out, "warn  %s imported but not removed: %v\n", filepath.Base(path), err)
+		}
+	}
+	return "", nil
+}
+
+// requeue enqueues extraction again for a document an earlier run imported
+// but left queued, when its enqueue failed after the row was created. The
+// task is unique per document, so one still waiting is not doubled.
+func (imp *legacyImporter) requeue(ctx context.Context, doc *repository.Document) (string, error) {
+	if doc.Status != repository.StatusQueued {
+		return "already imported", nil
+	}
+	if imp.dryRun {
+		return "already imported, would queue extraction again", nil
+	}
+	queued, err := queue.EnqueueExtract(ctx, imp.queue, queue.ExtractPayload{DocumentID: doc.ID})
+	if err != nil {
+		return "", err
+	}
+	if !queued {
+		return "already imported, extraction pending", nil
+	}
+	return "already imported, extraction queued again", nil
+}
+
+// legacyRecords reads the standalone server's file records by id, from its
+// SQLite store when VAULTDROP_SQLITE_PATH is set and from its snapshot when
+// VAULTDROP_SNAPSHOT_PATH is. It returns nil when neither is.
+func legacyRecords(cfg *config.Config) (map[string]model.FileRecord, error) {
+	var store storage.FileStore
+	switch {
+	case cfg.SQLitePath != "":
+		// OpenSQLite would create a missing database, hiding a wrong path.
+		if _, err := os.Stat(cfg.SQLitePath); err != nil {
+			return nil, fmt.Errorf("VAULTDROP_SQLITE_PATH: %w", err)
+		}
+		db, err := storage.OpenSQLite(cfg.SQLitePath)
+		if err != nil {
+			return nil, err
+		}
+		defer db.Close()
+		store = db
+	case cfg.SnapshotPath != "":
+		memory := storage.NewMemoryStore()
+		if _, err := memory.LoadSnapshot(cfg.SnapshotPath); err != nil {
+			return nil, err
+		}
+		store = memory
+	default:
+		return nil, nil
+	}
+	records := make(map[string]model.FileRecord)
+	filter := storage.ListFilter{Ascending: true, Limit: 500}
+	for {
+		page, err := store.List(filter)
+		if err != nil {
+			return nil, fmt.Errorf("list legacy records: %w", err)
+		}
+		for _, rec := range page.Files {
+			records[rec.ID] = rec
+		}
+		if page.NextCursor == "" {
+			return records, nil
+		}
+		filter.Cursor = page.NextCursor
+	}
+}
+
+// legacyDocumentID maps a standalone server file id (16 random bytes, hex
+// encoded) to the UUID form the document table uses. Other names are not
+// legacy uploads.
+func legacyDocumentID(name string) (string, bool) {
+	raw, err := hex.DecodeString(name)
+	if err != nil || len(raw) != 16 {
+		return "", false
+	}
+	id, err := uuid.FromBytes(raw)
+	if err != nil {
+		return "", false
+	}
+	return id.String(), true
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/spf13/cobra"
+)
+
+// logColors are ANSI foreground colors assigned to services in order.
+var logColors = []string{"36", "33", "32", "35", "34", "31", "96", "93"}
+
+type logOptions struct {
+	follow  bool
+	since   string
+	tail    string
+	grep    string
+	noColor bool
+	rawJSON bool
+}
+
+func newLogsCmd() *cobra.Command {
+	var opts logOptions
+	cmd := &cobra.Command{
+		Use:   "logs [service...]",
+		Short: "Tail logs from docker-compose services",
+		Long: `Logs tails every service (or the ones given) concurrently, prefixing each
+line with the service name in its own color. Lines can be filtered with a
+regular expression, and JSON log lines are rendered as
+"time level message key=value" unless --raw-json is set.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			var filter *regexp.Regexp
+			if opts.grep != "" {
+				var err error
+				if filter, err = regexp.Compile(opts.grep); err != nil {
+					return fmt.Errorf("invalid --grep: %w", err)
+				}
+			}
+			services := args
+			if len(services) == 0 {
+				var err error
+				if services, err = composeServices(ctx); err != nil {
+					return err
+				}
+			}
+			out := cmd.OutOrStdout()
+			color := !opts.noColor && isTerminal(os.Stdout)
+			return tailServices(ctx, services, opts, filter, &lockedWriter{w: out}, color)
+		},
+	}
+	cmd.Flags().BoolVar(&opts.follow, "follow", false, "Stream logs continuously")
+	cmd.Flags().StringVar(&opts.since, "since", "", "Show logs since a timestamp or relative duration (e.g. 10m)")
+	cmd.Flags().StringVar(&opts.tail, "tail", "all", "Number of lines to show from the end of each service's logs")
+	cmd.Flags().StringVar(&opts.grep, "grep", "", "Only show lines matching this regular expression")
+	cmd.Flags().BoolVar(&opts.noColor, "no-color", false, "Disable colored prefixes (also off when stdout is not a terminal)")
+	cmd.Flags().BoolVar(&opts.rawJSON, "raw-json", false, "Print JSON log lines as-is")
+	return cmd
+}
+
+func composeServices(ctx context.Context) ([]string, error) {
+	out, err := exec.CommandContext(ctx, "docker", "compose", "-f", composeFile, "config", "--services").Output()
+	if err != nil {
+		return nil, fmt.Errorf("list compose services: %w", err)
+	}
+	services := strings.Fields(string(out))
+	sort.Strings(services)
+	return services, nil
+}
+
+// tailServices runs one docker compose logs process per service and merges
+// their output line by line.
+func tailServices(ctx context.Context, services []string, opts logOptions, filter *regexp.Regexp, out *lockedWriter, color bool) error {
+	width := 0
+	for _, svc := range services {
+		if len(svc) > width {
+			width = len(svc)
+		}
+	}
+	var wg sync.WaitGroup
+	errs := make(chan error, len(services))
+	for i, svc := range services {
+		prefix := fmt.Sprintf("%-*s | ", width, svc)
+		if color {
+			prefix = fmt.Sprintf("\x1b[%sm%s\x1b[0m", logColors[i%len(logColors)], prefix)
+		}
+		wg.Add(1)
+		go func(svc, prefix string) {
+			defer wg.Done()
+			if err := tailService(ctx, svc, opts, func(line string) {
+				if !opts.rawJSON {
+					line = prettyJSONLine(line)
+				}
+				if filter != nil && !filter.MatchString(line) {
+					return
+				}
+				out.writeLine(prefix + line)
+			}); err != nil && ctx.Err() == nil {
+				errs <- fmt.Errorf("%s: %w", svc, err)
+			}
+		}(svc, prefix)
+	}
+	wg.Wait()
+	close(errs)
+	return <-errs
+}
+
+func tailService(ctx context.Context, service string, opts logOptions, emit func(string)) error {
+	args := []string{"compose", "-f", composeFile, "logs", "--no-color", "--no-log-prefix", "--tail", opts.tail}
+	if opts.follow {
+		args = append(args, "--follow")
+	}
+	if opts.since != "" {
+		args = append(args, "--since", opts.since)
+	}
+	args = append(args, service)
+	execCmd := exec.CommandContext(ctx, "docker", args...)
+	execCmd.Env = append(os.Environ(), profileEnv()...)
+	var stderr bytes.Buffer
+	execCmd.Stderr = &stderr
+	stdout, err := execCmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := execCmd.Start(); err != nil {
+		return err
+	}
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		emit(scanner.Text())
+	}
+	if err := execCmd.Wait(); err != nil {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return scanner.Err()
+}
+
+// prettyJSONLine renders a JSON object log line as
+// "time level message key=value ...". Other lines are returned unchanged.
+func prettyJSONLine(line string) string {
+	trimmed := strings.TrimSpace(line)
+	if !strings.HasPrefix(trimmed, "{") {
+		return line
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(trimmed), &fields); err != nil {
+		return line
+	}
+	var parts []string
+	for _, keys := range [][]string{{"time", "ts", "timestamp"}, {"level", "lvl", "severity"}, {"msg", "message"}} {
+		for _, key := range keys {
+			if v, ok := fields[key]; ok {
+				parts = append(parts, fmt.Sprint(v))
+				delete(fields, key)
+				break
+			}
+		}
+	}
+	rest := make([]string, 0, len(fields))
+	for key := range fields {
+		rest = append(rest, key)
+	}
+	sort.Strings(rest)
+	for _, key := range rest {
+		value, _ := json.Marshal(fields[key])
+		parts = append(parts, key+"="+strings.Trim(string(value), `"`))
+	}
+	return strings.Join(parts, " ")
+}
+
+// lockedWriter serializes whole lines from concurrent tails.
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (l *lockedWriter) writeLine(line string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	fmt.Fprintln(l.w, line)
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
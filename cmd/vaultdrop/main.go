@@ -31,8 +31,13 @@ func newRootCommand() *cobra.Command {
 		Long: `VaultDrop CLI orchestrates common development workflows such as building the Docker stack,
 starting or stopping services, running tests, and launching the binaries directly.`,
 		SilenceUsage: true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return loadProfile(cmd)
+		},
 	}
-	cmd.PersistentFlags().StringVarP(&composeFile, "compose-file", "f", "docker-compose.yml", "Compose file to use for stack commands")
+	cmd.PersistentFlags().StringVar(&configPath, "config", defaultConfigPath(), "CLI config file with named profiles")
+	cmd.PersistentFlags().StringVar(&profileName, "profile", "", "Profile to use (defaults to $VAULTDROP_PROFILE or the file's `profile` key)")
+	cmd.PersistentFlags().StringVarP(&composeFile, "compose-file", "f", defaultComposeFile, "Compose file to use for stack commands (overrides the profile)")
 	cmd.AddCommand(
 		newBuildCmd(),
 		newUpCmd(),
@@ -40,6 +45,18 @@ starting or stopping services, running tests, and launching the binaries directl
 		newLogsCmd(),
 		newTestCmd(),
 		newRunCmd(),
+		newAPICmd(),
+		newConfigCmd(),
+		newMigrateCmd(),
+		newStatusCmd(),
+		newE2ECmd(),
+		newLegacyCmd(),
+		newWatchCmd(),
+		newQueueCmd(),
+		newSecretCmd(),
+		newAdminCmd(),
+		newExportCmd(),
+		newImportCmd(),
 	)
 	return cmd
 }
@@ -105,25 +122,6 @@ func newDownCmd() *cobra.Command {
 	return cmd
 }
 
-func newLogsCmd() *cobra.Command {
-	var follow bool
-	cmd := &cobra.Command{
-		Use:   "logs [service...]",
-		Short: "Tail logs from docker-compose services",
-		RunE: func(cmd *cobra.Command, args []string) error {
-			ctx := cmd.Context()
-			composeArgs := []string{"compose", "-f", composeFile, "logs"}
-			if follow {
-				composeArgs = append(composeArgs, "-f")
-			}
-			composeArgs = append(composeArgs, args...)
-			return runCommand(ctx, "docker", composeArgs...)
-		},
-	}
-	cmd.Flags().BoolVarP(&follow, "follow", "f", false, "Stream logs continuously")
-	return cmd
-}
-
 func newTestCmd() *cobra.Command {
 	var race bool
 	var cover bool
@@ -183,5 +181,8 @@ func runCommand(ctx context.Context, name string, args ...string) error {
 	execCmd.Stdout = os.Stdout
 	execCmd.Stderr = os.Stderr
 	execCmd.Stdin = os.Stdin
+	if env := profileEnv(); len(env) > 0 {
+		execCmd.Env = append(os.Environ(), env...)
+	}
 	return execCmd.Run()
 }
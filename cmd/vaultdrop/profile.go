@@ -0,0 +1,180 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const (
+	defaultProfile     = "default"
+	defaultComposeFile = "docker-compose.yml"
+	defaultAPIServer   = "http://localhost:8080"
+)
+
+// profileKeys are the settings a profile may define besides env overrides.
+var profileKeys = []string{"compose_file", "api_url", "api_key"}
+
+var (
+	configPath  string
+	profileName string
+	cliConfig   = viper.New()
+)
+
+// loadProfile reads the CLI config file (if present) and resolves the active
+// profile into the package-level flag variables. Explicit flags win over
+// environment variables, which win over the profile.
+func loadProfile(cmd *cobra.Command) error {
+	cliConfig.SetConfigFile(configPath)
+	cliConfig.SetConfigType("yaml")
+	if err := cliConfig.ReadInConfig(); err != nil && !errors.Is(err, os.ErrNotExist) {
+		var notFound viper.ConfigFileNotFoundError
+		if !errors.As(err, &notFound) {
+			return fmt.Errorf("read %s: %w", configPath, err)
+		}
+	}
+	if profileName == "" {
+		profileName = envOr("VAULTDROP_PROFILE", cliConfig.GetString("profile"))
+	}
+	if profileName == "" {
+		profileName = defaultProfile
+	}
+	flags := cmd.Flags()
+	if !flags.Changed("compose-file") {
+		composeFile = firstNonEmpty(profileValue("compose_file"), defaultComposeFile)
+	}
+	if flags.Lookup("server") != nil && !flags.Changed("server") {
+		apiServer = firstNonEmpty(os.Getenv("VAULTDROP_SERVER"), profileValue("api_url"), defaultAPIServer)
+	}
+	if flags.Lookup("api-key") != nil && !flags.Changed("api-key") {
+		apiKey = firstNonEmpty(os.Getenv("VAULTDROP_API_KEY"), profileValue("api_key"))
+	}
+	return nil
+}
+
+func profileValue(key string) string {
+	return cliConfig.GetString(fmt.Sprintf("profiles.%s.%s", profileName, key))
+}
+
+// profileEnv returns the profile's env overrides as KEY=value pairs. Viper
+// lower-cases keys, so names are upper-cased again here.
+func profileEnv() []string {
+	raw := cliConfig.GetStringMapString(fmt.Sprintf("profiles.%s.env", profileName))
+	out := make([]string, 0, len(raw))
+	for k, v := range raw {
+		out = append(out, strings.ToUpper(k)+"="+v)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// applyProfileEnv exports the profile's env overrides into this process for
+// commands that load the service config directly. Variables already set win.
+func applyProfileEnv() {
+	for _, kv := range profileEnv() {
+		key, value, _ := strings.Cut(kv, "=")
+		if os.Getenv(key) == "" {
+			os.Setenv(key, value)
+		}
+	}
+}
+
+func defaultConfigPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ".vaultdrop.yaml"
+	}
+	return filepath.Join(home, ".vaultdrop.yaml")
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func newConfigCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "View or edit the CLI config file and profiles",
+	}
+	cmd.AddCommand(
+		&cobra.Command{
+			Use:   "view",
+			Short: "Show the resolved settings of the active profile",
+			Args:  cobra.NoArgs,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				out := cmd.OutOrStdout()
+				fmt.Fprintf(out, "config_file:  %s\n", configPath)
+				fmt.Fprintf(out, "profile:      %s\n", profileName)
+				fmt.Fprintf(out, "compose_file: %s\n", composeFile)
+				fmt.Fprintf(out, "api_url:      %s\n", firstNonEmpty(os.Getenv("VAULTDROP_SERVER"), profileValue("api_url"), defaultAPIServer))
+				if key := firstNonEmpty(os.Getenv("VAULTDROP_API_KEY"), profileValue("api_key")); key != "" {
+					fmt.Fprintln(out, "api_key:      (set)")
+				}
+				for _, kv := range profileEnv() {
+					fmt.Fprintf(out, "env:          %s\n", kv)
+				}
+				return nil
+			},
+		},
+		&cobra.Command{
+			Use:   "get <key>",
+			Short: "Print a value from the active profile (compose_file, api_url, api_key, env.NAME)",
+			Args:  cobra.ExactArgs(1),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				key, err := profileSettingKey(args[0])
+				if err != nil {
+					return err
+				}
+				fmt.Fprintln(cmd.OutOrStdout(), cliConfig.GetString(key))
+				return nil
+			},
+		},
+		&cobra.Command{
+			Use:   "set <key> <value>",
+			Short: "Set a value on the active profile and save the config file",
+			Args:  cobra.ExactArgs(2),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				key, err := profileSettingKey(args[0])
+				if err != nil {
+					return err
+				}
+				cliConfig.Set(key, args[1])
+				return cliConfig.WriteConfigAs(configPath)
+			},
+		},
+		&cobra.Command{
+			Use:   "use <profile>",
+			Short: "Make a profile the default",
+			Args:  cobra.ExactArgs(1),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				cliConfig.Set("profile", args[0])
+				return cliConfig.WriteConfigAs(configPath)
+			},
+		},
+	)
+	return cmd
+}
+
+func profileSettingKey(name string) (string, error) {
+	prefix := fmt.Sprintf("profiles.%s.", profileName)
+	if env, ok := strings.CutPrefix(name, "env."); ok && env != "" {
+		return prefix + "env." + env, nil
+	}
+	for _, key := range profileKeys {
+		if key == name {
+			return prefix + key, nil
+		}
+	}
+	return "", fmt.Errorf("unknown key %q (want one of %s, env.NAME)", name, strings.Join(profileKeys, ", "))
+}
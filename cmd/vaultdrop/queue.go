@@ -0,0 +1,248 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/spf13/cobra"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/queue"
+)
+
+// taskStates are the asynq task states the queue commands understand.
+// "archived" is where tasks land once they exhaust their retries.
+var taskStates = []string{"pending", "active", "scheduled", "retry", "archived", "completed"}
+
+var queueName string
+
+func newQueueCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "queue",
+		Short: "Inspect and manage the job queue",
+		Long: `Queue commands talk to Redis directly through the asynq inspector, using
+VAULTDROP_REDIS_ADDR, VAULTDROP_REDIS_PASSWORD and VAULTDROP_REDIS_DB from the
+environment or the active profile.`,
+	}
+	cmd.PersistentFlags().StringVarP(&queueName, "queue", "q", queue.ExtractQueue, "Queue name")
+	cmd.AddCommand(newQueueStatsCmd(), newQueueListCmd(), newQueueRetryCmd(), newQueueDeleteCmd())
+	return cmd
+}
+
+func newInspector() (*asynq.Inspector, error) {
+	var opt asynq.RedisClientOpt
+	rawDB, err := resolveEnv("VAULTDROP_REDIS_DB", "0")
+	if err != nil {
+		return nil, err
+	}
+	opt.DB, _ = strconv.Atoi(rawDB)
+	if opt.Addr, err = resolveEnv("VAULTDROP_REDIS_ADDR", "localhost:6379"); err != nil {
+		return nil, err
+	}
+	if opt.Password, err = resolveEnv("VAULTDROP_REDIS_PASSWORD", ""); err != nil {
+		return nil, err
+	}
+	return asynq.NewInspector(opt), nil
+}
+
+func newQueueStatsCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "stats",
+		Short: "Show task counts per state for every queue",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			inspector, err := newInspector()
+			if err != nil {
+				return err
+			}
+			defer inspector.Close()
+			queues, err := inspector.Queues()
+			if err != nil {
+				return err
+			}
+			tw := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+			fmt.Fprintln(tw, "QUEUE\tPENDING\tACTIVE\tSCHEDULED\tRETRY\tARCHIVED\tCOMPLETED\tPROCESSED TODAY\tFAILED TODAY\tLATENCY\tPAUSED")
+			for _, name := range queues {
+				info, err := inspector.GetQueueInfo(name)
+				if err != nil {
+					return err
+				}
+				fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t%d\t%d\t%d\t%d\t%s\t%v\n",
+					info.Queue, info.Pending, info.Active, info.Scheduled, info.Retry, info.Archived,
+					info.Completed, info.Processed, info.Failed, info.Latency.Round(time.Millisecond), info.Paused)
+			}
+			if len(queues) == 0 {
+				fmt.Fprintln(tw, "(no queues yet)")
+			}
+			return tw.Flush()
+		},
+	}
+}
+
+func newQueueListCmd() *cobra.Command {
+	var state string
+	var limit int
+	cmd := &cobra.Command{
+		Use:   "ls",
+		Short: "List tasks in a queue by state",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			inspector, err := newInspector()
+			if err != nil {
+				return err
+			}
+			defer inspector.Close()
+			list, err := taskLister(inspector, state)
+			if err != nil {
+				return err
+			}
+			tasks, err := list(queueName, asynq.PageSize(limit))
+			if err != nil {
+				return err
+			}
+			tw := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+			fmt.Fprintln(tw, "ID\tTYPE\tSTATE\tRETRIED\tNEXT RUN\tLAST ERROR")
+			for _, t := range tasks {
+				next := "-"
+				if !t.NextProcessAt.IsZero() {
+					next = t.NextProcessAt.Local().Format("2006-01-02 15:04:05")
+				}
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%d/%d\t%s\t%s\n",
+					t.ID, t.Type, t.State, t.Retried, t.MaxRetry, next, oneLine(t.LastErr, 80))
+			}
+			return tw.Flush()
+		},
+	}
+	cmd.Flags().StringVar(&state, "state", "pending", "Task state: "+strings.Join(taskStates, ", "))
+	cmd.Flags().IntVar(&limit, "limit", 50, "Maximum number of tasks to show")
+	return cmd
+}
+
+func newQueueRetryCmd() *cobra.Command {
+	var all bool
+	cmd := &cobra.Command{
+		Use:   "retry [task-id...]",
+		Short: "Run archived (dead) or retrying tasks again now",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if all == (len(args) > 0) {
+				return fmt.Errorf("pass task ids or --all")
+			}
+			inspector, err := newInspector()
+			if err != nil {
+				return err
+			}
+			defer inspector.Close()
+			out := cmd.OutOrStdout()
+			if all {
+				n, err := inspector.RunAllArchivedTasks(queueName)
+				if err != nil {
+					return err
+				}
+				fmt.Fprintf(out, "requeued %d archived tasks\n", n)
+				return nil
+			}
+			for _, id := range args {
+				if err := inspector.RunTask(queueName, id); err != nil {
+					return fmt.Errorf("retry %s: %w", id, err)
+				}
+				fmt.Fprintf(out, "requeued %s\n", id)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&all, "all", false, "Requeue every archived task in the queue")
+	return cmd
+}
+
+func newQueueDeleteCmd() *cobra.Command {
+	var state string
+	var all, yes bool
+	cmd := &cobra.Command{
+		Use:   "delete [task-id...]",
+		Short: "Delete tasks, or purge every task in one state with --all",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if all == (len(args) > 0) {
+				return fmt.Errorf("pass task ids or --all --state <state>")
+			}
+			inspector, err := newInspector()
+			if err != nil {
+				return err
+			}
+			defer inspector.Close()
+			out := cmd.OutOrStdout()
+			if !all {
+				for _, id := range args {
+					if err := inspector.DeleteTask(queueName, id); err != nil {
+						return fmt.Errorf("delete %s: %w", id, err)
+					}
+					fmt.Fprintf(out, "deleted %s\n", id)
+				}
+				return nil
+			}
+			purge, err := taskPurger(inspector, state)
+			if err != nil {
+				return err
+			}
+			if !yes {
+				return fmt.Errorf("refusing to purge all %s tasks in %q without --yes", state, queueName)
+			}
+			n, err := purge(queueName)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "deleted %d %s tasks\n", n, state)
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&all, "all", false, "Delete every task in --state")
+	cmd.Flags().StringVar(&state, "state", "archived", "State to purge with --all: pending, scheduled, retry, archived, completed")
+	cmd.Flags().BoolVar(&yes, "yes", false, "Confirm a purge with --all")
+	return cmd
+}
+
+func taskLister(inspector *asynq.Inspector, state string) (func(string, ...asynq.ListOption) ([]*asynq.TaskInfo, error), error) {
+	switch state {
+	case "pending":
+		return inspector.ListPendingTasks, nil
+	case "active":
+		return inspector.ListActiveTasks, nil
+	case "scheduled":
+		return inspector.ListScheduledTasks, nil
+	case "retry":
+		return inspector.ListRetryTasks, nil
+	case "archived", "dead":
+		return inspector.ListArchivedTasks, nil
+	case "completed":
+		return inspector.ListCompletedTasks, nil
+	}
+	return nil, fmt.Errorf("unknown state %q (want %s)", state, strings.Join(taskStates, ", "))
+}
+
+// taskPurger has no "active" case: running tasks must be cancelled, not
+// deleted.
+func taskPurger(inspector *asynq.Inspector, state string) (func(string) (int, error), error) {
+	switch state {
+	case "pending":
+		return inspector.DeleteAllPendingTasks, nil
+	case "scheduled":
+		return inspector.DeleteAllScheduledTasks, nil
+	case "retry":
+		return inspector.DeleteAllRetryTasks, nil
+	case "archived", "dead":
+		return inspector.DeleteAllArchivedTasks, nil
+	case "completed":
+		return inspector.DeleteAllCompletedTasks, nil
+	}
+	return nil, fmt.Errorf("cannot purge state %q", state)
+}
+
+func oneLine(s string, max int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if len(s) > max {
+		return s[:max-3] + "..."
+	}
+	return s
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+func newSecretCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "secret",
+		Short: "Generate signing secrets and API keys",
+	}
+	cmd.AddCommand(newSecretGenerateCmd())
+	return cmd
+}
+
+func newSecretGenerateCmd() *cobra.Command {
+	var (
+		size         int
+		format       string
+		envFile      string
+		key          string
+		principal    string
+		dockerSecret string
+		force        bool
+	)
+	cmd := &cobra.Command{
+		Use:   "generate",
+		Short: "Generate a random secret and print it or store it",
+		Long: `Generate reads --bytes of crypto/rand output and encodes it as hex, base64
+or base64url. By default the secret is printed. With --env-file it is written
+to a dotenv file as --key (VAULTDROP_SIGNING_SECRET unless changed), and with
+--docker-secret it is stored through docker secret create; in both cases it is
+not echoed.
+
+--principal turns the secret into an API key: the value becomes
+principal:secret and, in an env file, is appended to the existing
+comma-separated list instead of replacing it.`,
+		Example: `  vaultdrop secret generate
+  vaultdrop secret generate --env-file .env
+  vaultdrop secret generate --principal ci --key VAULTDROP_API_KEYS --env-file .env
+  vaultdrop secret generate --docker-secret vaultdrop_signing_secret`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if size < 16 {
+				return fmt.Errorf("--bytes must be at least 16")
+			}
+			secret, err := generateSecret(size, format)
+			if err != nil {
+				return err
+			}
+			if principal != "" {
+				if strings.ContainsAny(principal, ":,") {
+					return fmt.Errorf("principal must not contain ':' or ','")
+				}
+				secret = principal + ":" + secret
+			}
+			out := cmd.OutOrStdout()
+			if envFile == "" && dockerSecret == "" {
+				fmt.Fprintln(out, secret)
+				return nil
+			}
+			if envFile != "" {
+				if err := writeEnvSecret(envFile, key, secret, principal != "", force); err != nil {
+					return err
+				}
+				fmt.Fprintf(out, "wrote %s to %s\n", key, envFile)
+			}
+			if dockerSecret != "" {
+				docker := exec.CommandContext(cmd.Context(), "docker", "secret", "create", dockerSecret, "-")
+				docker.Stdin = strings.NewReader(secret)
+				docker.Stderr = cmd.ErrOrStderr()
+				if err := docker.Run(); err != nil {
+					return fmt.Errorf("docker secret create: %w", err)
+				}
+				fmt.Fprintf(out, "created docker secret %s\n", dockerSecret)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().IntVar(&size, "bytes", 32, "Random bytes in the secret (at least 16)")
+	cmd.Flags().StringVar(&format, "format", "hex", "Encoding: hex, base64 or base64url")
+	cmd.Flags().StringVar(&envFile, "env-file", "", "Write the secret into this dotenv file instead of printing it")
+	cmd.Flags().StringVar(&key, "key", "VAULTDROP_SIGNING_SECRET", "Variable name to set in --env-file")
+	cmd.Flags().StringVar(&principal, "principal", "", "Emit an API key pair principal:secret for VAULTDROP_API_KEYS")
+	cmd.Flags().StringVar(&dockerSecret, "docker-secret", "", "Store the secret with docker secret create under this name")
+	cmd.Flags().BoolVar(&force, "force", false, "Replace an existing value for --key in --env-file")
+	return cmd
+}
+
+func generateSecret(size int, format string) (string, error) {
+	buf := make([]byte, size)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("read random bytes: %w", err)
+	}
+	switch format {
+	case "hex":
+		return hex.EncodeToString(buf), nil
+	case "base64":
+		return base64.StdEncoding.EncodeToString(buf), nil
+	case "base64url":
+		return base64.RawURLEncoding.EncodeToString(buf), nil
+	}
+	return "", fmt.Errorf("unknown format %q (want hex, base64 or base64url)", format)
+}
+
+// writeEnvSecret sets key in a dotenv file, keeping every other line as is.
+// An existing value is only replaced with force; with appendList the new
+// value is added to the existing comma-separated list instead. The file is
+// created, or tightened, to mode 0600.
+func writeEnvSecret(path, key, value string, appendList, force bool) error {
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	var out bytes.Buffer
+	found := false
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		name, current, ok := strings.Cut(strings.TrimPrefix(strings.TrimSpace(line), "export "), "=")
+		if ok && strings.TrimSpace(name) == key && !found {
+			found = true
+			current = strings.Trim(strings.TrimSpace(current), `"'`)
+			switch {
+			case appendList && current != "":
+				line = key + "=" + current + "," + value
+			case current != "" && !force:
+				return fmt.Errorf("%s already has %s; pass --force to replace it", path, key)
+			default:
+				line = key + "=" + value
+			}
+		}
+		out.WriteString(line)
+		out.WriteByte('\n')
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if !found {
+		fmt.Fprintf(&out, "%s=%s\n", key, value)
+	}
+	if err := os.WriteFile(path, out.Bytes(), 0o600); err != nil {
+		return err
+	}
+	return os.Chmod(path, 0o600)
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/cobra"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/database"
+)
+
+const probeTimeout = 3 * time.Second
+
+// composeService is the subset of `docker compose ps --format json` we show.
+type composeService struct {
+	Service string `json:"Service"`
+	State   string `json:"State"`
+	Health  string `json:"Health"`
+}
+
+// probeResult is one health check against a running service.
+type probeResult struct {
+	ok     bool
+	detail string
+}
+
+func newStatusCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "status",
+		Short: "Show container state and probe each service's health",
+		Long: `Status runs docker compose ps, then checks the API health endpoint, MinIO's
+liveness endpoint, Redis PING and Postgres connectivity using the same
+addresses the binaries would (environment, then profile env, then defaults).
+It exits non-zero when any probe fails.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			containers, psErr := composePS(ctx)
+
+			probes := map[string]probeResult{
+				"postgres": probePostgresEnv(ctx),
+				"redis":    probeRedisEnv(ctx),
+				"minio":    probeMinioEnv(ctx),
+				"api":      probeAPI(ctx, firstNonEmpty(os.Getenv("VAULTDROP_SERVER"), profileValue("api_url"), defaultAPIServer)),
+			}
+
+			tw := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+			fmt.Fprintln(tw, "SERVICE\tCONTAINER\tPROBE\tDETAIL")
+			failed := 0
+			for _, name := range []string{"postgres", "redis", "minio", "api", "worker"} {
+				state := "-"
+				if c, ok := containers[name]; ok {
+					state = c.State
+					if c.Health != "" {
+						state += " (" + c.Health + ")"
+					}
+				}
+				probe, probed := probes[name]
+				status, detail := "n/a", ""
+				if probed {
+					status, detail = "up", probe.detail
+					if !probe.ok {
+						status = "down"
+						failed++
+					}
+				}
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", name, state, status, detail)
+			}
+			if err := tw.Flush(); err != nil {
+				return err
+			}
+			if psErr != nil {
+				fmt.Fprintf(cmd.ErrOrStderr(), "docker compose ps: %v\n", psErr)
+			}
+			if failed > 0 {
+				return fmt.Errorf("%d service(s) down", failed)
+			}
+			return nil
+		},
+	}
+}
+
+// composePS returns container state keyed by compose service name. Older
+// compose releases print a JSON array, newer ones one object per line.
+func composePS(ctx context.Context) (map[string]composeService, error) {
+	var stdout, stderr bytes.Buffer
+	execCmd := exec.CommandContext(ctx, "docker", "compose", "-f", composeFile, "ps", "--all", "--format", "json")
+	execCmd.Stdout = &stdout
+	execCmd.Stderr = &stderr
+	if err := execCmd.Run(); err != nil {
+		return nil, fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	var services []composeService
+	data := bytes.TrimSpace(stdout.Bytes())
+	if bytes.HasPrefix(data, []byte("[")) {
+		if err := json.Unmarshal(data, &services); err != nil {
+			return nil, err
+		}
+	} else {
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		for scanner.Scan() {
+			var svc composeService
+			if err := json.Unmarshal(scanner.Bytes(), &svc); err != nil {
+				return nil, err
+			}
+			services = append(services, svc)
+		}
+	}
+	out := make(map[string]composeService, len(services))
+	for _, svc := range services {
+		out[svc.Service] = svc
+	}
+	return out, nil
+}
+
+// probePostgresEnv, probeRedisEnv and probeMinioEnv resolve each service's
+// settings the way the binaries would; a setting that cannot be read fails
+// the probe with the reason.
+func probePostgresEnv(ctx context.Context) probeResult {
+	dsn, err := resolveDatabaseURL()
+	if err != nil {
+		return probeResult{detail: err.Error()}
+	}
+	return probePostgres(ctx, dsn)
+}
+
+func probeRedisEnv(ctx context.Context) probeResult {
+	addr, err := resolveEnv("VAULTDROP_REDIS_ADDR", "localhost:6379")
+	if err != nil {
+		return probeResult{detail: err.Error()}
+	}
+	password, err := resolveEnv("VAULTDROP_REDIS_PASSWORD", "")
+	if err != nil {
+		return probeResult{detail: err.Error()}
+	}
+	return probeRedis(ctx, addr, password)
+}
+
+func probeMinioEnv(ctx context.Context) probeResult {
+	endpoint, err := resolveEnv("VAULTDROP_S3_ENDPOINT", "localhost:9000")
+	if err != nil {
+		return probeResult{detail: err.Error()}
+	}
+	useSSL, err := resolveEnv("VAULTDROP_S3_USE_SSL", "false")
+	if err != nil {
+		return probeResult{detail: err.Error()}
+	}
+	return probeHTTP(ctx, minioHealthURL(endpoint, useSSL == "true"), nil)
+}
+
+func probePostgres(ctx context.Context, dsn string) probeResult {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+	pool, err := pgxpool.New(ctx, dsn)
+	if err != nil {
+		return probeResult{detail: err.Error()}
+	}
+	defer pool.Close()
+	var version string
+	if err := pool.QueryRow(ctx, "SHOW server_version").Scan(&version); err != nil {
+		return probeResult{detail: err.Error()}
+	}
+	detail := "postgres " + version
+	if info, err := database.ReadSchemaInfo(ctx, pool); err == nil && info != nil {
+		detail += fmt.Sprintf(", schema v%d", info.Version)
+	}
+	return probeResult{ok: true, detail: detail}
+}
+
+func probeRedis(ctx context.Context, addr, password string) probeResult {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+	rdb := redis.NewClient(&redis.Options{Addr: addr, Password: password})
+	defer rdb.Close()
+	if err := rdb.Ping(ctx).Err(); err != nil {
+		return probeResult{detail: err.Error()}
+	}
+	detail := "PONG"
+	if info, err := rdb.Info(ctx, "server").Result(); err == nil {
+		for _, line := range strings.Split(info, "\n") {
+			if v, ok := strings.CutPrefix(strings.TrimSpace(line), "redis_version:"); ok {
+				detail = "redis " + v
+			}
+		}
+	}
+	return probeResult{ok: true, detail: detail}
+}
+
+func probeAPI(ctx context.Context, baseURL string) probeResult {
+	var health struct {
+		Status string `json:"status"`
+		Mode   string `json:"mode"`
+	}
+	res := probeHTTP(ctx, strings.TrimRight(baseURL, "/")+"/healthz", &health)
+	if res.ok && health.Mode != "" {
+		res.detail = fmt.Sprintf("%s (%s)", health.Status, health.Mode)
+	}
+	return res
+}
+
+// probeHTTP GETs url and reports success on a 2xx answer, decoding the JSON
+// body into into unless it is nil.
+func probeHTTP(ctx context.Context, url string, into interface{}) probeResult {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return probeResult{detail: err.Error()}
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return probeResult{detail: err.Error()}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return probeResult{detail: resp.Status}
+	}
+	detail := resp.Status
+	if server := resp.Header.Get("Server"); server != "" {
+		detail = server
+	}
+	if into != nil {
+		_ = json.NewDecoder(io.LimitReader(resp.Body, 1<<16)).Decode(into)
+	}
+	return probeResult{ok: true, detail: detail}
+}
+
+func minioHealthURL(endpoint string, useSSL bool) string {
+	scheme := "http"
+	if useSSL {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s/minio/health/live", scheme, endpoint)
+}
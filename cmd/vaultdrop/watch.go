@@ -0,0 +1,303 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/cobra"
+)
+
+// watchServices maps the services watch can run to their main packages.
+var watchServices = map[string]string{
+	"api":    "./cmd/api",
+	"worker": "./cmd/worker",
+	"server": "./cmd/server",
+}
+
+const (
+	watchDebounce    = 300 * time.Millisecond
+	watchStopTimeout = 5 * time.Second
+)
+
+func newWatchCmd() *cobra.Command {
+	var excludes []string
+	cmd := &cobra.Command{
+		Use:   "watch [service...]",
+		Short: "Rebuild and restart api/worker/server when their sources change",
+		Long: `Watch builds the given services (default: api and worker), runs them, and
+rebuilds and restarts a service whenever a file in one of the packages it
+imports changes. Each service only reacts to its own dependencies, as
+reported by go list -deps, so editing the legacy server does not restart the
+API. A failed build leaves the running process alone.`,
+		ValidArgs: []string{"api", "worker", "server"},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 {
+				args = []string{"api", "worker"}
+			}
+			binDir, err := os.MkdirTemp("", "vaultdrop-watch-*")
+			if err != nil {
+				return err
+			}
+			defer os.RemoveAll(binDir)
+
+			services := make([]*watchedService, 0, len(args))
+			for _, name := range args {
+				pkg, ok := watchServices[name]
+				if !ok {
+					return fmt.Errorf("unknown service %q (want api, worker or server)", name)
+				}
+				services = append(services, &watchedService{
+					name: name,
+					pkg:  pkg,
+					bin:  filepath.Join(binDir, name),
+					out:  cmd.OutOrStdout(),
+				})
+			}
+			return watchLoop(cmd.Context(), ".", services, excludes)
+		},
+	}
+	cmd.Flags().StringSliceVar(&excludes, "exclude", []string{"*_test.go", "*.md"}, "File name patterns that never trigger a rebuild")
+	return cmd
+}
+
+// watchLoop starts every service, then rebuilds the ones whose dependency
+// directories saw changes, debouncing bursts of events from editors.
+func watchLoop(ctx context.Context, root string, services []*watchedService, excludes []string) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+	if err := addWatchDirs(watcher, root); err != nil {
+		return err
+	}
+	for _, svc := range services {
+		svc.rebuild(ctx)
+	}
+	defer func() {
+		for _, svc := range services {
+			svc.stop()
+		}
+	}()
+
+	changed := map[string]bool{}
+	var debounce <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-watcher.Errors:
+			fmt.Fprintf(os.Stderr, "watch: %v\n", err)
+		case ev := <-watcher.Events:
+			if ev.Op&fsnotify.Create != 0 {
+				if info, err := os.Stat(ev.Name); err == nil && info.IsDir() {
+					_ = addWatchDirs(watcher, ev.Name)
+					continue
+				}
+			}
+			if ev.Op == fsnotify.Chmod || excluded(filepath.Base(ev.Name), excludes) {
+				continue
+			}
+			abs, err := filepath.Abs(ev.Name)
+			if err != nil {
+				continue
+			}
+			changed[abs] = true
+			debounce = time.After(watchDebounce)
+		case <-debounce:
+			for _, svc := range services {
+				if svc.affectedBy(changed) {
+					svc.rebuild(ctx)
+				}
+			}
+			changed = map[string]bool{}
+			debounce = nil
+		}
+	}
+}
+
+// addWatchDirs watches dir and its subdirectories; fsnotify is not recursive.
+func addWatchDirs(watcher *fsnotify.Watcher, dir string) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return err
+		}
+		name := d.Name()
+		if path != dir && (strings.HasPrefix(name, ".") || name == "vendor" || name == "node_modules" || name == "testdata") {
+			return filepath.SkipDir
+		}
+		return watcher.Add(path)
+	})
+}
+
+func excluded(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// watchedService is one binary under watch.
+type watchedService struct {
+	name string
+	pkg  string
+	bin  string
+	out  io.Writer
+
+	mu   sync.Mutex
+	proc *exec.Cmd
+	done chan struct{}
+	// deps holds the absolute directories of the module packages the
+	// service imports, refreshed after every build.
+	deps map[string]bool
+}
+
+func (s *watchedService) affectedBy(changed map[string]bool) bool {
+	for path := range changed {
+		base := filepath.Base(path)
+		if base == "go.mod" || base == "go.sum" || s.deps[filepath.Dir(path)] {
+			return true
+		}
+	}
+	return false
+}
+
+// rebuild compiles the service and, if that succeeds, replaces the running
+// process with the new binary.
+func (s *watchedService) rebuild(ctx context.Context) {
+	fmt.Fprintf(s.out, "[watch] building %s\n", s.name)
+	start := time.Now()
+	build := exec.CommandContext(ctx, "go", "build", "-o", s.bin+".next", s.pkg)
+	if output, err := build.CombinedOutput(); err != nil {
+		fmt.Fprintf(s.out, "[watch] %s build failed, keeping the previous binary:\n%s", s.name, output)
+		return
+	}
+	if deps, err := packageDirs(ctx, s.pkg); err == nil {
+		s.deps = deps
+	}
+	s.stop()
+	if err := os.Rename(s.bin+".next", s.bin); err != nil {
+		fmt.Fprintf(s.out, "[watch] %s: %v\n", s.name, err)
+		return
+	}
+	fmt.Fprintf(s.out, "[watch] built %s in %s, starting\n", s.name, time.Since(start).Round(time.Millisecond))
+	s.start()
+}
+
+func (s *watchedService) start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	proc := exec.Command(s.bin)
+	prefix := &prefixWriter{prefix: "[" + s.name + "] ", w: s.out}
+	proc.Stdout = prefix
+	proc.Stderr = prefix
+	proc.Env = append(os.Environ(), profileEnv()...)
+	if err := proc.Start(); err != nil {
+		fmt.Fprintf(s.out, "[watch] start %s: %v\n", s.name, err)
+		return
+	}
+	done := make(chan struct{})
+	go func() {
+		err := proc.Wait()
+		prefix.Flush()
+		if err != nil {
+			fmt.Fprintf(s.out, "[watch] %s exited: %v\n", s.name, err)
+		}
+		close(done)
+	}()
+	s.proc, s.done = proc, done
+}
+
+// stop interrupts the running process and kills it if it does not exit in
+// time, giving the services a chance to shut down gracefully.
+func (s *watchedService) stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.proc == nil {
+		return
+	}
+	select {
+	case <-s.done:
+	default:
+		_ = s.proc.Process.Signal(syscall.SIGINT)
+		select {
+		case <-s.done:
+		case <-time.After(watchStopTimeout):
+			_ = s.proc.Process.Kill()
+			<-s.done
+		}
+	}
+	s.proc, s.done = nil, nil
+}
+
+// packageDirs lists the directories of the module packages pkg depends on.
+func packageDirs(ctx context.Context, pkg string) (map[string]bool, error) {
+	out, err := exec.CommandContext(ctx, "go", "list", "-deps", "-f", "{{if not .Standard}}{{.Dir}}{{end}}", pkg).Output()
+	if err != nil {
+		return nil, err
+	}
+	modCache := strings.TrimSpace(goEnv(ctx, "GOMODCACHE"))
+	dirs := map[string]bool{}
+	for _, dir := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		if dir == "" || (modCache != "" && strings.HasPrefix(dir, modCache)) {
+			continue
+		}
+		dirs[dir] = true
+	}
+	return dirs, nil
+}
+
+func goEnv(ctx context.Context, key string) string {
+	out, _ := exec.CommandContext(ctx, "go", "env", key).Output()
+	return string(out)
+}
+
+// prefixWriter prefixes every complete line written to it, so output from
+// several services stays readable when interleaved.
+type prefixWriter struct {
+	mu     sync.Mutex
+	prefix string
+	w      io.Writer
+	buf    bytes.Buffer
+}
+
+func (p *prefixWriter) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.buf.Write(b)
+	for {
+		line, err := p.buf.ReadBytes('\n')
+		if err != nil {
+			// Keep the partial line for the next write.
+			p.buf.Reset()
+			p.buf.Write(line)
+			return len(b), nil
+		}
+		if _, err := fmt.Fprintf(p.w, "%s%s", p.prefix, line); err != nil {
+			return len(b), err
+		}
+	}
+}
+
+// Flush writes any trailing partial line.
+func (p *prefixWriter) Flush() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.buf.Len() > 0 {
+		fmt.Fprintf(p.w, "%s%s\n", p.prefix, p.buf.Bytes())
+		p.buf.Reset()
+	}
+}
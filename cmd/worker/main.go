@@ -2,21 +2,34 @@ package main
 
 import (
 	"context"
+	"io"
 	"log"
 	"os"
 	"os/signal"
 	"syscall"
 
 	"github.com/hibiken/asynq"
+	"github.com/redis/go-redis/v9"
 
 	"github.com/dharsanguruparan/VaultDrop/internal/config"
 	"github.com/dharsanguruparan/VaultDrop/internal/database"
+	"github.com/dharsanguruparan/VaultDrop/internal/events"
+	"github.com/dharsanguruparan/VaultDrop/internal/keys"
+	"github.com/dharsanguruparan/VaultDrop/internal/maintenance"
+	pdfutil "github.com/dharsanguruparan/VaultDrop/internal/pdf"
+	"github.com/dharsanguruparan/VaultDrop/internal/progress"
+	"github.com/dharsanguruparan/VaultDrop/internal/queue"
 	"github.com/dharsanguruparan/VaultDrop/internal/repository"
 	"github.com/dharsanguruparan/VaultDrop/internal/s3storage"
 	"github.com/dharsanguruparan/VaultDrop/internal/worker"
 )
 
 func main() {
+	// The worker re-executes itself to run sandboxed extractions.
+	if len(os.Args) > 1 && os.Args[1] == pdfutil.SandboxCommand {
+		os.Exit(pdfutil.RunSandbox(os.Args[2:]))
+	}
+
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
 
@@ -25,13 +38,23 @@ func main() {
 		log.Fatalf("load config: %v", err)
 	}
 
-	pool, err := database.Connect(ctx, cfg.DatabaseURL)
+	pool, err := database.Connect(ctx, cfg)
 	if err != nil {
 		log.Fatalf("connect database: %v", err)
 	}
 	defer pool.Close()
-	if err := database.EnsureSchema(ctx, pool); err != nil {
-		log.Fatalf("ensure schema: %v", err)
+	if err := database.CheckSchema(ctx, pool); err != nil {
+		log.Fatalf("check schema: %v", err)
+	}
+	if cfg.AutoMigrate {
+		if err := database.Migrate(ctx, pool); err != nil {
+			log.Fatalf("migrate database: %v", err)
+		}
+	}
+	// Without migrations, or after them, the schema must be the one this
+	// build expects.
+	if err := database.CheckSchemaCurrent(ctx, pool); err != nil {
+		log.Fatalf("check schema: %v", err)
 	}
 	repo := repository.NewDocumentRepository(pool)
 
@@ -42,16 +65,106 @@ func main() {
 	if err := store.EnsureBuckets(ctx); err != nil {
 		log.Fatalf("ensure buckets: %v", err)
 	}
+	if err := store.EnsureExportExpiry(ctx); err != nil {
+		log.Printf("export lifecycle rule not installed, relying on the expiry task alone: %v", err)
+	}
+	if cfg.S3ColdAfterDays > 0 {
+		if err := store.TransitionProcessed(ctx, cfg.S3ColdAfterDays, cfg.S3ColdStorageClass); err != nil {
+			log.Fatalf("VAULTDROP_S3_COLD_AFTER_DAYS: %v", err)
+		}
+	}
 
-	server := asynq.NewServer(asynq.RedisClientOpt{
+	redisOpt := asynq.RedisClientOpt{
 		Addr:     cfg.RedisAddr,
 		Password: cfg.RedisPassword,
 		DB:       cfg.RedisDB,
-	}, asynq.Config{
+	}
+	server := asynq.NewServer(redisOpt, asynq.Config{
 		Concurrency: cfg.ProcessingPool,
+		Queues:      worker.Queues(cfg.QueueWeights),
+	})
+	retention := worker.RetentionPolicy{
+		Raw:       cfg.RetainRaw,
+		Text:      cfg.RetainText,
+		Documents: cfg.RetainDocuments,
+		Archive:   cfg.ArchiveAfter,
+	}
+	rdb := redis.NewClient(&redis.Options{
+		Addr:     cfg.RedisAddr,
+		Password: cfg.RedisPassword,
+		DB:       cfg.RedisDB,
+	})
+	defer rdb.Close()
+	extractor, err := pdfutil.NewExtractor(cfg.ExtractBackend, cfg.PdftotextPath)
+	if err != nil {
+		log.Fatalf("VAULTDROP_EXTRACT_BACKEND: %v", err)
+	}
+	inspector := asynq.NewInspector(redisOpt)
+	defer inspector.Close()
+	monitor := worker.NewMonitor(cfg.ProcessingPool, map[string]func(context.Context) error{
+		"postgres": pool.Ping,
+		"redis":    func(ctx context.Context) error { return rdb.Ping(ctx).Err() },
+		"s3":       store.Ping,
+	}, inspector)
+	monitor.Collect(func(w io.Writer) { database.WritePoolMetrics(w, database.PoolStat{Name: "primary", Stat: pool.Stat()}) })
+	processor := worker.NewProcessor(repo, store, retention, progress.NewTracker(rdb), worker.ExtractOptions{
+		Extractor:   extractor,
+		Timeout:     cfg.ExtractTimeout,
+		Sandbox:     cfg.ExtractSandbox,
+		MemoryLimit: cfg.ExtractMemoryLimit,
+		Limits: pdfutil.Limits{
+			MaxPages:        cfg.PDFMaxPages,
+			MaxObjects:      cfg.PDFMaxObjects,
+			MaxDecompressed: cfg.PDFMaxDecompressed,
+		},
+		Layout:  cfg.ExtractLayout,
+		Observe: monitor.ObserveExtraction,
 	})
-	processor := worker.NewProcessor(repo, store)
+	publisher, err := events.New(cfg)
+	if err != nil {
+		log.Fatalf("VAULTDROP_EVENTS_DRIVER: %v", err)
+	}
+	defer publisher.Close()
+	processor.UseEvents(publisher)
+	keyring, err := keys.FromConfig(cfg)
+	if err != nil {
+		log.Fatalf("VAULTDROP_MASTER_KEYS: %v", err)
+	}
+	processor.UseKeys(keyring)
+	if cfg.CleanupInterval > 0 {
+		processor.UseCleanup(cfg.CleanupGrace, cfg.CleanupDryRun)
+		go maintenance.SweepTemp(ctx, cfg.CleanupInterval, cfg.CleanupGrace, cfg.CleanupDryRun)
+	}
 	mux := processor.Handler()
+	mux.Use(monitor.Middleware())
+	mux.Use(worker.TaskPolicy{Concurrency: cfg.TaskConcurrency, MaxRetry: cfg.TaskMaxRetry}.Middleware())
+
+	scheduler := asynq.NewScheduler(redisOpt, nil)
+	// The retention sweep runs without retention rules too: it also expires
+	// upload rejections.
+	if err := queue.ScheduleRetention(scheduler, cfg.RetentionInterval); err != nil {
+		log.Fatalf("schedule retention: %v", err)
+	}
+	if retention.Archive > 0 {
+		if err := queue.ScheduleArchive(scheduler, cfg.RetentionInterval); err != nil {
+			log.Fatalf("schedule archive: %v", err)
+		}
+	}
+	if cfg.CleanupInterval > 0 {
+		if err := queue.ScheduleCleanup(scheduler, cfg.CleanupInterval); err != nil {
+			log.Fatalf("schedule cleanup: %v", err)
+		}
+	}
+	if err := scheduler.Start(); err != nil {
+		log.Fatalf("start scheduler: %v", err)
+	}
+	defer scheduler.Shutdown()
+
+	go func() {
+		if err := monitor.Serve(ctx, cfg.WorkerAddress); err != nil {
+			log.Printf("worker monitoring stopped: %v", err)
+		}
+	}()
 
 	go func() {
 		<-ctx.Done()
@@ -0,0 +1,104 @@
+package api
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/httperr"
+	"github.com/dharsanguruparan/VaultDrop/internal/repository"
+)
+
+// Bounds of ?days= on GET /documents/{id}/analytics.
+const (
+	defaultAnalyticsDays = 30
+	maxAnalyticsDays     = 366
+)
+
+// accessStats is the part of DocumentRepository that keeps access counts.
+type accessStats interface {
+	RecordAccess(ctx context.Context, documentID string, kind repository.Access) error
+	AccessTotals(ctx context.Context, documentID string) (repository.AccessTotals, error)
+	ListAccessDays(ctx context.Context, documentID string, since time.Time) ([]repository.AccessDay, error)
+}
+
+// recordAccess counts a download or signed URL of the document. Analytics
+// never fail the request they count, so errors are only logged. A read-only
+// server writes nothing, so its accesses go uncounted.
+func (s *Server) recordAccess(ctx context.Context, id string, kind repository.Access) {
+	if s.cfg.ReadOnly {
+		return
+	}
+	if err := s.access.RecordAccess(context.WithoutCancel(ctx), id, kind); err != nil {
+		log.Printf("analytics for %s: %v", id, err)
+	}
+}
+
+// withAnalytics fills in doc.Analytics and returns the later of the
+// document's last change and its last access, for Last-Modified.
+func (s *Server) withAnalytics(ctx context.Context, doc *repository.Document) time.Time {
+	modified := doc.UpdatedAt
+	totals, err := s.access.AccessTotals(ctx, doc.ID)
+	if err != nil {
+		log.Printf("analytics for %s: %v", doc.ID, err)
+		return modified
+	}
+	doc.Analytics = &totals
+	if totals.LastAccessAt != nil && totals.LastAccessAt.After(modified) {
+		modified = *totals.LastAccessAt
+	}
+	return modified
+}
+
+// handleDocumentAnalytics serves GET /documents/{id}/analytics?days=30: the
+// document's download and signed-URL totals and their counts per UTC day
+// over the last days days. Scoped tokens may read a document but not see who
+// else did, so they are refused.
+func (s *Server) handleDocumentAnalytics(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodGet {
+		httperr.MethodNotAllowed(w)
+		return
+	}
+	if principalFrom(r.Context()).Grant != nil {
+		httperr.Forbidden(w, "analytics need a full API key")
+		return
+	}
+	days := defaultAnalyticsDays
+	if raw := r.URL.Query().Get("days"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxAnalyticsDays {
+			httperr.BadRequest(w, "days must be between 1 and 366")
+			return
+		}
+		days = n
+	}
+	if _, ok := s.lookupDocument(w, r, id); !ok {
+		return
+	}
+	totals, err := s.access.AccessTotals(r.Context(), id)
+	if err != nil {
+		log.Printf("analytics for %s: %v", id, err)
+		httperr.Internal(w, "failed to load analytics")
+		return
+	}
+	since := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, 1-days)
+	perDay, err := s.access.ListAccessDays(r.Context(), id, since)
+	if err != nil {
+		log.Printf("analytics for %s: %v", id, err)
+		httperr.Internal(w, "failed to load analytics")
+		return
+	}
+	if perDay == nil {
+		perDay = []repository.AccessDay{}
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"documentId":   id,
+		"downloads":    totals.Downloads,
+		"signedUrls":   totals.SignedURLs,
+		"lastAccessAt": totals.LastAccessAt,
+		"since":        since.Format("2006-01-02"),
+		"days":         perDay,
+	})
+}
@@ -0,0 +1,121 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/config"
+	"github.com/dharsanguruparan/VaultDrop/internal/repository"
+)
+
+// fakeAccess counts accesses in memory.
+type fakeAccess struct {
+	recorded []repository.Access
+	totals   repository.AccessTotals
+	err      error
+}
+
+func (f *fakeAccess) RecordAccess(ctx context.Context, documentID string, kind repository.Access) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.recorded = append(f.recorded, kind)
+	return nil
+}
+
+func (f *fakeAccess) AccessTotals(ctx context.Context, documentID string) (repository.AccessTotals, error) {
+	return f.totals, f.err
+}
+
+func (f *fakeAccess) ListAccessDays(ctx context.Context, documentID string, since time.Time) ([]repository.AccessDay, error) {
+	return nil, f.err
+}
+
+func TestRecordAccess(t *testing.T) {
+	access := &fakeAccess{}
+	s := &Server{cfg: &config.Config{}, access: access}
+	s.recordAccess(context.Background(), "a", repository.AccessDownload)
+	s.recordAccess(context.Background(), "a", repository.AccessSignedURL)
+	if len(access.recorded) != 2 || access.recorded[0] != repository.AccessDownload || access.recorded[1] != repository.AccessSignedURL {
+		t.Errorf("recorded %v", access.recorded)
+	}
+
+	// A read-only server writes nothing, not even analytics.
+	access = &fakeAccess{}
+	s = &Server{cfg: &config.Config{ReadOnly: true}, access: access}
+	s.recordAccess(context.Background(), "a", repository.AccessDownload)
+	if len(access.recorded) != 0 {
+		t.Errorf("read-only server recorded %v", access.recorded)
+	}
+
+	// Failures are logged, never returned to the request.
+	s = &Server{cfg: &config.Config{}, access: &fakeAccess{err: errors.New("db down")}}
+	s.recordAccess(context.Background(), "a", repository.AccessDownload)
+}
+
+func TestWithAnalyticsLastModified(t *testing.T) {
+	updated := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	later := updated.Add(time.Hour)
+	earlier := updated.Add(-time.Hour)
+	for _, tc := range []struct {
+		name   string
+		access *fakeAccess
+		want   time.Time
+	}{
+		{"never accessed", &fakeAccess{}, updated},
+		{"accessed since the last change", &fakeAccess{totals: repository.AccessTotals{Downloads: 2, LastAccessAt: &later}}, later},
+		{"changed since the last access", &fakeAccess{totals: repository.AccessTotals{SignedURLs: 1, LastAccessAt: &earlier}}, updated},
+		{"totals unavailable", &fakeAccess{err: errors.New("db down")}, updated},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s := &Server{cfg: &config.Config{}, access: tc.access}
+			doc := &repository.Document{ID: "a", UpdatedAt: updated}
+			modified := s.withAnalytics(context.Background(), doc)
+			if !modified.Equal(tc.want) {
+				t.Errorf("modified = %s, want %s", modified, tc.want)
+			}
+			if (doc.Analytics == nil) != (tc.access.err != nil) {
+				t.Errorf("analytics = %+v with error %v", doc.Analytics, tc.access.err)
+			}
+			rec := httptest.NewRecorder()
+			respondCachedJSON(rec, httptest.NewRequest(http.MethodGet, "/documents/a", nil), modified, doc)
+			if got := rec.Header().Get("Last-Modified"); got != tc.want.Format(http.TimeFormat) {
+				t.Errorf("Last-Modified = %q, want %q", got, tc.want.Format(http.TimeFormat))
+			}
+		})
+	}
+}
+
+func TestDocumentAnalyticsRejects(t *testing.T) {
+	s := &Server{cfg: &config.Config{}, access: &fakeAccess{}}
+	full := &Principal{ID: "admin"}
+	scoped := &Principal{ID: "grant", Grant: &Grant{}}
+	for _, tc := range []struct {
+		name      string
+		method    string
+		query     string
+		principal *Principal
+		want      int
+	}{
+		{"post", http.MethodPost, "", full, http.StatusMethodNotAllowed},
+		{"scoped token", http.MethodGet, "", scoped, http.StatusForbidden},
+		{"zero days", http.MethodGet, "?days=0", full, http.StatusBadRequest},
+		{"negative days", http.MethodGet, "?days=-1", full, http.StatusBadRequest},
+		{"too many days", http.MethodGet, "?days=367", full, http.StatusBadRequest},
+		{"not a number", http.MethodGet, "?days=week", full, http.StatusBadRequest},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, "/documents/a/analytics"+tc.query, nil)
+			req = req.WithContext(context.WithValue(req.Context(), principalContextKey{}, tc.principal))
+			rec := httptest.NewRecorder()
+			s.handleDocumentAnalytics(rec, req, "a")
+			if rec.Code != tc.want {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tc.want, rec.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,26 @@
+package api
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/repository"
+)
+
+// unarchiveDocument returns doc with the text the archive sweep moved to
+// object storage put back into its content, so archived documents read like
+// any other. Documents that were not archived are returned as they are.
+func (s *Server) unarchiveDocument(w http.ResponseWriter, r *http.Request, doc *repository.Document) (*repository.Document, bool) {
+	if doc.ArchivedAt == nil || doc.ArchiveKey == nil {
+		return doc, true
+	}
+	text, err := s.store.GetProcessed(r.Context(), *doc.ArchiveKey)
+	if err != nil {
+		log.Printf("get archived text %s: %v", doc.ID, err)
+		s.storageError(w, err, "failed to load archived text")
+		return nil, false
+	}
+	restored := *doc
+	restored.Content = string(text)
+	return &restored, true
+}
@@ -0,0 +1,31 @@
+package api
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/httperr"
+	"github.com/dharsanguruparan/VaultDrop/internal/repository"
+)
+
+// handleDocumentAttempts serves GET /documents/{id}/attempts: every extraction
+// run with its worker, timing and error, oldest first.
+func (s *Server) handleDocumentAttempts(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodGet {
+		httperr.MethodNotAllowed(w)
+		return
+	}
+	if _, ok := s.lookupDocument(w, r, id); !ok {
+		return
+	}
+	attempts, err := s.repo.ListAttempts(r.Context(), id)
+	if err != nil {
+		log.Printf("list attempts %s: %v", id, err)
+		httperr.Internal(w, "failed to list attempts")
+		return
+	}
+	if attempts == nil {
+		attempts = []repository.Attempt{}
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{"attempts": attempts})
+}
@@ -0,0 +1,134 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/httperr"
+)
+
+// anonymousPrincipal is used for every caller when no API keys are configured.
+const anonymousPrincipal = "anonymous"
+
+// Principal identifies the authenticated caller. Grant is set when the caller
+// presented a scoped token instead of a full API key.
+type Principal struct {
+	ID    string `json:"id"`
+	Grant *Grant `json:"-"`
+}
+
+// can reports whether the principal may perform action, optionally on a
+// specific document. Full API keys can do everything.
+func (p *Principal) can(action, documentID string) bool {
+	if p.Grant == nil {
+		return true
+	}
+	return p.Grant.allows(action, documentID)
+}
+
+type principalContextKey struct{}
+
+// authenticate resolves the caller from an `Authorization: Bearer` or
+// `X-API-Key` header, accepting API keys and scoped grant tokens.
+// Authentication is disabled when cfg.APIKeys is empty.
+func (s *Server) authenticate(r *http.Request) (*Principal, bool) {
+	if len(s.cfg.APIKeys) == 0 {
+		return &Principal{ID: anonymousPrincipal}, true
+	}
+	key := presentedKey(r)
+	if key == "" {
+		return nil, false
+	}
+	if strings.HasPrefix(key, grantTokenPrefix) {
+		grant, ok := s.verifyGrant(key)
+		if !ok {
+			return nil, false
+		}
+		return &Principal{ID: grant.Principal, Grant: grant}, true
+	}
+	id, ok := s.cfg.APIKeys[key]
+	if !ok {
+		return nil, false
+	}
+	return &Principal{ID: id}, true
+}
+
+// requireAuth rejects unauthenticated requests and stores the principal on
+// the request context for the wrapped handler.
+func (s *Server) requireAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := s.authenticate(r)
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="vaultdrop"`)
+			httperr.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		ctx := context.WithValue(r.Context(), principalContextKey{}, principal)
+		next(w, r.WithContext(ctx))
+	}
+}
+
+// requireFullAccess is requireAuth but rejects scoped grant tokens, for
+// endpoints that manage credentials or data on the principal's behalf.
+func (s *Server) requireFullAccess(next http.HandlerFunc) http.HandlerFunc {
+	return s.requireAuth(func(w http.ResponseWriter, r *http.Request) {
+		if principalFrom(r.Context()).Grant != nil {
+			httperr.Forbidden(w, "scoped tokens cannot use this endpoint")
+			return
+		}
+		next(w, r)
+	})
+}
+
+// requireAdmin is requireFullAccess restricted to principals listed in
+// cfg.Admins. With authentication disabled every caller is an admin.
+func (s *Server) requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return s.requireFullAccess(func(w http.ResponseWriter, r *http.Request) {
+		if len(s.cfg.APIKeys) > 0 && !s.isAdmin(principalFrom(r.Context()).ID) {
+			httperr.Forbidden(w, "admin access required")
+			return
+		}
+		next(w, r)
+	})
+}
+
+func (s *Server) isAdmin(id string) bool {
+	for _, admin := range s.cfg.Admins {
+		if admin != "" && admin == id {
+			return true
+		}
+	}
+	return false
+}
+
+// ownerScope returns the owner the caller's document queries are limited to,
+// or "" for callers that see every document: admins, and everyone when
+// authentication is disabled. Grant tokens act for the principal that minted
+// them.
+func (s *Server) ownerScope(r *http.Request) string {
+	if len(s.cfg.APIKeys) == 0 {
+		return ""
+	}
+	id := principalFrom(r.Context()).ID
+	if s.isAdmin(id) {
+		return ""
+	}
+	return id
+}
+
+// principalFrom returns the principal stored by requireAuth, if any.
+func principalFrom(ctx context.Context) *Principal {
+	principal, _ := ctx.Value(principalContextKey{}).(*Principal)
+	return principal
+}
+
+func presentedKey(r *http.Request) string {
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		scheme, token, ok := strings.Cut(auth, " ")
+		if ok && strings.EqualFold(scheme, "Bearer") {
+			return strings.TrimSpace(token)
+		}
+	}
+	return strings.TrimSpace(r.Header.Get("X-API-Key"))
+}
@@ -0,0 +1,38 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/httperr"
+	"github.com/dharsanguruparan/VaultDrop/internal/repository"
+)
+
+// handleCancelDocument serves POST /documents/{id}/cancel. A queued document
+// never starts; a processing one stops after the worker's current stage. The
+// pending task stays in the queue and completes without doing any work.
+func (s *Server) handleCancelDocument(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodPost {
+		httperr.MethodNotAllowed(w)
+		return
+	}
+	if _, ok := s.lookupDocument(w, r, id); !ok {
+		return
+	}
+	err := s.repo.MarkCancelled(r.Context(), id)
+	switch {
+	case err == nil:
+	case errors.Is(err, repository.ErrNotCancellable):
+		httperr.Error(w, err.Error(), http.StatusConflict)
+		return
+	case errors.Is(err, repository.ErrNotFound):
+		httperr.Error(w, "document not found", http.StatusNotFound)
+		return
+	default:
+		log.Printf("cancel document %s: %v", id, err)
+		httperr.Internal(w, "failed to cancel document")
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]string{"id": id, "status": string(repository.StatusCancelled)})
+}
@@ -0,0 +1,50 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/httperr"
+	"github.com/dharsanguruparan/VaultDrop/internal/repository"
+)
+
+// handleDocumentChanges serves GET /documents/changes: the documents created
+// or updated since ?since=, in the order they changed, for indexers that
+// sync incrementally. Callers see their own documents; admins see
+// everyone's and may narrow to one principal with ?owner=.
+func (s *Server) handleDocumentChanges(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httperr.MethodNotAllowed(w)
+		return
+	}
+	if principalFrom(r.Context()).Grant != nil {
+		httperr.Forbidden(w, "scoped tokens cannot list documents")
+		return
+	}
+	q := r.URL.Query()
+	filter := repository.ChangeFilter{OwnerID: s.ownerScope(r), Since: q.Get("since")}
+	if filter.OwnerID == "" {
+		filter.OwnerID = q.Get("owner")
+	}
+	if raw := q.Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			httperr.BadRequest(w, "invalid limit")
+			return
+		}
+		filter.Limit = n
+	}
+	page, err := s.repo.Changes(r.Context(), filter)
+	if err != nil {
+		if errors.Is(err, repository.ErrInvalidCursor) {
+			httperr.Write(w, http.StatusBadRequest, httperr.CodeInvalidCursor, "invalid cursor", nil)
+			return
+		}
+		log.Printf("list document changes: %v", err)
+		httperr.Internal(w, "failed to list changes")
+		return
+	}
+	respondJSON(w, http.StatusOK, page)
+}
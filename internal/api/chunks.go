@@ -0,0 +1,226 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/httperr"
+	pdfutil "github.com/dharsanguruparan/VaultDrop/internal/pdf"
+	"github.com/dharsanguruparan/VaultDrop/internal/repository"
+)
+
+// Chunk size units.
+const (
+	chunkUnitChars = "chars"
+	chunkUnitWords = "words"
+)
+
+const (
+	defaultChunkSize    = 800
+	defaultChunkOverlap = 100
+	maxChunkSize        = 100000
+)
+
+// textChunk is one chunk of extracted text. Offset and Length locate it in
+// the plain text served by /text, in bytes; pages are counted from 1.
+type textChunk struct {
+	Index     int    `json:"index"`
+	Offset    int    `json:"offset"`
+	Length    int    `json:"length"`
+	StartPage int    `json:"startPage"`
+	EndPage   int    `json:"endPage"`
+	Text      string `json:"text"`
+}
+
+// handleDocumentChunks serves GET /documents/{id}/chunks: the extracted text
+// cut into overlapping chunks of ?size= characters or words (?unit=), each
+// with the pages it spans, ready for embedding.
+func (s *Server) handleDocumentChunks(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodGet {
+		httperr.MethodNotAllowed(w)
+		return
+	}
+	q := r.URL.Query()
+	unit := q.Get("unit")
+	if unit == "" {
+		unit = chunkUnitChars
+	}
+	if unit != chunkUnitChars && unit != chunkUnitWords {
+		httperr.BadRequest(w, "unit must be chars or words")
+		return
+	}
+	size, overlap := defaultChunkSize, defaultChunkOverlap
+	for _, p := range []struct {
+		name string
+		dst  *int
+		min  int
+	}{{"size", &size, 1}, {"overlap", &overlap, 0}} {
+		if raw := q.Get(p.name); raw != "" {
+			n, err := strconv.Atoi(raw)
+			if err != nil || n < p.min || n > maxChunkSize {
+				httperr.BadRequest(w, p.name+" must be an integer between "+strconv.Itoa(p.min)+" and "+strconv.Itoa(maxChunkSize))
+				return
+			}
+			*p.dst = n
+		}
+	}
+	if q.Get("overlap") == "" && overlap >= size {
+		overlap = size / 8
+	}
+	if overlap >= size {
+		httperr.BadRequest(w, "overlap must be smaller than size")
+		return
+	}
+	doc, ok := s.lookupDocument(w, r, id)
+	if !ok {
+		return
+	}
+	if doc.TextPurgedAt != nil {
+		httperr.Write(w, http.StatusGone, httperr.CodeTextExpired, "extracted text expired under the retention policy", nil)
+		return
+	}
+	if doc.Status != repository.StatusCompleted {
+		httperr.Error(w, "document not processed", http.StatusAccepted)
+		return
+	}
+	if doc, ok = s.unarchiveDocument(w, r, doc); !ok {
+		return
+	}
+	var spans [][2]int
+	if unit == chunkUnitWords {
+		spans = chunkWords(doc.Content, size, overlap)
+	} else {
+		spans = chunkChars(doc.Content, size, overlap)
+	}
+	respondCachedJSON(w, r, doc.UpdatedAt, map[string]interface{}{
+		"id":      doc.ID,
+		"unit":    unit,
+		"size":    size,
+		"overlap": overlap,
+		"chunks":  buildChunks(doc.Content, spans),
+	})
+}
+
+// buildChunks turns byte spans of content into chunks with page references.
+// Page breaks inside a chunk become newlines.
+func buildChunks(content string, spans [][2]int) []textChunk {
+	pages := splitPages(content)
+	pageAt := func(offset int) int {
+		return sort.Search(len(pages), func(i int) bool { return pages[i].Offset > offset })
+	}
+	chunks := make([]textChunk, 0, len(spans))
+	for _, span := range spans {
+		start, end := span[0], span[1]
+		chunks = append(chunks, textChunk{
+			Index:     len(chunks),
+			Offset:    start,
+			Length:    end - start,
+			StartPage: pageAt(start),
+			EndPage:   pageAt(end - 1),
+			Text:      strings.ReplaceAll(content[start:end], pdfutil.PageBreak, "\n"),
+		})
+	}
+	return chunks
+}
+
+// chunkChars cuts text into spans of at most size characters, each starting
+// overlap characters before the previous one ended. A chunk ends after the
+// last whitespace in its second half when there is one, so words are not
+// split, and the next one starts at a word. Whitespace-only spans are
+// dropped.
+func chunkChars(text string, size, overlap int) [][2]int {
+	// forward and back move n characters from byte offset i.
+	forward := func(i, n int) int {
+		for ; n > 0 && i < len(text); n-- {
+			_, w := utf8.DecodeRuneInString(text[i:])
+			i += w
+		}
+		return i
+	}
+	back := func(i, n int) int {
+		for ; n > 0 && i > 0; n-- {
+			_, w := utf8.DecodeLastRuneInString(text[:i])
+			i -= w
+		}
+		return i
+	}
+	// afterSpace reports whether the character before byte offset i is
+	// whitespace.
+	afterSpace := func(i int) bool {
+		r, _ := utf8.DecodeLastRuneInString(text[:i])
+		return unicode.IsSpace(r)
+	}
+	var spans [][2]int
+	for start := 0; start < len(text); {
+		end := forward(start, size)
+		if end < len(text) {
+			half := forward(start, size/2)
+			for k := end; k > half; k = back(k, 1) {
+				if afterSpace(k) {
+					end = k
+					break
+				}
+			}
+		}
+		if strings.TrimSpace(text[start:end]) != "" {
+			spans = append(spans, [2]int{start, end})
+		}
+		if end == len(text) {
+			break
+		}
+		next := back(end, overlap)
+		if next <= start {
+			next = forward(start, 1)
+		}
+		// Start at the first word in the overlap, or at end when the overlap
+		// is inside one word.
+		for k := next; ; k = forward(k, 1) {
+			if afterSpace(k) {
+				next = k
+				break
+			}
+			if k >= end {
+				break
+			}
+		}
+		start = next
+	}
+	return spans
+}
+
+// chunkWords cuts text into spans of size whitespace-separated words, each
+// repeating the last overlap words of the previous one. Words are a rough
+// stand-in for model tokens, about three quarters of a token each in
+// English.
+func chunkWords(text string, size, overlap int) [][2]int {
+	var words [][2]int
+	start := -1
+	for i, r := range text {
+		switch {
+		case unicode.IsSpace(r) && start >= 0:
+			words = append(words, [2]int{start, i})
+			start = -1
+		case !unicode.IsSpace(r) && start < 0:
+			start = i
+		}
+	}
+	if start >= 0 {
+		words = append(words, [2]int{start, len(text)})
+	}
+	var spans [][2]int
+	for i := 0; i < len(words); i += size - overlap {
+		j := i + size
+		if j > len(words) {
+			j = len(words)
+		}
+		spans = append(spans, [2]int{words[i][0], words[j-1][1]})
+		if j == len(words) {
+			break
+		}
+	}
+	return spans
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestChunkChars(t *testing.T) {
+	text := "alpha beta gamma delta\fepsilon zeta eta theta"
+	chunks := buildChunks(text, chunkChars(text, 16, 6))
+	if len(chunks) < 3 {
+		t.Fatalf("expected several chunks, got %+v", chunks)
+	}
+	overlapping := 0
+	for i, c := range chunks {
+		if got := text[c.Offset : c.Offset+c.Length]; strings.ReplaceAll(got, "\f", "\n") != c.Text {
+			t.Errorf("chunk %d: offset/length do not match text %q", i, c.Text)
+		}
+		if n := len([]rune(c.Text)); n > 16 {
+			t.Errorf("chunk %d has %d characters", i, n)
+		}
+		// Chunks start at a word: never right after a letter.
+		if c.Offset > 0 && !strings.ContainsAny(text[c.Offset-1:c.Offset], " \f") {
+			t.Errorf("chunk %d splits a word: %q", i, c.Text)
+		}
+		if i > 0 {
+			prevEnd := chunks[i-1].Offset + chunks[i-1].Length
+			if c.Offset > prevEnd {
+				t.Errorf("chunk %d leaves a gap after the previous one", i)
+			}
+			if c.Offset < prevEnd {
+				overlapping++
+			}
+		}
+	}
+	// Overlaps that would start inside a word are skipped, but most fit.
+	if overlapping == 0 {
+		t.Error("no chunk overlaps the previous one")
+	}
+	first, last := chunks[0], chunks[len(chunks)-1]
+	if first.StartPage != 1 || last.EndPage != 2 || !strings.HasSuffix(last.Text, "theta") {
+		t.Errorf("first %+v, last %+v", first, last)
+	}
+	spanning := false
+	for _, c := range chunks {
+		spanning = spanning || (c.StartPage == 1 && c.EndPage == 2)
+	}
+	if !spanning {
+		t.Error("a chunk across the page break should reference both pages")
+	}
+}
+
+func TestChunkCharsMultibyte(t *testing.T) {
+	text := strings.Repeat("é", 25)
+	spans := chunkChars(text, 10, 0)
+	if len(spans) != 3 || spans[2][1] != len(text) {
+		t.Fatalf("spans = %v", spans)
+	}
+	for _, sp := range spans[:2] {
+		if n := len([]rune(text[sp[0]:sp[1]])); n != 10 {
+			t.Errorf("span %v has %d characters", sp, n)
+		}
+	}
+}
+
+func TestChunkWords(t *testing.T) {
+	text := "one two three four five six seven"
+	chunks := buildChunks(text, chunkWords(text, 3, 1))
+	want := []string{"one two three", "three four five", "five six seven"}
+	if len(chunks) != len(want) {
+		t.Fatalf("chunks = %+v", chunks)
+	}
+	for i, c := range chunks {
+		if c.Text != want[i] || c.Index != i {
+			t.Errorf("chunk %d = %q, want %q", i, c.Text, want[i])
+		}
+	}
+	if spans := chunkWords("   ", 3, 1); len(spans) != 0 {
+		t.Errorf("blank text gave %v", spans)
+	}
+}
@@ -0,0 +1,62 @@
+package api
+
+import (
+	"fmt"
+	"log"
+	"mime"
+	"strings"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/queue"
+	"github.com/dharsanguruparan/VaultDrop/internal/repository"
+)
+
+// acceptedTypes keeps the configured allowed types that workers can extract,
+// in the configured order. Others would only ever fail in the worker, so
+// they are logged and left out.
+func acceptedTypes(allowed []string) []string {
+	var accepted []string
+	for _, t := range allowed {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if queue.Extractable(t) {
+			accepted = append(accepted, t)
+		} else if t != "" {
+			log.Printf("api: ignoring allowed type %s: no extractor (supported: %s)", t, strings.Join(queue.ExtractableTypes, ", "))
+		}
+	}
+	if len(accepted) == 0 {
+		log.Printf("api: none of VAULTDROP_ALLOWED_TYPES can be extracted; every upload will be rejected")
+	}
+	return accepted
+}
+
+// accepts reports whether contentType, parameters aside, is an accepted type.
+func (s *Server) accepts(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, t := range s.acceptTypes {
+		if t == mediaType {
+			return true
+		}
+	}
+	return false
+}
+
+// checkType returns the rejection for an upload detected as contentType, or
+// nil when the type is accepted.
+func (s *Server) checkType(contentType string, size int64) *uploadRejection {
+	if s.accepts(contentType) {
+		return nil
+	}
+	return &uploadRejection{
+		reason:      repository.RejectUnsupportedType,
+		detail:      s.unsupportedTypeDetail(contentType),
+		size:        size,
+		contentType: contentType,
+	}
+}
+
+func (s *Server) unsupportedTypeDetail(contentType string) string {
+	return fmt.Sprintf("unsupported content type %s; supported: %s", contentType, strings.Join(s.acceptTypes, ", "))
+}
@@ -0,0 +1,35 @@
+package api
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/repository"
+)
+
+func TestAcceptedTypes(t *testing.T) {
+	got := acceptedTypes([]string{"text/plain", " Application/PDF ", "image/png", ""})
+	if want := []string{"text/plain", "application/pdf"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("acceptedTypes = %v, want %v", got, want)
+	}
+}
+
+func TestCheckType(t *testing.T) {
+	s := &Server{acceptTypes: []string{"application/pdf"}}
+	if rej := s.checkType("application/pdf", 10); rej != nil {
+		t.Errorf("pdf rejected: %v", rej)
+	}
+	rej := s.checkType("text/plain; charset=utf-8", 10)
+	if rej == nil || rej.reason != repository.RejectUnsupportedType {
+		t.Fatalf("text accepted: %v", rej)
+	}
+	if !strings.Contains(rej.detail, "supported: application/pdf") {
+		t.Errorf("detail = %q", rej.detail)
+	}
+
+	s.acceptTypes = append(s.acceptTypes, "text/plain")
+	if rej := s.checkType("text/plain; charset=utf-8", 10); rej != nil {
+		t.Errorf("text rejected once allowed: %v", rej)
+	}
+}
@@ -0,0 +1,89 @@
+package api
+
+import (
+	"errors"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/httperr"
+	"github.com/dharsanguruparan/VaultDrop/internal/keys"
+	"github.com/dharsanguruparan/VaultDrop/internal/repository"
+	"github.com/dharsanguruparan/VaultDrop/internal/s3storage"
+)
+
+// UseKeys encrypts every raw upload the API stores with a data key of its
+// own, wrapped under the current master key in k. Call it before Run.
+func (s *Server) UseKeys(k *keys.Keyring) {
+	s.keys = k
+}
+
+// newDataKey returns a data key for the new document id, in the clear and
+// wrapped, or nils when uploads are not encrypted.
+func (s *Server) newDataKey(id string) ([]byte, *keys.Wrapped, error) {
+	if s.keys == nil {
+		return nil, nil, nil
+	}
+	key, wrapped, err := s.keys.NewDataKey(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	return key, &wrapped, nil
+}
+
+// handleRawDownload serves GET /documents/{id}/raw: the original upload,
+// streamed through the API. Unlike a presigned raw-url it works for uploads
+// encrypted with a data key, which the API decrypts on the way.
+func (s *Server) handleRawDownload(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		httperr.MethodNotAllowed(w)
+		return
+	}
+	doc, ok := s.lookupDocument(w, r, id)
+	if !ok {
+		return
+	}
+	if doc.RawPurgedAt != nil {
+		httperr.Write(w, http.StatusGone, httperr.CodeRawExpired, "raw upload expired under the retention policy", nil)
+		return
+	}
+	var dataKey []byte
+	wrapped, err := s.repo.DataKey(r.Context(), doc.ID)
+	if err == nil && wrapped != nil {
+		dataKey, err = s.keys.Unwrap(doc.ID, *wrapped)
+	}
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			httperr.NotFound(w, r)
+			return
+		}
+		log.Printf("data key for %s: %v", doc.ID, err)
+		httperr.Internal(w, "failed to read raw upload")
+		return
+	}
+	body, info, err := s.store.OpenRaw(r.Context(), doc.ObjectKey, dataKey)
+	if errors.Is(err, s3storage.ErrNotFound) {
+		httperr.Error(w, "raw upload not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("open raw upload of %s: %v", doc.ID, err)
+		s.storageError(w, err, "failed to read raw upload")
+		return
+	}
+	defer body.Close()
+	h := w.Header()
+	h.Set("Content-Type", info.ContentType)
+	h.Set("Content-Length", strconv.FormatInt(info.Size, 10))
+	h.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": doc.FileName}))
+	h.Set("X-Content-Type-Options", "nosniff")
+	if r.Method == http.MethodHead {
+		return
+	}
+	s.recordAccess(r.Context(), doc.ID, repository.AccessDownload)
+	if _, err := io.Copy(w, body); err != nil {
+		log.Printf("stream raw upload of %s: %v", doc.ID, err)
+	}
+}
@@ -0,0 +1,235 @@
+package api
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/hibiken/asynq"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/database"
+	"github.com/dharsanguruparan/VaultDrop/internal/httperr"
+	"github.com/dharsanguruparan/VaultDrop/internal/queue"
+	"github.com/dharsanguruparan/VaultDrop/internal/repository"
+)
+
+// requestStats counts responses in one-minute buckets covering the last hour.
+type requestStats struct {
+	mu      sync.Mutex
+	buckets [60]requestBucket
+}
+
+type requestBucket struct {
+	minute      int64
+	total       int64
+	clientError int64
+	serverError int64
+}
+
+// RequestRates summarises responses over a window.
+type RequestRates struct {
+	Window          string  `json:"window"`
+	Requests        int64   `json:"requests"`
+	ClientErrors    int64   `json:"clientErrors"`
+	ServerErrors    int64   `json:"serverErrors"`
+	ServerErrorRate float64 `json:"serverErrorRate"`
+}
+
+func (s *requestStats) record(now time.Time, status int) {
+	minute := now.Unix() / 60
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b := &s.buckets[minute%int64(len(s.buckets))]
+	switch {
+	case b.minute > minute:
+		// A request that started before the slot was reused; too old to count.
+		return
+	case b.minute < minute:
+		*b = requestBucket{minute: minute}
+	}
+	b.total++
+	switch {
+	case status >= 500:
+		b.serverError++
+	case status >= 400:
+		b.clientError++
+	}
+}
+
+func (s *requestStats) rates(now time.Time, window time.Duration) RequestRates {
+	out := RequestRates{Window: window.String()}
+	oldest := now.Add(-window).Unix() / 60
+	s.mu.Lock()
+	for _, b := range s.buckets {
+		if b.minute > oldest {
+			out.Requests += b.total
+			out.ClientErrors += b.clientError
+			out.ServerErrors += b.serverError
+		}
+	}
+	s.mu.Unlock()
+	if out.Requests > 0 {
+		out.ServerErrorRate = float64(out.ServerErrors) / float64(out.Requests)
+	}
+	return out
+}
+
+// Diagnostics is the GET /admin/diagnostics document. Sections that could not
+// be collected carry an error string instead of failing the whole response,
+// since this is most useful exactly when something is broken.
+type Diagnostics struct {
+	GeneratedAt     time.Time                   `json:"generatedAt"`
+	Config          ConfigSummary               `json:"config"`
+	Requests        []RequestRates              `json:"requests"`
+	Rejections      *repository.RejectionReport `json:"rejections,omitempty"`
+	RejectionsError string                      `json:"rejectionsError,omitempty"`
+	SlowestQueries  []database.SlowQuery        `json:"slowestQueries"`
+	Queues          []queue.Backlog             `json:"queues"`
+	QueuesError     string                      `json:"queuesError,omitempty"`
+	TempDir         TempDirUsage                `json:"tempDir"`
+}
+
+// ConfigSummary identifies the configuration without exposing it.
+type ConfigSummary struct {
+	Fingerprint string `json:"fingerprint"`
+	Env         string `json:"env,omitempty"`
+	Production  bool   `json:"production"`
+	ReadOnly    bool   `json:"readOnly"`
+}
+
+// TempDirUsage covers the directory uploads are spooled to.
+type TempDirUsage struct {
+	Path       string `json:"path"`
+	FreeBytes  uint64 `json:"freeBytes,omitempty"`
+	TotalBytes uint64 `json:"totalBytes,omitempty"`
+	// SpoolFiles and SpoolBytes count uploads currently spooled to disk.
+	SpoolFiles int    `json:"spoolFiles"`
+	SpoolBytes int64  `json:"spoolBytes"`
+	Error      string `json:"error,omitempty"`
+}
+
+// handleDiagnostics serves GET /admin/diagnostics.
+func (s *Server) handleDiagnostics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httperr.MethodNotAllowed(w)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+	now := time.Now()
+	d := Diagnostics{
+		GeneratedAt: now.UTC(),
+		Config: ConfigSummary{
+			Fingerprint: s.cfg.Fingerprint(),
+			Env:         s.cfg.Env,
+			Production:  s.cfg.Production,
+			ReadOnly:    s.cfg.ReadOnly,
+		},
+		Requests: []RequestRates{
+			s.requests.rates(now, 5*time.Minute),
+			s.requests.rates(now, time.Hour),
+		},
+		SlowestQueries: database.SlowestQueries(),
+		TempDir:        tempDirUsage(os.TempDir()),
+	}
+	if report, err := s.repo.RejectionReport(ctx, now.Add(-time.Hour), 0); err != nil {
+		d.RejectionsError = err.Error()
+	} else {
+		d.Rejections = report
+	}
+	inspector := s.inspector()
+	defer inspector.Close()
+	if queues, err := queue.Backlogs(inspector); err != nil {
+		d.QueuesError = err.Error()
+	} else {
+		d.Queues = queues
+	}
+	respondJSON(w, http.StatusOK, d)
+}
+
+// Collect adds the metrics fn writes to GET /metrics, such as the database
+// pool statistics. Call it before Run.
+func (s *Server) Collect(fn func(io.Writer)) {
+	s.collectors = append(s.collectors, fn)
+}
+
+// handleMetrics serves GET /metrics in the Prometheus text format. It is
+// admin-only like the other operational endpoints; scrapers send an admin
+// key as their bearer token.
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httperr.MethodNotAllowed(w)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	for _, collect := range s.collectors {
+		collect(w)
+	}
+}
+
+// QueueStats is the GET /admin/queue-stats document. The totals are what
+// autoscalers such as KEDA's metrics-api scaler read; the per-queue detail
+// explains them.
+type QueueStats struct {
+	Pending              int             `json:"pending"`
+	OldestPendingSeconds float64         `json:"oldestPendingSeconds"`
+	Queues               []queue.Backlog `json:"queues"`
+}
+
+// handleQueueStats serves GET /admin/queue-stats.
+func (s *Server) handleQueueStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httperr.MethodNotAllowed(w)
+		return
+	}
+	inspector := s.inspector()
+	defer inspector.Close()
+	queues, err := queue.Backlogs(inspector)
+	if err != nil {
+		log.Printf("queue stats: %v", err)
+		httperr.Internal(w, "failed to read queues")
+		return
+	}
+	stats := QueueStats{Queues: queues}
+	for _, q := range queues {
+		stats.Pending += q.Pending
+		if age := float64(q.LatencyMS) / 1000; age > stats.OldestPendingSeconds {
+			stats.OldestPendingSeconds = age
+		}
+	}
+	respondJSON(w, http.StatusOK, stats)
+}
+
+// inspector opens an asynq inspector on the configured Redis; callers close
+// it.
+func (s *Server) inspector() *asynq.Inspector {
+	return asynq.NewInspector(asynq.RedisClientOpt{
+		Addr:     s.cfg.RedisAddr,
+		Password: s.cfg.RedisPassword,
+		DB:       s.cfg.RedisDB,
+	})
+}
+
+// tempDirUsage reports free space where uploads are spooled and how much the
+// spool files currently take.
+func tempDirUsage(dir string) TempDirUsage {
+	usage := TempDirUsage{Path: dir}
+	usage.FreeBytes, usage.TotalBytes = diskSpace(dir)
+	matches, err := filepath.Glob(filepath.Join(dir, "vaultdrop-*.pdf"))
+	if err != nil {
+		usage.Error = err.Error()
+		return usage
+	}
+	for _, path := range matches {
+		if info, err := os.Stat(path); err == nil {
+			usage.SpoolFiles++
+			usage.SpoolBytes += info.Size()
+		}
+	}
+	return usage
+}
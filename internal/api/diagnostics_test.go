@@ -0,0 +1,26 @@
+package api
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRequestStatsRates(t *testing.T) {
+	var stats requestStats
+	now := time.Date(2024, 1, 1, 12, 0, 30, 0, time.UTC)
+	stats.record(now.Add(-30*time.Minute), 200)
+	stats.record(now.Add(-2*time.Minute), 500)
+	stats.record(now, 404)
+	stats.record(now, 200)
+	// A record for a minute whose slot was already reused is dropped.
+	stats.record(now.Add(-time.Hour), 500)
+
+	recent := stats.rates(now, 5*time.Minute)
+	if recent.Requests != 3 || recent.ServerErrors != 1 || recent.ClientErrors != 1 {
+		t.Errorf("5m window: %+v", recent)
+	}
+	hour := stats.rates(now, time.Hour)
+	if hour.Requests != 4 || hour.ServerErrorRate != 0.25 {
+		t.Errorf("1h window: %+v", hour)
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package api
+
+// diskSpace is not implemented on this platform.
+func diskSpace(dir string) (free, total uint64) {
+	return 0, 0
+}
@@ -0,0 +1,15 @@
+//go:build unix
+
+package api
+
+import "syscall"
+
+// diskSpace returns the free and total bytes of the filesystem holding dir,
+// or zeros when it cannot be read.
+func diskSpace(dir string) (free, total uint64) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dir, &st); err != nil {
+		return 0, 0
+	}
+	return st.Bavail * uint64(st.Bsize), st.Blocks * uint64(st.Bsize)
+}
@@ -0,0 +1,159 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/httperr"
+	"github.com/dharsanguruparan/VaultDrop/internal/repository"
+)
+
+const (
+	defaultDropTTL     = 24 * time.Hour
+	defaultDropUploads = 1
+	maxDropUploads     = 1000
+	// dropReleaseTimeout bounds returning a slot after a failed upload,
+	// which runs after the client may have gone.
+	dropReleaseTimeout = 5 * time.Second
+)
+
+type createDropBody struct {
+	Label      string `json:"label"`
+	TTL        string `json:"ttl"`
+	MaxUploads int    `json:"maxUploads"`
+}
+
+type dropResponse struct {
+	*repository.Drop
+	URL         string   `json:"url,omitempty"`
+	DocumentIDs []string `json:"documentIds,omitempty"`
+}
+
+func (s *Server) handleDrops(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		httperr.MethodNotAllowed(w)
+		return
+	}
+	var body createDropBody
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&body); err != nil && !errors.Is(err, io.EOF) {
+		httperr.BadRequest(w, "invalid json body")
+		return
+	}
+	ttl := defaultDropTTL
+	if body.TTL != "" {
+		parsed, err := time.ParseDuration(body.TTL)
+		if err != nil || parsed <= 0 {
+			httperr.BadRequest(w, "invalid ttl")
+			return
+		}
+		ttl = parsed
+	}
+	if ttl > s.cfg.DropMaxTTL {
+		ttl = s.cfg.DropMaxTTL
+	}
+	if body.MaxUploads == 0 {
+		body.MaxUploads = defaultDropUploads
+	}
+	if body.MaxUploads < 0 || body.MaxUploads > maxDropUploads {
+		httperr.BadRequest(w, "maxUploads out of range")
+		return
+	}
+	token, err := newDropToken()
+	if err != nil {
+		httperr.Internal(w, "failed to create drop")
+		return
+	}
+	drop := &repository.Drop{
+		ID:         uuid.NewString(),
+		OwnerID:    principalFrom(r.Context()).ID,
+		Label:      strings.TrimSpace(body.Label),
+		MaxUploads: body.MaxUploads,
+		ExpiresAt:  time.Now().Add(ttl).UTC(),
+	}
+	if err := s.repo.CreateDrop(r.Context(), drop, repository.HashDropToken(token)); err != nil {
+		log.Printf("create drop: %v", err)
+		httperr.Internal(w, "failed to create drop")
+		return
+	}
+	// The token is only ever returned here; the database keeps its hash.
+	respondJSON(w, http.StatusCreated, dropResponse{Drop: drop, URL: "/drop/" + token})
+}
+
+func (s *Server) handleDropInfo(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httperr.MethodNotAllowed(w)
+		return
+	}
+	id := strings.TrimPrefix(r.URL.Path, "/drops/")
+	if id == "" || strings.Contains(id, "/") {
+		httperr.NotFound(w, r)
+		return
+	}
+	drop, err := s.repo.GetDrop(r.Context(), id)
+	if err != nil || drop.OwnerID != principalFrom(r.Context()).ID {
+		httperr.Error(w, "drop not found", http.StatusNotFound)
+		return
+	}
+	ids, err := s.repo.ListDropDocumentIDs(r.Context(), drop.ID)
+	if err != nil {
+		httperr.Internal(w, "failed to list drop documents")
+		return
+	}
+	respondJSON(w, http.StatusOK, dropResponse{Drop: drop, DocumentIDs: ids})
+}
+
+// handleDropUpload accepts an upload from an outsider holding a drop link. No
+// credentials are required; the link token itself is the capability.
+func (s *Server) handleDropUpload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		httperr.MethodNotAllowed(w)
+		return
+	}
+	token := strings.TrimPrefix(r.URL.Path, "/drop/")
+	if token == "" || strings.Contains(token, "/") {
+		httperr.NotFound(w, r)
+		return
+	}
+	drop, err := s.repo.ClaimDropSlot(r.Context(), repository.HashDropToken(token))
+	if err != nil {
+		if errors.Is(err, repository.ErrDropUnavailable) {
+			httperr.Error(w, "drop link expired or already used", http.StatusGone)
+			return
+		}
+		log.Printf("claim drop slot: %v", err)
+		httperr.Internal(w, "failed to accept upload")
+		return
+	}
+	// Files received through a drop belong to whoever created it.
+	id := uuid.NewString()
+	stored, ok := s.ingestUpload(w, r, id, drop.OwnerID, &drop.ID, nil)
+	if !ok {
+		// A failure after the row was inserted still used the slot. The
+		// request context may already be cancelled by a client that left.
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), dropReleaseTimeout)
+		defer cancel()
+		if err := s.repo.ReleaseDropSlot(ctx, drop.ID, id); err != nil {
+			log.Printf("release drop slot %s: %v", drop.ID, err)
+		}
+		return
+	}
+	respondJSON(w, http.StatusAccepted, stored.accepted())
+}
+
+func newDropToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
@@ -0,0 +1,167 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/httperr"
+	"github.com/dharsanguruparan/VaultDrop/internal/queue"
+	"github.com/dharsanguruparan/VaultDrop/internal/repository"
+)
+
+const maxErasureDocuments = 1000
+
+type erasureRequestBody struct {
+	Subject     string   `json:"subject"`
+	DocumentIDs []string `json:"documentIds"`
+}
+
+func (s *Server) handleErasureRequests(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		httperr.MethodNotAllowed(w)
+		return
+	}
+	var body erasureRequestBody
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&body); err != nil {
+		httperr.BadRequest(w, "invalid json body")
+		return
+	}
+	if strings.TrimSpace(body.Subject) == "" {
+		httperr.BadRequest(w, "subject is required")
+		return
+	}
+	ids := uniqueIDs(body.DocumentIDs)
+	if len(ids) == 0 {
+		httperr.BadRequest(w, "documentIds is required")
+		return
+	}
+	if len(ids) > maxErasureDocuments {
+		httperr.BadRequest(w, "too many documents in one request")
+		return
+	}
+	if owner := s.ownerScope(r); owner != "" {
+		foreign, err := s.repo.NotOwned(r.Context(), ids, owner)
+		if err != nil {
+			log.Printf("check erasure ownership: %v", err)
+			httperr.Internal(w, "failed to store erasure request")
+			return
+		}
+		if len(foreign) > 0 {
+			httperr.Error(w, "document not found: "+foreign[0], http.StatusNotFound)
+			return
+		}
+	}
+	req := &repository.ErasureRequest{
+		ID:          uuid.NewString(),
+		SubjectHash: repository.HashSubject(body.Subject),
+		DocumentIDs: ids,
+	}
+	if err := s.repo.CreateErasure(r.Context(), req); err != nil {
+		log.Printf("create erasure request: %v", err)
+		httperr.Internal(w, "failed to store erasure request")
+		return
+	}
+	if err := queue.EnqueueErasure(r.Context(), s.queue, queue.ErasurePayload{RequestID: req.ID}); err != nil {
+		_ = s.repo.FailErasure(r.Context(), req.ID, err.Error())
+		httperr.Internal(w, "failed to queue job")
+		return
+	}
+	respondJSON(w, http.StatusAccepted, map[string]string{
+		"id":     req.ID,
+		"status": string(req.Status),
+	})
+}
+
+type ownerErasureBody struct {
+	Subject string `json:"subject"`
+	OwnerID string `json:"ownerId"`
+}
+
+// handleOwnerErasure serves POST /admin/erasure-requests: erase everything
+// kept for one principal. The worker lists the owner's documents when the job
+// runs, erases each like a document erasure, then deletes the owner's
+// refused-upload records and upload links. Progress and the report are read
+// from GET /erasure-requests/{id}.
+func (s *Server) handleOwnerErasure(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		httperr.MethodNotAllowed(w)
+		return
+	}
+	var body ownerErasureBody
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&body); err != nil {
+		httperr.BadRequest(w, "invalid json body")
+		return
+	}
+	owner := strings.TrimSpace(body.OwnerID)
+	if owner == "" {
+		httperr.BadRequest(w, "ownerId is required")
+		return
+	}
+	subject := body.Subject
+	if strings.TrimSpace(subject) == "" {
+		subject = owner
+	}
+	req := &repository.ErasureRequest{
+		ID:          uuid.NewString(),
+		SubjectHash: repository.HashSubject(subject),
+		OwnerID:     owner,
+	}
+	if err := s.repo.CreateErasure(r.Context(), req); err != nil {
+		log.Printf("create erasure request: %v", err)
+		httperr.Internal(w, "failed to store erasure request")
+		return
+	}
+	if err := queue.EnqueueErasure(r.Context(), s.queue, queue.ErasurePayload{RequestID: req.ID}); err != nil {
+		_ = s.repo.FailErasure(r.Context(), req.ID, err.Error())
+		httperr.Internal(w, "failed to queue job")
+		return
+	}
+	respondJSON(w, http.StatusAccepted, map[string]string{
+		"id":     req.ID,
+		"status": string(req.Status),
+	})
+}
+
+func (s *Server) handleErasureRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httperr.MethodNotAllowed(w)
+		return
+	}
+	id := strings.TrimPrefix(r.URL.Path, "/erasure-requests/")
+	if id == "" || strings.Contains(id, "/") {
+		httperr.NotFound(w, r)
+		return
+	}
+	req, err := s.repo.GetErasure(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, repository.ErrErasureNotFound) {
+			httperr.Error(w, "erasure request not found", http.StatusNotFound)
+			return
+		}
+		httperr.Internal(w, "failed to load erasure request")
+		return
+	}
+	respondJSON(w, http.StatusOK, req)
+}
+
+func uniqueIDs(ids []string) []string {
+	seen := make(map[string]struct{}, len(ids))
+	out := make([]string, 0, len(ids))
+	for _, id := range ids {
+		id = strings.TrimSpace(id)
+		if id == "" {
+			continue
+		}
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		out = append(out, id)
+	}
+	return out
+}
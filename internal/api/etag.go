@@ -0,0 +1,67 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/httperr"
+)
+
+// respondCachedJSON writes payload like respondJSON, adding validators so
+// polling clients can revalidate with If-None-Match or If-Modified-Since and
+// get a bodiless 304 while nothing changed.
+func respondCachedJSON(w http.ResponseWriter, r *http.Request, modified time.Time, payload interface{}) {
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(payload); err != nil {
+		log.Printf("encode response: %v", err)
+		httperr.Internal(w, "failed to encode response")
+		return
+	}
+	respondCached(w, r, modified, "application/json", buf.Bytes())
+}
+
+// respondCached writes body with an ETag derived from its SHA-256 and a
+// Last-Modified of modified, or 304 when the request's validators match.
+func respondCached(w http.ResponseWriter, r *http.Request, modified time.Time, contentType string, body []byte) {
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	h := w.Header()
+	h.Set("ETag", etag)
+	if !modified.IsZero() {
+		h.Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	}
+	// Caches may keep the response but must check back before reusing it.
+	h.Set("Cache-Control", "private, no-cache")
+	if notModified(r, etag, modified) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	h.Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
+
+// notModified applies RFC 9110: If-None-Match wins when present, otherwise
+// If-Modified-Since is compared at the one-second resolution of HTTP dates.
+func notModified(r *http.Request, etag string, modified time.Time) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		for _, candidate := range strings.Split(inm, ",") {
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+			if candidate == "*" || candidate == etag {
+				return true
+			}
+		}
+		return false
+	}
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" && !modified.IsZero() {
+		since, err := http.ParseTime(ims)
+		return err == nil && !modified.Truncate(time.Second).After(since)
+	}
+	return false
+}
@@ -0,0 +1,43 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRespondCached(t *testing.T) {
+	modified := time.Date(2024, 5, 1, 12, 0, 0, 500, time.UTC)
+	first := httptest.NewRecorder()
+	respondCached(first, httptest.NewRequest(http.MethodGet, "/", nil), modified, "text/plain", []byte("hello"))
+	etag := first.Header().Get("ETag")
+	if first.Code != http.StatusOK || etag == "" || first.Body.String() != "hello" {
+		t.Fatalf("first response: %d %q %q", first.Code, etag, first.Body.String())
+	}
+
+	cases := []struct {
+		name   string
+		header string
+		value  string
+		want   int
+	}{
+		{"matching etag", "If-None-Match", etag, http.StatusNotModified},
+		{"weak etag in list", "If-None-Match", `"other", W/` + etag, http.StatusNotModified},
+		{"stale etag", "If-None-Match", `"other"`, http.StatusOK},
+		{"same second", "If-Modified-Since", modified.Format(http.TimeFormat), http.StatusNotModified},
+		{"older date", "If-Modified-Since", modified.Add(-time.Minute).Format(http.TimeFormat), http.StatusOK},
+	}
+	for _, tc := range cases {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set(tc.header, tc.value)
+		w := httptest.NewRecorder()
+		respondCached(w, r, modified, "text/plain", []byte("hello"))
+		if w.Code != tc.want {
+			t.Errorf("%s: status %d, want %d", tc.name, w.Code, tc.want)
+		}
+		if tc.want == http.StatusNotModified && w.Body.Len() != 0 {
+			t.Errorf("%s: 304 carried a body", tc.name)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/httperr"
+	"github.com/dharsanguruparan/VaultDrop/internal/notify"
+	"github.com/dharsanguruparan/VaultDrop/internal/repository"
+)
+
+const eventsHeartbeat = 15 * time.Second
+
+// handleDocumentEvents streams status changes as Server-Sent Events until the
+// document reaches a terminal status or the client disconnects.
+func (s *Server) handleDocumentEvents(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodGet {
+		httperr.MethodNotAllowed(w)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		httperr.Internal(w, "streaming unsupported")
+		return
+	}
+	// Subscribe before reading the current status so no transition is lost
+	// between the two.
+	updates, unsubscribe := s.hub.Subscribe(id)
+	defer unsubscribe()
+	doc, ok := s.lookupDocument(w, r, id)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	current := notify.Update{DocumentID: doc.ID, Status: string(doc.Status)}
+	writeStatusEvent(w, current)
+	flusher.Flush()
+	if isTerminalStatus(current.Status) {
+		return
+	}
+	heartbeat := time.NewTicker(eventsHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+			flusher.Flush()
+		case update := <-updates:
+			if update.Status == current.Status {
+				continue
+			}
+			current = update
+			writeStatusEvent(w, update)
+			flusher.Flush()
+			if isTerminalStatus(update.Status) {
+				return
+			}
+		}
+	}
+}
+
+func writeStatusEvent(w http.ResponseWriter, update notify.Update) {
+	data, _ := json.Marshal(update)
+	fmt.Fprintf(w, "event: status\ndata: %s\n\n", data)
+}
+
+func isTerminalStatus(status string) bool {
+	switch repository.DocumentStatus(status) {
+	case repository.StatusCompleted, repository.StatusFailed, repository.StatusCancelled:
+		return true
+	}
+	return false
+}
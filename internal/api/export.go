@@ -0,0 +1,230 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/export"
+	"github.com/dharsanguruparan/VaultDrop/internal/httperr"
+	"github.com/dharsanguruparan/VaultDrop/internal/queue"
+	"github.com/dharsanguruparan/VaultDrop/internal/repository"
+	"github.com/dharsanguruparan/VaultDrop/internal/s3storage"
+)
+
+const maxExportDocuments = 1000
+
+type exportBody struct {
+	DocumentIDs []string `json:"documentIds"`
+	Artifact    string   `json:"artifact"`
+	Async       bool     `json:"async"`
+}
+
+// handleExports serves POST /exports: a ZIP of several documents. Up to
+// VAULTDROP_EXPORT_SYNC_LIMIT documents are streamed back in the response
+// unless the body asks for async; larger exports are built by the worker and
+// answered with 202 and the export id to poll at GET /exports/{id}.
+func (s *Server) handleExports(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		httperr.MethodNotAllowed(w)
+		return
+	}
+	var body exportBody
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&body); err != nil {
+		httperr.BadRequest(w, "invalid json body")
+		return
+	}
+	artifact, err := export.ParseArtifact(body.Artifact)
+	if err != nil {
+		httperr.BadRequest(w, err.Error())
+		return
+	}
+	ids := uniqueIDs(body.DocumentIDs)
+	if len(ids) == 0 {
+		httperr.BadRequest(w, "documentIds is required")
+		return
+	}
+	if len(ids) > maxExportDocuments {
+		httperr.BadRequest(w, "too many documents in one export")
+		return
+	}
+	owner := s.ownerScope(r)
+	if owner != "" {
+		foreign, err := s.repo.NotOwned(r.Context(), ids, owner)
+		if err != nil {
+			log.Printf("check export ownership: %v", err)
+			httperr.Internal(w, "failed to start export")
+			return
+		}
+		if len(foreign) > 0 {
+			httperr.Error(w, "document not found: "+foreign[0], http.StatusNotFound)
+			return
+		}
+	}
+	if !body.Async && len(ids) <= s.cfg.ExportSyncLimit {
+		s.streamExport(w, r, ids, owner, artifact)
+		return
+	}
+	e := &repository.Export{
+		ID:          uuid.NewString(),
+		Scope:       owner,
+		Artifact:    string(artifact),
+		DocumentIDs: ids,
+		ExpiresAt:   time.Now().Add(s.cfg.ExportTTL).UTC(),
+	}
+	// The ZIP holds the decrypted uploads, so it gets a data key of its own.
+	if _, e.DataKey, err = s.newDataKey(e.ID); err != nil {
+		log.Printf("export data key: %v", err)
+		httperr.Internal(w, "failed to start export")
+		return
+	}
+	enqueue := func(ctx context.Context, p queue.ExportPayload, deadline time.Time) error {
+		return queue.EnqueueExport(ctx, s.queue, p, deadline)
+	}
+	if err := startExport(r.Context(), s.repo, enqueue, e); err != nil {
+		log.Printf("start export: %v", err)
+		if errors.Is(err, errExportNotQueued) {
+			httperr.Internal(w, "failed to queue job")
+			return
+		}
+		httperr.Internal(w, "failed to start export")
+		return
+	}
+	respondJSON(w, http.StatusAccepted, map[string]string{
+		"id":     e.ID,
+		"status": string(e.Status),
+	})
+}
+
+// exportRecorder is the part of the repository startExport writes to.
+type exportRecorder interface {
+	CreateExport(ctx context.Context, e *repository.Export) error
+	FailExport(ctx context.Context, id, msg string) error
+}
+
+// errExportNotQueued means the export row exists but its task is not queued.
+var errExportNotQueued = errors.New("export task not queued")
+
+// startExport inserts the pending export e and queues the worker's task for
+// it. When queueing fails the export is marked failed, so polling it does not
+// report pending forever.
+func startExport(ctx context.Context, exports exportRecorder, enqueue func(context.Context, queue.ExportPayload, time.Time) error, e *repository.Export) error {
+	if err := exports.CreateExport(ctx, e); err != nil {
+		return err
+	}
+	if err := enqueue(ctx, queue.ExportPayload{ExportID: e.ID}, e.ExpiresAt); err != nil {
+		if ferr := exports.FailExport(context.WithoutCancel(ctx), e.ID, "queue export: "+err.Error()); ferr != nil {
+			log.Printf("fail export %s: %v", e.ID, ferr)
+		}
+		return fmt.Errorf("%w: %v", errExportNotQueued, err)
+	}
+	return nil
+}
+
+// streamExport writes the ZIP as the response. Once the first entry is sent
+// the status is committed, so a failure part way only truncates the ZIP;
+// clients notice the missing central directory.
+func (s *Server) streamExport(w http.ResponseWriter, r *http.Request, ids []string, owner string, artifact export.Artifact) {
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="vaultdrop-export.zip"`)
+	w.Header().Set("Cache-Control", "no-store")
+	if _, err := export.New(s.repo, s.store, s.keys).Write(r.Context(), w, ids, owner, artifact); err != nil {
+		log.Printf("stream export of %d documents: %v", len(ids), err)
+	}
+}
+
+// handleExport serves GET /exports/{id} and GET /exports/{id}/download. A
+// completed export whose ZIP is not encrypted carries a presigned url for it,
+// valid for VAULTDROP_SIGNED_TTL or until the export expires, whichever is
+// sooner; an encrypted one is only downloaded through the API.
+func (s *Server) handleExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		httperr.MethodNotAllowed(w)
+		return
+	}
+	id := strings.TrimPrefix(r.URL.Path, "/exports/")
+	id, download := strings.CutSuffix(id, "/download")
+	if id == "" || strings.Contains(id, "/") || (!download && r.Method != http.MethodGet) {
+		httperr.NotFound(w, r)
+		return
+	}
+	e, err := s.repo.GetExport(r.Context(), id, s.ownerScope(r))
+	if err != nil {
+		if errors.Is(err, repository.ErrExportNotFound) {
+			httperr.Error(w, "export not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("load export %s: %v", id, err)
+		httperr.Internal(w, "failed to load export")
+		return
+	}
+	if download {
+		s.downloadExport(w, r, e)
+		return
+	}
+	resp := struct {
+		*repository.Export
+		URL          string     `json:"url,omitempty"`
+		URLExpiresAt *time.Time `json:"urlExpiresAt,omitempty"`
+	}{Export: e}
+	ttl := min(s.cfg.SignedURLTTL, time.Until(e.ExpiresAt))
+	if e.Status == repository.ExportCompleted && e.ObjectKey != nil && e.DataKey == nil && ttl >= time.Second {
+		url, err := s.store.PresignExportURL(r.Context(), *e.ObjectKey, "vaultdrop-export-"+e.ID+".zip", ttl)
+		if err != nil {
+			s.storageError(w, err, "failed to generate url")
+			return
+		}
+		expires := time.Now().Add(ttl).UTC()
+		resp.URL, resp.URLExpiresAt = url, &expires
+	}
+	respondJSON(w, http.StatusOK, resp)
+}
+
+// downloadExport streams a completed export's ZIP through the API, decrypting
+// it with the export's data key when it has one.
+func (s *Server) downloadExport(w http.ResponseWriter, r *http.Request, e *repository.Export) {
+	if e.Status != repository.ExportCompleted || e.ObjectKey == nil {
+		httperr.Error(w, "export is "+string(e.Status), http.StatusConflict)
+		return
+	}
+	var dataKey []byte
+	if e.DataKey != nil {
+		var err error
+		if dataKey, err = s.keys.Unwrap(e.ID, *e.DataKey); err != nil {
+			log.Printf("data key for export %s: %v", e.ID, err)
+			httperr.Internal(w, "failed to read export")
+			return
+		}
+	}
+	body, info, err := s.store.OpenExport(r.Context(), *e.ObjectKey, dataKey)
+	if errors.Is(err, s3storage.ErrNotFound) {
+		httperr.Error(w, "export not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("open export %s: %v", e.ID, err)
+		s.storageError(w, err, "failed to read export")
+		return
+	}
+	defer body.Close()
+	h := w.Header()
+	h.Set("Content-Type", "application/zip")
+	h.Set("Content-Length", strconv.FormatInt(info.Size, 10))
+	h.Set("Content-Disposition", `attachment; filename="vaultdrop-export-`+e.ID+`.zip"`)
+	h.Set("Cache-Control", "no-store")
+	if r.Method == http.MethodHead {
+		return
+	}
+	if _, err := io.Copy(w, body); err != nil {
+		log.Printf("stream export %s: %v", e.ID, err)
+	}
+}
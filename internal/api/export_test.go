@@ -0,0 +1,87 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/queue"
+	"github.com/dharsanguruparan/VaultDrop/internal/repository"
+)
+
+type fakeExports struct {
+	createErr error
+	created   []string
+	failed    map[string]string
+}
+
+func (f *fakeExports) CreateExport(ctx context.Context, e *repository.Export) error {
+	if f.createErr != nil {
+		return f.createErr
+	}
+	e.Status = repository.ExportPending
+	f.created = append(f.created, e.ID)
+	return nil
+}
+
+func (f *fakeExports) FailExport(ctx context.Context, id, msg string) error {
+	if f.failed == nil {
+		f.failed = make(map[string]string)
+	}
+	f.failed[id] = msg
+	return nil
+}
+
+func TestStartExport(t *testing.T) {
+	expires := time.Now().Add(time.Hour)
+	newExport := func() *repository.Export {
+		return &repository.Export{ID: "exp-1", DocumentIDs: []string{"a"}, ExpiresAt: expires}
+	}
+
+	t.Run("queued", func(t *testing.T) {
+		exports := &fakeExports{}
+		var queued []queue.ExportPayload
+		enqueue := func(ctx context.Context, p queue.ExportPayload, deadline time.Time) error {
+			if !deadline.Equal(expires) {
+				t.Errorf("deadline = %s, want the export's expiry %s", deadline, expires)
+			}
+			queued = append(queued, p)
+			return nil
+		}
+		if err := startExport(context.Background(), exports, enqueue, newExport()); err != nil {
+			t.Fatal(err)
+		}
+		if len(exports.created) != 1 || len(queued) != 1 || queued[0].ExportID != "exp-1" || len(exports.failed) != 0 {
+			t.Errorf("created %v, queued %v, failed %v", exports.created, queued, exports.failed)
+		}
+	})
+
+	t.Run("enqueue fails", func(t *testing.T) {
+		exports := &fakeExports{}
+		enqueue := func(context.Context, queue.ExportPayload, time.Time) error { return errors.New("redis down") }
+		// A cancelled request must still record the failure.
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		err := startExport(ctx, exports, enqueue, newExport())
+		if !errors.Is(err, errExportNotQueued) {
+			t.Fatalf("err = %v, want errExportNotQueued", err)
+		}
+		if msg, ok := exports.failed["exp-1"]; !ok || !strings.Contains(msg, "redis down") {
+			t.Errorf("failed = %v, want exp-1 failed with the queue error", exports.failed)
+		}
+	})
+
+	t.Run("insert fails", func(t *testing.T) {
+		exports := &fakeExports{createErr: errors.New("db down")}
+		enqueue := func(context.Context, queue.ExportPayload, time.Time) error {
+			t.Error("enqueued an export that was not stored")
+			return nil
+		}
+		err := startExport(context.Background(), exports, enqueue, newExport())
+		if err == nil || errors.Is(err, errExportNotQueued) {
+			t.Errorf("err = %v, want the insert error", err)
+		}
+	})
+}
@@ -0,0 +1,234 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/config"
+	"github.com/dharsanguruparan/VaultDrop/internal/httperr"
+	"github.com/dharsanguruparan/VaultDrop/internal/repository"
+)
+
+// maxIngestRedirects bounds how many redirects a URL fetch follows; each hop
+// is checked against the scheme and host rules again.
+const maxIngestRedirects = 5
+
+var errBlockedAddress = errors.New("address not allowed")
+
+type ingestURLBody struct {
+	URL       string            `json:"url"`
+	FileName  string            `json:"fileName"`
+	ProcessAt *time.Time        `json:"processAt"`
+	Metadata  map[string]string `json:"metadata"`
+}
+
+// handleIngestURL serves POST /documents/from-url: the server downloads the
+// PDF at the given URL and files it like an upload, so documents can be
+// pulled from other systems without a client round trip. What may be fetched
+// is limited by the VAULTDROP_URL_INGEST_* settings; the body is held to the
+// upload size limit and the whole fetch to URLIngestTimeout.
+func (s *Server) handleIngestURL(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		httperr.MethodNotAllowed(w)
+		return
+	}
+	if !principalFrom(r.Context()).can(actionUpload, "") {
+		httperr.Forbidden(w, "token does not grant "+actionUpload)
+		return
+	}
+	var body ingestURLBody
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&body); err != nil {
+		httperr.BadRequest(w, "invalid json body")
+		return
+	}
+	source, err := url.Parse(body.URL)
+	if err != nil || source.Host == "" {
+		httperr.BadRequest(w, "url must be absolute")
+		return
+	}
+	if err := checkIngestURL(s.cfg, source); err != nil {
+		httperr.BadRequest(w, err.Error())
+		return
+	}
+	if err := checkMetadata(body.Metadata); err != nil {
+		httperr.BadRequest(w, err.Error())
+		return
+	}
+	var processAt *time.Time
+	if body.ProcessAt != nil {
+		if time.Until(*body.ProcessAt) > maxProcessDelay {
+			httperr.BadRequest(w, fmt.Sprintf("processAt is more than %s ahead", maxProcessDelay))
+			return
+		}
+		if body.ProcessAt.After(time.Now()) {
+			at := body.ProcessAt.UTC()
+			processAt = &at
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), s.cfg.URLIngestTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, source.String(), nil)
+	if err != nil {
+		httperr.BadRequest(w, "invalid url")
+		return
+	}
+	req.Header.Set("Accept", strings.Join(s.acceptTypes, ", "))
+	resp, err := s.fetcher.Do(req)
+	if err != nil {
+		log.Printf("fetch %s: %v", source.Redacted(), err)
+		status := http.StatusBadGateway
+		if errors.Is(err, context.DeadlineExceeded) {
+			status = http.StatusGatewayTimeout
+		}
+		httperr.Error(w, "failed to fetch url: "+fetchErrorDetail(err), status)
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		httperr.Error(w, fmt.Sprintf("url answered %s", resp.Status), http.StatusBadGateway)
+		return
+	}
+	if resp.ContentLength > s.cfg.MaxFileSize {
+		detail := fmt.Sprintf("%v (%d bytes)", errFileTooLarge, s.cfg.MaxFileSize)
+		s.rejectUpload(w, r, repository.RejectTooLarge, detail, resp.ContentLength, resp.Header.Get("Content-Type"))
+		return
+	}
+
+	fileName := body.FileName
+	if fileName == "" {
+		fileName = remoteFileName(resp)
+	}
+	stored, ok := s.ingest(w, r, resp.Body, uploadTarget{
+		id:        uuid.NewString(),
+		fileName:  fileName,
+		size:      resp.ContentLength,
+		owner:     principalFrom(r.Context()).ID,
+		processAt: processAt,
+		metadata:  body.Metadata,
+	})
+	if !ok {
+		return
+	}
+	w.Header().Set("Location", "/documents/"+stored.id)
+	respondJSON(w, http.StatusAccepted, stored.accepted())
+}
+
+// checkIngestURL applies the configured scheme and host rules to u.
+func checkIngestURL(cfg *config.Config, u *url.URL) error {
+	schemeOK := false
+	for _, scheme := range cfg.URLIngestSchemes {
+		if strings.EqualFold(u.Scheme, scheme) {
+			schemeOK = true
+			break
+		}
+	}
+	if !schemeOK {
+		return fmt.Errorf("url scheme %q is not allowed", u.Scheme)
+	}
+	if u.User != nil {
+		return errors.New("urls with credentials are not allowed")
+	}
+	if len(cfg.URLIngestHosts) == 0 {
+		return nil
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, allowed := range cfg.URLIngestHosts {
+		allowed = strings.ToLower(allowed)
+		if host == allowed || (strings.HasPrefix(allowed, "*.") && strings.HasSuffix(host, allowed[1:])) {
+			return nil
+		}
+	}
+	return fmt.Errorf("url host %q is not allowed", host)
+}
+
+// newURLFetcher returns the client used for URL ingestion. It connects
+// directly, never through an environment proxy, so the address check in the
+// dialer sees the real destination, and it checks every redirect hop.
+func newURLFetcher(cfg *config.Config) *http.Client {
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	if !cfg.URLIngestAllowPrivate {
+		// Checking the resolved address at connect time also covers DNS
+		// names that point at internal addresses.
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !publicIP(ip) {
+				return fmt.Errorf("%w: %s", errBlockedAddress, host)
+			}
+			return nil
+		}
+	}
+	return &http.Client{
+		Transport: &http.Transport{
+			Proxy:                 nil,
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ResponseHeaderTimeout: cfg.URLIngestTimeout,
+			MaxIdleConns:          10,
+			IdleConnTimeout:       30 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxIngestRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxIngestRedirects)
+			}
+			return checkIngestURL(cfg, req.URL)
+		},
+	}
+}
+
+// publicIP reports whether ip is a globally routable unicast address.
+func publicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsMulticast() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() {
+		return false
+	}
+	// Carrier-grade NAT space is not covered by IsPrivate.
+	if v4 := ip.To4(); v4 != nil && v4[0] == 100 && v4[1]&0xc0 == 64 {
+		return false
+	}
+	return true
+}
+
+// fetchErrorDetail keeps blocked-address and redirect errors readable for the
+// client without echoing transport internals.
+func fetchErrorDetail(err error) string {
+	switch {
+	case errors.Is(err, errBlockedAddress):
+		return "destination address is not allowed"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timed out"
+	}
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) && urlErr.Err != nil {
+		return urlErr.Err.Error()
+	}
+	return "request failed"
+}
+
+// remoteFileName takes the name from Content-Disposition, else from the
+// last path segment; ingest falls back to upload.pdf when both are empty.
+func remoteFileName(resp *http.Response) string {
+	if _, params, err := mime.ParseMediaType(resp.Header.Get("Content-Disposition")); err == nil && params["filename"] != "" {
+		return path.Base(params["filename"])
+	}
+	if name := path.Base(resp.Request.URL.Path); name != "/" && name != "." {
+		return name
+	}
+	return ""
+}
@@ -0,0 +1,83 @@
+package api
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/config"
+)
+
+func TestCheckIngestURL(t *testing.T) {
+	cfg := &config.Config{
+		URLIngestSchemes: []string{"https"},
+		URLIngestHosts:   []string{"files.example.com", "*.cdn.example.net"},
+	}
+	for raw, ok := range map[string]bool{
+		"https://files.example.com/a.pdf":     true,
+		"https://FILES.example.com/a.pdf":     true,
+		"https://eu.cdn.example.net/a.pdf":    true,
+		"http://files.example.com/a.pdf":      false,
+		"ftp://files.example.com/a.pdf":       false,
+		"https://evil.example.com/a.pdf":      false,
+		"https://cdn.example.net.evil.io/a":   false,
+		"https://user:pw@files.example.com/a": false,
+	} {
+		u, err := url.Parse(raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := checkIngestURL(cfg, u); (err == nil) != ok {
+			t.Errorf("checkIngestURL(%s) = %v, want allowed=%v", raw, err, ok)
+		}
+	}
+}
+
+func TestPublicIP(t *testing.T) {
+	for addr, public := range map[string]bool{
+		"93.184.216.34":   true,
+		"2606:4700::1111": true,
+		"127.0.0.1":       false,
+		"10.1.2.3":        false,
+		"192.168.0.1":     false,
+		"169.254.169.254": false,
+		"100.64.0.1":      false,
+		"0.0.0.0":         false,
+		"::1":             false,
+		"fd00::1":         false,
+	} {
+		if got := publicIP(net.ParseIP(addr)); got != public {
+			t.Errorf("publicIP(%s) = %v, want %v", addr, got, public)
+		}
+	}
+}
+
+func TestURLFetcherBlocksPrivateAddresses(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("%PDF-1.4"))
+	}))
+	defer target.Close()
+	redirect := httptest.NewServer(http.RedirectHandler("ftp://files.example.com/a.pdf", http.StatusFound))
+	defer redirect.Close()
+
+	cfg := &config.Config{URLIngestSchemes: []string{"http"}, URLIngestTimeout: 5 * time.Second}
+	if _, err := newURLFetcher(cfg).Get(target.URL); !errors.Is(err, errBlockedAddress) {
+		t.Errorf("loopback fetch error = %v, want errBlockedAddress", err)
+	}
+
+	cfg.URLIngestAllowPrivate = true
+	resp, err := newURLFetcher(cfg).Get(target.URL)
+	if err != nil {
+		t.Fatalf("fetch with private addresses allowed: %v", err)
+	}
+	resp.Body.Close()
+
+	// Redirects are held to the same rules as the original URL.
+	if _, err := newURLFetcher(cfg).Get(redirect.URL); err == nil {
+		t.Error("redirect to a disallowed scheme was followed")
+	}
+}
@@ -0,0 +1,124 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/httperr"
+)
+
+const (
+	// grantTokenPrefix distinguishes scoped tokens from API keys.
+	grantTokenPrefix = "vdg_"
+
+	actionUpload = "upload"
+	actionRead   = "read"
+
+	defaultGrantTTL = time.Hour
+)
+
+// Grant is the signed claim set carried by a scoped token.
+type Grant struct {
+	ID         string   `json:"jti"`
+	Principal  string   `json:"sub"`
+	Actions    []string `json:"act"`
+	DocumentID string   `json:"doc,omitempty"`
+	ExpiresAt  int64    `json:"exp"`
+}
+
+func (g *Grant) allows(action, documentID string) bool {
+	if g.DocumentID != "" && g.DocumentID != documentID {
+		return false
+	}
+	for _, a := range g.Actions {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) verifyGrant(token string) (*Grant, bool) {
+	payload, ok := s.signer.VerifyToken(strings.TrimPrefix(token, grantTokenPrefix))
+	if !ok {
+		return nil, false
+	}
+	var grant Grant
+	if err := json.Unmarshal(payload, &grant); err != nil {
+		return nil, false
+	}
+	if time.Now().Unix() >= grant.ExpiresAt {
+		return nil, false
+	}
+	return &grant, true
+}
+
+type createGrantBody struct {
+	Actions    []string `json:"actions"`
+	DocumentID string   `json:"documentId"`
+	TTL        string   `json:"ttl"`
+}
+
+// handleTokens mints a scoped token for the calling principal, e.g.
+// upload-only for an hour, or read access to a single document.
+func (s *Server) handleTokens(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		httperr.MethodNotAllowed(w)
+		return
+	}
+	var body createGrantBody
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&body); err != nil && !errors.Is(err, io.EOF) {
+		httperr.BadRequest(w, "invalid json body")
+		return
+	}
+	if len(body.Actions) == 0 {
+		httperr.BadRequest(w, "actions is required")
+		return
+	}
+	for _, action := range body.Actions {
+		if action != actionUpload && action != actionRead {
+			httperr.BadRequest(w, "unknown action "+action)
+			return
+		}
+		if action == actionUpload && body.DocumentID != "" {
+			httperr.BadRequest(w, "upload grants cannot be scoped to a document")
+			return
+		}
+	}
+	ttl := defaultGrantTTL
+	if body.TTL != "" {
+		parsed, err := time.ParseDuration(body.TTL)
+		if err != nil || parsed <= 0 {
+			httperr.BadRequest(w, "invalid ttl")
+			return
+		}
+		ttl = parsed
+	}
+	if ttl > s.cfg.GrantMaxTTL {
+		ttl = s.cfg.GrantMaxTTL
+	}
+	grant := Grant{
+		ID:         uuid.NewString(),
+		Principal:  principalFrom(r.Context()).ID,
+		Actions:    uniqueIDs(body.Actions),
+		DocumentID: body.DocumentID,
+		ExpiresAt:  time.Now().Add(ttl).Unix(),
+	}
+	payload, err := json.Marshal(grant)
+	if err != nil {
+		httperr.Internal(w, "failed to mint token")
+		return
+	}
+	respondJSON(w, http.StatusCreated, map[string]interface{}{
+		"token":      grantTokenPrefix + s.signer.SignToken(payload),
+		"actions":    grant.Actions,
+		"documentId": grant.DocumentID,
+		"expiresAt":  time.Unix(grant.ExpiresAt, 0).UTC(),
+	})
+}
@@ -0,0 +1,101 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/httperr"
+	"github.com/dharsanguruparan/VaultDrop/internal/idempotency"
+)
+
+// maxIdempotencyKey bounds the Idempotency-Key header; UUIDs and similar
+// client-generated keys fit easily.
+const maxIdempotencyKey = 255
+
+// UseIdempotency lets uploads carry an Idempotency-Key header, remembered in
+// store. Without a store the header is ignored. Call it before Run.
+func (s *Server) UseIdempotency(store *idempotency.Store) {
+	s.idempotency = store
+}
+
+// idempotentRequest is an upload that claimed its Idempotency-Key; the zero
+// value stands for a request without one.
+type idempotentRequest struct {
+	scope, key, request string
+}
+
+// claimIdempotencyKey claims the request's Idempotency-Key. When the key was
+// used before it answers the request itself, replaying the first response or
+// refusing a conflicting one, and returns false.
+func (s *Server) claimIdempotencyKey(w http.ResponseWriter, r *http.Request) (idempotentRequest, bool) {
+	key := r.Header.Get("Idempotency-Key")
+	if key == "" || s.idempotency == nil {
+		return idempotentRequest{}, true
+	}
+	if !validIdempotencyKey(key) {
+		httperr.BadRequest(w, "Idempotency-Key must be 1 to 255 printable ASCII characters")
+		return idempotentRequest{}, false
+	}
+	req := idempotentRequest{scope: principalFrom(r.Context()).ID, key: key, request: r.Method + " " + r.URL.RequestURI()}
+	first, err := s.idempotency.Begin(r.Context(), req.scope, req.key, req.request)
+	switch {
+	case errors.Is(err, idempotency.ErrInProgress):
+		httperr.Write(w, http.StatusConflict, httperr.CodeIdempotencyInProgress, err.Error(), nil)
+		return idempotentRequest{}, false
+	case errors.Is(err, idempotency.ErrMismatch):
+		httperr.Write(w, http.StatusUnprocessableEntity, httperr.CodeIdempotencyMismatch, err.Error(), nil)
+		return idempotentRequest{}, false
+	case err != nil:
+		log.Printf("idempotency key: %v", err)
+		httperr.Error(w, "idempotency keys are unavailable, retry later", http.StatusServiceUnavailable)
+		return idempotentRequest{}, false
+	case first != nil:
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Idempotent-Replayed", "true")
+		w.WriteHeader(first.Status)
+		w.Write(first.Body)
+		return idempotentRequest{}, false
+	}
+	return req, true
+}
+
+// completeIdempotencyKey records the response to a claimed request, so
+// retries get it back.
+func (s *Server) completeIdempotencyKey(ctx context.Context, req idempotentRequest, status int, body interface{}) {
+	if req.key == "" {
+		return
+	}
+	data, err := json.Marshal(body)
+	if err == nil {
+		err = s.idempotency.Complete(context.WithoutCancel(ctx), req.scope, req.key, req.request, idempotency.Response{Status: status, Body: data})
+	}
+	if err != nil {
+		log.Printf("idempotency key: %v", err)
+	}
+}
+
+// releaseIdempotencyKey frees the key of a request that failed, so a retry
+// runs again instead of replaying the error.
+func (s *Server) releaseIdempotencyKey(ctx context.Context, req idempotentRequest) {
+	if req.key == "" {
+		return
+	}
+	if err := s.idempotency.Abandon(context.WithoutCancel(ctx), req.scope, req.key); err != nil {
+		log.Printf("idempotency key: %v", err)
+	}
+}
+
+func validIdempotencyKey(key string) bool {
+	if len(key) == 0 || len(key) > maxIdempotencyKey {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] < 0x20 || key[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
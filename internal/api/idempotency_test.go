@@ -0,0 +1,22 @@
+package api
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidIdempotencyKey(t *testing.T) {
+	for key, want := range map[string]bool{
+		"":                                       false,
+		"5f0c6c1e-2c8f-4a8e-9d57-3c1b1c7c2f10":   true,
+		"upload 42/retry":                        true,
+		strings.Repeat("k", maxIdempotencyKey):   true,
+		strings.Repeat("k", maxIdempotencyKey+1): false,
+		"tab\there":                              false,
+		"naïve":                                  false,
+	} {
+		if got := validIdempotencyKey(key); got != want {
+			t.Errorf("validIdempotencyKey(%q) = %v, want %v", key, got, want)
+		}
+	}
+}
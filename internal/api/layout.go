@@ -0,0 +1,34 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/httperr"
+	"github.com/dharsanguruparan/VaultDrop/internal/repository"
+	"github.com/dharsanguruparan/VaultDrop/internal/s3storage"
+)
+
+// layoutDocument returns doc with its content replaced by the layout-preserving
+// text the worker stored next to the processed text, so ?layout=true combines
+// with every format, page and slice option.
+func (s *Server) layoutDocument(w http.ResponseWriter, r *http.Request, doc *repository.Document) (*repository.Document, bool) {
+	if doc.ProcessedKey == nil {
+		httperr.Error(w, "no layout text for this document", http.StatusNotFound)
+		return nil, false
+	}
+	text, err := s.store.GetProcessed(r.Context(), s3storage.LayoutKey(*doc.ProcessedKey))
+	if errors.Is(err, s3storage.ErrNotFound) {
+		httperr.Error(w, "no layout text for this document; it is stored when VAULTDROP_EXTRACT_LAYOUT is on or by backfilling the layout stage", http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		log.Printf("get layout text %s: %v", doc.ID, err)
+		s.storageError(w, err, "failed to load layout text")
+		return nil, false
+	}
+	layout := *doc
+	layout.Content = string(text)
+	return &layout, true
+}
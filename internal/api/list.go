@@ -0,0 +1,98 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/httperr"
+	"github.com/dharsanguruparan/VaultDrop/internal/repository"
+)
+
+// handleListDocuments serves GET /documents. Callers see their own documents;
+// admins see everyone's and may narrow to one principal with ?owner=.
+func (s *Server) handleListDocuments(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filter := repository.ListFilter{
+		OwnerID:        s.ownerScope(r),
+		FileNamePrefix: q.Get("prefix"),
+		Cursor:         q.Get("cursor"),
+		WithTotal:      q.Get("total") == "true",
+	}
+	if filter.OwnerID == "" {
+		filter.OwnerID = q.Get("owner")
+	}
+	if raw := q.Get("status"); raw != "" {
+		for _, status := range strings.Split(raw, ",") {
+			switch st := repository.DocumentStatus(strings.TrimSpace(status)); st {
+			case repository.StatusQueued, repository.StatusProcessing, repository.StatusCompleted, repository.StatusFailed, repository.StatusCancelled:
+				filter.Statuses = append(filter.Statuses, st)
+			default:
+				httperr.BadRequest(w, "invalid status")
+				return
+			}
+		}
+	}
+	for name, dst := range map[string]*time.Time{"createdFrom": &filter.CreatedFrom, "createdTo": &filter.CreatedTo} {
+		if raw := q.Get(name); raw != "" {
+			t, err := time.Parse(time.RFC3339, raw)
+			if err != nil {
+				httperr.BadRequest(w, "invalid "+name)
+				return
+			}
+			*dst = t
+		}
+	}
+	meta, err := metadataFilter(q)
+	if err != nil {
+		httperr.BadRequest(w, err.Error())
+		return
+	}
+	filter.Metadata = meta
+	if raw := q.Get("tag"); raw != "" {
+		tags, err := normalizeTags(strings.Split(raw, ","))
+		if err != nil {
+			httperr.BadRequest(w, err.Error())
+			return
+		}
+		filter.Tags = tags
+	}
+	if raw := q.Get("maxCoverage"); raw != "" {
+		coverage, err := strconv.ParseFloat(raw, 64)
+		if err != nil || coverage < 0 || coverage > 1 {
+			httperr.BadRequest(w, "invalid maxCoverage")
+			return
+		}
+		filter.MaxCoverage = &coverage
+	}
+	switch q.Get("order") {
+	case "", "desc":
+	case "asc":
+		filter.Ascending = true
+	default:
+		httperr.BadRequest(w, "invalid order")
+		return
+	}
+	if raw := q.Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			httperr.BadRequest(w, "invalid limit")
+			return
+		}
+		filter.Limit = n
+	}
+	page, err := s.repo.List(r.Context(), filter)
+	if err != nil {
+		if errors.Is(err, repository.ErrInvalidCursor) {
+			httperr.Write(w, http.StatusBadRequest, httperr.CodeInvalidCursor, "invalid cursor", nil)
+			return
+		}
+		log.Printf("list documents: %v", err)
+		httperr.Internal(w, "failed to list documents")
+		return
+	}
+	respondJSON(w, http.StatusOK, page)
+}
@@ -0,0 +1,141 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/httperr"
+	"github.com/dharsanguruparan/VaultDrop/internal/maintenance"
+	"github.com/dharsanguruparan/VaultDrop/internal/repository"
+)
+
+// handleDocumentCounts serves GET /admin/counts: documents per status.
+func (s *Server) handleDocumentCounts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httperr.MethodNotAllowed(w)
+		return
+	}
+	counts, err := s.repo.CountByStatus(r.Context())
+	if err != nil {
+		log.Printf("count documents: %v", err)
+		httperr.Internal(w, "failed to count documents")
+		return
+	}
+	respondJSON(w, http.StatusOK, counts)
+}
+
+// failureSamples is how many document ids GET /admin/failed lists per error.
+const failureSamples = 5
+
+// handleFailures serves GET /admin/failed: failed documents grouped by error
+// message, so an outage shows up as one large group whose ids can be passed
+// to POST /admin/requeue. ?failedWithin= (e.g. 24h or 7d) narrows the window.
+func (s *Server) handleFailures(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httperr.MethodNotAllowed(w)
+		return
+	}
+	var since time.Time
+	if raw := r.URL.Query().Get("failedWithin"); raw != "" {
+		d, err := maintenance.ParseAge(raw)
+		if err != nil {
+			httperr.BadRequest(w, "invalid failedWithin")
+			return
+		}
+		since = time.Now().Add(-d)
+	}
+	groups, err := s.repo.FailureSummary(r.Context(), since, failureSamples)
+	if err != nil {
+		log.Printf("summarize failures: %v", err)
+		httperr.Internal(w, "failed to list failures")
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{"failures": groups})
+}
+
+type requeueBody struct {
+	// FailedWithin limits the requeue to documents that failed in this long
+	// before now; empty requeues every failure.
+	FailedWithin string `json:"failedWithin"`
+	// DocumentIDs limits the requeue to these failed documents.
+	DocumentIDs []string `json:"documentIds"`
+	Limit       int      `json:"limit"`
+}
+
+// handleRequeue serves POST /admin/requeue: failed documents go back to
+// queued and are enqueued for extraction.
+func (s *Server) handleRequeue(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		httperr.MethodNotAllowed(w)
+		return
+	}
+	var body requeueBody
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&body); err != nil && !errors.Is(err, io.EOF) {
+		httperr.BadRequest(w, "invalid json body")
+		return
+	}
+	var since time.Time
+	if body.FailedWithin != "" {
+		d, err := maintenance.ParseAge(body.FailedWithin)
+		if err != nil {
+			httperr.BadRequest(w, "invalid failedWithin")
+			return
+		}
+		since = time.Now().Add(-d)
+	}
+	if body.Limit < 0 {
+		httperr.BadRequest(w, "invalid limit")
+		return
+	}
+	res, err := maintenance.Requeue(r.Context(), s.repo, s.queue, since, body.DocumentIDs, body.Limit)
+	if err != nil {
+		log.Printf("requeue: %v", err)
+		httperr.Internal(w, "failed to requeue documents")
+		return
+	}
+	respondJSON(w, http.StatusOK, res)
+}
+
+type purgeBody struct {
+	OlderThan string                      `json:"olderThan"`
+	Statuses  []repository.DocumentStatus `json:"statuses"`
+}
+
+// handlePurge serves POST /admin/purge: documents older than the given age
+// are deleted together with their objects.
+func (s *Server) handlePurge(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		httperr.MethodNotAllowed(w)
+		return
+	}
+	var body purgeBody
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&body); err != nil {
+		httperr.BadRequest(w, "invalid json body")
+		return
+	}
+	age, err := maintenance.ParseAge(body.OlderThan)
+	if err != nil {
+		httperr.BadRequest(w, "olderThan must be a positive duration or a number of days")
+		return
+	}
+	if len(body.Statuses) == 0 {
+		body.Statuses = []repository.DocumentStatus{repository.StatusCompleted, repository.StatusFailed, repository.StatusCancelled}
+	}
+	for _, status := range body.Statuses {
+		if !maintenance.Purgeable(status) {
+			httperr.BadRequest(w, "only completed, failed and cancelled documents can be purged")
+			return
+		}
+	}
+	res, err := maintenance.Purge(r.Context(), s.repo, s.store, time.Now().Add(-age), body.Statuses)
+	if err != nil {
+		log.Printf("purge: %v", err)
+		httperr.Internal(w, "failed to purge documents")
+		return
+	}
+	respondJSON(w, http.StatusOK, res)
+}
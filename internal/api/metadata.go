@@ -0,0 +1,90 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+const (
+	maxMetadataKeys     = 32
+	maxMetadataValueLen = 1024
+	// metaFieldPrefix marks a multipart field or list query parameter that
+	// carries one metadata pair: meta.invoice_number=INV-42.
+	metaFieldPrefix = "meta."
+)
+
+var metadataKey = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,64}$`)
+
+var errInvalidMetadata = errors.New("invalid metadata")
+
+// checkMetadata enforces the limits on client metadata: at most 32 keys made
+// of letters, digits, '_', '.' and '-' (up to 64 characters), and values of
+// up to 1 KiB.
+func checkMetadata(meta map[string]string) error {
+	if len(meta) > maxMetadataKeys {
+		return fmt.Errorf("%w: more than %d keys", errInvalidMetadata, maxMetadataKeys)
+	}
+	for key, value := range meta {
+		if !metadataKey.MatchString(key) {
+			return fmt.Errorf("%w: bad key %q", errInvalidMetadata, key)
+		}
+		if len(value) > maxMetadataValueLen {
+			return fmt.Errorf("%w: value of %q exceeds %d bytes", errInvalidMetadata, key, maxMetadataValueLen)
+		}
+	}
+	return nil
+}
+
+// addMetadataPart merges one non-file multipart part into meta: a "metadata"
+// part holding a JSON object of strings, or a "meta.<key>" field. Other parts
+// are ignored.
+func addMetadataPart(meta map[string]string, part *multipart.Part) error {
+	name := part.FormName()
+	switch {
+	case name == "metadata":
+		var obj map[string]string
+		raw, err := io.ReadAll(io.LimitReader(part, maxMetadataKeys*(maxMetadataValueLen+128)+1))
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(raw, &obj); err != nil {
+			return fmt.Errorf("%w: metadata part must be a JSON object of strings", errInvalidMetadata)
+		}
+		for key, value := range obj {
+			meta[key] = value
+		}
+	case strings.HasPrefix(name, metaFieldPrefix):
+		value, err := io.ReadAll(io.LimitReader(part, maxMetadataValueLen+1))
+		if err != nil {
+			return err
+		}
+		meta[strings.TrimPrefix(name, metaFieldPrefix)] = string(value)
+	default:
+		return nil
+	}
+	return checkMetadata(meta)
+}
+
+// metadataFilter collects the meta.<key>=value parameters of a list query.
+func metadataFilter(q url.Values) (map[string]string, error) {
+	var meta map[string]string
+	for name, values := range q {
+		if !strings.HasPrefix(name, metaFieldPrefix) {
+			continue
+		}
+		if meta == nil {
+			meta = map[string]string{}
+		}
+		meta[strings.TrimPrefix(name, metaFieldPrefix)] = values[0]
+	}
+	if err := checkMetadata(meta); err != nil {
+		return nil, err
+	}
+	return meta, nil
+}
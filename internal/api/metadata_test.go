@@ -0,0 +1,68 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestNextFilePartCollectsMetadata(t *testing.T) {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	mw.WriteField("metadata", `{"customer_id": "c-7", "invoice_number": "INV-1"}`)
+	mw.WriteField("meta.invoice_number", "INV-42")
+	mw.WriteField("comment", "ignored")
+	fw, _ := mw.CreateFormFile("file", "a.pdf")
+	fw.Write([]byte("%PDF-1.4"))
+	mw.WriteField("meta.late", "not read")
+	mw.Close()
+
+	meta := map[string]string{}
+	part, err := nextFilePart(multipart.NewReader(&buf, mw.Boundary()), meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body, _ := io.ReadAll(part); string(body) != "%PDF-1.4" {
+		t.Errorf("file part = %q", body)
+	}
+	if len(meta) != 2 || meta["customer_id"] != "c-7" || meta["invoice_number"] != "INV-42" {
+		t.Errorf("metadata = %v", meta)
+	}
+}
+
+func TestNextFilePartRejectsBadMetadata(t *testing.T) {
+	for name, field := range map[string][2]string{
+		"not an object": {"metadata", `["a"]`},
+		"number value":  {"metadata", `{"n": 1}`},
+		"bad key":       {"meta.a b", "x"},
+		"long value":    {"meta.note", strings.Repeat("x", maxMetadataValueLen+1)},
+	} {
+		var buf bytes.Buffer
+		mw := multipart.NewWriter(&buf)
+		mw.WriteField(field[0], field[1])
+		fw, _ := mw.CreateFormFile("file", "a.pdf")
+		fw.Write([]byte("%PDF-1.4"))
+		mw.Close()
+		if _, err := nextFilePart(multipart.NewReader(&buf, mw.Boundary()), map[string]string{}); !errors.Is(err, errInvalidMetadata) {
+			t.Errorf("%s: err = %v, want errInvalidMetadata", name, err)
+		}
+	}
+}
+
+func TestMetadataFilter(t *testing.T) {
+	q, _ := url.ParseQuery("status=failed&meta.invoice_number=INV-42&meta.customer_id=c-7")
+	meta, err := metadataFilter(q)
+	if err != nil || len(meta) != 2 || meta["invoice_number"] != "INV-42" || meta["customer_id"] != "c-7" {
+		t.Errorf("metadataFilter = %v, %v", meta, err)
+	}
+	if meta, err := metadataFilter(url.Values{"status": {"failed"}}); meta != nil || err != nil {
+		t.Errorf("no meta params: %v, %v", meta, err)
+	}
+	if _, err := metadataFilter(url.Values{"meta.": {"x"}}); !errors.Is(err, errInvalidMetadata) {
+		t.Errorf("empty key: err = %v", err)
+	}
+}
@@ -0,0 +1,316 @@
+package api
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/httperr"
+)
+
+// openAPISpec is hand-maintained next to the handlers; update it whenever a
+// route or JSON body changes.
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+const maxValidatedBody = 1 << 20
+
+const swaggerUIPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>VaultDrop API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+  <script>window.ui = SwaggerUIBundle({url: "/openapi.json", dom_id: "#swagger-ui"});</script>
+</body>
+</html>
+`
+
+func (s *Server) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httperr.MethodNotAllowed(w)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(openAPISpec)
+}
+
+func (s *Server) handleDocs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httperr.MethodNotAllowed(w)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	io.WriteString(w, swaggerUIPage)
+}
+
+// schema is the subset of JSON Schema used by openapi.json.
+type schema struct {
+	Ref                  string             `json:"$ref"`
+	Type                 string             `json:"type"`
+	Required             []string           `json:"required"`
+	Properties           map[string]*schema `json:"properties"`
+	AdditionalProperties *bool              `json:"additionalProperties"`
+	Items                *schema            `json:"items"`
+	Enum                 []interface{}      `json:"enum"`
+	MinLength            *int               `json:"minLength"`
+	MaxLength            *int               `json:"maxLength"`
+	Minimum              *float64           `json:"minimum"`
+	Maximum              *float64           `json:"maximum"`
+	MinItems             *int               `json:"minItems"`
+	MaxItems             *int               `json:"maxItems"`
+}
+
+type openAPIDocument struct {
+	Components struct {
+		Schemas map[string]*schema `json:"schemas"`
+	} `json:"components"`
+	Paths map[string]map[string]json.RawMessage `json:"paths"`
+}
+
+type openAPIOperation struct {
+	RequestBody *struct {
+		Required bool `json:"required"`
+		Content  map[string]struct {
+			Schema *schema `json:"schema"`
+		} `json:"content"`
+	} `json:"requestBody"`
+}
+
+type validatedRoute struct {
+	method   string
+	segments []string
+	body     *schema
+	required bool
+}
+
+// requestValidator checks JSON request bodies against the schemas declared in
+// openapi.json before they reach a handler.
+type requestValidator struct {
+	routes  []validatedRoute
+	schemas map[string]*schema
+}
+
+func newRequestValidator(spec []byte) (*requestValidator, error) {
+	var doc openAPIDocument
+	if err := json.Unmarshal(spec, &doc); err != nil {
+		return nil, fmt.Errorf("parse openapi spec: %w", err)
+	}
+	v := &requestValidator{schemas: doc.Components.Schemas}
+	for path, item := range doc.Paths {
+		for method, raw := range item {
+			if method == "parameters" {
+				continue
+			}
+			var op openAPIOperation
+			if err := json.Unmarshal(raw, &op); err != nil {
+				return nil, fmt.Errorf("parse operation %s %s: %w", method, path, err)
+			}
+			if op.RequestBody == nil {
+				continue
+			}
+			media, ok := op.RequestBody.Content["application/json"]
+			if !ok || media.Schema == nil {
+				continue
+			}
+			v.routes = append(v.routes, validatedRoute{
+				method:   strings.ToUpper(method),
+				segments: strings.Split(strings.Trim(path, "/"), "/"),
+				body:     media.Schema,
+				required: op.RequestBody.Required,
+			})
+		}
+	}
+	return v, nil
+}
+
+func (v *requestValidator) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := v.match(r)
+		if route == nil || !isJSONRequest(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxValidatedBody))
+		if err != nil {
+			httperr.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		if len(bytes.TrimSpace(data)) == 0 {
+			if route.required {
+				httperr.BadRequest(w, "request validation failed: body is required")
+				return
+			}
+		} else {
+			dec := json.NewDecoder(bytes.NewReader(data))
+			dec.UseNumber()
+			var value interface{}
+			if err := dec.Decode(&value); err != nil {
+				httperr.BadRequest(w, "request validation failed: invalid json")
+				return
+			}
+			if err := v.validate(value, route.body, "body"); err != nil {
+				httperr.BadRequest(w, "request validation failed: "+err.Error())
+				return
+			}
+		}
+		r.Body = io.NopCloser(bytes.NewReader(data))
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (v *requestValidator) match(r *http.Request) *validatedRoute {
+	segments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	for i := range v.routes {
+		route := &v.routes[i]
+		if route.method != r.Method || len(route.segments) != len(segments) {
+			continue
+		}
+		matched := true
+		for j, seg := range route.segments {
+			if strings.HasPrefix(seg, "{") || seg == segments[j] {
+				continue
+			}
+			matched = false
+			break
+		}
+		if matched {
+			return route
+		}
+	}
+	return nil
+}
+
+func (v *requestValidator) resolve(sch *schema) (*schema, error) {
+	for sch != nil && sch.Ref != "" {
+		name := strings.TrimPrefix(sch.Ref, "#/components/schemas/")
+		next, ok := v.schemas[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown schema %s", sch.Ref)
+		}
+		sch = next
+	}
+	return sch, nil
+}
+
+func (v *requestValidator) validate(value interface{}, sch *schema, path string) error {
+	sch, err := v.resolve(sch)
+	if err != nil || sch == nil {
+		return err
+	}
+	if len(sch.Enum) > 0 && !enumContains(sch.Enum, value) {
+		return fmt.Errorf("%s: value not allowed", path)
+	}
+	switch sch.Type {
+	case "object":
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s: expected object", path)
+		}
+		for _, name := range sch.Required {
+			if _, ok := obj[name]; !ok {
+				return fmt.Errorf("%s.%s: is required", path, name)
+			}
+		}
+		keys := make([]string, 0, len(obj))
+		for key := range obj {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			prop, ok := sch.Properties[key]
+			if !ok {
+				if sch.AdditionalProperties != nil && !*sch.AdditionalProperties {
+					return fmt.Errorf("%s.%s: unknown field", path, key)
+				}
+				continue
+			}
+			if err := v.validate(obj[key], prop, path+"."+key); err != nil {
+				return err
+			}
+		}
+	case "array":
+		arr, ok := value.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s: expected array", path)
+		}
+		if sch.MinItems != nil && len(arr) < *sch.MinItems {
+			return fmt.Errorf("%s: expected at least %d items", path, *sch.MinItems)
+		}
+		if sch.MaxItems != nil && len(arr) > *sch.MaxItems {
+			return fmt.Errorf("%s: expected at most %d items", path, *sch.MaxItems)
+		}
+		for i, item := range arr {
+			if err := v.validate(item, sch.Items, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("%s: expected string", path)
+		}
+		if sch.MinLength != nil && len(str) < *sch.MinLength {
+			return fmt.Errorf("%s: shorter than %d characters", path, *sch.MinLength)
+		}
+		if sch.MaxLength != nil && len(str) > *sch.MaxLength {
+			return fmt.Errorf("%s: longer than %d characters", path, *sch.MaxLength)
+		}
+	case "integer", "number":
+		num, ok := value.(json.Number)
+		if !ok {
+			return fmt.Errorf("%s: expected %s", path, sch.Type)
+		}
+		if sch.Type == "integer" {
+			if _, err := num.Int64(); err != nil {
+				return fmt.Errorf("%s: expected integer", path)
+			}
+		}
+		f, err := num.Float64()
+		if err != nil {
+			return fmt.Errorf("%s: expected number", path)
+		}
+		if sch.Minimum != nil && f < *sch.Minimum {
+			return fmt.Errorf("%s: must be >= %v", path, *sch.Minimum)
+		}
+		if sch.Maximum != nil && f > *sch.Maximum {
+			return fmt.Errorf("%s: must be <= %v", path, *sch.Maximum)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("%s: expected boolean", path)
+		}
+	}
+	return nil
+}
+
+func enumContains(enum []interface{}, value interface{}) bool {
+	for _, candidate := range enum {
+		if fmt.Sprint(candidate) == fmt.Sprint(value) {
+			return true
+		}
+	}
+	return false
+}
+
+func isJSONRequest(r *http.Request) bool {
+	ct := r.Header.Get("Content-Type")
+	if ct == "" {
+		return r.ContentLength != 0
+	}
+	media, _, err := mime.ParseMediaType(ct)
+	return err == nil && media == "application/json"
+}
@@ -0,0 +1,71 @@
+package api
+
+import "testing"
+
+func TestRequestValidatorErasureBody(t *testing.T) {
+	v, err := newRequestValidator(openAPISpec)
+	if err != nil {
+		t.Fatalf("load spec: %v", err)
+	}
+	body := &schema{Ref: "#/components/schemas/ErasureRequestBody"}
+	valid := map[string]interface{}{
+		"subject":     "user@example.com",
+		"documentIds": []interface{}{"a", "b"},
+	}
+	if err := v.validate(valid, body, "body"); err != nil {
+		t.Fatalf("expected valid body, got %v", err)
+	}
+	cases := map[string]map[string]interface{}{
+		"missing subject": {"documentIds": []interface{}{"a"}},
+		"empty ids":       {"subject": "x", "documentIds": []interface{}{}},
+		"unknown field":   {"subject": "x", "documentIds": []interface{}{"a"}, "extra": true},
+		"wrong type":      {"subject": 42, "documentIds": []interface{}{"a"}},
+	}
+	for name, value := range cases {
+		if err := v.validate(value, body, "body"); err == nil {
+			t.Fatalf("%s: expected validation error", name)
+		}
+	}
+}
+
+func TestRequestValidatorOwnerErasureBody(t *testing.T) {
+	v, err := newRequestValidator(openAPISpec)
+	if err != nil {
+		t.Fatalf("load spec: %v", err)
+	}
+	body := &schema{Ref: "#/components/schemas/OwnerErasureBody"}
+	if err := v.validate(map[string]interface{}{"ownerId": "acme"}, body, "body"); err != nil {
+		t.Fatalf("expected valid body, got %v", err)
+	}
+	for name, value := range map[string]map[string]interface{}{
+		"missing owner": {"subject": "user@example.com"},
+		"empty owner":   {"ownerId": ""},
+		"document ids":  {"ownerId": "acme", "documentIds": []interface{}{"a"}},
+	} {
+		if err := v.validate(value, body, "body"); err == nil {
+			t.Errorf("%s: expected validation error", name)
+		}
+	}
+}
+
+func TestRequestValidatorExportBody(t *testing.T) {
+	v, err := newRequestValidator(openAPISpec)
+	if err != nil {
+		t.Fatalf("load spec: %v", err)
+	}
+	body := &schema{Ref: "#/components/schemas/ExportBody"}
+	valid := map[string]interface{}{"documentIds": []interface{}{"a", "b"}, "artifact": "text", "async": true}
+	if err := v.validate(valid, body, "body"); err != nil {
+		t.Fatalf("expected valid body, got %v", err)
+	}
+	for name, value := range map[string]map[string]interface{}{
+		"missing ids":   {"artifact": "raw"},
+		"empty ids":     {"documentIds": []interface{}{}},
+		"bad artifact":  {"documentIds": []interface{}{"a"}, "artifact": "pdf"},
+		"unknown field": {"documentIds": []interface{}{"a"}, "format": "zip"},
+	} {
+		if err := v.validate(value, body, "body"); err == nil {
+			t.Errorf("%s: expected validation error", name)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package api
+
+import (
+	"bytes"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/httperr"
+	"github.com/dharsanguruparan/VaultDrop/internal/repository"
+)
+
+// previewSecurityPolicy allows the page's inline styles and nothing else:
+// previews show untrusted text and never need scripts.
+const previewSecurityPolicy = "default-src 'none'; style-src 'unsafe-inline'; base-uri 'none'; form-action 'none'; frame-ancestors 'self'"
+
+var previewTemplate = template.Must(template.New("preview").Funcs(template.FuncMap{
+	"percent": func(f float64) float64 { return f * 100 },
+	"navLink": navLink,
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Doc.FileName}}{{if .Count}} — page {{.Page}} of {{.Count}}{{end}}</title>
+<style>
+body { margin: 0; font: 15px/1.5 system-ui, sans-serif; color: #1d2330; background: #f7f8fb; }
+header { padding: 1rem 1.5rem; background: #fff; border-bottom: 1px solid #d6dae3; }
+h1 { font-size: 1.25rem; margin: 0 0 0.5rem; word-break: break-all; }
+dl { display: grid; grid-template-columns: max-content 1fr; gap: 0.15rem 1rem; margin: 0; font-size: 0.9rem; }
+dt { color: #687083; }
+dd { margin: 0; word-break: break-all; }
+main { max-width: 960px; margin: 0 auto; padding: 1.5rem; }
+nav { display: flex; gap: 1rem; align-items: center; margin: 0.5rem 0; }
+nav span.off { color: #aab0bd; }
+pre { padding: 1rem; white-space: pre-wrap; background: #fff; border: 1px solid #d6dae3; border-radius: 4px; }
+p.note { color: #687083; }
+</style>
+</head>
+<body>
+<header>
+<h1>{{.Doc.FileName}}</h1>
+<dl>
+<dt>Document</dt><dd>{{.Doc.ID}}</dd>
+<dt>Status</dt><dd>{{.Doc.Status}}</dd>
+<dt>Uploaded</dt><dd>{{.Doc.CreatedAt.UTC.Format "2006-01-02 15:04:05 UTC"}}</dd>
+{{- with .Doc.Extraction}}
+<dt>Extraction</dt><dd>{{.Pages}} pages, {{.Characters}} characters, {{printf "%.0f" (percent .Coverage)}}% of pages with text</dd>
+{{- end}}
+{{- if .Doc.Tags}}
+<dt>Tags</dt><dd>{{range $i, $t := .Doc.Tags}}{{if $i}}, {{end}}{{$t}}{{end}}</dd>
+{{- end}}
+{{- range .Metadata}}
+<dt>{{.Key}}</dt><dd>{{.Value}}</dd>
+{{- end}}
+</dl>
+</header>
+<main>
+{{- if .Note}}
+<p class="note">{{.Note}}</p>
+{{- else}}
+{{template "nav" .}}
+<pre>{{.Text}}</pre>
+{{template "nav" .}}
+{{- end}}
+</main>
+</body>
+</html>
+{{define "nav"}}{{if gt .Count 1}}<nav>
+{{navLink .Links "first" "« First"}} {{navLink .Links "prev" "‹ Previous"}}
+<span>Page {{.Page}} of {{.Count}}</span>
+{{navLink .Links "next" "Next ›"}} {{navLink .Links "last" "Last »"}}
+</nav>{{end}}{{end}}
+`))
+
+// navLink renders the pager link for rel, or label alone when there is no
+// such page.
+func navLink(links map[string]string, rel, label string) template.HTML {
+	href, ok := links[rel]
+	if !ok {
+		return template.HTML(`<span class="off">` + template.HTMLEscapeString(label) + `</span>`)
+	}
+	return template.HTML(`<a rel="` + rel + `" href="` + template.HTMLEscapeString(href) + `">` + template.HTMLEscapeString(label) + `</a>`)
+}
+
+// previewPage is what previewTemplate renders.
+type previewPage struct {
+	Doc      *repository.Document
+	Metadata []metadataPair
+	// Note replaces the text when there is none to show.
+	Note        string
+	Text        string
+	Page, Count int
+	Links       map[string]string
+}
+
+type metadataPair struct{ Key, Value string }
+
+// handleDocumentPreview serves GET /documents/{id}/preview: one page of the
+// extracted text as HTML under a header with the file name, status and
+// metadata, for reviewing a document in the browser. ?page= picks the page;
+// ?layout=true shows the layout-preserving text.
+func (s *Server) handleDocumentPreview(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodGet {
+		httperr.MethodNotAllowed(w)
+		return
+	}
+	doc, ok := s.lookupDocument(w, r, id)
+	if !ok {
+		return
+	}
+	page := 1
+	if raw := r.URL.Query().Get("page"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			httperr.BadRequest(w, "page must be a positive integer")
+			return
+		}
+		page = n
+	}
+	view := previewPage{Doc: doc, Metadata: sortedMetadata(doc.Metadata)}
+	status := http.StatusOK
+	switch {
+	case doc.TextPurgedAt != nil:
+		view.Note, status = "The extracted text expired under the retention policy.", http.StatusGone
+	case doc.Status != repository.StatusCompleted:
+		view.Note, status = "The text appears here once processing completes.", http.StatusAccepted
+	}
+	if view.Note == "" {
+		if doc, ok = s.unarchiveDocument(w, r, doc); !ok {
+			return
+		}
+		if layout, _ := strconv.ParseBool(r.URL.Query().Get("layout")); layout {
+			if doc, ok = s.layoutDocument(w, r, doc); !ok {
+				return
+			}
+		}
+		pages := splitPages(doc.Content)
+		if page > len(pages) {
+			httperr.BadRequest(w, "page out of range; the text has "+strconv.Itoa(len(pages))+" page(s)")
+			return
+		}
+		view.Text, view.Page, view.Count = pages[page-1].Text, page, len(pages)
+		view.Links = previewLinks(r, pageLinks(page, len(pages)))
+		setTextLinks(w, r, pageLinks(page, len(pages)))
+	}
+	var buf bytes.Buffer
+	if err := previewTemplate.Execute(&buf, view); err != nil {
+		log.Printf("render preview %s: %v", id, err)
+		httperr.Internal(w, "failed to render preview")
+		return
+	}
+	w.Header().Set("Content-Security-Policy", previewSecurityPolicy)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if status != http.StatusOK {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(status)
+		w.Write(buf.Bytes())
+		return
+	}
+	respondCached(w, r, doc.UpdatedAt, "text/html; charset=utf-8", buf.Bytes())
+}
+
+// previewLinks turns pageLinks into hrefs relative to the request, keeping
+// its other query parameters.
+func previewLinks(r *http.Request, rels map[string]url.Values) map[string]string {
+	links := make(map[string]string, len(rels))
+	for rel, params := range rels {
+		q := r.URL.Query()
+		for key, values := range params {
+			q[key] = values
+		}
+		links[rel] = (&url.URL{Path: r.URL.Path, RawQuery: q.Encode()}).String()
+	}
+	return links
+}
+
+func sortedMetadata(metadata map[string]string) []metadataPair {
+	pairs := make([]metadataPair, 0, len(metadata))
+	for k, v := range metadata {
+		pairs = append(pairs, metadataPair{k, v})
+	}
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i].Key < pairs[j].Key })
+	return pairs
+}
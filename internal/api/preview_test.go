@@ -0,0 +1,53 @@
+package api
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/repository"
+)
+
+func TestPreviewTemplate(t *testing.T) {
+	doc := &repository.Document{
+		ID:        "d1",
+		FileName:  `<script>alert(1)</script>.pdf`,
+		Status:    repository.StatusCompleted,
+		Metadata:  map[string]string{"invoice": "INV-42", "customer": "Acme & Co"},
+		CreatedAt: time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC),
+	}
+	r := httptest.NewRequest("GET", "/documents/d1/preview?page=2&layout=true", nil)
+	view := previewPage{
+		Doc:      doc,
+		Metadata: sortedMetadata(doc.Metadata),
+		Text:     "Total <b>due</b>",
+		Page:     2,
+		Count:    3,
+		Links:    previewLinks(r, pageLinks(2, 3)),
+	}
+	var buf bytes.Buffer
+	if err := previewTemplate.Execute(&buf, view); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"&lt;script&gt;alert(1)&lt;/script&gt;.pdf",
+		"Total &lt;b&gt;due&lt;/b&gt;",
+		"Acme &amp; Co",
+		"Page 2 of 3",
+		`href="/documents/d1/preview?layout=true&amp;page=3"`,
+		`href="/documents/d1/preview?layout=true&amp;page=1"`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("preview should contain %s:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "<script>") {
+		t.Errorf("file name not escaped:\n%s", out)
+	}
+	if strings.Index(out, "customer") > strings.Index(out, "invoice") {
+		t.Error("metadata should be sorted by key")
+	}
+}
@@ -0,0 +1,37 @@
+package api
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/httperr"
+	"github.com/dharsanguruparan/VaultDrop/internal/progress"
+	"github.com/dharsanguruparan/VaultDrop/internal/repository"
+)
+
+// handleDocumentProgress serves GET /documents/{id}/progress: the status and,
+// while processing, the worker's latest stage report.
+func (s *Server) handleDocumentProgress(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodGet {
+		httperr.MethodNotAllowed(w)
+		return
+	}
+	doc, ok := s.lookupDocument(w, r, id)
+	if !ok {
+		return
+	}
+	body := struct {
+		Status repository.DocumentStatus `json:"status"`
+		*progress.Report
+	}{Status: doc.Status}
+	if doc.Status == repository.StatusProcessing {
+		report, err := s.progress.Get(r.Context(), id)
+		if err != nil {
+			log.Printf("get progress %s: %v", id, err)
+			httperr.Internal(w, "failed to load progress")
+			return
+		}
+		body.Report = report
+	}
+	respondJSON(w, http.StatusOK, body)
+}
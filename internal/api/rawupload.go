@@ -0,0 +1,147 @@
+package api
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+
+	"github.com/google/uuid"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/httperr"
+	"github.com/dharsanguruparan/VaultDrop/internal/repository"
+)
+
+var errChecksumMismatch = errors.New("body does not match Content-MD5")
+
+// handleRawUpload serves PUT /documents/{id or name}: the request body is
+// the file itself, so curl --upload-file and scripts need no multipart
+// framing. A UUID in the path becomes the document's id, which makes the
+// request safe to retry (a second PUT gets 409); anything else is taken as
+// the file name and the id is generated. Content-Length is required, and a
+// Content-MD5 header, when sent, is checked before the document is created.
+func (s *Server) handleRawUpload(w http.ResponseWriter, r *http.Request, target string) {
+	s.rawUpload(w, r, target, principalFrom(r.Context()).ID, s.cfg.MaxFileSize)
+}
+
+// rawUpload files the request body as a document owned by owner, refusing
+// bodies over maxSize bytes.
+func (s *Server) rawUpload(w http.ResponseWriter, r *http.Request, target, owner string, maxSize int64) {
+	contentType := r.Header.Get("Content-Type")
+	if r.ContentLength < 0 {
+		httperr.Error(w, "Content-Length required", http.StatusLengthRequired)
+		return
+	}
+	if r.ContentLength == 0 {
+		s.rejectUpload(w, r, repository.RejectEmpty, errEmptyFile.Error(), 0, contentType)
+		return
+	}
+	if maxSize > s.cfg.MaxFileSize {
+		maxSize = s.cfg.MaxFileSize
+	}
+	if r.ContentLength > maxSize {
+		detail := fmt.Sprintf("%v (%d bytes)", errFileTooLarge, maxSize)
+		s.rejectUpload(w, r, repository.RejectTooLarge, detail, r.ContentLength, contentType)
+		return
+	}
+	if contentType != "" {
+		// The body is sniffed either way; this only turns away requests
+		// that announce a type that is not accepted.
+		mediaType, _, err := mime.ParseMediaType(contentType)
+		if err != nil || (mediaType != "application/octet-stream" && !s.accepts(mediaType)) {
+			s.rejectUpload(w, r, repository.RejectUnsupportedType, s.unsupportedTypeDetail(contentType), r.ContentLength, contentType)
+			return
+		}
+	}
+	wantMD5, err := parseContentMD5(r.Header.Get("Content-MD5"))
+	if err != nil {
+		s.rejectUpload(w, r, repository.RejectMalformed, err.Error(), r.ContentLength, contentType)
+		return
+	}
+	processAt, err := parseProcessAt(r)
+	if err != nil {
+		httperr.BadRequest(w, err.Error())
+		return
+	}
+
+	t := uploadTarget{size: r.ContentLength, owner: owner, processAt: processAt}
+	if id, err := uuid.Parse(target); err == nil {
+		t.id, t.clientID = id.String(), true
+		t.fileName = r.URL.Query().Get("filename")
+		// Spare the body upload when the id is already known to be taken;
+		// a concurrent upload of the same id is turned away when its row
+		// is inserted.
+		if taken, err := s.documentIDTaken(r, t.id); err != nil {
+			log.Printf("check document %s: %v", t.id, err)
+			httperr.Internal(w, "failed to load document")
+			return
+		} else if taken {
+			httperr.Error(w, "document already exists", http.StatusConflict)
+			return
+		}
+	} else {
+		t.id = uuid.NewString()
+		t.fileName = target
+	}
+
+	var body io.Reader = r.Body
+	if wantMD5 != nil {
+		body = &checksumReader{r: r.Body, digest: md5.New(), want: wantMD5}
+	}
+	stored, ok := s.ingest(w, r, body, t)
+	if !ok {
+		return
+	}
+	w.Header().Set("Location", "/documents/"+stored.id)
+	respondJSON(w, http.StatusAccepted, stored.accepted())
+}
+
+// documentIDTaken reports whether id belongs to an existing or erased
+// document, whoever owns it.
+func (s *Server) documentIDTaken(r *http.Request, id string) (bool, error) {
+	_, err := s.repo.Get(r.Context(), id)
+	if err == nil {
+		return true, nil
+	}
+	if !errors.Is(err, repository.ErrNotFound) {
+		return false, err
+	}
+	return s.repo.IsErased(r.Context(), id)
+}
+
+// parseContentMD5 decodes a Content-MD5 header (RFC 1864: the base64 of the
+// digest). An empty header yields nil.
+func parseContentMD5(header string) ([]byte, error) {
+	if header == "" {
+		return nil, nil
+	}
+	sum, err := base64.StdEncoding.DecodeString(header)
+	if err != nil || len(sum) != md5.Size {
+		return nil, errors.New("invalid Content-MD5")
+	}
+	return sum, nil
+}
+
+// checksumReader fails at the end of the body instead of returning io.EOF
+// when the digest does not match, so storage aborts the upload like any other
+// read error.
+type checksumReader struct {
+	r      io.Reader
+	digest hash.Hash
+	want   []byte
+}
+
+func (c *checksumReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.digest.Write(p[:n])
+	if errors.Is(err, io.EOF) && !bytes.Equal(c.digest.Sum(nil), c.want) {
+		return n, errChecksumMismatch
+	}
+	return n, err
+}
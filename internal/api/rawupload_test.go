@@ -0,0 +1,41 @@
+package api
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestContentMD5(t *testing.T) {
+	body := "%PDF-1.4 raw upload"
+	sum := md5.Sum([]byte(body))
+	header := base64.StdEncoding.EncodeToString(sum[:])
+
+	want, err := parseContentMD5(header)
+	if err != nil {
+		t.Fatalf("parseContentMD5(%q): %v", header, err)
+	}
+	if got, _ := parseContentMD5(""); got != nil {
+		t.Errorf("empty header = %x, want nil", got)
+	}
+	for _, bad := range []string{"not base64!", base64.StdEncoding.EncodeToString([]byte("short"))} {
+		if _, err := parseContentMD5(bad); err == nil {
+			t.Errorf("parseContentMD5(%q) accepted", bad)
+		}
+	}
+
+	r := &checksumReader{r: strings.NewReader(body), digest: md5.New(), want: want}
+	if data, err := io.ReadAll(r); err != nil || string(data) != body {
+		t.Errorf("matching body: %q, %v", data, err)
+	}
+	r = &checksumReader{r: strings.NewReader(body + "!"), digest: md5.New(), want: want}
+	if _, err := io.ReadAll(r); !errors.Is(err, errChecksumMismatch) {
+		t.Errorf("altered body error = %v, want errChecksumMismatch", err)
+	}
+	if rejectionReason(errChecksumMismatch) != "checksum_mismatch" {
+		t.Errorf("rejectionReason(errChecksumMismatch) = %q", rejectionReason(errChecksumMismatch))
+	}
+}
@@ -0,0 +1,121 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/httperr"
+	"github.com/dharsanguruparan/VaultDrop/internal/repository"
+)
+
+const (
+	defaultRejectionWindow = 24 * time.Hour
+	maxRejectionWindow     = repository.RejectionRetention
+	defaultRecentRejects   = 50
+	maxRecentRejects       = 500
+	maxUserAgentLength     = 256
+)
+
+// rejectUpload answers a refused upload with 400 (413 for oversize files,
+// 415 for unsupported types), with the reason in the error details, and
+// records it for the rejection report. Recording is best effort.
+func (s *Server) rejectUpload(w http.ResponseWriter, r *http.Request, reason, detail string, size int64, contentType string) {
+	status := http.StatusBadRequest
+	switch reason {
+	case repository.RejectTooLarge:
+		status = http.StatusRequestEntityTooLarge
+	case repository.RejectUnsupportedType:
+		status = http.StatusUnsupportedMediaType
+	}
+	httperr.Write(w, status, httperr.CodeFor(status), detail, map[string]string{"reason": reason})
+
+	if size < 0 {
+		size = 0
+	}
+	rej := &repository.Rejection{
+		Reason:      reason,
+		Detail:      detail,
+		ContentType: contentType,
+		SizeBytes:   size,
+		ClientIP:    clientIP(r),
+		UserAgent:   truncate(r.UserAgent(), maxUserAgentLength),
+	}
+	if principal := principalFrom(r.Context()); principal != nil {
+		rej.Principal = principal.ID
+	}
+	// The request context may already be cancelled when the client gave up
+	// mid-upload, which is exactly the case worth recording.
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := s.repo.RecordRejection(ctx, rej); err != nil {
+		log.Printf("record rejection: %v", err)
+	}
+}
+
+// rejectionReason classifies errors from reading the upload body.
+func rejectionReason(err error) string {
+	var maxBytes *http.MaxBytesError
+	switch {
+	case errors.Is(err, errFileTooLarge), errors.As(err, &maxBytes):
+		return repository.RejectTooLarge
+	case errors.Is(err, errEmptyFile):
+		return repository.RejectEmpty
+	case errors.Is(err, errChecksumMismatch):
+		return repository.RejectChecksum
+	default:
+		return repository.RejectMalformed
+	}
+}
+
+// handleRejectionReport serves GET /admin/rejections?window=24h&recent=50.
+func (s *Server) handleRejectionReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httperr.MethodNotAllowed(w)
+		return
+	}
+	window := defaultRejectionWindow
+	if raw := r.URL.Query().Get("window"); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d <= 0 || d > maxRejectionWindow {
+			httperr.BadRequest(w, "invalid window")
+			return
+		}
+		window = d
+	}
+	recent := defaultRecentRejects
+	if raw := r.URL.Query().Get("recent"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 || n > maxRecentRejects {
+			httperr.BadRequest(w, "invalid recent")
+			return
+		}
+		recent = n
+	}
+	report, err := s.repo.RejectionReport(r.Context(), time.Now().UTC().Add(-window), recent)
+	if err != nil {
+		log.Printf("rejection report: %v", err)
+		httperr.Internal(w, "failed to build report")
+		return
+	}
+	respondJSON(w, http.StatusOK, report)
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
@@ -0,0 +1,80 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/httperr"
+	"github.com/dharsanguruparan/VaultDrop/internal/queue"
+	"github.com/dharsanguruparan/VaultDrop/internal/repository"
+)
+
+type reprocessBody struct {
+	Pipeline string `json:"pipeline"`
+}
+
+// handleReprocessDocument serves POST /documents/{id}/reprocess: a completed,
+// failed or cancelled document goes back to queued and its extraction runs
+// again from the raw upload, optionally with another pipeline
+// ({"pipeline": "sandbox"}). The current text stays readable until the new
+// run completes, and a changed result is recorded as a new version.
+func (s *Server) handleReprocessDocument(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodPost {
+		httperr.MethodNotAllowed(w)
+		return
+	}
+	var body reprocessBody
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&body); err != nil && !errors.Is(err, io.EOF) {
+		httperr.BadRequest(w, "invalid json body")
+		return
+	}
+	if !queue.KnownPipeline(body.Pipeline) {
+		httperr.BadRequest(w, "unknown pipeline; available: "+strings.Join(queue.Pipelines, ", "))
+		return
+	}
+	doc, ok := s.lookupDocument(w, r, id)
+	if !ok {
+		return
+	}
+	err := s.repo.MarkQueuedForReprocess(r.Context(), id, body.Pipeline)
+	switch {
+	case err == nil:
+	case errors.Is(err, repository.ErrNotReprocessable):
+		httperr.Error(w, err.Error(), http.StatusConflict)
+		return
+	case errors.Is(err, repository.ErrRawPurged):
+		httperr.Write(w, http.StatusGone, httperr.CodeRawExpired, err.Error(), nil)
+		return
+	case errors.Is(err, repository.ErrNotFound):
+		httperr.Error(w, "document not found", http.StatusNotFound)
+		return
+	default:
+		log.Printf("reprocess document %s: %v", id, err)
+		httperr.Internal(w, "failed to reprocess document")
+		return
+	}
+	// Extract tasks are unique per document. A task left over from a
+	// cancelled run may still hold the lock; it then runs the extraction
+	// instead, and reads the new pipeline from the row like this one would.
+	_, err = queue.EnqueueExtract(r.Context(), s.queue, queue.ExtractPayload{DocumentID: doc.ID})
+	if err != nil {
+		log.Printf("reprocess %s: %v", id, err)
+		// Put the document back the way maintenance.Requeue does, so it is
+		// not left queued without a task.
+		if err := s.repo.MarkFailed(context.WithoutCancel(r.Context()), id, "reprocess: "+err.Error()); err != nil {
+			log.Printf("reprocess %s: restore failed status: %v", id, err)
+		}
+		httperr.Internal(w, "failed to queue job")
+		return
+	}
+	pipeline := body.Pipeline
+	if pipeline == "" {
+		pipeline = queue.PipelineText
+	}
+	respondJSON(w, http.StatusAccepted, map[string]string{"id": id, "status": string(repository.StatusQueued), "pipeline": pipeline})
+}
@@ -0,0 +1,154 @@
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/config"
+	"github.com/dharsanguruparan/VaultDrop/internal/httperr"
+	"github.com/dharsanguruparan/VaultDrop/internal/queue"
+	"github.com/dharsanguruparan/VaultDrop/internal/repository"
+)
+
+// maxS3EventBody bounds one notification delivery; MinIO sends one record
+// per request, AWS-style batches stay well below this.
+const maxS3EventBody = 1 << 20
+
+// s3Event is the S3 event notification format MinIO's webhook target posts.
+type s3Event struct {
+	Records []struct {
+		EventName string `json:"eventName"`
+		S3        struct {
+			Bucket struct {
+				Name string `json:"name"`
+			} `json:"bucket"`
+			Object struct {
+				Key  string `json:"key"`
+				Size int64  `json:"size"`
+			} `json:"object"`
+		} `json:"s3"`
+	} `json:"Records"`
+}
+
+// s3Object is a newly created raw-bucket object that should become a
+// document.
+type s3Object struct {
+	key  string
+	size int64
+}
+
+// handleS3Events serves POST /ingest/s3-events, the target of a MinIO webhook
+// notification on the raw bucket. Objects that external systems write there
+// directly get a document row and an extract task, as if they had been
+// uploaded. Deliveries are idempotent: an object that already has a document
+// is only re-enqueued if it is still queued, so MinIO's retries are safe.
+func (s *Server) handleS3Events(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		httperr.MethodNotAllowed(w)
+		return
+	}
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(s.cfg.S3EventsToken)) != 1 {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="vaultdrop"`)
+		httperr.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	var event s3Event
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxS3EventBody)).Decode(&event); err != nil {
+		httperr.BadRequest(w, "invalid json body")
+		return
+	}
+	ingested, skipped := 0, 0
+	for _, obj := range objectsToIngest(s.cfg, &event) {
+		created, err := s.ingestObject(r, obj)
+		if err != nil {
+			// A 5xx makes MinIO redeliver the event; objects handled
+			// before the failure are recognised by key then.
+			log.Printf("ingest s3 object %s: %v", obj.key, err)
+			httperr.Internal(w, "failed to ingest object")
+			return
+		}
+		if created {
+			ingested++
+		} else {
+			skipped++
+		}
+	}
+	respondJSON(w, http.StatusOK, map[string]int{"ingested": ingested, "skipped": skipped})
+}
+
+// objectsToIngest picks the object-created records for the raw bucket that
+// fall under the configured prefix. The API's own uploads/ keys are left out:
+// their documents exist before the object does.
+func objectsToIngest(cfg *config.Config, event *s3Event) []s3Object {
+	var objects []s3Object
+	for _, rec := range event.Records {
+		// MinIO prefixes event names with "s3:", AWS does not.
+		if !strings.HasPrefix(strings.TrimPrefix(rec.EventName, "s3:"), "ObjectCreated:") {
+			continue
+		}
+		if rec.S3.Bucket.Name != cfg.RawBucket {
+			continue
+		}
+		// Keys arrive URL-encoded, with spaces as '+'.
+		key, err := url.QueryUnescape(rec.S3.Object.Key)
+		if err != nil || key == "" || strings.HasSuffix(key, "/") {
+			continue
+		}
+		if strings.HasPrefix(key, "uploads/") || !strings.HasPrefix(key, cfg.S3EventsPrefix) {
+			continue
+		}
+		if rec.S3.Object.Size <= 0 || rec.S3.Object.Size > cfg.MaxFileSize {
+			log.Printf("s3 event: skipping %s: size %d outside 1..%d bytes", key, rec.S3.Object.Size, cfg.MaxFileSize)
+			continue
+		}
+		objects = append(objects, s3Object{key: key, size: rec.S3.Object.Size})
+	}
+	return objects
+}
+
+// ingestObject creates the document for obj and enqueues its extraction. It
+// reports false when the object already had a document.
+func (s *Server) ingestObject(r *http.Request, obj s3Object) (bool, error) {
+	ctx := r.Context()
+	doc := &repository.Document{
+		ID:        uuid.NewString(),
+		FileName:  path.Base(obj.key),
+		ObjectKey: obj.key,
+		OwnerID:   s.cfg.S3EventsOwner,
+	}
+	created := true
+	if err := s.repo.Create(ctx, doc); err != nil {
+		if !errors.Is(err, repository.ErrExists) {
+			return false, err
+		}
+		existing, err := s.repo.GetByObjectKey(ctx, obj.key)
+		if err != nil {
+			return false, err
+		}
+		// A previous delivery may have stored the row but failed to
+		// enqueue; extract tasks are unique per document, so enqueueing
+		// again is harmless when the first attempt did succeed. Deferred
+		// documents are left to their scheduled task.
+		if existing.Status != repository.StatusQueued || existing.ProcessAt != nil {
+			return false, nil
+		}
+		doc, created = existing, false
+	}
+	if created {
+		s.emitCreated(ctx, doc)
+	}
+	payload := queue.ExtractPayload{DocumentID: doc.ID}
+	if _, err := queue.EnqueueExtract(ctx, s.queue, payload); err != nil {
+		return false, err
+	}
+	return created, nil
+}
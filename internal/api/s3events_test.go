@@ -0,0 +1,48 @@
+package api
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/config"
+)
+
+func TestObjectsToIngest(t *testing.T) {
+	cfg := &config.Config{RawBucket: "vaultdrop-raw", S3EventsPrefix: "incoming/", MaxFileSize: 1 << 20}
+	record := func(name, bucket, key string, size int64) map[string]interface{} {
+		return map[string]interface{}{
+			"eventName": name,
+			"s3": map[string]interface{}{
+				"bucket": map[string]interface{}{"name": bucket},
+				"object": map[string]interface{}{"key": key, "size": size},
+			},
+		}
+	}
+	data, err := json.Marshal(map[string]interface{}{"Records": []interface{}{
+		record("s3:ObjectCreated:Put", "vaultdrop-raw", "incoming/scan+2024.pdf", 100),
+		record("ObjectCreated:CompleteMultipartUpload", "vaultdrop-raw", "incoming/big%281%29.pdf", 2048),
+		record("s3:ObjectRemoved:Delete", "vaultdrop-raw", "incoming/gone.pdf", 100),
+		record("s3:ObjectCreated:Put", "other-bucket", "incoming/a.pdf", 100),
+		record("s3:ObjectCreated:Put", "vaultdrop-raw", "uploads/x/a.pdf", 100),
+		record("s3:ObjectCreated:Put", "vaultdrop-raw", "elsewhere/a.pdf", 100),
+		record("s3:ObjectCreated:Put", "vaultdrop-raw", "incoming/", 0),
+		record("s3:ObjectCreated:Put", "vaultdrop-raw", "incoming/huge.pdf", 2<<20),
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var event s3Event
+	if err := json.Unmarshal(data, &event); err != nil {
+		t.Fatal(err)
+	}
+	got := objectsToIngest(cfg, &event)
+	want := []s3Object{{key: "incoming/scan 2024.pdf", size: 100}, {key: "incoming/big(1).pdf", size: 2048}}
+	if len(got) != len(want) {
+		t.Fatalf("objectsToIngest = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("object %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
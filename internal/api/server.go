@@ -2,15 +2,19 @@ package api
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"log"
+	"math"
 	"mime/multipart"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -19,66 +23,182 @@ import (
 	"github.com/hibiken/asynq"
 
 	"github.com/dharsanguruparan/VaultDrop/internal/config"
+	"github.com/dharsanguruparan/VaultDrop/internal/events"
+	"github.com/dharsanguruparan/VaultDrop/internal/httperr"
+	"github.com/dharsanguruparan/VaultDrop/internal/idempotency"
+	"github.com/dharsanguruparan/VaultDrop/internal/keys"
+	"github.com/dharsanguruparan/VaultDrop/internal/metadata"
+	"github.com/dharsanguruparan/VaultDrop/internal/notify"
+	"github.com/dharsanguruparan/VaultDrop/internal/progress"
 	"github.com/dharsanguruparan/VaultDrop/internal/queue"
 	"github.com/dharsanguruparan/VaultDrop/internal/repository"
 	"github.com/dharsanguruparan/VaultDrop/internal/s3storage"
+	"github.com/dharsanguruparan/VaultDrop/internal/signing"
+	"github.com/dharsanguruparan/VaultDrop/internal/tlsserve"
 )
 
 // Server exposes HTTP endpoints for uploads and document visibility.
 type Server struct {
-	cfg    *config.Config
-	repo   *repository.DocumentRepository
-	store  *s3storage.Storage
-	queue  *asynq.Client
-	server *http.Server
-	once   sync.Once
+	cfg  *config.Config
+	repo *repository.DocumentRepository
+	// meta is repo seen through the status summary both stacks share.
+	meta metadata.Store
+	// access is repo's access counts, for analytics.
+	access   accessStats
+	store    *s3storage.Storage
+	queue    *asynq.Client
+	hub      *notify.Hub
+	progress *progress.Tracker
+	signer   *signing.Signer
+	server   *http.Server
+	once     sync.Once
+	// fetcher downloads documents for POST /documents/from-url.
+	fetcher *http.Client
+	// acceptTypes are the allowed media types workers can extract.
+	acceptTypes []string
+	// requests counts responses per minute for /admin/diagnostics.
+	requests requestStats
+	// collectors write the /metrics document; they are registered before
+	// Run, so reads need no lock.
+	collectors []func(io.Writer)
+	// events receives document.created for every new document.
+	events events.Publisher
+	// idempotency remembers uploads' Idempotency-Key headers; nil ignores
+	// them.
+	idempotency *idempotency.Store
+	// keys wraps the data keys raw uploads are encrypted with; nil stores
+	// them unencrypted.
+	keys *keys.Keyring
 }
 
 // New constructs a Server.
-func New(cfg *config.Config, repo *repository.DocumentRepository, store *s3storage.Storage, queueClient *asynq.Client) *Server {
+func New(cfg *config.Config, repo *repository.DocumentRepository, store *s3storage.Storage, queueClient *asynq.Client, hub *notify.Hub, tracker *progress.Tracker) *Server {
 	return &Server{
-		cfg:   cfg,
-		repo:  repo,
-		store: store,
-		queue: queueClient,
+		cfg:         cfg,
+		repo:        repo,
+		meta:        metadata.FromRepository(repo),
+		access:      repo,
+		store:       store,
+		queue:       queueClient,
+		hub:         hub,
+		progress:    tracker,
+		signer:      signing.NewSigner(cfg.SigningSecret),
+		fetcher:     newURLFetcher(cfg),
+		acceptTypes: acceptedTypes(cfg.AllowedTypes),
+		events:      events.Nop{},
 	}
 }
 
+// UseEvents publishes a document.created event to pub for each document the
+// API creates. Call it before Run.
+func (s *Server) UseEvents(pub events.Publisher) {
+	s.events = pub
+}
+
+// emitCreated reports a new document to the events publisher.
+func (s *Server) emitCreated(ctx context.Context, doc *repository.Document) {
+	e := events.NewEvent(events.DocumentCreated, doc.ID)
+	e.FileName = doc.FileName
+	e.OwnerID = doc.OwnerID
+	events.Emit(context.WithoutCancel(ctx), s.events, e)
+}
+
 // Run starts the HTTP server and blocks until the context is cancelled.
 func (s *Server) Run(ctx context.Context) error {
+	var initErr error
 	s.once.Do(func() {
+		validator, err := newRequestValidator(openAPISpec)
+		if err != nil {
+			initErr = err
+			return
+		}
 		mux := http.NewServeMux()
 		mux.HandleFunc("/healthz", s.handleHealth)
-		mux.HandleFunc("/documents", s.handleDocuments)
-		mux.HandleFunc("/documents/", s.handleDocumentRoute)
-		s.server = &http.Server{
-			Addr:    s.cfg.Address,
-			Handler: loggingMiddleware(mux),
+		mux.HandleFunc("/openapi.json", s.handleOpenAPI)
+		mux.HandleFunc("/docs", s.handleDocs)
+		if s.cfg.WebUI {
+			mux.HandleFunc("/", s.handleUIIndex)
+			mux.HandleFunc("/ui/", s.handleUIAsset)
+		}
+		mux.HandleFunc("/documents", s.requireAuth(s.handleDocuments))
+		mux.HandleFunc("/documents/", s.requireAuth(s.handleDocumentRoute))
+		mux.HandleFunc("/documents/from-url", s.requireAuth(s.handleIngestURL))
+		mux.HandleFunc("/documents/changes", s.requireAuth(s.handleDocumentChanges))
+		mux.HandleFunc("/erasure-requests", s.requireFullAccess(s.handleErasureRequests))
+		mux.HandleFunc("/erasure-requests/", s.requireFullAccess(s.handleErasureRequest))
+		mux.HandleFunc("/exports", s.requireFullAccess(s.handleExports))
+		mux.HandleFunc("/exports/", s.requireFullAccess(s.handleExport))
+		mux.HandleFunc("/drops", s.requireFullAccess(s.handleDrops))
+		mux.HandleFunc("/drops/", s.requireFullAccess(s.handleDropInfo))
+		mux.HandleFunc("/tokens", s.requireFullAccess(s.handleTokens))
+		mux.HandleFunc("/drop/", s.handleDropUpload)
+		mux.HandleFunc("/upload-urls", s.requireAuth(s.handleUploadURLs))
+		mux.HandleFunc("/signed-uploads/", s.handleSignedUpload)
+		mux.HandleFunc("/admin/rejections", s.requireAdmin(s.handleRejectionReport))
+		mux.HandleFunc("/admin/diagnostics", s.requireAdmin(s.handleDiagnostics))
+		mux.HandleFunc("/metrics", s.requireAdmin(s.handleMetrics))
+		mux.HandleFunc("/admin/queue-stats", s.requireAdmin(s.handleQueueStats))
+		mux.HandleFunc("/admin/counts", s.requireAdmin(s.handleDocumentCounts))
+		mux.HandleFunc("/admin/summary", s.requireAdmin(metadata.CountsHandler(s.meta)))
+		mux.HandleFunc("/admin/failed", s.requireAdmin(s.handleFailures))
+		mux.HandleFunc("/admin/requeue", s.requireAdmin(s.handleRequeue))
+		mux.HandleFunc("/admin/purge", s.requireAdmin(s.handlePurge))
+		mux.HandleFunc("/admin/erasure-requests", s.requireAdmin(s.handleOwnerErasure))
+		if s.cfg.S3EventsToken != "" {
+			mux.HandleFunc("/ingest/s3-events", s.handleS3Events)
 		}
+		s.server = s.cfg.HTTPServer(s.cfg.Address,
+			httperr.RequestID(loggingMiddleware(s.timingMiddleware(s.readOnlyMiddleware(validator.middleware(mux))))))
 	})
+	if initErr != nil {
+		return initErr
+	}
 	go func() {
 		<-ctx.Done()
 		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
 		_ = s.server.Shutdown(shutdownCtx)
 	}()
-	log.Printf("api listening on %s", s.cfg.Address)
-	if err := s.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+	if s.cfg.ReadOnly {
+		log.Printf("api running in read-only mode")
+	}
+	scheme := "http"
+	if s.cfg.TLSEnabled() {
+		scheme = "https"
+	}
+	log.Printf("api listening on %s (%s)", s.cfg.Address, scheme)
+	if err := tlsserve.ListenAndServe(ctx, s.server, s.cfg); err != nil && !errors.Is(err, http.ErrServerClosed) {
 		return err
 	}
 	return nil
 }
 
 func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
-	respondJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	mode := "read-write"
+	if s.cfg.ReadOnly {
+		mode = "read-only"
+	}
+	respondJSON(w, http.StatusOK, map[string]string{"status": "ok", "mode": mode})
 }
 
 func (s *Server) handleDocuments(w http.ResponseWriter, r *http.Request) {
+	principal := principalFrom(r.Context())
 	switch r.Method {
+	case http.MethodGet:
+		// Grants cover single documents or uploads, never listing.
+		if principal.Grant != nil {
+			httperr.Forbidden(w, "scoped tokens cannot list documents")
+			return
+		}
+		s.handleListDocuments(w, r)
 	case http.MethodPost:
+		if !principal.can(actionUpload, "") {
+			httperr.Forbidden(w, "token does not grant "+actionUpload)
+			return
+		}
 		s.handleUpload(w, r)
 	default:
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		httperr.MethodNotAllowed(w)
 	}
 }
 
@@ -86,10 +206,24 @@ func (s *Server) handleDocumentRoute(w http.ResponseWriter, r *http.Request) {
 	path := strings.TrimPrefix(r.URL.Path, "/documents/")
 	parts := strings.Split(path, "/")
 	if len(parts) == 0 || parts[0] == "" {
-		http.NotFound(w, r)
+		httperr.NotFound(w, r)
 		return
 	}
 	id := parts[0]
+	principal := principalFrom(r.Context())
+	if r.Method == http.MethodPut && len(parts) == 1 {
+		if !principal.can(actionUpload, "") {
+			httperr.Forbidden(w, "token does not grant "+actionUpload)
+			return
+		}
+		s.handleRawUpload(w, r, id)
+		return
+	}
+	// Scoped tokens may only read; every other method needs a full key.
+	if (r.Method != http.MethodGet && r.Method != http.MethodHead && principal.Grant != nil) || !principal.can(actionRead, id) {
+		httperr.Forbidden(w, "token does not grant access to this document")
+		return
+	}
 	if len(parts) == 1 {
 		s.handleDocument(w, r, id)
 		return
@@ -97,137 +231,402 @@ func (s *Server) handleDocumentRoute(w http.ResponseWriter, r *http.Request) {
 	switch parts[1] {
 	case "text":
 		s.handleDocumentText(w, r, id)
+	case "preview":
+		s.handleDocumentPreview(w, r, id)
+	case "chunks":
+		s.handleDocumentChunks(w, r, id)
 	case "processed-url":
 		s.handleProcessedURL(w, r, id)
+	case "raw-url":
+		s.handleRawURL(w, r, id)
+	case "raw":
+		s.handleRawDownload(w, r, id)
+	case "analytics":
+		s.handleDocumentAnalytics(w, r, id)
+	case "events":
+		s.handleDocumentEvents(w, r, id)
+	case "versions":
+		s.handleDocumentVersions(w, r, id, parts[2:])
+	case "attempts":
+		s.handleDocumentAttempts(w, r, id)
+	case "progress":
+		s.handleDocumentProgress(w, r, id)
+	case "cancel":
+		s.handleCancelDocument(w, r, id)
+	case "reprocess":
+		s.handleReprocessDocument(w, r, id)
 	default:
-		http.NotFound(w, r)
+		httperr.NotFound(w, r)
 	}
 }
 
 func (s *Server) handleDocument(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method == http.MethodPatch {
+		s.handleUpdateDocument(w, r, id)
+		return
+	}
 	if r.Method != http.MethodGet {
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		httperr.MethodNotAllowed(w)
 		return
 	}
-	doc, err := s.repo.Get(r.Context(), id)
-	if err != nil {
-		http.Error(w, "document not found", http.StatusNotFound)
+	doc, ok := s.lookupDocument(w, r, id)
+	if !ok {
 		return
 	}
-	respondJSON(w, http.StatusOK, doc)
+	if doc, ok = s.unarchiveDocument(w, r, doc); !ok {
+		return
+	}
+	respondCachedJSON(w, r, s.withAnalytics(r.Context(), doc), doc)
 }
 
 func (s *Server) handleDocumentText(w http.ResponseWriter, r *http.Request, id string) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		httperr.MethodNotAllowed(w)
 		return
 	}
-	doc, err := s.repo.Get(r.Context(), id)
-	if err != nil {
-		http.Error(w, "document not found", http.StatusNotFound)
+	doc, ok := s.lookupDocument(w, r, id)
+	if !ok {
+		return
+	}
+	if doc.TextPurgedAt != nil {
+		httperr.Write(w, http.StatusGone, httperr.CodeTextExpired, "extracted text expired under the retention policy", nil)
+		return
+	}
+	if doc, ok = s.unarchiveDocument(w, r, doc); !ok {
 		return
 	}
 	if doc.Status != repository.StatusCompleted || doc.Content == "" {
-		http.Error(w, "document not processed", http.StatusAccepted)
+		httperr.Error(w, "document not processed", http.StatusAccepted)
+		return
+	}
+	if layout, _ := strconv.ParseBool(r.URL.Query().Get("layout")); layout {
+		if doc, ok = s.layoutDocument(w, r, doc); !ok {
+			return
+		}
+	}
+	w.Header().Set("Vary", "Accept")
+	format := textFormat(r.URL.Query().Get("format"), r.Header.Get("Accept"))
+	if format == "" {
+		status := http.StatusNotAcceptable
+		if r.URL.Query().Get("format") != "" {
+			status = http.StatusBadRequest
+		}
+		httperr.Error(w, "supported formats: text (text/plain), json (application/json), markdown (text/markdown)", status)
 		return
 	}
-	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-	w.WriteHeader(http.StatusOK)
-	io.WriteString(w, doc.Content)
+	req, err := parseTextRequest(r.URL.Query())
+	if err != nil {
+		httperr.BadRequest(w, err.Error())
+		return
+	}
+	if req.sliced {
+		// Byte ranges cut through pages, so only plain text makes sense.
+		if format != textFormatPlain {
+			httperr.BadRequest(w, "offset/length slices are plain text; use page with other formats")
+			return
+		}
+		if req.offset > len(doc.Content) {
+			httperr.BadRequest(w, fmt.Sprintf("offset beyond the end of the text (%d bytes)", len(doc.Content)))
+			return
+		}
+		text, end := sliceText(doc.Content, req.offset, req.length)
+		start := end - len(text)
+		w.Header().Set("X-Text-Length", strconv.Itoa(len(doc.Content)))
+		setTextLinks(w, r, sliceLinks(start, end, req.length, len(doc.Content)))
+		respondCached(w, r, doc.UpdatedAt, textFormatTypes[format], []byte(text))
+		return
+	}
+	if req.page > 0 {
+		count := len(splitPages(doc.Content))
+		if req.page > count {
+			httperr.BadRequest(w, fmt.Sprintf("page out of range; the text has %d page(s)", count))
+			return
+		}
+		w.Header().Set("X-Page-Count", strconv.Itoa(count))
+		setTextLinks(w, r, pageLinks(req.page, count))
+	}
+	body, err := renderText(doc, format, req.page)
+	if err != nil {
+		log.Printf("render text %s: %v", id, err)
+		httperr.Internal(w, "failed to render text")
+		return
+	}
+	respondCached(w, r, doc.UpdatedAt, textFormatTypes[format], body)
+}
+
+// lookupDocument loads a document the caller owns and writes the error
+// response when it is missing. Other principals' documents answer 404 like
+// unknown ids. Erased documents answer 410 so clients can tell them apart from
+// ids that never existed.
+func (s *Server) lookupDocument(w http.ResponseWriter, r *http.Request, id string) (*repository.Document, bool) {
+	doc, err := s.repo.GetForOwner(r.Context(), id, s.ownerScope(r))
+	if err == nil {
+		return doc, true
+	}
+	if !errors.Is(err, repository.ErrNotFound) {
+		log.Printf("get document %s: %v", id, err)
+		httperr.Internal(w, "failed to load document")
+		return nil, false
+	}
+	if erased, _ := s.repo.IsErased(r.Context(), id); erased {
+		httperr.Write(w, http.StatusGone, httperr.CodeErased, "document erased", nil)
+		return nil, false
+	}
+	httperr.Error(w, "document not found", http.StatusNotFound)
+	return nil, false
 }
 
 func (s *Server) handleProcessedURL(w http.ResponseWriter, r *http.Request, id string) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		httperr.MethodNotAllowed(w)
 		return
 	}
-	doc, err := s.repo.Get(r.Context(), id)
-	if err != nil {
-		http.Error(w, "document not found", http.StatusNotFound)
+	doc, ok := s.lookupDocument(w, r, id)
+	if !ok {
+		return
+	}
+	if doc.TextPurgedAt != nil {
+		httperr.Write(w, http.StatusGone, httperr.CodeTextExpired, "extracted text expired under the retention policy", nil)
 		return
 	}
 	if doc.ProcessedKey == nil {
-		http.Error(w, "processed artifact unavailable", http.StatusNotFound)
+		httperr.Error(w, "processed artifact unavailable", http.StatusNotFound)
 		return
 	}
 	url, err := s.store.PresignProcessedURL(r.Context(), *doc.ProcessedKey, int64(s.cfg.SignedURLTTL.Seconds()))
 	if err != nil {
-		http.Error(w, "failed to generate url", http.StatusInternalServerError)
+		s.storageError(w, err, "failed to generate url")
 		return
 	}
+	s.recordAccess(r.Context(), doc.ID, repository.AccessSignedURL)
 	respondJSON(w, http.StatusOK, map[string]string{"url": url})
 }
 
-func (s *Server) handleUpload(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
-	r.Body = http.MaxBytesReader(w, r.Body, s.cfg.MaxFileSize+1024)
-	mr, err := r.MultipartReader()
-	if err != nil {
-		http.Error(w, "expecting multipart form", http.StatusBadRequest)
+// handleRawURL serves GET /documents/{id}/raw-url with a presigned download
+// of the original upload. It expires after VAULTDROP_SIGNED_TTL, or sooner
+// when ?ttl= asks for it.
+func (s *Server) handleRawURL(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodGet {
+		httperr.MethodNotAllowed(w)
 		return
 	}
-	part, err := nextFilePart(mr)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	ttl := s.cfg.SignedURLTTL
+	if raw := r.URL.Query().Get("ttl"); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d <= 0 {
+			httperr.BadRequest(w, "invalid ttl")
+			return
+		}
+		if d < ttl {
+			ttl = d
+		}
+	}
+	doc, ok := s.lookupDocument(w, r, id)
+	if !ok {
 		return
 	}
-	defer part.Close()
-	tmp, err := s.persistTemp(part)
+	if doc.RawPurgedAt != nil {
+		httperr.Write(w, http.StatusGone, httperr.CodeRawExpired, "raw upload expired under the retention policy", nil)
+		return
+	}
+	if encrypted, err := s.repo.DataKey(r.Context(), doc.ID); err != nil {
+		log.Printf("load data key for %s: %v", doc.ID, err)
+		httperr.Internal(w, "failed to generate url")
+		return
+	} else if encrypted != nil {
+		httperr.Write(w, http.StatusConflict, httperr.CodeEncrypted, "the raw upload is encrypted and cannot be presigned; download it from /documents/"+doc.ID+"/raw", nil)
+		return
+	}
+	url, err := s.store.PresignRawURL(r.Context(), doc.ObjectKey, doc.FileName, ttl)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		s.storageError(w, err, "failed to generate url")
 		return
+	}
+	s.recordAccess(r.Context(), doc.ID, repository.AccessSignedURL)
+	respondJSON(w, http.StatusOK, map[string]interface{}{"url": url, "expiresAt": time.Now().Add(ttl).UTC()})
 }
-	defer os.Remove(tmp.path)
-	defer tmp.f.Close()
-	if tmp.contentType != "application/pdf" {
-		http.Error(w, "only PDF files supported", http.StatusBadRequest)
+
+// maxProcessDelay bounds how far ahead an upload may defer its extraction.
+const maxProcessDelay = 7 * 24 * time.Hour
+
+// handleUpload serves POST /documents. An optional ?processAt= (RFC 3339)
+// defers extraction, so batches can be uploaded during the day and extracted
+// overnight.
+func (s *Server) handleUpload(w http.ResponseWriter, r *http.Request) {
+	processAt, err := parseProcessAt(r)
+	if err != nil {
+		httperr.BadRequest(w, err.Error())
 		return
 	}
-	docID := uuid.NewString()
-	objectKey := fmt.Sprintf("uploads/%s/%s", docID, filepath.Base(tmp.filename))
-	if err := s.uploadToStorage(ctx, objectKey, tmp); err != nil {
-		log.Printf("upload to storage failed: %v", err)
-		http.Error(w, "failed to store file", http.StatusInternalServerError)
+	idem, ok := s.claimIdempotencyKey(w, r)
+	if !ok {
 		return
 	}
-	doc := &repository.Document{
-		ID:        docID,
-		FileName:  tmp.filename,
-		ObjectKey: objectKey,
-	}
-	if err := s.repo.Create(ctx, doc); err != nil {
-		http.Error(w, "failed to store metadata", http.StatusInternalServerError)
+	stored, ok := s.ingestUpload(w, r, uuid.NewString(), principalFrom(r.Context()).ID, nil, processAt)
+	if !ok {
+		s.releaseIdempotencyKey(r.Context(), idem)
 		return
 	}
-	payload := queue.ExtractPayload{
-		DocumentID: docID,
-		ObjectKey:  objectKey,
-		FileName:   tmp.filename,
+	body := stored.accepted()
+	s.completeIdempotencyKey(r.Context(), idem, http.StatusAccepted, body)
+	respondJSON(w, http.StatusAccepted, body)
+}
+
+// parseProcessAt reads the optional ?processAt= of an upload. Past times
+// yield nil and run right away like an undeferred upload.
+func parseProcessAt(r *http.Request) (*time.Time, error) {
+	raw := r.URL.Query().Get("processAt")
+	if raw == "" {
+		return nil, nil
+	}
+	at, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return nil, errors.New("invalid processAt")
+	}
+	if time.Until(at) > maxProcessDelay {
+		return nil, fmt.Errorf("processAt is more than %s ahead", maxProcessDelay)
 	}
-	if err := queue.EnqueueExtract(ctx, s.queue, payload); err != nil {
-		http.Error(w, "failed to queue job", http.StatusInternalServerError)
-		return
+	if !at.After(time.Now()) {
+		return nil, nil
 	}
-	respondJSON(w, http.StatusAccepted, map[string]string{
-		"id":     docID,
-		"status": string(repository.StatusQueued),
+	at = at.UTC()
+	return &at, nil
+}
+
+// uploadTarget describes the document an upload becomes.
+type uploadTarget struct {
+	id string
+	// clientID is set when the caller chose id, so two uploads may race
+	// for it.
+	clientID  bool
+	fileName  string
+	owner     string
+	dropID    *string
+	processAt *time.Time
+	metadata  map[string]string
+	// size is the announced length of the upload, or -1 when unknown.
+	size int64
+}
+
+// ingestUpload stores the multipart file part, inserts the document row id
+// owned by owner, and enqueues extraction, held back until processAt when it
+// is not nil. On failure it writes the error response and returns false.
+func (s *Server) ingestUpload(w http.ResponseWriter, r *http.Request, id, owner string, dropID *string, processAt *time.Time) (*storedUpload, bool) {
+	r.Body = http.MaxBytesReader(w, r.Body, s.cfg.MaxFileSize+1024)
+	mr, err := r.MultipartReader()
+	if err != nil {
+		s.rejectUpload(w, r, repository.RejectMalformed, "expecting multipart form", 0, "")
+		return nil, false
+	}
+	metadata := map[string]string{}
+	part, err := nextFilePart(mr, metadata)
+	if err != nil {
+		s.rejectUpload(w, r, rejectionReason(err), err.Error(), 0, "")
+		return nil, false
+	}
+	defer part.Close()
+	return s.ingest(w, r, part, uploadTarget{
+		id:        id,
+		fileName:  part.FileName(),
+		size:      r.ContentLength,
+		owner:     owner,
+		dropID:    dropID,
+		processAt: processAt,
+		metadata:  metadata,
 	})
 }
 
+// ingest stores body as the raw upload of the document t describes, inserts
+// its row, and enqueues extraction. On failure it writes the error response
+// and returns false.
+func (s *Server) ingest(w http.ResponseWriter, r *http.Request, body io.Reader, t uploadTarget) (*storedUpload, bool) {
+	ctx := r.Context()
+	filename := t.fileName
+	if filename == "" {
+		filename = "upload.pdf"
+	}
+	objectKey := fmt.Sprintf("uploads/%s/%s", t.id, filepath.Base(filename))
+	if t.clientID {
+		// Each upload racing for the id stores its own object, so the one
+		// whose row loses cannot overwrite the winner's file.
+		objectKey = fmt.Sprintf("uploads/%s/%s/%s", t.id, uuid.NewString(), filepath.Base(filename))
+	}
+	dataKey, wrapped, err := s.newDataKey(t.id)
+	if err != nil {
+		log.Printf("data key for %s: %v", t.id, err)
+		httperr.Internal(w, "failed to store file")
+		return nil, false
+	}
+	stored, err := s.storeUpload(ctx, body, objectKey, t.size, dataKey)
+	var rejected *uploadRejection
+	if errors.As(err, &rejected) {
+		s.rejectUpload(w, r, rejected.reason, rejected.detail, rejected.size, rejected.contentType)
+		return nil, false
+	}
+	if err != nil {
+		log.Printf("upload to storage failed: %v", err)
+		s.storageError(w, err, "failed to store file")
+		return nil, false
+	}
+	doc := &repository.Document{
+		ID:        t.id,
+		FileName:  filename,
+		ObjectKey: objectKey,
+		DropID:    t.dropID,
+		OwnerID:   t.owner,
+		ProcessAt: t.processAt,
+		Metadata:  t.metadata,
+		SHA256:    stored.sha256,
+		DataKey:   wrapped,
+	}
+	if err := s.repo.Create(ctx, doc); err != nil {
+		if errors.Is(err, repository.ErrExists) {
+			// Another upload took the id; the object is this one's alone.
+			if err := s.store.RemoveRaw(context.WithoutCancel(ctx), objectKey); err != nil {
+				log.Printf("remove upload %s of a document not created: %v", objectKey, err)
+			}
+			httperr.Error(w, "document already exists", http.StatusConflict)
+			return nil, false
+		}
+		httperr.Internal(w, "failed to store metadata")
+		return nil, false
+	}
+	s.emitCreated(ctx, doc)
+	payload := queue.ExtractPayload{DocumentID: t.id}
+	var at time.Time
+	if t.processAt != nil {
+		at = *t.processAt
+	}
+	// A new document id cannot have a task yet, so duplicates do not occur.
+	if _, err := queue.ScheduleExtract(ctx, s.queue, payload, at); err != nil {
+		httperr.Internal(w, "failed to queue job")
+		return nil, false
+	}
+	stored.id = t.id
+	stored.processAt = t.processAt
+	return stored, true
+}
+
+var (
+	errFileTooLarge = errors.New("file exceeds limit")
+	errEmptyFile    = errors.New("empty file")
+)
+
 type tempUpload struct {
 	f           *os.File
 	path        string
 	size        int64
 	contentType string
-	filename    string
+	sha256      string
 }
 
-func (s *Server) persistTemp(part *multipart.Part) (*tempUpload, error) {
+func (s *Server) persistTemp(part io.Reader) (*tempUpload, error) {
 	tmpFile, err := os.CreateTemp("", "vaultdrop-*.pdf")
 	if err != nil {
 		return nil, fmt.Errorf("create temp file: %w", err)
 	}
 	var sniff []byte
+	digest := sha256.New()
 	buf := make([]byte, 32*1024)
 	var written int64
 	for {
@@ -237,7 +636,7 @@ func (s *Server) persistTemp(part *multipart.Part) (*tempUpload, error) {
 			if written > s.cfg.MaxFileSize {
 				tmpFile.Close()
 				os.Remove(tmpFile.Name())
-				return nil, fmt.Errorf("file exceeds limit (%d bytes)", s.cfg.MaxFileSize)
+				return nil, fmt.Errorf("%w (%d bytes)", errFileTooLarge, s.cfg.MaxFileSize)
 			}
 			if len(sniff) < 512 {
 				chunk := n
@@ -246,6 +645,7 @@ func (s *Server) persistTemp(part *multipart.Part) (*tempUpload, error) {
 				}
 				sniff = append(sniff, buf[:chunk]...)
 			}
+			digest.Write(buf[:n])
 			if _, err := tmpFile.Write(buf[:n]); err != nil {
 				tmpFile.Close()
 				os.Remove(tmpFile.Name())
@@ -264,36 +664,35 @@ func (s *Server) persistTemp(part *multipart.Part) (*tempUpload, error) {
 	if written == 0 {
 		tmpFile.Close()
 		os.Remove(tmpFile.Name())
-		return nil, errors.New("empty file")
+		return nil, errEmptyFile
 	}
 	contentType := http.DetectContentType(sniff)
 	if _, err := tmpFile.Seek(0, 0); err != nil {
 		return nil, fmt.Errorf("rewind temp file: %w", err)
 	}
-	filename := part.FileName()
-	if filename == "" {
-		filename = "upload.pdf"
-	}
 	return &tempUpload{
 		f:           tmpFile,
 		path:        tmpFile.Name(),
 		size:        written,
 		contentType: contentType,
-		filename:    filename,
+		sha256:      hex.EncodeToString(digest.Sum(nil)),
 	}, nil
 }
 
-func (s *Server) uploadToStorage(ctx context.Context, objectKey string, tmp *tempUpload) error {
+func (s *Server) uploadToStorage(ctx context.Context, objectKey string, tmp *tempUpload, dataKey []byte) error {
 	if _, err := tmp.f.Seek(0, 0); err != nil {
 		return err
 	}
-	if err := s.store.UploadRaw(ctx, objectKey, tmp.f, tmp.size, tmp.contentType); err != nil {
+	if err := s.store.UploadRaw(ctx, objectKey, tmp.f, tmp.size, tmp.contentType, tmp.sha256, dataKey); err != nil {
 		return err
 	}
 	return nil
 }
 
-func nextFilePart(mr *multipart.Reader) (*multipart.Part, error) {
+// nextFilePart returns the "file" part, adding the metadata fields that
+// precede it to meta. The file is streamed, so fields sent after it are not
+// read.
+func nextFilePart(mr *multipart.Reader, meta map[string]string) (*multipart.Part, error) {
 	for {
 		part, err := mr.NextPart()
 		if err != nil {
@@ -302,8 +701,23 @@ func nextFilePart(mr *multipart.Reader) (*multipart.Part, error) {
 		if part.FormName() == "file" {
 			return part, nil
 		}
+		err = addMetadataPart(meta, part)
 		part.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// storageError answers a failed object-store call: 503 while the storage
+// circuit breaker is open, so clients back off, and 500 with msg otherwise.
+func (s *Server) storageError(w http.ResponseWriter, err error, msg string) {
+	if errors.Is(err, s3storage.ErrUnavailable) {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(s.cfg.S3BreakerCooldown.Seconds()))))
+		httperr.Write(w, http.StatusServiceUnavailable, httperr.CodeStorageUnavailable, "storage unavailable", nil)
+		return
 	}
+	httperr.Internal(w, msg)
 }
 
 func respondJSON(w http.ResponseWriter, status int, payload interface{}) {
@@ -314,10 +728,26 @@ func respondJSON(w http.ResponseWriter, status int, payload interface{}) {
 	}
 }
 
+// readOnlyMiddleware answers every mutating request with 503 when the server
+// runs in read-only mode.
+func (s *Server) readOnlyMiddleware(next http.Handler) http.Handler {
+	if !s.cfg.ReadOnly {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(w, r)
+		default:
+			httperr.Write(w, http.StatusServiceUnavailable, httperr.CodeReadOnly, "server is in read-only mode", nil)
+		}
+	})
+}
+
 func loggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
 		next.ServeHTTP(w, r)
-		log.Printf("%s %s (%s)", r.Method, r.URL.Path, time.Since(start))
+		log.Printf("%s %s (%s) request=%s", r.Method, r.URL.Path, time.Since(start), httperr.RequestIDFrom(r.Context()))
 	})
 }
@@ -0,0 +1,164 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/httperr"
+	"github.com/dharsanguruparan/VaultDrop/internal/repository"
+	"github.com/dharsanguruparan/VaultDrop/internal/signing"
+)
+
+const (
+	defaultUploadURLTTL = 15 * time.Minute
+	maxUploadURLTTL     = 24 * time.Hour
+)
+
+type uploadURLBody struct {
+	FileName    string `json:"fileName"`
+	TTL         string `json:"ttl"`
+	MaxSize     int64  `json:"maxSize"`
+	ContentType string `json:"contentType"`
+}
+
+// handleUploadURLs serves POST /upload-urls: it mints a signed PUT URL that
+// lets a client without credentials upload one file, e.g. a browser handed
+// the URL by a backend. The URL names the document id, so it works once; the
+// document belongs to the caller. {"ttl": "15m", "maxSize": bytes,
+// "contentType": "application/pdf", "fileName": "..."} are optional.
+func (s *Server) handleUploadURLs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		httperr.MethodNotAllowed(w)
+		return
+	}
+	principal := principalFrom(r.Context())
+	if !principal.can(actionUpload, "") {
+		httperr.Forbidden(w, "token does not grant "+actionUpload)
+		return
+	}
+	var body uploadURLBody
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&body); err != nil && !errors.Is(err, io.EOF) {
+		httperr.BadRequest(w, "invalid json body")
+		return
+	}
+	ttl := defaultUploadURLTTL
+	if body.TTL != "" {
+		d, err := time.ParseDuration(body.TTL)
+		if err != nil || d <= 0 || d > maxUploadURLTTL {
+			httperr.BadRequest(w, fmt.Sprintf("ttl must be a duration up to %s", maxUploadURLTTL))
+			return
+		}
+		ttl = d
+	}
+	maxSize := body.MaxSize
+	if maxSize == 0 {
+		maxSize = s.cfg.MaxFileSize
+	}
+	if maxSize < 0 || maxSize > s.cfg.MaxFileSize {
+		httperr.BadRequest(w, fmt.Sprintf("maxSize must be between 1 and %d", s.cfg.MaxFileSize))
+		return
+	}
+	var contentType string
+	if body.ContentType != "" {
+		mediaType, _, err := mime.ParseMediaType(body.ContentType)
+		if err != nil || !s.accepts(mediaType) {
+			httperr.BadRequest(w, s.unsupportedTypeDetail(body.ContentType))
+			return
+		}
+		contentType = mediaType
+	}
+	fileName := strings.TrimSpace(body.FileName)
+	if fileName != "" {
+		if err := checkFileName(fileName); err != nil {
+			httperr.BadRequest(w, err.Error())
+			return
+		}
+	}
+
+	id := uuid.NewString()
+	expires := time.Now().Add(ttl).Truncate(time.Second)
+	claims := uploadClaims(id, principal.ID, expires.Unix(), contentType, maxSize)
+	q := url.Values{
+		"expires": {strconv.FormatInt(expires.Unix(), 10)},
+		"maxSize": {strconv.FormatInt(maxSize, 10)},
+		"sig":     {s.signer.SignRequest(claims)},
+	}
+	if principal.ID != "" {
+		q.Set("owner", principal.ID)
+	}
+	if contentType != "" {
+		q.Set("contentType", contentType)
+	}
+	if fileName != "" {
+		// Not signed: the uploader may pick another name with ?filename=.
+		q.Set("filename", fileName)
+	}
+	respondJSON(w, http.StatusCreated, map[string]interface{}{
+		"id":        id,
+		"url":       "/signed-uploads/" + id + "?" + q.Encode(),
+		"method":    http.MethodPut,
+		"expiresAt": expires.UTC(),
+		"maxSize":   maxSize,
+	})
+}
+
+// handleSignedUpload serves PUT /signed-uploads/{id}, the target of a URL
+// from POST /upload-urls. The signature stands in for credentials; the body
+// is handled like PUT /documents/{id}.
+func (s *Server) handleSignedUpload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		httperr.MethodNotAllowed(w)
+		return
+	}
+	id := strings.TrimPrefix(r.URL.Path, "/signed-uploads/")
+	q := r.URL.Query()
+	expires, err := strconv.ParseInt(q.Get("expires"), 10, 64)
+	if err != nil {
+		httperr.Forbidden(w, "invalid upload signature")
+		return
+	}
+	maxSize, err := strconv.ParseInt(q.Get("maxSize"), 10, 64)
+	if err != nil {
+		httperr.Forbidden(w, "invalid upload signature")
+		return
+	}
+	claims := uploadClaims(id, q.Get("owner"), expires, q.Get("contentType"), maxSize)
+	if !s.signer.ValidateRequest(claims, q.Get("sig")) {
+		httperr.Forbidden(w, "invalid upload signature")
+		return
+	}
+	if time.Now().Unix() > expires {
+		httperr.Forbidden(w, "upload url expired")
+		return
+	}
+	if claims.ContentType != "" {
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if mediaType != claims.ContentType {
+			s.rejectUpload(w, r, repository.RejectUnsupportedType, "this url only accepts "+claims.ContentType, r.ContentLength, r.Header.Get("Content-Type"))
+			return
+		}
+	}
+	s.rawUpload(w, r, id, claims.Owner, maxSize)
+}
+
+// uploadClaims binds a signed URL to PUT /signed-uploads/{id}.
+func uploadClaims(id, owner string, expires int64, contentType string, maxSize int64) signing.Claims {
+	return signing.Claims{
+		Method:      http.MethodPut,
+		Path:        "/signed-uploads/" + id,
+		Owner:       owner,
+		Expires:     expires,
+		ContentType: contentType,
+		MaxSize:     maxSize,
+	}
+}
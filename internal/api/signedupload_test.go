@@ -0,0 +1,79 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/config"
+	"github.com/dharsanguruparan/VaultDrop/internal/signing"
+)
+
+func TestSignedUploadURL(t *testing.T) {
+	s := &Server{cfg: &config.Config{MaxFileSize: 10 << 20}, signer: signing.NewSigner([]byte("secret")), acceptTypes: []string{"application/pdf", "text/plain"}}
+	req := httptest.NewRequest(http.MethodPost, "/upload-urls", strings.NewReader(`{"ttl": "5m", "maxSize": 1024, "contentType": "application/pdf", "fileName": "scan.pdf"}`))
+	req = req.WithContext(context.WithValue(req.Context(), principalContextKey{}, &Principal{ID: "alice"}))
+	rec := httptest.NewRecorder()
+	s.handleUploadURLs(rec, req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("issue: %d %s", rec.Code, rec.Body)
+	}
+	var issued struct {
+		ID      string `json:"id"`
+		URL     string `json:"url"`
+		MaxSize int64  `json:"maxSize"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &issued); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(issued.URL, "/signed-uploads/"+issued.ID+"?") || issued.MaxSize != 1024 {
+		t.Fatalf("issued %+v", issued)
+	}
+
+	put := func(target string, size int64) int {
+		req := httptest.NewRequest(http.MethodPut, target, strings.NewReader("%PDF-1.4"))
+		req.Header.Set("Content-Type", "application/pdf")
+		req.ContentLength = size
+		rec := httptest.NewRecorder()
+		s.handleSignedUpload(rec, req)
+		return rec.Code
+	}
+	// Tampering with any signed value is refused before the body is read.
+	for _, tampered := range []string{
+		strings.Replace(issued.URL, "maxSize=1024", "maxSize=4096", 1),
+		strings.Replace(issued.URL, "owner=alice", "owner=bob", 1),
+		strings.Replace(issued.URL, "contentType=application%2Fpdf", "contentType=text%2Fplain", 1),
+		strings.Replace(issued.URL, "sig=v2.", "sig=", 1),
+		strings.Replace(issued.URL, issued.ID, "00000000-0000-0000-0000-000000000000", 1),
+	} {
+		if code := put(tampered, 10); code != http.StatusForbidden {
+			t.Errorf("tampered %s: %d, want 403", tampered, code)
+		}
+	}
+	// A valid signature reaches the upload checks; stop at the first one,
+	// since later ones need storage.
+	if code := put(issued.URL, -1); code != http.StatusLengthRequired {
+		t.Errorf("valid signature without Content-Length: %d, want 411", code)
+	}
+
+	expired := s.signer.SignRequest(uploadClaims(issued.ID, "", 1, "", 1024))
+	if code := put("/signed-uploads/"+issued.ID+"?expires=1&maxSize=1024&sig="+expired, 10); code != http.StatusForbidden {
+		t.Errorf("expired url: %d, want 403", code)
+	}
+}
+
+func TestUploadURLLimits(t *testing.T) {
+	s := &Server{cfg: &config.Config{MaxFileSize: 1024}, signer: signing.NewSigner([]byte("secret"))}
+	for _, body := range []string{`{"contentType": "image/png"}`, `{"ttl": "48h"}`, `{"ttl": "-1m"}`, `{"maxSize": 2048}`, `{"fileName": "a/b.pdf"}`} {
+		req := httptest.NewRequest(http.MethodPost, "/upload-urls", strings.NewReader(body))
+		req = req.WithContext(context.WithValue(req.Context(), principalContextKey{}, &Principal{}))
+		rec := httptest.NewRecorder()
+		s.handleUploadURLs(rec, req)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: %d, want 400", body, rec.Code)
+		}
+	}
+}
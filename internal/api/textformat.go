@@ -0,0 +1,162 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+
+	pdfutil "github.com/dharsanguruparan/VaultDrop/internal/pdf"
+	"github.com/dharsanguruparan/VaultDrop/internal/repository"
+)
+
+// Formats GET /documents/{id}/text can render.
+const (
+	textFormatPlain    = "text"
+	textFormatJSON     = "json"
+	textFormatMarkdown = "markdown"
+)
+
+var textFormatTypes = map[string]string{
+	textFormatPlain:    "text/plain; charset=utf-8",
+	textFormatJSON:     "application/json",
+	textFormatMarkdown: "text/markdown; charset=utf-8",
+}
+
+// textFormat picks the rendering for a text request: ?format= wins, then the
+// most preferred Accept entry we can produce. It returns "" when neither names
+// a format we have.
+func textFormat(format, accept string) string {
+	if format != "" {
+		if _, ok := textFormatTypes[format]; ok {
+			return format
+		}
+		return ""
+	}
+	if strings.TrimSpace(accept) == "" {
+		return textFormatPlain
+	}
+	type choice struct {
+		format string
+		q      float64
+		order  int
+	}
+	var choices []choice
+	for i, entry := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(entry))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if raw, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(raw, 64); err != nil {
+				continue
+			}
+		}
+		if q <= 0 {
+			continue
+		}
+		var f string
+		switch mediaType {
+		case "text/plain", "text/*", "*/*":
+			f = textFormatPlain
+		case "application/json":
+			f = textFormatJSON
+		case "text/markdown":
+			f = textFormatMarkdown
+		default:
+			continue
+		}
+		choices = append(choices, choice{f, q, i})
+	}
+	if len(choices) == 0 {
+		return ""
+	}
+	sort.SliceStable(choices, func(a, b int) bool { return choices[a].q > choices[b].q })
+	return choices[0].format
+}
+
+// textPage is one page of extracted text in the JSON rendering. Offset is the
+// byte offset of the page within the plain text.
+type textPage struct {
+	Page   int    `json:"page"`
+	Offset int    `json:"offset"`
+	Text   string `json:"text"`
+}
+
+// splitPages cuts extracted text at the extractor's page breaks. Text
+// extracted before page breaks were recorded comes back as one page.
+func splitPages(content string) []textPage {
+	parts := strings.Split(content, pdfutil.PageBreak)
+	pages := make([]textPage, len(parts))
+	offset := 0
+	for i, part := range parts {
+		pages[i] = textPage{Page: i + 1, Offset: offset, Text: part}
+		offset += len(part) + len(pdfutil.PageBreak)
+	}
+	return pages
+}
+
+// renderText formats a completed document's text: every page, or only page
+// (counted from 1) when it is not 0. The caller checks page is in range.
+func renderText(doc *repository.Document, format string, page int) ([]byte, error) {
+	pages := splitPages(doc.Content)
+	count := len(pages)
+	if page > 0 {
+		pages = pages[page-1 : page]
+	}
+	switch format {
+	case textFormatJSON:
+		return json.Marshal(map[string]interface{}{
+			"id":        doc.ID,
+			"fileName":  doc.FileName,
+			"pageCount": count,
+			"pages":     pages,
+		})
+	case textFormatMarkdown:
+		return []byte(markdownText(doc.FileName, pages, count > 1)), nil
+	default:
+		if page > 0 {
+			return []byte(pages[0].Text), nil
+		}
+		return []byte(doc.Content), nil
+	}
+}
+
+// markdownText renders the pages under a title, with a section per page when
+// the document has several. Characters that would turn extracted text into
+// markup are escaped.
+func markdownText(title string, pages []textPage, sections bool) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n", escapeMarkdown(title))
+	for _, p := range pages {
+		if sections {
+			fmt.Fprintf(&b, "\n## Page %d\n", p.Page)
+		}
+		for _, line := range strings.Split(strings.TrimRight(p.Text, "\n"), "\n") {
+			if line = strings.TrimRight(line, " \t\r"); line == "" {
+				b.WriteString("\n")
+				continue
+			}
+			b.WriteString("\n")
+			b.WriteString(escapeMarkdown(line))
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", `*`, `\*`, `_`, `\_`, `[`, `\[`, `]`, `\]`, `<`, `\<`,
+)
+
+func escapeMarkdown(line string) string {
+	line = markdownEscaper.Replace(line)
+	// Block markers only count at the start of a line.
+	if trimmed := strings.TrimLeft(line, " "); trimmed != "" && strings.ContainsRune("#>-+|", rune(trimmed[0])) {
+		line = line[:len(line)-len(trimmed)] + `\` + trimmed
+	}
+	return line
+}
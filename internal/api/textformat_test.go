@@ -0,0 +1,64 @@
+package api
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/repository"
+)
+
+func TestTextFormat(t *testing.T) {
+	for _, tc := range []struct {
+		format, accept, want string
+	}{
+		{"", "", textFormatPlain},
+		{"json", "text/plain", textFormatJSON},
+		{"yaml", "", ""},
+		{"", "application/json", textFormatJSON},
+		{"", "text/markdown, text/plain;q=0.5", textFormatMarkdown},
+		{"", "text/plain;q=0.2, application/json;q=0.9", textFormatJSON},
+		{"", "text/html, */*;q=0.1", textFormatPlain},
+		{"", "image/png", ""},
+		{"", "application/json;q=0", ""},
+	} {
+		if got := textFormat(tc.format, tc.accept); got != tc.want {
+			t.Errorf("textFormat(%q, %q) = %q, want %q", tc.format, tc.accept, got, tc.want)
+		}
+	}
+}
+
+func TestRenderTextPages(t *testing.T) {
+	doc := &repository.Document{ID: "d1", FileName: "report_v2.pdf", Content: "Intro *bold*\n\fSecond page\n# not a heading\n"}
+	raw, err := renderText(doc, textFormatJSON, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got struct {
+		PageCount int        `json:"pageCount"`
+		Pages     []textPage `json:"pages"`
+	}
+	if err := json.Unmarshal(raw, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.PageCount != 2 || got.Pages[1].Offset != len("Intro *bold*\n\f") || !strings.HasPrefix(got.Pages[1].Text, "Second page") {
+		t.Errorf("json rendering = %s", raw)
+	}
+	if doc.Content[got.Pages[1].Offset:][:6] != "Second" {
+		t.Errorf("offset %d does not point at page 2", got.Pages[1].Offset)
+	}
+
+	md, err := renderText(doc, textFormatMarkdown, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "# report\\_v2.pdf\n\n## Page 1\n\nIntro \\*bold\\*\n\n## Page 2\n\nSecond page\n\\# not a heading\n"
+	if string(md) != want {
+		t.Errorf("markdown = %q, want %q", md, want)
+	}
+
+	// Text stored before page breaks were recorded is a single page.
+	if pages := splitPages("no breaks"); len(pages) != 1 || pages[0].Text != "no breaks" {
+		t.Errorf("splitPages = %+v", pages)
+	}
+}
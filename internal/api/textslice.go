@@ -0,0 +1,144 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// defaultTextSliceLen and maxTextSliceLen bound ?offset=&length= slices
+	// of the extracted text, in bytes.
+	defaultTextSliceLen = 256 << 10
+	maxTextSliceLen     = 4 << 20
+)
+
+// textRequest is how much of the text a GET /documents/{id}/text asks for:
+// one page, a byte range, or everything when both are zero.
+type textRequest struct {
+	page   int
+	offset int
+	length int
+	sliced bool
+}
+
+// parseTextRequest reads ?page= or ?offset=&length=.
+func parseTextRequest(q url.Values) (textRequest, error) {
+	var req textRequest
+	if q.Has("page") && (q.Has("offset") || q.Has("length")) {
+		return req, errors.New("use either page or offset/length")
+	}
+	if q.Has("page") {
+		n, err := strconv.Atoi(q.Get("page"))
+		if err != nil || n < 1 {
+			return req, errors.New("invalid page")
+		}
+		req.page = n
+		return req, nil
+	}
+	if !q.Has("offset") && !q.Has("length") {
+		return req, nil
+	}
+	req.sliced = true
+	req.length = defaultTextSliceLen
+	if raw := q.Get("offset"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			return req, errors.New("invalid offset")
+		}
+		req.offset = n
+	}
+	if raw := q.Get("length"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxTextSliceLen {
+			return req, fmt.Errorf("length must be between 1 and %d", maxTextSliceLen)
+		}
+		req.length = n
+	}
+	return req, nil
+}
+
+// sliceText returns the bytes of content from offset for up to length bytes,
+// with both ends moved back to the start of a UTF-8 sequence so no character
+// is split, and the end offset to continue from.
+func sliceText(content string, offset, length int) (string, int) {
+	start := runeStart(content, offset)
+	end := len(content)
+	if start+length < end {
+		end = runeStart(content, start+length)
+		if end == start {
+			// length is shorter than the character at start.
+			_, size := utf8.DecodeRuneInString(content[start:])
+			end = start + size
+		}
+	}
+	return content[start:end], end
+}
+
+func runeStart(s string, i int) int {
+	if i >= len(s) {
+		return len(s)
+	}
+	for i > 0 && !utf8.RuneStart(s[i]) {
+		i--
+	}
+	return i
+}
+
+// setTextLinks adds RFC 8288 Link headers pointing at the neighbouring pages
+// or slices of a text response, keeping the request's other parameters.
+func setTextLinks(w http.ResponseWriter, r *http.Request, rels map[string]url.Values) {
+	var links []string
+	for _, rel := range []string{"first", "prev", "next", "last"} {
+		params, ok := rels[rel]
+		if !ok {
+			continue
+		}
+		q := r.URL.Query()
+		for key, values := range params {
+			q[key] = values
+		}
+		u := url.URL{Path: r.URL.Path, RawQuery: q.Encode()}
+		links = append(links, fmt.Sprintf(`<%s>; rel="%s"`, u.String(), rel))
+	}
+	if len(links) > 0 {
+		w.Header().Set("Link", strings.Join(links, ", "))
+	}
+}
+
+// pageLinks are the Link targets around page of count pages.
+func pageLinks(page, count int) map[string]url.Values {
+	at := func(n int) url.Values { return url.Values{"page": {strconv.Itoa(n)}} }
+	rels := map[string]url.Values{"first": at(1), "last": at(count)}
+	if page > 1 {
+		rels["prev"] = at(page - 1)
+	}
+	if page < count {
+		rels["next"] = at(page + 1)
+	}
+	return rels
+}
+
+// sliceLinks are the Link targets around a slice that started at start and
+// ended at end of total bytes.
+func sliceLinks(start, end, length, total int) map[string]url.Values {
+	at := func(offset int) url.Values {
+		return url.Values{"offset": {strconv.Itoa(offset)}, "length": {strconv.Itoa(length)}}
+	}
+	rels := map[string]url.Values{}
+	if start > 0 {
+		prev := start - length
+		if prev < 0 {
+			prev = 0
+		}
+		rels["prev"] = at(prev)
+	}
+	if end < total {
+		rels["next"] = at(end)
+	}
+	return rels
+}
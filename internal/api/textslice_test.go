@@ -0,0 +1,77 @@
+package api
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestParseTextRequest(t *testing.T) {
+	for raw, want := range map[string]textRequest{
+		"":                     {},
+		"page=3":               {page: 3},
+		"offset=10":            {offset: 10, length: defaultTextSliceLen, sliced: true},
+		"offset=5&length=100":  {offset: 5, length: 100, sliced: true},
+		"length=100&format=md": {length: 100, sliced: true},
+	} {
+		q, _ := url.ParseQuery(raw)
+		got, err := parseTextRequest(q)
+		if err != nil || got != want {
+			t.Errorf("parseTextRequest(%q) = %+v, %v; want %+v", raw, got, err, want)
+		}
+	}
+	for _, raw := range []string{"page=0", "page=x", "page=1&offset=0", "offset=-1", "length=0", "length=99999999"} {
+		q, _ := url.ParseQuery(raw)
+		if _, err := parseTextRequest(q); err == nil {
+			t.Errorf("parseTextRequest(%q) accepted", raw)
+		}
+	}
+}
+
+func TestSliceText(t *testing.T) {
+	content := "abcé€z" // é and € are 2 and 3 bytes
+	for _, tc := range []struct {
+		offset, length int
+		want           string
+		end            int
+	}{
+		{0, 3, "abc", 3},
+		{0, 4, "abc", 3}, // would split é
+		{4, 3, "é", 5},   // offset inside é moves back to its start
+		{3, 1, "é", 5},   // length shorter than one character
+		{5, 100, "€z", len(content)},
+		{len(content), 5, "", len(content)},
+	} {
+		got, end := sliceText(content, tc.offset, tc.length)
+		if got != tc.want || end != tc.end {
+			t.Errorf("sliceText(%d, %d) = %q, %d; want %q, %d", tc.offset, tc.length, got, end, tc.want, tc.end)
+		}
+	}
+}
+
+func TestTextLinks(t *testing.T) {
+	r := httptest.NewRequest("GET", "/documents/d1/text?page=2&format=json", nil)
+	w := httptest.NewRecorder()
+	setTextLinks(w, r, pageLinks(2, 3))
+	link := w.Header().Get("Link")
+	for _, want := range []string{
+		`</documents/d1/text?format=json&page=1>; rel="first"`,
+		`</documents/d1/text?format=json&page=1>; rel="prev"`,
+		`</documents/d1/text?format=json&page=3>; rel="next"`,
+		`</documents/d1/text?format=json&page=3>; rel="last"`,
+	} {
+		if !strings.Contains(link, want) {
+			t.Errorf("Link = %s, missing %s", link, want)
+		}
+	}
+
+	rels := sliceLinks(0, 100, 100, 250)
+	if _, ok := rels["prev"]; ok || rels["next"].Get("offset") != "100" {
+		t.Errorf("first slice links = %v", rels)
+	}
+	rels = sliceLinks(200, 250, 100, 250)
+	if _, ok := rels["next"]; ok || rels["prev"].Get("offset") != "100" {
+		t.Errorf("last slice links = %v", rels)
+	}
+}
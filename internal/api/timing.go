@@ -0,0 +1,67 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/timing"
+)
+
+// timingMiddleware attaches a timing.Recorder to every request and reports it
+// in a Server-Timing header: db and s3 time recorded by the pool tracer and
+// storage transport, scan time for upload inspection, the total so far and,
+// when the request has a deadline, how much of it was left. The header has
+// to be set before the status line goes out, so work done while streaming a
+// body is not included. The response status also feeds the request counters
+// behind /admin/diagnostics.
+func (s *Server) timingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := timing.NewRecorder()
+		r = r.WithContext(timing.NewContext(r.Context(), rec))
+		tw := &timingWriter{ResponseWriter: w, r: r, rec: rec}
+		next.ServeHTTP(tw, r)
+		if tw.status == 0 {
+			tw.status = http.StatusOK
+		}
+		s.requests.record(time.Now(), tw.status)
+	})
+}
+
+type timingWriter struct {
+	http.ResponseWriter
+	r           *http.Request
+	rec         *timing.Recorder
+	wroteHeader bool
+	status      int
+}
+
+func (w *timingWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		w.status = status
+		w.Header().Set("Server-Timing", w.rec.Header(w.r.Context()))
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *timingWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush keeps the event stream working through the wrapper.
+func (w *timingWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *timingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,73 @@
+package api
+
+import (
+	"embed"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/httperr"
+)
+
+// uiFiles is the browser UI served at /: a single page that uploads PDFs,
+// follows their status and shows the extracted text, using only the public
+// API.
+//
+//go:embed ui
+var uiFiles embed.FS
+
+// uiContentTypes maps the asset extensions shipped in ui/.
+var uiContentTypes = map[string]string{
+	".html": "text/html; charset=utf-8",
+	".js":   "text/javascript; charset=utf-8",
+	".css":  "text/css; charset=utf-8",
+}
+
+// uiSecurityPolicy keeps the page to its own scripts and API, so a document
+// name or extracted text can never run as script.
+const uiSecurityPolicy = "default-src 'self'; img-src 'self' data:; base-uri 'none'; form-action 'self'; frame-ancestors 'none'"
+
+// handleUIIndex serves the UI at /. Registered on "/", it also receives
+// every path no other route matches, which stay 404.
+func (s *Server) handleUIIndex(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		httperr.NotFound(w, r)
+		return
+	}
+	s.serveUIFile(w, r, "index.html")
+}
+
+// handleUIAsset serves the page's scripts and styles under /ui/.
+func (s *Server) handleUIAsset(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/ui/")
+	if name == "" || strings.Contains(name, "/") || name == "index.html" {
+		httperr.NotFound(w, r)
+		return
+	}
+	s.serveUIFile(w, r, name)
+}
+
+func (s *Server) serveUIFile(w http.ResponseWriter, r *http.Request, name string) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		httperr.MethodNotAllowed(w)
+		return
+	}
+	contentType, ok := uiContentTypes[path.Ext(name)]
+	if !ok {
+		httperr.NotFound(w, r)
+		return
+	}
+	body, err := uiFiles.ReadFile("ui/" + name)
+	if err != nil {
+		httperr.NotFound(w, r)
+		return
+	}
+	h := w.Header()
+	h.Set("Content-Security-Policy", uiSecurityPolicy)
+	h.Set("X-Content-Type-Options", "nosniff")
+	h.Set("Referrer-Policy", "no-referrer")
+	// Embedded files carry no modification time; the ETag alone lets
+	// browsers revalidate after an upgrade.
+	respondCached(w, r, time.Time{}, contentType, body)
+}
@@ -0,0 +1,324 @@
+// VaultDrop web UI: upload PDFs, follow their processing and read the
+// extracted text. It only uses the public JSON API, authenticating with the
+// API key the user enters, which is kept for the browser session.
+"use strict";
+
+const terminal = new Set(["completed", "failed", "cancelled"]);
+const pageSize = 20;
+
+const $ = (id) => document.getElementById(id);
+const rows = new Map();
+const watching = new Set();
+let apiKey = sessionStorage.getItem("vaultdrop.apiKey") || "";
+let nextCursor = "";
+let viewing = null;
+
+class Unauthorized extends Error {}
+
+function headers(extra) {
+  const h = new Headers(extra);
+  if (apiKey) {
+    h.set("Authorization", "Bearer " + apiKey);
+  }
+  return h;
+}
+
+async function api(path, options = {}) {
+  const res = await fetch(path, { ...options, headers: headers(options.headers) });
+  if (res.status === 401) {
+    askForKey();
+    throw new Unauthorized("enter an API key to continue");
+  }
+  if (!res.ok) {
+    throw new Error(errorMessage(res.headers.get("Content-Type"), await res.text(), res.statusText));
+  }
+  return res;
+}
+
+// errorMessage reads the message out of the API's JSON error envelope.
+function errorMessage(contentType, body, fallback) {
+  if (contentType && contentType.startsWith("application/json")) {
+    try {
+      return JSON.parse(body).message || fallback;
+    } catch (err) {
+      // Not an error envelope; show the body as is.
+    }
+  }
+  return body.trim() || fallback;
+}
+
+function say(text, isError) {
+  const el = $("message");
+  el.textContent = text;
+  el.classList.toggle("error", Boolean(isError));
+}
+
+function fail(err) {
+  say(err.message, true);
+}
+
+function askForKey() {
+  $("key-form").hidden = false;
+  $("api-key").focus();
+}
+
+// Documents table.
+
+function renderRow(doc) {
+  let tr = rows.get(doc.id);
+  if (!tr) {
+    tr = document.createElement("tr");
+    for (let i = 0; i < 4; i++) {
+      tr.appendChild(document.createElement("td"));
+    }
+    tr.addEventListener("click", () => view(tr.dataset.id));
+    rows.set(doc.id, tr);
+  }
+  tr.dataset.id = doc.id;
+  if (doc.fileName) {
+    tr.dataset.name = doc.fileName;
+    tr.cells[0].textContent = doc.fileName;
+  }
+  if (doc.createdAt) {
+    tr.cells[3].textContent = new Date(doc.createdAt).toLocaleString();
+  }
+  setStatus(doc.id, doc.status);
+  return tr;
+}
+
+function setStatus(id, status) {
+  const tr = rows.get(id);
+  if (!tr || !status) {
+    return;
+  }
+  tr.dataset.status = status;
+  tr.cells[1].textContent = status;
+  tr.cells[1].className = "status-" + status;
+  if (status !== "processing") {
+    tr.cells[2].textContent = "";
+  }
+}
+
+async function loadDocuments(cursor) {
+  const params = new URLSearchParams({ limit: String(pageSize) });
+  if (cursor) {
+    params.set("cursor", cursor);
+  }
+  const page = await (await api("/documents?" + params)).json();
+  const body = $("documents");
+  for (const doc of page.documents) {
+    body.appendChild(renderRow(doc));
+    if (!terminal.has(doc.status)) {
+      watch(doc.id);
+    }
+  }
+  nextCursor = page.nextCursor || "";
+  $("more").hidden = !nextCursor;
+}
+
+// Live status. The events endpoint streams Server-Sent Events; EventSource
+// cannot send the API key, so the stream is read with fetch instead.
+
+async function watch(id) {
+  if (watching.has(id)) {
+    return;
+  }
+  watching.add(id);
+  const timer = setInterval(() => showProgress(id), 1500);
+  try {
+    const res = await api("/documents/" + encodeURIComponent(id) + "/events", {
+      headers: { Accept: "text/event-stream" },
+    });
+    const reader = res.body.pipeThrough(new TextDecoderStream()).getReader();
+    let buffer = "";
+    for (;;) {
+      const { value, done } = await reader.read();
+      if (done) {
+        break;
+      }
+      buffer += value;
+      let end;
+      while ((end = buffer.indexOf("\n\n")) >= 0) {
+        onEvent(buffer.slice(0, end));
+        buffer = buffer.slice(end + 2);
+      }
+    }
+  } catch (err) {
+    if (!(err instanceof Unauthorized)) {
+      console.warn("status stream for " + id + " ended:", err);
+    }
+  } finally {
+    clearInterval(timer);
+    watching.delete(id);
+  }
+  await refresh(id);
+}
+
+function onEvent(block) {
+  const data = block
+    .split("\n")
+    .filter((line) => line.startsWith("data:"))
+    .map((line) => line.slice(5).trim())
+    .join("\n");
+  if (!data) {
+    return;
+  }
+  const update = JSON.parse(data);
+  setStatus(update.id, update.status);
+  if (update.status === "completed" && viewing === update.id) {
+    view(update.id);
+  }
+}
+
+async function showProgress(id) {
+  const tr = rows.get(id);
+  if (!tr || tr.dataset.status !== "processing") {
+    return;
+  }
+  try {
+    const report = await (await api("/documents/" + encodeURIComponent(id) + "/progress")).json();
+    setStatus(id, report.status);
+    if (report.status === "processing" && report.stage) {
+      tr.cells[2].textContent = report.pages
+        ? report.stage + " " + report.page + "/" + report.pages
+        : report.stage;
+    }
+  } catch (err) {
+    // Progress is cosmetic; the status stream reports the outcome.
+  }
+}
+
+async function refresh(id) {
+  try {
+    renderRow(await (await api("/documents/" + encodeURIComponent(id))).json());
+  } catch (err) {
+    fail(err);
+  }
+}
+
+// Uploads. XMLHttpRequest rather than fetch, for upload progress.
+
+function upload(file) {
+  if (!file) {
+    return;
+  }
+  const bar = $("upload-progress");
+  const form = new FormData();
+  form.append("file", file);
+  const xhr = new XMLHttpRequest();
+  xhr.open("POST", "/documents");
+  headers().forEach((value, name) => xhr.setRequestHeader(name, value));
+  xhr.upload.addEventListener("progress", (e) => {
+    if (e.lengthComputable) {
+      bar.value = (100 * e.loaded) / e.total;
+    }
+  });
+  xhr.addEventListener("loadend", () => {
+    bar.hidden = true;
+    if (xhr.status === 401) {
+      askForKey();
+      say("Enter an API key, then drop the file again.", true);
+      return;
+    }
+    if (xhr.status !== 200 && xhr.status !== 202) {
+      say("Upload of " + file.name + " failed: " + errorMessage(xhr.getResponseHeader("Content-Type"), xhr.responseText, "network error"), true);
+      return;
+    }
+    const accepted = JSON.parse(xhr.responseText);
+    say("Uploaded " + file.name + ".");
+    const tr = renderRow({ id: accepted.id, fileName: file.name, status: accepted.status, createdAt: new Date().toISOString() });
+    $("documents").prepend(tr);
+    watch(accepted.id);
+  });
+  bar.value = 0;
+  bar.hidden = false;
+  say("Uploading " + file.name + "…");
+  xhr.send(form);
+}
+
+// Text viewer.
+
+async function view(id) {
+  const tr = rows.get(id);
+  viewing = id;
+  $("viewer").hidden = false;
+  $("viewer-title").textContent = (tr && tr.dataset.name) || id;
+  const text = $("text");
+  const status = tr && tr.dataset.status;
+  if (status && status !== "completed") {
+    text.textContent = status === "failed" || status === "cancelled"
+      ? "This document has no text: processing " + status + "."
+      : "The text appears here when processing completes.";
+    $("download").disabled = true;
+    return;
+  }
+  try {
+    const res = await api("/documents/" + encodeURIComponent(id) + "/text", {
+      headers: { Accept: "text/plain" },
+    });
+    if (res.status === 202) {
+      text.textContent = "The text appears here when processing completes.";
+      $("download").disabled = true;
+      return;
+    }
+    text.textContent = await res.text();
+    $("download").disabled = false;
+  } catch (err) {
+    text.textContent = "";
+    fail(err);
+  }
+}
+
+function download() {
+  const tr = rows.get(viewing);
+  const name = ((tr && tr.dataset.name) || viewing).replace(/\.pdf$/i, "") + ".txt";
+  const url = URL.createObjectURL(new Blob([$("text").textContent], { type: "text/plain" }));
+  const a = document.createElement("a");
+  a.href = url;
+  a.download = name;
+  a.click();
+  URL.revokeObjectURL(url);
+}
+
+// Wiring.
+
+function start() {
+  const zone = $("drop-zone");
+  zone.addEventListener("dragover", (e) => {
+    e.preventDefault();
+    zone.classList.add("over");
+  });
+  zone.addEventListener("dragleave", () => zone.classList.remove("over"));
+  zone.addEventListener("drop", (e) => {
+    e.preventDefault();
+    zone.classList.remove("over");
+    for (const file of e.dataTransfer.files) {
+      upload(file);
+    }
+  });
+  $("file-input").addEventListener("change", (e) => {
+    for (const file of e.target.files) {
+      upload(file);
+    }
+    e.target.value = "";
+  });
+  $("key-form").addEventListener("submit", (e) => {
+    e.preventDefault();
+    apiKey = $("api-key").value.trim();
+    sessionStorage.setItem("vaultdrop.apiKey", apiKey);
+    $("key-form").hidden = true;
+    say("");
+    $("documents").replaceChildren();
+    rows.clear();
+    loadDocuments().catch(fail);
+  });
+  $("more").addEventListener("click", () => loadDocuments(nextCursor).catch(fail));
+  $("download").addEventListener("click", download);
+  $("close-viewer").addEventListener("click", () => {
+    viewing = null;
+    $("viewer").hidden = true;
+  });
+  loadDocuments().catch(fail);
+}
+
+start();
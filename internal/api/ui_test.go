@@ -0,0 +1,42 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestUIRoutes(t *testing.T) {
+	s := &Server{}
+	for _, tc := range []struct {
+		path        string
+		handler     http.HandlerFunc
+		status      int
+		contentType string
+	}{
+		{"/", s.handleUIIndex, http.StatusOK, "text/html"},
+		{"/ui/app.js", s.handleUIAsset, http.StatusOK, "text/javascript"},
+		{"/ui/style.css", s.handleUIAsset, http.StatusOK, "text/css"},
+		{"/nope", s.handleUIIndex, http.StatusNotFound, ""},
+		{"/ui/", s.handleUIAsset, http.StatusNotFound, ""},
+		{"/ui/missing.js", s.handleUIAsset, http.StatusNotFound, ""},
+		{"/ui/../ui.go", s.handleUIAsset, http.StatusNotFound, ""},
+	} {
+		w := httptest.NewRecorder()
+		tc.handler(w, httptest.NewRequest(http.MethodGet, tc.path, nil))
+		if w.Code != tc.status {
+			t.Errorf("%s: status %d, want %d", tc.path, w.Code, tc.status)
+			continue
+		}
+		if tc.status != http.StatusOK {
+			continue
+		}
+		if got := w.Header().Get("Content-Type"); !strings.HasPrefix(got, tc.contentType) {
+			t.Errorf("%s: content type %q", tc.path, got)
+		}
+		if w.Header().Get("Content-Security-Policy") == "" {
+			t.Errorf("%s: no content security policy", tc.path)
+		}
+	}
+}
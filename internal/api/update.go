@@ -0,0 +1,125 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/httperr"
+	"github.com/dharsanguruparan/VaultDrop/internal/repository"
+)
+
+const (
+	maxFileNameLen = 255
+	maxTags        = 32
+)
+
+var errInvalidUpdate = errors.New("invalid update")
+
+type updateDocumentBody struct {
+	FileName *string           `json:"fileName"`
+	Tags     []string          `json:"tags"`
+	Metadata map[string]string `json:"metadata"`
+}
+
+// handleUpdateDocument serves PATCH /documents/{id}: the file name, tags and
+// metadata are the fields clients may change after upload. Tags and metadata
+// replace the stored values, so [] and {} clear them; omitted fields are kept.
+// The object key keeps the original name, only downloads use the new one.
+func (s *Server) handleUpdateDocument(w http.ResponseWriter, r *http.Request, id string) {
+	var body updateDocumentBody
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&body); err != nil {
+		httperr.BadRequest(w, "invalid json body")
+		return
+	}
+	update, err := body.update()
+	if err != nil {
+		httperr.BadRequest(w, err.Error())
+		return
+	}
+	if _, ok := s.lookupDocument(w, r, id); !ok {
+		return
+	}
+	doc, err := s.repo.Update(r.Context(), id, s.ownerScope(r), update)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			httperr.Error(w, "document not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("update document %s: %v", id, err)
+		httperr.Internal(w, "failed to update document")
+		return
+	}
+	respondJSON(w, http.StatusOK, doc)
+}
+
+// update validates the body and turns it into a repository update.
+func (b *updateDocumentBody) update() (repository.DocumentUpdate, error) {
+	var u repository.DocumentUpdate
+	if b.FileName == nil && b.Tags == nil && b.Metadata == nil {
+		return u, fmt.Errorf("%w: nothing to update", errInvalidUpdate)
+	}
+	if b.FileName != nil {
+		name := strings.TrimSpace(*b.FileName)
+		if err := checkFileName(name); err != nil {
+			return u, err
+		}
+		u.FileName = &name
+	}
+	if b.Tags != nil {
+		tags, err := normalizeTags(b.Tags)
+		if err != nil {
+			return u, err
+		}
+		u.Tags = tags
+	}
+	if b.Metadata != nil {
+		if err := checkMetadata(b.Metadata); err != nil {
+			return u, err
+		}
+		u.Metadata = b.Metadata
+	}
+	return u, nil
+}
+
+// checkFileName accepts a bare file name: no path separators or control
+// characters, at most 255 bytes.
+func checkFileName(name string) error {
+	if name == "" || name == "." || name == ".." {
+		return fmt.Errorf("%w: fileName must not be empty", errInvalidUpdate)
+	}
+	if len(name) > maxFileNameLen {
+		return fmt.Errorf("%w: fileName exceeds %d bytes", errInvalidUpdate, maxFileNameLen)
+	}
+	if strings.ContainsAny(name, `/\`) || strings.IndexFunc(name, unicode.IsControl) >= 0 {
+		return fmt.Errorf("%w: fileName must not contain path separators or control characters", errInvalidUpdate)
+	}
+	return nil
+}
+
+// normalizeTags checks tags against the metadata key rules and returns them
+// sorted without duplicates. An empty list stays non-nil so it clears the
+// stored tags.
+func normalizeTags(tags []string) ([]string, error) {
+	seen := make(map[string]bool, len(tags))
+	out := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		if !metadataKey.MatchString(tag) {
+			return nil, fmt.Errorf("%w: bad tag %q", errInvalidUpdate, tag)
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			out = append(out, tag)
+		}
+	}
+	if len(out) > maxTags {
+		return nil, fmt.Errorf("%w: more than %d tags", errInvalidUpdate, maxTags)
+	}
+	sort.Strings(out)
+	return out, nil
+}
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestUpdateDocumentBody(t *testing.T) {
+	var body updateDocumentBody
+	if err := json.Unmarshal([]byte(`{"fileName": " invoice.pdf ", "tags": ["paid", "2024", "paid"], "metadata": {}}`), &body); err != nil {
+		t.Fatal(err)
+	}
+	u, err := body.update()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.FileName == nil || *u.FileName != "invoice.pdf" {
+		t.Errorf("fileName = %v", u.FileName)
+	}
+	if !reflect.DeepEqual(u.Tags, []string{"2024", "paid"}) {
+		t.Errorf("tags = %v", u.Tags)
+	}
+	if u.Metadata == nil || len(u.Metadata) != 0 {
+		t.Errorf("empty metadata object should clear, got %v", u.Metadata)
+	}
+
+	// [] clears the tags; omitted fields stay nil and are left alone.
+	body = updateDocumentBody{}
+	json.Unmarshal([]byte(`{"tags": []}`), &body)
+	if u, err := body.update(); err != nil || u.Tags == nil || len(u.Tags) != 0 || u.FileName != nil || u.Metadata != nil {
+		t.Errorf("clear tags: %+v, %v", u, err)
+	}
+}
+
+func TestUpdateDocumentBodyRejects(t *testing.T) {
+	long := strings.Repeat("a", maxFileNameLen+1)
+	for name, raw := range map[string]string{
+		"empty body":   `{}`,
+		"blank name":   `{"fileName": "  "}`,
+		"path name":    `{"fileName": "../etc/passwd"}`,
+		"control char": `{"fileName": "a\u0000.pdf"}`,
+		"long name":    `{"fileName": "` + long + `"}`,
+		"bad tag":      `{"tags": ["has space"]}`,
+		"bad meta key": `{"metadata": {"a/b": "x"}}`,
+	} {
+		var body updateDocumentBody
+		if err := json.Unmarshal([]byte(raw), &body); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if _, err := body.update(); err == nil || !(errors.Is(err, errInvalidUpdate) || errors.Is(err, errInvalidMetadata)) {
+			t.Errorf("%s: err = %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/repository"
+	"github.com/dharsanguruparan/VaultDrop/internal/timing"
+)
+
+// sniffLen is how much of an upload http.DetectContentType looks at.
+const sniffLen = 512
+
+// storedUpload describes a raw upload that made it into the raw bucket.
+type storedUpload struct {
+	id          string
+	size        int64
+	contentType string
+	sha256      string
+	processAt   *time.Time
+}
+
+func (u *storedUpload) accepted() map[string]interface{} {
+	body := map[string]interface{}{
+		"id":     u.id,
+		"status": string(repository.StatusQueued),
+		"size":   u.size,
+		"sha256": u.sha256,
+	}
+	if u.processAt != nil {
+		body["processAt"] = u.processAt
+	}
+	return body
+}
+
+// uploadRejection is a problem with the upload itself, as opposed to a
+// storage failure; ingest answers it with rejectUpload.
+type uploadRejection struct {
+	reason      string
+	detail      string
+	size        int64
+	contentType string
+}
+
+func (e *uploadRejection) Error() string { return e.detail }
+
+// storeUpload stores the part as the raw object objectKey. Parts up to
+// UploadMemoryThreshold are read into memory and sent in one PUT; larger ones
+// continue, with what was read so far in front, to streamToStorage or
+// spoolToStorage.
+func (s *Server) storeUpload(ctx context.Context, part io.Reader, objectKey string, requestSize int64, dataKey []byte) (*storedUpload, error) {
+	if threshold := s.cfg.UploadMemoryThreshold; threshold > 0 {
+		stopScan := timing.Track(ctx, "scan")
+		var buf bytes.Buffer
+		_, err := buf.ReadFrom(io.LimitReader(part, threshold+1))
+		stopScan()
+		if err != nil {
+			err = fmt.Errorf("read file: %w", err)
+			return nil, &uploadRejection{reason: rejectionReason(err), detail: err.Error(), size: requestSize}
+		}
+		if int64(buf.Len()) <= threshold {
+			return s.putFromMemory(ctx, buf.Bytes(), objectKey, dataKey)
+		}
+		part = io.MultiReader(&buf, part)
+	}
+	if s.cfg.StreamUploads {
+		return s.streamToStorage(ctx, part, objectKey, requestSize, dataKey)
+	}
+	return s.spoolToStorage(ctx, part, objectKey, requestSize, dataKey)
+}
+
+// putFromMemory stores an upload read completely into data.
+func (s *Server) putFromMemory(ctx context.Context, data []byte, objectKey string, dataKey []byte) (*storedUpload, error) {
+	size := int64(len(data))
+	if size == 0 {
+		return nil, &uploadRejection{reason: repository.RejectEmpty, detail: errEmptyFile.Error()}
+	}
+	if size > s.cfg.MaxFileSize {
+		err := fmt.Errorf("%w (%d bytes)", errFileTooLarge, s.cfg.MaxFileSize)
+		return nil, &uploadRejection{reason: rejectionReason(err), detail: err.Error(), size: size}
+	}
+	contentType := http.DetectContentType(data)
+	if rej := s.checkType(contentType, size); rej != nil {
+		return nil, rej
+	}
+	sum := sha256.Sum256(data)
+	digest := hex.EncodeToString(sum[:])
+	if err := s.store.UploadRaw(ctx, objectKey, bytes.NewReader(data), size, contentType, digest, dataKey); err != nil {
+		return nil, err
+	}
+	return &storedUpload{size: size, contentType: contentType, sha256: digest}, nil
+}
+
+// streamToStorage pipes the part straight into a multipart upload. The first
+// bytes are peeked to check the type before anything is sent; the size limit
+// and digest are applied as the rest streams through, and a failing read
+// aborts the upload so nothing partial is left behind. A non-nil dataKey
+// encrypts the object.
+func (s *Server) streamToStorage(ctx context.Context, part io.Reader, objectKey string, requestSize int64, dataKey []byte) (*storedUpload, error) {
+	stopScan := timing.Track(ctx, "scan")
+	br := bufio.NewReaderSize(part, sniffLen)
+	head, err := br.Peek(sniffLen)
+	stopScan()
+	if err != nil && !errors.Is(err, io.EOF) {
+		err = fmt.Errorf("read file: %w", err)
+		return nil, &uploadRejection{reason: rejectionReason(err), detail: err.Error(), size: requestSize}
+	}
+	if len(head) == 0 {
+		return nil, &uploadRejection{reason: repository.RejectEmpty, detail: errEmptyFile.Error()}
+	}
+	contentType := http.DetectContentType(head)
+	if rej := s.checkType(contentType, requestSize); rej != nil {
+		return nil, rej
+	}
+	body := &meteredReader{r: br, limit: s.cfg.MaxFileSize, digest: sha256.New()}
+	err = s.store.UploadRawStream(ctx, objectKey, body, contentType, dataKey)
+	if body.err != nil {
+		return nil, &uploadRejection{reason: rejectionReason(body.err), detail: body.err.Error(), size: body.n, contentType: contentType}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &storedUpload{size: body.n, contentType: contentType, sha256: hex.EncodeToString(body.digest.Sum(nil))}, nil
+}
+
+// spoolToStorage buffers the part in a temp file first, for object stores
+// without multipart upload support, which need the size before the PUT.
+func (s *Server) spoolToStorage(ctx context.Context, part io.Reader, objectKey string, requestSize int64, dataKey []byte) (*storedUpload, error) {
+	stopScan := timing.Track(ctx, "scan")
+	tmp, err := s.persistTemp(part)
+	stopScan()
+	if err != nil {
+		return nil, &uploadRejection{reason: rejectionReason(err), detail: err.Error(), size: requestSize}
+	}
+	defer os.Remove(tmp.path)
+	defer tmp.f.Close()
+	if rej := s.checkType(tmp.contentType, tmp.size); rej != nil {
+		return nil, rej
+	}
+	if err := s.uploadToStorage(ctx, objectKey, tmp, dataKey); err != nil {
+		return nil, err
+	}
+	return &storedUpload{size: tmp.size, contentType: tmp.contentType, sha256: tmp.sha256}, nil
+}
+
+// meteredReader counts and hashes what passes through and fails once more
+// than limit bytes were read. err remembers the first client-side failure so
+// it can be told apart from storage errors after the upload returns.
+type meteredReader struct {
+	r      io.Reader
+	limit  int64
+	n      int64
+	digest hash.Hash
+	err    error
+}
+
+func (m *meteredReader) Read(p []byte) (int, error) {
+	if m.err != nil {
+		return 0, m.err
+	}
+	n, err := m.r.Read(p)
+	m.n += int64(n)
+	m.digest.Write(p[:n])
+	if m.n > m.limit {
+		m.err = fmt.Errorf("%w (%d bytes)", errFileTooLarge, m.limit)
+		return n, m.err
+	}
+	if err != nil && !errors.Is(err, io.EOF) {
+		m.err = fmt.Errorf("read file: %w", err)
+		return n, m.err
+	}
+	return n, err
+}
@@ -0,0 +1,33 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/config"
+	"github.com/dharsanguruparan/VaultDrop/internal/repository"
+)
+
+// TestStoreUploadInMemoryRejections covers the checks small uploads get
+// before anything reaches the store.
+func TestStoreUploadInMemoryRejections(t *testing.T) {
+	s := &Server{
+		cfg:         &config.Config{MaxFileSize: 16, UploadMemoryThreshold: 64},
+		acceptTypes: []string{"application/pdf"},
+	}
+	for _, tc := range []struct {
+		name, body, reason string
+	}{
+		{"empty", "", repository.RejectEmpty},
+		{"too large", "%PDF-1.4 " + strings.Repeat("x", 20), repository.RejectTooLarge},
+		{"wrong type", "plain text", repository.RejectUnsupportedType},
+	} {
+		_, err := s.storeUpload(context.Background(), strings.NewReader(tc.body), "uploads/x/a.pdf", -1, nil)
+		var rej *uploadRejection
+		if !errors.As(err, &rej) || rej.reason != tc.reason {
+			t.Errorf("%s: err = %v, want %s rejection", tc.name, err, tc.reason)
+		}
+	}
+}
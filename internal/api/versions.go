@@ -0,0 +1,153 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/httperr"
+	"github.com/dharsanguruparan/VaultDrop/internal/repository"
+	"github.com/dharsanguruparan/VaultDrop/internal/textdiff"
+)
+
+const (
+	// maxDiffInputBytes bounds the text of each side of a diff.
+	maxDiffInputBytes = 2 << 20
+	// maxDiffEdits bounds the number of changed lines; Myers' memory grows
+	// with its square.
+	maxDiffEdits       = 2000
+	defaultDiffContext = 3
+	maxDiffContext     = 50
+)
+
+// errDiffInputTooLarge marks a version over maxDiffInputBytes.
+var errDiffInputTooLarge = errors.New("diff input too large")
+
+// handleDocumentVersions serves /documents/{id}/versions and
+// /documents/{id}/versions/{a}/diff/{b}.
+func (s *Server) handleDocumentVersions(w http.ResponseWriter, r *http.Request, id string, rest []string) {
+	if r.Method != http.MethodGet {
+		httperr.MethodNotAllowed(w)
+		return
+	}
+	switch {
+	case len(rest) == 0 || (len(rest) == 1 && rest[0] == ""):
+		s.handleListVersions(w, r, id)
+	case len(rest) == 3 && rest[1] == "diff":
+		s.handleVersionDiff(w, r, id, rest[0], rest[2])
+	default:
+		httperr.NotFound(w, r)
+	}
+}
+
+func (s *Server) handleListVersions(w http.ResponseWriter, r *http.Request, id string) {
+	if _, ok := s.lookupDocument(w, r, id); !ok {
+		return
+	}
+	versions, err := s.repo.ListVersions(r.Context(), id)
+	if err != nil {
+		log.Printf("list versions %s: %v", id, err)
+		httperr.Internal(w, "failed to list versions")
+		return
+	}
+	if versions == nil {
+		versions = []repository.DocumentVersion{}
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{"versions": versions})
+}
+
+func (s *Server) handleVersionDiff(w http.ResponseWriter, r *http.Request, id, rawA, rawB string) {
+	a, errA := strconv.Atoi(rawA)
+	b, errB := strconv.Atoi(rawB)
+	if errA != nil || errB != nil || a <= 0 || b <= 0 {
+		httperr.BadRequest(w, "versions must be positive integers")
+		return
+	}
+	context := defaultDiffContext
+	if raw := r.URL.Query().Get("context"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 || n > maxDiffContext {
+			httperr.BadRequest(w, "invalid context")
+			return
+		}
+		context = n
+	}
+	if _, ok := s.lookupDocument(w, r, id); !ok {
+		return
+	}
+	textA, ok := s.versionText(w, r, id, a)
+	if !ok {
+		return
+	}
+	textB, ok := s.versionText(w, r, id, b)
+	if !ok {
+		return
+	}
+	diff, err := textdiff.Unified(textA, textB, fmt.Sprintf("%s@v%d", id, a), fmt.Sprintf("%s@v%d", id, b), context, maxDiffEdits)
+	if errors.Is(err, textdiff.ErrTooManyChanges) {
+		httperr.Error(w, "versions differ too much to diff", http.StatusUnprocessableEntity)
+		return
+	}
+	if err != nil {
+		httperr.Internal(w, "failed to diff versions")
+		return
+	}
+	w.Header().Set("Content-Type", "text/x-diff; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	io.WriteString(w, diff)
+}
+
+func (s *Server) versionText(w http.ResponseWriter, r *http.Request, id string, version int) (string, bool) {
+	text, archiveKey, err := s.repo.VersionContent(r.Context(), id, version)
+	if errors.Is(err, repository.ErrVersionNotFound) {
+		httperr.Error(w, fmt.Sprintf("version %d not found", version), http.StatusNotFound)
+		return "", false
+	}
+	if err != nil {
+		log.Printf("load version %s@%d: %v", id, version, err)
+		httperr.Internal(w, "failed to load version")
+		return "", false
+	}
+	tooLarge := len(text) > maxDiffInputBytes
+	if archiveKey != "" {
+		obj, info, err := s.store.OpenProcessed(r.Context(), archiveKey)
+		if err != nil {
+			log.Printf("get archived version %s@%d: %v", id, version, err)
+			s.storageError(w, err, "failed to load archived version")
+			return "", false
+		}
+		text, err = readDiffInput(obj, info.Size)
+		obj.Close()
+		tooLarge = errors.Is(err, errDiffInputTooLarge)
+		if err != nil && !tooLarge {
+			log.Printf("read archived version %s@%d: %v", id, version, err)
+			s.storageError(w, err, "failed to load archived version")
+			return "", false
+		}
+	}
+	if tooLarge {
+		httperr.Error(w, fmt.Sprintf("version %d exceeds the %d byte diff limit", version, maxDiffInputBytes), http.StatusRequestEntityTooLarge)
+		return "", false
+	}
+	return text, true
+}
+
+// readDiffInput reads an archived version of size bytes. One over
+// maxDiffInputBytes is refused before it is buffered, by its size or, should
+// the store misreport that, once the limit is read.
+func readDiffInput(r io.Reader, size int64) (string, error) {
+	if size > maxDiffInputBytes {
+		return "", errDiffInputTooLarge
+	}
+	data, err := io.ReadAll(io.LimitReader(r, maxDiffInputBytes+1))
+	if err != nil {
+		return "", err
+	}
+	if len(data) > maxDiffInputBytes {
+		return "", errDiffInputTooLarge
+	}
+	return string(data), nil
+}
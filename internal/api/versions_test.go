@@ -0,0 +1,47 @@
+package api
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += n
+	return n, err
+}
+
+func TestReadDiffInput(t *testing.T) {
+	text, err := readDiffInput(strings.NewReader("line\n"), 5)
+	if err != nil || text != "line\n" {
+		t.Errorf("small version = %q, %v", text, err)
+	}
+	at := strings.Repeat("a", maxDiffInputBytes)
+	if text, err := readDiffInput(strings.NewReader(at), int64(len(at))); err != nil || len(text) != maxDiffInputBytes {
+		t.Errorf("version at the limit = %d bytes, %v", len(text), err)
+	}
+
+	// A version whose size is over the limit is not read at all.
+	big := &countingReader{r: strings.NewReader(at + "b")}
+	if _, err := readDiffInput(big, maxDiffInputBytes+1); !errors.Is(err, errDiffInputTooLarge) || big.n != 0 {
+		t.Errorf("oversized version: %v after reading %d bytes", err, big.n)
+	}
+	// One whose size was misreported is read no further than the limit.
+	endless := &countingReader{r: iotest.OneByteReader(strings.NewReader(at + strings.Repeat("b", 1<<20)))}
+	if _, err := readDiffInput(endless, 5); !errors.Is(err, errDiffInputTooLarge) || endless.n != maxDiffInputBytes+1 {
+		t.Errorf("misreported size: %v after reading %d bytes", err, endless.n)
+	}
+
+	if _, err := readDiffInput(iotest.ErrReader(io.ErrUnexpectedEOF), 5); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("read error = %v", err)
+	}
+}
@@ -0,0 +1,253 @@
+// Package client is a small Go SDK for the VaultDrop HTTP API.
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ErrNotReady is returned by Text while the document is still being processed.
+var ErrNotReady = errors.New("document not processed yet")
+
+// APIError carries a non-success HTTP response from the API. Code is the
+// machine-readable error code, e.g. "not_found" or "text_expired"; it is
+// empty when the response was not the API's JSON error envelope.
+type APIError struct {
+	StatusCode int
+	Code       string
+	Message    string
+	Details    json.RawMessage
+	RequestID  string
+}
+
+func (e *APIError) Error() string {
+	if e.Code != "" {
+		return fmt.Sprintf("api returned %d %s: %s", e.StatusCode, e.Code, e.Message)
+	}
+	return fmt.Sprintf("api returned %d: %s", e.StatusCode, e.Message)
+}
+
+// Document mirrors the JSON returned by GET /documents/{id}.
+type Document struct {
+	ID           string    `json:"id"`
+	FileName     string    `json:"fileName"`
+	ObjectKey    string    `json:"objectKey"`
+	ProcessedKey *string   `json:"processedKey,omitempty"`
+	Status       string    `json:"status"`
+	ErrorMessage *string   `json:"errorMessage,omitempty"`
+	CreatedAt    time.Time `json:"createdAt"`
+	UpdatedAt    time.Time `json:"updatedAt"`
+}
+
+// UploadResult is the response to a successful upload.
+type UploadResult struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+}
+
+// ProgressFunc is called as upload bytes are sent.
+type ProgressFunc func(sent, total int64)
+
+// Client talks to a running VaultDrop API.
+type Client struct {
+	baseURL string
+	apiKey  string
+	http    *http.Client
+}
+
+// New constructs a Client for the API at baseURL. apiKey may be empty when the
+// server runs without authentication.
+func New(baseURL, apiKey string) *Client {
+	return &Client{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		apiKey:  apiKey,
+		http:    &http.Client{},
+	}
+}
+
+// Upload streams the file at path to POST /documents.
+func (c *Client) Upload(ctx context.Context, path string, progress ProgressFunc) (*UploadResult, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	pr, pw := io.Pipe()
+	mw := multipart.NewWriter(pw)
+	go func() {
+		part, err := mw.CreateFormFile("file", filepath.Base(path))
+		if err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+		src := io.Reader(f)
+		if progress != nil {
+			src = &progressReader{r: f, total: info.Size(), fn: progress}
+		}
+		if _, err := io.Copy(part, src); err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+		pw.CloseWithError(mw.Close())
+	}()
+	req, err := c.newRequest(ctx, http.MethodPost, "/documents", pr)
+	if err != nil {
+		pr.Close()
+		return nil, err
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	var result UploadResult
+	if err := c.doJSON(req, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Document fetches document metadata.
+func (c *Client) Document(ctx context.Context, id string) (*Document, error) {
+	req, err := c.newRequest(ctx, http.MethodGet, "/documents/"+url.PathEscape(id), nil)
+	if err != nil {
+		return nil, err
+	}
+	var doc Document
+	if err := c.doJSON(req, &doc); err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
+
+// Text returns the extracted text, or ErrNotReady while processing continues.
+func (c *Client) Text(ctx context.Context, id string) (string, error) {
+	req, err := c.newRequest(ctx, http.MethodGet, "/documents/"+url.PathEscape(id)+"/text", nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusAccepted {
+		return "", ErrNotReady
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", readAPIError(resp)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// ProcessedURL returns the presigned URL of the processed text artifact.
+func (c *Client) ProcessedURL(ctx context.Context, id string) (string, error) {
+	req, err := c.newRequest(ctx, http.MethodGet, "/documents/"+url.PathEscape(id)+"/processed-url", nil)
+	if err != nil {
+		return "", err
+	}
+	var body struct {
+		URL string `json:"url"`
+	}
+	if err := c.doJSON(req, &body); err != nil {
+		return "", err
+	}
+	return body.URL, nil
+}
+
+// Download writes the processed text artifact to w via its presigned URL.
+func (c *Client) Download(ctx context.Context, id string, w io.Writer) (int64, error) {
+	signed, err := c.ProcessedURL(ctx, id)
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, signed, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, readAPIError(resp)
+	}
+	return io.Copy(w, resp.Body)
+}
+
+func (c *Client) newRequest(ctx context.Context, method, path string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+	return req, nil
+}
+
+func (c *Client) doJSON(req *http.Request, out interface{}) error {
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return readAPIError(resp)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
+
+func readAPIError(resp *http.Response) error {
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	apiErr := &APIError{StatusCode: resp.StatusCode, RequestID: resp.Header.Get("X-Request-Id")}
+	var body struct {
+		Code      string          `json:"code"`
+		Message   string          `json:"message"`
+		Details   json.RawMessage `json:"details"`
+		RequestID string          `json:"requestId"`
+	}
+	if json.Unmarshal(data, &body) == nil && body.Code != "" {
+		apiErr.Code, apiErr.Message, apiErr.Details = body.Code, body.Message, body.Details
+		if body.RequestID != "" {
+			apiErr.RequestID = body.RequestID
+		}
+		return apiErr
+	}
+	apiErr.Message = strings.TrimSpace(string(data))
+	return apiErr
+}
+
+type progressReader struct {
+	r     io.Reader
+	sent  int64
+	total int64
+	fn    ProgressFunc
+}
+
+func (p *progressReader) Read(buf []byte) (int, error) {
+	n, err := p.r.Read(buf)
+	if n > 0 {
+		p.sent += int64(n)
+		p.fn(p.sent, p.total)
+	}
+	return n, err
+}
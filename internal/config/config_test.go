@@ -0,0 +1,186 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testConfigFile = `
+defaults:
+  max_file_bytes: 1048576
+  allowed_types: [application/pdf, text/plain]
+profiles:
+  dev:
+    address: ":9090"
+    signed_ttl: 1m
+  staging:
+    extends: dev
+    signed_ttl: 10m
+  loop:
+    extends: loop
+`
+
+func writeConfig(t *testing.T, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "vaultdrop.yaml")
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadProfileInheritance(t *testing.T) {
+	t.Setenv("VAULTDROP_CONFIG", writeConfig(t, testConfigFile))
+	t.Setenv("VAULTDROP_ENV", "staging")
+	t.Setenv("VAULTDROP_ADDRESS", ":7070")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Address != ":7070" {
+		t.Errorf("environment should win over the file, got %q", cfg.Address)
+	}
+	if cfg.SignedURLTTL != 10*time.Minute {
+		t.Errorf("staging should override dev, got %s", cfg.SignedURLTTL)
+	}
+	if cfg.MaxFileSize != 1<<20 {
+		t.Errorf("defaults should apply, got %d", cfg.MaxFileSize)
+	}
+	if strings.Join(cfg.AllowedTypes, ",") != "application/pdf,text/plain" {
+		t.Errorf("lists should map to comma-separated values, got %v", cfg.AllowedTypes)
+	}
+}
+
+func TestLoadProfileErrors(t *testing.T) {
+	path := writeConfig(t, testConfigFile)
+	for env, want := range map[string]string{
+		"missing": "not found",
+		"loop":    "cycle",
+	} {
+		t.Setenv("VAULTDROP_CONFIG", path)
+		t.Setenv("VAULTDROP_ENV", env)
+		if _, err := Load(); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("VAULTDROP_ENV=%s: expected error containing %q, got %v", env, want, err)
+		}
+	}
+
+	t.Setenv("VAULTDROP_CONFIG", writeConfig(t, "defaults:\n  max_file_byte: 1\n"))
+	t.Setenv("VAULTDROP_ENV", "")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "max_file_byte") {
+		t.Errorf("expected unknown setting error, got %v", err)
+	}
+}
+
+func TestLoadStrictMode(t *testing.T) {
+	t.Setenv("VAULTDROP_MAX_FILE_BYTES", "25M")
+	t.Setenv("VAULTDROP_SIGNED_TTL", "soon")
+	_, err := Load()
+	if err == nil || !strings.Contains(err.Error(), "VAULTDROP_MAX_FILE_BYTES") || !strings.Contains(err.Error(), "VAULTDROP_SIGNED_TTL") {
+		t.Fatalf("invalid values should fail the load by default, got %v", err)
+	}
+
+	t.Setenv("VAULTDROP_LENIENT_CONFIG", "true")
+	cfg, err := Load()
+	if err != nil || cfg.MaxFileSize != 25<<20 || cfg.SignedURLTTL != defaultSignedTTL {
+		t.Fatalf("lenient mode should read 25M as MiB and default the TTL, got %+v, %v", cfg, err)
+	}
+
+	t.Setenv("VAULTDROP_LENIENT_CONFIG", "maybe")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "VAULTDROP_LENIENT_CONFIG") {
+		t.Fatalf("an unparsable escape hatch should fail the load, got %v", err)
+	}
+}
+
+func TestLoadTOMLAndFlatFiles(t *testing.T) {
+	dir := t.TempDir()
+	tomlPath := filepath.Join(dir, "vaultdrop.toml")
+	err := os.WriteFile(tomlPath, []byte(`
+[defaults]
+max_file_bytes = 2048
+allowed_types = ["application/pdf"]
+
+[profiles.prod.s3]
+use_ssl = true
+raw_bucket = "prod-raw"
+`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("VAULTDROP_CONFIG", tomlPath)
+	t.Setenv("VAULTDROP_ENV", "prod")
+	t.Setenv("VAULTDROP_SIGNING_SECRET", "0123456789abcdef")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.MaxFileSize != 2048 || !cfg.S3UseSSL || cfg.RawBucket != "prod-raw" {
+		t.Errorf("unexpected config from TOML: size=%d ssl=%v raw=%q", cfg.MaxFileSize, cfg.S3UseSSL, cfg.RawBucket)
+	}
+
+	t.Setenv("VAULTDROP_CONFIG", writeConfig(t, "address: \":9191\"\ns3:\n  region: eu-west-1\n"))
+	t.Setenv("VAULTDROP_ENV", "")
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("Load flat file: %v", err)
+	}
+	if cfg.Address != ":9191" || cfg.S3Region != "eu-west-1" {
+		t.Errorf("flat file not applied: address=%q region=%q", cfg.Address, cfg.S3Region)
+	}
+
+	t.Setenv("VAULTDROP_CONFIG", writeConfig(t, "address: \":9191\"\ndefaults:\n  read_only: true\n"))
+	if _, err := Load(); err == nil {
+		t.Error("mixing flat settings with sections should fail")
+	}
+}
+
+func TestLoadMalformedAPIKeys(t *testing.T) {
+	t.Setenv("VAULTDROP_API_KEYS", "justakey")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), `VAULTDROP_API_KEYS: want principal:key, got "justakey"`) {
+		t.Fatalf("a key with no principal should fail the load, got %v", err)
+	}
+	t.Setenv("VAULTDROP_LENIENT_CONFIG", "true")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "want principal:key") {
+		t.Fatalf("a malformed API key should fail the load in lenient mode too, got %v", err)
+	}
+	t.Setenv("VAULTDROP_API_KEYS", "alice:k1, ,bob:k2")
+	t.Setenv("VAULTDROP_ADMINS", "alice")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.APIKeys["k1"] != "alice" || cfg.APIKeys["k2"] != "bob" || len(cfg.APIKeys) != 2 {
+		t.Fatalf("APIKeys = %v", cfg.APIKeys)
+	}
+}
+
+func TestLoadSecretFiles(t *testing.T) {
+	dir := t.TempDir()
+	secret := filepath.Join(dir, "signing_secret")
+	if err := os.WriteFile(secret, []byte("from-a-mounted-secret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("VAULTDROP_SIGNING_SECRET_FILE", secret)
+	t.Setenv("VAULTDROP_CONFIG", writeConfig(t, "s3_secret_key_file: "+secret+"\n"))
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if string(cfg.SigningSecret) != "from-a-mounted-secret" || cfg.S3SecretKey != "from-a-mounted-secret" {
+		t.Errorf("secret files not read: %q, %q", cfg.SigningSecret, cfg.S3SecretKey)
+	}
+
+	t.Setenv("VAULTDROP_SIGNING_SECRET", "inline")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "both set") {
+		t.Errorf("expected conflict error, got %v", err)
+	}
+
+	t.Setenv("VAULTDROP_SIGNING_SECRET", "")
+	t.Setenv("VAULTDROP_SIGNING_SECRET_FILE", filepath.Join(dir, "missing"))
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "VAULTDROP_SIGNING_SECRET_FILE") {
+		t.Errorf("expected unreadable file error, got %v", err)
+	}
+}
@@ -0,0 +1,267 @@
+package config
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// fileConfig is the layout of the VAULTDROP_CONFIG file, YAML or TOML:
+//
+//	defaults:
+//	  max_file_bytes: 26214400
+//	profiles:
+//	  dev:
+//	    database_url: postgres://localhost/vaultdrop
+//	  prod:
+//	    extends: dev
+//	    s3:
+//	      use_ssl: true
+//
+// Keys are the environment variable names without the VAULTDROP_ prefix, in
+// lower case; nested sections join their keys with an underscore, so s3.use_ssl
+// is s3_use_ssl. A profile inherits its `extends` chain, which inherits
+// `defaults`; the nearest definition of a key wins. A file with neither
+// section is a flat list of settings, read as defaults.
+type fileConfig struct {
+	Defaults map[string]interface{}
+	Profiles map[string]map[string]interface{}
+}
+
+const extendsKey = "extends"
+
+// loader resolves settings from the environment first and then from the
+// config file's active profile. It remembers which keys were asked for so
+// misspelled file keys can be reported instead of silently ignored.
+type loader struct {
+	env    string
+	file   map[string]string
+	seen   map[string]bool
+	strict bool
+	errs   []error
+}
+
+func newLoader(path, env string) (*loader, error) {
+	l := &loader{env: env, seen: make(map[string]bool), strict: true}
+	if path == "" {
+		if env != "" {
+			return nil, fmt.Errorf("VAULTDROP_ENV=%s requires VAULTDROP_CONFIG", env)
+		}
+		return l, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config file: %w", err)
+	}
+	fc, err := parseConfigFile(path, data)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	l.file, err = fc.resolve(env)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return l, nil
+}
+
+// lookup returns the value for an environment variable name, preferring the
+// process environment over the config file. Every setting also has a _FILE
+// variant naming a file that holds the value, as Docker and Kubernetes mount
+// secrets; it is consulted after the plain name at each level.
+func (l *loader) lookup(key string) (string, bool) {
+	fileKey := strings.ToLower(strings.TrimPrefix(key, "VAULTDROP_"))
+	l.seen[fileKey] = true
+	l.seen[fileKey+"_file"] = true
+	v, hasValue := os.LookupEnv(key)
+	path, hasPath := os.LookupEnv(key + "_FILE")
+	if hasValue && v != "" && hasPath && path != "" {
+		l.errs = append(l.errs, fmt.Errorf("%s and %s_FILE are both set", key, key))
+		return "", false
+	}
+	if hasValue && v != "" {
+		return v, true
+	}
+	if hasPath && path != "" {
+		return l.readSecretFile(key+"_FILE", path)
+	}
+	if v, ok := l.file[fileKey]; ok {
+		return v, true
+	}
+	if path, ok := l.file[fileKey+"_file"]; ok && path != "" {
+		return l.readSecretFile(fileKey+"_file", path)
+	}
+	return "", false
+}
+
+// readSecretFile reads a _FILE setting. A trailing newline, which editors and
+// `echo` add, is dropped. An unreadable file always fails the load, even
+// in lenient mode: silently falling back to a default secret is worse than not
+// starting.
+func (l *loader) readSecretFile(name, path string) (string, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		l.errs = append(l.errs, fmt.Errorf("%s: %w", name, err))
+		return "", false
+	}
+	return strings.TrimRight(string(data), "\r\n"), true
+}
+
+// invalid handles a value that failed to parse. It is reported from Load
+// unless VAULTDROP_LENIENT_CONFIG is set, in which case the default is used
+// and a warning logged.
+func (l *loader) invalid(key string, err error) {
+	if l.strict {
+		l.errs = append(l.errs, fmt.Errorf("%s: %w", key, err))
+		return
+	}
+	log.Printf("config: ignoring %s: %v; using the default", key, err)
+}
+
+func (l *loader) checkUnused() error {
+	var unknown []string
+	for key := range l.file {
+		if !l.seen[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	if len(unknown) == 0 {
+		return nil
+	}
+	sort.Strings(unknown)
+	return fmt.Errorf("config file: unknown settings %s", strings.Join(unknown, ", "))
+}
+
+// parseConfigFile decodes TOML for .toml files and YAML (which includes JSON)
+// otherwise.
+func parseConfigFile(path string, data []byte) (*fileConfig, error) {
+	var raw map[string]interface{}
+	var err error
+	if strings.EqualFold(filepath.Ext(path), ".toml") {
+		err = toml.Unmarshal(data, &raw)
+	} else {
+		err = yaml.Unmarshal(data, &raw)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defaults, hasDefaults := raw["defaults"]
+	profiles, hasProfiles := raw["profiles"]
+	if !hasDefaults && !hasProfiles {
+		return &fileConfig{Defaults: raw}, nil
+	}
+	if len(raw) > btoi(hasDefaults)+btoi(hasProfiles) {
+		return nil, fmt.Errorf("settings must go under defaults or a profile when either section is present")
+	}
+	fc := &fileConfig{Profiles: map[string]map[string]interface{}{}}
+	if defaults != nil {
+		m, ok := defaults.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("defaults must be a map")
+		}
+		fc.Defaults = m
+	}
+	if profiles != nil {
+		all, ok := profiles.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("profiles must be a map")
+		}
+		for name, p := range all {
+			m, ok := p.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("profile %q must be a map", name)
+			}
+			fc.Profiles[name] = m
+		}
+	}
+	return fc, nil
+}
+
+func btoi(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// resolve flattens defaults and the profile's extends chain into one map.
+func (fc *fileConfig) resolve(env string) (map[string]string, error) {
+	var chain []map[string]interface{}
+	if env != "" {
+		visited := map[string]bool{}
+		for name := env; name != ""; {
+			if visited[name] {
+				return nil, fmt.Errorf("profile %q: extends cycle", env)
+			}
+			visited[name] = true
+			profile, ok := fc.Profiles[name]
+			if !ok {
+				return nil, fmt.Errorf("profile %q not found", name)
+			}
+			chain = append(chain, profile)
+			parent, _ := profile[extendsKey].(string)
+			name = parent
+		}
+	}
+	chain = append(chain, fc.Defaults)
+
+	out := make(map[string]string)
+	// Apply from the most general to the most specific layer.
+	for i := len(chain) - 1; i >= 0; i-- {
+		if err := flatten(out, "", chain[i]); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// flatten writes every leaf of m into out, joining nested section names and
+// keys with underscores.
+func flatten(out map[string]string, prefix string, m map[string]interface{}) error {
+	for key, raw := range m {
+		if prefix == "" && key == extendsKey {
+			continue
+		}
+		name := strings.ToLower(prefix + key)
+		if nested, ok := raw.(map[string]interface{}); ok {
+			if err := flatten(out, name+"_", nested); err != nil {
+				return err
+			}
+			continue
+		}
+		value, err := scalarString(raw)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		out[name] = value
+	}
+	return nil
+}
+
+// scalarString renders a file value the way the matching environment
+// variable would be written; lists become comma-separated.
+func scalarString(raw interface{}) (string, error) {
+	switch v := raw.(type) {
+	case nil:
+		return "", nil
+	case []interface{}:
+		parts := make([]string, len(v))
+		for i, item := range v {
+			s, err := scalarString(item)
+			if err != nil {
+				return "", err
+			}
+			parts[i] = s
+		}
+		return strings.Join(parts, ","), nil
+	case map[string]interface{}:
+		return "", fmt.Errorf("maps are not supported inside lists")
+	default:
+		return fmt.Sprint(v), nil
+	}
+}
@@ -0,0 +1,50 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/url"
+	"sort"
+)
+
+// Fingerprint returns a short hash of the effective settings, so replicas
+// running with different configuration can be spotted by comparing one
+// value. Secrets (the signing secret, S3 secret key, Redis password, API keys,
+// master keys and any password in the database and events URLs) are left out,
+// so the fingerprint can be shared in tickets; API keys contribute only their
+// principals.
+func (c *Config) Fingerprint() string {
+	redacted := *c
+	redacted.SigningSecret = nil
+	redacted.S3SecretKey = ""
+	redacted.RedisPassword = ""
+	redacted.APIKeys = nil
+	redacted.MasterKeys = nil
+	redacted.DatabaseURL = redactDSN(c.DatabaseURL)
+	redacted.DatabaseReadURL = redactDSN(c.DatabaseReadURL)
+	redacted.EventsURL = redactDSN(c.EventsURL)
+	principals := make([]string, 0, len(c.APIKeys))
+	for _, p := range c.APIKeys {
+		principals = append(principals, p)
+	}
+	sort.Strings(principals)
+
+	// json.Marshal writes struct fields in declaration order, which keeps the
+	// encoding stable between replicas of the same build.
+	data, _ := json.Marshal(struct {
+		Config     Config
+		Principals []string
+	}{redacted, principals})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
+
+// redactDSN drops the password from a URL-style DSN.
+func redactDSN(dsn string) string {
+	if u, err := url.Parse(dsn); err == nil && u.User != nil {
+		u.User = url.User(u.User.Username())
+		return u.String()
+	}
+	return dsn
+}
@@ -0,0 +1,20 @@
+package config
+
+import "net/http"
+
+// TLSEnabled reports whether the HTTP servers should serve HTTPS.
+func (c *Config) TLSEnabled() bool {
+	return c.TLSCert != "" || len(c.TLSAutocertDomains) > 0
+}
+
+// HTTPServer returns an http.Server for addr and h with the configured header
+// and keep-alive limits applied.
+func (c *Config) HTTPServer(addr string, h http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           h,
+		ReadHeaderTimeout: c.HTTPReadHeaderTimeout,
+		IdleTimeout:       c.HTTPIdleTimeout,
+		MaxHeaderBytes:    int(c.HTTPMaxHeaderBytes),
+	}
+}
@@ -0,0 +1,121 @@
+package config
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// sizeUnits maps unit suffixes to byte multipliers. SI units are powers of
+// 1000 and IEC units powers of 1024, as in "25MB" versus "25MiB".
+var sizeUnits = map[string]int64{
+	"":    1,
+	"B":   1,
+	"KB":  1000,
+	"MB":  1000 * 1000,
+	"GB":  1000 * 1000 * 1000,
+	"TB":  1000 * 1000 * 1000 * 1000,
+	"KIB": 1 << 10,
+	"MIB": 1 << 20,
+	"GIB": 1 << 30,
+	"TIB": 1 << 40,
+}
+
+// ambiguousSizeUnits are single-letter suffixes that could mean either SI or
+// IEC units. Lenient parsing reads them as IEC, like Docker does.
+var ambiguousSizeUnits = map[string]int64{
+	"K": 1 << 10,
+	"M": 1 << 20,
+	"G": 1 << 30,
+	"T": 1 << 40,
+}
+
+// parseSize converts strings like "26214400", "25MB", "1.5GiB" or "25M" into
+// bytes. Units are case-insensitive unless strict is set, in which case the
+// single-letter suffixes and a lower-case "b" (bits) are rejected as
+// ambiguous.
+func parseSize(value string, strict bool) (int64, error) {
+	s := strings.TrimSpace(value)
+	split := strings.IndexFunc(s, func(r rune) bool {
+		return !unicode.IsDigit(r) && r != '.'
+	})
+	number, unit := s, ""
+	if split >= 0 {
+		number, unit = s[:split], strings.TrimSpace(s[split:])
+	}
+	if number == "" {
+		return 0, fmt.Errorf("invalid size %q", value)
+	}
+	if strict && strings.HasSuffix(unit, "b") {
+		return 0, fmt.Errorf("ambiguous size %q: use B for bytes", value)
+	}
+	multiplier, ok := sizeUnits[strings.ToUpper(unit)]
+	if !ok {
+		multiplier, ok = ambiguousSizeUnits[strings.ToUpper(unit)]
+		if !ok {
+			return 0, fmt.Errorf("invalid size %q: unknown unit %q", value, unit)
+		}
+		if strict {
+			return 0, fmt.Errorf("ambiguous size %q: use %sB or %siB", value, strings.ToUpper(unit), strings.ToUpper(unit))
+		}
+	}
+	if !strings.Contains(number, ".") {
+		n, err := strconv.ParseInt(number, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid size %q", value)
+		}
+		if n > math.MaxInt64/multiplier {
+			return 0, fmt.Errorf("size %q overflows", value)
+		}
+		return n * multiplier, nil
+	}
+	f, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q", value)
+	}
+	bytes := f * float64(multiplier)
+	if bytes >= math.MaxInt64 {
+		return 0, fmt.Errorf("size %q overflows", value)
+	}
+	return int64(bytes), nil
+}
+
+// parseHumanDuration extends time.ParseDuration with leading day and week
+// components, so retention periods can be written as "30d" or "1w2d12h".
+func parseHumanDuration(value string) (time.Duration, error) {
+	s := strings.TrimSpace(value)
+	if d, err := time.ParseDuration(s); err == nil {
+		return d, nil
+	}
+	var total time.Duration
+	for {
+		i := 0
+		for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+			i++
+		}
+		if i == 0 || i == len(s) || (s[i] != 'd' && s[i] != 'w') {
+			break
+		}
+		n, err := strconv.ParseInt(s[:i], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", value)
+		}
+		unit := 24 * time.Hour
+		if s[i] == 'w' {
+			unit *= 7
+		}
+		total += time.Duration(n) * unit
+		s = s[i+1:]
+	}
+	if s == "" {
+		return total, nil
+	}
+	rest, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+	return total + rest, nil
+}
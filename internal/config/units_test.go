@@ -0,0 +1,52 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSize(t *testing.T) {
+	cases := []struct {
+		in     string
+		strict bool
+		want   int64
+		ok     bool
+	}{
+		{"26214400", true, 26214400, true},
+		{"25MB", true, 25_000_000, true},
+		{"25MiB", true, 25 << 20, true},
+		{"1.5GiB", true, 3 << 29, true},
+		{"25 mb", false, 25_000_000, true},
+		{"25M", false, 25 << 20, true},
+		{"25M", true, 0, false},
+		{"25Mb", true, 0, false},
+		{"25XB", false, 0, false},
+		{"MB", false, 0, false},
+		{"9999999TiB", false, 0, false},
+	}
+	for _, tc := range cases {
+		got, err := parseSize(tc.in, tc.strict)
+		if (err == nil) != tc.ok || got != tc.want {
+			t.Errorf("parseSize(%q, strict=%v) = %d, %v; want %d, ok=%v", tc.in, tc.strict, got, err, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestParseHumanDuration(t *testing.T) {
+	cases := map[string]time.Duration{
+		"90s":     90 * time.Second,
+		"30d":     30 * 24 * time.Hour,
+		"1w2d12h": 9*24*time.Hour + 12*time.Hour,
+	}
+	for in, want := range cases {
+		got, err := parseHumanDuration(in)
+		if err != nil || got != want {
+			t.Errorf("parseHumanDuration(%q) = %s, %v; want %s", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"d", "3x", "1d2x"} {
+		if _, err := parseHumanDuration(in); err == nil {
+			t.Errorf("parseHumanDuration(%q) should fail", in)
+		}
+	}
+}
@@ -0,0 +1,305 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	// maxPresignTTL is the longest expiry S3 accepts for a presigned URL.
+	maxPresignTTL = 7 * 24 * time.Hour
+	// maxExtractTimeout matches queue.ExtractTaskTimeout, the asynq timeout
+	// extract tasks carry; a longer worker deadline would never be reached.
+	maxExtractTimeout = 2 * time.Hour
+	// minS3PartSize and maxS3PartSize are S3's bounds on a multipart part.
+	minS3PartSize = 5 << 20
+	maxS3PartSize = 5 << 30
+	// maxObjectTags is how many tags S3 keeps on one object.
+	maxObjectTags = 10
+)
+
+var (
+	bucketNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9.-]{1,61}[a-z0-9]$`)
+	// objectTagPattern is the character set S3 allows in tag keys and values.
+	objectTagPattern = regexp.MustCompile(`^[\p{L}\p{N} +\-=._:/@]*$`)
+)
+
+// Validate reports every setting that parsed but cannot work, joined into one
+// error so an operator can fix them all in one go. Load calls it; callers that
+// build or adjust a Config by hand can call it again.
+func (c *Config) Validate() error {
+	var errs []error
+	fail := func(key, format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("%s: %s", key, fmt.Sprintf(format, args...)))
+	}
+
+	if _, _, err := net.SplitHostPort(c.Address); err != nil {
+		fail("VAULTDROP_ADDRESS", "want host:port, got %q", c.Address)
+	}
+	if _, _, err := net.SplitHostPort(c.WorkerAddress); err != nil {
+		fail("VAULTDROP_WORKER_ADDRESS", "want host:port, got %q", c.WorkerAddress)
+	}
+	if _, _, err := net.SplitHostPort(c.RedisAddr); err != nil {
+		fail("VAULTDROP_REDIS_ADDR", "want host:port, got %q", c.RedisAddr)
+	}
+	if strings.Contains(c.S3Endpoint, "://") || strings.Contains(c.S3Endpoint, "/") {
+		fail("VAULTDROP_S3_ENDPOINT", "want host[:port] without scheme or path (use VAULTDROP_S3_USE_SSL for https), got %q", c.S3Endpoint)
+	}
+	for _, d := range []struct{ key, dsn string }{
+		{"VAULTDROP_DATABASE_URL", c.DatabaseURL},
+		{"VAULTDROP_DATABASE_READ_URL", c.DatabaseReadURL},
+	} {
+		if !strings.Contains(d.dsn, "://") {
+			continue
+		}
+		u, err := url.Parse(d.dsn)
+		if err != nil {
+			fail(d.key, "%v", err)
+		} else if u.Scheme != "postgres" && u.Scheme != "postgresql" {
+			fail(d.key, "unsupported scheme %q", u.Scheme)
+		}
+	}
+	if c.DBMaxConns < 1 {
+		fail("VAULTDROP_DB_MAX_CONNS", "must be at least 1, got %d", c.DBMaxConns)
+	}
+	if c.DBMinConns < 0 || c.DBMinConns > c.DBMaxConns {
+		fail("VAULTDROP_DB_MIN_CONNS", "must be between 0 and VAULTDROP_DB_MAX_CONNS (%d), got %d", c.DBMaxConns, c.DBMinConns)
+	}
+	for _, d := range []struct {
+		key   string
+		value time.Duration
+	}{
+		{"VAULTDROP_DB_MAX_CONN_LIFETIME", c.DBMaxConnLifetime},
+		{"VAULTDROP_DB_MAX_CONN_IDLE_TIME", c.DBMaxConnIdleTime},
+		{"VAULTDROP_DB_HEALTH_CHECK_PERIOD", c.DBHealthCheckPeriod},
+	} {
+		if d.value <= 0 {
+			fail(d.key, "must be positive, got %s", d.value)
+		}
+	}
+	for _, b := range []struct{ key, name string }{
+		{"VAULTDROP_S3_RAW_BUCKET", c.RawBucket},
+		{"VAULTDROP_S3_PROCESSED_BUCKET", c.ProcessedBucket},
+	} {
+		if !bucketNamePattern.MatchString(b.name) || strings.Contains(b.name, "..") || net.ParseIP(b.name) != nil {
+			fail(b.key, "%q is not a valid bucket name (3-63 lower-case letters, digits, dots and hyphens; no paths)", b.name)
+		}
+	}
+	if c.S3PartSize < minS3PartSize || c.S3PartSize > maxS3PartSize {
+		fail("VAULTDROP_S3_PART_SIZE", "must be between 5MiB and 5GiB, got %d bytes", c.S3PartSize)
+	}
+	// Every upload in flight may hold this much, so it stays well below the
+	// part size a streamed upload buffers anyway.
+	if c.UploadMemoryThreshold < 0 || c.UploadMemoryThreshold > c.S3PartSize {
+		fail("VAULTDROP_UPLOAD_MEMORY_THRESHOLD", "must be between 0 and VAULTDROP_S3_PART_SIZE (%d bytes), got %d bytes", c.S3PartSize, c.UploadMemoryThreshold)
+	}
+	if c.S3UploadThreads < 1 {
+		fail("VAULTDROP_S3_UPLOAD_THREADS", "must be at least 1, got %d", c.S3UploadThreads)
+	}
+	if c.S3MaxAttempts < 1 {
+		fail("VAULTDROP_S3_MAX_ATTEMPTS", "must be at least 1, got %d", c.S3MaxAttempts)
+	}
+	if c.S3BreakerFailures < 0 {
+		fail("VAULTDROP_S3_BREAKER_FAILURES", "must not be negative, got %d", c.S3BreakerFailures)
+	}
+	if c.S3BreakerFailures > 0 && c.S3BreakerCooldown <= 0 {
+		fail("VAULTDROP_S3_BREAKER_COOLDOWN", "must be positive when the breaker is enabled, got %s", c.S3BreakerCooldown)
+	}
+	if len(c.S3ObjectTags) > maxObjectTags {
+		fail("VAULTDROP_S3_OBJECT_TAGS", "S3 allows at most %d tags per object, got %d", maxObjectTags, len(c.S3ObjectTags))
+	}
+	for k, v := range c.S3ObjectTags {
+		if len(k) > 128 || len(v) > 256 || !objectTagPattern.MatchString(k) || !objectTagPattern.MatchString(v) {
+			fail("VAULTDROP_S3_OBJECT_TAGS", "%q=%q is not a valid tag (keys up to 128 and values up to 256 letters, digits, spaces and + - = . _ : / @)", k, v)
+		}
+	}
+	for _, d := range []struct {
+		key  string
+		days int
+	}{
+		{"VAULTDROP_S3_COLD_AFTER_DAYS", c.S3ColdAfterDays},
+		{"VAULTDROP_S3_RAW_EXPIRE_AFTER_DAYS", c.S3RawExpireAfterDays},
+		{"VAULTDROP_S3_ABORT_INCOMPLETE_AFTER_DAYS", c.S3AbortIncompleteAfterDays},
+		{"VAULTDROP_S3_NONCURRENT_EXPIRE_AFTER_DAYS", c.S3NoncurrentExpireAfterDays},
+	} {
+		if d.days < 0 {
+			fail(d.key, "must not be negative, got %d", d.days)
+		}
+	}
+	if c.S3NoncurrentExpireAfterDays > 0 && !c.S3Versioning {
+		fail("VAULTDROP_S3_NONCURRENT_EXPIRE_AFTER_DAYS", "only applies with VAULTDROP_S3_VERSIONING=true")
+	}
+	if c.S3ColdAfterDays > 0 && c.S3ColdStorageClass == "" {
+		fail("VAULTDROP_S3_COLD_STORAGE_CLASS", "required when VAULTDROP_S3_COLD_AFTER_DAYS is set")
+	}
+	if c.SignedURLTTL > maxPresignTTL {
+		fail("VAULTDROP_SIGNED_TTL", "%s exceeds the 7 day limit for presigned URLs", c.SignedURLTTL)
+	}
+	if c.StatusPollInterval <= 0 {
+		fail("VAULTDROP_STATUS_POLL_INTERVAL", "must be positive, got %s", c.StatusPollInterval)
+	}
+	if c.ProcessingQueue < 0 {
+		fail("VAULTDROP_PROCESSING_QUEUE", "must not be negative, got %d", c.ProcessingQueue)
+	}
+	if c.ProcessingMaxAttempts < 1 {
+		fail("VAULTDROP_PROCESSING_MAX_ATTEMPTS", "must be at least 1, got %d", c.ProcessingMaxAttempts)
+	}
+	if c.SQLitePath != "" {
+		if c.SnapshotPath != "" {
+			fail("VAULTDROP_SNAPSHOT_PATH", "snapshots only apply to the in-memory store, but VAULTDROP_SQLITE_PATH is set")
+		}
+		if c.StoreMaxRecords != 0 || c.StoreMaxBytes != 0 {
+			fail("VAULTDROP_STORE_MAX_RECORDS", "store limits only apply to the in-memory store, but VAULTDROP_SQLITE_PATH is set")
+		}
+	}
+	if c.StoreMaxRecords < 0 {
+		fail("VAULTDROP_STORE_MAX_RECORDS", "must not be negative, got %d", c.StoreMaxRecords)
+	}
+	if c.StoreMaxBytes < 0 {
+		fail("VAULTDROP_STORE_MAX_BYTES", "must not be negative, got %d", c.StoreMaxBytes)
+	}
+	if c.RedisDB < 0 {
+		fail("VAULTDROP_REDIS_DB", "must not be negative, got %d", c.RedisDB)
+	}
+	if len(c.APIKeys) > 0 {
+		principals := make(map[string]bool, len(c.APIKeys))
+		for _, p := range c.APIKeys {
+			principals[p] = true
+		}
+		for _, admin := range c.Admins {
+			if !principals[admin] {
+				fail("VAULTDROP_ADMINS", "principal %q has no entry in VAULTDROP_API_KEYS", admin)
+			}
+		}
+	}
+	if c.ExtractTimeout <= 0 || c.ExtractTimeout > maxExtractTimeout {
+		fail("VAULTDROP_EXTRACT_TIMEOUT", "must be between 0 and %s, got %s", maxExtractTimeout, c.ExtractTimeout)
+	}
+	// The Go runtime alone reserves tens of MiB of address space.
+	if c.ExtractSandbox && c.ExtractMemoryLimit != 0 && c.ExtractMemoryLimit < 64<<20 {
+		fail("VAULTDROP_EXTRACT_MEMORY_LIMIT", "must be 0 or at least 64MiB, got %d bytes", c.ExtractMemoryLimit)
+	}
+	switch c.ExtractBackend {
+	case "go", "pdftotext", "pdfium":
+	default:
+		fail("VAULTDROP_EXTRACT_BACKEND", "want go, pdftotext or pdfium, got %q", c.ExtractBackend)
+	}
+	if c.PDFMaxPages < 0 {
+		fail("VAULTDROP_PDF_MAX_PAGES", "must not be negative, got %d", c.PDFMaxPages)
+	}
+	if c.PDFMaxObjects < 0 {
+		fail("VAULTDROP_PDF_MAX_OBJECTS", "must not be negative, got %d", c.PDFMaxObjects)
+	}
+	if c.PDFMaxDecompressed < 0 {
+		fail("VAULTDROP_PDF_MAX_DECOMPRESSED", "must not be negative, got %d bytes", c.PDFMaxDecompressed)
+	}
+	if len(c.QueueWeights) == 0 {
+		fail("VAULTDROP_QUEUE_WEIGHTS", "at least one queue is required")
+	}
+	for _, setting := range []struct {
+		key    string
+		values map[string]int
+		min    int
+	}{
+		{"VAULTDROP_QUEUE_WEIGHTS", c.QueueWeights, 1},
+		{"VAULTDROP_TASK_CONCURRENCY", c.TaskConcurrency, 1},
+		{"VAULTDROP_TASK_MAX_RETRY", c.TaskMaxRetry, 0},
+	} {
+		for name, n := range setting.values {
+			if n < setting.min {
+				fail(setting.key, "%s must be at least %d, got %d", name, setting.min, n)
+			}
+		}
+	}
+	if (c.TLSCert == "") != (c.TLSKey == "") {
+		fail("VAULTDROP_TLS_KEY", "VAULTDROP_TLS_CERT and VAULTDROP_TLS_KEY must be set together")
+	}
+	if len(c.TLSAutocertDomains) > 0 {
+		if c.TLSCert != "" {
+			fail("VAULTDROP_TLS_AUTOCERT_DOMAINS", "cannot be combined with VAULTDROP_TLS_CERT")
+		}
+		if c.TLSAutocertCache == "" {
+			fail("VAULTDROP_TLS_AUTOCERT_CACHE", "required with VAULTDROP_TLS_AUTOCERT_DOMAINS so certificates survive restarts")
+		}
+	}
+	if c.TLSHTTPAddress != "" {
+		if !c.TLSEnabled() {
+			fail("VAULTDROP_TLS_HTTP_ADDRESS", "only applies when TLS is configured")
+		} else if _, _, err := net.SplitHostPort(c.TLSHTTPAddress); err != nil {
+			fail("VAULTDROP_TLS_HTTP_ADDRESS", "want host:port, got %q", c.TLSHTTPAddress)
+		}
+	}
+	if c.HTTPReadHeaderTimeout <= 0 {
+		fail("VAULTDROP_HTTP_READ_HEADER_TIMEOUT", "must be positive, got %s", c.HTTPReadHeaderTimeout)
+	}
+	if c.HTTPIdleTimeout <= 0 {
+		fail("VAULTDROP_HTTP_IDLE_TIMEOUT", "must be positive, got %s", c.HTTPIdleTimeout)
+	}
+	// Below a few KiB ordinary browsers' cookies and auth headers no longer
+	// fit; above 16 MiB the limit stops protecting anything.
+	if c.HTTPMaxHeaderBytes < 4<<10 || c.HTTPMaxHeaderBytes > 16<<20 {
+		fail("VAULTDROP_HTTP_MAX_HEADER_BYTES", "must be between 4KiB and 16MiB, got %d bytes", c.HTTPMaxHeaderBytes)
+	}
+	for _, scheme := range c.URLIngestSchemes {
+		if scheme != "http" && scheme != "https" {
+			fail("VAULTDROP_URL_INGEST_SCHEMES", "only http and https are supported, got %q", scheme)
+		}
+	}
+	if c.URLIngestTimeout <= 0 {
+		fail("VAULTDROP_URL_INGEST_TIMEOUT", "must be positive, got %s", c.URLIngestTimeout)
+	}
+	if strings.HasPrefix(c.S3EventsPrefix, "uploads/") {
+		fail("VAULTDROP_S3_EVENTS_PREFIX", "uploads/ holds the API's own uploads, which already have documents")
+	}
+	switch c.EventsDriver {
+	case "":
+	case "nats", "kafka":
+		if c.EventsURL == "" {
+			fail("VAULTDROP_EVENTS_URL", "required with VAULTDROP_EVENTS_DRIVER=%s", c.EventsDriver)
+		}
+		if c.EventsTopic == "" {
+			fail("VAULTDROP_EVENTS_TOPIC", "required with VAULTDROP_EVENTS_DRIVER=%s", c.EventsDriver)
+		}
+	default:
+		fail("VAULTDROP_EVENTS_DRIVER", "want nats or kafka, got %q", c.EventsDriver)
+	}
+	if len(c.MasterKeys) > 0 {
+		for id, key := range c.MasterKeys {
+			if len(key) != 32 {
+				fail("VAULTDROP_MASTER_KEYS", "key %q must be 32 bytes, base64-encoded (create one with `vaultdrop secret generate --format base64`)", id)
+			}
+		}
+		if _, ok := c.MasterKeys[c.MasterKeyID]; !ok {
+			fail("VAULTDROP_MASTER_KEY_ID", "must name one of the keys in VAULTDROP_MASTER_KEYS, got %q", c.MasterKeyID)
+		}
+		if !c.S3UseSSL {
+			fail("VAULTDROP_S3_USE_SSL", "required with VAULTDROP_MASTER_KEYS: the store only accepts encryption keys over TLS")
+		}
+	} else if c.MasterKeyID != "" {
+		fail("VAULTDROP_MASTER_KEY_ID", "set without VAULTDROP_MASTER_KEYS")
+	}
+	// Uploads in flight have temp files and objects without a row yet, and a
+	// worker's spool file lives as long as its extraction; the grace period
+	// must outlast the slowest of them.
+	if c.CleanupInterval > 0 {
+		if c.CleanupGrace < time.Hour {
+			fail("VAULTDROP_CLEANUP_GRACE", "must be at least 1h, got %s", c.CleanupGrace)
+		} else if c.CleanupGrace <= c.ExtractTimeout {
+			fail("VAULTDROP_CLEANUP_GRACE", "must be longer than VAULTDROP_EXTRACT_TIMEOUT (%s), got %s", c.ExtractTimeout, c.CleanupGrace)
+		}
+	}
+	// POST /exports takes at most 1000 documents; 0 makes every export a
+	// background job.
+	if c.ExportSyncLimit < 0 || c.ExportSyncLimit > 1000 {
+		fail("VAULTDROP_EXPORT_SYNC_LIMIT", "must be between 0 and 1000, got %d", c.ExportSyncLimit)
+	}
+	if c.Production && c.generatedSecret {
+		fail("VAULTDROP_SIGNING_SECRET", "required in production (create one with `vaultdrop secret generate`)")
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,140 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadValidation(t *testing.T) {
+	t.Setenv("VAULTDROP_S3_ENDPOINT", "http://minio:9000")
+	t.Setenv("VAULTDROP_S3_RAW_BUCKET", "uploads/raw")
+	t.Setenv("VAULTDROP_SIGNED_TTL", "30d")
+	t.Setenv("VAULTDROP_API_KEYS", "alice:k1")
+	t.Setenv("VAULTDROP_ADMINS", "alice,bob")
+	t.Setenv("VAULTDROP_PRODUCTION", "true")
+	t.Setenv("VAULTDROP_TASK_CONCURRENCY", "derive:ocr=0")
+	t.Setenv("VAULTDROP_TLS_CERT", "server.crt")
+	t.Setenv("VAULTDROP_TLS_AUTOCERT_DOMAINS", "files.example.com")
+	t.Setenv("VAULTDROP_HTTP_MAX_HEADER_BYTES", "1KiB")
+	t.Setenv("VAULTDROP_PDF_MAX_PAGES", "-1")
+	t.Setenv("VAULTDROP_EXTRACT_BACKEND", "mupdf")
+	t.Setenv("VAULTDROP_S3_PART_SIZE", "1MiB")
+	t.Setenv("VAULTDROP_S3_COLD_AFTER_DAYS", "30")
+	t.Setenv("VAULTDROP_S3_NONCURRENT_EXPIRE_AFTER_DAYS", "7")
+	t.Setenv("VAULTDROP_S3_OBJECT_TAGS", "tenant=acme;corp")
+	t.Setenv("VAULTDROP_DB_MIN_CONNS", "20")
+	t.Setenv("VAULTDROP_DATABASE_READ_URL", "mysql://replica/vaultdrop")
+	t.Setenv("VAULTDROP_EVENTS_DRIVER", "rabbitmq")
+	t.Setenv("VAULTDROP_MASTER_KEYS", "2025=c2hvcnQ=")
+	t.Setenv("VAULTDROP_CLEANUP_INTERVAL", "6h")
+	t.Setenv("VAULTDROP_CLEANUP_GRACE", "5m")
+	t.Setenv("VAULTDROP_UPLOAD_MEMORY_THRESHOLD", "1GiB")
+	t.Setenv("VAULTDROP_EXPORT_SYNC_LIMIT", "-1")
+
+	_, err := Load()
+	if err == nil {
+		t.Fatal("expected validation errors")
+	}
+	for _, want := range []string{
+		"VAULTDROP_S3_ENDPOINT",
+		"VAULTDROP_S3_RAW_BUCKET",
+		"VAULTDROP_SIGNED_TTL",
+		`principal "bob"`,
+		"VAULTDROP_SIGNING_SECRET",
+		"derive:ocr must be at least 1",
+		"VAULTDROP_TLS_KEY",
+		"VAULTDROP_TLS_AUTOCERT_DOMAINS",
+		"VAULTDROP_HTTP_MAX_HEADER_BYTES",
+		"VAULTDROP_PDF_MAX_PAGES",
+		"VAULTDROP_EXTRACT_BACKEND",
+		"VAULTDROP_S3_PART_SIZE",
+		"VAULTDROP_S3_COLD_STORAGE_CLASS",
+		"VAULTDROP_S3_OBJECT_TAGS",
+		"VAULTDROP_S3_NONCURRENT_EXPIRE_AFTER_DAYS",
+		"VAULTDROP_DB_MIN_CONNS",
+		"VAULTDROP_DATABASE_READ_URL",
+		"VAULTDROP_EVENTS_DRIVER",
+		"VAULTDROP_MASTER_KEYS",
+		"VAULTDROP_S3_USE_SSL",
+		"VAULTDROP_CLEANUP_GRACE",
+		"VAULTDROP_UPLOAD_MEMORY_THRESHOLD",
+		"VAULTDROP_EXPORT_SYNC_LIMIT",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error should mention %s, got:\n%v", want, err)
+		}
+	}
+	if strings.Contains(err.Error(), `principal "alice"`) {
+		t.Errorf("alice has a key and should not be reported:\n%v", err)
+	}
+
+	t.Setenv("VAULTDROP_S3_ENDPOINT", "minio:9000")
+	t.Setenv("VAULTDROP_S3_RAW_BUCKET", "vaultdrop-raw")
+	t.Setenv("VAULTDROP_SIGNED_TTL", "1h")
+	t.Setenv("VAULTDROP_ADMINS", "alice")
+	t.Setenv("VAULTDROP_SIGNING_SECRET", "0123456789abcdef0123456789abcdef")
+	t.Setenv("VAULTDROP_TASK_CONCURRENCY", "derive:ocr=1")
+	t.Setenv("VAULTDROP_TLS_KEY", "server.key")
+	t.Setenv("VAULTDROP_TLS_AUTOCERT_DOMAINS", "")
+	t.Setenv("VAULTDROP_HTTP_MAX_HEADER_BYTES", "")
+	t.Setenv("VAULTDROP_PDF_MAX_PAGES", "0")
+	t.Setenv("VAULTDROP_EXTRACT_BACKEND", "pdftotext")
+	t.Setenv("VAULTDROP_S3_PART_SIZE", "64MiB")
+	t.Setenv("VAULTDROP_S3_COLD_STORAGE_CLASS", "GLACIER")
+	t.Setenv("VAULTDROP_S3_VERSIONING", "true")
+	t.Setenv("VAULTDROP_S3_OBJECT_TAGS", "tenant=acme, retention-class=legal")
+	t.Setenv("VAULTDROP_DB_MIN_CONNS", "2")
+	t.Setenv("VAULTDROP_DATABASE_READ_URL", "postgres://vaultdrop@replica:5432/vaultdrop")
+	t.Setenv("VAULTDROP_EVENTS_DRIVER", "nats")
+	t.Setenv("VAULTDROP_EVENTS_URL", "nats://localhost:4222")
+	t.Setenv("VAULTDROP_MASTER_KEYS", "2025=AQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQE=,2026=AgICAgICAgICAgICAgICAgICAgICAgICAgICAgICAgI=")
+	t.Setenv("VAULTDROP_MASTER_KEY_ID", "2026")
+	t.Setenv("VAULTDROP_S3_USE_SSL", "true")
+	t.Setenv("VAULTDROP_CLEANUP_GRACE", "")
+	t.Setenv("VAULTDROP_UPLOAD_MEMORY_THRESHOLD", "256KiB")
+	t.Setenv("VAULTDROP_EXPORT_SYNC_LIMIT", "0")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("valid config rejected: %v", err)
+	}
+	if cfg.TaskConcurrency["derive:ocr"] != 1 || cfg.QueueWeights["extract"] != 6 {
+		t.Errorf("unexpected task settings: %v %v", cfg.TaskConcurrency, cfg.QueueWeights)
+	}
+	if cfg.S3ObjectTags["tenant"] != "acme" || cfg.S3ObjectTags["retention-class"] != "legal" {
+		t.Errorf("S3ObjectTags = %v", cfg.S3ObjectTags)
+	}
+	if cfg.UploadMemoryThreshold != 256<<10 {
+		t.Errorf("UploadMemoryThreshold = %d", cfg.UploadMemoryThreshold)
+	}
+	if len(cfg.MasterKeys["2026"]) != 32 || cfg.MasterKeyID != "2026" {
+		t.Errorf("master keys: %d keys, current %q", len(cfg.MasterKeys), cfg.MasterKeyID)
+	}
+	if !cfg.TLSEnabled() || len(cfg.TLSAutocertDomains) != 0 {
+		t.Errorf("TLS settings: enabled=%v domains=%q", cfg.TLSEnabled(), cfg.TLSAutocertDomains)
+	}
+	if srv := cfg.HTTPServer(":0", nil); srv.ReadHeaderTimeout != 10*time.Second || srv.MaxHeaderBytes != 64<<10 {
+		t.Errorf("HTTPServer limits: header timeout %s, max header bytes %d", srv.ReadHeaderTimeout, srv.MaxHeaderBytes)
+	}
+}
+
+func TestCleanupGraceOutlastsExtraction(t *testing.T) {
+	t.Setenv("VAULTDROP_CLEANUP_INTERVAL", "6h")
+	t.Setenv("VAULTDROP_EXTRACT_TIMEOUT", "2h")
+	for grace, ok := range map[string]bool{"1h": false, "2h": false, "3h": true} {
+		t.Setenv("VAULTDROP_CLEANUP_GRACE", grace)
+		_, err := Load()
+		if ok && err != nil {
+			t.Errorf("grace %s rejected: %v", grace, err)
+		}
+		if !ok && (err == nil || !strings.Contains(err.Error(), "longer than VAULTDROP_EXTRACT_TIMEOUT")) {
+			t.Errorf("grace %s: error %v, want it to outlast the extraction timeout", grace, err)
+		}
+	}
+	// Without the cleanup the grace does not matter.
+	t.Setenv("VAULTDROP_CLEANUP_INTERVAL", "")
+	t.Setenv("VAULTDROP_CLEANUP_GRACE", "1h")
+	if _, err := Load(); err != nil {
+		t.Errorf("grace without cleanup rejected: %v", err)
+	}
+}
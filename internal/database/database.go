@@ -2,42 +2,97 @@ package database
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-// Connect opens a pgx connection pool using the provided DSN.
-func Connect(ctx context.Context, dsn string) (*pgxpool.Pool, error) {
-	cfg, err := pgxpool.ParseConfig(dsn)
+const (
+	// SchemaVersion is the schema version this build creates and expects. It
+	// must match the highest migration in migrations/.
+	SchemaVersion = 28
+	// MinCompatibleVersion is the oldest build schema version that can keep
+	// running against a database at SchemaVersion. Bump it together with
+	// SchemaVersion whenever a change is not additive (dropped or renamed
+	// columns, tightened constraints) so older replicas refuse to start.
+	// Version 24 moved the object key, file name and pipeline out of extract
+	// tasks, which older workers cannot run.
+	MinCompatibleVersion = 24
+)
+
+// ErrIncompatibleSchema is returned by CheckSchema when this build must not
+// run against the current database.
+var ErrIncompatibleSchema = errors.New("incompatible database schema")
+
+// SchemaInfo describes the schema version recorded in the database.
+type SchemaInfo struct {
+	Version       int
+	MinCompatible int
+}
+
+// ReadSchemaInfo returns the recorded schema version, or nil when the
+// database has not been initialised yet.
+func ReadSchemaInfo(ctx context.Context, pool *pgxpool.Pool) (*SchemaInfo, error) {
+	var exists bool
+	if err := pool.QueryRow(ctx, `SELECT to_regclass('schema_info') IS NOT NULL`).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("check schema_info: %w", err)
+	}
+	if !exists {
+		return nil, nil
+	}
+	var info SchemaInfo
+	err := pool.QueryRow(ctx, `SELECT version, min_compatible FROM schema_info WHERE id`).Scan(&info.Version, &info.MinCompatible)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
 	if err != nil {
-		return nil, fmt.Errorf("parse dsn: %w", err)
+		return nil, fmt.Errorf("read schema_info: %w", err)
 	}
-	cfg.MaxConns = 8
-	cfg.MaxConnIdleTime = 5 * time.Minute
-	return pgxpool.NewWithConfig(ctx, cfg)
+	return &info, nil
 }
 
-// EnsureSchema creates the documents table if needed. Having the migration in
-// code keeps the demo self-contained so docker-compose can bootstrap everything.
-func EnsureSchema(ctx context.Context, pool *pgxpool.Pool) error {
-	const stmt = `
-CREATE TABLE IF NOT EXISTS documents (
-	id TEXT PRIMARY KEY,
-	file_name TEXT NOT NULL,
-	object_key TEXT NOT NULL,
-	processed_key TEXT,
-	status TEXT NOT NULL,
-	content TEXT,
-	error_message TEXT,
-	created_at TIMESTAMPTZ NOT NULL,
-	updated_at TIMESTAMPTZ NOT NULL
-);
-CREATE INDEX IF NOT EXISTS idx_documents_status ON documents(status);`
-	_, err := pool.Exec(ctx, stmt)
+// CheckSchema verifies this build can safely run against the database. A
+// database older than SchemaVersion is fine because migrations upgrade it; a
+// newer one is only accepted while its min_compatible still covers this build.
+// Call it before Migrate so an outdated replica never runs DDL against a
+// schema it does not understand.
+func CheckSchema(ctx context.Context, pool *pgxpool.Pool) error {
+	info, err := ReadSchemaInfo(ctx, pool)
 	if err != nil {
-		return fmt.Errorf("ensure schema: %w", err)
+		return err
+	}
+	return checkCompatible(info)
+}
+
+// CheckSchemaCurrent is CheckSchema without the allowance for older
+// databases: it also refuses a database that has no schema or one older than
+// SchemaVersion. Call it once migrations had their chance to run, and instead
+// of CheckSchema where they never do.
+func CheckSchemaCurrent(ctx context.Context, pool *pgxpool.Pool) error {
+	info, err := ReadSchemaInfo(ctx, pool)
+	if err != nil {
+		return err
+	}
+	return checkCurrent(info)
+}
+
+func checkCompatible(info *SchemaInfo) error {
+	if info != nil && info.Version > SchemaVersion && info.MinCompatible > SchemaVersion {
+		return fmt.Errorf("%w: database is at version %d (requires build version >= %d), this build supports %d",
+			ErrIncompatibleSchema, info.Version, info.MinCompatible, SchemaVersion)
 	}
 	return nil
 }
+
+func checkCurrent(info *SchemaInfo) error {
+	if info == nil {
+		return fmt.Errorf("%w: database has no schema; run vaultdrop migrate up", ErrIncompatibleSchema)
+	}
+	if info.Version < SchemaVersion {
+		return fmt.Errorf("%w: database is at version %d, this build needs %d; run vaultdrop migrate up",
+			ErrIncompatibleSchema, info.Version, SchemaVersion)
+	}
+	return checkCompatible(info)
+}
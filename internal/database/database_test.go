@@ -0,0 +1,27 @@
+package database
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCheckSchemaInfo(t *testing.T) {
+	for _, tc := range []struct {
+		name                string
+		info                *SchemaInfo
+		compatible, current bool
+	}{
+		{"missing row", nil, true, false},
+		{"older version", &SchemaInfo{Version: SchemaVersion - 1, MinCompatible: MinCompatibleVersion}, true, false},
+		{"same version", &SchemaInfo{Version: SchemaVersion, MinCompatible: MinCompatibleVersion}, true, true},
+		{"newer, still compatible", &SchemaInfo{Version: SchemaVersion + 1, MinCompatible: SchemaVersion}, true, true},
+		{"newer, incompatible", &SchemaInfo{Version: SchemaVersion + 1, MinCompatible: SchemaVersion + 1}, false, false},
+	} {
+		if err := checkCompatible(tc.info); (err == nil) != tc.compatible || (err != nil && !errors.Is(err, ErrIncompatibleSchema)) {
+			t.Errorf("%s: checkCompatible = %v, want compatible %v", tc.name, err, tc.compatible)
+		}
+		if err := checkCurrent(tc.info); (err == nil) != tc.current || (err != nil && !errors.Is(err, ErrIncompatibleSchema)) {
+			t.Errorf("%s: checkCurrent = %v, want current %v", tc.name, err, tc.current)
+		}
+	}
+}
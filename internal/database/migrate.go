@@ -0,0 +1,349 @@
+package database
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+//go:embed migrations/*.sql
+var migrationFS embed.FS
+
+// Migration is one numbered schema change loaded from migrations/ as a
+// NNNN_name.up.sql / NNNN_name.down.sql pair.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus reports whether a migration has been applied.
+type MigrationStatus struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// Migrations returns the embedded migrations in version order.
+func Migrations() ([]Migration, error) {
+	entries, err := fs.ReadDir(migrationFS, "migrations")
+	if err != nil {
+		return nil, fmt.Errorf("read migrations: %w", err)
+	}
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		name := entry.Name()
+		base, direction, ok := splitMigrationName(name)
+		if !ok {
+			return nil, fmt.Errorf("invalid migration file name %q", name)
+		}
+		prefix, label, _ := strings.Cut(base, "_")
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %q", name)
+		}
+		data, err := migrationFS.ReadFile(path.Join("migrations", name))
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", name, err)
+		}
+		m := byVersion[version]
+		if m == nil {
+			m = &Migration{Version: version, Name: label}
+			byVersion[version] = m
+		}
+		if direction == "up" {
+			m.Up = string(data)
+		} else {
+			m.Down = string(data)
+		}
+	}
+	out := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %04d_%s needs both up and down files", m.Version, m.Name)
+		}
+		out = append(out, *m)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Version < out[j].Version })
+	if len(out) == 0 || out[len(out)-1].Version != SchemaVersion {
+		return nil, fmt.Errorf("latest migration does not match SchemaVersion %d", SchemaVersion)
+	}
+	return out, nil
+}
+
+func splitMigrationName(name string) (string, string, bool) {
+	for _, direction := range []string{"up", "down"} {
+		if base, ok := strings.CutSuffix(name, "."+direction+".sql"); ok {
+			return base, direction, true
+		}
+	}
+	return "", "", false
+}
+
+// Migrator applies and rolls back the embedded migrations.
+type Migrator struct {
+	pool       *pgxpool.Pool
+	migrations []Migration
+}
+
+// NewMigrator constructs a Migrator.
+func NewMigrator(pool *pgxpool.Pool) (*Migrator, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{pool: pool, migrations: migrations}, nil
+}
+
+// Migrate applies every pending migration.
+func Migrate(ctx context.Context, pool *pgxpool.Pool) error {
+	m, err := NewMigrator(pool)
+	if err != nil {
+		return err
+	}
+	_, err = m.Up(ctx, 0)
+	return err
+}
+
+// Status lists every known migration and when it was applied.
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]MigrationStatus, 0, len(m.migrations))
+	for _, mig := range m.migrations {
+		status := MigrationStatus{Migration: mig}
+		if at, ok := applied[mig.Version]; ok {
+			at := at
+			status.AppliedAt = &at
+		}
+		out = append(out, status)
+	}
+	return out, nil
+}
+
+// PlanUp returns the migrations Up would apply. A target of 0 means latest.
+func (m *Migrator) PlanUp(ctx context.Context, target int) ([]Migration, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var plan []Migration
+	for _, mig := range m.migrations {
+		if target > 0 && mig.Version > target {
+			break
+		}
+		if _, ok := applied[mig.Version]; !ok {
+			plan = append(plan, mig)
+		}
+	}
+	return plan, nil
+}
+
+// PlanDown returns the migrations Down would roll back, newest first.
+func (m *Migrator) PlanDown(ctx context.Context, steps int) ([]Migration, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var plan []Migration
+	for i := len(m.migrations) - 1; i >= 0 && len(plan) < steps; i-- {
+		if _, ok := applied[m.migrations[i].Version]; ok {
+			plan = append(plan, m.migrations[i])
+		}
+	}
+	return plan, nil
+}
+
+// migrationLockID is the Postgres advisory lock key serialising migrations.
+const migrationLockID int64 = 0x76646d6967 // "vdmig"
+
+// withLock runs fn while holding the migration advisory lock on a dedicated
+// connection, so replicas starting together apply each migration once: the
+// others wait, then find nothing left to do. The lock is released with the
+// session if the process dies.
+func (m *Migrator) withLock(ctx context.Context, fn func() error) error {
+	conn, err := m.pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("acquire migration connection: %w", err)
+	}
+	defer conn.Release()
+	if _, err := conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, migrationLockID); err != nil {
+		return fmt.Errorf("take migration lock: %w", err)
+	}
+	defer func() {
+		// Use a fresh context: ctx may be what cancelled us, and a lock left
+		// on a pooled connection would block every later migration.
+		unlockCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if _, err := conn.Exec(unlockCtx, `SELECT pg_advisory_unlock($1)`, migrationLockID); err != nil {
+			conn.Conn().Close(unlockCtx)
+		}
+	}()
+	return fn()
+}
+
+// Up applies pending migrations up to target (0 for latest), each in its own
+// transaction, and returns what was applied. It holds the migration lock
+// throughout.
+func (m *Migrator) Up(ctx context.Context, target int) (applied []Migration, err error) {
+	err = m.withLock(ctx, func() error {
+		applied, err = m.up(ctx, target)
+		return err
+	})
+	return applied, err
+}
+
+func (m *Migrator) up(ctx context.Context, target int) ([]Migration, error) {
+	if err := m.ensureTables(ctx); err != nil {
+		return nil, err
+	}
+	plan, err := m.PlanUp(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	for i, mig := range plan {
+		err := m.apply(ctx, mig.Up, `INSERT INTO schema_migrations (version, name, applied_at) VALUES ($1,$2,$3)`,
+			mig.Version, mig.Name, time.Now().UTC())
+		if err != nil {
+			return plan[:i], fmt.Errorf("apply migration %04d_%s: %w", mig.Version, mig.Name, err)
+		}
+	}
+	if err := m.recordVersion(ctx, false); err != nil {
+		return plan, err
+	}
+	return plan, nil
+}
+
+// Down rolls back the newest steps applied migrations, holding the migration
+// lock.
+func (m *Migrator) Down(ctx context.Context, steps int) (rolledBack []Migration, err error) {
+	err = m.withLock(ctx, func() error {
+		rolledBack, err = m.down(ctx, steps)
+		return err
+	})
+	return rolledBack, err
+}
+
+func (m *Migrator) down(ctx context.Context, steps int) ([]Migration, error) {
+	if err := m.ensureTables(ctx); err != nil {
+		return nil, err
+	}
+	plan, err := m.PlanDown(ctx, steps)
+	if err != nil {
+		return nil, err
+	}
+	for i, mig := range plan {
+		if err := m.apply(ctx, mig.Down, `DELETE FROM schema_migrations WHERE version=$1`, mig.Version); err != nil {
+			return plan[:i], fmt.Errorf("roll back migration %04d_%s: %w", mig.Version, mig.Name, err)
+		}
+	}
+	if err := m.recordVersion(ctx, true); err != nil {
+		return plan, err
+	}
+	return plan, nil
+}
+
+func (m *Migrator) apply(ctx context.Context, sql, bookkeeping string, args ...interface{}) error {
+	tx, err := m.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	if _, err := tx.Exec(ctx, sql); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, bookkeeping, args...); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// recordVersion stores the highest applied migration in schema_info. Upgrades
+// never lower the recorded version so a still-compatible older replica cannot
+// hide a newer schema; explicit rollbacks do.
+func (m *Migrator) recordVersion(ctx context.Context, downgrade bool) error {
+	var version int
+	if err := m.pool.QueryRow(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version); err != nil {
+		return fmt.Errorf("read applied version: %w", err)
+	}
+	stmt := `
+		INSERT INTO schema_info (id, version, min_compatible, updated_at)
+		VALUES (TRUE, $1, $2, $3)
+		ON CONFLICT (id) DO UPDATE
+		SET version = EXCLUDED.version, min_compatible = EXCLUDED.min_compatible, updated_at = EXCLUDED.updated_at
+		WHERE schema_info.version < EXCLUDED.version`
+	minCompatible := MinCompatibleVersion
+	if downgrade {
+		stmt = `
+		INSERT INTO schema_info (id, version, min_compatible, updated_at)
+		VALUES (TRUE, $1, $2, $3)
+		ON CONFLICT (id) DO UPDATE
+		SET version = EXCLUDED.version, min_compatible = LEAST(schema_info.min_compatible, EXCLUDED.version), updated_at = EXCLUDED.updated_at`
+		if version < minCompatible {
+			minCompatible = version
+		}
+	}
+	if _, err := m.pool.Exec(ctx, stmt, version, minCompatible, time.Now().UTC()); err != nil {
+		return fmt.Errorf("record schema version: %w", err)
+	}
+	return nil
+}
+
+// ensureTables creates the bookkeeping tables. Planning and status never call
+// it so dry runs leave the database untouched.
+func (m *Migrator) ensureTables(ctx context.Context) error {
+	const bootstrap = `
+CREATE TABLE IF NOT EXISTS schema_migrations (
+	version INTEGER PRIMARY KEY,
+	name TEXT NOT NULL,
+	applied_at TIMESTAMPTZ NOT NULL
+);
+CREATE TABLE IF NOT EXISTS schema_info (
+	id BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (id),
+	version INTEGER NOT NULL,
+	min_compatible INTEGER NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL
+);`
+	if _, err := m.pool.Exec(ctx, bootstrap); err != nil {
+		return fmt.Errorf("create migration tables: %w", err)
+	}
+	return nil
+}
+
+func (m *Migrator) applied(ctx context.Context) (map[int]time.Time, error) {
+	out := make(map[int]time.Time)
+	var exists bool
+	if err := m.pool.QueryRow(ctx, `SELECT to_regclass('schema_migrations') IS NOT NULL`).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("check schema_migrations: %w", err)
+	}
+	if !exists {
+		return out, nil
+	}
+	rows, err := m.pool.Query(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("select applied migrations: %w", err)
+	}
+	var (
+		version   int
+		appliedAt time.Time
+	)
+	_, err = pgx.ForEachRow(rows, []any{&version, &appliedAt}, func() error {
+		out[version] = appliedAt
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("scan applied migrations: %w", err)
+	}
+	return out, nil
+}
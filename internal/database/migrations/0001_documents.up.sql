@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS documents (
+	id TEXT PRIMARY KEY,
+	file_name TEXT NOT NULL,
+	object_key TEXT NOT NULL,
+	processed_key TEXT,
+	status TEXT NOT NULL,
+	content TEXT,
+	error_message TEXT,
+	created_at TIMESTAMPTZ NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_documents_status ON documents(status);
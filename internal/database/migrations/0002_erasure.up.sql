@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS document_tombstones (
+	document_id TEXT PRIMARY KEY,
+	erasure_id TEXT NOT NULL,
+	erased_at TIMESTAMPTZ NOT NULL
+);
+CREATE TABLE IF NOT EXISTS erasure_requests (
+	id TEXT PRIMARY KEY,
+	subject_hash TEXT NOT NULL,
+	document_ids TEXT[] NOT NULL,
+	status TEXT NOT NULL,
+	report JSONB,
+	error_message TEXT,
+	requested_at TIMESTAMPTZ NOT NULL,
+	completed_at TIMESTAMPTZ
+);
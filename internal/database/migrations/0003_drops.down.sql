@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_documents_drop_id;
+ALTER TABLE documents DROP COLUMN IF EXISTS drop_id;
+DROP TABLE IF EXISTS drops;
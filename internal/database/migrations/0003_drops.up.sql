@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS drops (
+	id TEXT PRIMARY KEY,
+	token_hash TEXT NOT NULL UNIQUE,
+	owner_id TEXT NOT NULL,
+	label TEXT NOT NULL DEFAULT '',
+	max_uploads INTEGER NOT NULL,
+	upload_count INTEGER NOT NULL DEFAULT 0,
+	expires_at TIMESTAMPTZ NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL
+);
+ALTER TABLE documents ADD COLUMN IF NOT EXISTS drop_id TEXT;
+CREATE INDEX IF NOT EXISTS idx_documents_drop_id ON documents(drop_id);
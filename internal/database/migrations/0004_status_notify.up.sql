@@ -0,0 +1,12 @@
+CREATE OR REPLACE FUNCTION notify_document_status() RETURNS trigger AS $$
+BEGIN
+	IF TG_OP = 'INSERT' OR NEW.status IS DISTINCT FROM OLD.status THEN
+		PERFORM pg_notify('document_status', json_build_object('id', NEW.id, 'status', NEW.status)::text);
+	END IF;
+	RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+DROP TRIGGER IF EXISTS documents_status_notify ON documents;
+CREATE TRIGGER documents_status_notify
+	AFTER INSERT OR UPDATE OF status ON documents
+	FOR EACH ROW EXECUTE FUNCTION notify_document_status();
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS upload_rejections (
+	id BIGSERIAL PRIMARY KEY,
+	reason TEXT NOT NULL,
+	detail TEXT NOT NULL DEFAULT '',
+	content_type TEXT NOT NULL DEFAULT '',
+	size_bytes BIGINT NOT NULL DEFAULT 0,
+	client_ip TEXT NOT NULL DEFAULT '',
+	user_agent TEXT NOT NULL DEFAULT '',
+	principal TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_upload_rejections_created_at ON upload_rejections(created_at);
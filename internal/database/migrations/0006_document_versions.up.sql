@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS document_versions (
+	document_id TEXT NOT NULL REFERENCES documents(id) ON DELETE CASCADE,
+	version INT NOT NULL,
+	content TEXT NOT NULL,
+	content_sha256 TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL,
+	PRIMARY KEY (document_id, version)
+);
+INSERT INTO document_versions (document_id, version, content, content_sha256, created_at)
+SELECT id, 1, content, encode(sha256(convert_to(content, 'UTF8')), 'hex'), updated_at
+FROM documents
+WHERE status = 'completed' AND content IS NOT NULL
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_documents_created_at;
+ALTER TABLE documents DROP COLUMN IF EXISTS text_purged_at;
+ALTER TABLE documents DROP COLUMN IF EXISTS raw_purged_at;
@@ -0,0 +1,3 @@
+ALTER TABLE documents ADD COLUMN IF NOT EXISTS raw_purged_at TIMESTAMPTZ;
+ALTER TABLE documents ADD COLUMN IF NOT EXISTS text_purged_at TIMESTAMPTZ;
+CREATE INDEX IF NOT EXISTS idx_documents_created_at ON documents(created_at);
@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS document_artifacts (
+	document_id TEXT NOT NULL REFERENCES documents(id) ON DELETE CASCADE,
+	stage TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL,
+	PRIMARY KEY (document_id, stage)
+);
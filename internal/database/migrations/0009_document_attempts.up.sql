@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS document_attempts (
+	id BIGSERIAL PRIMARY KEY,
+	document_id TEXT NOT NULL REFERENCES documents(id) ON DELETE CASCADE,
+	task_id TEXT NOT NULL DEFAULT '',
+	retry INT NOT NULL DEFAULT 0,
+	worker_id TEXT NOT NULL,
+	started_at TIMESTAMPTZ NOT NULL,
+	finished_at TIMESTAMPTZ,
+	error TEXT
+);
+CREATE INDEX IF NOT EXISTS idx_document_attempts_document ON document_attempts(document_id, started_at);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_documents_owner_created;
+ALTER TABLE documents DROP COLUMN IF EXISTS owner_id;
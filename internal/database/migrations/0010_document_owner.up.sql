@@ -0,0 +1,5 @@
+ALTER TABLE documents ADD COLUMN IF NOT EXISTS owner_id TEXT;
+-- Documents received through a drop belong to the drop's owner. Older direct
+-- uploads keep a NULL owner and are only visible to admins.
+UPDATE documents d SET owner_id = dr.owner_id FROM drops dr WHERE d.drop_id = dr.id AND d.owner_id IS NULL;
+CREATE INDEX IF NOT EXISTS idx_documents_owner_created ON documents(owner_id, created_at, id);
@@ -0,0 +1 @@
+ALTER TABLE documents ADD COLUMN IF NOT EXISTS process_at TIMESTAMPTZ;
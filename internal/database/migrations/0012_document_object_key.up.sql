@@ -0,0 +1,3 @@
+-- Objects ingested from bucket notifications are matched to documents by
+-- key, and redelivered events must not create a second row.
+CREATE UNIQUE INDEX IF NOT EXISTS idx_documents_object_key ON documents (object_key);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_documents_metadata;
+ALTER TABLE documents DROP COLUMN IF EXISTS metadata;
@@ -0,0 +1,4 @@
+-- Client-supplied key/value pairs. jsonb_path_ops keeps the index small and
+-- serves the containment (@>) queries the list filter issues.
+ALTER TABLE documents ADD COLUMN IF NOT EXISTS metadata JSONB NOT NULL DEFAULT '{}';
+CREATE INDEX IF NOT EXISTS idx_documents_metadata ON documents USING GIN (metadata jsonb_path_ops);
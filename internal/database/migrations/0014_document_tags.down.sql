@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_documents_tags;
+ALTER TABLE documents DROP COLUMN IF EXISTS tags;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_documents_archivable;
+ALTER TABLE documents DROP COLUMN IF EXISTS archived_at;
+ALTER TABLE documents DROP COLUMN IF EXISTS archive_key;
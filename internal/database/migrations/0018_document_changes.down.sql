@@ -0,0 +1,6 @@
+DROP TRIGGER IF EXISTS documents_change_seq ON documents;
+DROP FUNCTION IF EXISTS bump_document_change_seq();
+DROP INDEX IF EXISTS idx_documents_updated_at;
+DROP INDEX IF EXISTS idx_documents_change_seq;
+ALTER TABLE documents DROP COLUMN IF EXISTS change_seq;
+DROP SEQUENCE IF EXISTS documents_change_seq;
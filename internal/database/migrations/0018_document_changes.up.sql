@@ -0,0 +1,19 @@
+CREATE SEQUENCE IF NOT EXISTS documents_change_seq;
+ALTER TABLE documents ADD COLUMN IF NOT EXISTS change_seq BIGINT;
+UPDATE documents d SET change_seq = s.seq
+	FROM (SELECT id, nextval('documents_change_seq') AS seq FROM (SELECT id FROM documents WHERE change_seq IS NULL ORDER BY updated_at, id) o) s
+	WHERE d.id = s.id;
+ALTER TABLE documents ALTER COLUMN change_seq SET DEFAULT nextval('documents_change_seq');
+ALTER TABLE documents ALTER COLUMN change_seq SET NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_documents_change_seq ON documents(change_seq);
+CREATE INDEX IF NOT EXISTS idx_documents_updated_at ON documents(updated_at);
+CREATE OR REPLACE FUNCTION bump_document_change_seq() RETURNS trigger AS $$
+BEGIN
+	NEW.change_seq := nextval('documents_change_seq');
+	RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+DROP TRIGGER IF EXISTS documents_change_seq ON documents;
+CREATE TRIGGER documents_change_seq
+	BEFORE UPDATE ON documents
+	FOR EACH ROW EXECUTE FUNCTION bump_document_change_seq();
@@ -0,0 +1,9 @@
+CREATE OR REPLACE FUNCTION bump_document_change_seq() RETURNS trigger AS $$
+BEGIN
+	NEW.change_seq := nextval('documents_change_seq');
+	RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+DROP INDEX IF EXISTS idx_documents_data_key_id;
+ALTER TABLE documents DROP COLUMN IF EXISTS data_key_id;
+ALTER TABLE documents DROP COLUMN IF EXISTS data_key;
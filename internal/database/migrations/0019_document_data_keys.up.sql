@@ -0,0 +1,14 @@
+ALTER TABLE documents ADD COLUMN IF NOT EXISTS data_key BYTEA;
+ALTER TABLE documents ADD COLUMN IF NOT EXISTS data_key_id TEXT;
+CREATE INDEX IF NOT EXISTS idx_documents_data_key_id ON documents(data_key_id) WHERE data_key_id IS NOT NULL;
+-- Rewrapping a data key changes nothing clients can see, so it does not
+-- move the document in the changefeed.
+CREATE OR REPLACE FUNCTION bump_document_change_seq() RETURNS trigger AS $$
+BEGIN
+	IF to_jsonb(NEW) - 'data_key' - 'data_key_id' - 'change_seq' = to_jsonb(OLD) - 'data_key' - 'data_key_id' - 'change_seq' THEN
+		RETURN NEW;
+	END IF;
+	NEW.change_seq := nextval('documents_change_seq');
+	RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
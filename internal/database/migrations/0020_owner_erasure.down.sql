@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_upload_rejections_principal;
+ALTER TABLE erasure_requests DROP COLUMN IF EXISTS done;
+ALTER TABLE erasure_requests DROP COLUMN IF EXISTS total;
+ALTER TABLE erasure_requests DROP COLUMN IF EXISTS owner_id;
@@ -0,0 +1,4 @@
+ALTER TABLE erasure_requests ADD COLUMN IF NOT EXISTS owner_id TEXT;
+ALTER TABLE erasure_requests ADD COLUMN IF NOT EXISTS total INTEGER;
+ALTER TABLE erasure_requests ADD COLUMN IF NOT EXISTS done INTEGER NOT NULL DEFAULT 0;
+CREATE INDEX IF NOT EXISTS idx_upload_rejections_principal ON upload_rejections(principal);
@@ -0,0 +1,3 @@
+-- The orphan cleanup walks API uploads in byte order next to the bucket
+-- listing; the unique index on object_key sorts by the database collation.
+CREATE INDEX IF NOT EXISTS idx_documents_upload_keys ON documents (object_key COLLATE "C") WHERE object_key LIKE 'uploads/%';
@@ -0,0 +1,10 @@
+-- Downloads and signed-URL issuances per document and UTC day, for
+-- GET /documents/{id}/analytics.
+CREATE TABLE IF NOT EXISTS document_access_stats (
+	document_id TEXT NOT NULL REFERENCES documents(id) ON DELETE CASCADE,
+	day DATE NOT NULL,
+	downloads BIGINT NOT NULL DEFAULT 0,
+	signed_urls BIGINT NOT NULL DEFAULT 0,
+	last_access_at TIMESTAMPTZ NOT NULL,
+	PRIMARY KEY (document_id, day)
+);
@@ -0,0 +1,16 @@
+-- ZIP exports built in the background for POST /exports. scope is the owner
+-- the documents were checked against, empty for admins.
+CREATE TABLE IF NOT EXISTS exports (
+	id TEXT PRIMARY KEY,
+	scope TEXT NOT NULL,
+	artifact TEXT NOT NULL,
+	document_ids TEXT[] NOT NULL,
+	status TEXT NOT NULL,
+	object_key TEXT,
+	size BIGINT,
+	manifest JSONB,
+	error_message TEXT,
+	created_at TIMESTAMPTZ NOT NULL,
+	completed_at TIMESTAMPTZ,
+	expires_at TIMESTAMPTZ NOT NULL
+);
@@ -0,0 +1,3 @@
+-- The extraction pipeline a reprocess asked for. Extract tasks carry only the
+-- document id, so their unique lock is per document, and read it from here.
+ALTER TABLE documents ADD COLUMN IF NOT EXISTS pipeline TEXT;
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_exports_document_ids;
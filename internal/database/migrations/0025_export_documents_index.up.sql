@@ -0,0 +1,2 @@
+-- Erasure finds the exports that hold an erased document.
+CREATE INDEX IF NOT EXISTS idx_exports_document_ids ON exports USING GIN (document_ids);
@@ -0,0 +1,2 @@
+ALTER TABLE exports DROP COLUMN IF EXISTS data_key_id;
+ALTER TABLE exports DROP COLUMN IF EXISTS data_key;
@@ -0,0 +1,4 @@
+-- Background exports hold decrypted raw uploads; with master keys configured
+-- each ZIP is encrypted with a data key of its own, wrapped like documents'.
+ALTER TABLE exports ADD COLUMN IF NOT EXISTS data_key BYTEA;
+ALTER TABLE exports ADD COLUMN IF NOT EXISTS data_key_id TEXT;
@@ -0,0 +1,14 @@
+DROP TRIGGER IF EXISTS documents_deletion ON documents;
+DROP FUNCTION IF EXISTS record_document_deletion();
+DROP TABLE IF EXISTS document_deletions;
+CREATE OR REPLACE FUNCTION bump_document_change_seq() RETURNS trigger AS $$
+BEGIN
+	IF to_jsonb(NEW) - 'data_key' - 'data_key_id' - 'change_seq' = to_jsonb(OLD) - 'data_key' - 'data_key_id' - 'change_seq' THEN
+		RETURN NEW;
+	END IF;
+	NEW.change_seq := nextval('documents_change_seq');
+	RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+DROP INDEX IF EXISTS idx_documents_changed_at;
+ALTER TABLE documents DROP COLUMN IF EXISTS changed_at;
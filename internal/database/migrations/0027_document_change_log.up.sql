@@ -0,0 +1,36 @@
+-- changed_at is set by the database whenever change_seq is, so the
+-- changefeed can hold back recent changes even when a write leaves
+-- updated_at alone (retention purges, archiving).
+ALTER TABLE documents ADD COLUMN IF NOT EXISTS changed_at TIMESTAMPTZ NOT NULL DEFAULT now();
+ALTER TABLE documents ALTER COLUMN changed_at SET DEFAULT clock_timestamp();
+CREATE INDEX IF NOT EXISTS idx_documents_changed_at ON documents(changed_at);
+CREATE OR REPLACE FUNCTION bump_document_change_seq() RETURNS trigger AS $$
+BEGIN
+	IF to_jsonb(NEW) - 'data_key' - 'data_key_id' - 'change_seq' - 'changed_at' = to_jsonb(OLD) - 'data_key' - 'data_key_id' - 'change_seq' - 'changed_at' THEN
+		RETURN NEW;
+	END IF;
+	NEW.change_seq := nextval('documents_change_seq');
+	NEW.changed_at := clock_timestamp();
+	RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Deleted documents, erased or removed, take a change_seq of their own so
+-- the changefeed reports them.
+CREATE TABLE IF NOT EXISTS document_deletions (
+	change_seq  BIGINT PRIMARY KEY DEFAULT nextval('documents_change_seq'),
+	document_id TEXT NOT NULL,
+	owner_id    TEXT,
+	deleted_at  TIMESTAMPTZ NOT NULL DEFAULT clock_timestamp()
+);
+CREATE INDEX IF NOT EXISTS idx_document_deletions_deleted_at ON document_deletions(deleted_at);
+CREATE OR REPLACE FUNCTION record_document_deletion() RETURNS trigger AS $$
+BEGIN
+	INSERT INTO document_deletions (document_id, owner_id) VALUES (OLD.id, OLD.owner_id);
+	RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+DROP TRIGGER IF EXISTS documents_deletion ON documents;
+CREATE TRIGGER documents_deletion
+	AFTER DELETE ON documents
+	FOR EACH ROW EXECUTE FUNCTION record_document_deletion();
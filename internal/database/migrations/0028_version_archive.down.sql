@@ -0,0 +1,5 @@
+-- Archived bodies cannot be brought back here; their versions are dropped.
+DELETE FROM document_versions WHERE content IS NULL;
+ALTER TABLE document_versions DROP COLUMN IF EXISTS size;
+ALTER TABLE document_versions DROP COLUMN IF EXISTS archive_key;
+ALTER TABLE document_versions ALTER COLUMN content SET NOT NULL;
@@ -0,0 +1,5 @@
+-- The archive sweep moves version bodies to object storage along with the
+-- document's text, leaving the key and the size behind.
+ALTER TABLE document_versions ALTER COLUMN content DROP NOT NULL;
+ALTER TABLE document_versions ADD COLUMN IF NOT EXISTS archive_key TEXT;
+ALTER TABLE document_versions ADD COLUMN IF NOT EXISTS size INT;
@@ -0,0 +1,95 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/config"
+)
+
+// Connect opens a pgx connection pool on cfg.DatabaseURL, sized and recycled
+// as the VAULTDROP_DB_* settings say.
+func Connect(ctx context.Context, cfg *config.Config) (*pgxpool.Pool, error) {
+	return connect(ctx, cfg.DatabaseURL, cfg)
+}
+
+// ConnectReplica opens a pool on cfg.DatabaseReadURL, sized like the primary
+// one, or returns nil when no read replica is configured. Connections are
+// opened on first use, so a replica that is down does not stop startup.
+func ConnectReplica(ctx context.Context, cfg *config.Config) (*pgxpool.Pool, error) {
+	if cfg.DatabaseReadURL == "" {
+		return nil, nil
+	}
+	pool, err := connect(ctx, cfg.DatabaseReadURL, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("read replica: %w", err)
+	}
+	return pool, nil
+}
+
+func connect(ctx context.Context, dsn string, cfg *config.Config) (*pgxpool.Pool, error) {
+	poolCfg, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("parse dsn: %w", err)
+	}
+	poolCfg.MaxConns = int32(cfg.DBMaxConns)
+	poolCfg.MinConns = int32(cfg.DBMinConns)
+	poolCfg.MaxConnLifetime = cfg.DBMaxConnLifetime
+	poolCfg.MaxConnIdleTime = cfg.DBMaxConnIdleTime
+	poolCfg.HealthCheckPeriod = cfg.DBHealthCheckPeriod
+	poolCfg.ConnConfig.Tracer = queryTimer{}
+	return pgxpool.NewWithConfig(ctx, poolCfg)
+}
+
+// PoolStat is the statistics of one pool, labelled with its name.
+type PoolStat struct {
+	Name string
+	Stat *pgxpool.Stat
+}
+
+// WritePoolMetrics writes the pools' statistics in the Prometheus text
+// format, one series per pool. A pool that keeps hitting its size shows as a
+// rising vaultdrop_db_pool_empty_acquire_total and acquire wait time.
+func WritePoolMetrics(w io.Writer, pools ...PoolStat) {
+	gauges := []struct {
+		name, help string
+		value      func(*pgxpool.Stat) int32
+	}{
+		{"vaultdrop_db_pool_max_conns", "Maximum size of the connection pool.", (*pgxpool.Stat).MaxConns},
+		{"vaultdrop_db_pool_total_conns", "Connections in the pool, in any state.", (*pgxpool.Stat).TotalConns},
+		{"vaultdrop_db_pool_acquired_conns", "Connections currently in use.", (*pgxpool.Stat).AcquiredConns},
+		{"vaultdrop_db_pool_idle_conns", "Connections idle in the pool.", (*pgxpool.Stat).IdleConns},
+		{"vaultdrop_db_pool_constructing_conns", "Connections being opened.", (*pgxpool.Stat).ConstructingConns},
+	}
+	for _, g := range gauges {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n", g.name, g.help, g.name)
+		for _, p := range pools {
+			fmt.Fprintf(w, "%s{pool=%q} %d\n", g.name, p.Name, g.value(p.Stat))
+		}
+	}
+	counters := []struct {
+		name, help string
+		value      func(*pgxpool.Stat) int64
+	}{
+		{"vaultdrop_db_pool_acquire_total", "Connections acquired from the pool.", (*pgxpool.Stat).AcquireCount},
+		{"vaultdrop_db_pool_empty_acquire_total", "Acquires that had to wait because no connection was idle.", (*pgxpool.Stat).EmptyAcquireCount},
+		{"vaultdrop_db_pool_canceled_acquire_total", "Acquires given up by their caller while waiting.", (*pgxpool.Stat).CanceledAcquireCount},
+		{"vaultdrop_db_pool_new_conns_total", "Connections opened.", (*pgxpool.Stat).NewConnsCount},
+		{"vaultdrop_db_pool_max_lifetime_destroy_total", "Connections closed for reaching VAULTDROP_DB_MAX_CONN_LIFETIME.", (*pgxpool.Stat).MaxLifetimeDestroyCount},
+		{"vaultdrop_db_pool_max_idle_destroy_total", "Connections closed for idling past VAULTDROP_DB_MAX_CONN_IDLE_TIME.", (*pgxpool.Stat).MaxIdleDestroyCount},
+	}
+	for _, c := range counters {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+		for _, p := range pools {
+			fmt.Fprintf(w, "%s{pool=%q} %d\n", c.name, p.Name, c.value(p.Stat))
+		}
+	}
+	fmt.Fprintln(w, "# HELP vaultdrop_db_pool_acquire_seconds_total Time spent acquiring connections, including waits.")
+	fmt.Fprintln(w, "# TYPE vaultdrop_db_pool_acquire_seconds_total counter")
+	for _, p := range pools {
+		fmt.Fprintf(w, "vaultdrop_db_pool_acquire_seconds_total{pool=%q} %g\n", p.Name, p.Stat.AcquireDuration().Seconds())
+	}
+}
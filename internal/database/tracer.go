@@ -0,0 +1,109 @@
+package database
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/timing"
+)
+
+const (
+	// slowQueryLimit is how many statements the slow query log keeps.
+	slowQueryLimit = 10
+	// slowQueryWindow is how long a statement stays in the slow query log.
+	slowQueryWindow = time.Hour
+)
+
+type queryStartKey struct{}
+
+type queryStart struct {
+	at  time.Time
+	sql string
+}
+
+// queryTimer reports query time to the request's timing.Recorder, if any, so
+// API responses can break latency down without touching every repository
+// method, and feeds the process-wide slow query log. For QueryRow the end is
+// traced when the row is scanned.
+type queryTimer struct{}
+
+func (queryTimer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	return context.WithValue(ctx, queryStartKey{}, queryStart{at: time.Now(), sql: data.SQL})
+}
+
+func (queryTimer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	start, ok := ctx.Value(queryStartKey{}).(queryStart)
+	if !ok {
+		return
+	}
+	elapsed := time.Since(start.at)
+	timing.FromContext(ctx).Add("db", elapsed)
+	slowQueries.record(start.sql, elapsed, start.at, data.Err)
+}
+
+// SlowQuery is an entry in the slow query log.
+type SlowQuery struct {
+	SQL        string    `json:"sql"`
+	DurationMS float64   `json:"durationMs"`
+	StartedAt  time.Time `json:"startedAt"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// SlowestQueries returns the slowest statements this process ran in roughly
+// the last hour, slowest first. It only sees pools opened with Connect.
+func SlowestQueries() []SlowQuery {
+	return slowQueries.snapshot()
+}
+
+var slowQueries = &slowQueryLog{}
+
+// slowQueryLog keeps the slowQueryLimit slowest statements, dropping entries
+// older than slowQueryWindow as new statements come in.
+type slowQueryLog struct {
+	mu      sync.Mutex
+	entries []SlowQuery
+}
+
+func (l *slowQueryLog) record(sql string, d time.Duration, at time.Time, err error) {
+	ms := float64(d) / float64(time.Millisecond)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	cutoff := time.Now().Add(-slowQueryWindow)
+	kept := l.entries[:0]
+	for _, e := range l.entries {
+		if e.StartedAt.After(cutoff) {
+			kept = append(kept, e)
+		}
+	}
+	l.entries = kept
+	entry := SlowQuery{SQL: strings.Join(strings.Fields(sql), " "), DurationMS: ms, StartedAt: at}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	if len(l.entries) < slowQueryLimit {
+		l.entries = append(l.entries, entry)
+		return
+	}
+	fastest := 0
+	for i, e := range l.entries {
+		if e.DurationMS < l.entries[fastest].DurationMS {
+			fastest = i
+		}
+	}
+	if ms > l.entries[fastest].DurationMS {
+		l.entries[fastest] = entry
+	}
+}
+
+func (l *slowQueryLog) snapshot() []SlowQuery {
+	l.mu.Lock()
+	out := append([]SlowQuery(nil), l.entries...)
+	l.mu.Unlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].DurationMS > out[j].DurationMS })
+	return out
+}
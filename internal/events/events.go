@@ -0,0 +1,88 @@
+// Package events publishes document lifecycle events to a message broker, so
+// other systems can react to uploads and finished extractions without
+// polling the API. The broker is chosen by configuration: NATS, Kafka
+// through a Confluent REST Proxy, or none.
+//
+// Publishing is best effort. A broker outage never fails an upload or an
+// extraction; lost events are logged, and the documents table stays the
+// source of truth.
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/config"
+)
+
+// Event types, one per lifecycle transition.
+const (
+	DocumentCreated    = "document.created"
+	DocumentProcessing = "document.processing"
+	DocumentCompleted  = "document.completed"
+	DocumentFailed     = "document.failed"
+)
+
+// Event is one lifecycle transition of a document. Fields the emitter does
+// not know at that point are left out.
+type Event struct {
+	// ID is unique per event, for consumers that deduplicate redeliveries.
+	ID         string    `json:"id"`
+	Type       string    `json:"type"`
+	DocumentID string    `json:"documentId"`
+	FileName   string    `json:"fileName,omitempty"`
+	OwnerID    string    `json:"ownerId,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	Time       time.Time `json:"time"`
+}
+
+// NewEvent returns an event of type typ for documentID, stamped now.
+func NewEvent(typ, documentID string) Event {
+	return Event{ID: uuid.NewString(), Type: typ, DocumentID: documentID, Time: time.Now().UTC()}
+}
+
+// Publisher sends events to a broker.
+type Publisher interface {
+	// Publish sends e or queues it for sending.
+	Publish(ctx context.Context, e Event) error
+	// Close flushes queued events and releases the connection.
+	Close() error
+}
+
+// ErrQueueFull is returned when a publisher's send buffer is full and the
+// event was dropped.
+var ErrQueueFull = errors.New("events: queue full")
+
+// New returns the publisher cfg.EventsDriver selects, or Nop when it is
+// empty.
+func New(cfg *config.Config) (Publisher, error) {
+	switch cfg.EventsDriver {
+	case "":
+		return Nop{}, nil
+	case "nats":
+		return newNATS(cfg.EventsURL, cfg.EventsTopic)
+	case "kafka":
+		return newKafkaREST(cfg.EventsURL, cfg.EventsTopic), nil
+	default:
+		return nil, fmt.Errorf("unknown events driver %q", cfg.EventsDriver)
+	}
+}
+
+// Emit publishes e and logs a failure instead of returning it: an event is
+// never worth failing the transition it reports.
+func Emit(ctx context.Context, p Publisher, e Event) {
+	if err := p.Publish(ctx, e); err != nil {
+		log.Printf("publish %s for %s: %v", e.Type, e.DocumentID, err)
+	}
+}
+
+// Nop discards events.
+type Nop struct{}
+
+func (Nop) Publish(context.Context, Event) error { return nil }
+func (Nop) Close() error                         { return nil }
@@ -0,0 +1,81 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestNATSSubject(t *testing.T) {
+	for typ, want := range map[string]string{
+		DocumentCreated:    "vaultdrop.documents.created",
+		DocumentProcessing: "vaultdrop.documents.processing",
+		DocumentCompleted:  "vaultdrop.documents.completed",
+		DocumentFailed:     "vaultdrop.documents.failed",
+	} {
+		if got := natsSubject("vaultdrop.documents", typ); got != want {
+			t.Errorf("natsSubject(%q) = %q, want %q", typ, got, want)
+		}
+	}
+}
+
+func TestKafkaRESTPublish(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		records []kafkaRecord
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/topics/vaultdrop.documents" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		if ct := r.Header.Get("Content-Type"); ct != kafkaContentType {
+			t.Errorf("Content-Type = %q", ct)
+		}
+		var body struct {
+			Records []kafkaRecord `json:"records"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode body: %v", err)
+		}
+		mu.Lock()
+		records = append(records, body.Records...)
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/vnd.kafka.v2+json")
+		w.Write([]byte(`{"offsets":[]}`))
+	}))
+	defer srv.Close()
+
+	p := newKafkaREST(srv.URL+"/", "vaultdrop.documents")
+	ctx := context.Background()
+	created := NewEvent(DocumentCreated, "doc-1")
+	created.FileName = "a.pdf"
+	failed := NewEvent(DocumentFailed, "doc-2")
+	failed.Error = "corrupt"
+	for _, e := range []Event{created, failed} {
+		if err := p.Publish(ctx, e); err != nil {
+			t.Fatalf("Publish: %v", err)
+		}
+	}
+	if err := p.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if err := p.Publish(ctx, created); err == nil {
+		t.Error("Publish after Close succeeded")
+	}
+
+	if len(records) != 2 {
+		t.Fatalf("got %d records, want 2", len(records))
+	}
+	if records[0].Key != "doc-1" || records[0].Value.Type != DocumentCreated || records[0].Value.FileName != "a.pdf" {
+		t.Errorf("first record = %+v", records[0])
+	}
+	if records[1].Key != "doc-2" || records[1].Value.Error != "corrupt" {
+		t.Errorf("second record = %+v", records[1])
+	}
+	if records[0].Value.ID == "" || records[0].Value.ID == records[1].Value.ID {
+		t.Errorf("event ids not unique: %q, %q", records[0].Value.ID, records[1].Value.ID)
+	}
+}
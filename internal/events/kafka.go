@@ -0,0 +1,142 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// kafkaQueueSize is how many events wait for the REST Proxy before new
+	// ones are dropped.
+	kafkaQueueSize = 1024
+	// kafkaMaxBatch caps the records sent in one request.
+	kafkaMaxBatch = 100
+	// kafkaSendTimeout bounds one request to the REST Proxy.
+	kafkaSendTimeout = 10 * time.Second
+	// kafkaCloseTimeout bounds how long Close waits for queued events.
+	kafkaCloseTimeout = 10 * time.Second
+)
+
+// kafkaContentType is the REST Proxy v2 media type for JSON records.
+const kafkaContentType = "application/vnd.kafka.json.v2+json"
+
+// kafkaREST produces to a Kafka topic through a Confluent REST Proxy, keyed
+// by document id so one document's events stay in order on one partition.
+// Publish only queues the event; a background goroutine sends whatever has
+// queued up in one request, so a slow proxy never holds up an upload.
+type kafkaREST struct {
+	endpoint string
+	client   *http.Client
+
+	mu     sync.RWMutex
+	closed bool
+	queue  chan Event
+	done   chan struct{}
+}
+
+type kafkaRecord struct {
+	Key   string `json:"key"`
+	Value Event  `json:"value"`
+}
+
+func newKafkaREST(baseURL, topic string) *kafkaREST {
+	k := &kafkaREST{
+		endpoint: strings.TrimSuffix(baseURL, "/") + "/topics/" + url.PathEscape(topic),
+		client:   &http.Client{Timeout: kafkaSendTimeout},
+		queue:    make(chan Event, kafkaQueueSize),
+		done:     make(chan struct{}),
+	}
+	go k.run()
+	return k
+}
+
+func (k *kafkaREST) Publish(_ context.Context, e Event) error {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	if k.closed {
+		return fmt.Errorf("events: publisher closed")
+	}
+	select {
+	case k.queue <- e:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Close stops accepting events and waits for the queued ones to be sent.
+func (k *kafkaREST) Close() error {
+	k.mu.Lock()
+	if !k.closed {
+		k.closed = true
+		close(k.queue)
+	}
+	k.mu.Unlock()
+	select {
+	case <-k.done:
+		return nil
+	case <-time.After(kafkaCloseTimeout):
+		return fmt.Errorf("events: %d event(s) still queued after %s", len(k.queue), kafkaCloseTimeout)
+	}
+}
+
+func (k *kafkaREST) run() {
+	defer close(k.done)
+	for e := range k.queue {
+		batch := []Event{e}
+	fill:
+		for len(batch) < kafkaMaxBatch {
+			select {
+			case e, ok := <-k.queue:
+				if !ok {
+					break fill
+				}
+				batch = append(batch, e)
+			default:
+				break fill
+			}
+		}
+		if err := k.send(batch); err != nil {
+			log.Printf("publish %d event(s) to kafka: %v", len(batch), err)
+		}
+	}
+}
+
+func (k *kafkaREST) send(batch []Event) error {
+	records := make([]kafkaRecord, len(batch))
+	for i, e := range batch {
+		records[i] = kafkaRecord{Key: e.DocumentID, Value: e}
+	}
+	body, err := json.Marshal(map[string]interface{}{"records": records})
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), kafkaSendTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, k.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", kafkaContentType)
+	req.Header.Set("Accept", "application/vnd.kafka.v2+json")
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("rest proxy: %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
@@ -0,0 +1,55 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// natsFlushTimeout bounds how long Close waits for buffered events.
+const natsFlushTimeout = 5 * time.Second
+
+// natsPublisher publishes each event on prefix + "." + the event's verb, e.g.
+// "vaultdrop.documents.completed", so consumers can subscribe to one
+// transition or to prefix + ".>" for all of them.
+type natsPublisher struct {
+	conn   *nats.Conn
+	prefix string
+}
+
+// newNATS connects to url. The connection keeps retrying in the background,
+// so a broker that is down at startup does not stop the service; events
+// published meanwhile wait in the client's reconnect buffer.
+func newNATS(url, prefix string) (*natsPublisher, error) {
+	conn, err := nats.Connect(url,
+		nats.Name("vaultdrop"),
+		nats.RetryOnFailedConnect(true),
+		nats.MaxReconnects(-1),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &natsPublisher{conn: conn, prefix: prefix}, nil
+}
+
+func (p *natsPublisher) Publish(_ context.Context, e Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return p.conn.Publish(natsSubject(p.prefix, e.Type), data)
+}
+
+func (p *natsPublisher) Close() error {
+	err := p.conn.FlushTimeout(natsFlushTimeout)
+	p.conn.Close()
+	return err
+}
+
+// natsSubject maps an event type to its subject under prefix.
+func natsSubject(prefix, typ string) string {
+	return prefix + "." + strings.TrimPrefix(typ, "document.")
+}
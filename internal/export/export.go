@@ -0,0 +1,230 @@
+// Package export writes several documents into one ZIP for POST /exports.
+// The API streams small exports straight to the client; the worker builds
+// large ones into the processed bucket. Either way the archive holds a
+// folder per document and a manifest.json saying what became of each.
+package export
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"path"
+	"strings"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/keys"
+	"github.com/dharsanguruparan/VaultDrop/internal/repository"
+	"github.com/dharsanguruparan/VaultDrop/internal/s3storage"
+)
+
+// Artifact is what an export holds of each document.
+type Artifact string
+
+const (
+	// Raw is the original upload, decrypted when it was encrypted.
+	Raw Artifact = "raw"
+	// Text is the extracted text.
+	Text Artifact = "text"
+)
+
+// ParseArtifact reads an artifact name; empty means Raw.
+func ParseArtifact(s string) (Artifact, error) {
+	switch Artifact(s) {
+	case "", Raw:
+		return Raw, nil
+	case Text:
+		return Text, nil
+	}
+	return "", fmt.Errorf("unknown artifact %q; want raw or text", s)
+}
+
+// Reasons a document is left out of an export, recorded in its Entry.
+const (
+	SkipNotFound    = "not_found"
+	SkipRawExpired  = "raw_expired"
+	SkipTextExpired = "text_expired"
+	SkipNotReady    = "not_ready"
+	SkipEncrypted   = "encrypted"
+	SkipMissing     = "missing_object"
+)
+
+// ManifestName is the manifest's name in the ZIP.
+const ManifestName = "manifest.json"
+
+// Entry is what became of one document: the file it was written to, or why
+// it was skipped.
+type Entry struct {
+	DocumentID string `json:"documentId"`
+	FileName   string `json:"fileName,omitempty"`
+	Path       string `json:"path,omitempty"`
+	Size       int64  `json:"size,omitempty"`
+	Skipped    string `json:"skipped,omitempty"`
+}
+
+// Manifest is the content of manifest.json.
+type Manifest struct {
+	Artifact  Artifact `json:"artifact"`
+	Documents []Entry  `json:"documents"`
+}
+
+// Documents is what an Exporter reads from the database;
+// *repository.DocumentRepository implements it.
+type Documents interface {
+	GetForOwner(ctx context.Context, id, owner string) (*repository.Document, error)
+	DataKey(ctx context.Context, id string) (*keys.Wrapped, error)
+}
+
+// Objects is what an Exporter reads from the object store;
+// *s3storage.Storage implements it.
+type Objects interface {
+	OpenRaw(ctx context.Context, objectKey string, dataKey []byte) (io.ReadCloser, s3storage.ObjectInfo, error)
+	GetProcessed(ctx context.Context, objectKey string) ([]byte, error)
+}
+
+// Exporter reads documents for exports.
+type Exporter struct {
+	repo  Documents
+	store Objects
+	keys  *keys.Keyring
+}
+
+// New constructs an Exporter. keyring may be nil, in which case encrypted
+// raw uploads are skipped.
+func New(repo Documents, store Objects, keyring *keys.Keyring) *Exporter {
+	return &Exporter{repo: repo, store: store, keys: keyring}
+}
+
+// Write writes the artifact of each document in ids, as owner may read them
+// (any owner when empty), into a ZIP on w, followed by the manifest it also
+// returns. Documents that cannot be exported are skipped and listed in the
+// manifest; an error means storage or the database failed and the ZIP is
+// incomplete.
+func (e *Exporter) Write(ctx context.Context, w io.Writer, ids []string, owner string, artifact Artifact) (*Manifest, error) {
+	zw := zip.NewWriter(w)
+	manifest := &Manifest{Artifact: artifact, Documents: make([]Entry, 0, len(ids))}
+	for _, id := range ids {
+		entry, err := e.writeDocument(ctx, zw, id, owner, artifact)
+		if err != nil {
+			return nil, fmt.Errorf("export %s: %w", id, err)
+		}
+		manifest.Documents = append(manifest.Documents, entry)
+	}
+	f, err := zw.Create(ManifestName)
+	if err != nil {
+		return nil, err
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(manifest); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+func (e *Exporter) writeDocument(ctx context.Context, zw *zip.Writer, id, owner string, artifact Artifact) (Entry, error) {
+	entry := Entry{DocumentID: id}
+	doc, err := e.repo.GetForOwner(ctx, id, owner)
+	if errors.Is(err, repository.ErrNotFound) {
+		entry.Skipped = SkipNotFound
+		return entry, nil
+	}
+	if err != nil {
+		return entry, err
+	}
+	entry.FileName = doc.FileName
+	if artifact == Text {
+		return e.writeText(ctx, zw, doc, entry)
+	}
+	return e.writeRaw(ctx, zw, doc, entry)
+}
+
+func (e *Exporter) writeRaw(ctx context.Context, zw *zip.Writer, doc *repository.Document, entry Entry) (Entry, error) {
+	if doc.RawPurgedAt != nil {
+		entry.Skipped = SkipRawExpired
+		return entry, nil
+	}
+	var dataKey []byte
+	wrapped, err := e.repo.DataKey(ctx, doc.ID)
+	if err == nil && wrapped != nil {
+		dataKey, err = e.keys.Unwrap(doc.ID, *wrapped)
+		if errors.Is(err, keys.ErrNoKeys) || errors.Is(err, keys.ErrUnknownKey) {
+			log.Printf("export %s: %v", doc.ID, err)
+			entry.Skipped = SkipEncrypted
+			return entry, nil
+		}
+	}
+	if errors.Is(err, repository.ErrNotFound) {
+		entry.Skipped = SkipNotFound
+		return entry, nil
+	}
+	if err != nil {
+		return entry, err
+	}
+	body, _, err := e.store.OpenRaw(ctx, doc.ObjectKey, dataKey)
+	if errors.Is(err, s3storage.ErrNotFound) {
+		entry.Skipped = SkipMissing
+		return entry, nil
+	}
+	if err != nil {
+		return entry, err
+	}
+	defer body.Close()
+	entry.Path = doc.ID + "/" + safeName(doc.FileName)
+	// PDFs and images are compressed already.
+	f, err := zw.CreateHeader(&zip.FileHeader{Name: entry.Path, Method: zip.Store, Modified: doc.CreatedAt})
+	if err != nil {
+		return entry, err
+	}
+	if entry.Size, err = io.Copy(f, body); err != nil {
+		return entry, err
+	}
+	return entry, nil
+}
+
+func (e *Exporter) writeText(ctx context.Context, zw *zip.Writer, doc *repository.Document, entry Entry) (Entry, error) {
+	switch {
+	case doc.TextPurgedAt != nil:
+		entry.Skipped = SkipTextExpired
+		return entry, nil
+	case doc.Status != repository.StatusCompleted:
+		entry.Skipped = SkipNotReady
+		return entry, nil
+	}
+	text := doc.Content
+	if doc.ArchivedAt != nil && doc.ArchiveKey != nil {
+		data, err := e.store.GetProcessed(ctx, *doc.ArchiveKey)
+		if errors.Is(err, s3storage.ErrNotFound) {
+			entry.Skipped = SkipMissing
+			return entry, nil
+		}
+		if err != nil {
+			return entry, err
+		}
+		text = string(data)
+	}
+	name := safeName(doc.FileName)
+	entry.Path = doc.ID + "/" + strings.TrimSuffix(name, path.Ext(name)) + ".txt"
+	f, err := zw.CreateHeader(&zip.FileHeader{Name: entry.Path, Method: zip.Deflate, Modified: doc.CreatedAt})
+	if err != nil {
+		return entry, err
+	}
+	n, err := io.WriteString(f, text)
+	entry.Size = int64(n)
+	return entry, err
+}
+
+// safeName reduces an uploaded file name to one path element, so entries
+// stay inside their document's folder whatever the client sent.
+func safeName(name string) string {
+	name = path.Base(strings.ReplaceAll(name, `\`, "/"))
+	if name == "." || name == "/" || name == ".." {
+		return "document"
+	}
+	return name
+}
@@ -0,0 +1,181 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/keys"
+	"github.com/dharsanguruparan/VaultDrop/internal/repository"
+	"github.com/dharsanguruparan/VaultDrop/internal/s3storage"
+)
+
+func TestParseArtifact(t *testing.T) {
+	for in, want := range map[string]Artifact{"": Raw, "raw": Raw, "text": Text} {
+		if got, err := ParseArtifact(in); err != nil || got != want {
+			t.Errorf("ParseArtifact(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	if _, err := ParseArtifact("processed"); err == nil {
+		t.Error("ParseArtifact accepted an unknown artifact")
+	}
+}
+
+func TestSafeName(t *testing.T) {
+	for in, want := range map[string]string{
+		"report.pdf":         "report.pdf",
+		"../../etc/passwd":   "passwd",
+		`C:\Users\a\doc.pdf`: "doc.pdf",
+		"":                   "document",
+		"..":                 "document",
+		"/":                  "document",
+	} {
+		if got := safeName(in); got != want {
+			t.Errorf("safeName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+type fakeDocuments struct {
+	docs map[string]*repository.Document
+	// owners maps a document to the owner allowed to read it.
+	owners   map[string]string
+	dataKeys map[string]*keys.Wrapped
+}
+
+func (f *fakeDocuments) GetForOwner(ctx context.Context, id, owner string) (*repository.Document, error) {
+	doc, ok := f.docs[id]
+	if !ok || (owner != "" && f.owners[id] != owner) {
+		return nil, repository.ErrNotFound
+	}
+	copy := *doc
+	return &copy, nil
+}
+
+func (f *fakeDocuments) DataKey(ctx context.Context, id string) (*keys.Wrapped, error) {
+	return f.dataKeys[id], nil
+}
+
+type fakeObjects map[string]string
+
+func (f fakeObjects) OpenRaw(ctx context.Context, objectKey string, dataKey []byte) (io.ReadCloser, s3storage.ObjectInfo, error) {
+	data, ok := f[objectKey]
+	if !ok {
+		return nil, s3storage.ObjectInfo{}, s3storage.ErrNotFound
+	}
+	return io.NopCloser(strings.NewReader(data)), s3storage.ObjectInfo{Size: int64(len(data))}, nil
+}
+
+func (f fakeObjects) GetProcessed(ctx context.Context, objectKey string) ([]byte, error) {
+	data, ok := f[objectKey]
+	if !ok {
+		return nil, s3storage.ErrNotFound
+	}
+	return []byte(data), nil
+}
+
+// readZip returns the files in the ZIP data by name.
+func readZip(t *testing.T, data []byte) map[string]string {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := make(map[string]string)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[f.Name] = string(b)
+	}
+	return files
+}
+
+func TestWrite(t *testing.T) {
+	now := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	archive := "archive/archived/text.txt"
+	docs := &fakeDocuments{
+		docs: map[string]*repository.Document{
+			"plain":    {ID: "plain", FileName: "../a.pdf", ObjectKey: "uploads/plain/a.pdf", Status: repository.StatusCompleted, Content: "hello", CreatedAt: now},
+			"archived": {ID: "archived", FileName: "b.pdf", ObjectKey: "uploads/archived/b.pdf", Status: repository.StatusCompleted, ArchivedAt: &now, ArchiveKey: &archive, CreatedAt: now},
+			"purged":   {ID: "purged", FileName: "c.pdf", ObjectKey: "uploads/purged/c.pdf", Status: repository.StatusCompleted, RawPurgedAt: &now, TextPurgedAt: &now, CreatedAt: now},
+			"queued":   {ID: "queued", FileName: "d.pdf", ObjectKey: "uploads/queued/d.pdf", Status: repository.StatusQueued, CreatedAt: now},
+			"lost":     {ID: "lost", FileName: "e.pdf", ObjectKey: "uploads/lost/e.pdf", Status: repository.StatusCompleted, CreatedAt: now},
+			"sealed":   {ID: "sealed", FileName: "f.pdf", ObjectKey: "uploads/sealed/f.pdf", Status: repository.StatusQueued, CreatedAt: now},
+			"foreign":  {ID: "foreign", FileName: "g.pdf", ObjectKey: "uploads/foreign/g.pdf", Status: repository.StatusCompleted, CreatedAt: now},
+		},
+		owners:   map[string]string{"plain": "alice", "archived": "alice", "purged": "alice", "queued": "alice", "lost": "alice", "sealed": "alice", "foreign": "bob"},
+		dataKeys: map[string]*keys.Wrapped{"sealed": {KeyID: "k1", Key: []byte{1}}},
+	}
+	objects := fakeObjects{
+		"uploads/plain/a.pdf":    "%PDF-a",
+		"uploads/archived/b.pdf": "%PDF-b",
+		"uploads/queued/d.pdf":   "%PDF-d",
+		"uploads/sealed/f.pdf":   "sealed",
+		archive:                  "archived text",
+	}
+	ids := []string{"plain", "archived", "purged", "queued", "lost", "sealed", "foreign", "missing"}
+
+	for _, tc := range []struct {
+		artifact Artifact
+		files    map[string]string
+		skipped  map[string]string
+	}{
+		{Raw,
+			map[string]string{"plain/a.pdf": "%PDF-a", "archived/b.pdf": "%PDF-b", "queued/d.pdf": "%PDF-d"},
+			map[string]string{"purged": SkipRawExpired, "lost": SkipMissing, "sealed": SkipEncrypted, "foreign": SkipNotFound, "missing": SkipNotFound},
+		},
+		{Text,
+			map[string]string{"plain/a.txt": "hello", "archived/b.txt": "archived text", "lost/e.txt": ""},
+			map[string]string{"purged": SkipTextExpired, "queued": SkipNotReady, "sealed": SkipNotReady, "foreign": SkipNotFound, "missing": SkipNotFound},
+		},
+	} {
+		var buf bytes.Buffer
+		manifest, err := New(docs, objects, nil).Write(context.Background(), &buf, ids, "alice", tc.artifact)
+		if err != nil {
+			t.Fatalf("%s: Write: %v", tc.artifact, err)
+		}
+		files := readZip(t, buf.Bytes())
+		for name, want := range tc.files {
+			if files[name] != want {
+				t.Errorf("%s: %s = %q, want %q", tc.artifact, name, files[name], want)
+			}
+		}
+		if len(files) != len(tc.files)+1 {
+			t.Errorf("%s: zip has %d files, want %d and the manifest", tc.artifact, len(files), len(tc.files))
+		}
+		var written Manifest
+		if err := json.Unmarshal([]byte(files[ManifestName]), &written); err != nil {
+			t.Fatalf("%s: manifest: %v", tc.artifact, err)
+		}
+		if !reflect.DeepEqual(&written, manifest) {
+			t.Errorf("%s: manifest.json = %+v, returned %+v", tc.artifact, written, manifest)
+		}
+		if len(manifest.Documents) != len(ids) {
+			t.Fatalf("%s: manifest has %d entries, want %d", tc.artifact, len(manifest.Documents), len(ids))
+		}
+		for i, entry := range manifest.Documents {
+			if entry.DocumentID != ids[i] {
+				t.Errorf("%s: entry %d is %s, want %s", tc.artifact, i, entry.DocumentID, ids[i])
+			}
+			if entry.Skipped != tc.skipped[entry.DocumentID] {
+				t.Errorf("%s: %s skipped = %q, want %q", tc.artifact, entry.DocumentID, entry.Skipped, tc.skipped[entry.DocumentID])
+			}
+			if entry.Skipped == "" && entry.Size != int64(len(tc.files[entry.Path])) {
+				t.Errorf("%s: %s size = %d, want %d", tc.artifact, entry.Path, entry.Size, len(tc.files[entry.Path]))
+			}
+		}
+	}
+}
@@ -0,0 +1,207 @@
+// Package httperr writes HTTP errors in the one JSON envelope both servers
+// use:
+//
+//	{"code": "not_found", "message": "document not found", "requestId": "..."}
+//
+// Code is stable and meant for programs to branch on; Message is for people
+// and may change. Details, when present, carries structured context such as
+// the accepted content types. RequestID matches the X-Request-Id response
+// header and the server's log line for the request.
+package httperr
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+// Code is a machine-readable error code.
+type Code string
+
+// Codes for each error status. Handlers use these unless a more specific
+// code below applies.
+const (
+	CodeInvalidRequest       Code = "invalid_request"
+	CodeUnauthorized         Code = "unauthorized"
+	CodeForbidden            Code = "forbidden"
+	CodeNotFound             Code = "not_found"
+	CodeMethodNotAllowed     Code = "method_not_allowed"
+	CodeNotAcceptable        Code = "not_acceptable"
+	CodeConflict             Code = "conflict"
+	CodeGone                 Code = "gone"
+	CodeLengthRequired       Code = "length_required"
+	CodePreconditionFailed   Code = "precondition_failed"
+	CodeTooLarge             Code = "too_large"
+	CodeUnsupportedMediaType Code = "unsupported_media_type"
+	CodeRangeNotSatisfiable  Code = "range_not_satisfiable"
+	CodeUnprocessable        Code = "unprocessable"
+	CodeRateLimited          Code = "rate_limited"
+	CodeInternal             Code = "internal"
+	CodeUpstreamFailed       Code = "upstream_failed"
+	CodeUnavailable          Code = "unavailable"
+	CodeUpstreamTimeout      Code = "upstream_timeout"
+)
+
+// Specific codes for cases clients commonly handle on their own.
+const (
+	// CodeNotReady answers 202 when a document has no text yet.
+	CodeNotReady Code = "not_ready"
+	// CodeTextExpired answers 410 when retention purged the text.
+	CodeTextExpired Code = "text_expired"
+	// CodeRawExpired answers 410 when retention purged the raw upload.
+	CodeRawExpired Code = "raw_expired"
+	// CodeErased answers 410 for a document removed by an erasure request.
+	CodeErased Code = "erased"
+	// CodeInvalidCursor answers 400 for a paging cursor the server did not
+	// issue.
+	CodeInvalidCursor Code = "invalid_cursor"
+	// CodeReadOnly answers 503 for writes to a read-only server.
+	CodeReadOnly Code = "read_only"
+	// CodeStorageUnavailable answers 503 while object storage is failing.
+	CodeStorageUnavailable Code = "storage_unavailable"
+	// CodeIdempotencyInProgress and CodeIdempotencyMismatch answer uploads
+	// whose Idempotency-Key is still running or was used differently.
+	CodeIdempotencyInProgress Code = "idempotency_in_progress"
+	CodeIdempotencyMismatch   Code = "idempotency_mismatch"
+	// CodeEncrypted answers 409 for a presigned download of a raw upload
+	// encrypted with a data key, which only the API can decrypt.
+	CodeEncrypted Code = "encrypted"
+)
+
+var statusCodes = map[int]Code{
+	http.StatusAccepted:                     CodeNotReady,
+	http.StatusBadRequest:                   CodeInvalidRequest,
+	http.StatusUnauthorized:                 CodeUnauthorized,
+	http.StatusForbidden:                    CodeForbidden,
+	http.StatusNotFound:                     CodeNotFound,
+	http.StatusMethodNotAllowed:             CodeMethodNotAllowed,
+	http.StatusNotAcceptable:                CodeNotAcceptable,
+	http.StatusConflict:                     CodeConflict,
+	http.StatusGone:                         CodeGone,
+	http.StatusLengthRequired:               CodeLengthRequired,
+	http.StatusPreconditionFailed:           CodePreconditionFailed,
+	http.StatusRequestEntityTooLarge:        CodeTooLarge,
+	http.StatusUnsupportedMediaType:         CodeUnsupportedMediaType,
+	http.StatusRequestedRangeNotSatisfiable: CodeRangeNotSatisfiable,
+	http.StatusUnprocessableEntity:          CodeUnprocessable,
+	http.StatusTooManyRequests:              CodeRateLimited,
+	http.StatusInternalServerError:          CodeInternal,
+	http.StatusBadGateway:                   CodeUpstreamFailed,
+	http.StatusServiceUnavailable:           CodeUnavailable,
+	http.StatusGatewayTimeout:               CodeUpstreamTimeout,
+}
+
+// CodeFor returns the generic code for status.
+func CodeFor(status int) Code {
+	if code, ok := statusCodes[status]; ok {
+		return code
+	}
+	if status >= 500 {
+		return CodeInternal
+	}
+	return CodeInvalidRequest
+}
+
+// Body is the error envelope.
+type Body struct {
+	Code      Code        `json:"code"`
+	Message   string      `json:"message"`
+	Details   interface{} `json:"details,omitempty"`
+	RequestID string      `json:"requestId,omitempty"`
+}
+
+// Write sends an error with code and message, and details when not nil.
+func Write(w http.ResponseWriter, status int, code Code, message string, details interface{}) {
+	h := w.Header()
+	h.Del("Content-Length")
+	h.Set("Content-Type", "application/json")
+	h.Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	body := Body{Code: code, Message: message, Details: details, RequestID: h.Get(RequestIDHeader)}
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Printf("encode error response: %v", err)
+	}
+}
+
+// Error replaces http.Error: it sends message with the generic code for
+// status.
+func Error(w http.ResponseWriter, message string, status int) {
+	Write(w, status, CodeFor(status), message, nil)
+}
+
+// BadRequest sends a 400 invalid_request error.
+func BadRequest(w http.ResponseWriter, message string) {
+	Error(w, message, http.StatusBadRequest)
+}
+
+// Forbidden sends a 403 forbidden error.
+func Forbidden(w http.ResponseWriter, message string) {
+	Error(w, message, http.StatusForbidden)
+}
+
+// NotFound replaces http.NotFound.
+func NotFound(w http.ResponseWriter, _ *http.Request) {
+	Error(w, "not found", http.StatusNotFound)
+}
+
+// MethodNotAllowed sends a 405 method_not_allowed error.
+func MethodNotAllowed(w http.ResponseWriter) {
+	Error(w, "method not allowed", http.StatusMethodNotAllowed)
+}
+
+// Internal sends a 500 internal error. message is shown to the client, so
+// it must not carry the underlying error; log that instead.
+func Internal(w http.ResponseWriter, message string) {
+	Error(w, message, http.StatusInternalServerError)
+}
+
+// RequestIDHeader carries the request id on requests and responses.
+const RequestIDHeader = "X-Request-Id"
+
+// maxRequestID bounds a request id accepted from the client or a proxy.
+const maxRequestID = 128
+
+type requestIDKey struct{}
+
+// RequestID gives every request an id: the client's or proxy's
+// X-Request-Id when it is reasonable, otherwise a new random one. The id is
+// echoed in the X-Request-Id response header, where Write picks it up.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(RequestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
+
+// RequestIDFrom returns the id RequestID assigned, or "".
+func RequestIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestID {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= 0x20 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,72 @@
+package httperr
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestErrorEnvelope(t *testing.T) {
+	h := RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Write(w, http.StatusUnsupportedMediaType, CodeFor(http.StatusUnsupportedMediaType), "only PDFs", map[string]string{"reason": "unsupported_type"})
+	}))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/documents", nil))
+
+	if rec.Code != http.StatusUnsupportedMediaType || rec.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("got %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	var body struct {
+		Code      Code              `json:"code"`
+		Message   string            `json:"message"`
+		Details   map[string]string `json:"details"`
+		RequestID string            `json:"requestId"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Code != CodeUnsupportedMediaType || body.Message != "only PDFs" || body.Details["reason"] != "unsupported_type" {
+		t.Errorf("body = %+v", body)
+	}
+	if body.RequestID == "" || body.RequestID != rec.Header().Get(RequestIDHeader) {
+		t.Errorf("requestId %q, header %q", body.RequestID, rec.Header().Get(RequestIDHeader))
+	}
+}
+
+func TestRequestIDFromClient(t *testing.T) {
+	var seen string
+	h := RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = RequestIDFrom(r.Context())
+	}))
+	for id, keep := range map[string]bool{
+		"req-123":                true,
+		"has space":              false,
+		strings.Repeat("x", 129): false,
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(RequestIDHeader, id)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if got := seen == id; got != keep {
+			t.Errorf("client id %q: kept = %v, want %v", id, got, keep)
+		}
+		if seen == "" || rec.Header().Get(RequestIDHeader) != seen {
+			t.Errorf("client id %q: header %q, context %q", id, rec.Header().Get(RequestIDHeader), seen)
+		}
+	}
+}
+
+func TestCodeFor(t *testing.T) {
+	for status, want := range map[int]Code{
+		http.StatusNotFound:       CodeNotFound,
+		http.StatusAccepted:       CodeNotReady,
+		http.StatusTeapot:         CodeInvalidRequest,
+		http.StatusNotImplemented: CodeInternal,
+	} {
+		if got := CodeFor(status); got != want {
+			t.Errorf("CodeFor(%d) = %q, want %q", status, got, want)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+// Package idempotency remembers the responses to requests that carried an
+// Idempotency-Key header, so a client retrying an upload after a timeout gets
+// the first response back instead of creating a second document. Keys live
+// in Redis and expire on their own.
+package idempotency
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// pendingTTL bounds how long a claimed key blocks retries when the request
+// holding it never finishes, e.g. because the replica died mid-upload.
+const pendingTTL = 15 * time.Minute
+
+// Errors returned by Begin.
+var (
+	// ErrInProgress means the first request with the key is still running.
+	ErrInProgress = errors.New("a request with this idempotency key is in progress")
+	// ErrMismatch means the key was first used for a different request.
+	ErrMismatch = errors.New("idempotency key was used for a different request")
+)
+
+// Response is what a completed request answered.
+type Response struct {
+	Status int             `json:"status"`
+	Body   json.RawMessage `json:"body"`
+}
+
+// entry is the Redis value; Response is nil while the request is running.
+type entry struct {
+	Request  string    `json:"request"`
+	Response *Response `json:"response,omitempty"`
+}
+
+// Store keeps idempotency keys in Redis.
+type Store struct {
+	rdb *redis.Client
+	ttl time.Duration
+}
+
+// NewStore constructs a Store that remembers responses for ttl.
+func NewStore(rdb *redis.Client, ttl time.Duration) *Store {
+	return &Store{rdb: rdb, ttl: ttl}
+}
+
+// key hashes the caller-chosen key so its length and characters never reach
+// Redis, and scopes it to the principal so clients cannot collide.
+func key(scope, idemKey string) string {
+	sum := sha256.Sum256([]byte(scope + "\x00" + idemKey))
+	return "vaultdrop:idempotency:" + hex.EncodeToString(sum[:])
+}
+
+// Begin claims idemKey for request, a description of the request such as its
+// method and URL. It returns nil when the caller should go ahead and must
+// then call Complete or Abandon; the first response when the key was already
+// used for the same request; or ErrInProgress or ErrMismatch.
+func (s *Store) Begin(ctx context.Context, scope, idemKey, request string) (*Response, error) {
+	k := key(scope, idemKey)
+	pending, err := json.Marshal(entry{Request: request})
+	if err != nil {
+		return nil, err
+	}
+	// The key can expire between SETNX and GET; one more round settles it.
+	for attempt := 0; attempt < 2; attempt++ {
+		claimed, err := s.rdb.SetNX(ctx, k, pending, pendingTTL).Result()
+		if err != nil {
+			return nil, fmt.Errorf("claim idempotency key: %w", err)
+		}
+		if claimed {
+			return nil, nil
+		}
+		data, err := s.rdb.Get(ctx, k).Bytes()
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read idempotency key: %w", err)
+		}
+		var e entry
+		if err := json.Unmarshal(data, &e); err != nil {
+			return nil, fmt.Errorf("decode idempotency key: %w", err)
+		}
+		switch {
+		case e.Request != request:
+			return nil, ErrMismatch
+		case e.Response == nil:
+			return nil, ErrInProgress
+		default:
+			return e.Response, nil
+		}
+	}
+	return nil, ErrInProgress
+}
+
+// Complete stores the response to the request that claimed idemKey.
+func (s *Store) Complete(ctx context.Context, scope, idemKey, request string, resp Response) error {
+	data, err := json.Marshal(entry{Request: request, Response: &resp})
+	if err != nil {
+		return err
+	}
+	if err := s.rdb.Set(ctx, key(scope, idemKey), data, s.ttl).Err(); err != nil {
+		return fmt.Errorf("store idempotency key: %w", err)
+	}
+	return nil
+}
+
+// Abandon releases idemKey after the request failed, so a retry runs again.
+func (s *Store) Abandon(ctx context.Context, scope, idemKey string) error {
+	if err := s.rdb.Del(ctx, key(scope, idemKey)).Err(); err != nil {
+		return fmt.Errorf("release idempotency key: %w", err)
+	}
+	return nil
+}
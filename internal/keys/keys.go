@@ -0,0 +1,137 @@
+// Package keys implements envelope encryption for raw uploads. Every document
+// gets its own random data key, which encrypts the object in the store; the
+// data key is kept in the document row wrapped (encrypted) under a master key
+// from the configuration. Rotating the master key only rewraps the small data
+// keys with Rewrap; the objects are never rewritten.
+package keys
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/config"
+)
+
+// Size is the length of master and data keys: AES-256.
+const Size = 32
+
+var (
+	// ErrUnknownKey means a data key was wrapped under a master key that is
+	// not configured any more.
+	ErrUnknownKey = errors.New("unknown master key")
+	// ErrNoKeys is returned by Unwrap on a nil Keyring, i.e. when an
+	// encrypted document is read by a process without master keys.
+	ErrNoKeys = errors.New("no master keys configured")
+)
+
+// Wrapped is a data key encrypted under the master key KeyID. Key holds the
+// GCM nonce followed by the sealed data key.
+type Wrapped struct {
+	KeyID string
+	Key   []byte
+}
+
+// Keyring holds the configured master keys and the id of the current one,
+// which wraps new data keys.
+type Keyring struct {
+	current string
+	aeads   map[string]cipher.AEAD
+}
+
+// NewKeyring builds a Keyring from master keys by id. current must be one of
+// them.
+func NewKeyring(masters map[string][]byte, current string) (*Keyring, error) {
+	k := &Keyring{current: current, aeads: make(map[string]cipher.AEAD, len(masters))}
+	for id, key := range masters {
+		if len(key) != Size {
+			return nil, fmt.Errorf("master key %q is %d bytes, want %d", id, len(key), Size)
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		k.aeads[id] = aead
+	}
+	if _, ok := k.aeads[current]; !ok {
+		return nil, fmt.Errorf("current master key %q is not configured", current)
+	}
+	return k, nil
+}
+
+// FromConfig returns the Keyring of VAULTDROP_MASTER_KEYS, or nil when none
+// are configured and raw uploads are stored unencrypted.
+func FromConfig(cfg *config.Config) (*Keyring, error) {
+	if len(cfg.MasterKeys) == 0 {
+		return nil, nil
+	}
+	return NewKeyring(cfg.MasterKeys, cfg.MasterKeyID)
+}
+
+// Current returns the id of the master key new data keys are wrapped under.
+func (k *Keyring) Current() string {
+	return k.current
+}
+
+// NewDataKey generates a data key for the document id and returns it in the
+// clear, to encrypt the object with, and wrapped, to store with the document.
+func (k *Keyring) NewDataKey(id string) ([]byte, Wrapped, error) {
+	key := make([]byte, Size)
+	if _, err := rand.Read(key); err != nil {
+		return nil, Wrapped{}, fmt.Errorf("generate data key: %w", err)
+	}
+	w, err := k.wrap(id, key)
+	if err != nil {
+		return nil, Wrapped{}, err
+	}
+	return key, w, nil
+}
+
+// Unwrap decrypts the data key of the document id. The id is authenticated
+// with the key, so a wrapped key copied to another row does not unwrap.
+func (k *Keyring) Unwrap(id string, w Wrapped) ([]byte, error) {
+	if k == nil {
+		return nil, ErrNoKeys
+	}
+	aead, ok := k.aeads[w.KeyID]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownKey, w.KeyID)
+	}
+	n := aead.NonceSize()
+	if len(w.Key) < n {
+		return nil, fmt.Errorf("unwrap data key of %s: too short", id)
+	}
+	key, err := aead.Open(nil, w.Key[:n], w.Key[n:], []byte(id))
+	if err != nil {
+		return nil, fmt.Errorf("unwrap data key of %s under %q: %w", id, w.KeyID, err)
+	}
+	return key, nil
+}
+
+// Rewrap rewraps the data key of the document id under the current master
+// key. It returns w unchanged when it already is.
+func (k *Keyring) Rewrap(id string, w Wrapped) (Wrapped, error) {
+	if w.KeyID == k.current {
+		return w, nil
+	}
+	key, err := k.Unwrap(id, w)
+	if err != nil {
+		return Wrapped{}, err
+	}
+	return k.wrap(id, key)
+}
+
+func (k *Keyring) wrap(id string, key []byte) (Wrapped, error) {
+	aead := k.aeads[k.current]
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(key)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return Wrapped{}, fmt.Errorf("generate nonce: %w", err)
+	}
+	return Wrapped{KeyID: k.current, Key: aead.Seal(nonce, nonce, key, []byte(id))}, nil
+}
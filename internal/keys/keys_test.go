@@ -0,0 +1,82 @@
+package keys
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func testKeyring(t *testing.T, current string) *Keyring {
+	t.Helper()
+	k, err := NewKeyring(map[string][]byte{
+		"2025": bytes.Repeat([]byte{1}, Size),
+		"2026": bytes.Repeat([]byte{2}, Size),
+	}, current)
+	if err != nil {
+		t.Fatalf("NewKeyring: %v", err)
+	}
+	return k
+}
+
+func TestWrapUnwrap(t *testing.T) {
+	k := testKeyring(t, "2025")
+	key, w, err := k.NewDataKey("doc-1")
+	if err != nil {
+		t.Fatalf("NewDataKey: %v", err)
+	}
+	if len(key) != Size || w.KeyID != "2025" || bytes.Contains(w.Key, key) {
+		t.Fatalf("NewDataKey = %x, %+v", key, w)
+	}
+	got, err := k.Unwrap("doc-1", w)
+	if err != nil || !bytes.Equal(got, key) {
+		t.Fatalf("Unwrap = %x, %v; want %x", got, err, key)
+	}
+	if _, err := k.Unwrap("doc-2", w); err == nil {
+		t.Error("Unwrap accepted the key of another document")
+	}
+	w.KeyID = "2024"
+	if _, err := k.Unwrap("doc-1", w); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Unwrap with unknown master key: %v", err)
+	}
+}
+
+func TestRewrap(t *testing.T) {
+	key, old, err := testKeyring(t, "2025").NewDataKey("doc-1")
+	if err != nil {
+		t.Fatalf("NewDataKey: %v", err)
+	}
+	k := testKeyring(t, "2026")
+	w, err := k.Rewrap("doc-1", old)
+	if err != nil {
+		t.Fatalf("Rewrap: %v", err)
+	}
+	if w.KeyID != "2026" {
+		t.Errorf("rewrapped under %q", w.KeyID)
+	}
+	if got, err := k.Unwrap("doc-1", w); err != nil || !bytes.Equal(got, key) {
+		t.Errorf("Unwrap after Rewrap = %x, %v; want %x", got, err, key)
+	}
+	if again, err := k.Rewrap("doc-1", w); err != nil || !bytes.Equal(again.Key, w.Key) {
+		t.Errorf("Rewrap of a current key changed it: %v", err)
+	}
+}
+
+func TestNewKeyringRejectsBadKeys(t *testing.T) {
+	if _, err := NewKeyring(map[string][]byte{"a": make([]byte, 16)}, "a"); err == nil {
+		t.Error("accepted a 128-bit master key")
+	}
+	if _, err := NewKeyring(map[string][]byte{"a": make([]byte, Size)}, "b"); err == nil {
+		t.Error("accepted an unknown current key")
+	}
+}
+
+func TestUnwrapWithoutKeys(t *testing.T) {
+	_, w, err := testKeyring(t, "2025").NewDataKey("doc-1")
+	if err != nil {
+		t.Fatalf("NewDataKey: %v", err)
+	}
+	var k *Keyring
+	if _, err := k.Unwrap("doc-1", w); !errors.Is(err, ErrNoKeys) {
+		t.Errorf("Unwrap on nil Keyring: %v", err)
+	}
+}
@@ -0,0 +1,330 @@
+package maintenance
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/repository"
+	"github.com/dharsanguruparan/VaultDrop/internal/s3storage"
+)
+
+// TempPattern matches the temp files uploads are spooled to, by the API
+// before storing them and by the worker before extracting them. A worker's
+// spool file lives as long as its extraction, which is why config.Validate
+// wants the cleanup grace to outlast VAULTDROP_EXTRACT_TIMEOUT.
+const TempPattern = "vaultdrop-*.pdf"
+
+// orphanBatch is how many rows or objects the orphan cleanup handles per
+// database round trip.
+const orphanBatch = 500
+
+// maxMissingListed bounds the ids OrphanReport lists; the count is complete.
+const maxMissingListed = 100
+
+// Reclaimed reports files or objects a cleanup removed, or would remove on a
+// dry run.
+type Reclaimed struct {
+	Count int   `json:"count"`
+	Bytes int64 `json:"bytes"`
+}
+
+func (r *Reclaimed) add(size int64) {
+	r.Count++
+	r.Bytes += size
+}
+
+// RemoveStaleTemp removes the upload temp files in dir last modified before
+// before. A process that crashed mid-upload leaves them behind; live ones
+// are younger than any sensible grace period.
+func RemoveStaleTemp(dir string, before time.Time, dryRun bool) (Reclaimed, error) {
+	var res Reclaimed
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return res, err
+	}
+	for _, entry := range entries {
+		if ok, _ := filepath.Match(TempPattern, entry.Name()); !ok || !entry.Type().IsRegular() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || !info.ModTime().Before(before) {
+			continue
+		}
+		if !dryRun {
+			if err := os.Remove(filepath.Join(dir, entry.Name())); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				log.Printf("cleanup: %v", err)
+				continue
+			}
+		}
+		res.add(info.Size())
+	}
+	return res, nil
+}
+
+// SweepTemp runs RemoveStaleTemp on the system temp directory every interval
+// until ctx is done. Temp files are local to each process, so every API and
+// worker replica runs its own sweep.
+func SweepTemp(ctx context.Context, interval, grace time.Duration, dryRun bool) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		res, err := RemoveStaleTemp(os.TempDir(), time.Now().Add(-grace), dryRun)
+		if err != nil {
+			log.Printf("cleanup: temp files: %v", err)
+		}
+		if res.Count > 0 {
+			log.Printf("cleanup: %s %d temp files, %d bytes", verb(dryRun), res.Count, res.Bytes)
+		}
+	}
+}
+
+// OrphanReport reports a CleanOrphans run.
+type OrphanReport struct {
+	// Raw and Processed count objects removed because their document is
+	// gone.
+	Raw       Reclaimed `json:"raw"`
+	Processed Reclaimed `json:"processed"`
+	// MissingRaw counts documents whose raw upload is gone from the bucket
+	// although retention did not purge it, and lists the first of them.
+	// They are only reported: the row may be all that is left to recover.
+	MissingRaw    int      `json:"missingRaw"`
+	MissingRawIDs []string `json:"missingRawIds,omitempty"`
+	// ObjectErrors counts objects that could not be removed; they are logged
+	// and retried on the next run.
+	ObjectErrors int  `json:"objectErrors"`
+	DryRun       bool `json:"dryRun"`
+}
+
+func (r *OrphanReport) missing(id string) {
+	r.MissingRaw++
+	if len(r.MissingRawIDs) < maxMissingListed {
+		r.MissingRawIDs = append(r.MissingRawIDs, id)
+	}
+}
+
+// OrphanDocuments is what CleanOrphans reads from the database;
+// *repository.DocumentRepository implements it.
+type OrphanDocuments interface {
+	ListUploadKeys(ctx context.Context, after string, limit int) ([]repository.UploadKey, error)
+	GetByObjectKey(ctx context.Context, key string) (*repository.Document, error)
+	ExistingDocumentIDs(ctx context.Context, ids []string) (map[string]bool, error)
+}
+
+// OrphanObjects is the part of the object store CleanOrphans walks;
+// *s3storage.Storage implements it.
+type OrphanObjects interface {
+	ListRaw(ctx context.Context, prefix string, fn func(s3storage.ListedObject) error) error
+	RemoveRaw(ctx context.Context, objectKey string) error
+	ListProcessed(ctx context.Context, prefix string, fn func(s3storage.ListedObject) error) error
+	RemoveProcessedObject(ctx context.Context, objectKey string) error
+}
+
+// CleanOrphans compares the objects under the API's prefixes (uploads/ in
+// both buckets and archive/ in the processed one) with the documents table.
+// Objects older than before whose document is gone are removed; documents
+// created before before whose raw upload is missing are reported. Objects
+// ingested from bucket notifications live under other prefixes and are not
+// the API's to remove.
+func CleanOrphans(ctx context.Context, repo OrphanDocuments, store OrphanObjects, before time.Time, dryRun bool) (OrphanReport, error) {
+	rep := OrphanReport{DryRun: dryRun}
+	if err := cleanRaw(ctx, repo, store, before, &rep); err != nil {
+		return rep, err
+	}
+	if err := cleanProcessed(ctx, repo, store, before, &rep); err != nil {
+		return rep, err
+	}
+	return rep, nil
+}
+
+// cleanRaw walks the raw listing and the upload keys of the documents table
+// side by side; both are in byte order, so each key is either in both, only
+// in the bucket (an orphan) or only in the table (missing).
+func cleanRaw(ctx context.Context, repo OrphanDocuments, store OrphanObjects, before time.Time, rep *OrphanReport) error {
+	var (
+		pending   []repository.UploadKey
+		after     string
+		exhausted bool
+	)
+	// peek returns the next upload key, or nil when the table has no more.
+	peek := func() (*repository.UploadKey, error) {
+		if len(pending) == 0 && !exhausted {
+			page, err := repo.ListUploadKeys(ctx, after, orphanBatch)
+			if err != nil {
+				return nil, err
+			}
+			exhausted = len(page) < orphanBatch
+			if len(page) > 0 {
+				after = page[len(page)-1].ObjectKey
+			}
+			pending = page
+		}
+		if len(pending) == 0 {
+			return nil, nil
+		}
+		return &pending[0], nil
+	}
+	missing := func(k repository.UploadKey) {
+		if !k.RawPurged && k.CreatedAt.Before(before) {
+			rep.missing(k.DocumentID)
+		}
+	}
+	err := store.ListRaw(ctx, "uploads/", func(obj s3storage.ListedObject) error {
+		for {
+			k, err := peek()
+			if err != nil {
+				return err
+			}
+			if k == nil || k.ObjectKey > obj.Key {
+				break
+			}
+			pending = pending[1:]
+			if k.ObjectKey == obj.Key {
+				return nil
+			}
+			missing(*k)
+		}
+		if !obj.LastModified.Before(before) {
+			return nil
+		}
+		// The listing is not a snapshot; ask once more right before
+		// removing.
+		if _, err := repo.GetByObjectKey(ctx, obj.Key); !errors.Is(err, repository.ErrNotFound) {
+			return err
+		}
+		return removeOrphan(ctx, store.RemoveRaw, obj, &rep.Raw, rep)
+	})
+	if err != nil {
+		return err
+	}
+	for {
+		k, err := peek()
+		if err != nil {
+			return err
+		}
+		if k == nil {
+			return nil
+		}
+		pending = pending[1:]
+		missing(*k)
+	}
+}
+
+// cleanProcessed removes processed and archived objects whose document is
+// gone. Their keys carry the document id, so they are checked by id in
+// batches.
+func cleanProcessed(ctx context.Context, repo OrphanDocuments, store OrphanObjects, before time.Time, rep *OrphanReport) error {
+	var batch []s3storage.ListedObject
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		ids := make([]string, len(batch))
+		for i, obj := range batch {
+			ids[i] = processedDocumentID(obj.Key)
+		}
+		exists, err := repo.ExistingDocumentIDs(ctx, ids)
+		if err != nil {
+			return err
+		}
+		for i, obj := range batch {
+			if exists[ids[i]] {
+				continue
+			}
+			if err := removeOrphan(ctx, store.RemoveProcessedObject, obj, &rep.Processed, rep); err != nil {
+				return err
+			}
+		}
+		batch = batch[:0]
+		return nil
+	}
+	for _, prefix := range []string{"uploads/", "archive/"} {
+		err := store.ListProcessed(ctx, prefix, func(obj s3storage.ListedObject) error {
+			if !obj.LastModified.Before(before) || processedDocumentID(obj.Key) == "" {
+				return nil
+			}
+			batch = append(batch, obj)
+			if len(batch) < orphanBatch {
+				return nil
+			}
+			return flush()
+		})
+		if err != nil {
+			return err
+		}
+		if err := flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// processedDocumentID returns the document id in a processed key,
+// uploads/<id>/<name>.txt, archive/<id>.txt or archive/<id>/v<n>.txt, or ""
+// for other keys.
+func processedDocumentID(key string) string {
+	if rest, ok := strings.CutPrefix(key, "uploads/"); ok {
+		id, _, ok := strings.Cut(rest, "/")
+		if !ok {
+			return ""
+		}
+		return id
+	}
+	if rest, ok := strings.CutPrefix(key, "archive/"); ok {
+		if id, _, ok := strings.Cut(rest, "/"); ok {
+			return id
+		}
+		id, ok := strings.CutSuffix(rest, ".txt")
+		if !ok {
+			return ""
+		}
+		return id
+	}
+	return ""
+}
+
+func removeOrphan(ctx context.Context, remove func(context.Context, string) error, obj s3storage.ListedObject, into *Reclaimed, rep *OrphanReport) error {
+	if !rep.DryRun {
+		if err := remove(ctx, obj.Key); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			rep.ObjectErrors++
+			log.Printf("cleanup: remove %s: %v", obj.Key, err)
+			return nil
+		}
+	}
+	into.add(obj.Size)
+	return nil
+}
+
+// LogReport logs what a CleanOrphans run reclaimed, if anything.
+func LogReport(rep OrphanReport) {
+	if rep.Raw.Count > 0 || rep.Processed.Count > 0 {
+		log.Printf("cleanup: %s %d raw objects (%d bytes) and %d processed objects (%d bytes) without a document",
+			verb(rep.DryRun), rep.Raw.Count, rep.Raw.Bytes, rep.Processed.Count, rep.Processed.Bytes)
+	}
+	if rep.MissingRaw > 0 {
+		log.Printf("cleanup: %d documents have lost their raw upload, e.g. %s", rep.MissingRaw, strings.Join(rep.MissingRawIDs[:min(len(rep.MissingRawIDs), 5)], ", "))
+	}
+	if rep.ObjectErrors > 0 {
+		log.Printf("cleanup: %d objects could not be removed", rep.ObjectErrors)
+	}
+}
+
+func verb(dryRun bool) string {
+	if dryRun {
+		return "would remove"
+	}
+	return "removed"
+}
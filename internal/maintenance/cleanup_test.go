@@ -0,0 +1,242 @@
+package maintenance
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/repository"
+	"github.com/dharsanguruparan/VaultDrop/internal/s3storage"
+)
+
+func TestRemoveStaleTemp(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	old := now.Add(-2 * time.Hour)
+	for name, mtime := range map[string]time.Time{
+		"vaultdrop-1.pdf":     old, // an API upload spool
+		"vaultdrop-raw-2.pdf": old, // a worker extraction spool
+		"vaultdrop-3.pdf":     now, // still in use
+		"other-4.pdf":         old, // not ours
+		"vaultdrop-5.txt":     old,
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte("12345"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "vaultdrop-dir.pdf"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	before := now.Add(-time.Hour)
+
+	res, err := RemoveStaleTemp(dir, before, true)
+	if err != nil || res.Count != 2 || res.Bytes != 10 {
+		t.Fatalf("dry run = %+v, %v", res, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "vaultdrop-1.pdf")); err != nil {
+		t.Errorf("dry run removed a file: %v", err)
+	}
+
+	res, err = RemoveStaleTemp(dir, before, false)
+	if err != nil || res.Count != 2 || res.Bytes != 10 {
+		t.Fatalf("RemoveStaleTemp = %+v, %v", res, err)
+	}
+	entries, _ := os.ReadDir(dir)
+	var left []string
+	for _, e := range entries {
+		left = append(left, e.Name())
+	}
+	if want := "other-4.pdf vaultdrop-3.pdf vaultdrop-5.txt vaultdrop-dir.pdf"; strings.Join(left, " ") != want {
+		t.Errorf("left %v, want %s", left, want)
+	}
+
+	if _, err := RemoveStaleTemp(filepath.Join(dir, "missing"), before, false); err == nil {
+		t.Error("missing directory reported no error")
+	}
+}
+
+func TestProcessedDocumentID(t *testing.T) {
+	for key, want := range map[string]string{
+		"uploads/doc-1/report.txt":        "doc-1",
+		"uploads/doc-1/report.layout.txt": "doc-1",
+		"archive/doc-2.txt":               "doc-2",
+		"archive/doc-3/v4.txt":            "doc-3",
+		"uploads/doc-4":                   "",
+		"archive/doc-5.bin":               "",
+		"exports/exp-1.zip":               "",
+		"doc-6.txt":                       "",
+	} {
+		if got := processedDocumentID(key); got != want {
+			t.Errorf("processedDocumentID(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
+
+// fakeOrphanDocuments holds the documents table's upload keys in byte order.
+type fakeOrphanDocuments struct {
+	keys []repository.UploadKey
+	// late are keys whose row appears after the listing was taken.
+	late map[string]bool
+}
+
+func (f *fakeOrphanDocuments) ListUploadKeys(ctx context.Context, after string, limit int) ([]repository.UploadKey, error) {
+	i := sort.Search(len(f.keys), func(i int) bool { return f.keys[i].ObjectKey > after })
+	return f.keys[i:min(i+limit, len(f.keys))], nil
+}
+
+func (f *fakeOrphanDocuments) GetByObjectKey(ctx context.Context, key string) (*repository.Document, error) {
+	if f.late[key] {
+		return &repository.Document{ObjectKey: key}, nil
+	}
+	for _, k := range f.keys {
+		if k.ObjectKey == key {
+			return &repository.Document{ID: k.DocumentID, ObjectKey: key}, nil
+		}
+	}
+	return nil, repository.ErrNotFound
+}
+
+func (f *fakeOrphanDocuments) ExistingDocumentIDs(ctx context.Context, ids []string) (map[string]bool, error) {
+	found := make(map[string]bool)
+	for _, id := range ids {
+		for _, k := range f.keys {
+			if k.DocumentID == id {
+				found[id] = true
+			}
+		}
+	}
+	return found, nil
+}
+
+// fakeOrphanObjects lists both buckets in key order.
+type fakeOrphanObjects struct {
+	raw, processed   []s3storage.ListedObject
+	removedRaw       []string
+	removedProcessed []string
+}
+
+func list(objs []s3storage.ListedObject, prefix string, fn func(s3storage.ListedObject) error) error {
+	for _, obj := range objs {
+		if !strings.HasPrefix(obj.Key, prefix) {
+			continue
+		}
+		if err := fn(obj); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *fakeOrphanObjects) ListRaw(ctx context.Context, prefix string, fn func(s3storage.ListedObject) error) error {
+	return list(f.raw, prefix, fn)
+}
+
+func (f *fakeOrphanObjects) ListProcessed(ctx context.Context, prefix string, fn func(s3storage.ListedObject) error) error {
+	return list(f.processed, prefix, fn)
+}
+
+func (f *fakeOrphanObjects) RemoveRaw(ctx context.Context, key string) error {
+	f.removedRaw = append(f.removedRaw, key)
+	return nil
+}
+
+func (f *fakeOrphanObjects) RemoveProcessedObject(ctx context.Context, key string) error {
+	f.removedProcessed = append(f.removedProcessed, key)
+	return nil
+}
+
+func TestCleanOrphans(t *testing.T) {
+	now := time.Now()
+	old, fresh := now.Add(-48*time.Hour), now
+	before := now.Add(-24 * time.Hour)
+	docs := &fakeOrphanDocuments{late: map[string]bool{}}
+	store := &fakeOrphanObjects{}
+	rawKey := func(i int) string { return fmt.Sprintf("uploads/%04d/file.pdf", i) }
+
+	// More rows than one orphanBatch page, so the walk crosses pages.
+	var wantRemoved, wantMissing []string
+	for i := 0; i < 2*orphanBatch+50; i++ {
+		key := rawKey(i)
+		switch {
+		case i%100 == 7:
+			// A stale object without a row: removed.
+			store.raw = append(store.raw, s3storage.ListedObject{Key: key, Size: 3, LastModified: old})
+			wantRemoved = append(wantRemoved, key)
+			continue
+		case i%100 == 8:
+			// A young object without a row is an upload in flight.
+			store.raw = append(store.raw, s3storage.ListedObject{Key: key, Size: 3, LastModified: fresh})
+			continue
+		case i == 9:
+			// Its row was inserted after the table was paged through.
+			store.raw = append(store.raw, s3storage.ListedObject{Key: key, Size: 3, LastModified: old})
+			docs.late[key] = true
+			continue
+		}
+		k := repository.UploadKey{DocumentID: fmt.Sprintf("doc-%04d", i), ObjectKey: key, CreatedAt: old}
+		switch {
+		case i%100 == 11:
+			// The row's object is gone: reported.
+			wantMissing = append(wantMissing, k.DocumentID)
+		case i%100 == 12:
+			// Gone, but retention purged it.
+			k.RawPurged = true
+		case i%100 == 13:
+			// Gone, but the row is too young to tell.
+			k.CreatedAt = fresh
+		default:
+			store.raw = append(store.raw, s3storage.ListedObject{Key: key, Size: 3, LastModified: old})
+		}
+		docs.keys = append(docs.keys, k)
+	}
+	// Rows after the last object are drained too.
+	docs.keys = append(docs.keys, repository.UploadKey{DocumentID: "doc-z", ObjectKey: "uploads/zzzz/file.pdf", CreatedAt: old})
+	wantMissing = append(wantMissing, "doc-z")
+
+	store.processed = []s3storage.ListedObject{
+		{Key: "archive/doc-0001.txt", Size: 5, LastModified: old},
+		{Key: "archive/gone/v1.txt", Size: 5, LastModified: old},
+		{Key: "archive/gone.txt", Size: 5, LastModified: old},
+		{Key: "exports/exp.zip", Size: 5, LastModified: old},
+		{Key: "uploads/doc-0001/file.txt", Size: 5, LastModified: old},
+		{Key: "uploads/gone/file.txt", Size: 5, LastModified: old},
+		{Key: "uploads/young/file.txt", Size: 5, LastModified: fresh},
+	}
+
+	rep, err := CleanOrphans(context.Background(), docs, store, before, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(store.removedRaw) != 0 || len(store.removedProcessed) != 0 {
+		t.Errorf("dry run removed %v %v", store.removedRaw, store.removedProcessed)
+	}
+	if rep.Raw.Count != len(wantRemoved) || rep.Processed.Count != 3 {
+		t.Errorf("dry run report = %+v", rep)
+	}
+
+	rep, err = CleanOrphans(context.Background(), docs, store, before, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(store.removedRaw, " ") != strings.Join(wantRemoved, " ") {
+		t.Errorf("removed raw %v, want %v", store.removedRaw, wantRemoved)
+	}
+	if rep.Raw.Count != len(wantRemoved) || rep.Raw.Bytes != int64(3*len(wantRemoved)) {
+		t.Errorf("raw = %+v", rep.Raw)
+	}
+	if want := "uploads/gone/file.txt archive/gone/v1.txt archive/gone.txt"; strings.Join(store.removedProcessed, " ") != want {
+		t.Errorf("removed processed %v, want %s", store.removedProcessed, want)
+	}
+	if rep.MissingRaw != len(wantMissing) || strings.Join(rep.MissingRawIDs, " ") != strings.Join(wantMissing, " ") {
+		t.Errorf("missing %d %v, want %v", rep.MissingRaw, rep.MissingRawIDs, wantMissing)
+	}
+}
@@ -0,0 +1,131 @@
+// Package maintenance holds the bulk operations shared by the admin API and
+// the vaultdrop CLI. Each one changes rows with a single statement and then
+// does the per-document follow-up (enqueueing, object removal) that cannot
+// happen in SQL.
+package maintenance
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hibiken/asynq"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/queue"
+	"github.com/dharsanguruparan/VaultDrop/internal/repository"
+	"github.com/dharsanguruparan/VaultDrop/internal/s3storage"
+)
+
+// RequeueResult reports a Requeue run.
+type RequeueResult struct {
+	Requeued int `json:"requeued"`
+	// Failed counts documents whose task could not be enqueued; they are
+	// marked failed again so a later run picks them up.
+	Failed int `json:"failed"`
+}
+
+// Requeue moves failed documents that failed at or after since back to queued
+// and enqueues extraction for each. Non-empty ids restricts it to those
+// documents. A zero since and a limit of 0 mean no bound.
+func Requeue(ctx context.Context, repo *repository.DocumentRepository, client *asynq.Client, since time.Time, ids []string, limit int) (RequeueResult, error) {
+	var res RequeueResult
+	docs, err := repo.MarkQueuedWhereFailed(ctx, since, ids, limit)
+	if err != nil {
+		return res, err
+	}
+	for _, doc := range docs {
+		// A document whose earlier task is still retrying is not queued a
+		// second time; it counts as requeued either way.
+		_, err := queue.EnqueueExtract(ctx, client, queue.ExtractPayload{DocumentID: doc.ID})
+		if err == nil {
+			res.Requeued++
+			continue
+		}
+		res.Failed++
+		log.Printf("requeue %s: %v", doc.ID, err)
+		if err := repo.MarkFailed(context.WithoutCancel(ctx), doc.ID, "requeue: "+err.Error()); err != nil {
+			log.Printf("requeue %s: restore failed status: %v", doc.ID, err)
+		}
+	}
+	return res, nil
+}
+
+// ParseAge reads an age for requeue and purge: a Go duration such as "6h", or
+// whole days such as "30d".
+func ParseAge(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("invalid age %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid age %q", s)
+	}
+	return d, nil
+}
+
+// PurgeResult reports a Purge run.
+type PurgeResult struct {
+	Deleted int `json:"deleted"`
+	// ObjectErrors counts objects that could not be removed after their row
+	// was deleted; they are logged and left in the bucket.
+	ObjectErrors int `json:"objectErrors"`
+}
+
+// Purgeable reports whether Purge accepts status. Queued and processing
+// documents are excluded so a purge never races extraction.
+func Purgeable(status repository.DocumentStatus) bool {
+	switch status {
+	case repository.StatusCompleted, repository.StatusFailed, repository.StatusCancelled:
+		return true
+	}
+	return false
+}
+
+// Purge deletes documents created before cutoff in one of statuses, then
+// removes their raw, processed and archived objects.
+func Purge(ctx context.Context, repo *repository.DocumentRepository, store *s3storage.Storage, cutoff time.Time, statuses []repository.DocumentStatus) (PurgeResult, error) {
+	var res PurgeResult
+	for _, status := range statuses {
+		if !Purgeable(status) {
+			return res, fmt.Errorf("cannot purge %s documents", status)
+		}
+	}
+	docs, err := repo.DeleteOlderThan(ctx, cutoff, statuses)
+	if err != nil {
+		return res, err
+	}
+	res.Deleted = len(docs)
+	// The rows are gone, so finish removing objects even if the caller
+	// disconnects.
+	ctx = context.WithoutCancel(ctx)
+	for _, doc := range docs {
+		if err := store.RemoveRaw(ctx, doc.ObjectKey); err != nil {
+			res.ObjectErrors++
+			log.Printf("purge %s: %v", doc.ID, err)
+		}
+		if doc.ProcessedKey != nil {
+			if err := store.RemoveProcessed(ctx, *doc.ProcessedKey); err != nil {
+				res.ObjectErrors++
+				log.Printf("purge %s: %v", doc.ID, err)
+			}
+		}
+		if doc.ArchiveKey != nil {
+			if err := store.RemoveArchive(ctx, *doc.ArchiveKey); err != nil {
+				res.ObjectErrors++
+				log.Printf("purge %s: %v", doc.ID, err)
+			}
+		}
+		if err := store.RemoveVersionArchives(ctx, doc.ID); err != nil {
+			res.ObjectErrors++
+			log.Printf("purge %s: %v", doc.ID, err)
+		}
+	}
+	return res, nil
+}
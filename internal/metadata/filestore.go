@@ -0,0 +1,112 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/model"
+	"github.com/dharsanguruparan/VaultDrop/internal/storage"
+)
+
+// fileStatuses maps the standalone server's statuses onto Status.
+var fileStatuses = map[model.FileStatus]Status{
+	model.StatusUploaded:   StatusPending,
+	model.StatusScanned:    StatusPending,
+	model.StatusQueued:     StatusPending,
+	model.StatusProcessing: StatusProcessing,
+	model.StatusRetrying:   StatusProcessing,
+	model.StatusComplete:   StatusComplete,
+	model.StatusRejected:   StatusFailed,
+	model.StatusFailed:     StatusFailed,
+}
+
+type fileStore struct {
+	store storage.FileStore
+}
+
+// FromFileStore adapts the standalone server's record store, in memory or
+// SQLite, to Store.
+func FromFileStore(store storage.FileStore) Store {
+	return fileStore{store: store}
+}
+
+func (m fileStore) Get(ctx context.Context, id string) (*Record, error) {
+	rec, err := m.store.Get(id)
+	if err != nil {
+		return nil, fileErr(err)
+	}
+	r := fromFileRecord(rec)
+	return &r, nil
+}
+
+func (m fileStore) List(ctx context.Context, filter Filter) (*Page, error) {
+	f := storage.ListFilter{
+		NamePrefix: filter.NamePrefix,
+		Ascending:  filter.Ascending,
+		Limit:      filter.Limit,
+		Cursor:     filter.Cursor,
+	}
+	for _, want := range filter.Statuses {
+		for fs, s := range fileStatuses {
+			if s == want {
+				f.Statuses = append(f.Statuses, fs)
+			}
+		}
+	}
+	if len(filter.Statuses) > 0 && len(f.Statuses) == 0 {
+		// Only statuses this backend never uses were asked for; an empty
+		// status list would mean no filter at all.
+		return &Page{Records: []Record{}}, nil
+	}
+	page, err := m.store.List(f)
+	if err != nil {
+		return nil, fileErr(err)
+	}
+	out := &Page{Records: make([]Record, 0, len(page.Files)), NextCursor: page.NextCursor, Total: int64(page.Total)}
+	for i := range page.Files {
+		out.Records = append(out.Records, fromFileRecord(&page.Files[i]))
+	}
+	return out, nil
+}
+
+func (m fileStore) CountByStatus(ctx context.Context) (map[Status]int64, error) {
+	byFile, err := m.store.CountByStatus()
+	if err != nil {
+		return nil, err
+	}
+	counts := emptyCounts()
+	for fs, n := range byFile {
+		counts[fileStatuses[fs]] += int64(n)
+	}
+	return counts, nil
+}
+
+func (m fileStore) MarkProcessing(ctx context.Context, id string) error {
+	return fileErr(m.store.UpdateStatus(id, model.StatusProcessing, "processing started"))
+}
+
+func (m fileStore) MarkFailed(ctx context.Context, id, msg string) error {
+	return fileErr(m.store.UpdateStatus(id, model.StatusFailed, msg))
+}
+
+func fromFileRecord(rec *model.FileRecord) Record {
+	return Record{
+		ID:        rec.ID,
+		Name:      rec.Name,
+		Status:    fileStatuses[rec.Status],
+		Message:   rec.Message,
+		CreatedAt: rec.CreatedAt,
+		UpdatedAt: rec.UpdatedAt,
+	}
+}
+
+// fileErr translates storage errors into this package's sentinels.
+func fileErr(err error) error {
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		return ErrNotFound
+	case errors.Is(err, storage.ErrInvalidCursor):
+		return ErrInvalidCursor
+	}
+	return err
+}
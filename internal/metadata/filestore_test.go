@@ -0,0 +1,119 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/model"
+	"github.com/dharsanguruparan/VaultDrop/internal/storage"
+)
+
+// backends builds each standalone backend the adapter is tested against.
+// sqlite_test.go adds SQLite when cgo is available, since its driver needs it.
+var backends = map[string]func(t *testing.T) storage.FileStore{
+	"memory": func(*testing.T) storage.FileStore { return storage.NewMemoryStore() },
+}
+
+// TestFileStoreAdapter runs the same checks against both standalone backends.
+func TestFileStoreAdapter(t *testing.T) {
+	for name, open := range backends {
+		t.Run(name, func(t *testing.T) { testFileStoreAdapter(t, open(t)) })
+	}
+}
+
+func testFileStoreAdapter(t *testing.T, backend storage.FileStore) {
+	ctx := context.Background()
+	for _, rec := range []struct {
+		id     string
+		status model.FileStatus
+	}{
+		{"a", model.StatusScanned},
+		{"b", model.StatusRetrying},
+		{"c", model.StatusRejected},
+		{"d", model.StatusComplete},
+	} {
+		if err := backend.Save(&model.FileRecord{ID: rec.id, Name: rec.id + ".pdf", Status: rec.status}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	store := FromFileStore(backend)
+
+	rec, err := store.Get(ctx, "b")
+	if err != nil || rec.Status != StatusProcessing || rec.Name != "b.pdf" {
+		t.Fatalf("Get(b) = %+v, %v", rec, err)
+	}
+	if _, err := store.Get(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get(missing) error = %v, want ErrNotFound", err)
+	}
+
+	counts, err := store.CountByStatus(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[Status]int64{StatusPending: 1, StatusProcessing: 1, StatusComplete: 1, StatusFailed: 1, StatusCancelled: 0}
+	for s, n := range want {
+		if counts[s] != n {
+			t.Errorf("counts[%s] = %d, want %d", s, counts[s], n)
+		}
+	}
+
+	page, err := store.List(ctx, Filter{Statuses: []Status{StatusFailed}})
+	if err != nil || len(page.Records) != 1 || page.Records[0].ID != "c" {
+		t.Fatalf("List(failed) = %+v, %v", page, err)
+	}
+	page, err = store.List(ctx, Filter{Statuses: []Status{StatusCancelled}})
+	if err != nil || len(page.Records) != 0 {
+		t.Errorf("List(cancelled) = %+v, %v; standalone stores never cancel", page, err)
+	}
+	if _, err := store.List(ctx, Filter{Cursor: "bogus!"}); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("List(bad cursor) error = %v, want ErrInvalidCursor", err)
+	}
+
+	var seen []string
+	filter := Filter{Ascending: true, Limit: 3}
+	for {
+		page, err := store.List(ctx, filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if page.Total != 4 {
+			t.Errorf("Total = %d, want 4", page.Total)
+		}
+		for _, r := range page.Records {
+			seen = append(seen, r.ID)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		filter.Cursor = page.NextCursor
+	}
+	if len(seen) != 4 || seen[0] != "a" || seen[3] != "d" {
+		t.Errorf("paged through %v, want a b c d", seen)
+	}
+
+	files, err := backend.List(storage.ListFilter{Ascending: true, Limit: 2, Offset: 1})
+	if err != nil || len(files.Files) != 2 || files.Files[0].ID != "b" || files.Files[1].ID != "c" {
+		t.Errorf("List(offset 1) = %+v, %v", files, err)
+	}
+
+	if err := store.MarkFailed(ctx, "a", "boom"); err != nil {
+		t.Fatal(err)
+	}
+	if rec, _ := store.Get(ctx, "a"); rec.Status != StatusFailed || rec.Message != "boom" {
+		t.Errorf("after MarkFailed: %+v", rec)
+	}
+	if err := store.MarkProcessing(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("MarkProcessing(missing) error = %v, want ErrNotFound", err)
+	}
+
+	if err := backend.SetAttributes("d", map[string]string{"sha256": "x"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := backend.SetAttributes("d", map[string]string{"thumbnail": "1x1"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := backend.Get("d"); got.Attributes["sha256"] != "x" || got.Attributes["thumbnail"] != "1x1" {
+		t.Errorf("attributes = %v", got.Attributes)
+	}
+}
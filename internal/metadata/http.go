@@ -0,0 +1,29 @@
+package metadata
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/httperr"
+)
+
+// CountsHandler serves GET requests with the number of files in each Status,
+// the same summary whichever backend store holds. Callers mount it behind
+// whatever access check their stack uses.
+func CountsHandler(store Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			httperr.MethodNotAllowed(w)
+			return
+		}
+		counts, err := store.CountByStatus(r.Context())
+		if err != nil {
+			log.Printf("count files by status: %v", err)
+			httperr.Internal(w, "failed to count files")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(counts)
+	}
+}
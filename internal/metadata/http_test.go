@@ -0,0 +1,46 @@
+package metadata
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/model"
+	"github.com/dharsanguruparan/VaultDrop/internal/storage"
+)
+
+func TestCountsHandler(t *testing.T) {
+	backend := storage.NewMemoryStore()
+	for id, status := range map[string]model.FileStatus{"a": model.StatusQueued, "b": model.StatusRetrying, "c": model.StatusRejected} {
+		if err := backend.Save(&model.FileRecord{ID: id, Status: status}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h := CountsHandler(FromFileStore(backend))
+
+	rec := httptest.NewRecorder()
+	h(rec, httptest.NewRequest(http.MethodGet, "/files/summary", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d", rec.Code)
+	}
+	var counts map[Status]int64
+	if err := json.NewDecoder(rec.Body).Decode(&counts); err != nil {
+		t.Fatal(err)
+	}
+	want := map[Status]int64{StatusPending: 1, StatusProcessing: 1, StatusComplete: 0, StatusFailed: 1, StatusCancelled: 0}
+	if len(counts) != len(want) {
+		t.Errorf("counts = %v, want %v", counts, want)
+	}
+	for s, n := range want {
+		if counts[s] != n {
+			t.Errorf("counts[%s] = %d, want %d", s, counts[s], n)
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	h(rec, httptest.NewRequest(http.MethodPost, "/files/summary", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST status = %d, want 405", rec.Code)
+	}
+}
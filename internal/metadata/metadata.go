@@ -0,0 +1,84 @@
+// Package metadata defines the file metadata store both server stacks share.
+// The standalone server keeps FileRecords in a storage.FileStore while the
+// API keeps Documents in Postgres; each gets an adapter here so handlers,
+// processing and tests can be written once against Store.
+package metadata
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Status is the lifecycle stage of a file, normalized across backends. Each
+// backend's finer-grained statuses map onto one of these.
+type Status string
+
+const (
+	// StatusPending covers files waiting to be processed.
+	StatusPending    Status = "pending"
+	StatusProcessing Status = "processing"
+	StatusComplete   Status = "complete"
+	// StatusFailed includes uploads rejected before processing.
+	StatusFailed    Status = "failed"
+	StatusCancelled Status = "cancelled"
+)
+
+// Statuses lists every Status in lifecycle order.
+var Statuses = []Status{StatusPending, StatusProcessing, StatusComplete, StatusFailed, StatusCancelled}
+
+var (
+	// ErrNotFound is returned for an ID the store does not hold.
+	ErrNotFound = errors.New("file not found")
+	// ErrInvalidCursor is returned by List for a cursor it did not issue.
+	ErrInvalidCursor = errors.New("invalid cursor")
+)
+
+// Record is the metadata every backend can provide for a file.
+type Record struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Status Status `json:"status"`
+	// Message is the latest status message or error, if any.
+	Message   string    `json:"message,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Filter narrows and pages List results. Zero values mean no filter.
+type Filter struct {
+	Statuses   []Status
+	NamePrefix string
+	// Ascending lists oldest first; the default is newest first.
+	Ascending bool
+	// Limit defaults to 50 and is capped at 500 by both backends.
+	Limit int
+	// Cursor is the NextCursor of the previous page.
+	Cursor string
+}
+
+// Page is one page of List results.
+type Page struct {
+	Records    []Record `json:"records"`
+	NextCursor string   `json:"nextCursor,omitempty"`
+	// Total counts every match, ignoring the cursor.
+	Total int64 `json:"total"`
+}
+
+// Store reads file metadata and records processing outcomes.
+type Store interface {
+	Get(ctx context.Context, id string) (*Record, error)
+	List(ctx context.Context, filter Filter) (*Page, error)
+	// CountByStatus has an entry for every Status, zero when unused.
+	CountByStatus(ctx context.Context) (map[Status]int64, error)
+	MarkProcessing(ctx context.Context, id string) error
+	MarkFailed(ctx context.Context, id, msg string) error
+}
+
+func emptyCounts() map[Status]int64 {
+	counts := make(map[Status]int64, len(Statuses))
+	for _, s := range Statuses {
+		counts[s] = 0
+	}
+	return counts
+}
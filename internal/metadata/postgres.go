@@ -0,0 +1,114 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/repository"
+)
+
+// documentStatuses maps the API's document statuses onto Status.
+var documentStatuses = map[repository.DocumentStatus]Status{
+	repository.StatusQueued:     StatusPending,
+	repository.StatusProcessing: StatusProcessing,
+	repository.StatusCompleted:  StatusComplete,
+	repository.StatusFailed:     StatusFailed,
+	repository.StatusCancelled:  StatusCancelled,
+}
+
+type documentStore struct {
+	repo *repository.DocumentRepository
+}
+
+// FromRepository adapts the Postgres DocumentRepository to Store. It sees
+// every owner's documents, so callers scope access themselves.
+func FromRepository(repo *repository.DocumentRepository) Store {
+	return documentStore{repo: repo}
+}
+
+func (d documentStore) Get(ctx context.Context, id string) (*Record, error) {
+	doc, err := d.repo.Get(ctx, id)
+	if err != nil {
+		return nil, documentErr(err)
+	}
+	r := fromDocument(doc)
+	return &r, nil
+}
+
+func (d documentStore) List(ctx context.Context, filter Filter) (*Page, error) {
+	f := repository.ListFilter{
+		FileNamePrefix: filter.NamePrefix,
+		Ascending:      filter.Ascending,
+		Limit:          filter.Limit,
+		Cursor:         filter.Cursor,
+		WithTotal:      true,
+	}
+	for _, want := range filter.Statuses {
+		for ds, s := range documentStatuses {
+			if s == want {
+				f.Statuses = append(f.Statuses, ds)
+			}
+		}
+	}
+	if len(filter.Statuses) > 0 && len(f.Statuses) == 0 {
+		return &Page{Records: []Record{}}, nil
+	}
+	page, err := d.repo.List(ctx, f)
+	if err != nil {
+		return nil, documentErr(err)
+	}
+	out := &Page{Records: make([]Record, 0, len(page.Documents)), NextCursor: page.NextCursor}
+	if page.Total != nil {
+		out.Total = *page.Total
+	}
+	for i := range page.Documents {
+		out.Records = append(out.Records, fromDocument(&page.Documents[i]))
+	}
+	return out, nil
+}
+
+func (d documentStore) CountByStatus(ctx context.Context) (map[Status]int64, error) {
+	byDoc, err := d.repo.CountByStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+	counts := emptyCounts()
+	for ds, n := range byDoc {
+		counts[documentStatuses[ds]] += n
+	}
+	return counts, nil
+}
+
+func (d documentStore) MarkProcessing(ctx context.Context, id string) error {
+	return documentErr(d.repo.MarkProcessing(ctx, id))
+}
+
+func (d documentStore) MarkFailed(ctx context.Context, id, msg string) error {
+	return documentErr(d.repo.MarkFailed(ctx, id, msg))
+}
+
+func fromDocument(doc *repository.Document) Record {
+	r := Record{
+		ID:        doc.ID,
+		Name:      doc.FileName,
+		Status:    documentStatuses[doc.Status],
+		CreatedAt: doc.CreatedAt,
+		UpdatedAt: doc.UpdatedAt,
+	}
+	if doc.ErrorMessage != nil {
+		r.Message = *doc.ErrorMessage
+	}
+	return r
+}
+
+// documentErr translates repository errors into this package's sentinels.
+// ErrCancelled passes through unchanged.
+func documentErr(err error) error {
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		return ErrNotFound
+	case errors.Is(err, repository.ErrInvalidCursor):
+		return ErrInvalidCursor
+	}
+	return err
+}
@@ -0,0 +1,21 @@
+//go:build cgo
+
+package metadata
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/storage"
+)
+
+func init() {
+	backends["sqlite"] = func(t *testing.T) storage.FileStore {
+		db, err := storage.OpenSQLite(filepath.Join(t.TempDir(), "files.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+		return db
+	}
+}
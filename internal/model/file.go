@@ -16,11 +16,20 @@ const (
 	StatusScanned    FileStatus = "scanned"
 	StatusQueued     FileStatus = "queued"
 	StatusProcessing FileStatus = "processing"
+	// StatusRetrying marks a failed run waiting out its backoff before the
+	// next attempt.
+	StatusRetrying   FileStatus = "retrying"
 	StatusComplete   FileStatus = "complete"
 	StatusRejected   FileStatus = "rejected"
 	StatusFailed     FileStatus = "failed"
 )
 
+// Statuses lists every FileStatus in lifecycle order.
+var Statuses = []FileStatus{
+	StatusUploaded, StatusScanned, StatusQueued, StatusProcessing,
+	StatusRetrying, StatusComplete, StatusRejected, StatusFailed,
+}
+
 // FileRecord holds metadata about an uploaded file. Struct tags such as
 // `json:"id"` instruct the encoding/json package to use custom field names when
 // marshalling/unmarshalling.
@@ -37,4 +46,7 @@ type FileRecord struct {
 	UpdatedAt   time.Time  `json:"updatedAt"`
 	// omitempty instructs encoders to drop the field when empty.
 	Message     string     `json:"message,omitempty"`
+	// Attributes holds what processing stages learned about the file, such
+	// as its checksum.
+	Attributes  map[string]string `json:"attributes,omitempty"`
 }
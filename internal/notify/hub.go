@@ -0,0 +1,209 @@
+// Package notify fans out document status changes to in-process subscribers.
+// Changes arrive through Postgres LISTEN/NOTIFY; when notifications are not
+// available (e.g. behind a transaction-pooling proxy) the hub polls the
+// documents table for the ids that currently have subscribers.
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/repository"
+)
+
+// Channel is the Postgres notification channel written by the documents trigger.
+const Channel = "document_status"
+
+const (
+	subscriberBuffer = 8
+	listenRetryDelay = 30 * time.Second
+)
+
+// Update is a single status transition.
+type Update struct {
+	DocumentID string `json:"id"`
+	Status     string `json:"status"`
+}
+
+// Hub delivers Updates to subscribers keyed by document id.
+type Hub struct {
+	pool         *pgxpool.Pool
+	pollInterval time.Duration
+
+	mu   sync.Mutex
+	subs map[string]map[chan Update]struct{}
+}
+
+// NewHub constructs a Hub. pollInterval is used only while LISTEN is unavailable.
+func NewHub(pool *pgxpool.Pool, pollInterval time.Duration) *Hub {
+	if pollInterval <= 0 {
+		pollInterval = 2 * time.Second
+	}
+	return &Hub{
+		pool:         pool,
+		pollInterval: pollInterval,
+		subs:         make(map[string]map[chan Update]struct{}),
+	}
+}
+
+// Subscribe returns a channel receiving updates for the document and a func
+// that must be called to release it.
+func (h *Hub) Subscribe(documentID string) (<-chan Update, func()) {
+	ch := make(chan Update, subscriberBuffer)
+	h.mu.Lock()
+	if h.subs[documentID] == nil {
+		h.subs[documentID] = make(map[chan Update]struct{})
+	}
+	h.subs[documentID][ch] = struct{}{}
+	h.mu.Unlock()
+	return ch, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		delete(h.subs[documentID], ch)
+		if len(h.subs[documentID]) == 0 {
+			delete(h.subs, documentID)
+		}
+	}
+}
+
+// Run listens for notifications until ctx is cancelled, falling back to
+// polling whenever the LISTEN connection cannot be established or drops.
+func (h *Hub) Run(ctx context.Context) {
+	for {
+		err := h.listen(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("status notifications unavailable, polling for %s: %v", listenRetryDelay, err)
+		pollCtx, cancel := context.WithTimeout(ctx, listenRetryDelay)
+		h.poll(pollCtx)
+		cancel()
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+func (h *Hub) listen(ctx context.Context) error {
+	conn, err := h.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	// The connection is left in LISTEN state, so it is closed rather than
+	// returned to the pool.
+	defer func() {
+		conn.Conn().Close(context.Background())
+		conn.Release()
+	}()
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{Channel}.Sanitize()); err != nil {
+		return err
+	}
+	for {
+		n, err := conn.Conn().WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		var update Update
+		if err := json.Unmarshal([]byte(n.Payload), &update); err != nil {
+			log.Printf("decode status notification: %v", err)
+			continue
+		}
+		h.broadcast(update)
+	}
+}
+
+// poll reads the status of subscribed documents whose change_seq moved,
+// holding back recent changes like the changefeed does so a write that
+// commits late is not skipped.
+func (h *Hub) poll(ctx context.Context) {
+	ticker := time.NewTicker(h.pollInterval)
+	defer ticker.Stop()
+	since, err := repository.SettledChangeSeq(ctx, h.pool, 0)
+	if err != nil {
+		log.Printf("poll document status: %v", err)
+		return
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		until, err := repository.SettledChangeSeq(ctx, h.pool, since)
+		if err != nil {
+			log.Printf("poll document status: %v", err)
+			continue
+		}
+		ids := h.subscribedIDs()
+		if len(ids) == 0 || until == since {
+			since = until
+			continue
+		}
+		rows, err := h.pool.Query(ctx, `
+			SELECT id, status FROM documents
+			WHERE id = ANY($1) AND change_seq > $2 AND change_seq <= $3
+			ORDER BY change_seq
+		`, ids, since, until)
+		if err != nil {
+			log.Printf("poll document status: %v", err)
+			continue
+		}
+		read := true
+		for rows.Next() {
+			var update Update
+			if err := rows.Scan(&update.DocumentID, &update.Status); err != nil {
+				log.Printf("scan document status: %v", err)
+				read = false
+				break
+			}
+			h.broadcast(update)
+		}
+		rows.Close()
+		// A failed read is retried from the same cursor next tick.
+		if read && rows.Err() == nil {
+			since = until
+		}
+	}
+}
+
+func (h *Hub) subscribedIDs() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	ids := make([]string, 0, len(h.subs))
+	for id := range h.subs {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func (h *Hub) broadcast(update Update) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subs[update.DocumentID] {
+		send(ch, update)
+	}
+}
+
+// send delivers update without blocking. A slow subscriber whose buffer is
+// full loses its oldest pending update instead of this one, so the latest
+// status always reaches it. Only broadcast sends, under h.mu, so the slot
+// freed is still free when the send is retried.
+func send(ch chan Update, update Update) {
+	for {
+		select {
+		case ch <- update:
+			return
+		default:
+		}
+		select {
+		case <-ch:
+		default:
+		}
+	}
+}
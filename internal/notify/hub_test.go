@@ -0,0 +1,33 @@
+package notify
+
+import "testing"
+
+func TestBroadcastKeepsLatestForSlowSubscriber(t *testing.T) {
+	h := NewHub(nil, 0)
+	updates, unsubscribe := h.Subscribe("doc-1")
+	defer unsubscribe()
+	statuses := []string{"queued", "processing", "processing", "processing", "processing", "processing", "processing", "processing", "processing", "completed"}
+	for _, status := range statuses {
+		h.broadcast(Update{DocumentID: "doc-1", Status: status})
+	}
+	if len(updates) != subscriberBuffer {
+		t.Fatalf("buffered %d updates, want %d", len(updates), subscriberBuffer)
+	}
+	var last Update
+	for len(updates) > 0 {
+		last = <-updates
+	}
+	if last.Status != "completed" {
+		t.Errorf("last update = %q, want completed", last.Status)
+	}
+}
+
+func TestBroadcastOnlyReachesSubscribersOfTheDocument(t *testing.T) {
+	h := NewHub(nil, 0)
+	updates, unsubscribe := h.Subscribe("doc-1")
+	defer unsubscribe()
+	h.broadcast(Update{DocumentID: "doc-2", Status: "completed"})
+	if len(updates) != 0 {
+		t.Errorf("got %d updates for another document", len(updates))
+	}
+}
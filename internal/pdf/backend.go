@@ -0,0 +1,157 @@
+package pdfutil
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Extraction backends selectable with VAULTDROP_EXTRACT_BACKEND.
+const (
+	// BackendGo is the pure-Go ledongthuc/pdf parser.
+	BackendGo = "go"
+	// BackendPdftotext runs poppler's pdftotext command.
+	BackendPdftotext = "pdftotext"
+	// BackendPdfium links Google's pdfium through cgo; binaries must be
+	// built with the pdfium tag.
+	BackendPdfium = "pdfium"
+)
+
+// Backends lists the extraction backends in the order they are documented.
+var Backends = []string{BackendGo, BackendPdftotext, BackendPdfium}
+
+// Extractor turns the PDF at path into text. Pages after the first start with
+// PageBreak. onPage, when not nil, is called before each page a backend can
+// report progress for.
+type Extractor interface {
+	Name() string
+	Extract(ctx context.Context, path string, onPage func(page, total int)) (string, error)
+}
+
+// NewExtractor returns the backend called name; an empty name is BackendGo.
+// tool is the pdftotext executable, looked up in PATH when it has no
+// directory.
+func NewExtractor(name, tool string) (Extractor, error) {
+	switch name {
+	case "", BackendGo:
+		return GoExtractor{}, nil
+	case BackendPdftotext:
+		if tool == "" {
+			tool = "pdftotext"
+		}
+		// Resolve the path now: a missing tool should stop startup, and the
+		// sandbox child runs without PATH.
+		path, err := exec.LookPath(tool)
+		if err != nil {
+			return nil, fmt.Errorf("pdftotext backend: %w", err)
+		}
+		return PdftotextExtractor{Command: path}, nil
+	case BackendPdfium:
+		return newPdfiumExtractor()
+	default:
+		return nil, fmt.Errorf("unknown extraction backend %q; available: %s", name, strings.Join(Backends, ", "))
+	}
+}
+
+// Extract checks the PDF at path against limits and extracts it with ex. The
+// limits are checked with the pure-Go parser. A PDF that parser cannot open
+// is still handed to any other backend unchecked, since coping with such
+// files is why that backend was chosen; the extraction deadline bounds it.
+func Extract(ctx context.Context, ex Extractor, path string, limits Limits, onPage func(page, total int)) (string, error) {
+	if err := checkFile(ctx, ex, path, limits); err != nil {
+		return "", err
+	}
+	return ex.Extract(ctx, path, onPage)
+}
+
+// ExtractLayout is Extract for layout-preserving text. Backends that do not
+// implement LayoutExtractor fall back to the pure-Go one.
+func ExtractLayout(ctx context.Context, ex Extractor, path string, limits Limits, onPage func(page, total int)) (string, error) {
+	if err := checkFile(ctx, ex, path, limits); err != nil {
+		return "", err
+	}
+	layout, ok := ex.(LayoutExtractor)
+	if !ok {
+		layout = GoExtractor{}
+	}
+	return layout.ExtractLayout(ctx, path, onPage)
+}
+
+func checkFile(ctx context.Context, ex Extractor, path string, limits Limits) error {
+	if !limits.enabled() {
+		return nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err == nil {
+		err = CheckLimits(ctx, f, info.Size(), limits)
+	}
+	if err == nil || errors.Is(err, ErrLimitExceeded) || ctx.Err() != nil || ex.Name() == BackendGo {
+		// With the Go backend the extraction would fail the same way.
+		return err
+	}
+	return nil
+}
+
+// GoExtractor extracts with ExtractTextAt.
+type GoExtractor struct{}
+
+// Name implements Extractor.
+func (GoExtractor) Name() string { return BackendGo }
+
+// Extract implements Extractor.
+func (GoExtractor) Extract(ctx context.Context, path string, onPage func(page, total int)) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	if info.Size() == 0 {
+		return "", errors.New("empty file")
+	}
+	return ExtractTextAt(ctx, f, info.Size(), onPage)
+}
+
+// PdftotextExtractor runs poppler's pdftotext. It reports no page progress.
+type PdftotextExtractor struct {
+	Command string
+}
+
+// Name implements Extractor.
+func (PdftotextExtractor) Name() string { return BackendPdftotext }
+
+// Extract implements Extractor. pdftotext ends every page with a form feed,
+// so dropping the last one leaves PageBreak between pages.
+func (e PdftotextExtractor) Extract(ctx context.Context, path string, _ func(page, total int)) (string, error) {
+	return e.run(ctx, path)
+}
+
+func (e PdftotextExtractor) run(ctx context.Context, path string, flags ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	args := append([]string{"-enc", "UTF-8", "-q"}, flags...)
+	cmd := exec.CommandContext(ctx, e.Command, append(args, path, "-")...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return "", fmt.Errorf("pdftotext: %w", ctxErr)
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("pdftotext: %v: %s", err, msg)
+		}
+		return "", fmt.Errorf("pdftotext: %w", err)
+	}
+	return strings.ToValidUTF8(strings.TrimSuffix(stdout.String(), PageBreak), "\uFFFD"), nil
+}
@@ -0,0 +1,38 @@
+package pdfutil
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fakeExtractor stands in for a backend other than the Go parser.
+type fakeExtractor struct{}
+
+func (fakeExtractor) Name() string { return BackendPdftotext }
+
+func (fakeExtractor) Extract(context.Context, string, func(page, total int)) (string, error) {
+	return "text", nil
+}
+
+func TestExtractUnparsableFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "broken.pdf")
+	if err := os.WriteFile(path, []byte("%PDF-1.7 not really"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	limits := Limits{MaxPages: 10}
+	// Other backends get the files the Go parser cannot open, sandbox or not.
+	text, err := Extract(context.Background(), fakeExtractor{}, path, limits, nil)
+	if err != nil || text != "text" {
+		t.Fatalf("Extract with %s = %q, %v", BackendPdftotext, text, err)
+	}
+	if _, err := Extract(context.Background(), fakeExtractor{}, path, Limits{}, nil); err != nil {
+		t.Fatalf("Extract without limits = %v", err)
+	}
+	// The Go backend fails on them up front, and not as a limit.
+	if _, err := Extract(context.Background(), GoExtractor{}, path, limits, nil); err == nil || errors.Is(err, ErrLimitExceeded) {
+		t.Fatalf("Extract with %s = %v, want the parse error", BackendGo, err)
+	}
+}
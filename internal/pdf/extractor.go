@@ -2,6 +2,7 @@ package pdfutil
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"strings"
@@ -11,14 +12,36 @@ import (
 
 // ExtractText reads PDF bytes and returns plain text using ledongthuc/pdf.
 func ExtractText(data []byte) (string, error) {
-	reader := bytes.NewReader(data)
-	doc, err := pdf.NewReader(reader, int64(len(data)))
+	return ExtractTextAt(context.Background(), bytes.NewReader(data), int64(len(data)), nil)
+}
+
+// PageBreak separates pages in extracted text, as pdftotext does, so page
+// boundaries survive in the stored text.
+const PageBreak = "\f"
+
+// ExtractTextAt extracts from a PDF of size bytes read through r. The parser
+// reads objects on demand, so a file-backed r keeps memory bounded by the
+// pages being decoded rather than the file size. Pages after the first start
+// with PageBreak, empty ones included. onPage, when not nil, is called before
+// each page is read. It stops between pages once ctx is done and returns the
+// context's error.
+func ExtractTextAt(ctx context.Context, r io.ReaderAt, size int64, onPage func(page, total int)) (string, error) {
+	doc, err := pdf.NewReader(r, size)
 	if err != nil {
 		return "", fmt.Errorf("new pdf reader: %w", err)
 	}
 	var builder strings.Builder
 	total := doc.NumPage()
 	for page := 1; page <= total; page++ {
+		if err := ctx.Err(); err != nil {
+			return "", fmt.Errorf("page %d of %d: %w", page, total, err)
+		}
+		if onPage != nil {
+			onPage(page, total)
+		}
+		if page > 1 {
+			builder.WriteString(PageBreak)
+		}
 		p := doc.Page(page)
 		if p.V.IsNull() {
 			continue
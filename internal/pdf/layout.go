@@ -0,0 +1,176 @@
+package pdfutil
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strings"
+
+	pdf "github.com/ledongthuc/pdf"
+)
+
+// LayoutExtractor is implemented by backends that can keep a page's layout:
+// text is placed on a character grid from glyph coordinates, so columns stay
+// side by side, lines break where they do on the page, and larger vertical
+// gaps become blank lines.
+type LayoutExtractor interface {
+	ExtractLayout(ctx context.Context, path string, onPage func(page, total int)) (string, error)
+}
+
+// ExtractLayout implements LayoutExtractor.
+func (GoExtractor) ExtractLayout(ctx context.Context, path string, onPage func(page, total int)) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	return ExtractLayoutAt(ctx, f, info.Size(), onPage)
+}
+
+// ExtractLayout implements LayoutExtractor with pdftotext -layout.
+func (e PdftotextExtractor) ExtractLayout(ctx context.Context, path string, _ func(page, total int)) (string, error) {
+	return e.run(ctx, path, "-layout")
+}
+
+// ExtractLayoutAt is ExtractTextAt with the page layout kept. Pages are
+// separated by PageBreak as in ExtractTextAt.
+func ExtractLayoutAt(ctx context.Context, r io.ReaderAt, size int64, onPage func(page, total int)) (string, error) {
+	doc, err := pdf.NewReader(r, size)
+	if err != nil {
+		return "", fmt.Errorf("new pdf reader: %w", err)
+	}
+	var builder strings.Builder
+	total := doc.NumPage()
+	for page := 1; page <= total; page++ {
+		if err := ctx.Err(); err != nil {
+			return "", fmt.Errorf("page %d of %d: %w", page, total, err)
+		}
+		if onPage != nil {
+			onPage(page, total)
+		}
+		if page > 1 {
+			builder.WriteString(PageBreak)
+		}
+		p := doc.Page(page)
+		if p.V.IsNull() {
+			continue
+		}
+		content, err := pageContent(p)
+		if err != nil {
+			return "", fmt.Errorf("page %d: %w", page, err)
+		}
+		builder.WriteString(layoutPage(content.Text))
+	}
+	return builder.String(), nil
+}
+
+// pageContent reads the positioned glyphs of p. The parser panics on
+// malformed content streams.
+func pageContent(p pdf.Page) (content pdf.Content, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("read page content: %v", r)
+		}
+	}()
+	return p.Content(), nil
+}
+
+// layoutLine is the glyphs sharing a baseline.
+type layoutLine struct {
+	y, size float64
+	glyphs  []pdf.Text
+}
+
+// layoutPage places glyphs on a grid whose cell is the typical glyph width, so
+// text that starts at the same x starts in the same column.
+func layoutPage(glyphs []pdf.Text) string {
+	var kept []pdf.Text
+	visible := false
+	for _, g := range glyphs {
+		if g.S != "" {
+			kept = append(kept, g)
+			visible = visible || strings.TrimSpace(g.S) != ""
+		}
+	}
+	if !visible {
+		return ""
+	}
+	cell := cellWidth(kept)
+	left := kept[0].X
+	for _, g := range kept {
+		left = math.Min(left, g.X)
+	}
+	// Top to bottom, then left to right; PDF y grows upwards.
+	sort.SliceStable(kept, func(i, j int) bool {
+		if kept[i].Y != kept[j].Y {
+			return kept[i].Y > kept[j].Y
+		}
+		return kept[i].X < kept[j].X
+	})
+	var lines []*layoutLine
+	for _, g := range kept {
+		if n := len(lines); n > 0 && math.Abs(lines[n-1].y-g.Y) <= math.Max(lines[n-1].size/2, 1) {
+			lines[n-1].glyphs = append(lines[n-1].glyphs, g)
+			continue
+		}
+		lines = append(lines, &layoutLine{y: g.Y, size: g.FontSize, glyphs: []pdf.Text{g}})
+	}
+	var b strings.Builder
+	for i, line := range lines {
+		// A gap of more than one and a half lines is a paragraph break.
+		if i > 0 && lines[i-1].y-line.y > 1.5*math.Max(line.size, 1) {
+			b.WriteString("\n")
+		}
+		sort.SliceStable(line.glyphs, func(a, c int) bool { return line.glyphs[a].X < line.glyphs[c].X })
+		var row []rune
+		end := math.Inf(-1)
+		for _, g := range line.glyphs {
+			col := int(math.Round((g.X - left) / cell))
+			for len(row) < col {
+				row = append(row, ' ')
+			}
+			// Keep words apart when the grid squeezes a real gap shut.
+			if len(row) > 0 && row[len(row)-1] != ' ' && g.X-end > cell/3 {
+				row = append(row, ' ')
+			}
+			runes := []rune(g.S)
+			row = append(row, runes...)
+			if end = g.X + g.W; g.W <= 0 {
+				end = g.X + cell*float64(len(runes))
+			}
+		}
+		b.WriteString(strings.TrimRight(string(row), " "))
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// cellWidth is the median width of single-character glyphs, falling back to
+// half the median font size for fonts without width tables.
+func cellWidth(glyphs []pdf.Text) float64 {
+	var widths, sizes []float64
+	for _, g := range glyphs {
+		if g.W > 0 && len([]rune(g.S)) == 1 {
+			widths = append(widths, g.W)
+		}
+		if g.FontSize > 0 {
+			sizes = append(sizes, g.FontSize/2)
+		}
+	}
+	for _, values := range [][]float64{widths, sizes} {
+		if len(values) > 0 {
+			sort.Float64s(values)
+			if w := values[len(values)/2]; w > 0 {
+				return w
+			}
+		}
+	}
+	return 5
+}
@@ -0,0 +1,116 @@
+package pdfutil
+
+import (
+	"strings"
+	"testing"
+
+	pdf "github.com/ledongthuc/pdf"
+)
+
+// textRun lays s out as one glyph per character from x on baseline y, each 6
+// units wide in a 10 point font.
+func textRun(x, y float64, s string) []pdf.Text {
+	var glyphs []pdf.Text
+	for i, r := range s {
+		glyphs = append(glyphs, pdf.Text{X: x + float64(i)*6, Y: y, W: 6, FontSize: 10, S: string(r)})
+	}
+	return glyphs
+}
+
+func glyphs(runs ...[]pdf.Text) []pdf.Text {
+	var all []pdf.Text
+	for _, r := range runs {
+		all = append(all, r...)
+	}
+	return all
+}
+
+func TestLayoutPage(t *testing.T) {
+	gap := func(n int) string { return strings.Repeat(" ", n) }
+	for _, tc := range []struct {
+		name   string
+		glyphs []pdf.Text
+		want   string
+	}{
+		{"no glyphs", nil, ""},
+		{"only blanks", glyphs(textRun(72, 700, "   "), []pdf.Text{{X: 90, Y: 700, S: ""}}), ""},
+		{
+			"two columns",
+			glyphs(
+				textRun(72, 700, "Left one"), textRun(372, 700, "Right one"),
+				textRun(72, 688, "Left two"), textRun(372, 688, "Right two"),
+			),
+			"Left one" + gap(42) + "Right one\n" +
+				"Left two" + gap(42) + "Right two\n",
+		},
+		{
+			// Content streams often draw one column after the other.
+			"two columns drawn column by column",
+			glyphs(
+				textRun(372, 700, "Right one"), textRun(372, 688, "Right two"),
+				textRun(72, 700, "Left one"), textRun(72, 688, "Left two"),
+			),
+			"Left one" + gap(42) + "Right one\n" +
+				"Left two" + gap(42) + "Right two\n",
+		},
+		{
+			"short left column",
+			glyphs(
+				textRun(72, 700, "Title"), textRun(372, 700, "Right one"),
+				textRun(372, 688, "Right two"),
+			),
+			"Title" + gap(45) + "Right one\n" +
+				gap(50) + "Right two\n",
+		},
+		{
+			"indented line",
+			glyphs(textRun(72, 700, "Heading"), textRun(96, 688, "indented")),
+			"Heading\n    indented\n",
+		},
+		{
+			"baseline jitter stays on the line",
+			glyphs(textRun(72, 700, "ab"), textRun(84, 701.5, "cd")),
+			"abcd\n",
+		},
+		{
+			"paragraph gap",
+			glyphs(textRun(72, 700, "one"), textRun(72, 688, "two"), textRun(72, 650, "three")),
+			"one\ntwo\n\nthree\n",
+		},
+		{
+			// "ab" is one glyph ending at 10; "c" starts at 13, the next
+			// grid column, but the gap is wider than a third of a cell.
+			"squeezed word gap",
+			[]pdf.Text{{X: 0, Y: 700, W: 10, FontSize: 10, S: "ab"}, {X: 13, Y: 700, W: 6, FontSize: 10, S: "c"}},
+			"ab c\n",
+		},
+		{
+			// Without widths the cell is half the font size.
+			"no width table",
+			[]pdf.Text{{X: 0, Y: 700, FontSize: 10, S: "a"}, {X: 50, Y: 700, FontSize: 10, S: "b"}},
+			"a" + gap(9) + "b\n",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := layoutPage(tc.glyphs); got != tc.want {
+				t.Errorf("layoutPage =\n%q\nwant\n%q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCellWidth(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		glyphs []pdf.Text
+		want   float64
+	}{
+		{"median single glyph width", []pdf.Text{{S: "a", W: 4}, {S: "b", W: 6}, {S: "c", W: 30}, {S: "wide", W: 100}}, 6},
+		{"half the font size", []pdf.Text{{S: "a", FontSize: 12}, {S: "b", FontSize: 8}}, 6},
+		{"nothing to go on", []pdf.Text{{S: "a"}}, 5},
+	} {
+		if got := cellWidth(tc.glyphs); got != tc.want {
+			t.Errorf("%s: cellWidth = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
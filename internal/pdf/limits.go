@@ -0,0 +1,142 @@
+package pdfutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	pdf "github.com/ledongthuc/pdf"
+)
+
+// ErrLimitExceeded marks a PDF rejected by CheckLimits. The message names the
+// limit, so the document's failure reason tells a bomb from a corrupt file.
+var ErrLimitExceeded = errors.New("pdf limit exceeded")
+
+// Limits bound the work one PDF may cause before its text is extracted. Zero
+// fields are not checked.
+type Limits struct {
+	MaxPages int
+	// MaxObjects caps the objects the cross-reference table declares.
+	MaxObjects int64
+	// MaxDecompressed caps the decoded size of the streams text extraction
+	// reads, which is what compression bombs inflate: each page's content
+	// streams, the form XObjects it draws and the ToUnicode maps of their
+	// fonts. Streams shared between pages count once per page, as
+	// extractors decode them again for each.
+	MaxDecompressed int64
+}
+
+func (l Limits) enabled() bool {
+	return l.MaxPages > 0 || l.MaxObjects > 0 || l.MaxDecompressed > 0
+}
+
+// CheckLimits opens the PDF of size bytes read through r and checks it
+// against l, cheapest check first. Streams are decoded and discarded,
+// never past the remaining budget, so a bomb costs at most MaxDecompressed
+// bytes of inflation instead of minutes of text layout.
+func CheckLimits(ctx context.Context, r io.ReaderAt, size int64, l Limits) (err error) {
+	if !l.enabled() {
+		return nil
+	}
+	// The parser panics on some malformed structures.
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("check pdf limits: %v", p)
+		}
+	}()
+	doc, err := pdf.NewReader(r, size)
+	if err != nil {
+		return fmt.Errorf("new pdf reader: %w", err)
+	}
+	if objects := doc.Trailer().Key("Size").Int64(); l.MaxObjects > 0 && objects > l.MaxObjects {
+		return fmt.Errorf("%w: %d objects, limit %d", ErrLimitExceeded, objects, l.MaxObjects)
+	}
+	pages := doc.NumPage()
+	if l.MaxPages > 0 && pages > l.MaxPages {
+		return fmt.Errorf("%w: %d pages, limit %d", ErrLimitExceeded, pages, l.MaxPages)
+	}
+	if l.MaxDecompressed <= 0 {
+		return nil
+	}
+	budget := l.MaxDecompressed
+	for page := 1; page <= pages; page++ {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("page %d of %d: %w", page, pages, err)
+		}
+		p := doc.Page(page)
+		err := pageStreams(p.V.Key("Contents"), p.Resources(), 0, func(stream pdf.Value) error {
+			n, err := decodedSize(stream, budget+1)
+			if err != nil {
+				return err
+			}
+			if budget -= n; budget < 0 {
+				return fmt.Errorf("%w: page streams decompress to more than %d bytes", ErrLimitExceeded, l.MaxDecompressed)
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("page %d: %w", page, err)
+		}
+	}
+	return nil
+}
+
+// maxFormDepth bounds how deeply form XObjects drawing other forms are
+// followed; real documents nest a few levels, and a form may draw itself.
+const maxFormDepth = 8
+
+// pageStreams calls fn with each stream extraction decodes for a page:
+// contents, a stream or an array of them, then the ToUnicode maps of the
+// fonts in resources and, recursively, the form XObjects there.
+func pageStreams(contents, resources pdf.Value, depth int, fn func(pdf.Value) error) error {
+	streams := []pdf.Value{contents}
+	if contents.Kind() == pdf.Array {
+		streams = streams[:0]
+		for i := 0; i < contents.Len(); i++ {
+			streams = append(streams, contents.Index(i))
+		}
+	}
+	fonts := resources.Key("Font")
+	for _, name := range fonts.Keys() {
+		streams = append(streams, fonts.Key(name).Key("ToUnicode"))
+	}
+	for _, stream := range streams {
+		if stream.Kind() != pdf.Stream {
+			continue
+		}
+		if err := fn(stream); err != nil {
+			return err
+		}
+	}
+	if depth >= maxFormDepth {
+		return nil
+	}
+	xobjects := resources.Key("XObject")
+	for _, name := range xobjects.Keys() {
+		form := xobjects.Key(name)
+		if form.Kind() != pdf.Stream || form.Key("Subtype").Name() != "Form" {
+			continue
+		}
+		// A form without resources of its own uses its page's.
+		formResources := form.Key("Resources")
+		if formResources.Kind() != pdf.Dict {
+			formResources = resources
+		}
+		if err := pageStreams(form, formResources, depth+1, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decodedSize decodes stream and returns its size, reading at most max bytes.
+func decodedSize(stream pdf.Value, max int64) (int64, error) {
+	rd := stream.Reader()
+	defer rd.Close()
+	n, err := io.Copy(io.Discard, io.LimitReader(rd, max))
+	if err != nil {
+		return n, fmt.Errorf("decode stream: %w", err)
+	}
+	return n, nil
+}
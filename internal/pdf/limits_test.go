@@ -0,0 +1,148 @@
+package pdfutil
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testPDF builds a PDF from objects, where objects[i] is the body of object
+// i+1 and object 1 is the catalog.
+func testPDF(objects ...string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.7\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return buf.Bytes()
+}
+
+// flateStream is a FlateDecode stream object whose data inflates to size
+// bytes.
+func flateStream(dict string, size int) string {
+	var z bytes.Buffer
+	w := zlib.NewWriter(&z)
+	w.Write(bytes.Repeat([]byte{' '}, size))
+	w.Close()
+	return fmt.Sprintf("<< %s /Length %d /Filter /FlateDecode >>\nstream\n%s\nendstream", dict, z.Len(), z.Bytes())
+}
+
+// pagesPDF builds n pages that share resources and each draw contents.
+func pagesPDF(n int, contents, resources string, extra ...string) []byte {
+	kids := make([]string, n)
+	objects := []string{"<< /Type /Catalog /Pages 2 0 R >>", ""}
+	for i := range kids {
+		kids[i] = fmt.Sprintf("%d 0 R", len(objects)+1)
+		objects = append(objects, fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Contents %s /Resources %s >>", contents, resources))
+	}
+	objects[1] = fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), n)
+	// Objects referred to by contents and resources come after the pages.
+	return testPDF(append(objects, extra...)...)
+}
+
+func checkPDF(data []byte, l Limits) error {
+	return CheckLimits(context.Background(), bytes.NewReader(data), int64(len(data)), l)
+}
+
+func TestCheckLimits(t *testing.T) {
+	const kb = 1 << 10
+	// Objects after three pages start at 6.
+	small := pagesPDF(3, "6 0 R", "<< >>", flateStream("", kb))
+	// The content stream is small but the page's font maps 64KiB.
+	font := pagesPDF(1, "4 0 R", "<< /Font << /F1 5 0 R >> >>",
+		flateStream("", 16),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /ToUnicode 6 0 R >>",
+		flateStream("", 64*kb))
+	// The page draws a form that draws another, whose font map is large.
+	forms := pagesPDF(1, "4 0 R", "<< /XObject << /X1 5 0 R >> >>",
+		flateStream("", 16),
+		flateStream("/Type /XObject /Subtype /Form /BBox [0 0 10 10] /Resources << /XObject << /X2 6 0 R >> >>", 16),
+		flateStream("/Type /XObject /Subtype /Form /BBox [0 0 10 10] /Resources << /Font << /F1 7 0 R >> >>", 16),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /ToUnicode 8 0 R >>",
+		flateStream("", 64*kb))
+	// A form that draws itself must not recurse forever.
+	loop := pagesPDF(1, "4 0 R", "<< /XObject << /X1 5 0 R >> >>",
+		flateStream("", 16),
+		flateStream("/Type /XObject /Subtype /Form /BBox [0 0 10 10] /Resources << /XObject << /X1 5 0 R >> >>", kb))
+	// Images are not decoded for text and do not count.
+	image := pagesPDF(1, "4 0 R", "<< /XObject << /Im1 5 0 R >> >>",
+		flateStream("", 16),
+		flateStream("/Type /XObject /Subtype /Image /Width 256 /Height 256 /ColorSpace /DeviceGray /BitsPerComponent 8", 64*kb))
+	// Shared content counts once per page: three pages of 32KiB.
+	shared := pagesPDF(3, "[6 0 R 7 0 R]", "<< >>", flateStream("", 16*kb), flateStream("", 16*kb))
+
+	for _, tc := range []struct {
+		name    string
+		pdf     []byte
+		limits  Limits
+		wantErr string
+	}{
+		{"within limits", small, Limits{MaxPages: 3, MaxObjects: 10, MaxDecompressed: 3 * kb}, ""},
+		{"too many pages", small, Limits{MaxPages: 2}, "3 pages, limit 2"},
+		{"too many objects", small, Limits{MaxObjects: 5}, "7 objects, limit 5"},
+		{"content over budget", small, Limits{MaxDecompressed: 3*kb - 1}, "decompress to more than"},
+		{"font map counted", font, Limits{MaxDecompressed: 32 * kb}, "decompress to more than"},
+		{"font map within budget", font, Limits{MaxDecompressed: 65 * kb}, ""},
+		{"nested form font counted", forms, Limits{MaxDecompressed: 32 * kb}, "decompress to more than"},
+		{"self-drawing form counted per level", loop, Limits{MaxDecompressed: 4 * kb}, "decompress to more than"},
+		{"self-drawing form stops at maxFormDepth", loop, Limits{MaxDecompressed: 16 * kb}, ""},
+		{"images not counted", image, Limits{MaxDecompressed: kb}, ""},
+		{"shared streams counted per page", shared, Limits{MaxDecompressed: 95 * kb}, "decompress to more than"},
+		{"shared streams within budget", shared, Limits{MaxDecompressed: 96 * kb}, ""},
+		{"no limits", small, Limits{}, ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := checkPDF(tc.pdf, tc.limits)
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Fatalf("CheckLimits = %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrLimitExceeded) || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("CheckLimits = %v, want ErrLimitExceeded with %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckLimitsErrors(t *testing.T) {
+	if err := checkPDF([]byte("not a pdf"), Limits{MaxPages: 1}); err == nil || errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("garbage: %v, want a parse error that is not a limit", err)
+	}
+	data := pagesPDF(1, "4 0 R", "<< >>", flateStream("", 16))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := CheckLimits(ctx, bytes.NewReader(data), int64(len(data)), Limits{MaxDecompressed: 1 << 20})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled: %v, want context.Canceled", err)
+	}
+}
+
+func TestExtractChecksLimitsForEveryBackend(t *testing.T) {
+	// A bomb the Go parser opens is refused before any backend sees it.
+	path := filepath.Join(t.TempDir(), "bomb.pdf")
+	data := pagesPDF(1, "4 0 R", "<< >>", flateStream("", 1<<20))
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	for _, ex := range []Extractor{GoExtractor{}, fakeExtractor{}} {
+		if _, err := Extract(context.Background(), ex, path, Limits{MaxDecompressed: 1 << 10}, nil); !errors.Is(err, ErrLimitExceeded) {
+			t.Errorf("%s: Extract = %v, want ErrLimitExceeded", ex.Name(), err)
+		}
+	}
+}
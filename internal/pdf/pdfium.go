@@ -0,0 +1,95 @@
+//go:build pdfium && cgo
+
+package pdfutil
+
+/*
+#cgo LDFLAGS: -lpdfium
+#include <stdlib.h>
+#include <fpdfview.h>
+#include <fpdf_text.h>
+*/
+import "C"
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"unicode/utf16"
+	"unsafe"
+)
+
+// pdfium is not thread-safe: one document is processed at a time per process.
+var (
+	pdfiumOnce sync.Once
+	pdfiumMu   sync.Mutex
+)
+
+// PdfiumExtractor extracts with pdfium's text API.
+type PdfiumExtractor struct{}
+
+func newPdfiumExtractor() (Extractor, error) {
+	pdfiumOnce.Do(func() { C.FPDF_InitLibrary() })
+	return PdfiumExtractor{}, nil
+}
+
+// Name implements Extractor.
+func (PdfiumExtractor) Name() string { return BackendPdfium }
+
+// Extract implements Extractor.
+func (PdfiumExtractor) Extract(ctx context.Context, path string, onPage func(page, total int)) (string, error) {
+	pdfiumMu.Lock()
+	defer pdfiumMu.Unlock()
+	cpath := C.CString(path)
+	defer C.free(unsafe.Pointer(cpath))
+	doc := C.FPDF_LoadDocument(cpath, nil)
+	if doc == nil {
+		return "", fmt.Errorf("pdfium: load document: error %d", int(C.FPDF_GetLastError()))
+	}
+	defer C.FPDF_CloseDocument(doc)
+	total := int(C.FPDF_GetPageCount(doc))
+	var builder strings.Builder
+	for page := 1; page <= total; page++ {
+		if err := ctx.Err(); err != nil {
+			return "", fmt.Errorf("page %d of %d: %w", page, total, err)
+		}
+		if onPage != nil {
+			onPage(page, total)
+		}
+		if page > 1 {
+			builder.WriteString(PageBreak)
+		}
+		text, err := pdfiumPageText(doc, page-1)
+		if err != nil {
+			return "", fmt.Errorf("page %d: %w", page, err)
+		}
+		builder.WriteString(text)
+		builder.WriteString("\n")
+	}
+	return builder.String(), nil
+}
+
+// pdfiumPageText returns the text of the page at index, counted from 0.
+func pdfiumPageText(doc C.FPDF_DOCUMENT, index int) (string, error) {
+	page := C.FPDF_LoadPage(doc, C.int(index))
+	if page == nil {
+		return "", fmt.Errorf("pdfium: load page: error %d", int(C.FPDF_GetLastError()))
+	}
+	defer C.FPDF_ClosePage(page)
+	textPage := C.FPDFText_LoadPage(page)
+	if textPage == nil {
+		return "", fmt.Errorf("pdfium: load text")
+	}
+	defer C.FPDFText_ClosePage(textPage)
+	count := int(C.FPDFText_CountChars(textPage))
+	if count <= 0 {
+		return "", nil
+	}
+	// FPDFText_GetText writes UTF-16 code units plus a terminating NUL.
+	buf := make([]uint16, count+1)
+	n := int(C.FPDFText_GetText(textPage, 0, C.int(count), (*C.ushort)(unsafe.Pointer(&buf[0]))))
+	if n > 0 {
+		n--
+	}
+	return strings.ReplaceAll(string(utf16.Decode(buf[:n])), "\r\n", "\n"), nil
+}
@@ -0,0 +1,9 @@
+//go:build !pdfium || !cgo
+
+package pdfutil
+
+import "errors"
+
+func newPdfiumExtractor() (Extractor, error) {
+	return nil, errors.New("this binary was built without pdfium; rebuild with CGO_ENABLED=1 and -tags pdfium")
+}
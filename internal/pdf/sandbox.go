@@ -0,0 +1,181 @@
+package pdfutil
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// SandboxCommand is the hidden first argument that makes a binary act as the
+// extraction child. Binaries that use ExtractInSandbox must check for it at
+// the top of main and hand over to RunSandbox.
+const SandboxCommand = "__extract-sandbox"
+
+// progressPrefix marks progress lines on the child's stderr; every other line
+// is diagnostic output such as a panic trace.
+const progressPrefix = "vaultdrop-progress "
+
+// stderrTail bounds the diagnostic lines kept for the error message.
+const stderrTail = 20
+
+// maxStderrLine bounds one line of the child's stderr. Past it the rest of
+// the output is discarded so the child never blocks writing to a full pipe.
+const maxStderrLine = 1 << 20
+
+// Modes for ExtractInSandbox.
+const (
+	ModeText   = "text"
+	ModeLayout = "layout"
+)
+
+// limitExitCode is the child's exit code when the PDF fails CheckLimits, so
+// the parent can report ErrLimitExceeded instead of a generic failure.
+const limitExitCode = 3
+
+// ExtractInSandbox runs Extract, or ExtractLayout for ModeLayout, with ex on
+// the file at path in a child process
+// started from the current executable, so a parser panic, runaway allocation
+// or hang kills the child instead of the worker. memoryLimit caps the child's
+// address space in bytes where the platform supports it; zero means no cap.
+// The child checks limits before extracting. Cancelling ctx kills the child.
+func ExtractInSandbox(ctx context.Context, path string, memoryLimit int64, ex Extractor, limits Limits, mode string, onPage func(page, total int)) (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("locate executable: %w", err)
+	}
+	var tool string
+	if p, ok := ex.(PdftotextExtractor); ok {
+		tool = p.Command
+	}
+	cmd := exec.CommandContext(ctx, exe, SandboxCommand,
+		mode,
+		ex.Name(),
+		tool,
+		strconv.FormatInt(memoryLimit, 10),
+		strconv.Itoa(limits.MaxPages),
+		strconv.FormatInt(limits.MaxObjects, 10),
+		strconv.FormatInt(limits.MaxDecompressed, 10),
+		path)
+	cmd.Env = []string{}
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return "", fmt.Errorf("sandbox stderr: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return "", fmt.Errorf("start sandbox: %w", err)
+	}
+	var tail []string
+	scanner := bufio.NewScanner(stderr)
+	scanner.Buffer(make([]byte, 0, 64<<10), maxStderrLine)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if rest, ok := strings.CutPrefix(line, progressPrefix); ok {
+			var page, total int
+			if _, err := fmt.Sscanf(rest, "%d %d", &page, &total); err == nil && onPage != nil {
+				onPage(page, total)
+			}
+			continue
+		}
+		if len(tail) == stderrTail {
+			tail = tail[1:]
+		}
+		tail = append(tail, line)
+	}
+	if err := scanner.Err(); err != nil {
+		if len(tail) == stderrTail {
+			tail = tail[1:]
+		}
+		tail = append(tail, fmt.Sprintf("(stderr unread: %v)", err))
+		io.Copy(io.Discard, stderr)
+	}
+	if err := cmd.Wait(); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return "", fmt.Errorf("sandbox: %w", ctxErr)
+		}
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == limitExitCode {
+			return "", fmt.Errorf("%w: %s", ErrLimitExceeded, strings.TrimPrefix(sandboxReason(tail), ErrLimitExceeded.Error()+": "))
+		}
+		return "", fmt.Errorf("sandboxed extraction failed (%v): %s", err, sandboxReason(tail))
+	}
+	return stdout.String(), nil
+}
+
+// sandboxReason picks the most useful line from the child's stderr: the
+// extraction error, the panic message, or the last line written.
+func sandboxReason(tail []string) string {
+	for _, line := range tail {
+		if strings.HasPrefix(line, "panic: ") || strings.HasPrefix(line, "fatal error: ") {
+			return line
+		}
+	}
+	if len(tail) == 0 {
+		return "no output"
+	}
+	return tail[len(tail)-1]
+}
+
+// RunSandbox is the child side of ExtractInSandbox. args are the arguments
+// after SandboxCommand. It writes the text to stdout and returns the exit
+// code.
+func RunSandbox(args []string) int {
+	if len(args) != 8 {
+		fmt.Fprintln(os.Stderr, "usage: "+SandboxCommand+" <mode> <backend> <tool> <memory-limit> <max-pages> <max-objects> <max-decompressed> <path>")
+		return 2
+	}
+	extract := Extract
+	switch mode := args[0]; mode {
+	case ModeText:
+	case ModeLayout:
+		extract = ExtractLayout
+	default:
+		fmt.Fprintf(os.Stderr, "invalid mode %q\n", mode)
+		return 2
+	}
+	args = args[1:]
+	ex, err := NewExtractor(args[0], args[1])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	var nums [4]int64
+	for i := range nums {
+		n, err := strconv.ParseInt(args[i+2], 10, 64)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid limit %q\n", args[i+2])
+			return 2
+		}
+		nums[i] = n
+	}
+	if nums[0] > 0 {
+		if err := limitMemory(nums[0]); err != nil {
+			fmt.Fprintf(os.Stderr, "limit memory: %v\n", err)
+			return 1
+		}
+	}
+	limits := Limits{MaxPages: int(nums[1]), MaxObjects: nums[2], MaxDecompressed: nums[3]}
+	text, err := extract(context.Background(), ex, args[6], limits, func(page, total int) {
+		fmt.Fprintf(os.Stderr, "%s%d %d\n", progressPrefix, page, total)
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		if errors.Is(err, ErrLimitExceeded) {
+			return limitExitCode
+		}
+		return 1
+	}
+	if _, err := io.WriteString(os.Stdout, text); err != nil {
+		fmt.Fprintf(os.Stderr, "write text: %v\n", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,11 @@
+//go:build !linux && !darwin
+
+package pdfutil
+
+import "runtime/debug"
+
+// limitMemory can only set a soft Go heap limit on this platform.
+func limitMemory(limit int64) error {
+	debug.SetMemoryLimit(limit)
+	return nil
+}
@@ -0,0 +1,16 @@
+//go:build linux || darwin
+
+package pdfutil
+
+import (
+	"runtime/debug"
+	"syscall"
+)
+
+// limitMemory caps the process address space so a runaway parse fails with an
+// out-of-memory error instead of exhausting the host. The Go heap target is
+// set below the cap so the collector works harder before it is reached.
+func limitMemory(limit int64) error {
+	debug.SetMemoryLimit(limit / 10 * 8)
+	return syscall.Setrlimit(syscall.RLIMIT_AS, &syscall.Rlimit{Cur: uint64(limit), Max: uint64(limit)})
+}
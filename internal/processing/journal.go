@@ -0,0 +1,167 @@
+package processing
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/model"
+)
+
+// Journal operations. A job is pending from "queued" until "done"; "started"
+// only tells recovery the job was interrupted mid-run.
+const (
+	opQueued  = "queued"
+	opStarted = "started"
+	opDone    = "done"
+)
+
+// entry is one line of the journal. The record is copied in full because the
+// MemoryStore it came from does not survive a restart; Path is carried
+// separately since FileRecord hides it from JSON.
+type entry struct {
+	Op     string            `json:"op"`
+	FileID string            `json:"fileId"`
+	Record *model.FileRecord `json:"record,omitempty"`
+	Path   string            `json:"path,omitempty"`
+}
+
+// Journal is an append-only log of job transitions, one JSON object per line.
+// Replaying it yields the jobs that were queued or running when the process
+// stopped.
+type Journal struct {
+	mu      sync.Mutex
+	f       *os.File
+	pending []*model.FileRecord
+}
+
+// OpenJournal reads the journal at path, keeps the jobs still pending, and
+// compacts the file down to them before reopening it for appends.
+func OpenJournal(path string) (*Journal, error) {
+	pending, err := replay(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := compact(path, pending); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o640)
+	if err != nil {
+		return nil, fmt.Errorf("open journal: %w", err)
+	}
+	return &Journal{f: f, pending: pending}, nil
+}
+
+// replay returns the pending records in the order they were queued. A torn
+// final line from a crash mid-write ends the replay rather than failing it.
+func replay(path string) ([]*model.FileRecord, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("open journal: %w", err)
+	}
+	defer f.Close()
+	var order []string
+	records := make(map[string]*model.FileRecord)
+	dec := json.NewDecoder(bufio.NewReader(f))
+	for {
+		var e entry
+		if err := dec.Decode(&e); err != nil {
+			if !errors.Is(err, io.EOF) {
+				log.Printf("journal %s: ignoring tail after bad entry: %v", path, err)
+			}
+			break
+		}
+		switch e.Op {
+		case opQueued:
+			if e.Record == nil {
+				continue
+			}
+			e.Record.Path = e.Path
+			if _, ok := records[e.FileID]; !ok {
+				order = append(order, e.FileID)
+			}
+			records[e.FileID] = e.Record
+		case opDone:
+			delete(records, e.FileID)
+		}
+	}
+	pending := make([]*model.FileRecord, 0, len(records))
+	for _, id := range order {
+		if rec, ok := records[id]; ok {
+			pending = append(pending, rec)
+			// A job can be queued again after it finished; keep it once.
+			delete(records, id)
+		}
+	}
+	return pending, nil
+}
+
+// compact rewrites the journal with only the pending jobs. Writing a sibling
+// file and renaming it keeps the old journal intact if we crash halfway.
+func compact(path string, pending []*model.FileRecord) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("compact journal: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	w := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(w)
+	for _, rec := range pending {
+		if err := enc.Encode(queuedEntry(rec)); err != nil {
+			tmp.Close()
+			return fmt.Errorf("compact journal: %w", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("compact journal: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("compact journal: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("compact journal: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("compact journal: %w", err)
+	}
+	return nil
+}
+
+func queuedEntry(rec *model.FileRecord) entry {
+	return entry{Op: opQueued, FileID: rec.ID, Record: rec, Path: rec.Path}
+}
+
+// Pending returns the jobs recovered when the journal was opened.
+func (j *Journal) Pending() []*model.FileRecord {
+	return j.pending
+}
+
+// append writes e and syncs it so an acknowledged upload survives a crash.
+func (j *Journal) append(e entry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if _, err := j.f.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	return j.f.Sync()
+}
+
+// Close closes the journal file.
+func (j *Journal) Close() error {
+	return j.f.Close()
+}
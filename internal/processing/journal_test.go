@@ -0,0 +1,186 @@
+package processing
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/model"
+	"github.com/dharsanguruparan/VaultDrop/internal/storage"
+)
+
+func TestOpenJournalMissingFile(t *testing.T) {
+	j, err := OpenJournal(filepath.Join(t.TempDir(), "jobs.journal"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+	if n := len(j.Pending()); n != 0 {
+		t.Errorf("Pending() has %d jobs, want 0", n)
+	}
+}
+
+func TestJournalReplayAfterCrash(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jobs.journal")
+	// What a crash leaves behind: a finished, an interrupted and a waiting
+	// job, one queued twice, and a line torn mid-write.
+	lines := []string{
+		`{"op":"queued","fileId":"a","record":{"id":"a","name":"a.pdf","status":"queued"},"path":"/data/a"}`,
+		`{"op":"queued","fileId":"b","record":{"id":"b","name":"b.pdf","status":"queued"},"path":"/data/b"}`,
+		`{"op":"queued","fileId":"c","record":{"id":"c","name":"c.pdf","status":"queued"},"path":"/data/c"}`,
+		`{"op":"started","fileId":"a"}`,
+		`{"op":"started","fileId":"b"}`,
+		`{"op":"done","fileId":"b"}`,
+		`{"op":"queued","fileId":"a","record":{"id":"a","name":"a-v2.pdf","status":"queued"},"path":"/data/a2"}`,
+		`{"op":"queued","fileId":"d"}`,
+		`{"op":"done","fileId":"c","rec`,
+	}
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0o640); err != nil {
+		t.Fatal(err)
+	}
+
+	j, err := OpenJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pending := j.Pending()
+	if len(pending) != 2 {
+		t.Fatalf("Pending() = %d jobs, want 2", len(pending))
+	}
+	// Queue order is kept, with the latest record of a job queued twice.
+	if a := pending[0]; a.ID != "a" || a.Name != "a-v2.pdf" || a.Path != "/data/a2" {
+		t.Errorf("pending[0] = %+v", a)
+	}
+	// The torn line is dropped, so c is still pending.
+	if c := pending[1]; c.ID != "c" || c.Path != "/data/c" {
+		t.Errorf("pending[1] = %+v", c)
+	}
+	if err := j.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Opening compacted the file to the pending jobs.
+	if got := journalLines(t, path); len(got) != 2 {
+		t.Errorf("compacted journal has %d lines, want 2:\n%s", len(got), strings.Join(got, "\n"))
+	}
+	if matches, _ := filepath.Glob(path + ".*"); len(matches) != 0 {
+		t.Errorf("temporary files left behind: %v", matches)
+	}
+}
+
+func TestProcessorResumesJournaledJobs(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "jobs.journal")
+	file := filepath.Join(dir, "a.txt")
+	if err := os.WriteFile(file, []byte("hello"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// First run: the job is accepted but the process stops before a worker
+	// picks it up.
+	store := storage.NewMemoryStore()
+	if err := store.Save(&model.FileRecord{ID: "a", Name: "a.txt", Path: file, Status: model.StatusScanned}); err != nil {
+		t.Fatal(err)
+	}
+	j, err := OpenJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := New(store, Options{Journal: j, Stages: []Stage{checksumStage{}}})
+	if err := p.Submit(Job{FileID: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	j.Close()
+
+	// Second run, with an empty store as after a restart.
+	j, err = OpenJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store = storage.NewMemoryStore()
+	p = New(store, Options{Journal: j, Stages: []Stage{checksumStage{}}})
+	rec, err := store.Get("a")
+	if err != nil || rec.Status != model.StatusQueued || rec.Message != "requeued after restart" || rec.Path != file {
+		t.Fatalf("restored record = %+v, %v", rec, err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p.Start(ctx)
+	rec = waitForStatus(t, store, "a", model.StatusComplete)
+	if rec.Attributes["sha256"] == "" {
+		t.Errorf("attributes = %v, want a checksum", rec.Attributes)
+	}
+	// The journal entry follows the status update.
+	waitFor(t, func() bool {
+		lines := journalLines(t, path)
+		return strings.Contains(lines[len(lines)-1], `"op":"done"`)
+	})
+	cancel()
+	j.Close()
+
+	// The finished job is not recovered again.
+	j, err = OpenJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+	if n := len(j.Pending()); n != 0 {
+		t.Errorf("Pending() after completion has %d jobs, want 0", n)
+	}
+}
+
+func TestInterruptedJobStaysPending(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jobs.journal")
+	j, err := OpenJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := storage.NewMemoryStore()
+	if err := store.Save(&model.FileRecord{ID: "a", Status: model.StatusScanned}); err != nil {
+		t.Fatal(err)
+	}
+	running, stopped := make(chan struct{}), make(chan struct{})
+	stage := stageFunc(func(ctx context.Context, rec *model.FileRecord) error {
+		defer close(stopped)
+		close(running)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	p := New(store, Options{Journal: j, Stages: []Stage{stage}})
+	ctx, cancel := context.WithCancel(context.Background())
+	p.Start(ctx)
+	if err := p.Submit(Job{FileID: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	<-running
+	cancel()
+	<-stopped
+	j.Close()
+
+	j, err = OpenJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+	if pending := j.Pending(); len(pending) != 1 || pending[0].ID != "a" {
+		t.Errorf("Pending() = %v, want job a", pending)
+	}
+}
+
+func journalLines(t *testing.T, path string) []string {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var lines []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		lines = append(lines, sc.Text())
+	}
+	return lines
+}
@@ -5,38 +5,118 @@ package processing
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
 	"time"
 
+	"github.com/dharsanguruparan/VaultDrop/internal/metadata"
 	"github.com/dharsanguruparan/VaultDrop/internal/model"
 	"github.com/dharsanguruparan/VaultDrop/internal/storage"
 )
 
+// ErrQueueFull is returned by Submit when the queue has no room; callers
+// should ask the client to retry later.
+var ErrQueueFull = errors.New("processing queue full")
+
+// Retry backoff doubles from retryBaseDelay per failed attempt, capped at
+// retryMaxDelay.
+const (
+	defaultMaxAttempts = 3
+	retryBaseDelay     = time.Second
+	retryMaxDelay      = time.Minute
+)
+
 // Job represents background processing work. Simple structs like this make it
 // easy to extend later without changing channel type signatures.
 type Job struct {
 	FileID string
+	// Attempt counts the failed runs so far. It is not journaled, so a job
+	// recovered after a restart gets a fresh set of attempts.
+	Attempt int
+}
+
+// Options tunes a Processor. Zero values pick the defaults noted per field.
+type Options struct {
+	// Workers is how many jobs run at once; at least one.
+	Workers int
+	// QueueLen is how many jobs wait before Submit refuses more; zero means
+	// four per worker.
+	QueueLen int
+	// MaxAttempts is how often a job runs before its file is marked failed;
+	// zero means three.
+	MaxAttempts int
+	// Stages run in order for every job; empty means DefaultStages.
+	Stages []Stage
+	// Journal persists queued jobs across restarts when set.
+	Journal *Journal
 }
 
 // Processor consumes Jobs and updates their lifecycle.
 type Processor struct {
-	store   *storage.MemoryStore
-	queue   chan Job
-	workers int
+	store storage.FileStore
+	// status records failures through the store interface the API's worker
+	// uses too.
+	status      metadata.Store
+	queue       chan Job
+	workers     int
+	maxAttempts int
+	stages      []Stage
+	// journal persists queued jobs when set; recovered holds the jobs it
+	// handed back at startup until Start requeues them.
+	journal   *Journal
+	recovered []Job
 }
 
-// New builds a Processor with queue capacity tied to worker count.
-func New(store *storage.MemoryStore, workers int) *Processor {
+// New builds a Processor from opts. With a journal, jobs pending from a
+// previous run are restored into the store as queued and requeued once Start
+// is called.
+func New(store storage.FileStore, opts Options) *Processor {
+	workers := opts.Workers
 	if workers <= 0 {
 		workers = 1
 	}
-	return &Processor{
-		store: store,
+	queueLen := opts.QueueLen
+	if queueLen <= 0 {
+		queueLen = workers * 4
+	}
+	maxAttempts := opts.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = defaultMaxAttempts
+	}
+	stages := opts.Stages
+	if len(stages) == 0 {
+		// The built-in names always resolve.
+		stages, _ = StagesByName(DefaultStages)
+	}
+	journal := opts.Journal
+	p := &Processor{
+		store:  store,
+		status: metadata.FromFileStore(store),
 		// make(chan T, N) creates a buffered channel that can hold N messages
 		// without blocking producers, keeping uploads responsive.
-		queue:   make(chan Job, workers*4),
-		workers: workers,
+		queue:       make(chan Job, queueLen),
+		workers:     workers,
+		maxAttempts: maxAttempts,
+		stages:      stages,
+		journal:     journal,
+	}
+	if journal != nil {
+		for _, rec := range journal.Pending() {
+			// Jobs interrupted mid-run start over from the queue.
+			rec.Status = model.StatusQueued
+			rec.Message = "requeued after restart"
+			if err := store.Save(rec); err != nil {
+				log.Printf("restore %s: %v", rec.ID, err)
+				continue
+			}
+			p.recovered = append(p.recovered, Job{FileID: rec.ID})
+		}
+		if n := len(p.recovered); n > 0 {
+			log.Printf("processor recovered %d pending jobs from journal", n)
+		}
 	}
+	return p
 }
 
 // Start launches worker goroutines.
@@ -46,17 +126,44 @@ func (p *Processor) Start(ctx context.Context) {
 		// Go runtime). Each worker listens for jobs until the context closes.
 		go p.worker(ctx)
 	}
+	if len(p.recovered) > 0 {
+		// Recovered jobs may outnumber the buffer, so feed them in from a
+		// goroutine that waits for room instead of dropping any.
+		go p.requeue(ctx, p.recovered)
+		p.recovered = nil
+	}
 }
 
-// Submit queues a job for async processing.
-func (p *Processor) Submit(job Job) {
+func (p *Processor) requeue(ctx context.Context, jobs []Job) {
+	for _, job := range jobs {
+		select {
+		case p.queue <- job:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Submit queues a job for async processing. It never blocks: when the queue
+// is full the job is refused with ErrQueueFull and the file left untouched.
+func (p *Processor) Submit(job Job) error {
+	if p.journal != nil {
+		rec, err := p.store.Get(job.FileID)
+		if err != nil {
+			return err
+		}
+		if err := p.journal.append(queuedEntry(rec)); err != nil {
+			log.Printf("journal job %s: %v", job.FileID, err)
+		}
+	}
 	select {
 	case p.queue <- job:
+		return nil
 	default:
-		// default branch activates when the channel buffer is full; we opt to
-		// drop work but mark the file failed so the API reflects reality.
-		log.Printf("processor queue full, dropping job for %s", job.FileID)
-		_ = p.store.UpdateStatus(job.FileID, model.StatusFailed, "processing queue full")
+		// default branch activates when the channel buffer is full. The job
+		// was journaled above, so close it out before refusing it.
+		p.record(opDone, job.FileID)
+		return ErrQueueFull
 	}
 }
 
@@ -68,18 +175,94 @@ func (p *Processor) worker(ctx context.Context) {
 			// is cancelled (triggered by signal handling in main.go).
 			return
 		case job := <-p.queue:
-			p.process(job)
+			p.process(ctx, job)
+		}
+	}
+}
+
+func (p *Processor) process(ctx context.Context, job Job) {
+	msg := "processing started"
+	if job.Attempt > 0 {
+		msg = fmt.Sprintf("processing started (attempt %d of %d)", job.Attempt+1, p.maxAttempts)
+	}
+	if err := p.store.UpdateStatus(job.FileID, model.StatusProcessing, msg); err != nil {
+		p.record(opDone, job.FileID)
+		return
+	}
+	p.record(opStarted, job.FileID)
+	err := p.runStages(ctx, job)
+	if err != nil && ctx.Err() != nil {
+		// Shutting down: the journal still lists the job as pending, and the
+		// interrupted run does not count as an attempt.
+		return
+	}
+	if err == nil {
+		if err := p.store.UpdateStatus(job.FileID, model.StatusComplete, "processing finished"); err != nil {
+			log.Printf("update status failed: %v", err)
+		}
+		p.record(opDone, job.FileID)
+		return
+	}
+	job.Attempt++
+	if job.Attempt >= p.maxAttempts {
+		_ = p.status.MarkFailed(ctx, job.FileID, fmt.Sprintf("failed after %d attempts: %v", job.Attempt, err))
+		p.record(opDone, job.FileID)
+		return
+	}
+	delay := backoff(job.Attempt)
+	_ = p.store.UpdateStatus(job.FileID, model.StatusRetrying, fmt.Sprintf("attempt %d failed: %v; retrying in %s", job.Attempt, err, delay))
+	// The job stays pending in the journal while it waits, so a restart
+	// picks it up again.
+	go func() {
+		select {
+		case <-time.After(delay):
+			p.requeue(ctx, []Job{job})
+		case <-ctx.Done():
+		}
+	}()
+}
+
+// runStages passes the job's file through every stage, saving the
+// attributes each one adds as it goes.
+func (p *Processor) runStages(ctx context.Context, job Job) error {
+	rec, err := p.store.Get(job.FileID)
+	if err != nil {
+		return err
+	}
+	if rec.Attributes == nil {
+		rec.Attributes = make(map[string]string)
+	}
+	for _, stage := range p.stages {
+		_ = p.store.UpdateStatus(job.FileID, model.StatusProcessing, "running "+stage.Name())
+		if err := stage.Run(ctx, rec); err != nil {
+			return fmt.Errorf("%s: %w", stage.Name(), err)
+		}
+		if err := p.store.SetAttributes(job.FileID, rec.Attributes); err != nil {
+			return err
 		}
 	}
+	return nil
+}
+
+// backoff returns the wait before retrying a job that failed attempt times.
+func backoff(attempt int) time.Duration {
+	delay := retryBaseDelay
+	for i := 1; i < attempt && delay < retryMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > retryMaxDelay {
+		delay = retryMaxDelay
+	}
+	return delay
 }
 
-func (p *Processor) process(job Job) {
-	if err := p.store.UpdateStatus(job.FileID, model.StatusProcessing, "processing started"); err != nil {
+// record notes a job transition in the journal, if there is one. Failures
+// are logged only: at worst the job runs again after a restart.
+func (p *Processor) record(op, fileID string) {
+	if p.journal == nil {
 		return
 	}
-	// Simulate heavy work
-	time.Sleep(2 * time.Second)
-	if err := p.store.UpdateStatus(job.FileID, model.StatusComplete, "processing finished"); err != nil {
-		log.Printf("update status failed: %v", err)
+	if err := p.journal.append(entry{Op: op, FileID: fileID}); err != nil {
+		log.Printf("journal job %s: %v", fileID, err)
 	}
 }
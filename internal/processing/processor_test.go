@@ -0,0 +1,181 @@
+package processing
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/model"
+	"github.com/dharsanguruparan/VaultDrop/internal/storage"
+)
+
+// stageFunc adapts a function to Stage for tests.
+type stageFunc func(ctx context.Context, rec *model.FileRecord) error
+
+func (stageFunc) Name() string { return "test" }
+
+func (f stageFunc) Run(ctx context.Context, rec *model.FileRecord) error { return f(ctx, rec) }
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func waitForStatus(t *testing.T, store storage.FileStore, id string, status model.FileStatus) *model.FileRecord {
+	t.Helper()
+	var rec *model.FileRecord
+	waitFor(t, func() bool {
+		var err error
+		rec, err = store.Get(id)
+		return err == nil && rec.Status == status
+	})
+	return rec
+}
+
+func TestBackoff(t *testing.T) {
+	for _, tc := range []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{6, 32 * time.Second},
+		{7, time.Minute},
+		{100, time.Minute},
+	} {
+		if got := backoff(tc.attempt); got != tc.want {
+			t.Errorf("backoff(%d) = %s, want %s", tc.attempt, got, tc.want)
+		}
+	}
+}
+
+func TestProcessorGivesUpAfterMaxAttempts(t *testing.T) {
+	store := storage.NewMemoryStore()
+	if err := store.Save(&model.FileRecord{ID: "a", Status: model.StatusScanned}); err != nil {
+		t.Fatal(err)
+	}
+	var runs atomic.Int32
+	stage := stageFunc(func(ctx context.Context, rec *model.FileRecord) error {
+		runs.Add(1)
+		return errors.New("boom")
+	})
+	p := New(store, Options{MaxAttempts: 1, Stages: []Stage{stage}})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p.Start(ctx)
+	if err := p.Submit(Job{FileID: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	rec := waitForStatus(t, store, "a", model.StatusFailed)
+	if rec.Message != "failed after 1 attempts: test: boom" {
+		t.Errorf("message = %q", rec.Message)
+	}
+	if n := runs.Load(); n != 1 {
+		t.Errorf("stage ran %d times, want 1", n)
+	}
+}
+
+func TestProcessorRetriesFailedAttempt(t *testing.T) {
+	store := storage.NewMemoryStore()
+	if err := store.Save(&model.FileRecord{ID: "a", Status: model.StatusScanned}); err != nil {
+		t.Fatal(err)
+	}
+	var runs atomic.Int32
+	stage := stageFunc(func(ctx context.Context, rec *model.FileRecord) error {
+		if runs.Add(1) == 1 {
+			return errors.New("flaky")
+		}
+		rec.Attributes["ok"] = "yes"
+		return nil
+	})
+	p := New(store, Options{MaxAttempts: 2, Stages: []Stage{stage}})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p.Start(ctx)
+	if err := p.Submit(Job{FileID: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	rec := waitForStatus(t, store, "a", model.StatusRetrying)
+	if rec.Message != "attempt 1 failed: test: flaky; retrying in 1s" {
+		t.Errorf("retrying message = %q", rec.Message)
+	}
+	rec = waitForStatus(t, store, "a", model.StatusComplete)
+	if rec.Attributes["ok"] != "yes" || runs.Load() != 2 {
+		t.Errorf("after retry: %+v, %d runs", rec, runs.Load())
+	}
+}
+
+func TestSubmitRefusesWhenQueueFull(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jobs.journal")
+	j, err := OpenJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := storage.NewMemoryStore()
+	for _, id := range []string{"a", "b"} {
+		if err := store.Save(&model.FileRecord{ID: id, Status: model.StatusScanned}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Not started, so nothing drains the queue.
+	p := New(store, Options{QueueLen: 1, Journal: j})
+	if err := p.Submit(Job{FileID: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Submit(Job{FileID: "b"}); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("Submit(b) error = %v, want ErrQueueFull", err)
+	}
+	if err := p.Submit(Job{FileID: "missing"}); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("Submit(missing) error = %v, want ErrNotFound", err)
+	}
+	j.Close()
+
+	// The refused job was closed out, so only a is recovered.
+	j, err = OpenJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+	if pending := j.Pending(); len(pending) != 1 || pending[0].ID != "a" {
+		t.Errorf("Pending() = %v, want job a", pending)
+	}
+}
+
+func TestRunStagesInOrder(t *testing.T) {
+	store := storage.NewMemoryStore()
+	if err := store.Save(&model.FileRecord{ID: "a", Status: model.StatusScanned}); err != nil {
+		t.Fatal(err)
+	}
+	var order []string
+	stage := func(name string, err error) Stage {
+		return stageFunc(func(ctx context.Context, rec *model.FileRecord) error {
+			order = append(order, name)
+			rec.Attributes[name] = "done"
+			return err
+		})
+	}
+	p := New(store, Options{Stages: []Stage{stage("first", nil), stage("second", errors.New("broken")), stage("third", nil)}})
+	err := p.runStages(context.Background(), Job{FileID: "a"})
+	if err == nil || !strings.Contains(err.Error(), "broken") {
+		t.Fatalf("runStages error = %v", err)
+	}
+	if got := strings.Join(order, ","); got != "first,second" {
+		t.Errorf("stages ran %s, want first,second", got)
+	}
+	// Only what finished stages added is saved.
+	rec, _ := store.Get("a")
+	if rec.Attributes["first"] != "done" || rec.Attributes["second"] != "" {
+		t.Errorf("attributes = %v", rec.Attributes)
+	}
+}
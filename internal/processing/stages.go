@@ -0,0 +1,176 @@
+package processing
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	// Blank imports register decoders with image.Decode.
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/model"
+)
+
+// Stage is one step of processing a file. Stages run in the order given to
+// New; an error fails the attempt and the retry starts over from the first
+// stage, so stages must be safe to repeat.
+type Stage interface {
+	// Name identifies the stage in status messages and configuration.
+	Name() string
+	// Run works on rec, a copy of the stored record. Entries a stage adds to
+	// rec.Attributes are saved once it returns successfully.
+	Run(ctx context.Context, rec *model.FileRecord) error
+}
+
+// DefaultStages is the pipeline used when Options.Stages is empty.
+var DefaultStages = []string{"checksum", "thumbnail"}
+
+// StagesByName resolves stage names from configuration, such as
+// VAULTDROP_PROCESSING_STAGES, into Stages.
+func StagesByName(names []string) ([]Stage, error) {
+	stages := make([]Stage, 0, len(names))
+	for _, name := range names {
+		switch strings.TrimSpace(name) {
+		case "checksum":
+			stages = append(stages, checksumStage{})
+		case "thumbnail":
+			stages = append(stages, thumbnailStage{maxSide: thumbnailSide, maxPixels: thumbnailMaxPixels})
+		case "simulate":
+			stages = append(stages, delayStage{d: 2 * time.Second})
+		default:
+			return nil, fmt.Errorf("unknown processing stage %q", name)
+		}
+	}
+	return stages, nil
+}
+
+// checksumStage records the SHA-256 of the upload as attributes["sha256"].
+type checksumStage struct{}
+
+func (checksumStage) Name() string { return "checksum" }
+
+func (checksumStage) Run(ctx context.Context, rec *model.FileRecord) error {
+	f, err := os.Open(rec.Path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return err
+	}
+	rec.Attributes["sha256"] = hex.EncodeToString(h.Sum(nil))
+	return nil
+}
+
+// thumbnailSide bounds the longer side of generated thumbnails in pixels.
+const thumbnailSide = 128
+
+// thumbnailMaxPixels caps the width×height of images the thumbnail stage
+// decodes. Decoding allocates the full bitmap, so a small file declaring
+// huge dimensions would otherwise exhaust memory.
+const thumbnailMaxPixels = 40_000_000
+
+// ThumbnailPath is where the thumbnail stage writes a file's thumbnail.
+func ThumbnailPath(rec *model.FileRecord) string {
+	return rec.Path + ".thumb.png"
+}
+
+// thumbnailStage writes a downscaled PNG next to image uploads and records
+// its size as attributes["thumbnail"]. Other files pass through untouched.
+type thumbnailStage struct {
+	maxSide   int
+	maxPixels int
+}
+
+func (thumbnailStage) Name() string { return "thumbnail" }
+
+func (s thumbnailStage) Run(ctx context.Context, rec *model.FileRecord) error {
+	if !strings.HasPrefix(rec.ContentType, "image/") {
+		return nil
+	}
+	f, err := os.Open(rec.Path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	cfg, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return fmt.Errorf("decode image: %w", err)
+	}
+	if s.maxPixels > 0 && int64(cfg.Width)*int64(cfg.Height) > int64(s.maxPixels) {
+		return fmt.Errorf("image is %dx%d, over the %d pixel limit for thumbnails", cfg.Width, cfg.Height, s.maxPixels)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	src, _, err := image.Decode(f)
+	if err != nil {
+		return fmt.Errorf("decode image: %w", err)
+	}
+	thumb := scaleDown(src, s.maxSide)
+	out, err := os.Create(ThumbnailPath(rec))
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(out, thumb); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	b := thumb.Bounds()
+	rec.Attributes["thumbnail"] = fmt.Sprintf("%dx%d", b.Dx(), b.Dy())
+	return nil
+}
+
+// scaleDown shrinks src by nearest-neighbour sampling so its longer side is
+// at most maxSide. It is crude but needs nothing beyond the standard library.
+func scaleDown(src image.Image, maxSide int) image.Image {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= maxSide && h <= maxSide {
+		return src
+	}
+	tw, th := maxSide, h*maxSide/w
+	if h > w {
+		tw, th = w*maxSide/h, maxSide
+	}
+	if tw < 1 {
+		tw = 1
+	}
+	if th < 1 {
+		th = 1
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, tw, th))
+	for y := 0; y < th; y++ {
+		for x := 0; x < tw; x++ {
+			dst.Set(x, y, src.At(b.Min.X+x*w/tw, b.Min.Y+y*h/th))
+		}
+	}
+	return dst
+}
+
+// delayStage sleeps for d, standing in for heavy work in demos.
+type delayStage struct {
+	d time.Duration
+}
+
+func (delayStage) Name() string { return "simulate" }
+
+func (s delayStage) Run(ctx context.Context, rec *model.FileRecord) error {
+	select {
+	case <-time.After(s.d):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,150 @@
+package processing
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/model"
+)
+
+func TestStagesByName(t *testing.T) {
+	stages, err := StagesByName([]string{" checksum", "thumbnail ", "simulate"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, s := range stages {
+		names = append(names, s.Name())
+	}
+	if len(names) != 3 || names[0] != "checksum" || names[1] != "thumbnail" || names[2] != "simulate" {
+		t.Errorf("names = %v", names)
+	}
+	if _, err := StagesByName([]string{"checksum", "ocr"}); err == nil {
+		t.Error("StagesByName(ocr) succeeded")
+	}
+}
+
+func TestChecksumStage(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.txt")
+	if err := os.WriteFile(path, []byte("hello"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	rec := &model.FileRecord{Path: path, Attributes: map[string]string{}}
+	if err := (checksumStage{}).Run(context.Background(), rec); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := rec.Attributes["sha256"], "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"; got != want {
+		t.Errorf("sha256 = %s, want %s", got, want)
+	}
+	rec.Path = filepath.Join(t.TempDir(), "missing")
+	if err := (checksumStage{}).Run(context.Background(), rec); err == nil {
+		t.Error("checksum of a missing file succeeded")
+	}
+}
+
+func TestThumbnailStage(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wide.png")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(f, image.NewRGBA(image.Rect(0, 0, 300, 150))); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	stage := thumbnailStage{maxSide: thumbnailSide, maxPixels: thumbnailMaxPixels}
+	rec := &model.FileRecord{Path: path, ContentType: "image/png", Attributes: map[string]string{}}
+	if err := stage.Run(context.Background(), rec); err != nil {
+		t.Fatal(err)
+	}
+	if got := rec.Attributes["thumbnail"]; got != "128x64" {
+		t.Errorf("thumbnail = %s, want 128x64", got)
+	}
+	if _, err := os.Stat(ThumbnailPath(rec)); err != nil {
+		t.Errorf("thumbnail not written: %v", err)
+	}
+
+	// Files that are not images pass through.
+	rec = &model.FileRecord{Path: path, ContentType: "application/pdf", Attributes: map[string]string{}}
+	if err := stage.Run(context.Background(), rec); err != nil || len(rec.Attributes) != 0 {
+		t.Errorf("pdf: attributes %v, error %v", rec.Attributes, err)
+	}
+	// An image that does not decode fails the stage.
+	bad := filepath.Join(t.TempDir(), "bad.png")
+	if err := os.WriteFile(bad, []byte("not a png"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	rec = &model.FileRecord{Path: bad, ContentType: "image/png", Attributes: map[string]string{}}
+	if err := stage.Run(context.Background(), rec); err == nil {
+		t.Error("thumbnail of an invalid image succeeded")
+	}
+	// Images over the pixel cap are refused before they are decoded.
+	small := thumbnailStage{maxSide: thumbnailSide, maxPixels: 300*150 - 1}
+	rec = &model.FileRecord{Path: path, ContentType: "image/png", Attributes: map[string]string{}}
+	if err := small.Run(context.Background(), rec); err == nil || !strings.Contains(err.Error(), "pixel limit") {
+		t.Errorf("over the cap: error %v, want the pixel limit", err)
+	}
+	if len(rec.Attributes) != 0 {
+		t.Errorf("over the cap: attributes %v", rec.Attributes)
+	}
+}
+
+func TestThumbnailStageRejectsHugeHeader(t *testing.T) {
+	// A PNG whose header claims 100000x100000 pixels but that carries no
+	// image data: the header alone must be enough to refuse it.
+	var buf bytes.Buffer
+	buf.WriteString("\x89PNG\r\n\x1a\n")
+	ihdr := make([]byte, 13)
+	binary.BigEndian.PutUint32(ihdr[0:], 100000)
+	binary.BigEndian.PutUint32(ihdr[4:], 100000)
+	ihdr[8], ihdr[9] = 8, 6 // 8-bit RGBA
+	binary.Write(&buf, binary.BigEndian, uint32(len(ihdr)))
+	crc := crc32.NewIEEE()
+	io.MultiWriter(&buf, crc).Write(append([]byte("IHDR"), ihdr...))
+	binary.Write(&buf, binary.BigEndian, crc.Sum32())
+
+	path := filepath.Join(t.TempDir(), "huge.png")
+	if err := os.WriteFile(path, buf.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	stage := thumbnailStage{maxSide: thumbnailSide, maxPixels: thumbnailMaxPixels}
+	rec := &model.FileRecord{Path: path, ContentType: "image/png", Attributes: map[string]string{}}
+	if err := stage.Run(context.Background(), rec); err == nil || !strings.Contains(err.Error(), "100000x100000") {
+		t.Errorf("error = %v, want the pixel limit", err)
+	}
+}
+
+func TestScaleDown(t *testing.T) {
+	for _, tc := range []struct {
+		w, h, wantW, wantH int
+	}{
+		{100, 50, 100, 50},
+		{256, 128, 128, 64},
+		{128, 512, 32, 128},
+		{1000, 2, 128, 1},
+	} {
+		b := scaleDown(image.NewRGBA(image.Rect(0, 0, tc.w, tc.h)), 128).Bounds()
+		if b.Dx() != tc.wantW || b.Dy() != tc.wantH {
+			t.Errorf("scaleDown(%dx%d) = %dx%d, want %dx%d", tc.w, tc.h, b.Dx(), b.Dy(), tc.wantW, tc.wantH)
+		}
+	}
+}
+
+func TestDelayStageStopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := (delayStage{d: time.Hour}).Run(ctx, &model.FileRecord{}); err != context.Canceled {
+		t.Errorf("Run error = %v, want context.Canceled", err)
+	}
+}
@@ -0,0 +1,85 @@
+// Package progress shares the worker's view of an extraction in flight with
+// the API. The worker overwrites one Redis key per document as it moves
+// through the stages; the key expires on its own so abandoned runs do not
+// linger.
+package progress
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Stages reported while a document is processing.
+const (
+	StageDownloading = "downloading"
+	StageExtracting  = "extracting"
+	StageUploading   = "uploading"
+)
+
+// ttl bounds how long a report outlives the last update.
+const ttl = time.Hour
+
+// Report is the latest progress written for a document. Page and Pages are
+// only set while extracting.
+type Report struct {
+	Stage     string    `json:"stage"`
+	Page      int       `json:"page,omitempty"`
+	Pages     int       `json:"pages,omitempty"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Tracker reads and writes progress reports in Redis.
+type Tracker struct {
+	rdb *redis.Client
+}
+
+// NewTracker constructs a Tracker.
+func NewTracker(rdb *redis.Client) *Tracker {
+	return &Tracker{rdb: rdb}
+}
+
+func key(documentID string) string {
+	return "vaultdrop:progress:" + documentID
+}
+
+// Set replaces the report for documentID.
+func (t *Tracker) Set(ctx context.Context, documentID string, report Report) error {
+	report.UpdatedAt = time.Now().UTC()
+	data, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("marshal progress: %w", err)
+	}
+	if err := t.rdb.Set(ctx, key(documentID), data, ttl).Err(); err != nil {
+		return fmt.Errorf("write progress: %w", err)
+	}
+	return nil
+}
+
+// Get returns the report for documentID, or nil when none is recorded.
+func (t *Tracker) Get(ctx context.Context, documentID string) (*Report, error) {
+	data, err := t.rdb.Get(ctx, key(documentID)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read progress: %w", err)
+	}
+	var report Report
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("decode progress: %w", err)
+	}
+	return &report, nil
+}
+
+// Clear removes the report once processing finished either way.
+func (t *Tracker) Clear(ctx context.Context, documentID string) error {
+	if err := t.rdb.Del(ctx, key(documentID)).Err(); err != nil {
+		return fmt.Errorf("clear progress: %w", err)
+	}
+	return nil
+}
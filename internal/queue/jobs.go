@@ -3,7 +3,9 @@ package queue
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/hibiken/asynq"
 )
@@ -11,25 +13,276 @@ import (
 const (
 	// ExtractDocumentTask is scheduled each time a PDF is uploaded.
 	ExtractDocumentTask = "document:extract"
+	// EraseDocumentsTask executes a right-to-be-forgotten erasure request.
+	EraseDocumentsTask = "erasure:execute"
+	// RetentionSweepTask purges artifacts that outlived their retention period.
+	RetentionSweepTask = "retention:sweep"
+	// ArchiveSweepTask moves old documents' text out of Postgres into S3.
+	ArchiveSweepTask = "archive:sweep"
+	// CleanupOrphansTask removes objects no document refers to any more.
+	CleanupOrphansTask = "cleanup:orphans"
+	// ExportBuildTask builds the ZIP of a background export.
+	ExportBuildTask = "export:build"
+	// ExportExpireTask deletes an export's ZIP once its time is up.
+	ExportExpireTask = "export:expire"
 )
 
-// ExtractPayload is serialized into the task payload so the worker knows which
-// object to download from MinIO.
+// Queues tasks are routed to. The worker serves them with the weights in
+// VAULTDROP_QUEUE_WEIGHTS, so extraction is not starved by backfills.
+const (
+	ExtractQueue     = "extract"
+	DeriveQueue      = "derive"
+	MaintenanceQueue = "maintenance"
+	// LegacyQueue held every task before queues were split. Workers keep
+	// serving it so tasks enqueued by older builds still run.
+	LegacyQueue = "default"
+)
+
+// DeriveTask is the task type that builds one derived artifact (a pipeline
+// stage run after extraction) for a document.
+func DeriveTask(stage string) string {
+	return "derive:" + stage
+}
+
+// DerivePayload identifies the document a derive task works on.
+type DerivePayload struct {
+	DocumentID string `json:"document_id"`
+}
+
+// EnqueueDerive enqueues a derive task. The task id is fixed per document and
+// stage, so enqueueing the same work twice while it is still queued is a no-op
+// and reports false. A task that used up its retries stays archived under that
+// id; it is deleted through inspector and replaced, so a backfill re-run
+// tries the document again.
+func EnqueueDerive(ctx context.Context, client *asynq.Client, inspector *asynq.Inspector, stage string, payload DerivePayload) (bool, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return false, fmt.Errorf("marshal payload: %w", err)
+	}
+	task := asynq.NewTask(DeriveTask(stage), data)
+	id := DeriveTask(stage) + ":" + payload.DocumentID
+	ok, err := enqueueReplacingArchived(func() error {
+		_, err := client.EnqueueContext(ctx, task, asynq.MaxRetry(5), asynq.Queue(DeriveQueue), asynq.TaskID(id))
+		return err
+	}, inspector, DeriveQueue, id)
+	if err != nil {
+		return false, fmt.Errorf("enqueue %s task: %w", stage, err)
+	}
+	return ok, nil
+}
+
+// archivedTasks is the part of asynq.Inspector enqueueReplacingArchived uses.
+type archivedTasks interface {
+	GetTaskInfo(queue, id string) (*asynq.TaskInfo, error)
+	DeleteTask(queue, id string) error
+}
+
+// enqueueReplacingArchived calls enqueue, which enqueues a task with a fixed
+// id, and reports whether it did. When the id is taken by an archived task,
+// that task is deleted and enqueue tried once more; any other task holding it
+// is still to run, and false is reported.
+func enqueueReplacingArchived(enqueue func() error, tasks archivedTasks, queue, id string) (bool, error) {
+	err := enqueue()
+	if !errors.Is(err, asynq.ErrTaskIDConflict) {
+		return err == nil, err
+	}
+	info, err := tasks.GetTaskInfo(queue, id)
+	switch {
+	case errors.Is(err, asynq.ErrTaskNotFound):
+		// It finished between the two calls.
+	case err != nil:
+		return false, fmt.Errorf("inspect task %s: %w", id, err)
+	case info.State != asynq.TaskStateArchived:
+		return false, nil
+	default:
+		if err := tasks.DeleteTask(queue, id); err != nil && !errors.Is(err, asynq.ErrTaskNotFound) {
+			return false, fmt.Errorf("delete archived task %s: %w", id, err)
+		}
+	}
+	err = enqueue()
+	if errors.Is(err, asynq.ErrTaskIDConflict) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// ExtractPayload identifies the document an extract task works on. It holds
+// nothing else: the unique lock of an extract task is derived from its payload,
+// so any other field would let two tasks for one document run at once. The
+// worker reads the object key, file name and pipeline from the document row.
 type ExtractPayload struct {
 	DocumentID string `json:"document_id"`
-	ObjectKey  string `json:"object_key"`
-	FileName   string `json:"file_name"`
 }
 
-// EnqueueExtract enqueues a PDF extraction job.
-func EnqueueExtract(ctx context.Context, client *asynq.Client, payload ExtractPayload) error {
+// Extraction pipelines a reprocess request may ask for.
+const (
+	// PipelineText extracts in-process, or sandboxed when the worker is
+	// configured with VAULTDROP_EXTRACT_SANDBOX.
+	PipelineText = "text"
+	// PipelineSandbox always extracts in a child process, for documents
+	// that crashed or exhausted a worker before.
+	PipelineSandbox = "sandbox"
+)
+
+// Pipelines lists the extraction pipelines workers implement.
+var Pipelines = []string{PipelineText, PipelineSandbox}
+
+// KnownPipeline reports whether name is in Pipelines; empty means the
+// default pipeline and is known too.
+func KnownPipeline(name string) bool {
+	if name == "" {
+		return true
+	}
+	for _, p := range Pipelines {
+		if p == name {
+			return true
+		}
+	}
+	return false
+}
+
+// ExtractableTypes lists the media types workers have an extractor for. The
+// API accepts the configured allowed types that appear here.
+var ExtractableTypes = []string{"application/pdf", "text/plain"}
+
+// Extractable reports whether mediaType, without parameters, is in
+// ExtractableTypes.
+func Extractable(mediaType string) bool {
+	for _, t := range ExtractableTypes {
+		if t == mediaType {
+			return true
+		}
+	}
+	return false
+}
+
+// ExtractTaskTimeout is the asynq timeout extract tasks are enqueued with. The
+// worker applies its own, shorter, configurable deadline; this only bounds it.
+const ExtractTaskTimeout = 2 * time.Hour
+
+// extractUniqueWindow is how long an extract task stays unique once it is due.
+// It only has to outlast the retries; the lock is released as soon as the task
+// succeeds or is archived.
+const extractUniqueWindow = 24 * time.Hour
+
+// EnqueueExtract enqueues a PDF extraction job to run as soon as a worker is
+// free. See ScheduleExtract for the return values.
+func EnqueueExtract(ctx context.Context, client *asynq.Client, payload ExtractPayload) (bool, error) {
+	return ScheduleExtract(ctx, client, payload, time.Time{})
+}
+
+// ScheduleExtract enqueues a PDF extraction job that is held back until
+// processAt. A zero or past processAt runs it immediately. Extract tasks are
+// unique per document, so enqueueing one while an earlier task for the same
+// document is still pending, scheduled or running is a no-op and reports
+// false; two workers never extract one document at the same time.
+func ScheduleExtract(ctx context.Context, client *asynq.Client, payload ExtractPayload, processAt time.Time) (bool, error) {
+	task, err := extractTask(payload)
+	if err != nil {
+		return false, err
+	}
+	unique := extractUniqueWindow
+	opts := []asynq.Option{asynq.MaxRetry(5), asynq.Queue(ExtractQueue), asynq.Timeout(ExtractTaskTimeout)}
+	if !processAt.IsZero() {
+		opts = append(opts, asynq.ProcessAt(processAt))
+		if delay := time.Until(processAt); delay > 0 {
+			unique += delay
+		}
+	}
+	opts = append(opts, asynq.Unique(unique))
+	_, err = client.EnqueueContext(ctx, task, opts...)
+	if errors.Is(err, asynq.ErrDuplicateTask) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("enqueue extract task: %w", err)
+	}
+	return true, nil
+}
+
+// extractTask builds the task ScheduleExtract enqueues. asynq keys the unique
+// lock on the queue, the type and the payload bytes.
+func extractTask(payload ExtractPayload) (*asynq.Task, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("marshal payload: %w", err)
+	}
+	return asynq.NewTask(ExtractDocumentTask, data), nil
+}
+
+// ErasurePayload identifies the erasure request the worker should execute.
+type ErasurePayload struct {
+	RequestID string `json:"request_id"`
+}
+
+// EnqueueErasure enqueues an erasure job. Erasure is idempotent so retries are
+// safe if the worker stops midway.
+func EnqueueErasure(ctx context.Context, client *asynq.Client, payload ErasurePayload) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("marshal payload: %w", err)
+	}
+	task := asynq.NewTask(EraseDocumentsTask, data)
+	if _, err := client.EnqueueContext(ctx, task, asynq.MaxRetry(10), asynq.Queue(MaintenanceQueue)); err != nil {
+		return fmt.Errorf("enqueue erasure task: %w", err)
+	}
+	return nil
+}
+
+// ExportPayload identifies the export a task works on.
+type ExportPayload struct {
+	ExportID string `json:"export_id"`
+}
+
+// EnqueueExport enqueues the build of an export, and its expiry at expiresAt.
+func EnqueueExport(ctx context.Context, client *asynq.Client, payload ExportPayload, expiresAt time.Time) error {
 	data, err := json.Marshal(payload)
 	if err != nil {
 		return fmt.Errorf("marshal payload: %w", err)
 	}
-	task := asynq.NewTask(ExtractDocumentTask, data)
-	if _, err := client.EnqueueContext(ctx, task, asynq.MaxRetry(5)); err != nil {
-		return fmt.Errorf("enqueue extract task: %w", err)
+	// The expiry is queued first: an export without one would keep its ZIP
+	// forever.
+	expire := asynq.NewTask(ExportExpireTask, data)
+	if _, err := client.EnqueueContext(ctx, expire, asynq.ProcessAt(expiresAt), asynq.MaxRetry(10), asynq.Queue(MaintenanceQueue)); err != nil {
+		return fmt.Errorf("enqueue export expiry: %w", err)
+	}
+	build := asynq.NewTask(ExportBuildTask, data)
+	if _, err := client.EnqueueContext(ctx, build, asynq.MaxRetry(3), asynq.Queue(MaintenanceQueue)); err != nil {
+		return fmt.Errorf("enqueue export task: %w", err)
+	}
+	return nil
+}
+
+// ScheduleRetention registers the periodic retention sweep. Every worker
+// replica runs a scheduler, so the task is unique for one interval to avoid
+// duplicate sweeps.
+func ScheduleRetention(scheduler *asynq.Scheduler, interval time.Duration) error {
+	task := asynq.NewTask(RetentionSweepTask, nil)
+	spec := fmt.Sprintf("@every %s", interval)
+	if _, err := scheduler.Register(spec, task, asynq.Unique(interval), asynq.MaxRetry(0), asynq.Queue(MaintenanceQueue)); err != nil {
+		return fmt.Errorf("schedule retention sweep: %w", err)
+	}
+	return nil
+}
+
+// ScheduleArchive registers the periodic archive sweep, unique for one
+// interval like the retention sweep.
+func ScheduleArchive(scheduler *asynq.Scheduler, interval time.Duration) error {
+	task := asynq.NewTask(ArchiveSweepTask, nil)
+	spec := fmt.Sprintf("@every %s", interval)
+	if _, err := scheduler.Register(spec, task, asynq.Unique(interval), asynq.MaxRetry(0), asynq.Queue(MaintenanceQueue)); err != nil {
+		return fmt.Errorf("schedule archive sweep: %w", err)
+	}
+	return nil
+}
+
+// ScheduleCleanup registers the periodic orphan cleanup, unique for one
+// interval like the retention sweep.
+func ScheduleCleanup(scheduler *asynq.Scheduler, interval time.Duration) error {
+	task := asynq.NewTask(CleanupOrphansTask, nil)
+	spec := fmt.Sprintf("@every %s", interval)
+	if _, err := scheduler.Register(spec, task, asynq.Unique(interval), asynq.MaxRetry(0), asynq.Queue(MaintenanceQueue)); err != nil {
+		return fmt.Errorf("schedule orphan cleanup: %w", err)
 	}
 	return nil
 }
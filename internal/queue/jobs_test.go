@@ -0,0 +1,114 @@
+package queue
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/hibiken/asynq"
+)
+
+// asynq derives an extract task's unique lock from its queue, type and
+// payload. A reprocess must build the same task as the upload that created
+// the document, whatever pipeline it asks for, so it shares the upload's lock
+// and cannot run alongside an extraction still in flight. Any field besides
+// the document id would break that.
+func TestExtractTaskUniquePerDocument(t *testing.T) {
+	task, err := extractTask(ExtractPayload{DocumentID: "doc-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if task.Type() != ExtractDocumentTask {
+		t.Errorf("type = %s", task.Type())
+	}
+	if got, want := string(task.Payload()), `{"document_id":"doc-1"}`; got != want {
+		t.Errorf("payload = %s, want %s", got, want)
+	}
+	other, err := extractTask(ExtractPayload{DocumentID: "doc-2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(other.Payload()) == string(task.Payload()) {
+		t.Error("two documents share a unique lock")
+	}
+}
+
+// fakeTasks is a queue holding at most one task per id.
+type fakeTasks struct {
+	states  map[string]asynq.TaskState
+	deleted []string
+}
+
+func (f *fakeTasks) enqueue(id string) func() error {
+	return func() error {
+		if _, ok := f.states[id]; ok {
+			return asynq.ErrTaskIDConflict
+		}
+		f.states[id] = asynq.TaskStatePending
+		return nil
+	}
+}
+
+func (f *fakeTasks) GetTaskInfo(queue, id string) (*asynq.TaskInfo, error) {
+	state, ok := f.states[id]
+	if !ok {
+		return nil, asynq.ErrTaskNotFound
+	}
+	return &asynq.TaskInfo{ID: id, Queue: queue, State: state}, nil
+}
+
+func (f *fakeTasks) DeleteTask(queue, id string) error {
+	f.deleted = append(f.deleted, id)
+	delete(f.states, id)
+	return nil
+}
+
+func TestEnqueueReplacingArchived(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		existing    asynq.TaskState
+		wantQueued  bool
+		wantDeleted bool
+	}{
+		{"no task yet", 0, true, false},
+		{"still pending", asynq.TaskStatePending, false, false},
+		{"running", asynq.TaskStateActive, false, false},
+		{"waiting to retry", asynq.TaskStateRetry, false, false},
+		{"archived after its retries", asynq.TaskStateArchived, true, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tasks := &fakeTasks{states: map[string]asynq.TaskState{}}
+			if tc.existing != 0 {
+				tasks.states["derive:layout:doc-1"] = tc.existing
+			}
+			queued, err := enqueueReplacingArchived(tasks.enqueue("derive:layout:doc-1"), tasks, DeriveQueue, "derive:layout:doc-1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if queued != tc.wantQueued || (len(tasks.deleted) > 0) != tc.wantDeleted {
+				t.Errorf("queued %v, deleted %v", queued, tasks.deleted)
+			}
+			if tc.wantQueued && tasks.states["derive:layout:doc-1"] != asynq.TaskStatePending {
+				t.Errorf("state after enqueue = %v", tasks.states["derive:layout:doc-1"])
+			}
+		})
+	}
+
+	// A task that finished between the conflict and the lookup is replaced
+	// without deleting anything.
+	tasks := &fakeTasks{states: map[string]asynq.TaskState{}}
+	calls := 0
+	enqueue := func() error {
+		if calls++; calls == 1 {
+			return asynq.ErrTaskIDConflict
+		}
+		return nil
+	}
+	if queued, err := enqueueReplacingArchived(enqueue, tasks, DeriveQueue, "x"); !queued || err != nil || len(tasks.deleted) != 0 {
+		t.Errorf("finished meanwhile: queued %v, err %v, deleted %v", queued, err, tasks.deleted)
+	}
+
+	failing := func() error { return errors.New("redis down") }
+	if _, err := enqueueReplacingArchived(failing, tasks, DeriveQueue, "x"); err == nil {
+		t.Error("enqueue error was swallowed")
+	}
+}
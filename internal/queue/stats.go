@@ -0,0 +1,52 @@
+package queue
+
+import (
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/hibiken/asynq"
+)
+
+// Backlog is the task count per state for one asynq queue.
+type Backlog struct {
+	Queue     string `json:"queue"`
+	Pending   int    `json:"pending"`
+	Active    int    `json:"active"`
+	Scheduled int    `json:"scheduled"`
+	Retry     int    `json:"retry"`
+	Archived  int    `json:"archived"`
+	// LatencyMS is the age of the oldest pending task, zero when none is
+	// waiting.
+	LatencyMS int64 `json:"latencyMs"`
+	Paused    bool  `json:"paused"`
+}
+
+// Backlogs reads the backlog of every queue, sorted by name. Queues that
+// cannot be read are logged and left out.
+func Backlogs(inspector *asynq.Inspector) ([]Backlog, error) {
+	names, err := inspector.Queues()
+	if err != nil {
+		return nil, fmt.Errorf("list queues: %w", err)
+	}
+	sort.Strings(names)
+	out := make([]Backlog, 0, len(names))
+	for _, name := range names {
+		info, err := inspector.GetQueueInfo(name)
+		if err != nil {
+			log.Printf("queue %s: %v", name, err)
+			continue
+		}
+		out = append(out, Backlog{
+			Queue:     info.Queue,
+			Pending:   info.Pending,
+			Active:    info.Active,
+			Scheduled: info.Scheduled,
+			Retry:     info.Retry,
+			Archived:  info.Archived,
+			LatencyMS: info.Latency.Milliseconds(),
+			Paused:    info.Paused,
+		})
+	}
+	return out, nil
+}
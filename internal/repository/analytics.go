@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Access is a way a document's content was handed out.
+type Access string
+
+const (
+	// AccessDownload is a download through the API.
+	AccessDownload Access = "download"
+	// AccessSignedURL is a presigned URL issued for the raw upload or the
+	// extracted text. What happens to the URL afterwards happens at the
+	// object store and is not counted.
+	AccessSignedURL Access = "signed_url"
+)
+
+// AccessTotals counts a document's downloads and signed URLs since upload.
+type AccessTotals struct {
+	Downloads  int64 `json:"downloads"`
+	SignedURLs int64 `json:"signedUrls"`
+	// LastAccessAt is nil for a document that was never handed out.
+	LastAccessAt *time.Time `json:"lastAccessAt,omitempty"`
+}
+
+// AccessDay counts one UTC day's downloads and signed URLs.
+type AccessDay struct {
+	Day        string `json:"day"`
+	Downloads  int64  `json:"downloads"`
+	SignedURLs int64  `json:"signedUrls"`
+}
+
+// RecordAccess counts one access of kind to the document today.
+func (r *DocumentRepository) RecordAccess(ctx context.Context, documentID string, kind Access) error {
+	var column string
+	switch kind {
+	case AccessDownload:
+		column = "downloads"
+	case AccessSignedURL:
+		column = "signed_urls"
+	default:
+		return fmt.Errorf("unknown access %q", kind)
+	}
+	now := time.Now().UTC()
+	_, err := r.pool.Exec(ctx, `
+		INSERT INTO document_access_stats (document_id, day, `+column+`, last_access_at)
+		VALUES ($1, $2, 1, $3)
+		ON CONFLICT (document_id, day) DO UPDATE
+		SET `+column+` = document_access_stats.`+column+` + 1, last_access_at = EXCLUDED.last_access_at
+	`, documentID, now.Truncate(24*time.Hour), now)
+	if err != nil {
+		return fmt.Errorf("record %s: %w", kind, err)
+	}
+	return nil
+}
+
+// AccessTotals sums the document's accesses over all days.
+func (r *DocumentRepository) AccessTotals(ctx context.Context, documentID string) (AccessTotals, error) {
+	var t AccessTotals
+	err := r.pool.QueryRow(ctx, `
+		SELECT COALESCE(SUM(downloads), 0), COALESCE(SUM(signed_urls), 0), MAX(last_access_at)
+		FROM document_access_stats WHERE document_id=$1
+	`, documentID).Scan(&t.Downloads, &t.SignedURLs, &t.LastAccessAt)
+	if err != nil {
+		return t, fmt.Errorf("select access totals: %w", err)
+	}
+	return t, nil
+}
+
+// ListAccessDays returns the document's accesses per day from since on,
+// oldest first. Days without any are left out.
+func (r *DocumentRepository) ListAccessDays(ctx context.Context, documentID string, since time.Time) ([]AccessDay, error) {
+	rows, err := r.pool.Query(ctx, `
+		SELECT to_char(day, 'YYYY-MM-DD'), downloads, signed_urls FROM document_access_stats
+		WHERE document_id=$1 AND day >= $2 ORDER BY day
+	`, documentID, since.UTC().Truncate(24*time.Hour))
+	if err != nil {
+		return nil, fmt.Errorf("select access days: %w", err)
+	}
+	days, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (AccessDay, error) {
+		var d AccessDay
+		err := row.Scan(&d.Day, &d.Downloads, &d.SignedURLs)
+		return d, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("scan access days: %w", err)
+	}
+	return days, nil
+}
@@ -0,0 +1,15 @@
+package repository
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRecordAccessRejectsUnknownKind(t *testing.T) {
+	// The kind picks a column, so anything else is refused before the
+	// database is touched.
+	var r DocumentRepository
+	if err := r.RecordAccess(context.Background(), "a", Access("upload")); err == nil {
+		t.Error("RecordAccess accepted an unknown kind")
+	}
+}
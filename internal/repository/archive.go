@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ListArchivable returns the ids of up to limit completed documents created
+// before cutoff whose text is still in the content column, oldest first.
+func (r *DocumentRepository) ListArchivable(ctx context.Context, cutoff time.Time, limit int) ([]string, error) {
+	rows, err := r.pool.Query(ctx, `
+		SELECT id FROM documents
+		WHERE created_at < $1 AND archived_at IS NULL AND content IS NOT NULL AND content <> ''
+			AND status = 'completed' AND text_purged_at IS NULL
+		ORDER BY created_at LIMIT $2
+	`, cutoff, limit)
+	if err != nil {
+		return nil, fmt.Errorf("select archivable documents: %w", err)
+	}
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("scan archivable documents: %w", err)
+	}
+	return ids, nil
+}
+
+// ArchivedVersion is a version whose text was copied to the object at Key.
+type ArchivedVersion struct {
+	Version int
+	Key     string
+}
+
+// MarkArchived clears the content column of a document whose text was
+// copied to the object at key, and the bodies of the versions copied to
+// theirs. It changes nothing and reports false when the document was updated
+// after updatedAt, for example by a reprocess, so text that was not archived
+// is never dropped.
+func (r *DocumentRepository) MarkArchived(ctx context.Context, id, key string, updatedAt time.Time, versions []ArchivedVersion) (bool, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return false, fmt.Errorf("begin mark archived: %w", err)
+	}
+	defer tx.Rollback(ctx)
+	now := time.Now().UTC()
+	tag, err := tx.Exec(ctx, `
+		UPDATE documents SET content=NULL, archive_key=$2, archived_at=$3, updated_at=$3
+		WHERE id=$1 AND updated_at=$4 AND archived_at IS NULL AND status=$5
+	`, id, key, now, updatedAt, StatusCompleted)
+	if err != nil {
+		return false, fmt.Errorf("mark archived: %w", err)
+	}
+	if tag.RowsAffected() != 1 {
+		return false, nil
+	}
+	for _, v := range versions {
+		_, err := tx.Exec(ctx, `
+			UPDATE document_versions SET size=octet_length(content), content=NULL, archive_key=$3
+			WHERE document_id=$1 AND version=$2 AND content IS NOT NULL
+		`, id, v.Version, v.Key)
+		if err != nil {
+			return false, fmt.Errorf("mark version %d archived: %w", v.Version, err)
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return false, fmt.Errorf("commit mark archived: %w", err)
+	}
+	return true, nil
+}
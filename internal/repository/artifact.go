@@ -0,0 +1,43 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// RecordArtifact notes that the derived artifact for stage exists for the
+// document, so backfills skip it.
+func (r *DocumentRepository) RecordArtifact(ctx context.Context, id, stage string) error {
+	_, err := r.pool.Exec(ctx, `
+		INSERT INTO document_artifacts (document_id, stage, created_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (document_id, stage) DO NOTHING
+	`, id, stage, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("record %s artifact: %w", stage, err)
+	}
+	return nil
+}
+
+// ListMissingArtifacts returns up to limit ids of completed documents, after
+// the given id in id order, that have no artifact recorded for stage. Passing
+// the last id back pages through the whole table without offsets.
+func (r *DocumentRepository) ListMissingArtifacts(ctx context.Context, stage, after string, limit int) ([]string, error) {
+	rows, err := r.pool.Query(ctx, `
+		SELECT d.id FROM documents d
+		WHERE d.status = $1 AND d.id > $2 AND d.text_purged_at IS NULL
+		AND NOT EXISTS (SELECT 1 FROM document_artifacts a WHERE a.document_id = d.id AND a.stage = $3)
+		ORDER BY d.id LIMIT $4
+	`, StatusCompleted, after, stage, limit)
+	if err != nil {
+		return nil, fmt.Errorf("select documents missing %s: %w", stage, err)
+	}
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("scan documents missing %s: %w", stage, err)
+	}
+	return ids, nil
+}
@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// maxAttemptsListed bounds GetAttempts; a document retried this often has a
+// problem the oldest attempts will not explain.
+const maxAttemptsListed = 100
+
+// Attempt is one extraction run for a document. FinishedAt is nil while it
+// runs, or when the worker died before recording the outcome.
+type Attempt struct {
+	ID         int64      `json:"id"`
+	TaskID     string     `json:"taskId,omitempty"`
+	Retry      int        `json:"retry"`
+	WorkerID   string     `json:"workerId"`
+	StartedAt  time.Time  `json:"startedAt"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+	Error      *string    `json:"error,omitempty"`
+}
+
+// StartAttempt records the start of an extraction run and returns its id.
+func (r *DocumentRepository) StartAttempt(ctx context.Context, documentID, taskID string, retry int, workerID string) (int64, error) {
+	var id int64
+	err := r.pool.QueryRow(ctx, `
+		INSERT INTO document_attempts (document_id, task_id, retry, worker_id, started_at)
+		VALUES ($1,$2,$3,$4,$5) RETURNING id
+	`, documentID, taskID, retry, workerID, time.Now().UTC()).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("insert attempt: %w", err)
+	}
+	return id, nil
+}
+
+// FinishAttempt records the outcome of a run; runErr nil means success.
+func (r *DocumentRepository) FinishAttempt(ctx context.Context, id int64, runErr error) error {
+	var msg *string
+	if runErr != nil {
+		s := runErr.Error()
+		msg = &s
+	}
+	if _, err := r.pool.Exec(ctx, `UPDATE document_attempts SET finished_at=$1, error=$2 WHERE id=$3`, time.Now().UTC(), msg, id); err != nil {
+		return fmt.Errorf("finish attempt: %w", err)
+	}
+	return nil
+}
+
+// RunningAttempts counts the document's attempts started after since that
+// have not finished. Older unfinished ones belong to workers that died.
+func (r *DocumentRepository) RunningAttempts(ctx context.Context, documentID string, since time.Time) (int, error) {
+	var n int
+	err := r.pool.QueryRow(ctx, `
+		SELECT COUNT(*) FROM document_attempts WHERE document_id=$1 AND finished_at IS NULL AND started_at > $2
+	`, documentID, since).Scan(&n)
+	if err != nil {
+		return 0, fmt.Errorf("count running attempts: %w", err)
+	}
+	return n, nil
+}
+
+// ListAttempts returns a document's most recent attempts, oldest first.
+func (r *DocumentRepository) ListAttempts(ctx context.Context, documentID string) ([]Attempt, error) {
+	rows, err := r.pool.Query(ctx, `
+		SELECT id, task_id, retry, worker_id, started_at, finished_at, error FROM (
+			SELECT * FROM document_attempts WHERE document_id=$1 ORDER BY started_at DESC, id DESC LIMIT $2
+		) recent ORDER BY started_at, id
+	`, documentID, maxAttemptsListed)
+	if err != nil {
+		return nil, fmt.Errorf("select attempts: %w", err)
+	}
+	attempts, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (Attempt, error) {
+		var a Attempt
+		err := row.Scan(&a.ID, &a.TaskID, &a.Retry, &a.WorkerID, &a.StartedAt, &a.FinishedAt, &a.Error)
+		return a, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("scan attempts: %w", err)
+	}
+	return attempts, nil
+}
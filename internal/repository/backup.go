@@ -0,0 +1,237 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// DocumentRecord is a documents row as DumpDocuments reads it and
+// RestoreDocuments writes it back, for moving documents between
+// environments. Unlike Document it carries every column, the wrapped data key
+// included, except the change sequence: restored rows get a new one.
+type DocumentRecord struct {
+	ID           string            `json:"id"`
+	FileName     string            `json:"fileName"`
+	ObjectKey    string            `json:"objectKey"`
+	ProcessedKey *string           `json:"processedKey,omitempty"`
+	Status       DocumentStatus    `json:"status"`
+	Content      *string           `json:"content,omitempty"`
+	ErrorMessage *string           `json:"errorMessage,omitempty"`
+	DropID       *string           `json:"dropId,omitempty"`
+	OwnerID      *string           `json:"ownerId,omitempty"`
+	RawPurgedAt  *time.Time        `json:"rawPurgedAt,omitempty"`
+	TextPurgedAt *time.Time        `json:"textPurgedAt,omitempty"`
+	ArchivedAt   *time.Time        `json:"archivedAt,omitempty"`
+	ArchiveKey   *string           `json:"archiveKey,omitempty"`
+	ProcessAt    *time.Time        `json:"processAt,omitempty"`
+	Pipeline     *string           `json:"pipeline,omitempty"`
+	Metadata     map[string]string `json:"metadata"`
+	Tags         []string          `json:"tags"`
+	Extraction   *ExtractionStats  `json:"extraction,omitempty"`
+	SHA256       *string           `json:"sha256,omitempty"`
+	// DataKeyID and DataKey are the wrapped data key of an encrypted raw
+	// upload; the target needs the same master key to read it.
+	DataKeyID *string   `json:"dataKeyId,omitempty"`
+	DataKey   []byte    `json:"dataKey,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Validate reports a record RestoreDocuments would refuse.
+func (rec *DocumentRecord) Validate() error {
+	switch {
+	case rec.ID == "":
+		return errors.New("id is required")
+	case rec.FileName == "":
+		return fmt.Errorf("%s: fileName is required", rec.ID)
+	case rec.ObjectKey == "":
+		return fmt.Errorf("%s: objectKey is required", rec.ID)
+	case rec.CreatedAt.IsZero() || rec.UpdatedAt.IsZero():
+		return fmt.Errorf("%s: createdAt and updatedAt are required", rec.ID)
+	case (rec.DataKeyID == nil) != (len(rec.DataKey) == 0):
+		return fmt.Errorf("%s: dataKey and dataKeyId go together", rec.ID)
+	}
+	switch rec.Status {
+	case StatusQueued, StatusProcessing, StatusCompleted, StatusFailed, StatusCancelled:
+		return nil
+	}
+	return fmt.Errorf("%s: unknown status %q", rec.ID, rec.Status)
+}
+
+// documentRecordColumns are the documents columns a DocumentRecord carries,
+// in the order of its fields.
+const documentRecordColumns = `id, file_name, object_key, processed_key, status, content, error_message, drop_id, owner_id, raw_purged_at, text_purged_at, archived_at, archive_key, process_at, pipeline, metadata, tags, extraction, sha256, data_key_id, data_key, created_at, updated_at`
+
+// fields returns pointers to rec's fields in documentRecordColumns order, to
+// scan a row into.
+func (rec *DocumentRecord) fields() []interface{} {
+	return []interface{}{&rec.ID, &rec.FileName, &rec.ObjectKey, &rec.ProcessedKey, &rec.Status, &rec.Content, &rec.ErrorMessage, &rec.DropID, &rec.OwnerID, &rec.RawPurgedAt, &rec.TextPurgedAt, &rec.ArchivedAt, &rec.ArchiveKey, &rec.ProcessAt, &rec.Pipeline, &rec.Metadata, &rec.Tags, &rec.Extraction, &rec.SHA256, &rec.DataKeyID, &rec.DataKey, &rec.CreatedAt, &rec.UpdatedAt}
+}
+
+// values returns rec's fields in documentRecordColumns order, to insert.
+func (rec *DocumentRecord) values() []interface{} {
+	return []interface{}{rec.ID, rec.FileName, rec.ObjectKey, rec.ProcessedKey, rec.Status, rec.Content, rec.ErrorMessage, rec.DropID, rec.OwnerID, rec.RawPurgedAt, rec.TextPurgedAt, rec.ArchivedAt, rec.ArchiveKey, rec.ProcessAt, rec.Pipeline, rec.Metadata, rec.Tags, rec.Extraction, rec.SHA256, rec.DataKeyID, rec.DataKey, rec.CreatedAt, rec.UpdatedAt}
+}
+
+// settle returns rec as it is restored. No task exists for a document the
+// source had queued or processing, so it would wait forever; it is restored
+// as failed instead, and reprocessing it queues a task. It reports whether
+// the status changed.
+func (rec DocumentRecord) settle() (DocumentRecord, bool) {
+	if rec.Status != StatusQueued && rec.Status != StatusProcessing {
+		return rec, false
+	}
+	msg := fmt.Sprintf("imported while %s; reprocess to extract it", rec.Status)
+	rec.Status = StatusFailed
+	rec.ErrorMessage = &msg
+	rec.ProcessAt = nil
+	return rec, true
+}
+
+// DumpDocuments returns, in id order after the given id, up to limit
+// documents with every column.
+func (r *DocumentRepository) DumpDocuments(ctx context.Context, after string, limit int) ([]DocumentRecord, error) {
+	rows, err := r.pool.Query(ctx, `
+		SELECT `+documentRecordColumns+`
+		FROM documents WHERE id > $1
+		ORDER BY id LIMIT $2
+	`, after, limit)
+	if err != nil {
+		return nil, fmt.Errorf("select documents: %w", err)
+	}
+	out, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (DocumentRecord, error) {
+		var rec DocumentRecord
+		err := row.Scan(rec.fields()...)
+		return rec, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("scan documents: %w", err)
+	}
+	return out, nil
+}
+
+// RestoreResult counts what RestoreDocuments did with each record.
+type RestoreResult struct {
+	Inserted int
+	Updated  int
+	// Skipped counts records whose id already existed, left alone without
+	// overwrite.
+	Skipped int
+	// Failed counts inserted or updated records that were queued or
+	// processing at the source and were restored as failed.
+	Failed int
+	// Erased counts records of documents an erasure request removed here,
+	// left out so a dump taken before the erasure does not bring them back.
+	Erased int
+}
+
+// RestoreDocuments inserts recs in one transaction. A record whose id
+// exists is skipped, or replaces the row when overwrite is set. A record of
+// an erased document is never restored. A record
+// whose object key belongs to another document fails the whole batch with
+// ErrExists. Records still queued or processing are restored as failed, since
+// no task was queued for them here.
+func (r *DocumentRepository) RestoreDocuments(ctx context.Context, recs []DocumentRecord, overwrite bool) (RestoreResult, error) {
+	var res RestoreResult
+	for i := range recs {
+		if err := recs[i].Validate(); err != nil {
+			return res, err
+		}
+	}
+	conflict := `ON CONFLICT (id) DO NOTHING`
+	if overwrite {
+		conflict = `ON CONFLICT (id) DO UPDATE SET
+			file_name=EXCLUDED.file_name, object_key=EXCLUDED.object_key, processed_key=EXCLUDED.processed_key,
+			status=EXCLUDED.status, content=EXCLUDED.content, error_message=EXCLUDED.error_message,
+			drop_id=EXCLUDED.drop_id, owner_id=EXCLUDED.owner_id, raw_purged_at=EXCLUDED.raw_purged_at,
+			text_purged_at=EXCLUDED.text_purged_at, archived_at=EXCLUDED.archived_at, archive_key=EXCLUDED.archive_key,
+			process_at=EXCLUDED.process_at, pipeline=EXCLUDED.pipeline, metadata=EXCLUDED.metadata, tags=EXCLUDED.tags,
+			extraction=EXCLUDED.extraction, sha256=EXCLUDED.sha256, data_key_id=EXCLUDED.data_key_id,
+			data_key=EXCLUDED.data_key, created_at=EXCLUDED.created_at, updated_at=EXCLUDED.updated_at`
+	}
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return res, fmt.Errorf("begin restore: %w", err)
+	}
+	defer tx.Rollback(ctx)
+	for _, rec := range recs {
+		rec, failed := rec.settle()
+		outcome, err := restoreRecord(ctx, tx, rec, conflict)
+		if err != nil {
+			return RestoreResult{}, err
+		}
+		switch outcome {
+		case restoreErased:
+			res.Erased++
+			continue
+		case restoreSkipped:
+			res.Skipped++
+			continue
+		case restoreInserted:
+			res.Inserted++
+		case restoreUpdated:
+			res.Updated++
+		}
+		if failed {
+			res.Failed++
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return RestoreResult{}, fmt.Errorf("commit restore: %w", err)
+	}
+	return res, nil
+}
+
+// restoreOutcome is what restoreRecord did with one record.
+type restoreOutcome int
+
+const (
+	restoreInserted restoreOutcome = iota
+	restoreUpdated
+	restoreSkipped
+	restoreErased
+)
+
+// restoreRecord writes rec with the given ON CONFLICT clause, unless the
+// document has a tombstone.
+func restoreRecord(ctx context.Context, q querier, rec DocumentRecord, conflict string) (restoreOutcome, error) {
+	var erased bool
+	err := q.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM document_tombstones WHERE document_id=$1)`, rec.ID).Scan(&erased)
+	if err != nil {
+		return 0, fmt.Errorf("restore %s: check tombstone: %w", rec.ID, err)
+	}
+	if erased {
+		return restoreErased, nil
+	}
+	if rec.Metadata == nil {
+		rec.Metadata = map[string]string{}
+	}
+	if rec.Tags == nil {
+		rec.Tags = []string{}
+	}
+	// xmax is 0 for a freshly inserted row and set for an updated one.
+	var inserted bool
+	err = q.QueryRow(ctx, `
+		INSERT INTO documents (`+documentRecordColumns+`)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16,$17,$18,$19,$20,$21,$22,$23)
+		`+conflict+`
+		RETURNING xmax = 0
+	`, rec.values()...).Scan(&inserted)
+	var pgErr *pgconn.PgError
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		return restoreSkipped, nil
+	case errors.As(err, &pgErr) && pgErr.Code == uniqueViolation:
+		return 0, fmt.Errorf("restore %s: object key %s: %w", rec.ID, rec.ObjectKey, ErrExists)
+	case err != nil:
+		return 0, fmt.Errorf("restore %s: %w", rec.ID, err)
+	case inserted:
+		return restoreInserted, nil
+	}
+	return restoreUpdated, nil
+}
@@ -0,0 +1,169 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func TestDocumentRecordRoundTrip(t *testing.T) {
+	keyID, pipeline := "2026", "ocr"
+	at := time.Date(2026, 3, 1, 10, 30, 0, 0, time.UTC)
+	rec := DocumentRecord{
+		ID:        "doc-1",
+		FileName:  "invoice.pdf",
+		ObjectKey: "uploads/doc-1/invoice.pdf",
+		Status:    StatusCompleted,
+		Pipeline:  &pipeline,
+		Metadata:  map[string]string{"invoice_number": "INV-42"},
+		Tags:      []string{"paid"},
+		DataKeyID: &keyID,
+		DataKey:   []byte{0, 1, 2, 0xff},
+		CreatedAt: at,
+		UpdatedAt: at,
+	}
+	if err := rec.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got DocumentRecord
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.DataKey, rec.DataKey) || *got.DataKeyID != keyID || got.Pipeline == nil || *got.Pipeline != pipeline || got.Metadata["invoice_number"] != "INV-42" || !got.CreatedAt.Equal(at) {
+		t.Errorf("round trip = %+v", got)
+	}
+}
+
+func TestDocumentRecordValidate(t *testing.T) {
+	at := time.Now()
+	valid := func() DocumentRecord {
+		return DocumentRecord{ID: "doc-1", FileName: "a.pdf", ObjectKey: "uploads/doc-1/a.pdf", Status: StatusQueued, CreatedAt: at, UpdatedAt: at}
+	}
+	for name, change := range map[string]func(*DocumentRecord){
+		"missing id":          func(r *DocumentRecord) { r.ID = "" },
+		"missing object key":  func(r *DocumentRecord) { r.ObjectKey = "" },
+		"unknown status":      func(r *DocumentRecord) { r.Status = "done" },
+		"missing created at":  func(r *DocumentRecord) { r.CreatedAt = time.Time{} },
+		"data key without id": func(r *DocumentRecord) { r.DataKey = []byte{1} },
+	} {
+		rec := valid()
+		change(&rec)
+		if err := rec.Validate(); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestDocumentRecordColumns(t *testing.T) {
+	// Dump scans into fields and Restore inserts values; both must cover
+	// every column, in order.
+	columns := strings.Split(documentRecordColumns, ", ")
+	var rec DocumentRecord
+	if n := len(rec.fields()); n != len(columns) {
+		t.Errorf("fields() has %d entries for %d columns", n, len(columns))
+	}
+	if n := len(rec.values()); n != len(columns) {
+		t.Errorf("values() has %d entries for %d columns", n, len(columns))
+	}
+	for _, col := range []string{"pipeline", "data_key", "process_at"} {
+		if !strings.Contains(", "+documentRecordColumns+", ", ", "+col+", ") {
+			t.Errorf("documentRecordColumns misses %s", col)
+		}
+	}
+	pipeline := "ocr"
+	rec = DocumentRecord{ID: "doc-1", Pipeline: &pipeline, UpdatedAt: time.Unix(1, 0)}
+	values, fields := rec.values(), rec.fields()
+	if values[0] != "doc-1" || values[14] != &pipeline || fields[14] != &rec.Pipeline || values[22] != rec.UpdatedAt {
+		t.Errorf("values = %v", values)
+	}
+}
+
+func TestDocumentRecordSettle(t *testing.T) {
+	at := time.Now()
+	for status, want := range map[DocumentStatus]DocumentStatus{
+		StatusQueued:     StatusFailed,
+		StatusProcessing: StatusFailed,
+		StatusCompleted:  StatusCompleted,
+		StatusFailed:     StatusFailed,
+		StatusCancelled:  StatusCancelled,
+	} {
+		rec := DocumentRecord{ID: "doc-1", Status: status, ProcessAt: &at}
+		got, changed := rec.settle()
+		if got.Status != want || changed != (status != want) {
+			t.Errorf("settle(%s) = %s, %v", status, got.Status, changed)
+		}
+		if !changed {
+			if got.ErrorMessage != nil || got.ProcessAt == nil {
+				t.Errorf("settle(%s) changed the record: %+v", status, got)
+			}
+			continue
+		}
+		if got.ErrorMessage == nil || !strings.Contains(*got.ErrorMessage, string(status)) || got.ProcessAt != nil {
+			t.Errorf("settle(%s) = %+v", status, got)
+		}
+		if rec.Status != status {
+			t.Errorf("settle changed its receiver")
+		}
+	}
+}
+
+// restoreQuerier answers the tombstone check with erased and the insert with
+// a new row, recording the statements it sees.
+type restoreQuerier struct {
+	erased bool
+	sql    []string
+}
+
+func (q *restoreQuerier) Query(context.Context, string, ...any) (pgx.Rows, error) {
+	return nil, errors.New("unexpected query")
+}
+
+func (q *restoreQuerier) QueryRow(_ context.Context, sql string, _ ...any) pgx.Row {
+	q.sql = append(q.sql, sql)
+	if strings.Contains(sql, "document_tombstones") {
+		return boolRow(q.erased)
+	}
+	return boolRow(true)
+}
+
+type boolRow bool
+
+func (b boolRow) Scan(dest ...any) error {
+	*dest[0].(*bool) = bool(b)
+	return nil
+}
+
+func TestRestoreRecordSkipsErased(t *testing.T) {
+	at := time.Now()
+	rec := DocumentRecord{ID: "doc-1", FileName: "a.pdf", ObjectKey: "uploads/doc-1/a.pdf", Status: StatusCompleted, CreatedAt: at, UpdatedAt: at}
+
+	q := &restoreQuerier{erased: true}
+	got, err := restoreRecord(context.Background(), q, rec, `ON CONFLICT (id) DO NOTHING`)
+	if err != nil || got != restoreErased {
+		t.Fatalf("restoreRecord(erased) = %v, %v; want restoreErased", got, err)
+	}
+	for _, sql := range q.sql {
+		if strings.Contains(sql, "INSERT") {
+			t.Errorf("erased document was inserted: %s", sql)
+		}
+	}
+
+	q = &restoreQuerier{}
+	got, err = restoreRecord(context.Background(), q, rec, `ON CONFLICT (id) DO NOTHING`)
+	if err != nil || got != restoreInserted {
+		t.Fatalf("restoreRecord = %v, %v; want restoreInserted", got, err)
+	}
+	if len(q.sql) != 2 || !strings.Contains(q.sql[1], "INSERT INTO documents") {
+		t.Errorf("statements = %q", q.sql)
+	}
+}
@@ -0,0 +1,150 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// RequeuedDocument carries what the extract task needs for a requeued row.
+type RequeuedDocument struct {
+	ID        string
+	ObjectKey string
+	FileName  string
+}
+
+// CountByStatus returns the number of documents in each status. Every status
+// is present in the result, with zero when no document has it.
+func (r *DocumentRepository) CountByStatus(ctx context.Context) (map[DocumentStatus]int64, error) {
+	counts := map[DocumentStatus]int64{
+		StatusQueued:     0,
+		StatusProcessing: 0,
+		StatusCompleted:  0,
+		StatusFailed:     0,
+		StatusCancelled:  0,
+	}
+	rows, err := r.pool.Query(ctx, `SELECT status, COUNT(*) FROM documents GROUP BY status`)
+	if err != nil {
+		return nil, fmt.Errorf("count documents: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			status DocumentStatus
+			n      int64
+		)
+		if err := rows.Scan(&status, &n); err != nil {
+			return nil, fmt.Errorf("scan document counts: %w", err)
+		}
+		counts[status] = n
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("count documents: %w", err)
+	}
+	return counts, nil
+}
+
+// MarkQueuedWhereFailed moves failed documents that failed at or after since
+// back to queued in one statement and returns them, oldest failure first, so
+// the caller can enqueue extraction. A zero since matches every failure, an
+// empty ids matches every document, and a limit of 0 means no limit.
+// Documents whose raw upload was purged cannot be extracted again and are
+// left alone. Rows locked by a concurrent requeue are skipped rather than
+// waited for.
+func (r *DocumentRepository) MarkQueuedWhereFailed(ctx context.Context, since time.Time, ids []string, limit int) ([]RequeuedDocument, error) {
+	var maxRows interface{}
+	if limit > 0 {
+		maxRows = limit
+	}
+	if len(ids) == 0 {
+		ids = nil
+	}
+	rows, err := r.pool.Query(ctx, `
+		UPDATE documents SET status=$1, error_message=NULL, updated_at=$2
+		WHERE id IN (
+			SELECT id FROM documents
+			WHERE status=$3 AND raw_purged_at IS NULL AND updated_at >= $4
+				AND ($6::text[] IS NULL OR id = ANY($6))
+			ORDER BY updated_at LIMIT $5
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, object_key, file_name
+	`, StatusQueued, time.Now().UTC(), StatusFailed, since, maxRows, ids)
+	if err != nil {
+		return nil, fmt.Errorf("requeue failed documents: %w", err)
+	}
+	docs, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (RequeuedDocument, error) {
+		var d RequeuedDocument
+		err := row.Scan(&d.ID, &d.ObjectKey, &d.FileName)
+		return d, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("scan requeued documents: %w", err)
+	}
+	return docs, nil
+}
+
+// FailureGroup is one distinct error message among failed documents.
+type FailureGroup struct {
+	Message      string    `json:"message"`
+	Count        int64     `json:"count"`
+	LastFailedAt time.Time `json:"lastFailedAt"`
+	// DocumentIDs holds the most recent failures with this message.
+	DocumentIDs []string `json:"documentIds"`
+}
+
+// maxFailureGroups bounds FailureSummary; failures beyond the most common
+// messages are better found with List.
+const maxFailureGroups = 100
+
+// FailureSummary groups the documents that failed at or after since by error
+// message, most frequent first, with up to samples ids each. A zero since
+// covers every failure.
+func (r *DocumentRepository) FailureSummary(ctx context.Context, since time.Time, samples int) ([]FailureGroup, error) {
+	rows, err := r.pool.Query(ctx, `
+		SELECT COALESCE(error_message,''), COUNT(*), MAX(updated_at), (array_agg(id ORDER BY updated_at DESC))[1:$3]
+		FROM documents WHERE status=$1 AND updated_at >= $2
+		GROUP BY 1 ORDER BY 2 DESC, 3 DESC LIMIT $4
+	`, StatusFailed, since, samples, maxFailureGroups)
+	if err != nil {
+		return nil, fmt.Errorf("summarize failures: %w", err)
+	}
+	groups, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (FailureGroup, error) {
+		var g FailureGroup
+		err := row.Scan(&g.Message, &g.Count, &g.LastFailedAt, &g.DocumentIDs)
+		return g, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("scan failures: %w", err)
+	}
+	return groups, nil
+}
+
+// DeleteOlderThan deletes, in one statement, every document created before
+// cutoff whose status is one of statuses, and returns the object keys so the
+// caller can remove the objects. Versions, artifacts and attempts cascade.
+// Callers should not pass queued or processing, which would race extraction.
+func (r *DocumentRepository) DeleteOlderThan(ctx context.Context, cutoff time.Time, statuses []DocumentStatus) ([]ExpiredDocument, error) {
+	names := make([]string, len(statuses))
+	for i, s := range statuses {
+		names[i] = string(s)
+	}
+	rows, err := r.pool.Query(ctx, `
+		DELETE FROM documents WHERE created_at < $1 AND status = ANY($2)
+		RETURNING id, object_key, processed_key, archive_key
+	`, cutoff, names)
+	if err != nil {
+		return nil, fmt.Errorf("delete documents: %w", err)
+	}
+	docs, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (ExpiredDocument, error) {
+		var d ExpiredDocument
+		err := row.Scan(&d.ID, &d.ObjectKey, &d.ProcessedKey, &d.ArchiveKey)
+		return d, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("scan deleted documents: %w", err)
+	}
+	return docs, nil
+}
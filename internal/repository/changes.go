@@ -0,0 +1,192 @@
+package repository
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// changeSettle is how old a change must be before Changes returns it. Every
+// write takes a change_seq when its statement runs but becomes visible when
+// it commits, so a write can appear after a later one was already read;
+// holding back recent changes, and everything after them, keeps a cursor
+// from skipping past it. Age is measured on changed_at, which the database
+// sets with change_seq, not on updated_at, which some writes leave alone.
+const changeSettle = 5 * time.Second
+
+// ChangeFilter pages through Changes. Zero values mean no filter.
+type ChangeFilter struct {
+	// OwnerID limits results to one principal's documents.
+	OwnerID string
+	// Since is the Cursor of the previous page; empty starts from the
+	// beginning.
+	Since string
+	// Limit defaults to 50 and is capped at 500.
+	Limit int
+}
+
+// ChangePage is one page of Changes. Content is not loaded.
+type ChangePage struct {
+	Documents []Document `json:"documents"`
+	// Deleted lists documents deleted since the cursor. A document deleted
+	// and then created again under the same id is in Documents only, so
+	// callers apply Deleted first.
+	Deleted []DeletedDocument `json:"deleted"`
+	// Cursor resumes after the last change; it is returned even when the
+	// page is empty, so callers can poll with it.
+	Cursor string `json:"cursor"`
+	// HasMore is true when more changes are ready now.
+	HasMore bool `json:"hasMore"`
+}
+
+// DeletedDocument is a deletion in the changefeed.
+type DeletedDocument struct {
+	ID        string    `json:"id"`
+	DeletedAt time.Time `json:"deletedAt"`
+	// Erased is true when the document was erased for a data subject rather
+	// than removed by retention or a bulk delete.
+	Erased bool `json:"erased"`
+}
+
+// Changes returns documents created, updated or deleted since filter.Since
+// in the order they changed, each document once at its latest state. A
+// document changed again after a page was read comes back on a later page.
+func (r *DocumentRepository) Changes(ctx context.Context, filter ChangeFilter) (*ChangePage, error) {
+	since := int64(0)
+	if filter.Since != "" {
+		var err error
+		if since, err = decodeChangeCursor(filter.Since); err != nil {
+			return nil, err
+		}
+	}
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	if limit > maxListLimit {
+		limit = maxListLimit
+	}
+	page := &ChangePage{Documents: []Document{}, Deleted: []DeletedDocument{}, Cursor: encodeChangeCursor(since)}
+	err := r.read(ctx, func(q querier) error {
+		until, err := settledChangeSeq(ctx, q, since)
+		if err != nil {
+			return err
+		}
+		rows, err := q.Query(ctx, `
+			SELECT id, file_name, object_key, processed_key, status, error_message, drop_id, COALESCE(owner_id,''), raw_purged_at, text_purged_at, archived_at, archive_key, process_at, metadata, tags, extraction, COALESCE(sha256,''), created_at, updated_at, change_seq
+			FROM documents
+			WHERE change_seq > $1 AND change_seq <= $2 AND ($3 = '' OR owner_id = $3)
+			ORDER BY change_seq LIMIT $4
+		`, since, until, filter.OwnerID, limit+1)
+		if err != nil {
+			return fmt.Errorf("list changes: %w", err)
+		}
+		var docSeqs []int64
+		docs, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (Document, error) {
+			var d Document
+			var seq int64
+			err := row.Scan(&d.ID, &d.FileName, &d.ObjectKey, &d.ProcessedKey, &d.Status, &d.ErrorMessage, &d.DropID, &d.OwnerID, &d.RawPurgedAt, &d.TextPurgedAt, &d.ArchivedAt, &d.ArchiveKey, &d.ProcessAt, &d.Metadata, &d.Tags, &d.Extraction, &d.SHA256, &d.CreatedAt, &d.UpdatedAt, &seq)
+			docSeqs = append(docSeqs, seq)
+			return d, err
+		})
+		if err != nil {
+			return fmt.Errorf("scan changes: %w", err)
+		}
+		rows, err = q.Query(ctx, `
+			SELECT d.document_id, d.deleted_at, EXISTS (SELECT 1 FROM document_tombstones t WHERE t.document_id = d.document_id), d.change_seq
+			FROM document_deletions d
+			WHERE d.change_seq > $1 AND d.change_seq <= $2 AND ($3 = '' OR d.owner_id = $3)
+			ORDER BY d.change_seq LIMIT $4
+		`, since, until, filter.OwnerID, limit+1)
+		if err != nil {
+			return fmt.Errorf("list deletions: %w", err)
+		}
+		var delSeqs []int64
+		deleted, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (DeletedDocument, error) {
+			var d DeletedDocument
+			var seq int64
+			err := row.Scan(&d.ID, &d.DeletedAt, &d.Erased, &seq)
+			delSeqs = append(delSeqs, seq)
+			return d, err
+		})
+		if err != nil {
+			return fmt.Errorf("scan deletions: %w", err)
+		}
+		nDocs, nDeleted, last, more := mergeChanges(docSeqs, delSeqs, limit)
+		page.Documents = append(page.Documents[:0], docs[:nDocs]...)
+		page.Deleted = append(page.Deleted[:0], deleted[:nDeleted]...)
+		page.HasMore = more
+		if nDocs+nDeleted > 0 {
+			page.Cursor = encodeChangeCursor(last)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// SettledChangeSeq returns the last change_seq after since that has
+// settled: the newest change when none is younger than changeSettle, by the
+// database clock, and otherwise the one before the first young change. It
+// never returns less than since. Pollers outside the package, like the
+// status hub, page with it the way Changes does.
+func SettledChangeSeq(ctx context.Context, pool *pgxpool.Pool, since int64) (int64, error) {
+	return settledChangeSeq(ctx, pool, since)
+}
+
+func settledChangeSeq(ctx context.Context, q querier, since int64) (int64, error) {
+	var until int64
+	err := q.QueryRow(ctx, `
+		SELECT GREATEST(COALESCE(LEAST(
+			(SELECT MIN(change_seq) FROM documents WHERE change_seq > $1 AND changed_at > clock_timestamp() - $2::interval) - 1,
+			(SELECT MIN(change_seq) FROM document_deletions WHERE change_seq > $1 AND deleted_at > clock_timestamp() - $2::interval) - 1,
+			GREATEST((SELECT MAX(change_seq) FROM documents), (SELECT MAX(change_seq) FROM document_deletions))
+		), $1), $1)
+	`, since, changeSettle).Scan(&until)
+	if err != nil {
+		return 0, fmt.Errorf("settle changes: %w", err)
+	}
+	return until, nil
+}
+
+// mergeChanges interleaves two ascending runs of change_seqs, documents and
+// deletions, and takes up to limit of them. It returns how many of each were
+// taken, the last seq taken and whether any were left over.
+func mergeChanges(docs, deleted []int64, limit int) (nDocs, nDeleted int, last int64, more bool) {
+	for nDocs+nDeleted < limit {
+		switch {
+		case nDocs < len(docs) && (nDeleted == len(deleted) || docs[nDocs] < deleted[nDeleted]):
+			last = docs[nDocs]
+			nDocs++
+		case nDeleted < len(deleted):
+			last = deleted[nDeleted]
+			nDeleted++
+		default:
+			return nDocs, nDeleted, last, false
+		}
+	}
+	return nDocs, nDeleted, last, nDocs < len(docs) || nDeleted < len(deleted)
+}
+
+func encodeChangeCursor(seq int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte("c" + strconv.FormatInt(seq, 10)))
+}
+
+func decodeChangeCursor(cursor string) (int64, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || len(raw) < 2 || raw[0] != 'c' {
+		return 0, ErrInvalidCursor
+	}
+	seq, err := strconv.ParseInt(string(raw[1:]), 10, 64)
+	if err != nil || seq < 0 {
+		return 0, ErrInvalidCursor
+	}
+	return seq, nil
+}
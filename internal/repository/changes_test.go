@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestChangeCursorRoundTrip(t *testing.T) {
+	for _, seq := range []int64{0, 1, 9223372036854775806} {
+		got, err := decodeChangeCursor(encodeChangeCursor(seq))
+		if err != nil || got != seq {
+			t.Errorf("round trip %d: %d %v", seq, got, err)
+		}
+	}
+	listCursor := encodeCursor(time.Date(2024, 3, 1, 10, 30, 0, 0, time.UTC), "doc-1")
+	for _, bad := range []string{"not base64!", "", "Yw", "Yy0x", listCursor} {
+		if _, err := decodeChangeCursor(bad); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("decodeChangeCursor(%q) = %v, want ErrInvalidCursor", bad, err)
+		}
+	}
+}
+
+func TestMergeChanges(t *testing.T) {
+	for _, tc := range []struct {
+		name            string
+		docs, deleted   []int64
+		limit           int
+		nDocs, nDeleted int
+		last            int64
+		more            bool
+	}{
+		{"empty", nil, nil, 3, 0, 0, 0, false},
+		{"documents only", []int64{4, 7}, nil, 3, 2, 0, 7, false},
+		{"interleaved", []int64{2, 5, 9}, []int64{3, 4}, 4, 2, 2, 5, true},
+		{"deletions first", []int64{8}, []int64{1, 2}, 2, 0, 2, 2, true},
+		{"exactly the limit", []int64{1}, []int64{2}, 2, 1, 1, 2, false},
+	} {
+		nDocs, nDeleted, last, more := mergeChanges(tc.docs, tc.deleted, tc.limit)
+		if nDocs != tc.nDocs || nDeleted != tc.nDeleted || last != tc.last || more != tc.more {
+			t.Errorf("%s: got (%d, %d, %d, %v), want (%d, %d, %d, %v)", tc.name, nDocs, nDeleted, last, more, tc.nDocs, tc.nDeleted, tc.last, tc.more)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/keys"
+)
+
+// DataKey returns the wrapped key the document's raw upload is encrypted
+// with, or nil when it is not encrypted. It reads the primary, since the
+// worker asks right after the upload.
+func (r *DocumentRepository) DataKey(ctx context.Context, id string) (*keys.Wrapped, error) {
+	var (
+		key   []byte
+		keyID *string
+	)
+	err := r.pool.QueryRow(ctx, `SELECT data_key, data_key_id FROM documents WHERE id=$1`, id).Scan(&key, &keyID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("select data key: %w", err)
+	}
+	if keyID == nil {
+		return nil, nil
+	}
+	return &keys.Wrapped{KeyID: *keyID, Key: key}, nil
+}
+
+// WrappedDataKey is a document's data key as stored.
+type WrappedDataKey struct {
+	DocumentID string
+	Key        keys.Wrapped
+}
+
+// ListStaleDataKeys returns, in id order after the given id, up to limit data
+// keys wrapped under a master key other than current.
+func (r *DocumentRepository) ListStaleDataKeys(ctx context.Context, current, after string, limit int) ([]WrappedDataKey, error) {
+	rows, err := r.pool.Query(ctx, `
+		SELECT id, data_key_id, data_key FROM documents
+		WHERE data_key_id IS NOT NULL AND data_key_id <> $1 AND id > $2
+		ORDER BY id LIMIT $3
+	`, current, after, limit)
+	if err != nil {
+		return nil, fmt.Errorf("select stale data keys: %w", err)
+	}
+	out, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (WrappedDataKey, error) {
+		var k WrappedDataKey
+		err := row.Scan(&k.DocumentID, &k.Key.KeyID, &k.Key.Key)
+		return k, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("scan stale data keys: %w", err)
+	}
+	return out, nil
+}
+
+// ReplaceDataKey swaps the document's wrapped data key from old to next. It
+// returns false, and changes nothing, when the stored key is no longer old,
+// e.g. because another rewrap run got there first.
+func (r *DocumentRepository) ReplaceDataKey(ctx context.Context, id string, old, next keys.Wrapped) (bool, error) {
+	tag, err := r.pool.Exec(ctx, `
+		UPDATE documents SET data_key=$4, data_key_id=$5
+		WHERE id=$1 AND data_key_id=$2 AND data_key=$3
+	`, id, old.KeyID, old.Key, next.Key, next.KeyID)
+	if err != nil {
+		return false, fmt.Errorf("replace data key: %w", err)
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// CountDataKeys returns how many documents have a data key wrapped under each
+// master key, so an operator can tell when a retired key is no longer used.
+func (r *DocumentRepository) CountDataKeys(ctx context.Context) (map[string]int, error) {
+	rows, err := r.pool.Query(ctx, `SELECT data_key_id, COUNT(*) FROM documents WHERE data_key_id IS NOT NULL GROUP BY data_key_id`)
+	if err != nil {
+		return nil, fmt.Errorf("count data keys: %w", err)
+	}
+	defer rows.Close()
+	counts := make(map[string]int)
+	for rows.Next() {
+		var (
+			id string
+			n  int
+		)
+		if err := rows.Scan(&id, &n); err != nil {
+			return nil, fmt.Errorf("scan data key counts: %w", err)
+		}
+		counts[id] = n
+	}
+	return counts, rows.Err()
+}
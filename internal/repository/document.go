@@ -5,10 +5,14 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/keys"
 )
 
 // DocumentStatus enumerates the lifecycle of a PDF during processing.
@@ -19,6 +23,29 @@ const (
 	StatusProcessing DocumentStatus = "processing"
 	StatusCompleted  DocumentStatus = "completed"
 	StatusFailed     DocumentStatus = "failed"
+	// StatusCancelled is terminal: the owner stopped processing and the
+	// worker leaves the document alone from then on.
+	StatusCancelled DocumentStatus = "cancelled"
+)
+
+var (
+	// ErrNotFound is returned when a document row does not exist.
+	ErrNotFound = errors.New("document not found")
+	// ErrCancelled is returned by worker status updates on a document that
+	// was cancelled.
+	ErrCancelled = errors.New("document cancelled")
+	// ErrExists is returned by Create for an id or object key that is
+	// already taken.
+	ErrExists = errors.New("document already exists")
+	// ErrNotCancellable is returned by MarkCancelled once processing has
+	// already finished.
+	ErrNotCancellable = errors.New("document is not queued or processing")
+	// ErrNotReprocessable is returned by MarkQueuedForReprocess while the
+	// document is still queued or processing.
+	ErrNotReprocessable = errors.New("document is still queued or processing")
+	// ErrRawPurged is returned by MarkQueuedForReprocess once retention
+	// removed the raw upload, leaving nothing to extract from.
+	ErrRawPurged = errors.New("raw upload purged by retention")
 )
 
 // Document represents a row in the documents table.
@@ -30,13 +57,59 @@ type Document struct {
 	Status        DocumentStatus `json:"status"`
 	Content       string         `json:"content,omitempty"`
 	ErrorMessage  *string        `json:"errorMessage,omitempty"`
+	DropID        *string        `json:"dropId,omitempty"`
+	// OwnerID is the principal the document belongs to; empty for documents
+	// uploaded before ownership was tracked.
+	OwnerID       string         `json:"ownerId,omitempty"`
+	// RawPurgedAt and TextPurgedAt are set once retention removed the raw
+	// upload or the extracted text while keeping the rest of the document.
+	RawPurgedAt   *time.Time     `json:"rawPurgedAt,omitempty"`
+	TextPurgedAt  *time.Time     `json:"textPurgedAt,omitempty"`
+	// ArchivedAt is set once the archive sweep moved the extracted text out
+	// of the content column into the object at ArchiveKey; Content is then
+	// empty until the caller loads it from there.
+	ArchivedAt    *time.Time     `json:"archivedAt,omitempty"`
+	ArchiveKey    *string        `json:"archiveKey,omitempty"`
+	// ProcessAt is when a deferred upload becomes eligible for extraction;
+	// nil for documents queued to run right away.
+	ProcessAt     *time.Time     `json:"processAt,omitempty"`
+	// Metadata holds the client's key/value pairs from the upload.
+	Metadata      map[string]string `json:"metadata,omitempty"`
+	// Tags are labels set through PATCH /documents/{id}, kept sorted.
+	Tags          []string       `json:"tags,omitempty"`
+	// Extraction measures the text of the last completed extraction; nil
+	// for documents completed before it was recorded.
+	Extraction    *ExtractionStats `json:"extraction,omitempty"`
+	// SHA256 is the hex digest of the raw upload as received, which the
+	// worker checks the stored object against; empty when it was not
+	// uploaded through the API.
+	SHA256        string         `json:"sha256,omitempty"`
+	// DataKey is the wrapped key the raw upload is encrypted with, nil when
+	// it is not encrypted. Create stores it; reads leave it nil, use DataKey.
+	DataKey       *keys.Wrapped  `json:"-"`
+	// Analytics counts downloads and signed URLs. GET /documents/{id} fills
+	// it in; reads leave it nil, use AccessTotals.
+	Analytics     *AccessTotals  `json:"analytics,omitempty"`
 	CreatedAt     time.Time      `json:"createdAt"`
 	UpdatedAt     time.Time      `json:"updatedAt"`
 }
 
+// ExtractionStats describe what an extraction produced, so documents that
+// completed with little or no usable text stand out.
+type ExtractionStats struct {
+	Pages int `json:"pages"`
+	// Characters counts the non-whitespace characters extracted.
+	Characters int `json:"characters"`
+	// Coverage is the share of pages that yielded text, from 0 to 1. Scanned
+	// pages without a text layer bring it down.
+	Coverage   float64 `json:"coverage"`
+	DurationMS int64   `json:"durationMs"`
+}
+
 // DocumentRepository wraps all SQL used throughout the API and worker.
 type DocumentRepository struct {
-	pool *pgxpool.Pool
+	pool    *pgxpool.Pool
+	replica *replica
 }
 
 // NewDocumentRepository constructs a repository.
@@ -44,16 +117,33 @@ func NewDocumentRepository(pool *pgxpool.Pool) *DocumentRepository {
 	return &DocumentRepository{pool: pool}
 }
 
+// uniqueViolation is the Postgres error code for a duplicate key.
+const uniqueViolation = "23505"
+
 // Create inserts a queued document before processing begins.
 func (r *DocumentRepository) Create(ctx context.Context, doc *Document) error {
 	now := time.Now().UTC()
 	doc.Status = StatusQueued
+	if doc.Metadata == nil {
+		doc.Metadata = map[string]string{}
+	}
 	doc.CreatedAt = now
 	doc.UpdatedAt = now
+	var (
+		dataKey   []byte
+		dataKeyID *string
+	)
+	if doc.DataKey != nil {
+		dataKey, dataKeyID = doc.DataKey.Key, &doc.DataKey.KeyID
+	}
 	_, err := r.pool.Exec(ctx, `
-		INSERT INTO documents (id, file_name, object_key, status, content, error_message, created_at, updated_at)
-		VALUES ($1,$2,$3,$4,$5,$6,$7,$8)
-	`, doc.ID, doc.FileName, doc.ObjectKey, doc.Status, "", nil, doc.CreatedAt, doc.UpdatedAt)
+		INSERT INTO documents (id, file_name, object_key, status, content, error_message, drop_id, owner_id, process_at, metadata, sha256, data_key, data_key_id, created_at, updated_at)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,NULLIF($8,''),$9,$10,NULLIF($11,''),$12,$13,$14,$15)
+	`, doc.ID, doc.FileName, doc.ObjectKey, doc.Status, "", nil, doc.DropID, doc.OwnerID, doc.ProcessAt, doc.Metadata, doc.SHA256, dataKey, dataKeyID, doc.CreatedAt, doc.UpdatedAt)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+		return ErrExists
+	}
 	if err != nil {
 		return fmt.Errorf("insert document: %w", err)
 	}
@@ -62,18 +152,52 @@ func (r *DocumentRepository) Create(ctx context.Context, doc *Document) error {
 
 // Get returns a document by id.
 func (r *DocumentRepository) Get(ctx context.Context, id string) (*Document, error) {
+	return r.getReplicated(ctx, id, "")
+}
+
+// GetForOwner is Get limited to documents owned by owner; other documents
+// report ErrNotFound so callers cannot probe for ids. An empty owner is not a
+// restriction.
+func (r *DocumentRepository) GetForOwner(ctx context.Context, id, owner string) (*Document, error) {
+	return r.getReplicated(ctx, id, owner)
+}
+
+// getReplicated is get on the read replica, if any. A document the replica
+// does not have yet is looked up on the primary.
+func (r *DocumentRepository) getReplicated(ctx context.Context, id, owner string) (*Document, error) {
+	var (
+		doc     *Document
+		primary bool
+	)
+	err := r.read(ctx, func(q querier) (err error) {
+		primary = q == querier(r.pool)
+		doc, err = r.getFrom(ctx, q, id, owner)
+		return err
+	})
+	if errors.Is(err, ErrNotFound) && !primary {
+		return r.get(ctx, id, owner)
+	}
+	return doc, err
+}
+
+func (r *DocumentRepository) get(ctx context.Context, id, owner string) (*Document, error) {
+	return r.getFrom(ctx, r.pool, id, owner)
+}
+
+func (r *DocumentRepository) getFrom(ctx context.Context, q querier, id, owner string) (*Document, error) {
 	var (
 		doc          Document
 		processedKey sql.NullString
 		errorMsg     sql.NullString
+		dropID       sql.NullString
 	)
-	row := r.pool.QueryRow(ctx, `
-		SELECT id, file_name, object_key, processed_key, status, COALESCE(content,''), error_message, created_at, updated_at
-		FROM documents WHERE id=$1
-	`, id)
-	if err := row.Scan(&doc.ID, &doc.FileName, &doc.ObjectKey, &processedKey, &doc.Status, &doc.Content, &errorMsg, &doc.CreatedAt, &doc.UpdatedAt); err != nil {
+	row := q.QueryRow(ctx, `
+		SELECT id, file_name, object_key, processed_key, status, COALESCE(content,''), error_message, drop_id, COALESCE(owner_id,''), raw_purged_at, text_purged_at, archived_at, archive_key, process_at, metadata, tags, extraction, COALESCE(sha256,''), created_at, updated_at
+		FROM documents WHERE id=$1 AND ($2 = '' OR owner_id = $2)
+	`, id, owner)
+	if err := row.Scan(&doc.ID, &doc.FileName, &doc.ObjectKey, &processedKey, &doc.Status, &doc.Content, &errorMsg, &dropID, &doc.OwnerID, &doc.RawPurgedAt, &doc.TextPurgedAt, &doc.ArchivedAt, &doc.ArchiveKey, &doc.ProcessAt, &doc.Metadata, &doc.Tags, &doc.Extraction, &doc.SHA256, &doc.CreatedAt, &doc.UpdatedAt); err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			return nil, fmt.Errorf("document not found: %w", err)
+			return nil, ErrNotFound
 		}
 		return nil, fmt.Errorf("select document: %w", err)
 	}
@@ -85,37 +209,263 @@ func (r *DocumentRepository) Get(ctx context.Context, id string) (*Document, err
 		msg := errorMsg.String
 		doc.ErrorMessage = &msg
 	}
+	if dropID.Valid {
+		drop := dropID.String
+		doc.DropID = &drop
+	}
 	return &doc, nil
 }
 
-// MarkProcessing sets the status to processing.
+// NotOwned returns the ids in ids that are not documents owned by owner,
+// including ids that do not exist.
+func (r *DocumentRepository) NotOwned(ctx context.Context, ids []string, owner string) ([]string, error) {
+	rows, err := r.pool.Query(ctx, `SELECT id FROM documents WHERE id = ANY($1) AND owner_id = $2`, ids, owner)
+	if err != nil {
+		return nil, fmt.Errorf("select owned documents: %w", err)
+	}
+	owned, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("scan owned documents: %w", err)
+	}
+	seen := make(map[string]bool, len(owned))
+	for _, id := range owned {
+		seen[id] = true
+	}
+	var missing []string
+	for _, id := range ids {
+		if !seen[id] {
+			missing = append(missing, id)
+		}
+	}
+	return missing, nil
+}
+
+// GetByObjectKey returns the document whose raw upload is stored under key.
+func (r *DocumentRepository) GetByObjectKey(ctx context.Context, key string) (*Document, error) {
+	var id string
+	err := r.pool.QueryRow(ctx, `SELECT id FROM documents WHERE object_key=$1`, key).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("select document by key: %w", err)
+	}
+	return r.get(ctx, id, "")
+}
+
+// DocumentUpdate lists the client-editable fields PATCH changes; nil fields
+// are left alone. Tags and Metadata replace the stored values as a whole.
+type DocumentUpdate struct {
+	FileName *string
+	Tags     []string
+	Metadata map[string]string
+}
+
+// Update applies u to the document if owner owns it (an empty owner is not a
+// restriction), bumps updated_at, and returns the updated document.
+func (r *DocumentRepository) Update(ctx context.Context, id, owner string, u DocumentUpdate) (*Document, error) {
+	args := []interface{}{id, owner, time.Now().UTC()}
+	set := []string{"updated_at=$3"}
+	add := func(column string, arg interface{}) {
+		args = append(args, arg)
+		set = append(set, fmt.Sprintf("%s=$%d", column, len(args)))
+	}
+	if u.FileName != nil {
+		add("file_name", *u.FileName)
+	}
+	if u.Tags != nil {
+		add("tags", u.Tags)
+	}
+	if u.Metadata != nil {
+		add("metadata", u.Metadata)
+	}
+	tag, err := r.pool.Exec(ctx, `
+		UPDATE documents SET `+strings.Join(set, ", ")+`
+		WHERE id=$1 AND ($2 = '' OR owner_id = $2)
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("update document: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return nil, ErrNotFound
+	}
+	return r.get(ctx, id, owner)
+}
+
+// MarkProcessing sets the status to processing. It returns ErrCancelled for
+// a cancelled document.
 func (r *DocumentRepository) MarkProcessing(ctx context.Context, id string) error {
 	return r.updateStatus(ctx, id, StatusProcessing, nil, nil, nil)
 }
 
 // MarkFailed marks the processing attempt as failed and stores the message.
+// A cancelled document keeps its status and ErrCancelled is returned.
 func (r *DocumentRepository) MarkFailed(ctx context.Context, id string, msg string) error {
 	return r.updateStatus(ctx, id, StatusFailed, nil, nil, &msg)
 }
 
-// MarkCompleted updates the status and stores the processed artifact references.
-func (r *DocumentRepository) MarkCompleted(ctx context.Context, id, processedKey, content string) error {
-	return r.updateStatus(ctx, id, StatusCompleted, &processedKey, &content, nil)
+// MarkCancelled cancels a queued or processing document. It returns
+// ErrNotCancellable when the document already finished and ErrNotFound when
+// it does not exist.
+func (r *DocumentRepository) MarkCancelled(ctx context.Context, id string) error {
+	tag, err := r.pool.Exec(ctx, `
+		UPDATE documents SET status=$1, updated_at=$2
+		WHERE id=$3 AND status = ANY($4)
+	`, StatusCancelled, time.Now().UTC(), id, []string{string(StatusQueued), string(StatusProcessing)})
+	if err != nil {
+		return fmt.Errorf("cancel document: %w", err)
+	}
+	if tag.RowsAffected() == 1 {
+		return nil
+	}
+	if _, err := r.Get(ctx, id); err != nil {
+		return err
+	}
+	return ErrNotCancellable
+}
+
+// MarkQueuedForReprocess moves a completed, failed or cancelled document back
+// to queued so extraction can run again, with pipeline (empty for the
+// default). Its text and versions stay until the new run completes. It
+// returns ErrNotReprocessable while the document is queued or processing,
+// ErrRawPurged when the raw upload is gone, and ErrNotFound when it does not
+// exist.
+func (r *DocumentRepository) MarkQueuedForReprocess(ctx context.Context, id, pipeline string) error {
+	tag, err := r.pool.Exec(ctx, `
+		UPDATE documents SET status=$1, error_message=NULL, process_at=NULL, pipeline=NULLIF($5,''), updated_at=$2
+		WHERE id=$3 AND status = ANY($4) AND raw_purged_at IS NULL
+	`, StatusQueued, time.Now().UTC(), id, []string{string(StatusCompleted), string(StatusFailed), string(StatusCancelled)}, pipeline)
+	if err != nil {
+		return fmt.Errorf("requeue document: %w", err)
+	}
+	if tag.RowsAffected() == 1 {
+		return nil
+	}
+	doc, err := r.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if doc.RawPurgedAt != nil {
+		return ErrRawPurged
+	}
+	return ErrNotReprocessable
+}
+
+// ExtractSource is what an extract task needs of its document.
+type ExtractSource struct {
+	DocumentID string
+	ObjectKey  string
+	FileName   string
+	// Pipeline is the one the last reprocess asked for; empty is the
+	// default.
+	Pipeline string
 }
 
+// ExtractSource reads the document an extract task works on from the
+// primary, since the worker asks right after the upload or reprocess.
+func (r *DocumentRepository) ExtractSource(ctx context.Context, id string) (*ExtractSource, error) {
+	src := ExtractSource{DocumentID: id}
+	err := r.pool.QueryRow(ctx, `SELECT object_key, file_name, COALESCE(pipeline,'') FROM documents WHERE id=$1`, id).Scan(&src.ObjectKey, &src.FileName, &src.Pipeline)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("select extract source: %w", err)
+	}
+	return &src, nil
+}
+
+// IsCancelled reports whether the document was cancelled; the worker checks
+// it between stages.
+func (r *DocumentRepository) IsCancelled(ctx context.Context, id string) (bool, error) {
+	var cancelled bool
+	err := r.pool.QueryRow(ctx, `SELECT status = $2 FROM documents WHERE id=$1`, id, StatusCancelled).Scan(&cancelled)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, ErrNotFound
+	}
+	if err != nil {
+		return false, fmt.Errorf("select document status: %w", err)
+	}
+	return cancelled, nil
+}
+
+// RawChecksum returns the SHA-256 the raw upload was received with, or ""
+// when none was recorded.
+func (r *DocumentRepository) RawChecksum(ctx context.Context, id string) (string, error) {
+	var sum string
+	err := r.pool.QueryRow(ctx, `SELECT COALESCE(sha256,'') FROM documents WHERE id=$1`, id).Scan(&sum)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("select document checksum: %w", err)
+	}
+	return sum, nil
+}
+
+// MarkCompleted updates the status, stores the processed artifact references,
+// and records the text as a new version when it changed. It returns
+// ErrCancelled, and changes nothing, for a cancelled document.
+func (r *DocumentRepository) MarkCompleted(ctx context.Context, id, processedKey, content string, stats ExtractionStats) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin complete: %w", err)
+	}
+	defer tx.Rollback(ctx)
+	now := time.Now().UTC()
+	tag, err := tx.Exec(ctx, `
+		UPDATE documents
+		SET status=$1, processed_key=$2, content=$3, extraction=$4, error_message=NULL, text_purged_at=NULL, archived_at=NULL, updated_at=$5
+		WHERE id=$6 AND status <> $7
+	`, StatusCompleted, processedKey, content, stats, now, id, StatusCancelled)
+	if err != nil {
+		return fmt.Errorf("update document: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return r.missingOrCancelled(ctx, id)
+	}
+	if err := recordVersion(ctx, tx, id, content, now); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit complete: %w", err)
+	}
+	return nil
+}
+
+// updateStatus never touches a cancelled document; it reports ErrCancelled
+// instead so the worker can stop.
 func (r *DocumentRepository) updateStatus(ctx context.Context, id string, status DocumentStatus, processedKey *string, content *string, errorMsg *string) error {
 	now := time.Now().UTC()
-	_, err := r.pool.Exec(ctx, `
+	tag, err := r.pool.Exec(ctx, `
 		UPDATE documents
 		SET status=$1,
 			processed_key = COALESCE($2, processed_key),
 			content = COALESCE($3, content),
 			error_message = $4,
 			updated_at=$5
-		WHERE id=$6
-	`, status, processedKey, content, errorMsg, now, id)
+		WHERE id=$6 AND status <> $7
+	`, status, processedKey, content, errorMsg, now, id, StatusCancelled)
 	if err != nil {
 		return fmt.Errorf("update document: %w", err)
 	}
+	if tag.RowsAffected() == 0 {
+		if err := r.missingOrCancelled(ctx, id); !errors.Is(err, ErrNotFound) {
+			return err
+		}
+	}
 	return nil
 }
+
+// missingOrCancelled explains an update that matched no row: ErrCancelled when
+// the document was cancelled, ErrNotFound otherwise.
+func (r *DocumentRepository) missingOrCancelled(ctx context.Context, id string) error {
+	cancelled, err := r.IsCancelled(ctx, id)
+	if err != nil {
+		return err
+	}
+	if cancelled {
+		return ErrCancelled
+	}
+	return ErrNotFound
+}
@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	// ErrDropNotFound is returned when a drop link does not exist.
+	ErrDropNotFound = errors.New("drop not found")
+	// ErrDropUnavailable is returned when a drop link is expired or used up.
+	ErrDropUnavailable = errors.New("drop expired or upload limit reached")
+)
+
+// Drop is an upload link minted by an authenticated principal that lets
+// outsiders submit files without an account. Only the SHA-256 of the link
+// token is stored.
+type Drop struct {
+	ID          string    `json:"id"`
+	OwnerID     string    `json:"ownerId"`
+	Label       string    `json:"label,omitempty"`
+	MaxUploads  int       `json:"maxUploads"`
+	UploadCount int       `json:"uploadCount"`
+	ExpiresAt   time.Time `json:"expiresAt"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+// HashDropToken returns the digest stored in place of the drop link token.
+func HashDropToken(token string) string {
+	return sha256Hex(token)
+}
+
+// CreateDrop inserts a drop link.
+func (r *DocumentRepository) CreateDrop(ctx context.Context, drop *Drop, tokenHash string) error {
+	drop.CreatedAt = time.Now().UTC()
+	_, err := r.pool.Exec(ctx, `
+		INSERT INTO drops (id, token_hash, owner_id, label, max_uploads, upload_count, expires_at, created_at)
+		VALUES ($1,$2,$3,$4,$5,0,$6,$7)
+	`, drop.ID, tokenHash, drop.OwnerID, drop.Label, drop.MaxUploads, drop.ExpiresAt, drop.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("insert drop: %w", err)
+	}
+	return nil
+}
+
+// GetDrop returns a drop by id.
+func (r *DocumentRepository) GetDrop(ctx context.Context, id string) (*Drop, error) {
+	var drop Drop
+	row := r.pool.QueryRow(ctx, `
+		SELECT id, owner_id, label, max_uploads, upload_count, expires_at, created_at
+		FROM drops WHERE id=$1
+	`, id)
+	if err := row.Scan(&drop.ID, &drop.OwnerID, &drop.Label, &drop.MaxUploads, &drop.UploadCount, &drop.ExpiresAt, &drop.CreatedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrDropNotFound
+		}
+		return nil, fmt.Errorf("select drop: %w", err)
+	}
+	return &drop, nil
+}
+
+// ClaimDropSlot atomically reserves one upload on the drop identified by the
+// token hash. Callers must ReleaseDropSlot if the upload does not complete.
+func (r *DocumentRepository) ClaimDropSlot(ctx context.Context, tokenHash string) (*Drop, error) {
+	var drop Drop
+	row := r.pool.QueryRow(ctx, `
+		UPDATE drops SET upload_count = upload_count + 1
+		WHERE token_hash=$1 AND upload_count < max_uploads AND expires_at > $2
+		RETURNING id, owner_id, label, max_uploads, upload_count, expires_at, created_at
+	`, tokenHash, time.Now().UTC())
+	if err := row.Scan(&drop.ID, &drop.OwnerID, &drop.Label, &drop.MaxUploads, &drop.UploadCount, &drop.ExpiresAt, &drop.CreatedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrDropUnavailable
+		}
+		return nil, fmt.Errorf("claim drop slot: %w", err)
+	}
+	return &drop, nil
+}
+
+// ReleaseDropSlot returns a reserved upload slot after a failed upload,
+// unless the upload got as far as inserting documentID, which keeps it.
+func (r *DocumentRepository) ReleaseDropSlot(ctx context.Context, id, documentID string) error {
+	_, err := r.pool.Exec(ctx, `
+		UPDATE drops SET upload_count = upload_count - 1
+		WHERE id=$1 AND upload_count > 0 AND NOT EXISTS (SELECT 1 FROM documents WHERE id=$2)
+	`, id, documentID)
+	if err != nil {
+		return fmt.Errorf("release drop slot: %w", err)
+	}
+	return nil
+}
+
+// ListDropDocumentIDs returns the documents received through a drop, oldest first.
+func (r *DocumentRepository) ListDropDocumentIDs(ctx context.Context, dropID string) ([]string, error) {
+	rows, err := r.pool.Query(ctx, `
+		SELECT id FROM documents WHERE drop_id=$1 ORDER BY created_at
+	`, dropID)
+	if err != nil {
+		return nil, fmt.Errorf("select drop documents: %w", err)
+	}
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("scan drop documents: %w", err)
+	}
+	return ids, nil
+}
@@ -0,0 +1,311 @@
+package repository
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ErasureStatus tracks the lifecycle of a right-to-be-forgotten request.
+type ErasureStatus string
+
+const (
+	ErasurePending   ErasureStatus = "pending"
+	ErasureRunning   ErasureStatus = "running"
+	ErasureCompleted ErasureStatus = "completed"
+	ErasureFailed    ErasureStatus = "failed"
+)
+
+// ErrErasureNotFound is returned when an erasure request does not exist.
+var ErrErasureNotFound = errors.New("erasure request not found")
+
+// ErasureRequest represents a row in the erasure_requests table. The subject is
+// only persisted as a SHA-256 digest so the request itself holds no PII.
+type ErasureRequest struct {
+	ID          string   `json:"id"`
+	SubjectHash string   `json:"subjectHash"`
+	DocumentIDs []string `json:"documentIds"`
+	// OwnerID, when set, erases everything the principal owns: the worker
+	// fills DocumentIDs with its documents when the request runs.
+	OwnerID      string           `json:"ownerId,omitempty"`
+	Status       ErasureStatus    `json:"status"`
+	Progress     *ErasureProgress `json:"progress,omitempty"`
+	Report       *ErasureReport   `json:"report,omitempty"`
+	ErrorMessage *string          `json:"errorMessage,omitempty"`
+	RequestedAt  time.Time        `json:"requestedAt"`
+	CompletedAt  *time.Time       `json:"completedAt,omitempty"`
+}
+
+// ErasureProgress counts the documents a running request has handled. Total
+// is unknown, and Progress nil, until an owner's documents are listed.
+type ErasureProgress struct {
+	Done  int `json:"done"`
+	Total int `json:"total"`
+}
+
+// ErasureReport is the completion report returned to the requester. Every
+// item is re-checked after deletion so Verified reflects the final state of
+// the database and object storage rather than the success of the delete calls.
+type ErasureReport struct {
+	Items    []ErasureItem `json:"items"`
+	Erased   int           `json:"erased"`
+	Missing  int           `json:"missing"`
+	Verified bool          `json:"verified"`
+	// RejectionsDeleted and DropsDeleted count the refused-upload records
+	// and upload links removed by an owner erasure.
+	RejectionsDeleted int64 `json:"rejectionsDeleted,omitempty"`
+	DropsDeleted      int64 `json:"dropsDeleted,omitempty"`
+	// ExportsDeleted counts the exports of erased documents removed with
+	// their ZIPs.
+	ExportsDeleted int `json:"exportsDeleted,omitempty"`
+}
+
+// ErasureItem records what was removed for a single document.
+type ErasureItem struct {
+	DocumentID       string `json:"documentId"`
+	Found            bool   `json:"found"`
+	RawDeleted       bool   `json:"rawDeleted"`
+	ProcessedDeleted bool   `json:"processedDeleted"`
+	RowDeleted       bool   `json:"rowDeleted"`
+	Verified         bool   `json:"verified"`
+	// ErasedEarlier is set when an earlier attempt of the same request
+	// already erased the document, so a retry does not report it missing.
+	ErasedEarlier bool `json:"erasedEarlier,omitempty"`
+}
+
+// HashSubject returns the digest stored in place of the raw subject identifier.
+func HashSubject(subject string) string {
+	return sha256Hex(strings.TrimSpace(subject))
+}
+
+func sha256Hex(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateErasure inserts a pending erasure request.
+func (r *DocumentRepository) CreateErasure(ctx context.Context, req *ErasureRequest) error {
+	req.Status = ErasurePending
+	req.RequestedAt = time.Now().UTC()
+	if req.DocumentIDs == nil {
+		req.DocumentIDs = []string{}
+	}
+	var total *int
+	if req.OwnerID == "" {
+		n := len(req.DocumentIDs)
+		total = &n
+		req.Progress = &ErasureProgress{Total: n}
+	}
+	_, err := r.pool.Exec(ctx, `
+		INSERT INTO erasure_requests (id, subject_hash, document_ids, owner_id, total, status, requested_at)
+		VALUES ($1,$2,$3,NULLIF($4,''),$5,$6,$7)
+	`, req.ID, req.SubjectHash, req.DocumentIDs, req.OwnerID, total, req.Status, req.RequestedAt)
+	if err != nil {
+		return fmt.Errorf("insert erasure request: %w", err)
+	}
+	return nil
+}
+
+// GetErasure returns an erasure request by id.
+func (r *DocumentRepository) GetErasure(ctx context.Context, id string) (*ErasureRequest, error) {
+	var (
+		req         ErasureRequest
+		report      []byte
+		errorMsg    sql.NullString
+		completedAt sql.NullTime
+		total       *int
+		done        int
+	)
+	row := r.pool.QueryRow(ctx, `
+		SELECT id, subject_hash, document_ids, COALESCE(owner_id,''), total, done, status, report, error_message, requested_at, completed_at
+		FROM erasure_requests WHERE id=$1
+	`, id)
+	if err := row.Scan(&req.ID, &req.SubjectHash, &req.DocumentIDs, &req.OwnerID, &total, &done, &req.Status, &report, &errorMsg, &req.RequestedAt, &completedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrErasureNotFound
+		}
+		return nil, fmt.Errorf("select erasure request: %w", err)
+	}
+	if total != nil {
+		req.Progress = &ErasureProgress{Done: done, Total: *total}
+	}
+	if len(report) > 0 {
+		req.Report = &ErasureReport{}
+		if err := json.Unmarshal(report, req.Report); err != nil {
+			return nil, fmt.Errorf("decode erasure report: %w", err)
+		}
+	}
+	if errorMsg.Valid {
+		msg := errorMsg.String
+		req.ErrorMessage = &msg
+	}
+	if completedAt.Valid {
+		t := completedAt.Time
+		req.CompletedAt = &t
+	}
+	return &req, nil
+}
+
+// MarkErasureRunning flags the request as in progress.
+func (r *DocumentRepository) MarkErasureRunning(ctx context.Context, id string) error {
+	_, err := r.pool.Exec(ctx, `
+		UPDATE erasure_requests SET status=$1, error_message=NULL WHERE id=$2
+	`, ErasureRunning, id)
+	if err != nil {
+		return fmt.Errorf("update erasure request: %w", err)
+	}
+	return nil
+}
+
+// SetErasureDocuments records the documents a request erases once they are
+// known, and restarts its progress count.
+func (r *DocumentRepository) SetErasureDocuments(ctx context.Context, id string, ids []string) error {
+	_, err := r.pool.Exec(ctx, `
+		UPDATE erasure_requests SET document_ids=$1, total=$2, done=0 WHERE id=$3
+	`, ids, len(ids), id)
+	if err != nil {
+		return fmt.Errorf("update erasure request: %w", err)
+	}
+	return nil
+}
+
+// ReportErasureProgress records how many of the request's documents are done.
+func (r *DocumentRepository) ReportErasureProgress(ctx context.Context, id string, done int) error {
+	_, err := r.pool.Exec(ctx, `UPDATE erasure_requests SET done=$1 WHERE id=$2`, done, id)
+	if err != nil {
+		return fmt.Errorf("update erasure progress: %w", err)
+	}
+	return nil
+}
+
+// ListOwnerDocumentIDs returns the ids of every document owner owns.
+func (r *DocumentRepository) ListOwnerDocumentIDs(ctx context.Context, owner string) ([]string, error) {
+	rows, err := r.pool.Query(ctx, `SELECT id FROM documents WHERE owner_id=$1 ORDER BY id`, owner)
+	if err != nil {
+		return nil, fmt.Errorf("select owner documents: %w", err)
+	}
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("scan owner documents: %w", err)
+	}
+	return ids, nil
+}
+
+// EraseOwnerRecords deletes what else is kept about owner besides documents:
+// the refused uploads recorded with the principal, and its upload links.
+func (r *DocumentRepository) EraseOwnerRecords(ctx context.Context, owner string) (rejections, drops int64, err error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return 0, 0, fmt.Errorf("begin owner erase: %w", err)
+	}
+	defer tx.Rollback(ctx)
+	tag, err := tx.Exec(ctx, `DELETE FROM upload_rejections WHERE principal=$1`, owner)
+	if err != nil {
+		return 0, 0, fmt.Errorf("delete rejections: %w", err)
+	}
+	rejections = tag.RowsAffected()
+	if tag, err = tx.Exec(ctx, `DELETE FROM drops WHERE owner_id=$1`, owner); err != nil {
+		return 0, 0, fmt.Errorf("delete drops: %w", err)
+	}
+	drops = tag.RowsAffected()
+	if err := tx.Commit(ctx); err != nil {
+		return 0, 0, fmt.Errorf("commit owner erase: %w", err)
+	}
+	return rejections, drops, nil
+}
+
+// CompleteErasure stores the final report.
+func (r *DocumentRepository) CompleteErasure(ctx context.Context, id string, report *ErasureReport) error {
+	data, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("encode erasure report: %w", err)
+	}
+	_, err = r.pool.Exec(ctx, `
+		UPDATE erasure_requests SET status=$1, report=$2, error_message=NULL, completed_at=$3, done=COALESCE(total, done) WHERE id=$4
+	`, ErasureCompleted, data, time.Now().UTC(), id)
+	if err != nil {
+		return fmt.Errorf("update erasure request: %w", err)
+	}
+	return nil
+}
+
+// FailErasure records the error that interrupted the request.
+func (r *DocumentRepository) FailErasure(ctx context.Context, id, msg string) error {
+	_, err := r.pool.Exec(ctx, `
+		UPDATE erasure_requests SET status=$1, error_message=$2 WHERE id=$3
+	`, ErasureFailed, msg, id)
+	if err != nil {
+		return fmt.Errorf("update erasure request: %w", err)
+	}
+	return nil
+}
+
+// EraseDocument hard-deletes the document row and leaves a tombstone behind so
+// the id is reported as erased rather than simply missing.
+func (r *DocumentRepository) EraseDocument(ctx context.Context, id, erasureID string) (bool, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return false, fmt.Errorf("begin erase: %w", err)
+	}
+	defer tx.Rollback(ctx)
+	tag, err := tx.Exec(ctx, `DELETE FROM documents WHERE id=$1`, id)
+	if err != nil {
+		return false, fmt.Errorf("delete document: %w", err)
+	}
+	_, err = tx.Exec(ctx, `
+		INSERT INTO document_tombstones (document_id, erasure_id, erased_at)
+		VALUES ($1,$2,$3)
+		ON CONFLICT (document_id) DO NOTHING
+	`, id, erasureID, time.Now().UTC())
+	if err != nil {
+		return false, fmt.Errorf("insert tombstone: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return false, fmt.Errorf("commit erase: %w", err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// IsErased reports whether a tombstone exists for the document id.
+func (r *DocumentRepository) IsErased(ctx context.Context, id string) (bool, error) {
+	var exists bool
+	err := r.pool.QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM document_tombstones WHERE document_id=$1)
+	`, id).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("select tombstone: %w", err)
+	}
+	return exists, nil
+}
+
+// ErasedBy reports whether the document's tombstone was left by the erasure
+// request erasureID.
+func (r *DocumentRepository) ErasedBy(ctx context.Context, id, erasureID string) (bool, error) {
+	var exists bool
+	err := r.pool.QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM document_tombstones WHERE document_id=$1 AND erasure_id=$2)
+	`, id, erasureID).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("select tombstone: %w", err)
+	}
+	return exists, nil
+}
+
+// CancelForErasure cancels the document whatever its status, so an extraction
+// still running stops at its next check and cannot complete it. It reports
+// whether the document exists.
+func (r *DocumentRepository) CancelForErasure(ctx context.Context, id string) (bool, error) {
+	tag, err := r.pool.Exec(ctx, `UPDATE documents SET status=$1, updated_at=$2 WHERE id=$3`, StatusCancelled, time.Now().UTC(), id)
+	if err != nil {
+		return false, fmt.Errorf("cancel document for erasure: %w", err)
+	}
+	return tag.RowsAffected() == 1, nil
+}
@@ -0,0 +1,177 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/keys"
+)
+
+// ExportStatus tracks a background export.
+type ExportStatus string
+
+const (
+	ExportPending   ExportStatus = "pending"
+	ExportRunning   ExportStatus = "running"
+	ExportCompleted ExportStatus = "completed"
+	ExportFailed    ExportStatus = "failed"
+	// ExportExpired means the ZIP was deleted after its time was up.
+	ExportExpired ExportStatus = "expired"
+)
+
+// ErrExportNotFound is returned when an export does not exist or belongs to
+// another owner.
+var ErrExportNotFound = errors.New("export not found")
+
+// Export is a row of the exports table: a ZIP of several documents built by
+// the worker into the processed bucket.
+type Export struct {
+	ID string `json:"id"`
+	// Scope is the owner the documents were checked against when the export
+	// was requested, empty for an admin; the worker reads them the same way.
+	Scope       string       `json:"-"`
+	Artifact    string       `json:"artifact"`
+	DocumentIDs []string     `json:"documentIds"`
+	Status      ExportStatus `json:"status"`
+	ObjectKey   *string      `json:"-"`
+	Size        *int64       `json:"size,omitempty"`
+	// Manifest is the export's manifest.json: what each document became.
+	Manifest     json.RawMessage `json:"manifest,omitempty"`
+	ErrorMessage *string         `json:"errorMessage,omitempty"`
+	CreatedAt    time.Time       `json:"createdAt"`
+	CompletedAt  *time.Time      `json:"completedAt,omitempty"`
+	// ExpiresAt is when the ZIP is deleted.
+	ExpiresAt time.Time `json:"expiresAt"`
+	// DataKey is the wrapped key the ZIP is encrypted with (SSE-C), nil when
+	// no master keys are configured.
+	DataKey *keys.Wrapped `json:"-"`
+}
+
+// CreateExport inserts a pending export.
+func (r *DocumentRepository) CreateExport(ctx context.Context, e *Export) error {
+	e.Status = ExportPending
+	e.CreatedAt = time.Now().UTC()
+	var (
+		dataKey   []byte
+		dataKeyID *string
+	)
+	if e.DataKey != nil {
+		dataKey, dataKeyID = e.DataKey.Key, &e.DataKey.KeyID
+	}
+	_, err := r.pool.Exec(ctx, `
+		INSERT INTO exports (id, scope, artifact, document_ids, status, created_at, expires_at, data_key, data_key_id)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9)
+	`, e.ID, e.Scope, e.Artifact, e.DocumentIDs, e.Status, e.CreatedAt, e.ExpiresAt, dataKey, dataKeyID)
+	if err != nil {
+		return fmt.Errorf("insert export: %w", err)
+	}
+	return nil
+}
+
+// GetExport returns the export id. A non-empty owner only finds exports that
+// owner requested.
+func (r *DocumentRepository) GetExport(ctx context.Context, id, owner string) (*Export, error) {
+	var (
+		e         Export
+		dataKey   []byte
+		dataKeyID *string
+	)
+	err := r.pool.QueryRow(ctx, `
+		SELECT id, scope, artifact, document_ids, status, object_key, size, manifest, error_message, created_at, completed_at, expires_at, data_key, data_key_id
+		FROM exports WHERE id=$1 AND ($2 = '' OR scope = $2)
+	`, id, owner).Scan(&e.ID, &e.Scope, &e.Artifact, &e.DocumentIDs, &e.Status, &e.ObjectKey, &e.Size, &e.Manifest, &e.ErrorMessage, &e.CreatedAt, &e.CompletedAt, &e.ExpiresAt, &dataKey, &dataKeyID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrExportNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("select export: %w", err)
+	}
+	if dataKeyID != nil {
+		e.DataKey = &keys.Wrapped{KeyID: *dataKeyID, Key: dataKey}
+	}
+	return &e, nil
+}
+
+// MarkExportRunning flags a pending or failed export as in progress. It
+// returns false when the export is already done or expired.
+func (r *DocumentRepository) MarkExportRunning(ctx context.Context, id string) (bool, error) {
+	tag, err := r.pool.Exec(ctx, `
+		UPDATE exports SET status=$1, error_message=NULL WHERE id=$2 AND status IN ($3, $4, $1)
+	`, ExportRunning, id, ExportPending, ExportFailed)
+	if err != nil {
+		return false, fmt.Errorf("update export: %w", err)
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// CompleteExport records the finished ZIP. It returns false when the export
+// expired while it was being built, in which case the caller removes the
+// object.
+func (r *DocumentRepository) CompleteExport(ctx context.Context, id, objectKey string, size int64, manifest []byte) (bool, error) {
+	tag, err := r.pool.Exec(ctx, `
+		UPDATE exports SET status=$1, object_key=$2, size=$3, manifest=$4, completed_at=$5
+		WHERE id=$6 AND status=$7
+	`, ExportCompleted, objectKey, size, manifest, time.Now().UTC(), id, ExportRunning)
+	if err != nil {
+		return false, fmt.Errorf("update export: %w", err)
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// FailExport records the error that stopped the export: a build that
+// failed, or a pending export whose task could not be queued.
+func (r *DocumentRepository) FailExport(ctx context.Context, id, msg string) error {
+	_, err := r.pool.Exec(ctx, `
+		UPDATE exports SET status=$1, error_message=$2 WHERE id=$3 AND status IN ($4, $5)
+	`, ExportFailed, msg, id, ExportPending, ExportRunning)
+	if err != nil {
+		return fmt.Errorf("update export: %w", err)
+	}
+	return nil
+}
+
+// ExpireExport marks the export expired and returns the key of its ZIP, if
+// one was stored.
+func (r *DocumentRepository) ExpireExport(ctx context.Context, id string) (*string, error) {
+	var key *string
+	err := r.pool.QueryRow(ctx, `
+		UPDATE exports SET status=$1 WHERE id=$2 RETURNING object_key
+	`, ExportExpired, id).Scan(&key)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrExportNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("expire export: %w", err)
+	}
+	return key, nil
+}
+
+// ExpireExportsOf marks every export that holds one of the documents ids as
+// expired, so a build still running cannot complete it, and returns their
+// ids. Erasure then removes the ZIPs and DeleteExports the rows.
+func (r *DocumentRepository) ExpireExportsOf(ctx context.Context, ids []string) ([]string, error) {
+	rows, err := r.pool.Query(ctx, `
+		UPDATE exports SET status=$1 WHERE document_ids && $2 RETURNING id
+	`, ExportExpired, ids)
+	if err != nil {
+		return nil, fmt.Errorf("expire exports: %w", err)
+	}
+	out, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("scan exports: %w", err)
+	}
+	return out, nil
+}
+
+// DeleteExports deletes the export rows ids.
+func (r *DocumentRepository) DeleteExports(ctx context.Context, ids []string) error {
+	if _, err := r.pool.Exec(ctx, `DELETE FROM exports WHERE id = ANY($1)`, ids); err != nil {
+		return fmt.Errorf("delete exports: %w", err)
+	}
+	return nil
+}
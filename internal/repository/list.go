@@ -0,0 +1,185 @@
+package repository
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	defaultListLimit = 50
+	maxListLimit     = 500
+)
+
+// ErrInvalidCursor is returned by List for a cursor it did not issue.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// ListFilter narrows and pages List results. Zero values mean no filter.
+type ListFilter struct {
+	// OwnerID limits results to one principal's documents.
+	OwnerID  string
+	Statuses []DocumentStatus
+	// CreatedFrom is inclusive, CreatedTo exclusive.
+	CreatedFrom    time.Time
+	CreatedTo      time.Time
+	FileNamePrefix string
+	// Metadata keeps documents whose metadata holds every pair given.
+	Metadata map[string]string
+	// Tags keeps documents carrying every tag given.
+	Tags []string
+	// MaxCoverage keeps documents whose last extraction covered at most
+	// this share of pages with text.
+	MaxCoverage *float64
+	// Ascending lists oldest first; the default is newest first.
+	Ascending bool
+	// Limit defaults to 50 and is capped at 500.
+	Limit int
+	// Cursor is the NextCursor of the previous page.
+	Cursor string
+	// WithTotal also counts every match, ignoring the cursor. It costs a
+	// second query, so callers ask for it only when they show it.
+	WithTotal bool
+}
+
+// DocumentPage is one page of List results. Content is not loaded.
+type DocumentPage struct {
+	Documents  []Document `json:"documents"`
+	NextCursor string     `json:"nextCursor,omitempty"`
+	Total      *int64     `json:"total,omitempty"`
+}
+
+// List returns documents matching filter ordered by creation time, using
+// keyset pagination on (created_at, id) so pages stay stable and cheap while
+// rows are inserted.
+func (r *DocumentRepository) List(ctx context.Context, filter ListFilter) (*DocumentPage, error) {
+	var page *DocumentPage
+	err := r.read(ctx, func(q querier) (err error) {
+		page, err = list(ctx, q, filter)
+		return err
+	})
+	return page, err
+}
+
+func list(ctx context.Context, q querier, filter ListFilter) (*DocumentPage, error) {
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	if limit > maxListLimit {
+		limit = maxListLimit
+	}
+	where, args := filter.conditions()
+	page := &DocumentPage{Documents: []Document{}}
+	if filter.WithTotal {
+		var total int64
+		if err := q.QueryRow(ctx, `SELECT COUNT(*) FROM documents WHERE `+where, args...).Scan(&total); err != nil {
+			return nil, fmt.Errorf("count documents: %w", err)
+		}
+		page.Total = &total
+	}
+
+	cmp, order := "<", "DESC"
+	if filter.Ascending {
+		cmp, order = ">", "ASC"
+	}
+	if filter.Cursor != "" {
+		at, id, err := decodeCursor(filter.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, at, id)
+		where += fmt.Sprintf(" AND (created_at, id) %s ($%d, $%d)", cmp, len(args)-1, len(args))
+	}
+	args = append(args, limit+1)
+	rows, err := q.Query(ctx, `
+		SELECT id, file_name, object_key, processed_key, status, error_message, drop_id, COALESCE(owner_id,''), raw_purged_at, text_purged_at, archived_at, archive_key, process_at, metadata, tags, extraction, COALESCE(sha256,''), created_at, updated_at
+		FROM documents WHERE `+where+`
+		ORDER BY created_at `+order+`, id `+order+fmt.Sprintf(` LIMIT $%d`, len(args)), args...)
+	if err != nil {
+		return nil, fmt.Errorf("list documents: %w", err)
+	}
+	docs, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (Document, error) {
+		var d Document
+		err := row.Scan(&d.ID, &d.FileName, &d.ObjectKey, &d.ProcessedKey, &d.Status, &d.ErrorMessage, &d.DropID, &d.OwnerID, &d.RawPurgedAt, &d.TextPurgedAt, &d.ArchivedAt, &d.ArchiveKey, &d.ProcessAt, &d.Metadata, &d.Tags, &d.Extraction, &d.SHA256, &d.CreatedAt, &d.UpdatedAt)
+		return d, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("scan documents: %w", err)
+	}
+	if len(docs) > limit {
+		docs = docs[:limit]
+		last := docs[limit-1]
+		page.NextCursor = encodeCursor(last.CreatedAt, last.ID)
+	}
+	page.Documents = append(page.Documents, docs...)
+	return page, nil
+}
+
+// conditions renders the filter as a WHERE clause with numbered arguments.
+func (f ListFilter) conditions() (string, []interface{}) {
+	clauses := []string{"TRUE"}
+	var args []interface{}
+	add := func(clause string, arg interface{}) {
+		args = append(args, arg)
+		clauses = append(clauses, fmt.Sprintf(clause, len(args)))
+	}
+	if f.OwnerID != "" {
+		add("owner_id = $%d", f.OwnerID)
+	}
+	if len(f.Statuses) > 0 {
+		statuses := make([]string, len(f.Statuses))
+		for i, s := range f.Statuses {
+			statuses[i] = string(s)
+		}
+		add("status = ANY($%d)", statuses)
+	}
+	if !f.CreatedFrom.IsZero() {
+		add("created_at >= $%d", f.CreatedFrom)
+	}
+	if !f.CreatedTo.IsZero() {
+		add("created_at < $%d", f.CreatedTo)
+	}
+	if f.FileNamePrefix != "" {
+		add(`file_name LIKE $%d ESCAPE '\'`, escapeLike(f.FileNamePrefix)+"%")
+	}
+	if len(f.Metadata) > 0 {
+		add("metadata @> $%d", f.Metadata)
+	}
+	if len(f.Tags) > 0 {
+		add("tags @> $%d", f.Tags)
+	}
+	if f.MaxCoverage != nil {
+		add("(extraction->>'coverage')::float8 <= $%d", *f.MaxCoverage)
+	}
+	return strings.Join(clauses, " AND "), args
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// Cursors are opaque to clients: the last row's creation time and id.
+func encodeCursor(at time.Time, id string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(at.UTC().Format(time.RFC3339Nano) + "|" + id))
+}
+
+func decodeCursor(cursor string) (time.Time, string, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, "", ErrInvalidCursor
+	}
+	ts, id, ok := strings.Cut(string(raw), "|")
+	if !ok || id == "" {
+		return time.Time{}, "", ErrInvalidCursor
+	}
+	at, err := time.Parse(time.RFC3339Nano, ts)
+	if err != nil {
+		return time.Time{}, "", ErrInvalidCursor
+	}
+	return at, id, nil
+}
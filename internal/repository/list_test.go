@@ -0,0 +1,43 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	at := time.Date(2024, 3, 1, 10, 30, 0, 123456000, time.UTC)
+	gotAt, gotID, err := decodeCursor(encodeCursor(at, "doc-1"))
+	if err != nil || !gotAt.Equal(at) || gotID != "doc-1" {
+		t.Fatalf("round trip: %v %q %v", gotAt, gotID, err)
+	}
+	for _, bad := range []string{"not base64!", "bm8tc2VwYXJhdG9y", encodeCursor(at, "")} {
+		if _, _, err := decodeCursor(bad); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("decodeCursor(%q) = %v, want ErrInvalidCursor", bad, err)
+		}
+	}
+}
+
+func TestListFilterConditions(t *testing.T) {
+	coverage := 0.5
+	where, args := ListFilter{
+		OwnerID:        "alice",
+		Statuses:       []DocumentStatus{StatusFailed},
+		CreatedFrom:    time.Unix(0, 0),
+		FileNamePrefix: "50%_off",
+		Metadata:       map[string]string{"invoice_number": "INV-42"},
+		Tags:           []string{"paid"},
+		MaxCoverage:    &coverage,
+	}.conditions()
+	want := `TRUE AND owner_id = $1 AND status = ANY($2) AND created_at >= $3 AND file_name LIKE $4 ESCAPE '\' AND metadata @> $5 AND tags @> $6 AND (extraction->>'coverage')::float8 <= $7`
+	if where != want {
+		t.Errorf("where = %s", where)
+	}
+	if len(args) != 7 || args[3] != `50\%\_off%` {
+		t.Errorf("args = %v", args)
+	}
+	if where, args := (ListFilter{}).conditions(); where != "TRUE" || len(args) != 0 {
+		t.Errorf("empty filter: %s %v", where, args)
+	}
+}
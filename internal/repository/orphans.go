@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// UploadKey is the raw object key of a document uploaded through the API.
+type UploadKey struct {
+	DocumentID string
+	ObjectKey  string
+	CreatedAt  time.Time
+	RawPurged  bool
+}
+
+// ListUploadKeys returns up to limit documents whose object key is under
+// uploads/ and sorts after the given key, in byte order: the order object
+// stores list keys in, so the two listings can be walked side by side. It
+// reads the primary, so a document created a moment ago is never taken for
+// missing.
+func (r *DocumentRepository) ListUploadKeys(ctx context.Context, after string, limit int) ([]UploadKey, error) {
+	rows, err := r.pool.Query(ctx, `
+		SELECT id, object_key, created_at, raw_purged_at IS NOT NULL FROM documents
+		WHERE object_key LIKE 'uploads/%' AND object_key COLLATE "C" > $1
+		ORDER BY object_key COLLATE "C" LIMIT $2
+	`, after, limit)
+	if err != nil {
+		return nil, fmt.Errorf("select upload keys: %w", err)
+	}
+	keys, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (UploadKey, error) {
+		var k UploadKey
+		err := row.Scan(&k.DocumentID, &k.ObjectKey, &k.CreatedAt, &k.RawPurged)
+		return k, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("scan upload keys: %w", err)
+	}
+	return keys, nil
+}
+
+// ExistingDocumentIDs returns which of ids still have a document row.
+func (r *DocumentRepository) ExistingDocumentIDs(ctx context.Context, ids []string) (map[string]bool, error) {
+	rows, err := r.pool.Query(ctx, `SELECT id FROM documents WHERE id = ANY($1)`, ids)
+	if err != nil {
+		return nil, fmt.Errorf("select document ids: %w", err)
+	}
+	found, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("scan document ids: %w", err)
+	}
+	out := make(map[string]bool, len(found))
+	for _, id := range found {
+		out[id] = true
+	}
+	return out, nil
+}
@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Rejection reasons recorded for refused uploads.
+const (
+	RejectTooLarge        = "too_large"
+	RejectUnsupportedType = "unsupported_type"
+	RejectEmpty           = "empty_file"
+	RejectMalformed       = "malformed_request"
+	RejectChecksum        = "checksum_mismatch"
+)
+
+// RejectionRetention is how long rejections are kept, and so how far back the
+// rejection report can look.
+const RejectionRetention = 90 * 24 * time.Hour
+
+// Rejection is a refused upload attempt.
+type Rejection struct {
+	Reason      string    `json:"reason"`
+	Detail      string    `json:"detail,omitempty"`
+	ContentType string    `json:"contentType,omitempty"`
+	SizeBytes   int64     `json:"sizeBytes"`
+	ClientIP    string    `json:"clientIp,omitempty"`
+	UserAgent   string    `json:"userAgent,omitempty"`
+	Principal   string    `json:"principal,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+// RejectionBucket aggregates rejections sharing a key.
+type RejectionBucket struct {
+	Key   string `json:"key"`
+	Count int64  `json:"count"`
+	Bytes int64  `json:"bytes"`
+}
+
+// RejectionReport summarises rejections since a point in time.
+type RejectionReport struct {
+	Since         time.Time         `json:"since"`
+	Total         int64             `json:"total"`
+	ByReason      []RejectionBucket `json:"byReason"`
+	ByContentType []RejectionBucket `json:"byContentType"`
+	Recent        []Rejection       `json:"recent"`
+}
+
+// RecordRejection stores a refused upload.
+func (r *DocumentRepository) RecordRejection(ctx context.Context, rej *Rejection) error {
+	rej.CreatedAt = time.Now().UTC()
+	_, err := r.pool.Exec(ctx, `
+		INSERT INTO upload_rejections (reason, detail, content_type, size_bytes, client_ip, user_agent, principal, created_at)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$8)
+	`, rej.Reason, rej.Detail, rej.ContentType, rej.SizeBytes, rej.ClientIP, rej.UserAgent, rej.Principal, rej.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("insert rejection: %w", err)
+	}
+	return nil
+}
+
+// DeleteRejectionsBefore removes rejections recorded before cutoff and
+// returns how many it removed.
+func (r *DocumentRepository) DeleteRejectionsBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	tag, err := r.pool.Exec(ctx, `DELETE FROM upload_rejections WHERE created_at < $1`, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("delete rejections: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// RejectionReport aggregates rejections recorded after since.
+func (r *DocumentRepository) RejectionReport(ctx context.Context, since time.Time, recent int) (*RejectionReport, error) {
+	report := &RejectionReport{Since: since}
+	var err error
+	if report.ByReason, err = r.rejectionBuckets(ctx, "reason", since); err != nil {
+		return nil, err
+	}
+	if report.ByContentType, err = r.rejectionBuckets(ctx, "content_type", since); err != nil {
+		return nil, err
+	}
+	for _, b := range report.ByReason {
+		report.Total += b.Count
+	}
+	rows, err := r.pool.Query(ctx, `
+		SELECT reason, detail, content_type, size_bytes, client_ip, user_agent, principal, created_at
+		FROM upload_rejections WHERE created_at >= $1
+		ORDER BY created_at DESC LIMIT $2
+	`, since, recent)
+	if err != nil {
+		return nil, fmt.Errorf("select rejections: %w", err)
+	}
+	report.Recent, err = pgx.CollectRows(rows, func(row pgx.CollectableRow) (Rejection, error) {
+		var rej Rejection
+		err := row.Scan(&rej.Reason, &rej.Detail, &rej.ContentType, &rej.SizeBytes, &rej.ClientIP, &rej.UserAgent, &rej.Principal, &rej.CreatedAt)
+		return rej, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("scan rejections: %w", err)
+	}
+	return report, nil
+}
+
+func (r *DocumentRepository) rejectionBuckets(ctx context.Context, column string, since time.Time) ([]RejectionBucket, error) {
+	// column is one of a fixed set chosen by RejectionReport, never user input.
+	rows, err := r.pool.Query(ctx, `
+		SELECT `+column+`, COUNT(*), COALESCE(SUM(size_bytes), 0)
+		FROM upload_rejections WHERE created_at >= $1
+		GROUP BY 1 ORDER BY 2 DESC
+	`, since)
+	if err != nil {
+		return nil, fmt.Errorf("aggregate rejections by %s: %w", column, err)
+	}
+	buckets, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (RejectionBucket, error) {
+		var b RejectionBucket
+		err := row.Scan(&b.Key, &b.Count, &b.Bytes)
+		return b, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("scan rejection buckets: %w", err)
+	}
+	return buckets, nil
+}
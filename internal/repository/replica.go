@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// replicaRetry is how long reads stay on the primary after the replica
+// failed, so an outage costs one failed query per interval rather than one
+// per request.
+const replicaRetry = 30 * time.Second
+
+// querier is the part of a pool that reads need; both pools provide it.
+type querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// replica is a read-only pool and the time it may be tried again after a
+// failure.
+type replica struct {
+	pool      *pgxpool.Pool
+	fallbacks atomic.Int64
+
+	mu        sync.Mutex
+	downUntil time.Time
+}
+
+// UseReplica sends Get, GetForOwner and List to pool, a streaming replica of
+// the primary. Everything else, including the lookups writes make, stays on
+// the primary. Reads see the replica's lag; Get asks the primary about ids
+// the replica has not seen yet, so a document is found right after upload.
+func (r *DocumentRepository) UseReplica(pool *pgxpool.Pool) {
+	r.replica = &replica{pool: pool}
+}
+
+// read runs fn against the replica when there is a healthy one, and against
+// the primary when there is not or the replica fails. fn may run twice.
+func (r *DocumentRepository) read(ctx context.Context, fn func(q querier) error) error {
+	rep := r.replica
+	if rep == nil || !rep.up() {
+		return fn(r.pool)
+	}
+	err := fn(rep.pool)
+	if !replicaFailed(ctx, err) {
+		return err
+	}
+	rep.markDown()
+	return fn(r.pool)
+}
+
+func (rep *replica) up() bool {
+	rep.mu.Lock()
+	defer rep.mu.Unlock()
+	return time.Now().After(rep.downUntil)
+}
+
+func (rep *replica) markDown() {
+	rep.fallbacks.Add(1)
+	rep.mu.Lock()
+	defer rep.mu.Unlock()
+	rep.downUntil = time.Now().Add(replicaRetry)
+}
+
+// replicaFailed reports whether err means the replica could not answer, as
+// opposed to answering with no rows or rejecting the query itself.
+func replicaFailed(ctx context.Context, err error) bool {
+	if err == nil || ctx.Err() != nil {
+		return false
+	}
+	if errors.Is(err, pgx.ErrNoRows) || errors.Is(err, ErrNotFound) || errors.Is(err, ErrInvalidCursor) {
+		return false
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		// Class 57P is a server shutting down or still starting; 40001 is a
+		// query cancelled by a conflict with WAL replay on the standby.
+		return strings.HasPrefix(pgErr.Code, "57P") || pgErr.Code == "40001"
+	}
+	// Anything else failed before Postgres answered: a refused or dropped
+	// connection, a closed pool, an unreachable host.
+	return true
+}
+
+// WriteReplicaMetrics writes how often reads fell back to the primary, in
+// the Prometheus text format. It writes nothing without a replica.
+func (r *DocumentRepository) WriteReplicaMetrics(w io.Writer) {
+	if r.replica == nil {
+		return
+	}
+	fmt.Fprintln(w, "# HELP vaultdrop_db_replica_fallback_total Reads sent to the primary because the read replica failed.")
+	fmt.Fprintln(w, "# TYPE vaultdrop_db_replica_fallback_total counter")
+	fmt.Fprintf(w, "vaultdrop_db_replica_fallback_total %d\n", r.replica.fallbacks.Load())
+}
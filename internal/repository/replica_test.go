@@ -0,0 +1,38 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestReplicaFailed(t *testing.T) {
+	ctx := context.Background()
+	for _, tc := range []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{ErrNotFound, false},
+		{fmt.Errorf("select document: %w", pgx.ErrNoRows), false},
+		{ErrInvalidCursor, false},
+		{fmt.Errorf("list documents: %w", &pgconn.PgError{Code: "42703"}), false},
+		{fmt.Errorf("list documents: %w", &pgconn.PgError{Code: "57P03"}), true},
+		{&pgconn.PgError{Code: "40001"}, true},
+		{fmt.Errorf("select document: %w", &net.OpError{Op: "dial", Err: errors.New("connection refused")}), true},
+	} {
+		if got := replicaFailed(ctx, tc.err); got != tc.want {
+			t.Errorf("replicaFailed(%v) = %v, want %v", tc.err, got, tc.want)
+		}
+	}
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if replicaFailed(cancelled, context.Canceled) {
+		t.Error("a cancelled caller should not take the replica out of rotation")
+	}
+}
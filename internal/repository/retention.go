@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Artifact names what a retention rule removes.
+type Artifact string
+
+const (
+	// ArtifactRaw is the uploaded PDF in the raw bucket.
+	ArtifactRaw Artifact = "raw"
+	// ArtifactText is the extracted text: the processed object, the content
+	// column, and its versions.
+	ArtifactText Artifact = "text"
+	// ArtifactDocument is the whole document, metadata row included.
+	ArtifactDocument Artifact = "document"
+)
+
+// ExpiredDocument carries the object keys retention needs to delete.
+type ExpiredDocument struct {
+	ID           string
+	ObjectKey    string
+	ProcessedKey *string
+	ArchiveKey   *string
+}
+
+// ListExpired returns up to limit documents created before cutoff whose
+// artifact has not been purged yet. Documents still queued or processing are
+// skipped so retention never races extraction.
+func (r *DocumentRepository) ListExpired(ctx context.Context, artifact Artifact, cutoff time.Time, limit int) ([]ExpiredDocument, error) {
+	filter, err := expiredFilter(artifact)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := r.pool.Query(ctx, `
+		SELECT id, object_key, processed_key, archive_key FROM documents
+		WHERE created_at < $1 AND `+filter+`
+		ORDER BY created_at LIMIT $2
+	`, cutoff, limit)
+	if err != nil {
+		return nil, fmt.Errorf("select expired documents: %w", err)
+	}
+	docs, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (ExpiredDocument, error) {
+		var d ExpiredDocument
+		err := row.Scan(&d.ID, &d.ObjectKey, &d.ProcessedKey, &d.ArchiveKey)
+		return d, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("scan expired documents: %w", err)
+	}
+	return docs, nil
+}
+
+// expiredFilter is the condition ListExpired adds for artifact. Only
+// completed documents have text; raw uploads and whole documents expire once
+// extraction is over, whether it completed, failed or was cancelled.
+func expiredFilter(artifact Artifact) (string, error) {
+	switch artifact {
+	case ArtifactRaw:
+		return "status IN ('completed', 'failed', 'cancelled') AND raw_purged_at IS NULL", nil
+	case ArtifactText:
+		return "status = 'completed' AND text_purged_at IS NULL", nil
+	case ArtifactDocument:
+		return "status IN ('completed', 'failed', 'cancelled')", nil
+	}
+	return "", fmt.Errorf("unknown artifact %q", artifact)
+}
+
+// MarkRawPurged records that the raw upload was deleted.
+func (r *DocumentRepository) MarkRawPurged(ctx context.Context, id string) error {
+	_, err := r.pool.Exec(ctx, `UPDATE documents SET raw_purged_at=$1 WHERE id=$2`, time.Now().UTC(), id)
+	if err != nil {
+		return fmt.Errorf("mark raw purged: %w", err)
+	}
+	return nil
+}
+
+// PurgeText clears the extracted text, its archive and its versions,
+// keeping metadata.
+func (r *DocumentRepository) PurgeText(ctx context.Context, id string) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin purge text: %w", err)
+	}
+	defer tx.Rollback(ctx)
+	_, err = tx.Exec(ctx, `
+		UPDATE documents SET content=NULL, processed_key=NULL, archive_key=NULL, archived_at=NULL, text_purged_at=$1 WHERE id=$2
+	`, time.Now().UTC(), id)
+	if err != nil {
+		return fmt.Errorf("purge text: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM document_versions WHERE document_id=$1`, id); err != nil {
+		return fmt.Errorf("delete versions: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit purge text: %w", err)
+	}
+	return nil
+}
+
+// DeleteDocument removes the document row. Versions cascade.
+func (r *DocumentRepository) DeleteDocument(ctx context.Context, id string) error {
+	if _, err := r.pool.Exec(ctx, `DELETE FROM documents WHERE id=$1`, id); err != nil {
+		return fmt.Errorf("delete document: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrVersionNotFound is returned when a document has no such version.
+var ErrVersionNotFound = errors.New("document version not found")
+
+// DocumentVersion describes one extraction result of a document. A new
+// version is recorded each time extraction completes with different text.
+type DocumentVersion struct {
+	Version       int       `json:"version"`
+	ContentSHA256 string    `json:"contentSha256"`
+	Size          int       `json:"size"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
+
+// recordVersion appends content as the next version unless it matches the
+// latest one, so retried jobs do not create duplicates. The caller's
+// transaction must hold the document row lock.
+func recordVersion(ctx context.Context, tx pgx.Tx, id, content string, now time.Time) error {
+	sum := sha256Hex(content)
+	var latest int
+	var latestSum string
+	err := tx.QueryRow(ctx, `
+		SELECT version, content_sha256 FROM document_versions
+		WHERE document_id=$1 ORDER BY version DESC LIMIT 1
+	`, id).Scan(&latest, &latestSum)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("select latest version: %w", err)
+	}
+	if latestSum == sum {
+		return nil
+	}
+	_, err = tx.Exec(ctx, `
+		INSERT INTO document_versions (document_id, version, content, content_sha256, created_at)
+		VALUES ($1,$2,$3,$4,$5)
+	`, id, latest+1, content, sum, now)
+	if err != nil {
+		return fmt.Errorf("insert version: %w", err)
+	}
+	return nil
+}
+
+// ListVersions returns a document's versions, oldest first, without content.
+func (r *DocumentRepository) ListVersions(ctx context.Context, id string) ([]DocumentVersion, error) {
+	rows, err := r.pool.Query(ctx, `
+		SELECT version, content_sha256, COALESCE(octet_length(content), size, 0), created_at
+		FROM document_versions WHERE document_id=$1 ORDER BY version
+	`, id)
+	if err != nil {
+		return nil, fmt.Errorf("select versions: %w", err)
+	}
+	versions, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (DocumentVersion, error) {
+		var v DocumentVersion
+		err := row.Scan(&v.Version, &v.ContentSHA256, &v.Size, &v.CreatedAt)
+		return v, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("scan versions: %w", err)
+	}
+	return versions, nil
+}
+
+// VersionContent returns the extracted text stored for a version, or the
+// key of the object in the processed bucket the archive sweep moved it to.
+func (r *DocumentRepository) VersionContent(ctx context.Context, id string, version int) (content, archiveKey string, err error) {
+	var text, key *string
+	err = r.pool.QueryRow(ctx, `
+		SELECT content, archive_key FROM document_versions WHERE document_id=$1 AND version=$2
+	`, id, version).Scan(&text, &key)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", "", ErrVersionNotFound
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("select version: %w", err)
+	}
+	if text == nil {
+		if key == nil {
+			return "", "", fmt.Errorf("version %d of %s has neither content nor archive", version, id)
+		}
+		return "", *key, nil
+	}
+	return *text, "", nil
+}
+
+// VersionBody is the text of a version still held in the database.
+type VersionBody struct {
+	Version int
+	Content string
+}
+
+// UnarchivedVersions returns the versions of a document whose text is still
+// in the database, oldest first.
+func (r *DocumentRepository) UnarchivedVersions(ctx context.Context, id string) ([]VersionBody, error) {
+	rows, err := r.pool.Query(ctx, `
+		SELECT version, content FROM document_versions
+		WHERE document_id=$1 AND content IS NOT NULL ORDER BY version
+	`, id)
+	if err != nil {
+		return nil, fmt.Errorf("select unarchived versions: %w", err)
+	}
+	versions, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (VersionBody, error) {
+		var v VersionBody
+		err := row.Scan(&v.Version, &v.Content)
+		return v, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("scan unarchived versions: %w", err)
+	}
+	return versions, nil
+}
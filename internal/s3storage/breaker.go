@@ -0,0 +1,121 @@
+package s3storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// ErrUnavailable is returned without contacting the object store while the
+// circuit breaker is open, after repeated transient failures. Handlers map it
+// to 503 so clients back off instead of seeing raw network errors.
+var ErrUnavailable = errors.New("storage unavailable")
+
+// breaker is a circuit breaker over object-store calls. After threshold
+// consecutive transient failures it opens and fails calls fast for cooldown;
+// then one call is let through as a probe, whose outcome closes or reopens
+// it. A zero threshold disables it.
+type breaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+// allow reports whether a call may go ahead.
+func (b *breaker) allow() error {
+	if b == nil || b.threshold <= 0 {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.failures < b.threshold {
+		return nil
+	}
+	if wait := time.Until(b.openUntil); wait > 0 || b.probing {
+		return fmt.Errorf("%w: %d consecutive failures, retrying in %s", ErrUnavailable, b.failures, wait.Round(time.Second))
+	}
+	b.probing = true
+	return nil
+}
+
+// record counts the outcome of a call that allow let through. Errors the
+// request itself caused, such as a missing key, show the store is up; a call
+// whose caller gave up, or whose upload failed reading the caller's data,
+// says nothing either way.
+func (b *breaker) record(ctx context.Context, err error) {
+	if b == nil || b.threshold <= 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+	switch {
+	case ctx.Err() != nil:
+	case errors.As(err, new(sourceError)):
+	case transient(err):
+		b.failures++
+		if b.failures >= b.threshold {
+			b.openUntil = time.Now().Add(b.cooldown)
+		}
+	default:
+		b.failures = 0
+	}
+}
+
+// transient reports whether err means the object store is unreachable or
+// overloaded, as opposed to rejecting this particular request.
+func transient(err error) bool {
+	if err == nil {
+		return false
+	}
+	resp := minio.ToErrorResponse(err)
+	if resp.StatusCode >= 500 || resp.Code == "SlowDown" || resp.Code == "RequestTimeout" {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// sourceError is a failure reading the data an upload sends, such as a
+// client that disconnected mid-body. It surfaces from PutObject like a network
+// error but tells nothing about the object store.
+type sourceError struct {
+	err error
+}
+
+func (e sourceError) Error() string { return e.err.Error() }
+
+func (e sourceError) Unwrap() error { return e.err }
+
+// sourceReader marks the errors of r, other than io.EOF, as sourceError.
+type sourceReader struct {
+	r io.Reader
+}
+
+func (s sourceReader) Read(p []byte) (int, error) {
+	n, err := s.r.Read(p)
+	if err != nil && err != io.EOF {
+		err = sourceError{err: err}
+	}
+	return n, err
+}
+
+// guard runs call when the breaker allows it and records the outcome.
+func (s *Storage) guard(ctx context.Context, call func() error) error {
+	if err := s.breaker.allow(); err != nil {
+		return err
+	}
+	err := call()
+	s.breaker.record(ctx, err)
+	return err
+}
@@ -0,0 +1,171 @@
+package s3storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+func TestTransient(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"internal error", minio.ErrorResponse{StatusCode: 500, Code: "InternalError"}, true},
+		{"service unavailable", minio.ErrorResponse{StatusCode: 503, Code: "ServiceUnavailable"}, true},
+		{"slow down", minio.ErrorResponse{StatusCode: 503, Code: "SlowDown"}, true},
+		{"request timeout", minio.ErrorResponse{StatusCode: 400, Code: "RequestTimeout"}, true},
+		{"connection refused", &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", errors.New("connection refused"))}, true},
+		{"wrapped network error", fmt.Errorf("put object: %w", &net.DNSError{Err: "no such host", Name: "minio"}), true},
+		{"truncated response", fmt.Errorf("read: %w", io.ErrUnexpectedEOF), true},
+		{"missing key", minio.ErrorResponse{StatusCode: 404, Code: "NoSuchKey"}, false},
+		{"access denied", minio.ErrorResponse{StatusCode: 403, Code: "AccessDenied"}, false},
+		{"bad request", minio.ErrorResponse{StatusCode: 400, Code: "InvalidArgument"}, false},
+		{"other error", errors.New("invalid object name"), false},
+	} {
+		if got := transient(tc.err); got != tc.want {
+			t.Errorf("%s: transient(%v) = %v, want %v", tc.name, tc.err, got, tc.want)
+		}
+	}
+}
+
+func TestBreaker(t *testing.T) {
+	unavailable := minio.ErrorResponse{StatusCode: 503, Code: "ServiceUnavailable"}
+	notFound := minio.ErrorResponse{StatusCode: 404, Code: "NoSuchKey"}
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// A step either makes a call, which allow must let through or refuse as
+	// wantOpen says, or, with expire set, lets the cooldown run out.
+	type step struct {
+		err      error
+		ctx      context.Context
+		expire   bool
+		wantOpen bool
+	}
+	for _, tc := range []struct {
+		name  string
+		steps []step
+	}{
+		{"opens after threshold", []step{
+			{err: unavailable}, {err: unavailable}, {err: unavailable},
+			{wantOpen: true},
+		}},
+		{"success resets the count", []step{
+			{err: unavailable}, {err: unavailable}, {},
+			{err: unavailable}, {err: unavailable},
+			{},
+		}},
+		{"request errors show the store is up", []step{
+			{err: unavailable}, {err: unavailable}, {err: notFound},
+			{err: unavailable}, {err: unavailable},
+			{},
+		}},
+		{"failed reads of the upload body are not counted", []step{
+			{err: unavailable}, {err: unavailable},
+			{err: fmt.Errorf("stream raw object: %w", sourceError{err: io.ErrUnexpectedEOF})},
+			{err: &url.Error{Op: "Put", URL: "http://minio/raw/a", Err: sourceError{err: &net.OpError{Op: "read", Net: "tcp", Err: errors.New("connection reset by peer")}}}},
+			// Neither counted nor a success: one more store failure opens it.
+			{err: unavailable},
+			{wantOpen: true},
+		}},
+		{"cancelled calls are not counted", []step{
+			{err: unavailable}, {err: unavailable},
+			{err: context.Canceled, ctx: cancelled}, {err: context.Canceled, ctx: cancelled},
+			{},
+		}},
+		{"half-open probe closes it", []step{
+			{err: unavailable}, {err: unavailable}, {err: unavailable},
+			{wantOpen: true},
+			{expire: true},
+			{},
+			{}, {err: unavailable}, {err: unavailable},
+			{},
+		}},
+		{"failed probe reopens it", []step{
+			{err: unavailable}, {err: unavailable}, {err: unavailable},
+			{expire: true},
+			{err: unavailable},
+			{wantOpen: true},
+			{expire: true},
+			{},
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			b := &breaker{threshold: 3, cooldown: time.Hour}
+			for i, s := range tc.steps {
+				if s.expire {
+					b.openUntil = time.Now().Add(-time.Second)
+					continue
+				}
+				err := b.allow()
+				if open := errors.Is(err, ErrUnavailable); open != s.wantOpen {
+					t.Fatalf("step %d: allow() = %v, want open %v", i, err, s.wantOpen)
+				}
+				if err != nil {
+					continue
+				}
+				ctx := s.ctx
+				if ctx == nil {
+					ctx = context.Background()
+				}
+				b.record(ctx, s.err)
+			}
+		})
+	}
+}
+
+func TestBreakerAdmitsOneProbe(t *testing.T) {
+	b := &breaker{threshold: 1, cooldown: time.Hour}
+	b.record(context.Background(), io.ErrUnexpectedEOF)
+	b.openUntil = time.Now().Add(-time.Second)
+	if err := b.allow(); err != nil {
+		t.Fatalf("probe refused: %v", err)
+	}
+	// While the probe is out every other call fails fast.
+	if err := b.allow(); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("second call during probe = %v, want ErrUnavailable", err)
+	}
+	b.record(context.Background(), nil)
+	if err := b.allow(); err != nil {
+		t.Errorf("after successful probe: %v", err)
+	}
+}
+
+func TestBreakerDisabled(t *testing.T) {
+	for _, b := range []*breaker{nil, {threshold: 0, cooldown: time.Hour}} {
+		for i := 0; i < 10; i++ {
+			b.record(context.Background(), io.ErrUnexpectedEOF)
+		}
+		if err := b.allow(); err != nil {
+			t.Errorf("disabled breaker %+v refused a call: %v", b, err)
+		}
+	}
+}
+
+func TestSourceReader(t *testing.T) {
+	r := sourceReader{r: io.MultiReader(strings.NewReader("ab"), iotest.ErrReader(io.ErrUnexpectedEOF))}
+	data, err := io.ReadAll(r)
+	if string(data) != "ab" {
+		t.Errorf("read %q, want ab", data)
+	}
+	if !errors.As(err, new(sourceError)) || !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("error = %#v, want a sourceError wrapping io.ErrUnexpectedEOF", err)
+	}
+	// A clean end is not an error.
+	if _, err := io.ReadAll(sourceReader{r: strings.NewReader("ab")}); err != nil {
+		t.Errorf("ReadAll = %v", err)
+	}
+}
@@ -0,0 +1,145 @@
+package s3storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/lifecycle"
+)
+
+// IDs of the lifecycle rules VaultDrop manages. Rules with other IDs are an
+// operator's and are left alone.
+const (
+	// managedRuleID holds the expiry and cleanup actions EnsureBuckets sets.
+	managedRuleID = "vaultdrop-managed"
+	// coldRuleID is the transition TransitionProcessed sets.
+	coldRuleID = "vaultdrop-processed-cold"
+	// exportRuleID expires export ZIPs on the processed bucket.
+	exportRuleID = "vaultdrop-exports"
+)
+
+// bucketLifecycle is the configured lifecycle of the buckets, in days; zero
+// leaves an action out.
+type bucketLifecycle struct {
+	rawExpireDays        int
+	abortIncompleteDays  int
+	noncurrentExpireDays int
+	exportExpireDays     int
+}
+
+// rule returns the managed rule for the raw or the processed bucket, and
+// false when it would have no actions. Raw expiry is a backstop for the
+// retention sweep: objects it deletes are not marked purged on their
+// documents.
+func (l bucketLifecycle) rule(raw bool) (lifecycle.Rule, bool) {
+	rule := lifecycle.Rule{
+		ID:     managedRuleID,
+		Status: "Enabled",
+		AbortIncompleteMultipartUpload: lifecycle.AbortIncompleteMultipartUpload{
+			DaysAfterInitiation: lifecycle.ExpirationDays(l.abortIncompleteDays),
+		},
+		NoncurrentVersionExpiration: lifecycle.NoncurrentVersionExpiration{
+			NoncurrentDays: lifecycle.ExpirationDays(l.noncurrentExpireDays),
+		},
+	}
+	if raw {
+		rule.Expiration.Days = lifecycle.ExpirationDays(l.rawExpireDays)
+	}
+	ok := l.abortIncompleteDays > 0 || l.noncurrentExpireDays > 0 || (raw && l.rawExpireDays > 0)
+	return rule, ok
+}
+
+// exportRule returns the rule that deletes export ZIPs a day after they
+// expire. The worker removes each one when its export expires; the rule is
+// the backstop when that task is lost.
+func (l bucketLifecycle) exportRule() lifecycle.Rule {
+	return lifecycle.Rule{
+		ID:         exportRuleID,
+		Status:     "Enabled",
+		RuleFilter: lifecycle.Filter{Prefix: exportPrefix},
+		Expiration: lifecycle.Expiration{Days: lifecycle.ExpirationDays(l.exportExpireDays)},
+	}
+}
+
+// EnsureExportExpiry installs the export rule on the processed bucket. Only
+// the worker, which builds the ZIPs, needs it; callers should log a failure
+// rather than stop, since stores without lifecycle support still work and
+// the worker deletes each ZIP itself.
+func (s *Storage) EnsureExportExpiry(ctx context.Context) error {
+	rule := s.lifecycle.exportRule()
+	return s.updateLifecycle(ctx, s.processedBucket, exportRuleID, &rule)
+}
+
+// exportExpireDays is how many whole days after upload the export rule
+// deletes a ZIP that lives for ttl.
+func exportExpireDays(ttl time.Duration) int {
+	return int((ttl+24*time.Hour-1)/(24*time.Hour)) + 1
+}
+
+// TransitionProcessed installs a lifecycle rule on the processed bucket that
+// moves artifacts to storageClass once they are days old; the object store
+// applies it, so no data passes through VaultDrop. A days of zero removes the
+// rule.
+func (s *Storage) TransitionProcessed(ctx context.Context, days int, storageClass string) error {
+	if days <= 0 {
+		return s.updateLifecycle(ctx, s.processedBucket, coldRuleID, nil)
+	}
+	return s.updateLifecycle(ctx, s.processedBucket, coldRuleID, &lifecycle.Rule{
+		ID:     coldRuleID,
+		Status: "Enabled",
+		Transition: lifecycle.Transition{
+			Days:         lifecycle.ExpirationDays(days),
+			StorageClass: storageClass,
+		},
+	})
+}
+
+// lifecycleClient is the part of the minio client that edits bucket
+// lifecycles.
+type lifecycleClient interface {
+	GetBucketLifecycle(ctx context.Context, bucket string) (*lifecycle.Configuration, error)
+	SetBucketLifecycle(ctx context.Context, bucket string, cfg *lifecycle.Configuration) error
+}
+
+// updateLifecycle replaces the bucket's rule called id with rule, or removes
+// it when rule is nil, keeping every other rule.
+func (s *Storage) updateLifecycle(ctx context.Context, bucket, id string, rule *lifecycle.Rule) error {
+	return s.guard(ctx, func() error {
+		return replaceRule(ctx, s.client, bucket, id, rule)
+	})
+}
+
+// replaceRule does the work of updateLifecycle. Removing a rule the bucket
+// does not have writes nothing, so stores without lifecycle support are only
+// asked to read it.
+func replaceRule(ctx context.Context, c lifecycleClient, bucket, id string, rule *lifecycle.Rule) error {
+	cfg, err := c.GetBucketLifecycle(ctx, bucket)
+	if err != nil {
+		if minio.ToErrorResponse(err).Code != "NoSuchLifecycleConfiguration" {
+			return fmt.Errorf("get lifecycle of %s: %w", bucket, err)
+		}
+		cfg = lifecycle.NewConfiguration()
+	}
+	rules := cfg.Rules[:0]
+	found := false
+	for _, r := range cfg.Rules {
+		if r.ID == id {
+			found = true
+			continue
+		}
+		rules = append(rules, r)
+	}
+	if rule == nil && !found {
+		return nil
+	}
+	if rule != nil {
+		rules = append(rules, *rule)
+	}
+	cfg.Rules = rules
+	if err := c.SetBucketLifecycle(ctx, bucket, cfg); err != nil {
+		return fmt.Errorf("set lifecycle of %s: %w", bucket, err)
+	}
+	return nil
+}
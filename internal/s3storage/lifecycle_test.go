@@ -0,0 +1,120 @@
+package s3storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/lifecycle"
+)
+
+// fakeLifecycle keeps one bucket's lifecycle; a nil cfg answers
+// NoSuchLifecycleConfiguration.
+type fakeLifecycle struct {
+	cfg    *lifecycle.Configuration
+	getErr error
+	sets   int
+}
+
+func (f *fakeLifecycle) GetBucketLifecycle(ctx context.Context, bucket string) (*lifecycle.Configuration, error) {
+	if f.getErr != nil {
+		return nil, f.getErr
+	}
+	if f.cfg == nil {
+		return nil, minio.ErrorResponse{StatusCode: 404, Code: "NoSuchLifecycleConfiguration"}
+	}
+	copy := *f.cfg
+	copy.Rules = append([]lifecycle.Rule(nil), f.cfg.Rules...)
+	return &copy, nil
+}
+
+func (f *fakeLifecycle) SetBucketLifecycle(ctx context.Context, bucket string, cfg *lifecycle.Configuration) error {
+	f.sets++
+	f.cfg = cfg
+	return nil
+}
+
+func (f *fakeLifecycle) ruleIDs() []string {
+	var ids []string
+	if f.cfg != nil {
+		for _, r := range f.cfg.Rules {
+			ids = append(ids, r.ID)
+		}
+	}
+	return ids
+}
+
+func TestManagedRuleRemovedWhenUnset(t *testing.T) {
+	ctx := context.Background()
+	operator := lifecycle.Rule{ID: "operator-logs", Status: "Enabled", RuleFilter: lifecycle.Filter{Prefix: "logs/"}}
+	c := &fakeLifecycle{cfg: &lifecycle.Configuration{Rules: []lifecycle.Rule{operator}}}
+
+	on := bucketLifecycle{rawExpireDays: 30}
+	rule, ok := on.rule(true)
+	if !ok || rule.Expiration.Days != 30 {
+		t.Fatalf("rule(raw) = %+v, %v", rule, ok)
+	}
+	if err := replaceRule(ctx, c, "raw", managedRuleID, &rule); err != nil {
+		t.Fatal(err)
+	}
+	if ids := c.ruleIDs(); len(ids) != 2 || ids[0] != "operator-logs" || ids[1] != managedRuleID {
+		t.Fatalf("rules after install = %v", ids)
+	}
+
+	// Every day setting back to zero: EnsureBuckets passes a nil rule.
+	if _, ok := (bucketLifecycle{}).rule(true); ok {
+		t.Fatal("rule(raw) with no settings reports actions")
+	}
+	if err := replaceRule(ctx, c, "raw", managedRuleID, nil); err != nil {
+		t.Fatal(err)
+	}
+	if ids := c.ruleIDs(); len(ids) != 1 || ids[0] != "operator-logs" {
+		t.Errorf("rules after removal = %v, want only the operator's", ids)
+	}
+
+	// Removing it again writes nothing.
+	sets := c.sets
+	if err := replaceRule(ctx, c, "raw", managedRuleID, nil); err != nil {
+		t.Fatal(err)
+	}
+	if c.sets != sets {
+		t.Errorf("removing an absent rule wrote the lifecycle")
+	}
+}
+
+func TestReplaceRuleWithoutLifecycle(t *testing.T) {
+	ctx := context.Background()
+	c := &fakeLifecycle{}
+	if err := replaceRule(ctx, c, "raw", managedRuleID, nil); err != nil || c.sets != 0 {
+		t.Errorf("removal from an empty lifecycle: err %v, %d writes", err, c.sets)
+	}
+	rule := (bucketLifecycle{exportExpireDays: 2}).exportRule()
+	if err := replaceRule(ctx, c, "processed", exportRuleID, &rule); err != nil {
+		t.Fatal(err)
+	}
+	if ids := c.ruleIDs(); len(ids) != 1 || ids[0] != exportRuleID {
+		t.Errorf("rules = %v", ids)
+	}
+
+	c = &fakeLifecycle{getErr: minio.ErrorResponse{StatusCode: 501, Code: "NotImplemented"}}
+	if err := replaceRule(ctx, c, "raw", managedRuleID, nil); err == nil {
+		t.Error("a store without lifecycle support reported success")
+	} else if !errors.As(err, new(minio.ErrorResponse)) {
+		t.Errorf("err = %v, want the store's error", err)
+	}
+}
+
+func TestExportExpireDays(t *testing.T) {
+	for ttl, want := range map[time.Duration]int{
+		time.Hour:          2,
+		24 * time.Hour:     2,
+		25 * time.Hour:     3,
+		7 * 24 * time.Hour: 8,
+	} {
+		if got := exportExpireDays(ttl); got != want {
+			t.Errorf("exportExpireDays(%s) = %d, want %d", ttl, got, want)
+		}
+	}
+}
@@ -3,15 +3,25 @@ package s3storage
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
+	"log"
+	"mime"
 	"net/url"
+	"os"
+	"strings"
 	"time"
 
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/minio/minio-go/v7/pkg/encrypt"
 
 	"github.com/dharsanguruparan/VaultDrop/internal/config"
+	"github.com/dharsanguruparan/VaultDrop/internal/timing"
 )
 
 // Storage wraps MinIO/S3 interactions for raw and processed artifacts.
@@ -20,14 +30,34 @@ type Storage struct {
 	rawBucket       string
 	processedBucket string
 	region          string
+	partSize        uint64
+	uploadThreads   uint
+	breaker         *breaker
+	tags            map[string]string
+	rawClass        string
+	processedClass  string
+	versioning      bool
+	lifecycle       bucketLifecycle
 }
 
-// New creates a MinIO client from the Config.
+// New creates a MinIO client from the Config. minio retries each failed
+// request with jittered exponential backoff, VAULTDROP_S3_MAX_ATTEMPTS times
+// in all; the circuit breaker then counts what still fails.
 func New(cfg *config.Config) (*Storage, error) {
+	// The attempt count is a package variable in minio; this process talks
+	// to one object store, so setting it here is safe.
+	minio.MaxRetry = cfg.S3MaxAttempts
+	transport, err := minio.DefaultTransport(cfg.S3UseSSL)
+	if err != nil {
+		return nil, fmt.Errorf("init minio transport: %w", err)
+	}
 	client, err := minio.New(cfg.S3Endpoint, &minio.Options{
-		Creds:  credentials.NewStaticV4(cfg.S3AccessKey, cfg.S3SecretKey, ""),
-		Secure: cfg.S3UseSSL,
-		Region: cfg.S3Region,
+		Creds:     credentials.NewStaticV4(cfg.S3AccessKey, cfg.S3SecretKey, ""),
+		Secure:    cfg.S3UseSSL,
+		Region:    cfg.S3Region,
+		Transport: timing.Transport(transport, "s3"),
+		// Parts of multipart uploads carry a CRC32C the store verifies.
+		TrailingHeaders: cfg.S3TrailingChecksums,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("init minio: %w", err)
@@ -37,65 +67,677 @@ func New(cfg *config.Config) (*Storage, error) {
 		rawBucket:       cfg.RawBucket,
 		processedBucket: cfg.ProcessedBucket,
 		region:          cfg.S3Region,
+		partSize:        uint64(cfg.S3PartSize),
+		uploadThreads:   uint(cfg.S3UploadThreads),
+		breaker:         &breaker{threshold: cfg.S3BreakerFailures, cooldown: cfg.S3BreakerCooldown},
+		tags:            cfg.S3ObjectTags,
+		rawClass:        cfg.S3RawStorageClass,
+		processedClass:  cfg.S3ProcessedStorageClass,
+		versioning:      cfg.S3Versioning,
+		lifecycle: bucketLifecycle{
+			rawExpireDays:        cfg.S3RawExpireAfterDays,
+			abortIncompleteDays:  cfg.S3AbortIncompleteAfterDays,
+			noncurrentExpireDays: cfg.S3NoncurrentExpireAfterDays,
+			exportExpireDays:     exportExpireDays(cfg.ExportTTL),
+		},
 	}, nil
 }
 
-// EnsureBuckets makes sure the raw/processed buckets exist before use.
+// EnsureBuckets makes sure the raw/processed buckets exist before use, and
+// applies the configured versioning and lifecycle rules to them. A managed
+// rule left from an earlier configuration is removed once every lifecycle
+// setting is back to zero.
 func (s *Storage) EnsureBuckets(ctx context.Context) error {
 	for _, bucket := range []string{s.rawBucket, s.processedBucket} {
-		exists, err := s.client.BucketExists(ctx, bucket)
+		var exists bool
+		err := s.guard(ctx, func() (err error) {
+			exists, err = s.client.BucketExists(ctx, bucket)
+			return err
+		})
 		if err != nil {
 			return fmt.Errorf("check bucket %s: %w", bucket, err)
 		}
 		if !exists {
-			if err := s.client.MakeBucket(ctx, bucket, minio.MakeBucketOptions{Region: s.region}); err != nil {
+			err := s.guard(ctx, func() error {
+				return s.client.MakeBucket(ctx, bucket, minio.MakeBucketOptions{Region: s.region})
+			})
+			if err != nil {
 				return fmt.Errorf("make bucket %s: %w", bucket, err)
 			}
 		}
+		if s.versioning {
+			if err := s.guard(ctx, func() error { return s.client.EnableVersioning(ctx, bucket) }); err != nil {
+				return fmt.Errorf("enable versioning on %s: %w", bucket, err)
+			}
+		}
+		if rule, ok := s.lifecycle.rule(bucket == s.rawBucket); ok {
+			if err := s.updateLifecycle(ctx, bucket, managedRuleID, &rule); err != nil {
+				return err
+			}
+		} else if err := s.updateLifecycle(ctx, bucket, managedRuleID, nil); err != nil {
+			// Nothing is configured, so a store that cannot say whether an
+			// earlier rule is left only costs a warning.
+			log.Printf("remove lifecycle rule %s from %s: %v", managedRuleID, bucket, err)
+		}
 	}
 	return nil
 }
 
-// UploadRaw uploads the PDF into the raw bucket.
-func (s *Storage) UploadRaw(ctx context.Context, objectKey string, reader io.Reader, size int64, contentType string) error {
-	opts := minio.PutObjectOptions{ContentType: contentType}
-	_, err := s.client.PutObject(ctx, s.rawBucket, objectKey, reader, size, opts)
+// Ping checks that the object store answers and the raw bucket exists.
+func (s *Storage) Ping(ctx context.Context) error {
+	var exists bool
+	err := s.guard(ctx, func() (err error) {
+		exists, err = s.client.BucketExists(ctx, s.rawBucket)
+		return err
+	})
 	if err != nil {
-		return fmt.Errorf("upload raw object: %w", err)
+		return fmt.Errorf("check bucket %s: %w", s.rawBucket, err)
+	}
+	if !exists {
+		return fmt.Errorf("bucket %s does not exist", s.rawBucket)
 	}
 	return nil
 }
 
+// UploadRaw uploads the PDF into the raw bucket. Files larger than one part
+// go up as a multipart upload, several parts at a time when reader is an
+// io.ReaderAt such as the spooled temp file. sha256Hex, when set, is the
+// digest of the file; single-PUT uploads send it for the store to verify,
+// multipart ones rely on per-part checksums. A non-nil dataKey encrypts the
+// object with it (SSE-C); it must then be passed to every read.
+func (s *Storage) UploadRaw(ctx context.Context, objectKey string, reader io.Reader, size int64, contentType, sha256Hex string, dataKey []byte) error {
+	opts, err := s.rawPutOptions(contentType, dataKey)
+	if err != nil {
+		return err
+	}
+	opts.PartSize, opts.NumThreads = s.partSize, s.uploadThreads
+	if sum, err := hex.DecodeString(sha256Hex); err == nil && len(sum) == sha256.Size && size < int64(s.partSize) {
+		opts.UserMetadata = map[string]string{"X-Amz-Checksum-Sha256": base64.StdEncoding.EncodeToString(sum)}
+	}
+	err = s.guard(ctx, func() error {
+		_, err := s.client.PutObject(ctx, s.rawBucket, objectKey, reader, size, opts)
+		return err
+	})
+	if err != nil {
+		return s.abortIncomplete(ctx, s.rawBucket, objectKey, fmt.Errorf("upload raw object: %w", err))
+	}
+	return nil
+}
+
+// UploadRawStream uploads a raw object whose size is not known up front, using
+// a multipart upload that is aborted if reader fails. Parts are sent one at a
+// time: minio buffers each, so the part size bounds the memory a streamed
+// upload holds. The backend must support multipart uploads. dataKey is as for
+// UploadRaw. A failure reading reader, such as a client dropping the request
+// body, does not count against the circuit breaker.
+func (s *Storage) UploadRawStream(ctx context.Context, objectKey string, reader io.Reader, contentType string, dataKey []byte) error {
+	opts, err := s.rawPutOptions(contentType, dataKey)
+	if err != nil {
+		return err
+	}
+	opts.PartSize = s.partSize
+	err = s.guard(ctx, func() error {
+		_, err := s.client.PutObject(ctx, s.rawBucket, objectKey, sourceReader{r: reader}, -1, opts)
+		return err
+	})
+	if err != nil {
+		return s.abortIncomplete(ctx, s.rawBucket, objectKey, fmt.Errorf("stream raw object: %w", err))
+	}
+	return nil
+}
+
+// rawPutOptions carries the configured tags and storage class of raw objects,
+// and the encryption with dataKey when it is not nil.
+func (s *Storage) rawPutOptions(contentType string, dataKey []byte) (minio.PutObjectOptions, error) {
+	sse, err := customerKey(dataKey)
+	if err != nil {
+		return minio.PutObjectOptions{}, err
+	}
+	return minio.PutObjectOptions{ContentType: contentType, UserTags: s.tags, StorageClass: s.rawClass, ServerSideEncryption: sse}, nil
+}
+
+// customerKey turns a document's data key into SSE-C options: the store
+// encrypts the object with the key and keeps only a salted hash of it, so the
+// object cannot be read without the key. A nil key means no encryption.
+func customerKey(dataKey []byte) (encrypt.ServerSide, error) {
+	if dataKey == nil {
+		return nil, nil
+	}
+	sse, err := encrypt.NewSSEC(dataKey)
+	if err != nil {
+		return nil, fmt.Errorf("data key: %w", err)
+	}
+	return sse, nil
+}
+
+// abortTimeout bounds the cleanup of a failed multipart upload.
+const abortTimeout = 30 * time.Second
+
+// abortIncomplete removes the parts a failed upload of objectKey left in
+// bucket and returns err. minio aborts the upload itself, but with the
+// request context, which is gone when a client disconnect or deadline caused
+// the failure; orphaned parts are billed until a lifecycle rule clears them.
+func (s *Storage) abortIncomplete(ctx context.Context, bucket, objectKey string, err error) error {
+	if errors.Is(err, ErrUnavailable) {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), abortTimeout)
+	defer cancel()
+	if abortErr := s.client.RemoveIncompleteUpload(ctx, bucket, objectKey); abortErr != nil {
+		return fmt.Errorf("%w (abort incomplete upload: %v)", err, abortErr)
+	}
+	return err
+}
+
 // UploadProcessed uploads the extracted text output into the processed bucket.
 func (s *Storage) UploadProcessed(ctx context.Context, objectKey string, data []byte) error {
 	reader := bytes.NewReader(data)
-	opts := minio.PutObjectOptions{ContentType: "text/plain; charset=utf-8"}
-	_, err := s.client.PutObject(ctx, s.processedBucket, objectKey, reader, int64(len(data)), opts)
+	opts := minio.PutObjectOptions{ContentType: "text/plain; charset=utf-8", UserTags: s.tags, StorageClass: s.processedClass}
+	err := s.guard(ctx, func() error {
+		_, err := s.client.PutObject(ctx, s.processedBucket, objectKey, reader, int64(len(data)), opts)
+		return err
+	})
 	if err != nil {
 		return fmt.Errorf("upload processed object: %w", err)
 	}
 	return nil
 }
 
-// DownloadRaw fetches the raw PDF bytes from storage.
-func (s *Storage) DownloadRaw(ctx context.Context, objectKey string) ([]byte, error) {
-	obj, err := s.client.GetObject(ctx, s.rawBucket, objectKey, minio.GetObjectOptions{})
+// UploadExport streams a ZIP of unknown size into the processed bucket. A
+// non-nil dataKey encrypts it (SSE-C) like a raw upload; OpenExport then
+// needs it too.
+func (s *Storage) UploadExport(ctx context.Context, objectKey string, reader io.Reader, dataKey []byte) error {
+	sse, err := customerKey(dataKey)
+	if err != nil {
+		return err
+	}
+	opts := minio.PutObjectOptions{ContentType: "application/zip", UserTags: s.tags, PartSize: s.partSize, ServerSideEncryption: sse}
+	err = s.guard(ctx, func() error {
+		_, err := s.client.PutObject(ctx, s.processedBucket, objectKey, sourceReader{r: reader}, -1, opts)
+		return err
+	})
+	if err != nil {
+		return s.abortIncomplete(ctx, s.processedBucket, objectKey, fmt.Errorf("upload export: %w", err))
+	}
+	return nil
+}
+
+// OpenExport opens the export ZIP at objectKey, decrypting it with dataKey
+// when it was uploaded with one.
+func (s *Storage) OpenExport(ctx context.Context, objectKey string, dataKey []byte) (io.ReadCloser, ObjectInfo, error) {
+	sse, err := customerKey(dataKey)
+	if err != nil {
+		return nil, ObjectInfo{}, err
+	}
+	return s.open(ctx, s.processedBucket, objectKey, sse)
+}
+
+// exportPrefix is where export ZIPs live in the processed bucket.
+const exportPrefix = "exports/"
+
+// ExportKey names the ZIP of an export in the processed bucket.
+func ExportKey(exportID string) string {
+	return exportPrefix + exportID + ".zip"
+}
+
+// ErrNotFound is returned when a requested object does not exist.
+var ErrNotFound = errors.New("object not found")
+
+// LayoutKey names the layout-preserving text stored next to the processed
+// text at processedKey.
+func LayoutKey(processedKey string) string {
+	return strings.TrimSuffix(processedKey, ".txt") + ".layout.txt"
+}
+
+// ArchiveKey names the object in the processed bucket that the archive sweep
+// moves a document's text to.
+func ArchiveKey(documentID string) string {
+	return "archive/" + documentID + ".txt"
+}
+
+// VersionArchiveKey names the object the archive sweep moves the text of one
+// version of a document to. They share a prefix per document, see
+// RemoveVersionArchives.
+func VersionArchiveKey(documentID string, version int) string {
+	return fmt.Sprintf("%sv%d.txt", versionArchivePrefix(documentID), version)
+}
+
+// RemoveVersionArchives deletes every archived version text of a document.
+func (s *Storage) RemoveVersionArchives(ctx context.Context, documentID string) error {
+	keys, err := s.keysUnder(ctx, s.processedBucket, versionArchivePrefix(documentID))
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := s.remove(ctx, s.processedBucket, key); err != nil {
+			return fmt.Errorf("remove version archive: %w", err)
+		}
+	}
+	return nil
+}
+
+// VersionArchivesRetained reports whether any archived version text of a
+// document, or on a versioned bucket any version of one, is still stored.
+func (s *Storage) VersionArchivesRetained(ctx context.Context, documentID string) (bool, error) {
+	keys, err := s.keysUnder(ctx, s.processedBucket, versionArchivePrefix(documentID))
+	return len(keys) > 0, err
+}
+
+func versionArchivePrefix(documentID string) string {
+	return "archive/" + documentID + "/"
+}
+
+// keysUnder lists the keys under prefix, including on a versioned bucket
+// those left with only noncurrent versions or delete markers.
+func (s *Storage) keysUnder(ctx context.Context, bucket, prefix string) ([]string, error) {
+	var keys []string
+	err := s.guard(ctx, func() error {
+		// Cancelling stops minio's listing goroutine on an error.
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		opts := minio.ListObjectsOptions{Prefix: prefix, Recursive: true, WithVersions: s.versioning}
+		for obj := range s.client.ListObjects(ctx, bucket, opts) {
+			if obj.Err != nil {
+				return obj.Err
+			}
+			if len(keys) == 0 || keys[len(keys)-1] != obj.Key {
+				keys = append(keys, obj.Key)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list %s/%s: %w", bucket, prefix, err)
+	}
+	return keys, nil
+}
+
+// GetProcessed reads a processed object into memory. It returns ErrNotFound
+// when the object does not exist.
+func (s *Storage) GetProcessed(ctx context.Context, objectKey string) ([]byte, error) {
+	obj, _, err := s.OpenProcessed(ctx, objectKey)
+	if err != nil {
+		return nil, err
+	}
+	defer obj.Close()
+	data, err := io.ReadAll(obj)
+	if err != nil {
+		return nil, fmt.Errorf("read processed object: %w", err)
+	}
+	return data, nil
+}
+
+// ObjectInfo describes an object opened for streaming.
+type ObjectInfo struct {
+	Size         int64
+	ContentType  string
+	ETag         string
+	LastModified time.Time
+}
+
+// OpenRaw opens a raw object for streaming. The caller must Close the reader.
+// It returns ErrNotFound when the object does not exist. dataKey is the key
+// the object was uploaded with, or nil.
+func (s *Storage) OpenRaw(ctx context.Context, objectKey string, dataKey []byte) (io.ReadCloser, ObjectInfo, error) {
+	sse, err := customerKey(dataKey)
+	if err != nil {
+		return nil, ObjectInfo{}, err
+	}
+	return s.open(ctx, s.rawBucket, objectKey, sse)
+}
+
+// OpenProcessed is OpenRaw for the processed bucket, whose objects are not
+// encrypted with data keys.
+func (s *Storage) OpenProcessed(ctx context.Context, objectKey string) (io.ReadCloser, ObjectInfo, error) {
+	return s.open(ctx, s.processedBucket, objectKey, nil)
+}
+
+// open starts a GET for the object. minio issues the request lazily, so the
+// object is stat'ed first to surface a missing key here rather than on the
+// caller's first Read.
+func (s *Storage) open(ctx context.Context, bucket, objectKey string, sse encrypt.ServerSide) (io.ReadCloser, ObjectInfo, error) {
+	var (
+		obj  *minio.Object
+		stat minio.ObjectInfo
+	)
+	err := s.guard(ctx, func() (err error) {
+		if obj, err = s.client.GetObject(ctx, bucket, objectKey, minio.GetObjectOptions{ServerSideEncryption: sse}); err != nil {
+			return err
+		}
+		if stat, err = obj.Stat(); err != nil {
+			obj.Close()
+		}
+		return err
+	})
+	if err != nil {
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return nil, ObjectInfo{}, ErrNotFound
+		}
+		return nil, ObjectInfo{}, fmt.Errorf("get object %s/%s: %w", bucket, objectKey, err)
+	}
+	return obj, ObjectInfo{
+		Size:         stat.Size,
+		ContentType:  stat.ContentType,
+		ETag:         stat.ETag,
+		LastModified: stat.LastModified,
+	}, nil
+}
+
+// ErrCorrupt marks a downloaded object whose content does not match the
+// checksum recorded when it was uploaded.
+var ErrCorrupt = errors.New("corruption detected")
+
+// SpooledObject is a downloaded object held in a temp file. It reads like the
+// file; Close also removes it.
+type SpooledObject struct {
+	*os.File
+	Size int64
+	// SHA256 is the hex digest of the downloaded content.
+	SHA256 string
+}
+
+// Verify returns ErrCorrupt unless the content hashes to sha256Hex. An empty
+// sha256Hex, for objects uploaded without one, always verifies.
+func (o *SpooledObject) Verify(sha256Hex string) error {
+	if sha256Hex == "" || strings.EqualFold(o.SHA256, sha256Hex) {
+		return nil
+	}
+	return fmt.Errorf("%w: downloaded sha256 %s, uploaded as %s", ErrCorrupt, o.SHA256, sha256Hex)
+}
+
+// Close closes and deletes the temp file.
+func (o *SpooledObject) Close() error {
+	err := o.File.Close()
+	if rmErr := os.Remove(o.Name()); rmErr != nil && err == nil {
+		err = rmErr
+	}
+	return err
+}
+
+// SpoolRaw downloads a raw object into a temp file, so large PDFs can be read
+// at random offsets without holding them in memory. The caller must Close it.
+// dataKey is as for OpenRaw.
+func (s *Storage) SpoolRaw(ctx context.Context, objectKey string, dataKey []byte) (*SpooledObject, error) {
+	obj, _, err := s.OpenRaw(ctx, objectKey, dataKey)
 	if err != nil {
-		return nil, fmt.Errorf("get raw object: %w", err)
+		return nil, err
 	}
 	defer obj.Close()
-	buf, err := io.ReadAll(obj)
+	f, err := os.CreateTemp("", "vaultdrop-raw-*.pdf")
 	if err != nil {
+		return nil, fmt.Errorf("create spool file: %w", err)
+	}
+	spooled := &SpooledObject{File: f}
+	digest := sha256.New()
+	spooled.Size, err = io.Copy(io.MultiWriter(f, digest), obj)
+	spooled.SHA256 = hex.EncodeToString(digest.Sum(nil))
+	if err == nil {
+		_, err = f.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		spooled.Close()
 		return nil, fmt.Errorf("read raw object: %w", err)
 	}
-	return buf, nil
+	return spooled, nil
 }
 
 // PresignProcessedURL returns a signed GET URL for the processed text file.
 func (s *Storage) PresignProcessedURL(ctx context.Context, objectKey string, expirySeconds int64) (string, error) {
-	u, err := s.client.PresignedGetObject(ctx, s.processedBucket, objectKey, time.Duration(expirySeconds)*time.Second, url.Values{})
+	var u *url.URL
+	err := s.guard(ctx, func() (err error) {
+		u, err = s.client.PresignedGetObject(ctx, s.processedBucket, objectKey, time.Duration(expirySeconds)*time.Second, url.Values{})
+		return err
+	})
 	if err != nil {
 		return "", fmt.Errorf("presign processed object: %w", err)
 	}
 	return u.String(), nil
 }
+
+// PresignRawURL returns a presigned GET for the original upload. The response
+// is served as an attachment named fileName, so browsers save it under the
+// name it was uploaded with rather than the object key. Objects encrypted with
+// a data key cannot be fetched this way: the GET must carry the key.
+func (s *Storage) PresignRawURL(ctx context.Context, objectKey, fileName string, expiry time.Duration) (string, error) {
+	params := url.Values{}
+	params.Set("response-content-disposition", mime.FormatMediaType("attachment", map[string]string{"filename": fileName}))
+	var u *url.URL
+	err := s.guard(ctx, func() (err error) {
+		u, err = s.client.PresignedGetObject(ctx, s.rawBucket, objectKey, expiry, params)
+		return err
+	})
+	if err != nil {
+		return "", fmt.Errorf("presign raw object: %w", err)
+	}
+	return u.String(), nil
+}
+
+// PresignExportURL returns a presigned download of the export ZIP at
+// objectKey, saved under fileName.
+func (s *Storage) PresignExportURL(ctx context.Context, objectKey, fileName string, expiry time.Duration) (string, error) {
+	params := url.Values{}
+	params.Set("response-content-disposition", mime.FormatMediaType("attachment", map[string]string{"filename": fileName}))
+	var u *url.URL
+	err := s.guard(ctx, func() (err error) {
+		u, err = s.client.PresignedGetObject(ctx, s.processedBucket, objectKey, expiry, params)
+		return err
+	})
+	if err != nil {
+		return "", fmt.Errorf("presign export: %w", err)
+	}
+	return u.String(), nil
+}
+
+// RemoveRaw deletes the original upload from the raw bucket.
+func (s *Storage) RemoveRaw(ctx context.Context, objectKey string) error {
+	if err := s.remove(ctx, s.rawBucket, objectKey); err != nil {
+		return fmt.Errorf("remove raw object: %w", err)
+	}
+	return nil
+}
+
+// RemoveProcessed deletes the extracted text artifact from the processed
+// bucket, along with its layout-preserving variant if there is one.
+func (s *Storage) RemoveProcessed(ctx context.Context, objectKey string) error {
+	for _, key := range []string{objectKey, LayoutKey(objectKey)} {
+		if err := s.remove(ctx, s.processedBucket, key); err != nil {
+			return fmt.Errorf("remove processed object: %w", err)
+		}
+	}
+	return nil
+}
+
+// RemoveArchive deletes an archived text object from the processed bucket.
+func (s *Storage) RemoveArchive(ctx context.Context, objectKey string) error {
+	if err := s.remove(ctx, s.processedBucket, objectKey); err != nil {
+		return fmt.Errorf("remove archive object: %w", err)
+	}
+	return nil
+}
+
+// RemoveProcessedObject deletes one object from the processed bucket, be it
+// text, layout or archive, for callers that found it by listing.
+func (s *Storage) RemoveProcessedObject(ctx context.Context, objectKey string) error {
+	if err := s.remove(ctx, s.processedBucket, objectKey); err != nil {
+		return fmt.Errorf("remove processed object: %w", err)
+	}
+	return nil
+}
+
+// Object names an object in a bucket, for copies that may cross buckets.
+type Object struct {
+	Bucket string
+	Key    string
+}
+
+// RawObject returns the raw-bucket object at key.
+func (s *Storage) RawObject(key string) Object {
+	return Object{Bucket: s.rawBucket, Key: key}
+}
+
+// ProcessedObject returns the processed-bucket object at key.
+func (s *Storage) ProcessedObject(key string) Object {
+	return Object{Bucket: s.processedBucket, Key: key}
+}
+
+// Copy copies src to dst with S3 CopyObject, so the data stays in the object
+// store. Content type and metadata are kept. S3 copies at most 5 GiB in one
+// request, far above any upload limit. An object encrypted with a data key
+// needs that key to be read and stays encrypted with it; a nil key copies
+// plain objects. It returns ErrNotFound when src does not exist.
+func (s *Storage) Copy(ctx context.Context, dst, src Object, dataKey []byte) error {
+	sse, err := customerKey(dataKey)
+	if err != nil {
+		return err
+	}
+	err = s.guard(ctx, func() error {
+		_, err := s.client.CopyObject(ctx,
+			minio.CopyDestOptions{Bucket: dst.Bucket, Object: dst.Key, Encryption: sse},
+			minio.CopySrcOptions{Bucket: src.Bucket, Object: src.Key, Encryption: sse})
+		return err
+	})
+	if err != nil {
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return ErrNotFound
+		}
+		return fmt.Errorf("copy %s/%s to %s/%s: %w", src.Bucket, src.Key, dst.Bucket, dst.Key, err)
+	}
+	return nil
+}
+
+// Move copies src to dst, encrypted with dataKey as for Copy, and then
+// removes src. When the removal fails the object exists in both places, and
+// the error says so; retrying is safe.
+func (s *Storage) Move(ctx context.Context, dst, src Object, dataKey []byte) error {
+	if dst == src {
+		return nil
+	}
+	if err := s.Copy(ctx, dst, src, dataKey); err != nil {
+		return err
+	}
+	if err := s.remove(ctx, src.Bucket, src.Key); err != nil {
+		return fmt.Errorf("remove %s/%s after copying it to %s/%s: %w", src.Bucket, src.Key, dst.Bucket, dst.Key, err)
+	}
+	return nil
+}
+
+// remove deletes the object. On a versioned bucket a plain delete only adds a
+// delete marker and keeps the bytes, so every version of the key is deleted
+// instead, markers included.
+func (s *Storage) remove(ctx context.Context, bucket, objectKey string) error {
+	if !s.versioning {
+		return s.guard(ctx, func() error {
+			return s.client.RemoveObject(ctx, bucket, objectKey, minio.RemoveObjectOptions{})
+		})
+	}
+	versions, err := s.versions(ctx, bucket, objectKey)
+	if err != nil {
+		return err
+	}
+	for _, version := range versions {
+		err := s.guard(ctx, func() error {
+			return s.client.RemoveObject(ctx, bucket, objectKey, minio.RemoveObjectOptions{VersionID: version})
+		})
+		if err != nil {
+			return fmt.Errorf("remove version %s: %w", version, err)
+		}
+	}
+	return nil
+}
+
+// versions lists the version ids stored under exactly objectKey, delete
+// markers included.
+func (s *Storage) versions(ctx context.Context, bucket, objectKey string) ([]string, error) {
+	var versions []string
+	err := s.guard(ctx, func() error {
+		// Cancelling stops minio's listing goroutine on an error.
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		for obj := range s.client.ListObjects(ctx, bucket, minio.ListObjectsOptions{Prefix: objectKey, WithVersions: true}) {
+			if obj.Err != nil {
+				return obj.Err
+			}
+			if obj.Key == objectKey {
+				versions = append(versions, obj.VersionID)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list versions of %s/%s: %w", bucket, objectKey, err)
+	}
+	return versions, nil
+}
+
+// ListedObject is an object found by ListRaw or ListProcessed.
+type ListedObject struct {
+	Key          string
+	Size         int64
+	LastModified time.Time
+}
+
+// ListRaw calls fn for every raw object under prefix, in key order, and stops
+// at the first error fn returns.
+func (s *Storage) ListRaw(ctx context.Context, prefix string, fn func(ListedObject) error) error {
+	return s.list(ctx, s.rawBucket, prefix, fn)
+}
+
+// ListProcessed is ListRaw for the processed bucket.
+func (s *Storage) ListProcessed(ctx context.Context, prefix string, fn func(ListedObject) error) error {
+	return s.list(ctx, s.processedBucket, prefix, fn)
+}
+
+func (s *Storage) list(ctx context.Context, bucket, prefix string, fn func(ListedObject) error) error {
+	if err := s.breaker.allow(); err != nil {
+		return err
+	}
+	// Cancelling stops minio's listing goroutine when fn gives up early.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	for obj := range s.client.ListObjects(ctx, bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if obj.Err != nil {
+			s.breaker.record(ctx, obj.Err)
+			return fmt.Errorf("list %s/%s: %w", bucket, prefix, obj.Err)
+		}
+		if err := fn(ListedObject{Key: obj.Key, Size: obj.Size, LastModified: obj.LastModified}); err != nil {
+			s.breaker.record(ctx, nil)
+			return err
+		}
+	}
+	s.breaker.record(ctx, nil)
+	return nil
+}
+
+// RawRetained reports whether any bytes of the raw object are left: the
+// object or, on a versioned bucket, any version of it. Erasure verifies with
+// it.
+func (s *Storage) RawRetained(ctx context.Context, objectKey string) (bool, error) {
+	return s.retained(ctx, s.rawBucket, objectKey)
+}
+
+// ProcessedRetained is RawRetained for the processed bucket.
+func (s *Storage) ProcessedRetained(ctx context.Context, objectKey string) (bool, error) {
+	return s.retained(ctx, s.processedBucket, objectKey)
+}
+
+func (s *Storage) retained(ctx context.Context, bucket, objectKey string) (bool, error) {
+	if !s.versioning {
+		return s.exists(ctx, bucket, objectKey)
+	}
+	versions, err := s.versions(ctx, bucket, objectKey)
+	return len(versions) > 0, err
+}
+
+func (s *Storage) exists(ctx context.Context, bucket, objectKey string) (bool, error) {
+	err := s.guard(ctx, func() error {
+		_, err := s.client.StatObject(ctx, bucket, objectKey, minio.StatObjectOptions{})
+		return err
+	})
+	if err == nil {
+		return true, nil
+	}
+	if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+		return false, nil
+	}
+	return false, fmt.Errorf("stat object %s/%s: %w", bucket, objectKey, err)
+}
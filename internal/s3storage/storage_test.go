@@ -0,0 +1,167 @@
+package s3storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// fakeS3 answers object copies and deletes, recording each request. Copies
+// from a key named missing answer NoSuchKey, and deletes answer deleteStatus
+// when it is set.
+type fakeS3 struct {
+	mu           sync.Mutex
+	requests     []*http.Request
+	deleteStatus int
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	f.requests = append(f.requests, r.Clone(context.Background()))
+	deleteStatus := f.deleteStatus
+	f.mu.Unlock()
+	switch {
+	case r.Method == http.MethodPut && r.Header.Get("X-Amz-Copy-Source") != "":
+		if strings.HasSuffix(r.Header.Get("X-Amz-Copy-Source"), "/missing") {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`<Error><Code>NoSuchKey</Code><Message>The specified key does not exist.</Message></Error>`))
+			return
+		}
+		w.Write([]byte(`<CopyObjectResult><ETag>"abc"</ETag><LastModified>2026-01-01T00:00:00.000Z</LastModified></CopyObjectResult>`))
+	case r.Method == http.MethodDelete:
+		if deleteStatus != 0 {
+			w.WriteHeader(deleteStatus)
+			w.Write([]byte(`<Error><Code>AccessDenied</Code><Message>Access Denied.</Message></Error>`))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotImplemented)
+	}
+}
+
+// summary lists the recorded requests as "METHOD /bucket/key".
+func (f *fakeS3) summary() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []string
+	for _, r := range f.requests {
+		out = append(out, r.Method+" "+r.URL.Path)
+	}
+	return out
+}
+
+func newFakeStorage(t *testing.T, fake *fakeS3) *Storage {
+	t.Helper()
+	// SSE-C keys may only be sent over TLS.
+	srv := httptest.NewTLSServer(fake)
+	t.Cleanup(srv.Close)
+	client, err := minio.New(strings.TrimPrefix(srv.URL, "https://"), &minio.Options{
+		Creds:     credentials.NewStaticV4("access", "secret", ""),
+		Secure:    true,
+		Region:    "us-east-1",
+		Transport: srv.Client().Transport,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Storage{client: client, rawBucket: "raw", processedBucket: "processed"}
+}
+
+func TestCopyWithDataKey(t *testing.T) {
+	fake := &fakeS3{}
+	s := newFakeStorage(t, fake)
+	key := bytes.Repeat([]byte{7}, 32)
+	sum := md5.Sum(key)
+	wantKey, wantMD5 := base64.StdEncoding.EncodeToString(key), base64.StdEncoding.EncodeToString(sum[:])
+
+	if err := s.Copy(context.Background(), s.ProcessedObject("archive/a.pdf"), s.RawObject("uploads/a.pdf"), key); err != nil {
+		t.Fatalf("Copy: %v", err)
+	}
+	if got := fake.summary(); len(got) != 1 || got[0] != "PUT /processed/archive/a.pdf" {
+		t.Fatalf("requests = %q", got)
+	}
+	h := fake.requests[0].Header
+	if src := h.Get("X-Amz-Copy-Source"); src != "raw/uploads/a.pdf" {
+		t.Errorf("copy source = %q", src)
+	}
+	// The source key reads the object and the destination key encrypts the
+	// copy; both are the document's data key.
+	for _, prefix := range []string{"X-Amz-Copy-Source-Server-Side-Encryption-Customer-", "X-Amz-Server-Side-Encryption-Customer-"} {
+		if got := h.Get(prefix + "Algorithm"); got != "AES256" {
+			t.Errorf("%sAlgorithm = %q", prefix, got)
+		}
+		if got := h.Get(prefix + "Key"); got != wantKey {
+			t.Errorf("%sKey = %q, want the data key", prefix, got)
+		}
+		if got := h.Get(prefix + "Key-Md5"); got != wantMD5 {
+			t.Errorf("%sKey-MD5 = %q, want %q", prefix, got, wantMD5)
+		}
+	}
+}
+
+func TestCopyPlain(t *testing.T) {
+	fake := &fakeS3{}
+	s := newFakeStorage(t, fake)
+	if err := s.Copy(context.Background(), s.RawObject("b.pdf"), s.RawObject("a.pdf"), nil); err != nil {
+		t.Fatalf("Copy: %v", err)
+	}
+	for name := range fake.requests[0].Header {
+		if strings.Contains(strings.ToLower(name), "customer") {
+			t.Errorf("plain copy sent %s", name)
+		}
+	}
+	if err := s.Copy(context.Background(), s.RawObject("b.pdf"), s.RawObject("missing"), nil); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Copy of a missing object = %v, want ErrNotFound", err)
+	}
+}
+
+func TestMove(t *testing.T) {
+	ctx := context.Background()
+	key := bytes.Repeat([]byte{9}, 32)
+
+	fake := &fakeS3{}
+	s := newFakeStorage(t, fake)
+	if err := s.Move(ctx, s.ProcessedObject("b.pdf"), s.RawObject("a.pdf"), key); err != nil {
+		t.Fatalf("Move: %v", err)
+	}
+	want := []string{"PUT /processed/b.pdf", "DELETE /raw/a.pdf"}
+	if got := fake.summary(); strings.Join(got, ", ") != strings.Join(want, ", ") {
+		t.Errorf("requests = %q, want %q: the source goes only after the copy", got, want)
+	}
+
+	// A failed copy leaves the source alone.
+	fake = &fakeS3{}
+	s = newFakeStorage(t, fake)
+	if err := s.Move(ctx, s.RawObject("b.pdf"), s.RawObject("missing"), nil); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Move of a missing object = %v, want ErrNotFound", err)
+	}
+	if got := fake.summary(); len(got) != 1 {
+		t.Errorf("requests = %q, want the copy only", got)
+	}
+
+	// A failed delete reports both copies.
+	fake = &fakeS3{deleteStatus: http.StatusForbidden}
+	s = newFakeStorage(t, fake)
+	err := s.Move(ctx, s.RawObject("b.pdf"), s.RawObject("a.pdf"), nil)
+	if err == nil || !strings.Contains(err.Error(), "after copying it to raw/b.pdf") {
+		t.Errorf("Move with a failing delete = %v", err)
+	}
+
+	// Moving an object onto itself does nothing.
+	fake = &fakeS3{}
+	s = newFakeStorage(t, fake)
+	if err := s.Move(ctx, s.RawObject("a.pdf"), s.RawObject("a.pdf"), nil); err != nil || len(fake.summary()) != 0 {
+		t.Errorf("Move onto itself = %v, requests %q", err, fake.summary())
+	}
+}
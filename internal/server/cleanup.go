@@ -0,0 +1,94 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/processing"
+	"github.com/dharsanguruparan/VaultDrop/internal/storage"
+)
+
+// cleanupLoop runs cleanUploadDir every CleanupInterval until ctx is done.
+func (s *Server) cleanupLoop(ctx context.Context) {
+	ticker := time.NewTicker(s.cfg.CleanupInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		files, size, err := s.cleanUploadDir(time.Now().Add(-s.cfg.CleanupGrace), s.cfg.CleanupDryRun)
+		if err != nil {
+			log.Printf("cleanup: upload dir: %v", err)
+		}
+		if files > 0 {
+			verb := "removed"
+			if s.cfg.CleanupDryRun {
+				verb = "would remove"
+			}
+			log.Printf("cleanup: %s %d files, %d bytes, from %s that no record refers to", verb, files, size, s.uploadDir)
+		}
+	}
+}
+
+// cleanUploadDir removes files in uploadDir last modified before before that
+// are neither a record's upload nor its thumbnail: parts of failed uploads,
+// and files of records lost with an unsnapshotted memory store. Uploads in
+// flight have no record yet but are younger than the grace period. It
+// returns how many files it removed and their size.
+func (s *Server) cleanUploadDir(before time.Time, dryRun bool) (int, int64, error) {
+	known := make(map[string]bool)
+	// The snapshot and journal may have been pointed into uploadDir too.
+	for _, path := range []string{s.cfg.SnapshotPath, s.cfg.ProcessingJournal} {
+		if path != "" {
+			known[filepath.Clean(path)] = true
+		}
+	}
+	filter := storage.ListFilter{Limit: 500}
+	for {
+		page, err := s.store.List(filter)
+		if err != nil {
+			return 0, 0, err
+		}
+		for i := range page.Files {
+			known[page.Files[i].Path] = true
+			known[processing.ThumbnailPath(&page.Files[i])] = true
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		filter.Cursor = page.NextCursor
+	}
+	entries, err := os.ReadDir(s.uploadDir)
+	if err != nil {
+		return 0, 0, err
+	}
+	var (
+		files int
+		size  int64
+	)
+	for _, entry := range entries {
+		path := filepath.Join(s.uploadDir, entry.Name())
+		if known[path] || !entry.Type().IsRegular() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || !info.ModTime().Before(before) {
+			continue
+		}
+		if !dryRun {
+			if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				log.Printf("cleanup: %v", err)
+				continue
+			}
+		}
+		files++
+		size += info.Size()
+	}
+	return files, size, nil
+}
@@ -0,0 +1,69 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/config"
+	"github.com/dharsanguruparan/VaultDrop/internal/model"
+	"github.com/dharsanguruparan/VaultDrop/internal/processing"
+	"github.com/dharsanguruparan/VaultDrop/internal/storage"
+)
+
+func TestCleanUploadDir(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	old := now.Add(-2 * time.Hour)
+	store := storage.NewMemoryStore()
+	s := &Server{cfg: &config.Config{SnapshotPath: filepath.Join(dir, "snapshot.json")}, store: store, uploadDir: dir}
+
+	write := func(name string, mtime time.Time) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte("1234"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	rec := &model.FileRecord{ID: "a", Path: write("a.pdf", old), Status: model.StatusComplete}
+	write(filepath.Base(processing.ThumbnailPath(rec)), old)
+	if err := store.Save(rec); err != nil {
+		t.Fatal(err)
+	}
+	write("snapshot.json", old)
+	write("lost.pdf", old)     // its record is gone
+	write("partial.part", old) // a failed upload
+	write("inflight.pdf", now) // an upload without a record yet
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	before := now.Add(-time.Hour)
+
+	files, size, err := s.cleanUploadDir(before, true)
+	if err != nil || files != 2 || size != 8 {
+		t.Fatalf("dry run = %d files, %d bytes, %v", files, size, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "lost.pdf")); err != nil {
+		t.Errorf("dry run removed a file: %v", err)
+	}
+
+	files, size, err = s.cleanUploadDir(before, false)
+	if err != nil || files != 2 || size != 8 {
+		t.Fatalf("cleanUploadDir = %d files, %d bytes, %v", files, size, err)
+	}
+	entries, _ := os.ReadDir(dir)
+	var left []string
+	for _, e := range entries {
+		left = append(left, e.Name())
+	}
+	sort.Strings(left)
+	if want := "a.pdf a.pdf.thumb.png inflight.pdf snapshot.json sub"; strings.Join(left, " ") != want {
+		t.Errorf("left %v, want %s", left, want)
+	}
+}
@@ -0,0 +1,36 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/httperr"
+	"github.com/dharsanguruparan/VaultDrop/internal/model"
+	"github.com/dharsanguruparan/VaultDrop/internal/storage"
+)
+
+// handleDeleteFile serves DELETE /files/{id}: it forgets the record and
+// removes the upload and any thumbnail from uploadDir.
+func (s *Server) handleDeleteFile(w http.ResponseWriter, r *http.Request, id string) {
+	record, err := s.store.Get(id)
+	if errors.Is(err, storage.ErrNotFound) {
+		httperr.Error(w, "file not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		httperr.Internal(w, "failed to load file")
+		return
+	}
+	// A running stage still has the file open. Queued and retrying jobs
+	// notice the missing record when their turn comes and drop themselves.
+	if record.Status == model.StatusProcessing {
+		httperr.Error(w, "file is being processed", http.StatusConflict)
+		return
+	}
+	if err := s.store.Delete(id); err != nil {
+		httperr.Internal(w, "failed to delete file")
+		return
+	}
+	removeUpload(*record)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,59 @@
+package server
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/httperr"
+	"github.com/dharsanguruparan/VaultDrop/internal/model"
+)
+
+// contentETag returns a strong ETag for the file behind record. It reuses
+// the checksum stage's hash and otherwise hashes the file once, saving the
+// result so later downloads skip the work.
+func (s *Server) contentETag(record *model.FileRecord) (string, error) {
+	sum := record.Attributes["sha256"]
+	if sum == "" {
+		f, err := os.Open(record.Path)
+		if err != nil {
+			return "", err
+		}
+		defer f.Close()
+		h := sha256.New()
+		if _, err := io.Copy(h, f); err != nil {
+			return "", err
+		}
+		sum = hex.EncodeToString(h.Sum(nil))
+		if err := s.store.SetAttributes(record.ID, map[string]string{"sha256": sum}); err != nil {
+			log.Printf("save checksum for %s: %v", record.ID, err)
+		}
+	}
+	return `"` + sum + `"`, nil
+}
+
+// respondCachedJSON writes payload like respondJSON but with an ETag of the
+// encoded body and Last-Modified of modified. http.ServeContent then answers
+// If-None-Match and If-Modified-Since with 304 for clients that poll.
+func respondCachedJSON(w http.ResponseWriter, r *http.Request, modified time.Time, payload interface{}) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(payload); err != nil {
+		log.Printf("encode json failed: %v", err)
+		httperr.Internal(w, "failed to encode response")
+		return
+	}
+	sum := sha256.Sum256(buf.Bytes())
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+	w.Header().Set("Content-Type", "application/json")
+	// Clients may keep the response but must revalidate before reusing it.
+	w.Header().Set("Cache-Control", "no-cache")
+	http.ServeContent(w, r, "", modified, bytes.NewReader(buf.Bytes()))
+}
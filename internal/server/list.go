@@ -0,0 +1,94 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/httperr"
+	"github.com/dharsanguruparan/VaultDrop/internal/model"
+	"github.com/dharsanguruparan/VaultDrop/internal/storage"
+)
+
+// handleListFiles serves GET /files?status=&prefix=&order=&limit=&offset=&cursor=.
+// Records never include server-side paths.
+func (s *Server) handleListFiles(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httperr.MethodNotAllowed(w)
+		return
+	}
+	q := r.URL.Query()
+	filter := storage.ListFilter{
+		NamePrefix: q.Get("prefix"),
+		Cursor:     q.Get("cursor"),
+	}
+	if raw := q.Get("status"); raw != "" {
+		for _, status := range strings.Split(raw, ",") {
+			st := model.FileStatus(strings.TrimSpace(status))
+			if !knownStatus(st) {
+				httperr.BadRequest(w, "invalid status")
+				return
+			}
+			filter.Statuses = append(filter.Statuses, st)
+		}
+	}
+	switch q.Get("order") {
+	case "", "desc":
+	case "asc":
+		filter.Ascending = true
+	default:
+		httperr.BadRequest(w, "invalid order")
+		return
+	}
+	if raw := q.Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			httperr.BadRequest(w, "invalid limit")
+			return
+		}
+		filter.Limit = n
+	}
+	if raw := q.Get("offset"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			httperr.BadRequest(w, "invalid offset")
+			return
+		}
+		filter.Offset = n
+	}
+	page, err := s.store.List(filter)
+	if err != nil {
+		if errors.Is(err, storage.ErrInvalidCursor) {
+			httperr.Write(w, http.StatusBadRequest, httperr.CodeInvalidCursor, "invalid cursor", nil)
+			return
+		}
+		httperr.Internal(w, "failed to list files")
+		return
+	}
+	respondJSON(w, http.StatusOK, page)
+}
+
+// handleFileCounts serves GET /files/counts with the number of files in each
+// status.
+func (s *Server) handleFileCounts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httperr.MethodNotAllowed(w)
+		return
+	}
+	counts, err := s.store.CountByStatus()
+	if err != nil {
+		httperr.Internal(w, "failed to count files")
+		return
+	}
+	respondJSON(w, http.StatusOK, counts)
+}
+
+func knownStatus(st model.FileStatus) bool {
+	for _, s := range model.Statuses {
+		if s == st {
+			return true
+		}
+	}
+	return false
+}
@@ -22,18 +22,27 @@ import (
 	"time"
 
 	"github.com/dharsanguruparan/VaultDrop/internal/config"
+	"github.com/dharsanguruparan/VaultDrop/internal/httperr"
+	"github.com/dharsanguruparan/VaultDrop/internal/metadata"
 	"github.com/dharsanguruparan/VaultDrop/internal/model"
 	"github.com/dharsanguruparan/VaultDrop/internal/processing"
 	"github.com/dharsanguruparan/VaultDrop/internal/signing"
 	"github.com/dharsanguruparan/VaultDrop/internal/storage"
+	"github.com/dharsanguruparan/VaultDrop/internal/tlsserve"
 )
 
+// queueFullRetryAfter is the Retry-After hint sent when uploads are refused
+// because the processing queue is full.
+const queueFullRetryAfter = 5 * time.Second
+
 // Server hosts HTTP handlers for VaultDrop. It stitches together configuration,
 // storage, background processing, and signing helpers. Struct embedding is not
 // needed here; fields are explicitly referenced for clarity.
 type Server struct {
-	cfg       *config.Config
-	store     *storage.MemoryStore
+	cfg   *config.Config
+	store storage.FileStore
+	// meta is store seen through the status summary both stacks share.
+	meta      metadata.Store
 	processor *processing.Processor
 	signer    *signing.Signer
 	uploadDir string
@@ -43,31 +52,45 @@ type Server struct {
 // New creates a configured server. In Go it's conventional to return (*Type,
 // error) so callers can handle initialization failures (e.g., inability to
 // create the upload directory).
-func New(cfg *config.Config, store *storage.MemoryStore, processor *processing.Processor, signer *signing.Signer) (*Server, error) {
+func New(cfg *config.Config, store storage.FileStore, processor *processing.Processor, signer *signing.Signer) (*Server, error) {
 	dir := filepath.Join(os.TempDir(), "vaultdrop")
 	if err := os.MkdirAll(dir, 0o750); err != nil {
 		return nil, err
 	}
+	// Records evicted to respect the store's limits take their files along.
+	if evicting, ok := store.(interface{ OnEvict(func(model.FileRecord)) }); ok {
+		evicting.OnEvict(removeUpload)
+	}
 	return &Server{
 		cfg:       cfg,
 		store:     store,
+		meta:      metadata.FromFileStore(store),
 		processor: processor,
 		signer:    signer,
 		uploadDir: dir,
 	}, nil
 }
 
+// removeUpload deletes the files stored for rec.
+func removeUpload(rec model.FileRecord) {
+	for _, path := range []string{rec.Path, processing.ThumbnailPath(&rec)} {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Printf("remove %s: %v", path, err)
+		}
+	}
+}
+
 // Serve launches the HTTP server until the context is cancelled.
 func (s *Server) Serve(ctx context.Context) error {
 	s.once.Do(func() {
 		// sync.Once ensures we only start the background workers once even if
 		// Serve is called multiple times in tests.
 		s.processor.Start(ctx)
+		if s.cfg.CleanupInterval > 0 {
+			go s.cleanupLoop(ctx)
+		}
 	})
-	httpServer := &http.Server{
-		Addr:    s.cfg.Address,
-		Handler: s.routes(),
-	}
+	httpServer := s.cfg.HTTPServer(s.cfg.Address, s.routes())
 	go func() {
 		<-ctx.Done()
 		// When the context is cancelled we gracefully shutdown with a timeout.
@@ -75,7 +98,7 @@ func (s *Server) Serve(ctx context.Context) error {
 		defer cancel()
 		_ = httpServer.Shutdown(shutdownCtx)
 	}()
-	if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+	if err := tlsserve.ListenAndServe(ctx, httpServer, s.cfg); err != nil && !errors.Is(err, http.ErrServerClosed) {
 		return err
 	}
 	return nil
@@ -87,8 +110,9 @@ func (s *Server) routes() http.Handler {
 	mux.HandleFunc("/healthz", s.handleHealth)
 	mux.HandleFunc("/upload", s.handleUpload)
 	mux.HandleFunc("/download", s.handleDownload)
+	mux.HandleFunc("/files", s.handleListFiles)
 	mux.HandleFunc("/files/", s.handleFileRoute)
-	return mux
+	return httperr.RequestID(mux)
 }
 
 func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
@@ -98,7 +122,7 @@ func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 
 func (s *Server) handleUpload(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		httperr.MethodNotAllowed(w)
 		return
 	}
 	// http.MaxBytesReader wraps the Body to protect against oversized payloads.
@@ -107,7 +131,7 @@ func (s *Server) handleUpload(w http.ResponseWriter, r *http.Request) {
 	// into memory, which is a big reason Go is great for large uploads.
 	mr, err := r.MultipartReader()
 	if err != nil {
-		http.Error(w, "expecting multipart form", http.StatusBadRequest)
+		httperr.BadRequest(w, "expecting multipart form")
 		return
 	}
 	var saved *model.FileRecord
@@ -119,7 +143,7 @@ func (s *Server) handleUpload(w http.ResponseWriter, r *http.Request) {
 			break
 		}
 		if err != nil {
-			http.Error(w, "failed to read upload", http.StatusBadRequest)
+			httperr.BadRequest(w, "failed to read upload")
 			return
 		}
 		if part.FormName() != "file" {
@@ -129,26 +153,38 @@ func (s *Server) handleUpload(w http.ResponseWriter, r *http.Request) {
 		// Persist the first file part we encounter and ignore others.
 		record, err := s.persistPart(part)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			httperr.BadRequest(w, err.Error())
 			return
 		}
 		saved = record
 		break
 	}
 	if saved == nil {
-		http.Error(w, "missing file part", http.StatusBadRequest)
+		httperr.BadRequest(w, "missing file part")
 		return
 	}
 	if err := s.scan(saved); err != nil {
 		_ = os.Remove(saved.Path)
 		// Errors are ignored because the best effort update suffices for API.
 		_ = s.store.UpdateStatus(saved.ID, model.StatusRejected, err.Error())
-		http.Error(w, "file rejected: "+err.Error(), http.StatusBadRequest)
+		httperr.BadRequest(w, "file rejected: "+err.Error())
 		return
 	}
 	_ = s.store.UpdateStatus(saved.ID, model.StatusScanned, "scan clean")
 	_ = s.store.UpdateStatus(saved.ID, model.StatusQueued, "queued for processing")
-	s.processor.Submit(processing.Job{FileID: saved.ID})
+	if err := s.processor.Submit(processing.Job{FileID: saved.ID}); err != nil {
+		// The client never learns this ID, so forget the upload entirely and
+		// let it try again once the workers catch up.
+		_ = os.Remove(saved.Path)
+		_ = s.store.Delete(saved.ID)
+		if errors.Is(err, processing.ErrQueueFull) {
+			w.Header().Set("Retry-After", strconv.Itoa(int(queueFullRetryAfter/time.Second)))
+			httperr.Error(w, "processing queue full, retry later", http.StatusServiceUnavailable)
+			return
+		}
+		httperr.Internal(w, "failed to queue upload")
+		return
+	}
 	respondJSON(w, http.StatusAccepted, map[string]string{
 		"id":     saved.ID,
 		"status": string(model.StatusQueued),
@@ -161,11 +197,24 @@ func (s *Server) handleFileRoute(w http.ResponseWriter, r *http.Request) {
 	// strings.Split returns a slice; we inspect segments to route requests.
 	parts := strings.Split(path, "/")
 	if len(parts) == 0 || parts[0] == "" {
-		http.NotFound(w, r)
+		httperr.NotFound(w, r)
 		return
 	}
 	id := parts[0]
+	if len(parts) == 1 && id == "counts" {
+		// File IDs are hex, so this name cannot shadow a file.
+		s.handleFileCounts(w, r)
+		return
+	}
+	if len(parts) == 1 && id == "summary" {
+		metadata.CountsHandler(s.meta)(w, r)
+		return
+	}
 	if len(parts) == 1 {
+		if r.Method == http.MethodDelete {
+			s.handleDeleteFile(w, r, id)
+			return
+		}
 		s.handleFileInfo(w, r, id)
 		return
 	}
@@ -173,54 +222,80 @@ func (s *Server) handleFileRoute(w http.ResponseWriter, r *http.Request) {
 		s.handleSignedURL(w, r, id)
 		return
 	}
-	http.NotFound(w, r)
+	httperr.NotFound(w, r)
 }
 
 func (s *Server) handleFileInfo(w http.ResponseWriter, r *http.Request, id string) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		httperr.MethodNotAllowed(w)
 		return
 	}
 	record, err := s.store.Get(id)
 	if err != nil {
-		http.Error(w, "file not found", http.StatusNotFound)
+		httperr.Error(w, "file not found", http.StatusNotFound)
 		return
 	}
 	// Avoid leaking server-side paths when returning JSON.
 	record.Path = ""
-	respondJSON(w, http.StatusOK, record)
+	respondCachedJSON(w, r, record.UpdatedAt, record)
 }
 
 func (s *Server) handleSignedURL(w http.ResponseWriter, r *http.Request, id string) {
 	if r.Method != http.MethodPost && r.Method != http.MethodGet {
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		httperr.MethodNotAllowed(w)
 		return
 	}
 	if _, err := s.store.Get(id); err != nil {
-		http.Error(w, "file not found", http.StatusNotFound)
+		httperr.Error(w, "file not found", http.StatusNotFound)
+		return
+	}
+	// ?once=true mints a link that works for a single download, for files
+	// too sensitive to leave a reusable URL in browser history or logs.
+	once := false
+	if v := r.URL.Query().Get("once"); v != "" {
+		var err error
+		if once, err = strconv.ParseBool(v); err != nil {
+			httperr.BadRequest(w, "invalid once")
+			return
+		}
+	}
+	if once && !s.cfg.SignedURLNonces {
+		httperr.BadRequest(w, "one-time links need VAULTDROP_SIGNED_URL_NONCES")
 		return
 	}
 	// Build a short-lived URL by combining the ID, expiry timestamp, and HMAC
 	// signature. Unix() returns seconds since epoch which is easy to transmit.
 	expiry := time.Now().Add(s.cfg.SignedURLTTL).Unix()
-	signature := s.signer.Sign(id, expiry)
+	claims := downloadClaims(id, expiry, "")
+	if once {
+		claims.Nonce = signing.NewNonce()
+	}
 	downloadURL := &urlBuilder{
 		base: "/download",
 		params: map[string]string{
 			"file":      id,
 			"expires":   strconv.FormatInt(expiry, 10),
-			"signature": signature,
+			"signature": s.signer.SignRequest(claims),
 		},
 	}
+	if once {
+		downloadURL.params["nonce"] = claims.Nonce
+	}
 	respondJSON(w, http.StatusOK, map[string]string{
 		"url":     downloadURL.String(),
 		"expires": strconv.FormatInt(expiry, 10),
 	})
 }
 
+// downloadClaims binds a signed URL to GET /download for one file; a nonce
+// makes it single-use.
+func downloadClaims(id string, expiry int64, nonce string) signing.Claims {
+	return signing.Claims{Method: http.MethodGet, Path: "/download", ID: id, Expires: expiry, Nonce: nonce}
+}
+
 func (s *Server) handleDownload(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		httperr.MethodNotAllowed(w)
 		return
 	}
 	// Query parameters are retrieved via r.URL.Query().Get().
@@ -228,34 +303,52 @@ func (s *Server) handleDownload(w http.ResponseWriter, r *http.Request) {
 	expires := r.URL.Query().Get("expires")
 	signature := r.URL.Query().Get("signature")
 	if id == "" || expires == "" || signature == "" {
-		http.Error(w, "missing parameters", http.StatusBadRequest)
+		httperr.BadRequest(w, "missing parameters")
 		return
 	}
 	expiryUnix, err := strconv.ParseInt(expires, 10, 64)
 	if err != nil {
-		http.Error(w, "invalid expires", http.StatusBadRequest)
+		httperr.BadRequest(w, "invalid expires")
 		return
 	}
 	if time.Unix(expiryUnix, 0).Before(time.Now()) {
-		http.Error(w, "url expired", http.StatusUnauthorized)
+		httperr.Error(w, "url expired", http.StatusUnauthorized)
 		return
 	}
-	if !s.signer.Validate(id, expires, signature) {
-		http.Error(w, "invalid signature", http.StatusUnauthorized)
+	// Redeem spends the nonce of a one-time link; reusable links only have
+	// their signature checked.
+	switch err := s.signer.Redeem(r.Context(), downloadClaims(id, expiryUnix, r.URL.Query().Get("nonce")), signature); {
+	case err == nil:
+	case errors.Is(err, signing.ErrInvalidSignature):
+		httperr.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	case errors.Is(err, signing.ErrReplayed):
+		httperr.Error(w, "url already used", http.StatusGone)
+		return
+	default:
+		log.Printf("redeem signed url for %s: %v", id, err)
+		httperr.Error(w, "signed url check unavailable", http.StatusServiceUnavailable)
 		return
 	}
 	record, err := s.store.Get(id)
 	if err != nil {
-		http.Error(w, "file not found", http.StatusNotFound)
+		httperr.Error(w, "file not found", http.StatusNotFound)
 		return
 	}
 	f, err := os.Open(record.Path)
 	if err != nil {
-		http.Error(w, "file unavailable", http.StatusInternalServerError)
+		httperr.Internal(w, "file unavailable")
 		return
 	}
 	defer f.Close()
-	// HTTP headers describe the file; ServeContent streams data efficiently.
+	etag, err := s.contentETag(record)
+	if err != nil {
+		httperr.Internal(w, "file unavailable")
+		return
+	}
+	// HTTP headers describe the file; ServeContent streams data efficiently
+	// and answers If-None-Match/If-Modified-Since with 304 given the ETag.
+	w.Header().Set("ETag", etag)
 	w.Header().Set("Content-Type", record.ContentType)
 	w.Header().Set("Content-Length", strconv.FormatInt(record.Size, 10))
 	w.Header().Set("Content-Disposition", "attachment; filename=\""+record.Name+"\"")
@@ -329,7 +422,10 @@ func (s *Server) persistPart(part *multipart.Part) (*model.FileRecord, error) {
 		Path:        path,
 		Status:      model.StatusUploaded,
 	}
-	s.store.Save(record)
+	if err := s.store.Save(record); err != nil {
+		os.Remove(path)
+		return nil, err
+	}
 	return record, nil
 }
 
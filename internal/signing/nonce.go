@@ -0,0 +1,84 @@
+package signing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Errors returned by Redeem. ErrInvalidSignature covers forged and tampered
+// URLs alike so callers do not leak which claim failed.
+var (
+	ErrInvalidSignature = errors.New("invalid signature")
+	ErrReplayed         = errors.New("signed url already used")
+	ErrNoNonceStore     = errors.New("one-time signed urls are not enabled")
+)
+
+// NonceStore remembers the nonces of one-time URLs that were already used.
+type NonceStore interface {
+	// Consume records nonce for ttl and reports whether it was unused.
+	Consume(ctx context.Context, nonce string, ttl time.Duration) (bool, error)
+}
+
+// NewNonce returns a random nonce for a one-time URL.
+func NewNonce() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// UseNonces sets the store Redeem checks one-time URLs against. Without one,
+// URLs carrying a nonce are refused rather than accepted more than once.
+func (s *Signer) UseNonces(store NonceStore) {
+	s.nonces = store
+}
+
+// Redeem validates signature like ValidateRequest and, when c carries a
+// nonce, spends it so the same URL cannot be used again before it expires.
+// URLs without a nonce stay reusable until they expire.
+func (s *Signer) Redeem(ctx context.Context, c Claims, signature string) error {
+	if !s.ValidateRequest(c, signature) {
+		return ErrInvalidSignature
+	}
+	if c.Nonce == "" {
+		return nil
+	}
+	if s.nonces == nil {
+		return ErrNoNonceStore
+	}
+	// Remember the nonce a little past the expiry so clock skew between
+	// replicas cannot reopen the URL.
+	ttl := time.Until(time.Unix(c.Expires, 0)) + time.Minute
+	fresh, err := s.nonces.Consume(ctx, c.Nonce, ttl)
+	if err != nil {
+		return fmt.Errorf("consume nonce: %w", err)
+	}
+	if !fresh {
+		return ErrReplayed
+	}
+	return nil
+}
+
+// RedisNonces keeps used nonces as expiring Redis keys, so every replica
+// sharing the Redis instance sees a URL as spent.
+type RedisNonces struct {
+	rdb *redis.Client
+}
+
+// NewRedisNonces constructs a RedisNonces.
+func NewRedisNonces(rdb *redis.Client) *RedisNonces {
+	return &RedisNonces{rdb: rdb}
+}
+
+// Consume implements NonceStore with SET NX, which records the nonce and
+// reports whether it was new in one round trip.
+func (n *RedisNonces) Consume(ctx context.Context, nonce string, ttl time.Duration) (bool, error) {
+	return n.rdb.SetNX(ctx, "vaultdrop:nonce:"+nonce, 1, ttl).Result()
+}
@@ -5,14 +5,16 @@ package signing
 import (
 	"crypto/hmac"
 	"crypto/sha256"
+	"encoding/base64"
 	"encoding/hex"
-	"fmt"
 	"strconv"
+	"strings"
 )
 
 // Signer generates and validates HMAC based signatures.
 type Signer struct {
 	secret []byte
+	nonces NonceStore
 }
 
 // NewSigner creates a Signer.
@@ -20,27 +22,93 @@ func NewSigner(secret []byte) *Signer {
 	return &Signer{secret: secret}
 }
 
-// Sign returns the hex signature for inputs.
-func (s *Signer) Sign(fileID string, expiresUnix int64) string {
-	// hmac.New accepts a hash constructor (sha256.New) plus the secret key.
+// Version prefixes every URL signature so the canonical payload can change
+// again without old signatures being read under new rules.
+const Version = "v2"
+
+// Claims are the request properties a URL signature covers. A signed URL is
+// only valid for the method and path it was minted for, so a download link
+// cannot be replayed against another verb or endpoint. Empty and zero
+// fields are still part of the payload; they just do not constrain anything.
+type Claims struct {
+	Method string
+	Path   string
+	// ID names the resource when it travels in the query rather than the
+	// path, as in /download?file=<id>.
+	ID    string
+	Owner string
+	// Expires is a Unix time; checking it is left to the caller so it can
+	// answer expired and forged URLs differently.
+	Expires int64
+	// ContentType and MaxSize constrain uploads; zero values mean none.
+	ContentType string
+	MaxSize     int64
+	// Nonce makes a URL single-use when it is redeemed with Redeem; empty
+	// leaves it reusable until it expires.
+	Nonce string
+}
+
+// canonical joins the claims one per line. Newlines cannot occur in a method,
+// path, media type or nonce, and the free-form owner goes last.
+func (c Claims) canonical() string {
+	return strings.Join([]string{
+		Version,
+		c.Method,
+		c.Path,
+		c.ID,
+		strconv.FormatInt(c.Expires, 10),
+		c.ContentType,
+		strconv.FormatInt(c.MaxSize, 10),
+		c.Nonce,
+		c.Owner,
+	}, "\n")
+}
+
+// SignRequest returns the signature for c, "v2." followed by the hex HMAC.
+func (s *Signer) SignRequest(c Claims) string {
 	mac := hmac.New(sha256.New, s.secret)
-	// fmt.Sprintf builds the canonical payload string, ensuring consistent
-	// ordering of values. In Go, fmt is the go-to formatting package.
-	payload := fmt.Sprintf("%s:%d", fileID, expiresUnix)
-	// Write accepts a byte slice; we derive one by converting the string.
-	mac.Write([]byte(payload))
-	// hex.EncodeToString is handy for turning raw bytes into a printable token.
-	return hex.EncodeToString(mac.Sum(nil))
+	mac.Write([]byte(c.canonical()))
+	return Version + "." + hex.EncodeToString(mac.Sum(nil))
 }
 
-// Validate compares the provided signature with the expected.
-func (s *Signer) Validate(fileID, expires, signature string) bool {
-	// strconv.ParseInt converts the expires query parameter back to an integer.
-	exp, err := strconv.ParseInt(expires, 10, 64)
-	if err != nil {
+// ValidateRequest compares signature with the one SignRequest gives for c.
+// Signatures without the current version prefix are rejected.
+func (s *Signer) ValidateRequest(c Claims, signature string) bool {
+	if !strings.HasPrefix(signature, Version+".") {
 		return false
 	}
-	expected := s.Sign(fileID, exp)
 	// hmac.Equal performs constant-time comparison to avoid timing attacks.
-	return hmac.Equal([]byte(expected), []byte(signature))
+	return hmac.Equal([]byte(s.SignRequest(c)), []byte(signature))
+}
+
+// SignToken wraps an arbitrary payload (for example JSON claims) into a
+// bearer token of the form "<base64url payload>.<hex signature>".
+func (s *Signer) SignToken(payload []byte) string {
+	// RawURLEncoding omits padding so tokens can be pasted into URLs and
+	// headers without escaping.
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + s.tokenMAC(encoded)
+}
+
+// VerifyToken checks a token produced by SignToken and returns its payload.
+func (s *Signer) VerifyToken(token string) ([]byte, bool) {
+	// strings.Cut splits around the first separator and reports whether it
+	// was found, which saves an explicit length check on the split result.
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(s.tokenMAC(encoded)), []byte(signature)) {
+		return nil, false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, false
+	}
+	return payload, true
+}
+
+func (s *Signer) tokenMAC(encoded string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	// The "token:" prefix separates this domain from URL signatures so a
+	// signature minted for one can never be replayed as the other.
+	mac.Write([]byte("token:" + encoded))
+	return hex.EncodeToString(mac.Sum(nil))
 }
@@ -1,25 +1,106 @@
 package signing
 
-import "testing"
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
 
 func TestSigner(t *testing.T) {
 	// testing.T is provided by Go's stdlib test framework; helper methods like
 	// Fatalf fail the test immediately.
 	secret := []byte("topsecret")
 	s := NewSigner(secret)
-	sig := s.Sign("file123", 1700000000)
-	if len(sig) == 0 {
-		t.Fatalf("expected signature")
+	download := Claims{Method: "GET", Path: "/download", ID: "file123", Expires: 1700000000}
+	sig := s.SignRequest(download)
+	if !strings.HasPrefix(sig, "v2.") {
+		t.Fatalf("expected a versioned signature, got %q", sig)
 	}
-	// Positive case: Validate should succeed with matching inputs.
-	if !s.Validate("file123", "1700000000", sig) {
+	// Positive case: ValidateRequest should succeed with matching claims.
+	if !s.ValidateRequest(download, sig) {
 		t.Fatalf("expected signature to validate")
 	}
-	// Negative cases ensure Validate is strict about every parameter.
-	if s.Validate("wrong", "1700000000", sig) {
-		t.Fatalf("expected validation to fail for wrong file id")
+	// Negative cases ensure every claim is covered.
+	for name, change := range map[string]func(*Claims){
+		"file id":      func(c *Claims) { c.ID = "wrong" },
+		"expiry":       func(c *Claims) { c.Expires = 42 },
+		"method":       func(c *Claims) { c.Method = "DELETE" },
+		"path":         func(c *Claims) { c.Path = "/files/file123" },
+		"owner":        func(c *Claims) { c.Owner = "bob" },
+		"content type": func(c *Claims) { c.ContentType = "text/plain" },
+		"size":         func(c *Claims) { c.MaxSize = 1 << 20 },
+		"nonce":        func(c *Claims) { c.Nonce = "abc" },
+	} {
+		c := download
+		change(&c)
+		if s.ValidateRequest(c, sig) {
+			t.Errorf("expected validation to fail for a different %s", name)
+		}
 	}
-	if s.Validate("file123", "42", sig) {
-		t.Fatalf("expected validation to fail for wrong expiry")
+	// Unversioned signatures from before claims were signed are refused.
+	if s.ValidateRequest(download, strings.TrimPrefix(sig, "v2.")) {
+		t.Fatalf("expected signature without version prefix to fail")
+	}
+}
+
+// memoryNonces is a NonceStore for tests.
+type memoryNonces map[string]bool
+
+func (m memoryNonces) Consume(_ context.Context, nonce string, _ time.Duration) (bool, error) {
+	if m[nonce] {
+		return false, nil
+	}
+	m[nonce] = true
+	return true, nil
+}
+
+func TestRedeem(t *testing.T) {
+	ctx := context.Background()
+	s := NewSigner([]byte("topsecret"))
+	expires := time.Now().Add(time.Minute).Unix()
+	reusable := Claims{Method: "GET", Path: "/download", ID: "file123", Expires: expires}
+	once := reusable
+	once.Nonce = NewNonce()
+
+	if err := s.Redeem(ctx, once, s.SignRequest(once)); !errors.Is(err, ErrNoNonceStore) {
+		t.Fatalf("one-time URL without a nonce store: got %v, want ErrNoNonceStore", err)
+	}
+	s.UseNonces(memoryNonces{})
+	for i := 0; i < 2; i++ {
+		if err := s.Redeem(ctx, reusable, s.SignRequest(reusable)); err != nil {
+			t.Fatalf("reusable URL, use %d: %v", i+1, err)
+		}
+	}
+	sig := s.SignRequest(once)
+	if err := s.Redeem(ctx, once, sig); err != nil {
+		t.Fatalf("one-time URL, first use: %v", err)
+	}
+	if err := s.Redeem(ctx, once, sig); !errors.Is(err, ErrReplayed) {
+		t.Fatalf("one-time URL, second use: got %v, want ErrReplayed", err)
+	}
+	// Dropping the nonce from the URL does not make it reusable.
+	if err := s.Redeem(ctx, reusable, sig); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("nonce stripped: got %v, want ErrInvalidSignature", err)
+	}
+}
+
+func TestSignToken(t *testing.T) {
+	s := NewSigner([]byte("topsecret"))
+	token := s.SignToken([]byte(`{"actions":["read"]}`))
+	payload, ok := s.VerifyToken(token)
+	if !ok || string(payload) != `{"actions":["read"]}` {
+		t.Fatalf("expected token to verify, got %q %v", payload, ok)
+	}
+	// Tampering with either half must be rejected.
+	if _, ok := s.VerifyToken("x" + token); ok {
+		t.Fatalf("expected tampered payload to fail")
+	}
+	if _, ok := s.VerifyToken(token + "0"); ok {
+		t.Fatalf("expected tampered signature to fail")
+	}
+	if _, ok := NewSigner([]byte("other")).VerifyToken(token); ok {
+		t.Fatalf("expected token from another secret to fail")
 	}
 }
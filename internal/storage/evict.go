@@ -0,0 +1,122 @@
+package storage
+
+import (
+	"container/list"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/model"
+)
+
+// Limits caps what a MemoryStore keeps. Zero fields mean no cap. Once over a
+// cap the least recently used finished records (complete, failed or
+// rejected) are evicted; records still moving through the pipeline are never
+// evicted, so the store can exceed its caps while they are in flight.
+type Limits struct {
+	MaxRecords int
+	// MaxBytes caps the summed Size of the files behind the records.
+	MaxBytes int64
+}
+
+// SetLimits applies limits, evicting right away if the store is over them.
+func (m *MemoryStore) SetLimits(limits Limits) {
+	m.mu.Lock()
+	m.limits = limits
+	evicted := m.evictLocked("")
+	m.mu.Unlock()
+	m.notifyEvicted(evicted)
+}
+
+// OnEvict registers fn to be called with each evicted record, outside the
+// store's lock, so the owner can delete the files behind it.
+func (m *MemoryStore) OnEvict(fn func(model.FileRecord)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onEvict = fn
+}
+
+func (m *MemoryStore) limited() bool {
+	return m.limits.MaxRecords > 0 || m.limits.MaxBytes > 0
+}
+
+// track marks rec as most recently used and counts its bytes. The caller
+// holds the write lock and has not yet replaced any previous record.
+func (m *MemoryStore) track(rec *model.FileRecord) {
+	if old, ok := m.files[rec.ID]; ok {
+		m.bytes -= old.Size
+	}
+	m.bytes += rec.Size
+	if e, ok := m.recent[rec.ID]; ok {
+		m.lru.MoveToFront(e)
+		return
+	}
+	m.recent[rec.ID] = m.lru.PushFront(rec.ID)
+}
+
+// untrack forgets id; the caller holds the write lock.
+func (m *MemoryStore) untrack(id string) {
+	if rec, ok := m.files[id]; ok {
+		m.bytes -= rec.Size
+	}
+	if e, ok := m.recent[id]; ok {
+		m.lru.Remove(e)
+		delete(m.recent, id)
+	}
+}
+
+// touch marks id as recently used.
+func (m *MemoryStore) touch(id string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if e, ok := m.recent[id]; ok {
+		m.lru.MoveToFront(e)
+	}
+}
+
+// evictLocked drops least recently used finished records until the store is
+// within its limits, sparing keep. The caller holds the write lock.
+func (m *MemoryStore) evictLocked(keep string) []model.FileRecord {
+	over := func() bool {
+		return (m.limits.MaxRecords > 0 && len(m.files) > m.limits.MaxRecords) ||
+			(m.limits.MaxBytes > 0 && m.bytes > m.limits.MaxBytes)
+	}
+	var evicted []model.FileRecord
+	for e := m.lru.Back(); e != nil && over(); {
+		prev := e.Prev()
+		id := e.Value.(string)
+		rec := m.files[id]
+		if id != keep && evictable(rec.Status) {
+			evicted = append(evicted, *rec)
+			m.untrack(id)
+			delete(m.files, id)
+			m.version++
+		}
+		e = prev
+	}
+	return evicted
+}
+
+func (m *MemoryStore) notifyEvicted(evicted []model.FileRecord) {
+	if len(evicted) == 0 {
+		return
+	}
+	m.mu.RLock()
+	fn := m.onEvict
+	m.mu.RUnlock()
+	if fn == nil {
+		return
+	}
+	for _, rec := range evicted {
+		fn(rec)
+	}
+}
+
+func evictable(status model.FileStatus) bool {
+	switch status {
+	case model.StatusComplete, model.StatusFailed, model.StatusRejected:
+		return true
+	}
+	return false
+}
+
+func newLRU() (*list.List, map[string]*list.Element) {
+	return list.New(), make(map[string]*list.Element)
+}
@@ -0,0 +1,144 @@
+package storage
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/model"
+)
+
+// limitedStore returns a store with limits and a function reporting the ids
+// evicted so far, in eviction order.
+func limitedStore(limits Limits) (*MemoryStore, func() []string) {
+	store := NewMemoryStore()
+	store.SetLimits(limits)
+	var evicted []string
+	store.OnEvict(func(rec model.FileRecord) { evicted = append(evicted, rec.ID) })
+	return store, func() []string { return evicted }
+}
+
+func save(t *testing.T, store *MemoryStore, id string, size int64, status model.FileStatus) {
+	t.Helper()
+	if err := store.Save(&model.FileRecord{ID: id, Size: size, Status: status}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func ids(t *testing.T, store *MemoryStore) []string {
+	t.Helper()
+	page, err := store.List(ListFilter{Limit: 500})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out []string
+	for _, f := range page.Files {
+		out = append(out, f.ID)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func TestEvictLeastRecentlyUsed(t *testing.T) {
+	store, evicted := limitedStore(Limits{MaxRecords: 3})
+	for _, id := range []string{"a", "b", "c"} {
+		save(t, store, id, 1, model.StatusComplete)
+	}
+	// Reading a makes b the least recently used.
+	if _, err := store.Get("a"); err != nil {
+		t.Fatal(err)
+	}
+	save(t, store, "d", 1, model.StatusComplete)
+	// Saving c again moves it to the front as well.
+	save(t, store, "c", 1, model.StatusComplete)
+	save(t, store, "e", 1, model.StatusComplete)
+
+	if got, want := evicted(), []string{"b", "a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("evicted %v, want %v", got, want)
+	}
+	if got, want := ids(t, store), []string{"c", "d", "e"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("kept %v, want %v", got, want)
+	}
+	if _, err := store.Get("b"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get(evicted) error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestEvictByteCap(t *testing.T) {
+	store, evicted := limitedStore(Limits{MaxBytes: 100})
+	save(t, store, "a", 40, model.StatusComplete)
+	save(t, store, "b", 40, model.StatusFailed)
+	save(t, store, "c", 20, model.StatusRejected)
+	if len(evicted()) != 0 {
+		t.Fatalf("evicted %v at exactly the cap", evicted())
+	}
+	save(t, store, "d", 30, model.StatusComplete)
+	if got, want := evicted(), []string{"a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("evicted %v, want %v", got, want)
+	}
+
+	// Replacing a record counts its new size, not both.
+	save(t, store, "b", 10, model.StatusComplete)
+	if got := evicted(); len(got) != 1 {
+		t.Errorf("evicted %v after shrinking b", got)
+	}
+
+	// A record bigger than the cap on its own pushes out everything else
+	// that can go, but is kept itself.
+	save(t, store, "big", 500, model.StatusComplete)
+	if got, want := ids(t, store), []string{"big"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("kept %v, want %v", got, want)
+	}
+
+	// Deleting gives the bytes back.
+	if err := store.Delete("big"); err != nil {
+		t.Fatal(err)
+	}
+	before := len(evicted())
+	save(t, store, "e", 100, model.StatusComplete)
+	if got := evicted(); len(got) != before {
+		t.Errorf("evicted %v after deleting big", got[before:])
+	}
+}
+
+func TestEvictSparesRecordsInFlight(t *testing.T) {
+	store, evicted := limitedStore(Limits{MaxRecords: 2})
+	save(t, store, "uploaded", 1, model.StatusUploaded)
+	save(t, store, "processing", 1, model.StatusProcessing)
+	save(t, store, "retrying", 1, model.StatusRetrying)
+	save(t, store, "done", 1, model.StatusComplete)
+	// Over the cap, but only the record being saved is finished, and it is
+	// the one the caller just wrote.
+	if len(evicted()) != 0 {
+		t.Fatalf("evicted %v", evicted())
+	}
+	if got := ids(t, store); len(got) != 4 {
+		t.Errorf("kept %v, want all four", got)
+	}
+
+	// Once a record finishes it can go on the next save.
+	if err := store.UpdateStatus("processing", model.StatusFailed, "boom"); err != nil {
+		t.Fatal(err)
+	}
+	save(t, store, "queued", 1, model.StatusQueued)
+	if got, want := evicted(), []string{"processing", "done"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("evicted %v, want %v", got, want)
+	}
+	if got, want := ids(t, store), []string{"queued", "retrying", "uploaded"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("kept %v, want %v", got, want)
+	}
+}
+
+func TestSetLimitsEvictsRightAway(t *testing.T) {
+	store := NewMemoryStore()
+	for _, id := range []string{"a", "b", "c"} {
+		save(t, store, id, 1, model.StatusComplete)
+	}
+	var evicted []string
+	store.OnEvict(func(rec model.FileRecord) { evicted = append(evicted, rec.ID) })
+	store.SetLimits(Limits{MaxRecords: 1})
+	if want := []string{"a", "b"}; !reflect.DeepEqual(evicted, want) {
+		t.Errorf("evicted %v, want %v", evicted, want)
+	}
+}
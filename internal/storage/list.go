@@ -0,0 +1,154 @@
+package storage
+
+import (
+	"encoding/base64"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/model"
+)
+
+const (
+	defaultListLimit = 50
+	maxListLimit     = 500
+)
+
+// ErrInvalidCursor is returned by List for a cursor it did not issue.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// ListFilter narrows and pages List results. Zero values mean no filter.
+type ListFilter struct {
+	Statuses   []model.FileStatus
+	NamePrefix string
+	// Ascending lists oldest first; the default is newest first.
+	Ascending bool
+	// Limit defaults to 50 and is capped at 500.
+	Limit int
+	// Cursor is the NextCursor of the previous page.
+	Cursor string
+	// Offset skips that many matches, after the cursor if both are given.
+	// Cursors stay stable while files are added; offsets are simpler for
+	// quick scripts.
+	Offset int
+}
+
+// FilePage is one page of List results.
+type FilePage struct {
+	Files      []model.FileRecord `json:"files"`
+	NextCursor string             `json:"nextCursor,omitempty"`
+	Total      int                `json:"total"`
+}
+
+// List returns copies of the records matching filter ordered by creation
+// time. Like the Postgres listing its cursors page by (createdAt, id), so
+// records added between pages do not shift later pages; Offset is there for
+// clients that prefer numbered pages.
+func (m *MemoryStore) List(filter ListFilter) (*FilePage, error) {
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	if limit > maxListLimit {
+		limit = maxListLimit
+	}
+	var (
+		afterAt time.Time
+		afterID string
+	)
+	if filter.Cursor != "" {
+		var err error
+		if afterAt, afterID, err = decodeCursor(filter.Cursor); err != nil {
+			return nil, err
+		}
+	}
+	// before reports whether a sorts ahead of b in the requested order.
+	before := func(a, b *model.FileRecord) bool {
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.Before(b.CreatedAt) == filter.Ascending
+		}
+		return (a.ID < b.ID) == filter.Ascending
+	}
+	cursor := &model.FileRecord{ID: afterID, CreatedAt: afterAt}
+
+	m.mu.RLock()
+	var matches []*model.FileRecord
+	for _, rec := range m.files {
+		if filter.matches(rec) {
+			matches = append(matches, rec)
+		}
+	}
+	page := &FilePage{Files: []model.FileRecord{}, Total: len(matches)}
+	sort.Slice(matches, func(i, j int) bool { return before(matches[i], matches[j]) })
+	skip := filter.Offset
+	for _, rec := range matches {
+		if filter.Cursor != "" && !before(cursor, rec) {
+			continue
+		}
+		if skip > 0 {
+			skip--
+			continue
+		}
+		if len(page.Files) == limit {
+			last := page.Files[limit-1]
+			page.NextCursor = encodeCursor(last.CreatedAt, last.ID)
+			break
+		}
+		page.Files = append(page.Files, copyRecord(rec))
+	}
+	m.mu.RUnlock()
+	return page, nil
+}
+
+// CountByStatus returns the number of records in each status. Every status
+// is present in the result, with zero when no record has it.
+func (m *MemoryStore) CountByStatus() (map[model.FileStatus]int, error) {
+	counts := map[model.FileStatus]int{}
+	for _, status := range model.Statuses {
+		counts[status] = 0
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, rec := range m.files {
+		counts[rec.Status]++
+	}
+	return counts, nil
+}
+
+func (f ListFilter) matches(rec *model.FileRecord) bool {
+	if len(f.Statuses) > 0 {
+		found := false
+		for _, s := range f.Statuses {
+			if rec.Status == s {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return strings.HasPrefix(rec.Name, f.NamePrefix)
+}
+
+// Cursors are opaque to clients: the last record's creation time and id.
+func encodeCursor(at time.Time, id string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(at.UTC().Format(time.RFC3339Nano) + "|" + id))
+}
+
+func decodeCursor(cursor string) (time.Time, string, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, "", ErrInvalidCursor
+	}
+	ts, id, ok := strings.Cut(string(raw), "|")
+	if !ok || id == "" {
+		return time.Time{}, "", ErrInvalidCursor
+	}
+	at, err := time.Parse(time.RFC3339Nano, ts)
+	if err != nil {
+		return time.Time{}, "", ErrInvalidCursor
+	}
+	return at, id, nil
+}
@@ -3,6 +3,7 @@
 package storage
 
 import (
+	"container/list"
 	"errors"
 	"sync"
 	"time"
@@ -22,28 +23,45 @@ var (
 type MemoryStore struct {
 	mu    sync.RWMutex
 	files map[string]*model.FileRecord
+	// version counts writes so snapshots can skip an unchanged store.
+	version uint64
+	// lru orders record IDs from most to least recently used, with recent
+	// indexing its elements; bytes sums the tracked file sizes.
+	lru     *list.List
+	recent  map[string]*list.Element
+	bytes   int64
+	limits  Limits
+	onEvict func(model.FileRecord)
 }
 
 // NewMemoryStore constructs a MemoryStore.
 func NewMemoryStore() *MemoryStore {
+	lru, recent := newLRU()
 	return &MemoryStore{
-		files: make(map[string]*model.FileRecord),
+		files:  make(map[string]*model.FileRecord),
+		lru:    lru,
+		recent: recent,
 	}
 }
 
-// Save inserts or replaces a record.
-func (m *MemoryStore) Save(record *model.FileRecord) {
+// Save inserts or replaces a record, evicting older records if that takes
+// the store over its limits.
+func (m *MemoryStore) Save(record *model.FileRecord) error {
 	m.mu.Lock()
-	// defer schedules code to run when the function returns, guaranteeing the
-	// mutex unlock even if the function exits early.
-	defer m.mu.Unlock()
 	// time.Now returns local time; calling UTC standardizes timestamps for API.
 	now := time.Now().UTC()
 	if record.CreatedAt.IsZero() {
 		record.CreatedAt = now
 	}
 	record.UpdatedAt = now
+	m.track(record)
 	m.files[record.ID] = record
+	m.version++
+	evicted := m.evictLocked(record.ID)
+	// The eviction callback may touch the disk, so it runs unlocked.
+	m.mu.Unlock()
+	m.notifyEvicted(evicted)
+	return nil
 }
 
 // UpdateStatus updates status/message.
@@ -59,6 +77,41 @@ func (m *MemoryStore) UpdateStatus(id string, status model.FileStatus, msg strin
 	rec.Status = status
 	rec.Message = msg
 	rec.UpdatedAt = time.Now().UTC()
+	m.version++
+	return nil
+}
+
+// SetAttributes merges attrs into the record's attributes.
+func (m *MemoryStore) SetAttributes(id string, attrs map[string]string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rec, ok := m.files[id]
+	if !ok {
+		return ErrNotFound
+	}
+	// Build a new map so copies handed out by Get never change underneath
+	// their readers.
+	merged := make(map[string]string, len(rec.Attributes)+len(attrs))
+	for k, v := range rec.Attributes {
+		merged[k] = v
+	}
+	for k, v := range attrs {
+		merged[k] = v
+	}
+	rec.Attributes = merged
+	rec.UpdatedAt = time.Now().UTC()
+	m.version++
+	return nil
+}
+
+// Delete removes a record; deleting a missing record is not an error.
+func (m *MemoryStore) Delete(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.untrack(id)
+	// The built-in delete is a no-op for absent keys.
+	delete(m.files, id)
+	m.version++
 	return nil
 }
 
@@ -66,12 +119,31 @@ func (m *MemoryStore) UpdateStatus(id string, status model.FileStatus, msg strin
 func (m *MemoryStore) Get(id string) (*model.FileRecord, error) {
 	m.mu.RLock()
 	// Read locks allow multiple concurrent readers, improving throughput.
-	defer m.mu.RUnlock()
 	rec, ok := m.files[id]
 	if !ok {
+		m.mu.RUnlock()
 		return nil, ErrNotFound
 	}
-	// Returning a shallow copy prevents callers from mutating internal state.
-	copy := *rec
+	// Returning a copy prevents callers from mutating internal state.
+	copy := copyRecord(rec)
+	limited := m.limited()
+	m.mu.RUnlock()
+	if limited {
+		// Reordering the LRU needs the write lock, so only pay for it when
+		// eviction is on.
+		m.touch(id)
+	}
 	return &copy, nil
 }
+
+func copyRecord(rec *model.FileRecord) model.FileRecord {
+	copy := *rec
+	if rec.Attributes != nil {
+		// Maps are references, so the copy gets its own.
+		copy.Attributes = make(map[string]string, len(rec.Attributes))
+		for k, v := range rec.Attributes {
+			copy.Attributes[k] = v
+		}
+	}
+	return copy
+}
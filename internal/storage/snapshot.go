@@ -0,0 +1,140 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/model"
+)
+
+// snapshotRecord carries Path alongside the record because FileRecord hides
+// it from JSON, yet downloads need it after a restart.
+type snapshotRecord struct {
+	Record *model.FileRecord `json:"record"`
+	Path   string            `json:"path,omitempty"`
+}
+
+// Snapshot writes every record to path as JSON. The file is replaced
+// atomically, so a crash mid-write leaves the previous snapshot in place.
+func (m *MemoryStore) Snapshot(path string) error {
+	_, err := m.snapshot(path)
+	return err
+}
+
+// snapshot writes the store and returns the version it captured.
+func (m *MemoryStore) snapshot(path string) (uint64, error) {
+	m.mu.RLock()
+	version := m.version
+	records := make([]snapshotRecord, 0, len(m.files))
+	for _, rec := range m.files {
+		copy := copyRecord(rec)
+		records = append(records, snapshotRecord{Record: &copy, Path: rec.Path})
+	}
+	m.mu.RUnlock()
+
+	data, err := json.Marshal(records)
+	if err != nil {
+		return 0, fmt.Errorf("encode snapshot: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return 0, fmt.Errorf("write snapshot: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return 0, fmt.Errorf("write snapshot: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return 0, fmt.Errorf("write snapshot: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return 0, fmt.Errorf("write snapshot: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return 0, fmt.Errorf("write snapshot: %w", err)
+	}
+	return version, nil
+}
+
+// LoadSnapshot adds the records saved at path to the store, keeping their
+// timestamps. A missing file is not an error: there is nothing to restore on
+// the first run.
+func (m *MemoryStore) LoadSnapshot(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("read snapshot: %w", err)
+	}
+	var records []snapshotRecord
+	if err := json.Unmarshal(data, &records); err != nil {
+		return 0, fmt.Errorf("decode snapshot: %w", err)
+	}
+	valid := records[:0]
+	for _, r := range records {
+		if r.Record != nil && r.Record.ID != "" {
+			valid = append(valid, r)
+		}
+	}
+	records = valid
+	// Replay the least recently updated first so they end up at the back
+	// of the eviction order.
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Record.UpdatedAt.Before(records[j].Record.UpdatedAt)
+	})
+	m.mu.Lock()
+	for _, r := range records {
+		r.Record.Path = r.Path
+		m.track(r.Record)
+		m.files[r.Record.ID] = r.Record
+	}
+	m.version++
+	evicted := m.evictLocked("")
+	m.mu.Unlock()
+	m.notifyEvicted(evicted)
+	return len(records), nil
+}
+
+// RunSnapshots writes a snapshot to path every interval while the store has
+// changed, and once more when ctx is cancelled so a clean shutdown loses
+// nothing. It blocks until then.
+func (m *MemoryStore) RunSnapshots(ctx context.Context, path string, interval time.Duration) {
+	m.mu.RLock()
+	saved := m.version
+	m.mu.RUnlock()
+	write := func() {
+		m.mu.RLock()
+		current := m.version
+		m.mu.RUnlock()
+		if current == saved {
+			return
+		}
+		version, err := m.snapshot(path)
+		if err != nil {
+			log.Printf("snapshot store: %v", err)
+			return
+		}
+		saved = version
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			write()
+			return
+		case <-ticker.C:
+			write()
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/model"
+)
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.json")
+	store := NewMemoryStore()
+	created := time.Date(2026, 3, 1, 10, 30, 0, 0, time.UTC)
+	rec := &model.FileRecord{ID: "a", Name: "a.pdf", Size: 42, Path: "/data/a.pdf", Status: model.StatusComplete, CreatedAt: created}
+	if err := store.Save(rec); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SetAttributes("a", map[string]string{"sha256": "x"}); err != nil {
+		t.Fatal(err)
+	}
+	saved, _ := store.Get("a")
+	if err := store.Snapshot(path); err != nil {
+		t.Fatal(err)
+	}
+	if matches, _ := filepath.Glob(path + ".*"); len(matches) != 0 {
+		t.Errorf("temporary files left behind: %v", matches)
+	}
+
+	restored := NewMemoryStore()
+	n, err := restored.LoadSnapshot(path)
+	if err != nil || n != 1 {
+		t.Fatalf("LoadSnapshot = %d, %v", n, err)
+	}
+	got, err := restored.Get("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Path is hidden from JSON but has to survive the snapshot.
+	if !reflect.DeepEqual(got, saved) {
+		t.Errorf("restored %+v, want %+v", got, saved)
+	}
+}
+
+func TestLoadSnapshotMissingOrInvalid(t *testing.T) {
+	dir := t.TempDir()
+	store := NewMemoryStore()
+	if n, err := store.LoadSnapshot(filepath.Join(dir, "missing.json")); n != 0 || err != nil {
+		t.Errorf("LoadSnapshot(missing) = %d, %v; want 0, nil", n, err)
+	}
+	bad := filepath.Join(dir, "bad.json")
+	if err := os.WriteFile(bad, []byte("{not json"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.LoadSnapshot(bad); err == nil {
+		t.Error("LoadSnapshot(invalid) succeeded")
+	}
+	// Entries without a record or id are skipped.
+	partial := filepath.Join(dir, "partial.json")
+	if err := os.WriteFile(partial, []byte(`[{"record":null},{"record":{"id":""}},{"record":{"id":"a","status":"complete"}}]`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := store.LoadSnapshot(partial); n != 1 || err != nil {
+		t.Errorf("LoadSnapshot(partial) = %d, %v; want 1, nil", n, err)
+	}
+}
+
+func TestLoadSnapshotEvictsLeastRecentlyUpdated(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.json")
+	at := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	// Written newest first, so the load has to sort them.
+	var records []snapshotRecord
+	for i, id := range []string{"c", "b", "a"} {
+		updated := at.Add(time.Duration(3-i) * time.Hour)
+		records = append(records, snapshotRecord{Record: &model.FileRecord{ID: id, Status: model.StatusComplete, CreatedAt: at, UpdatedAt: updated}})
+	}
+	data, err := json.Marshal(records)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	store, evicted := limitedStore(Limits{MaxRecords: 2})
+	if _, err := store.LoadSnapshot(path); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := evicted(), []string{"a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("evicted %v, want %v", got, want)
+	}
+	// The restored order carries on: b is now the least recently used.
+	save(t, store, "d", 1, model.StatusComplete)
+	if got, want := evicted(), []string{"a", "b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("evicted %v, want %v", got, want)
+	}
+}
+
+func TestRunSnapshotsWritesOnShutdown(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.json")
+	store := NewMemoryStore()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		store.RunSnapshots(ctx, path, 5*time.Millisecond)
+		close(done)
+	}()
+	// Keep changing the store until a tick has written it, which shows the
+	// loop is running.
+	deadline := time.Now().Add(5 * time.Second)
+	for i := 0; ; i++ {
+		save(t, store, fmt.Sprintf("r%d", i), 1, model.StatusQueued)
+		if _, err := os.Stat(path); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("no snapshot written on the interval")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	save(t, store, "last", 1, model.StatusQueued)
+	cancel()
+	<-done
+
+	restored := NewMemoryStore()
+	if _, err := restored.LoadSnapshot(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := restored.Get("last"); err != nil {
+		t.Errorf("record saved before shutdown missing from the snapshot: %v", err)
+	}
+}
+
+func TestRunSnapshotsSkipsUnchangedStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.json")
+	store := NewMemoryStore()
+	save(t, store, "a", 1, model.StatusQueued)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	// Nothing changed since RunSnapshots started, so nothing is written.
+	store.RunSnapshots(ctx, path, time.Hour)
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("snapshot written for an unchanged store: %v", err)
+	}
+}
@@ -0,0 +1,309 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	// The driver registers itself as "sqlite3" with database/sql.
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/model"
+)
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS files (
+	id           TEXT PRIMARY KEY,
+	name         TEXT NOT NULL,
+	size         INTEGER NOT NULL,
+	content_type TEXT NOT NULL,
+	path         TEXT NOT NULL,
+	status       TEXT NOT NULL,
+	message      TEXT NOT NULL DEFAULT '',
+	attributes   TEXT NOT NULL DEFAULT '{}',
+	created_at   INTEGER NOT NULL,
+	updated_at   INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS files_created_at ON files (created_at, id);
+`
+
+// SQLiteStore keeps file records in a SQLite database so the standalone
+// server's metadata survives restarts without Postgres. Timestamps are stored
+// as Unix nanoseconds, which keeps them sortable and exact.
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+// Compile-time check that SQLiteStore satisfies FileStore.
+var _ FileStore = (*SQLiteStore)(nil)
+
+// OpenSQLite opens or creates the database at path and its schema.
+func OpenSQLite(path string) (*SQLiteStore, error) {
+	// WAL lets readers proceed while a write is in progress; the busy
+	// timeout makes concurrent writers wait instead of failing.
+	db, err := sql.Open("sqlite3", "file:"+path+"?_journal_mode=WAL&_busy_timeout=5000")
+	if err != nil {
+		return nil, fmt.Errorf("open sqlite: %w", err)
+	}
+	// SQLite allows one writer at a time anyway; a single connection avoids
+	// lock errors between our own goroutines.
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("create sqlite schema: %w", err)
+	}
+	return &SQLiteStore{db: db}, nil
+}
+
+// Close closes the database.
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+// Save inserts or replaces a record.
+func (s *SQLiteStore) Save(record *model.FileRecord) error {
+	now := time.Now().UTC()
+	if record.CreatedAt.IsZero() {
+		record.CreatedAt = now
+	}
+	record.UpdatedAt = now
+	attrs, err := encodeAttributes(record.Attributes)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`
+		INSERT INTO files (id, name, size, content_type, path, status, message, attributes, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET
+			name=excluded.name, size=excluded.size, content_type=excluded.content_type, path=excluded.path,
+			status=excluded.status, message=excluded.message, attributes=excluded.attributes,
+			created_at=excluded.created_at, updated_at=excluded.updated_at
+	`, record.ID, record.Name, record.Size, record.ContentType, record.Path, record.Status, record.Message, attrs,
+		record.CreatedAt.UnixNano(), record.UpdatedAt.UnixNano())
+	if err != nil {
+		return fmt.Errorf("save file: %w", err)
+	}
+	return nil
+}
+
+// UpdateStatus updates status/message.
+func (s *SQLiteStore) UpdateStatus(id string, status model.FileStatus, msg string) error {
+	res, err := s.db.Exec(`UPDATE files SET status=?, message=?, updated_at=? WHERE id=?`,
+		status, msg, time.Now().UTC().UnixNano(), id)
+	if err != nil {
+		return fmt.Errorf("update file: %w", err)
+	}
+	return requireRow(res)
+}
+
+// SetAttributes merges attrs into the record's attributes.
+func (s *SQLiteStore) SetAttributes(id string, attrs map[string]string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("begin attributes: %w", err)
+	}
+	defer tx.Rollback()
+	var raw string
+	err = tx.QueryRow(`SELECT attributes FROM files WHERE id=?`, id).Scan(&raw)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("select attributes: %w", err)
+	}
+	merged, err := decodeAttributes(raw)
+	if err != nil {
+		return err
+	}
+	if merged == nil {
+		merged = make(map[string]string, len(attrs))
+	}
+	for k, v := range attrs {
+		merged[k] = v
+	}
+	encoded, err := encodeAttributes(merged)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`UPDATE files SET attributes=?, updated_at=? WHERE id=?`, encoded, time.Now().UTC().UnixNano(), id); err != nil {
+		return fmt.Errorf("update attributes: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit attributes: %w", err)
+	}
+	return nil
+}
+
+// Delete removes a record; deleting a missing record is not an error.
+func (s *SQLiteStore) Delete(id string) error {
+	if _, err := s.db.Exec(`DELETE FROM files WHERE id=?`, id); err != nil {
+		return fmt.Errorf("delete file: %w", err)
+	}
+	return nil
+}
+
+const sqliteColumns = `id, name, size, content_type, path, status, message, attributes, created_at, updated_at`
+
+// Get returns a record.
+func (s *SQLiteStore) Get(id string) (*model.FileRecord, error) {
+	rec, err := scanFile(s.db.QueryRow(`SELECT `+sqliteColumns+` FROM files WHERE id=?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("select file: %w", err)
+	}
+	return rec, nil
+}
+
+// List returns records matching filter ordered by creation time, paging by
+// (created_at, id) like MemoryStore.List.
+func (s *SQLiteStore) List(filter ListFilter) (*FilePage, error) {
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	if limit > maxListLimit {
+		limit = maxListLimit
+	}
+	clauses := []string{"1=1"}
+	var args []interface{}
+	if len(filter.Statuses) > 0 {
+		marks := make([]string, len(filter.Statuses))
+		for i, st := range filter.Statuses {
+			marks[i] = "?"
+			args = append(args, st)
+		}
+		clauses = append(clauses, "status IN ("+strings.Join(marks, ",")+")")
+	}
+	if filter.NamePrefix != "" {
+		clauses = append(clauses, `name LIKE ? ESCAPE '\'`)
+		args = append(args, strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(filter.NamePrefix)+"%")
+	}
+	where := strings.Join(clauses, " AND ")
+	page := &FilePage{Files: []model.FileRecord{}}
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM files WHERE `+where, args...).Scan(&page.Total); err != nil {
+		return nil, fmt.Errorf("count files: %w", err)
+	}
+
+	cmp, order := "<", "DESC"
+	if filter.Ascending {
+		cmp, order = ">", "ASC"
+	}
+	if filter.Cursor != "" {
+		at, id, err := decodeCursor(filter.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		where += " AND (created_at, id) " + cmp + " (?, ?)"
+		args = append(args, at.UnixNano(), id)
+	}
+	args = append(args, limit+1, filter.Offset)
+	rows, err := s.db.Query(`SELECT `+sqliteColumns+` FROM files WHERE `+where+
+		` ORDER BY created_at `+order+`, id `+order+` LIMIT ? OFFSET ?`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list files: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		rec, err := scanFile(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan files: %w", err)
+		}
+		page.Files = append(page.Files, *rec)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list files: %w", err)
+	}
+	if len(page.Files) > limit {
+		page.Files = page.Files[:limit]
+		last := page.Files[limit-1]
+		page.NextCursor = encodeCursor(last.CreatedAt, last.ID)
+	}
+	return page, nil
+}
+
+// CountByStatus returns the number of records in each status. Every status
+// is present in the result, with zero when no record has it.
+func (s *SQLiteStore) CountByStatus() (map[model.FileStatus]int, error) {
+	counts := map[model.FileStatus]int{}
+	for _, status := range model.Statuses {
+		counts[status] = 0
+	}
+	rows, err := s.db.Query(`SELECT status, COUNT(*) FROM files GROUP BY status`)
+	if err != nil {
+		return nil, fmt.Errorf("count files: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			status model.FileStatus
+			n      int
+		)
+		if err := rows.Scan(&status, &n); err != nil {
+			return nil, fmt.Errorf("scan file counts: %w", err)
+		}
+		counts[status] = n
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("count files: %w", err)
+	}
+	return counts, nil
+}
+
+// scanFile reads one row selected with sqliteColumns.
+func scanFile(row interface{ Scan(...interface{}) error }) (*model.FileRecord, error) {
+	var (
+		rec                  model.FileRecord
+		attrs                string
+		createdAt, updatedAt int64
+	)
+	err := row.Scan(&rec.ID, &rec.Name, &rec.Size, &rec.ContentType, &rec.Path, &rec.Status, &rec.Message, &attrs, &createdAt, &updatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if rec.Attributes, err = decodeAttributes(attrs); err != nil {
+		return nil, err
+	}
+	rec.CreatedAt = time.Unix(0, createdAt).UTC()
+	rec.UpdatedAt = time.Unix(0, updatedAt).UTC()
+	return &rec, nil
+}
+
+func requireRow(res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func encodeAttributes(attrs map[string]string) (string, error) {
+	if len(attrs) == 0 {
+		return "{}", nil
+	}
+	data, err := json.Marshal(attrs)
+	if err != nil {
+		return "", fmt.Errorf("encode attributes: %w", err)
+	}
+	return string(data), nil
+}
+
+// decodeAttributes returns nil for an empty object, matching records that
+// never had attributes set.
+func decodeAttributes(raw string) (map[string]string, error) {
+	var attrs map[string]string
+	if err := json.Unmarshal([]byte(raw), &attrs); err != nil {
+		return nil, fmt.Errorf("decode attributes: %w", err)
+	}
+	if len(attrs) == 0 {
+		return nil, nil
+	}
+	return attrs, nil
+}
@@ -0,0 +1,19 @@
+//go:build cgo
+
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func init() {
+	fileStores["sqlite"] = func(t *testing.T) FileStore {
+		db, err := OpenSQLite(filepath.Join(t.TempDir(), "files.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+		return db
+	}
+}
@@ -0,0 +1,23 @@
+package storage
+
+import "github.com/dharsanguruparan/VaultDrop/internal/model"
+
+// FileStore keeps the standalone server's file records. MemoryStore is the
+// default; SQLiteStore keeps records on disk across restarts.
+type FileStore interface {
+	// Save inserts or replaces a record, setting its timestamps.
+	Save(record *model.FileRecord) error
+	UpdateStatus(id string, status model.FileStatus, msg string) error
+	// SetAttributes merges attrs into the record's attributes.
+	SetAttributes(id string, attrs map[string]string) error
+	// Delete removes a record; deleting a missing record is not an error.
+	Delete(id string) error
+	// Get returns a copy of the record, or ErrNotFound.
+	Get(id string) (*model.FileRecord, error)
+	List(filter ListFilter) (*FilePage, error)
+	// CountByStatus has an entry for every status, zero when unused.
+	CountByStatus() (map[model.FileStatus]int, error)
+}
+
+// Compile-time check that MemoryStore satisfies FileStore.
+var _ FileStore = (*MemoryStore)(nil)
@@ -0,0 +1,131 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/model"
+)
+
+// fileStores builds each FileStore backend under test. sqlite_test.go adds
+// SQLite when cgo is available, since its driver needs it.
+var fileStores = map[string]func(t *testing.T) FileStore{
+	"memory": func(*testing.T) FileStore { return NewMemoryStore() },
+}
+
+// TestFileStores runs the same checks against both FileStore backends.
+func TestFileStores(t *testing.T) {
+	for name, open := range fileStores {
+		t.Run(name, func(t *testing.T) { testFileStore(t, open(t)) })
+	}
+}
+
+func testFileStore(t *testing.T, store FileStore) {
+	for _, rec := range []struct {
+		id     string
+		status model.FileStatus
+	}{
+		{"a", model.StatusScanned},
+		{"b", model.StatusRetrying},
+		{"c", model.StatusRejected},
+		{"d", model.StatusComplete},
+	} {
+		if err := store.Save(&model.FileRecord{ID: rec.id, Name: rec.id + ".pdf", Size: 10, Status: rec.status}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rec, err := store.Get("b")
+	if err != nil || rec.Status != model.StatusRetrying || rec.Name != "b.pdf" || rec.Size != 10 || rec.CreatedAt.IsZero() {
+		t.Fatalf("Get(b) = %+v, %v", rec, err)
+	}
+	if _, err := store.Get("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get(missing) error = %v, want ErrNotFound", err)
+	}
+
+	counts, err := store.CountByStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(counts) != len(model.Statuses) {
+		t.Errorf("counts has %d statuses, want %d", len(counts), len(model.Statuses))
+	}
+	for _, s := range model.Statuses {
+		want := 0
+		switch s {
+		case model.StatusScanned, model.StatusRetrying, model.StatusRejected, model.StatusComplete:
+			want = 1
+		}
+		if counts[s] != want {
+			t.Errorf("counts[%s] = %d, want %d", s, counts[s], want)
+		}
+	}
+
+	page, err := store.List(ListFilter{Statuses: []model.FileStatus{model.StatusRejected, model.StatusComplete}, Ascending: true})
+	if err != nil || page.Total != 2 || len(page.Files) != 2 || page.Files[0].ID != "c" || page.Files[1].ID != "d" {
+		t.Fatalf("List(rejected, complete) = %+v, %v", page, err)
+	}
+	if _, err := store.List(ListFilter{Cursor: "bogus!"}); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("List(bad cursor) error = %v, want ErrInvalidCursor", err)
+	}
+
+	var seen []string
+	filter := ListFilter{Ascending: true, Limit: 3}
+	for {
+		page, err := store.List(filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if page.Total != 4 {
+			t.Errorf("Total = %d, want 4", page.Total)
+		}
+		for _, f := range page.Files {
+			seen = append(seen, f.ID)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		filter.Cursor = page.NextCursor
+	}
+	if len(seen) != 4 || seen[0] != "a" || seen[3] != "d" {
+		t.Errorf("paged through %v, want a b c d", seen)
+	}
+
+	page, err = store.List(ListFilter{Ascending: true, Limit: 2, Offset: 1})
+	if err != nil || len(page.Files) != 2 || page.Files[0].ID != "b" || page.Files[1].ID != "c" {
+		t.Errorf("List(offset 1) = %+v, %v", page, err)
+	}
+
+	if err := store.UpdateStatus("a", model.StatusFailed, "boom"); err != nil {
+		t.Fatal(err)
+	}
+	if rec, _ := store.Get("a"); rec.Status != model.StatusFailed || rec.Message != "boom" {
+		t.Errorf("after UpdateStatus: %+v", rec)
+	}
+	if err := store.UpdateStatus("missing", model.StatusProcessing, ""); !errors.Is(err, ErrNotFound) {
+		t.Errorf("UpdateStatus(missing) error = %v, want ErrNotFound", err)
+	}
+
+	if err := store.SetAttributes("d", map[string]string{"sha256": "x"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SetAttributes("d", map[string]string{"thumbnail": "1x1"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := store.Get("d"); got.Attributes["sha256"] != "x" || got.Attributes["thumbnail"] != "1x1" {
+		t.Errorf("attributes = %v", got.Attributes)
+	}
+	if err := store.SetAttributes("missing", map[string]string{"sha256": "x"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("SetAttributes(missing) error = %v, want ErrNotFound", err)
+	}
+
+	if err := store.Delete("d"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Get("d"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get after Delete error = %v, want ErrNotFound", err)
+	}
+	if err := store.Delete("d"); err != nil {
+		t.Errorf("Delete(missing) = %v, want nil", err)
+	}
+}
@@ -0,0 +1,235 @@
+// Package textdiff produces line-based unified diffs using Myers' algorithm.
+package textdiff
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrTooManyChanges is returned when the inputs differ in more lines than the
+// caller allowed, bounding the time and memory a diff can take.
+var ErrTooManyChanges = errors.New("textdiff: too many changes")
+
+type opKind int
+
+const (
+	opEqual opKind = iota
+	opDelete
+	opInsert
+)
+
+type edit struct {
+	kind opKind
+	text string
+}
+
+// Unified returns a unified diff turning a into b with the given number of
+// context lines. It returns an empty string when the texts are equal and
+// ErrTooManyChanges when more than maxEdits lines were inserted or deleted.
+func Unified(a, b, fromName, toName string, context, maxEdits int) (string, error) {
+	edits, err := diffLines(splitLines(a), splitLines(b), maxEdits)
+	if err != nil {
+		return "", err
+	}
+	changed := false
+	for _, e := range edits {
+		if e.kind != opEqual {
+			changed = true
+			break
+		}
+	}
+	if !changed {
+		return "", nil
+	}
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", fromName, toName)
+	writeHunks(&out, edits, context)
+	return out.String(), nil
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.Split(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffLines strips the common prefix and suffix before running Myers on the
+// remainder, which keeps typical small edits to large documents cheap.
+func diffLines(a, b []string, maxEdits int) ([]edit, error) {
+	pre := 0
+	for pre < len(a) && pre < len(b) && a[pre] == b[pre] {
+		pre++
+	}
+	suf := 0
+	for suf < len(a)-pre && suf < len(b)-pre && a[len(a)-1-suf] == b[len(b)-1-suf] {
+		suf++
+	}
+	mid, err := myers(a[pre:len(a)-suf], b[pre:len(b)-suf], maxEdits)
+	if err != nil {
+		return nil, err
+	}
+	edits := make([]edit, 0, pre+len(mid)+suf)
+	for _, line := range a[:pre] {
+		edits = append(edits, edit{opEqual, line})
+	}
+	edits = append(edits, mid...)
+	for _, line := range a[len(a)-suf:] {
+		edits = append(edits, edit{opEqual, line})
+	}
+	return edits, nil
+}
+
+// myers finds a shortest edit script. Only the diagonals reachable at each
+// step are kept in the trace, so memory grows with the square of the number
+// of edits rather than the input size.
+func myers(a, b []string, maxEdits int) ([]edit, error) {
+	n, m := len(a), len(b)
+	if n+m == 0 {
+		return nil, nil
+	}
+	offset := n + m
+	v := make([]int, 2*offset+2)
+	var trace [][]int
+	for d := 0; d <= n+m; d++ {
+		if d > maxEdits {
+			return nil, ErrTooManyChanges
+		}
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return backtrack(a, b, trace, d), nil
+			}
+		}
+		snapshot := make([]int, 2*d+1)
+		copy(snapshot, v[offset-d:offset+d+1])
+		trace = append(trace, snapshot)
+	}
+	return nil, ErrTooManyChanges
+}
+
+func backtrack(a, b []string, trace [][]int, depth int) []edit {
+	var rev []edit
+	x, y := len(a), len(b)
+	for d := depth; d > 0; d-- {
+		prev := trace[d-1]
+		at := func(k int) int { return prev[k+d-1] }
+		k := x - y
+		prevK := k - 1
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		}
+		prevX := at(prevK)
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			rev = append(rev, edit{opEqual, a[x-1]})
+			x--
+			y--
+		}
+		if x == prevX {
+			rev = append(rev, edit{opInsert, b[y-1]})
+			y--
+		} else {
+			rev = append(rev, edit{opDelete, a[x-1]})
+			x--
+		}
+	}
+	for x > 0 && y > 0 {
+		rev = append(rev, edit{opEqual, a[x-1]})
+		x--
+		y--
+	}
+	edits := make([]edit, len(rev))
+	for i, e := range rev {
+		edits[len(rev)-1-i] = e
+	}
+	return edits
+}
+
+// writeHunks groups changes separated by at most 2*context equal lines into
+// one hunk, as diff -u does.
+func writeHunks(out *strings.Builder, edits []edit, context int) {
+	// aPos[i] and bPos[i] are the 0-based line numbers before edits[i].
+	aPos := make([]int, len(edits)+1)
+	bPos := make([]int, len(edits)+1)
+	for i, e := range edits {
+		aPos[i+1], bPos[i+1] = aPos[i], bPos[i]
+		if e.kind != opInsert {
+			aPos[i+1]++
+		}
+		if e.kind != opDelete {
+			bPos[i+1]++
+		}
+	}
+	for i := 0; i < len(edits); {
+		if edits[i].kind == opEqual {
+			i++
+			continue
+		}
+		start := i - context
+		if start < 0 {
+			start = 0
+		}
+		end := i
+		for end < len(edits) {
+			if edits[end].kind != opEqual {
+				end++
+				continue
+			}
+			run := end
+			for run < len(edits) && edits[run].kind == opEqual {
+				run++
+			}
+			if run == len(edits) || run-end > 2*context {
+				end += context
+				if end > run {
+					end = run
+				}
+				break
+			}
+			end = run
+		}
+		fmt.Fprintf(out, "@@ -%s +%s @@\n",
+			hunkRange(aPos[start], aPos[end]-aPos[start]),
+			hunkRange(bPos[start], bPos[end]-bPos[start]))
+		for _, e := range edits[start:end] {
+			prefix := " "
+			switch e.kind {
+			case opDelete:
+				prefix = "-"
+			case opInsert:
+				prefix = "+"
+			}
+			out.WriteString(prefix + e.text + "\n")
+		}
+		i = end
+	}
+}
+
+// hunkRange formats a range the way diff -u does: an empty range is
+// reported at the line before it.
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
@@ -0,0 +1,50 @@
+package textdiff
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestUnified(t *testing.T) {
+	a := "one\ntwo\nthree\nfour\nfive\nsix\nseven\neight\nnine\nten\n"
+	b := "one\ntwo\nTHREE\nfour\nfive\nsix\nseven\neight\nnine\nten\neleven\n"
+	got, err := Unified(a, b, "v1", "v2", 2, 100)
+	if err != nil {
+		t.Fatalf("Unified: %v", err)
+	}
+	want := strings.Join([]string{
+		"--- v1",
+		"+++ v2",
+		"@@ -1,5 +1,5 @@",
+		" one",
+		" two",
+		"-three",
+		"+THREE",
+		" four",
+		" five",
+		"@@ -9,2 +9,3 @@",
+		" nine",
+		" ten",
+		"+eleven",
+		"",
+	}, "\n")
+	if got != want {
+		t.Fatalf("diff mismatch\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestUnifiedEqual(t *testing.T) {
+	got, err := Unified("same\n", "same\n", "a", "b", 3, 10)
+	if err != nil || got != "" {
+		t.Fatalf("expected empty diff, got %q, %v", got, err)
+	}
+}
+
+func TestUnifiedTooManyChanges(t *testing.T) {
+	a := strings.Repeat("a\n", 50)
+	b := strings.Repeat("b\n", 50)
+	if _, err := Unified(a, b, "a", "b", 3, 10); !errors.Is(err, ErrTooManyChanges) {
+		t.Fatalf("expected ErrTooManyChanges, got %v", err)
+	}
+}
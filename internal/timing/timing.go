@@ -0,0 +1,117 @@
+// Package timing collects per-request durations for the Server-Timing
+// response header. A Recorder travels in the request context; the database
+// tracer, the S3 transport and handlers add to it without knowing about HTTP.
+package timing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+type contextKey struct{}
+
+// Recorder accumulates named durations. It is safe for concurrent use.
+type Recorder struct {
+	mu      sync.Mutex
+	start   time.Time
+	metrics map[string]*metric
+	order   []string
+}
+
+type metric struct {
+	dur   time.Duration
+	count int
+}
+
+// NewRecorder starts a recorder whose total is measured from now.
+func NewRecorder() *Recorder {
+	return &Recorder{start: time.Now(), metrics: map[string]*metric{}}
+}
+
+// NewContext returns ctx carrying rec.
+func NewContext(ctx context.Context, rec *Recorder) context.Context {
+	return context.WithValue(ctx, contextKey{}, rec)
+}
+
+// FromContext returns the recorder in ctx, or nil.
+func FromContext(ctx context.Context) *Recorder {
+	rec, _ := ctx.Value(contextKey{}).(*Recorder)
+	return rec
+}
+
+// Add records one operation of the named kind. A nil recorder ignores it, so
+// callers outside a request (the worker, CLI tools) need no special casing.
+func (r *Recorder) Add(name string, d time.Duration) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	m, ok := r.metrics[name]
+	if !ok {
+		m = &metric{}
+		r.metrics[name] = m
+		r.order = append(r.order, name)
+	}
+	m.dur += d
+	m.count++
+}
+
+// Track starts timing an operation and returns the func that stops it:
+//
+//	defer timing.Track(ctx, "scan")()
+func Track(ctx context.Context, name string) func() {
+	rec := FromContext(ctx)
+	if rec == nil {
+		return func() {}
+	}
+	start := time.Now()
+	return func() { rec.Add(name, time.Since(start)) }
+}
+
+// Header renders the Server-Timing value: every recorded metric in first-use
+// order, then total, then the time left before ctx's deadline when it has
+// one. Durations are milliseconds as the spec requires.
+func (r *Recorder) Header(ctx context.Context) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	parts := make([]string, 0, len(r.order)+2)
+	for _, name := range r.order {
+		m := r.metrics[name]
+		parts = append(parts, fmt.Sprintf(`%s;dur=%s;desc="%d op(s)"`, name, millis(m.dur), m.count))
+	}
+	parts = append(parts, "total;dur="+millis(time.Since(r.start)))
+	if deadline, ok := ctx.Deadline(); ok {
+		remaining := time.Until(deadline)
+		if remaining < 0 {
+			remaining = 0
+		}
+		parts = append(parts, "deadline;dur="+millis(remaining)+`;desc="remaining"`)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func millis(d time.Duration) string {
+	return fmt.Sprintf("%.1f", float64(d)/float64(time.Millisecond))
+}
+
+// Transport wraps an http.RoundTripper so every request made with a context
+// carrying a Recorder is recorded under name, from sending the request until
+// the response headers arrive.
+func Transport(base http.RoundTripper, name string) http.RoundTripper {
+	return roundTripper{base: base, name: name}
+}
+
+type roundTripper struct {
+	base http.RoundTripper
+	name string
+}
+
+func (t roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	defer Track(req.Context(), t.name)()
+	return t.base.RoundTrip(req)
+}
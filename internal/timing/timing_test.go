@@ -0,0 +1,36 @@
+package timing
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHeader(t *testing.T) {
+	rec := NewRecorder()
+	rec.Add("db", 2*time.Millisecond)
+	rec.Add("s3", 10*time.Millisecond)
+	rec.Add("db", 3*time.Millisecond)
+
+	got := rec.Header(context.Background())
+	if !strings.HasPrefix(got, `db;dur=5.0;desc="2 op(s)", s3;dur=10.0;desc="1 op(s)", total;dur=`) {
+		t.Fatalf("unexpected header %q", got)
+	}
+	if strings.Contains(got, "deadline") {
+		t.Fatalf("deadline reported without one: %q", got)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if got := rec.Header(ctx); !strings.Contains(got, `deadline;dur=`) {
+		t.Fatalf("missing deadline in %q", got)
+	}
+}
+
+func TestTrackWithoutRecorder(t *testing.T) {
+	// Must not panic outside a request.
+	Track(context.Background(), "db")()
+	var rec *Recorder
+	rec.Add("db", time.Second)
+}
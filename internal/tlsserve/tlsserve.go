@@ -0,0 +1,143 @@
+// Package tlsserve runs the API and the standalone server over HTTPS when the
+// configuration asks for it, from a certificate on disk or one obtained from
+// Let's Encrypt, and over plain HTTP otherwise.
+package tlsserve
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/acme/autocert"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/config"
+)
+
+// ListenAndServe serves srv on srv.Addr with the TLS setup cfg describes. It
+// returns http.ErrServerClosed after srv.Shutdown like the net/http methods,
+// and stops the optional challenge/redirect listener when ctx is cancelled.
+func ListenAndServe(ctx context.Context, srv *http.Server, cfg *config.Config) error {
+	if !cfg.TLSEnabled() {
+		return srv.ListenAndServe()
+	}
+	redirect := redirectHTTPS(srv.Addr)
+	if len(cfg.TLSAutocertDomains) > 0 {
+		m := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(cfg.TLSAutocertDomains...),
+			Cache:      autocert.DirCache(cfg.TLSAutocertCache),
+			Email:      cfg.TLSAutocertEmail,
+		}
+		srv.TLSConfig = baseConfig()
+		srv.TLSConfig.GetCertificate = m.GetCertificate
+		// acme-tls/1 lets the TLS listener answer challenges by itself.
+		srv.TLSConfig.NextProtos = append(srv.TLSConfig.NextProtos, "h2", "http/1.1", "acme-tls/1")
+		redirect = m.HTTPHandler(redirect)
+	} else {
+		pair, err := loadKeyPair(cfg.TLSCert, cfg.TLSKey)
+		if err != nil {
+			return err
+		}
+		srv.TLSConfig = baseConfig()
+		srv.TLSConfig.GetCertificate = pair.getCertificate
+	}
+	if cfg.TLSHTTPAddress != "" {
+		go serveRedirect(ctx, cfg.HTTPServer(cfg.TLSHTTPAddress, redirect))
+	}
+	return srv.ListenAndServeTLS("", "")
+}
+
+// baseConfig allows TLS 1.2 and later only. Go's default cipher suites and
+// curve preferences are already restricted to modern choices.
+func baseConfig() *tls.Config {
+	return &tls.Config{MinVersion: tls.VersionTLS12}
+}
+
+// serveRedirect runs the plain HTTP listener srv until ctx is cancelled. A
+// failure is logged rather than fatal: HTTPS keeps working without it.
+func serveRedirect(ctx context.Context, srv *http.Server) {
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+	log.Printf("redirecting http on %s to https", srv.Addr)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Printf("http redirect listener: %v", err)
+	}
+}
+
+// redirectHTTPS sends requests to the same host on the port of the HTTPS
+// listener at addr, omitting it when it is the default 443.
+func redirectHTTPS(addr string) http.Handler {
+	_, port, _ := net.SplitHostPort(addr)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if port != "" && port != "443" {
+			host = net.JoinHostPort(host, port)
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+	})
+}
+
+// keyPair serves a certificate from disk and reloads it when the certificate
+// file's modification time changes, so renewals are picked up on the next
+// handshake. A broken renewal keeps the previous certificate in use.
+type keyPair struct {
+	certFile, keyFile string
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	modTime time.Time
+	checked time.Time
+}
+
+// reloadCheckInterval bounds how often handshakes stat the certificate file.
+const reloadCheckInterval = 10 * time.Second
+
+func loadKeyPair(certFile, keyFile string) (*keyPair, error) {
+	p := &keyPair{certFile: certFile, keyFile: keyFile}
+	info, err := os.Stat(certFile)
+	if err != nil {
+		return nil, fmt.Errorf("tls certificate: %w", err)
+	}
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("tls key pair: %w", err)
+	}
+	p.cert, p.modTime, p.checked = &cert, info.ModTime(), time.Now()
+	return p, nil
+}
+
+func (p *keyPair) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if time.Since(p.checked) < reloadCheckInterval {
+		return p.cert, nil
+	}
+	p.checked = time.Now()
+	info, err := os.Stat(p.certFile)
+	if err != nil || info.ModTime().Equal(p.modTime) {
+		return p.cert, nil
+	}
+	cert, err := tls.LoadX509KeyPair(p.certFile, p.keyFile)
+	if err != nil {
+		// The key may not have been replaced yet; try again next time.
+		log.Printf("reload tls key pair: %v", err)
+		return p.cert, nil
+	}
+	p.cert, p.modTime = &cert, info.ModTime()
+	log.Printf("reloaded tls certificate %s", p.certFile)
+	return p.cert, nil
+}
@@ -0,0 +1,82 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/hibiken/asynq"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/repository"
+	"github.com/dharsanguruparan/VaultDrop/internal/s3storage"
+)
+
+// handleArchive moves the extracted text of documents older than the archive
+// period, and the text of each of their versions, from the database to
+// objects in the processed bucket, so the database stops growing with every
+// upload. The object is written before the column is cleared; a sweep that
+// stops in between leaves an object the next sweep overwrites.
+func (p *Processor) handleArchive(ctx context.Context, _ *asynq.Task) error {
+	if p.retention.Archive <= 0 {
+		return nil
+	}
+	cutoff := time.Now().UTC().Add(-p.retention.Archive)
+	archived := 0
+	defer func() {
+		if archived > 0 {
+			log.Printf("archive: moved text of %d documents to object storage", archived)
+		}
+	}()
+	for {
+		ids, err := p.repo.ListArchivable(ctx, cutoff, retentionBatch)
+		if err != nil {
+			return err
+		}
+		moved := 0
+		for _, id := range ids {
+			ok, err := p.archiveText(ctx, id)
+			if err != nil {
+				return fmt.Errorf("archive text of %s: %w", id, err)
+			}
+			if ok {
+				moved++
+			}
+		}
+		archived += moved
+		// Documents changed while being archived are listed again; stop
+		// rather than spin on them until the next sweep.
+		if len(ids) < retentionBatch || moved == 0 {
+			return nil
+		}
+	}
+}
+
+// archiveText archives one document and reports whether its column was
+// cleared.
+func (p *Processor) archiveText(ctx context.Context, id string) (bool, error) {
+	doc, err := p.repo.Get(ctx, id)
+	if err != nil {
+		return false, err
+	}
+	if doc.Status != repository.StatusCompleted || doc.ArchivedAt != nil || doc.Content == "" {
+		return false, nil
+	}
+	versions, err := p.repo.UnarchivedVersions(ctx, doc.ID)
+	if err != nil {
+		return false, err
+	}
+	archived := make([]repository.ArchivedVersion, 0, len(versions))
+	for _, v := range versions {
+		key := s3storage.VersionArchiveKey(doc.ID, v.Version)
+		if err := p.store.UploadProcessed(ctx, key, []byte(v.Content)); err != nil {
+			return false, err
+		}
+		archived = append(archived, repository.ArchivedVersion{Version: v.Version, Key: key})
+	}
+	key := s3storage.ArchiveKey(doc.ID)
+	if err := p.store.UploadProcessed(ctx, key, []byte(doc.Content)); err != nil {
+		return false, err
+	}
+	return p.repo.MarkArchived(ctx, doc.ID, key, doc.UpdatedAt, archived)
+}
@@ -0,0 +1,29 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"github.com/hibiken/asynq"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/maintenance"
+)
+
+// UseCleanup turns on the orphan cleanup task, which leaves alone anything
+// younger than grace and only reports when dryRun is set. Call it before
+// Handler.
+func (p *Processor) UseCleanup(grace time.Duration, dryRun bool) {
+	p.cleanupGrace = grace
+	p.cleanupDryRun = dryRun
+}
+
+// handleCleanup removes objects whose document is gone and reports documents
+// whose raw upload is.
+func (p *Processor) handleCleanup(ctx context.Context, _ *asynq.Task) error {
+	if p.cleanupGrace <= 0 {
+		return nil
+	}
+	rep, err := maintenance.CleanOrphans(ctx, p.repo, p.store, time.Now().Add(-p.cleanupGrace), p.cleanupDryRun)
+	maintenance.LogReport(rep)
+	return err
+}
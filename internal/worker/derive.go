@@ -0,0 +1,68 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/hibiken/asynq"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/queue"
+	"github.com/dharsanguruparan/VaultDrop/internal/repository"
+)
+
+// deriver builds one derived artifact for a completed document.
+type deriver func(ctx context.Context, p *Processor, doc *repository.Document) error
+
+// derivers holds the derived-artifact stages this worker implements, keyed by
+// stage name. New pipeline stages register here; `vaultdrop admin backfill`
+// refuses stages that are not listed.
+var derivers = map[string]deriver{
+	LayoutStage: deriveLayout,
+}
+
+// DerivedStages lists the implemented stages in name order.
+func DerivedStages() []string {
+	stages := make([]string, 0, len(derivers))
+	for stage := range derivers {
+		stages = append(stages, stage)
+	}
+	sort.Strings(stages)
+	return stages
+}
+
+// HasStage reports whether the worker can run stage.
+func HasStage(stage string) bool {
+	_, ok := derivers[stage]
+	return ok
+}
+
+// handleDerive runs a stage and records its artifact. Documents that were
+// deleted or are no longer completed by the time the task runs are skipped.
+func (p *Processor) handleDerive(stage string, derive deriver) asynq.HandlerFunc {
+	return func(ctx context.Context, task *asynq.Task) error {
+		var payload queue.DerivePayload
+		if err := json.Unmarshal(task.Payload(), &payload); err != nil {
+			return fmt.Errorf("decode payload: %w", err)
+		}
+		doc, err := p.repo.Get(ctx, payload.DocumentID)
+		if errors.Is(err, repository.ErrNotFound) {
+			log.Printf("%s: document %s is gone, skipping", stage, payload.DocumentID)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if doc.Status != repository.StatusCompleted {
+			log.Printf("%s: document %s is %s, skipping", stage, doc.ID, doc.Status)
+			return nil
+		}
+		if err := derive(ctx, p, doc); err != nil {
+			return fmt.Errorf("%s for %s: %w", stage, doc.ID, err)
+		}
+		return p.repo.RecordArtifact(ctx, doc.ID, stage)
+	}
+}
@@ -0,0 +1,262 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/hibiken/asynq"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/queue"
+	"github.com/dharsanguruparan/VaultDrop/internal/repository"
+	"github.com/dharsanguruparan/VaultDrop/internal/s3storage"
+)
+
+func (p *Processor) handleErase(ctx context.Context, task *asynq.Task) error {
+	var payload queue.ErasurePayload
+	if err := json.Unmarshal(task.Payload(), &payload); err != nil {
+		return fmt.Errorf("decode payload: %w", err)
+	}
+	req, err := p.repo.GetErasure(ctx, payload.RequestID)
+	if err != nil {
+		return err
+	}
+	if req.Status == repository.ErasureCompleted {
+		return nil
+	}
+	if err := p.repo.MarkErasureRunning(ctx, req.ID); err != nil {
+		return err
+	}
+	fail := func(err error) error {
+		_ = p.repo.FailErasure(ctx, req.ID, err.Error())
+		return err
+	}
+	ids := req.DocumentIDs
+	if req.OwnerID != "" {
+		// A retry lists the owner again; documents an earlier attempt
+		// erased stay in the request as its record.
+		if ids, err = p.ownerDocuments(ctx, req.OwnerID, ids); err != nil {
+			return fail(err)
+		}
+		if err := p.repo.SetErasureDocuments(ctx, req.ID, ids); err != nil {
+			return fail(err)
+		}
+	}
+	report := &repository.ErasureReport{Verified: true}
+	for len(ids) > 0 {
+		for _, id := range ids {
+			item, err := p.eraseDocument(ctx, req.ID, id)
+			if err != nil {
+				log.Printf("erasure %s failed on %s: %v", req.ID, id, err)
+				return fail(err)
+			}
+			if item.Found || item.ErasedEarlier {
+				report.Erased++
+			} else {
+				report.Missing++
+			}
+			report.Verified = report.Verified && item.Verified
+			report.Items = append(report.Items, item)
+			if err := p.repo.ReportErasureProgress(ctx, req.ID, len(report.Items)); err != nil {
+				log.Printf("erasure %s: %v", req.ID, err)
+			}
+		}
+		if req.OwnerID == "" {
+			break
+		}
+		// Documents the owner uploaded while the job ran are erased too.
+		done := make([]string, len(report.Items))
+		for i, item := range report.Items {
+			done[i] = item.DocumentID
+		}
+		all, err := p.ownerDocuments(ctx, req.OwnerID, done)
+		if err != nil {
+			return fail(err)
+		}
+		if ids = all[len(done):]; len(ids) > 0 {
+			if err := p.repo.SetErasureDocuments(ctx, req.ID, all); err != nil {
+				return fail(err)
+			}
+			if err := p.repo.ReportErasureProgress(ctx, req.ID, len(done)); err != nil {
+				log.Printf("erasure %s: %v", req.ID, err)
+			}
+		}
+	}
+	erased := make([]string, len(report.Items))
+	for i, item := range report.Items {
+		erased[i] = item.DocumentID
+	}
+	var exportsGone bool
+	if report.ExportsDeleted, exportsGone, err = p.eraseExports(ctx, erased); err != nil {
+		return fail(err)
+	}
+	report.Verified = report.Verified && exportsGone
+	if req.OwnerID != "" {
+		if report.RejectionsDeleted, report.DropsDeleted, err = p.repo.EraseOwnerRecords(ctx, req.OwnerID); err != nil {
+			return fail(err)
+		}
+	}
+	if err := p.repo.CompleteErasure(ctx, req.ID, report); err != nil {
+		return err
+	}
+	log.Printf("erasure %s completed (%d erased, %d missing)", req.ID, report.Erased, report.Missing)
+	return nil
+}
+
+// ownerDocuments returns known followed by the documents owner still has
+// that are not in it.
+func (p *Processor) ownerDocuments(ctx context.Context, owner string, known []string) ([]string, error) {
+	owned, err := p.repo.ListOwnerDocumentIDs(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool, len(known))
+	ids := append([]string{}, known...)
+	for _, id := range known {
+		seen[id] = true
+	}
+	for _, id := range owned {
+		if !seen[id] {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// extractWait bounds how long an erasure waits for an extraction of the
+// document to stop. Past it the erasure fails and asynq retries it later.
+const extractWait = time.Minute
+
+// eraseDocument removes every artifact for a document and then re-checks each
+// location, every version included, so the report reflects what is actually
+// left behind.
+func (p *Processor) eraseDocument(ctx context.Context, erasureID, id string) (repository.ErasureItem, error) {
+	item := repository.ErasureItem{DocumentID: id}
+	doc, err := p.repo.Get(ctx, id)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		return item, err
+	}
+	if doc == nil {
+		// A retry finds the documents its earlier attempt erased gone.
+		if item.ErasedEarlier, err = p.repo.ErasedBy(ctx, id, erasureID); err != nil {
+			return item, err
+		}
+	}
+	var processed []string
+	if doc != nil {
+		item.Found = true
+		// An extraction in flight would upload the text again once it is
+		// removed.
+		if err := p.stopExtraction(ctx, id); err != nil {
+			return item, err
+		}
+		if err := p.store.RemoveRaw(ctx, doc.ObjectKey); err != nil {
+			return item, err
+		}
+		item.RawDeleted = true
+		// A run that never completed leaves its text under the derived key
+		// without recording it.
+		processed = []string{processedObjectKey(doc.ObjectKey)}
+		if doc.ProcessedKey != nil && *doc.ProcessedKey != processed[0] {
+			processed = append(processed, *doc.ProcessedKey)
+		}
+		for _, key := range processed {
+			if err := p.store.RemoveProcessed(ctx, key); err != nil {
+				return item, err
+			}
+		}
+		item.ProcessedDeleted = true
+		if doc.ArchiveKey != nil {
+			if err := p.store.RemoveArchive(ctx, *doc.ArchiveKey); err != nil {
+				return item, err
+			}
+		}
+		if err := p.store.RemoveVersionArchives(ctx, id); err != nil {
+			return item, err
+		}
+	}
+	deleted, err := p.repo.EraseDocument(ctx, id, erasureID)
+	if err != nil {
+		return item, err
+	}
+	item.RowDeleted = deleted
+	if _, err := p.repo.Get(ctx, id); !errors.Is(err, repository.ErrNotFound) {
+		return item, nil
+	}
+	if doc != nil {
+		if retained, err := p.store.RawRetained(ctx, doc.ObjectKey); err != nil || retained {
+			return item, err
+		}
+		for _, key := range processed {
+			for _, k := range []string{key, s3storage.LayoutKey(key)} {
+				if retained, err := p.store.ProcessedRetained(ctx, k); err != nil || retained {
+					return item, err
+				}
+			}
+		}
+		if doc.ArchiveKey != nil {
+			if retained, err := p.store.ProcessedRetained(ctx, *doc.ArchiveKey); err != nil || retained {
+				return item, err
+			}
+		}
+		if retained, err := p.store.VersionArchivesRetained(ctx, id); err != nil || retained {
+			return item, err
+		}
+	}
+	item.Verified = true
+	return item, nil
+}
+
+// stopExtraction cancels the document, which an extraction checks between
+// stages, and waits for any attempt still running to finish.
+func (p *Processor) stopExtraction(ctx context.Context, id string) error {
+	if _, err := p.repo.CancelForErasure(ctx, id); err != nil {
+		return err
+	}
+	// Unfinished attempts older than the extraction deadline belong to
+	// workers that died.
+	since := time.Now().Add(-p.extractOpts.Timeout - time.Minute)
+	deadline := time.Now().Add(extractWait)
+	for {
+		running, err := p.repo.RunningAttempts(ctx, id, since)
+		if err != nil || running == 0 {
+			return err
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("extraction of %s still running after %s", id, extractWait)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// eraseExports removes the exports that hold any of the documents ids, as
+// they copy the documents' content and names, and reports whether none of
+// their ZIPs is left. The exports are expired first, so a build still running
+// removes its own ZIP instead of completing.
+func (p *Processor) eraseExports(ctx context.Context, ids []string) (int, bool, error) {
+	exports, err := p.repo.ExpireExportsOf(ctx, ids)
+	if err != nil || len(exports) == 0 {
+		return 0, true, err
+	}
+	for _, id := range exports {
+		if err := p.store.RemoveProcessedObject(ctx, s3storage.ExportKey(id)); err != nil {
+			return 0, false, err
+		}
+	}
+	if err := p.repo.DeleteExports(ctx, exports); err != nil {
+		return 0, false, err
+	}
+	for _, id := range exports {
+		if retained, err := p.store.ProcessedRetained(ctx, s3storage.ExportKey(id)); err != nil || retained {
+			return len(exports), false, err
+		}
+	}
+	return len(exports), true, nil
+}
@@ -0,0 +1,112 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+
+	"github.com/hibiken/asynq"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/export"
+	"github.com/dharsanguruparan/VaultDrop/internal/queue"
+	"github.com/dharsanguruparan/VaultDrop/internal/repository"
+	"github.com/dharsanguruparan/VaultDrop/internal/s3storage"
+)
+
+// handleExport builds the ZIP of a background export, streaming it into the
+// processed bucket as it is written, encrypted with the export's data key when
+// it has one. A retry rebuilds it from the start.
+func (p *Processor) handleExport(ctx context.Context, task *asynq.Task) error {
+	var payload queue.ExportPayload
+	if err := json.Unmarshal(task.Payload(), &payload); err != nil {
+		return fmt.Errorf("decode payload: %w", err)
+	}
+	exp, err := p.repo.GetExport(ctx, payload.ExportID, "")
+	if errors.Is(err, repository.ErrExportNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	running, err := p.repo.MarkExportRunning(ctx, exp.ID)
+	if err != nil || !running {
+		return err
+	}
+	artifact, err := export.ParseArtifact(exp.Artifact)
+	if err != nil {
+		_ = p.repo.FailExport(ctx, exp.ID, err.Error())
+		return fmt.Errorf("%v: %w", err, asynq.SkipRetry)
+	}
+
+	var dataKey []byte
+	if exp.DataKey != nil {
+		if dataKey, err = p.keys.Unwrap(exp.ID, *exp.DataKey); err != nil {
+			_ = p.repo.FailExport(ctx, exp.ID, err.Error())
+			return fmt.Errorf("unwrap export key: %v: %w", err, asynq.SkipRetry)
+		}
+	}
+
+	key := s3storage.ExportKey(exp.ID)
+	pr, pw := io.Pipe()
+	counted := &countingWriter{w: pw}
+	built := make(chan *export.Manifest, 1)
+	go func() {
+		manifest, err := export.New(p.repo, p.store, p.keys).Write(ctx, counted, exp.DocumentIDs, exp.Scope, artifact)
+		pw.CloseWithError(err)
+		built <- manifest
+	}()
+	err = p.store.UploadExport(ctx, key, pr, dataKey)
+	// Stop the writer if the upload gave up first.
+	pr.CloseWithError(errors.Join(err, io.ErrClosedPipe))
+	manifest := <-built
+	if err == nil && manifest == nil {
+		err = errors.New("export stopped before the manifest was written")
+	}
+	if err != nil {
+		_ = p.repo.FailExport(ctx, exp.ID, err.Error())
+		return err
+	}
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	done, err := p.repo.CompleteExport(ctx, exp.ID, key, counted.n, data)
+	if err != nil {
+		return err
+	}
+	if !done {
+		// It expired while being built; nobody will ask for it.
+		return p.store.RemoveProcessedObject(ctx, key)
+	}
+	log.Printf("export %s: %d documents, %d bytes", exp.ID, len(manifest.Documents), counted.n)
+	return nil
+}
+
+// handleExportExpire deletes an export's ZIP and marks the export expired.
+func (p *Processor) handleExportExpire(ctx context.Context, task *asynq.Task) error {
+	var payload queue.ExportPayload
+	if err := json.Unmarshal(task.Payload(), &payload); err != nil {
+		return fmt.Errorf("decode payload: %w", err)
+	}
+	if _, err := p.repo.ExpireExport(ctx, payload.ExportID); err != nil && !errors.Is(err, repository.ErrExportNotFound) {
+		return err
+	}
+	// The ZIP may exist without its key recorded when a build was stopped
+	// after uploading, so it is removed by its name.
+	return p.store.RemoveProcessedObject(ctx, s3storage.ExportKey(payload.ExportID))
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(b []byte) (int, error) {
+	n, err := c.w.Write(b)
+	c.n += int64(n)
+	return n, err
+}
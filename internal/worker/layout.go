@@ -0,0 +1,59 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/hibiken/asynq"
+
+	pdfutil "github.com/dharsanguruparan/VaultDrop/internal/pdf"
+	"github.com/dharsanguruparan/VaultDrop/internal/repository"
+	"github.com/dharsanguruparan/VaultDrop/internal/s3storage"
+)
+
+// LayoutStage is the derived-artifact stage that stores layout-preserving
+// text, with columns and line breaks kept, next to the processed text.
+const LayoutStage = "layout"
+
+// deriveLayout builds the layout text for a document extracted without it.
+func deriveLayout(ctx context.Context, p *Processor, doc *repository.Document) error {
+	if doc.RawPurgedAt != nil || doc.ProcessedKey == nil {
+		return fmt.Errorf("raw upload or processed text is gone (%w)", asynq.SkipRetry)
+	}
+	raw, err := p.spoolRaw(ctx, doc.ID, doc.ObjectKey)
+	if err != nil {
+		return err
+	}
+	defer raw.Close()
+	if err := raw.Verify(doc.SHA256); err != nil {
+		return fmt.Errorf("%w (%w)", err, asynq.SkipRetry)
+	}
+	if err := p.storeLayout(ctx, raw, *doc.ProcessedKey, ""); err != nil {
+		return err
+	}
+	log.Printf("layout text stored for %s", doc.ID)
+	return nil
+}
+
+// storeLayout extracts the layout text of raw and uploads it under
+// s3storage.LayoutKey(processedKey). Plain text uploads are stored as is.
+func (p *Processor) storeLayout(ctx context.Context, raw *s3storage.SpooledObject, processedKey, pipeline string) error {
+	mediaType, err := sniffMediaType(raw)
+	if err != nil {
+		return err
+	}
+	var text string
+	switch mediaType {
+	case "application/pdf":
+		text, err = p.extractPDF(ctx, raw, pdfutil.ModeLayout, pipeline, nil)
+	case "text/plain":
+		text, err = plainText(raw)
+	default:
+		err = fmt.Errorf("no extractor for %s", mediaType)
+	}
+	if err != nil {
+		return err
+	}
+	return p.store.UploadProcessed(ctx, s3storage.LayoutKey(processedKey), []byte(text))
+}
@@ -0,0 +1,346 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hibiken/asynq"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/queue"
+	"github.com/dharsanguruparan/VaultDrop/internal/repository"
+)
+
+// healthCheckTimeout bounds each dependency check behind /healthz.
+const healthCheckTimeout = 3 * time.Second
+
+// Monitor tracks the tasks this process runs and serves them, with dependency
+// health and counters, over HTTP for orchestrators:
+//
+//	GET /healthz  dependency checks; 503 when one fails
+//	GET /metrics  Prometheus text format, including queue backlog gauges
+//	              for scaling workers on backlog rather than CPU
+//	GET /tasks    tasks currently running
+type Monitor struct {
+	concurrency int
+	checks      map[string]func(context.Context) error
+	inspector   *asynq.Inspector
+	// collectors add metrics the monitor does not track itself; they are
+	// registered before Serve, so reads need no lock.
+	collectors []func(io.Writer)
+
+	mu      sync.Mutex
+	nextID  int64
+	active  map[int64]ActiveTask
+	stats   map[string]*taskStats
+	quality extractionQuality
+}
+
+// ActiveTask is a task being processed.
+type ActiveTask struct {
+	Type       string    `json:"type"`
+	TaskID     string    `json:"taskId"`
+	Queue      string    `json:"queue,omitempty"`
+	DocumentID string    `json:"documentId,omitempty"`
+	StartedAt  time.Time `json:"startedAt"`
+}
+
+type taskStats struct {
+	succeeded int64
+	failed    int64
+	seconds   float64
+}
+
+// extractionQuality aggregates the stats of completed extractions.
+type extractionQuality struct {
+	pages      int64
+	characters int64
+	coverage   histogram
+	duration   histogram
+}
+
+// histogram is a Prometheus histogram with fixed upper bounds.
+type histogram struct {
+	bounds []float64
+	counts []int64
+	sum    float64
+	count  int64
+}
+
+func newHistogram(bounds ...float64) histogram {
+	return histogram{bounds: bounds, counts: make([]int64, len(bounds))}
+}
+
+func (h *histogram) observe(v float64) {
+	for i, bound := range h.bounds {
+		if v <= bound {
+			h.counts[i]++
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+func (h histogram) write(w io.Writer, name string) {
+	for i, bound := range h.bounds {
+		fmt.Fprintf(w, "%s_bucket{le=\"%g\"} %d\n", name, bound, h.counts[i])
+	}
+	fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n", name, h.count)
+	fmt.Fprintf(w, "%s_sum %g\n", name, h.sum)
+	fmt.Fprintf(w, "%s_count %d\n", name, h.count)
+}
+
+// NewMonitor constructs a Monitor. concurrency is the server's worker count,
+// reported so autoscalers can compute utilisation; checks are named
+// dependency probes for /healthz; inspector reads the queue backlog.
+func NewMonitor(concurrency int, checks map[string]func(context.Context) error, inspector *asynq.Inspector) *Monitor {
+	return &Monitor{
+		concurrency: concurrency,
+		checks:      checks,
+		inspector:   inspector,
+		active:      make(map[int64]ActiveTask),
+		stats:       make(map[string]*taskStats),
+		quality: extractionQuality{
+			coverage: newHistogram(0, 0.25, 0.5, 0.75, 0.9, 1),
+			duration: newHistogram(0.1, 0.5, 1, 5, 15, 60, 300),
+		},
+	}
+}
+
+// Collect adds the metrics fn writes to /metrics, such as the database pool
+// statistics. Call it before Serve.
+func (m *Monitor) Collect(fn func(io.Writer)) {
+	m.collectors = append(m.collectors, fn)
+}
+
+// ObserveExtraction records the stats of a completed extraction; it is the
+// worker.ExtractOptions Observe hook.
+func (m *Monitor) ObserveExtraction(stats repository.ExtractionStats) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.quality.pages += int64(stats.Pages)
+	m.quality.characters += int64(stats.Characters)
+	m.quality.coverage.observe(stats.Coverage)
+	m.quality.duration.observe(float64(stats.DurationMS) / 1000)
+}
+
+// Middleware records every task the server runs.
+func (m *Monitor) Middleware() asynq.MiddlewareFunc {
+	return func(next asynq.Handler) asynq.Handler {
+		return asynq.HandlerFunc(func(ctx context.Context, task *asynq.Task) error {
+			taskID, _ := asynq.GetTaskID(ctx)
+			queueName, _ := asynq.GetQueueName(ctx)
+			var payload struct {
+				DocumentID string `json:"document_id"`
+			}
+			_ = json.Unmarshal(task.Payload(), &payload)
+			start := time.Now()
+			id := m.begin(ActiveTask{Type: task.Type(), TaskID: taskID, Queue: queueName, DocumentID: payload.DocumentID, StartedAt: start.UTC()})
+			err := next.ProcessTask(ctx, task)
+			m.end(id, task.Type(), time.Since(start), err)
+			return err
+		})
+	}
+}
+
+func (m *Monitor) begin(task ActiveTask) int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.nextID++
+	m.active[m.nextID] = task
+	return m.nextID
+}
+
+func (m *Monitor) end(id int64, taskType string, took time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.active, id)
+	st := m.stats[taskType]
+	if st == nil {
+		st = &taskStats{}
+		m.stats[taskType] = st
+	}
+	if err != nil {
+		st.failed++
+	} else {
+		st.succeeded++
+	}
+	st.seconds += took.Seconds()
+}
+
+// Active returns the running tasks, longest running first.
+func (m *Monitor) Active() []ActiveTask {
+	m.mu.Lock()
+	out := make([]ActiveTask, 0, len(m.active))
+	for _, t := range m.active {
+		out = append(out, t)
+	}
+	m.mu.Unlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].StartedAt.Before(out[j].StartedAt) })
+	return out
+}
+
+// Handler serves the monitoring endpoints.
+func (m *Monitor) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", m.handleHealth)
+	mux.HandleFunc("/metrics", m.handleMetrics)
+	mux.HandleFunc("/tasks", m.handleTasks)
+	return mux
+}
+
+// Serve runs the monitoring listener on addr until ctx is cancelled.
+func (m *Monitor) Serve(ctx context.Context, addr string) error {
+	srv := &http.Server{Addr: addr, Handler: m.Handler(), ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+	log.Printf("worker monitoring on %s", addr)
+	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+func (m *Monitor) handleHealth(w http.ResponseWriter, r *http.Request) {
+	type result struct {
+		name string
+		err  error
+	}
+	results := make(chan result, len(m.checks))
+	for name, check := range m.checks {
+		go func(name string, check func(context.Context) error) {
+			ctx, cancel := context.WithTimeout(r.Context(), healthCheckTimeout)
+			defer cancel()
+			results <- result{name, check(ctx)}
+		}(name, check)
+	}
+	status := http.StatusOK
+	checks := make(map[string]string, len(m.checks))
+	for range m.checks {
+		res := <-results
+		if res.err != nil {
+			status = http.StatusServiceUnavailable
+			checks[res.name] = res.err.Error()
+			continue
+		}
+		checks[res.name] = "ok"
+	}
+	body := map[string]interface{}{"status": "ok", "checks": checks}
+	if status != http.StatusOK {
+		body["status"] = "unavailable"
+	}
+	writeJSON(w, status, body)
+}
+
+func (m *Monitor) handleTasks(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{"concurrency": m.concurrency, "active": m.Active()})
+}
+
+func (m *Monitor) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	activeByType := make(map[string]int)
+	for _, t := range m.active {
+		activeByType[t.Type]++
+	}
+	types := make([]string, 0, len(m.stats))
+	stats := make(map[string]taskStats, len(m.stats))
+	for taskType, st := range m.stats {
+		types = append(types, taskType)
+		stats[taskType] = *st
+	}
+	active := len(m.active)
+	quality := m.quality
+	quality.coverage.counts = append([]int64(nil), m.quality.coverage.counts...)
+	quality.duration.counts = append([]int64(nil), m.quality.duration.counts...)
+	m.mu.Unlock()
+	sort.Strings(types)
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	fmt.Fprintln(w, "# HELP vaultdrop_worker_concurrency Maximum tasks processed at once.")
+	fmt.Fprintln(w, "# TYPE vaultdrop_worker_concurrency gauge")
+	fmt.Fprintf(w, "vaultdrop_worker_concurrency %d\n", m.concurrency)
+	fmt.Fprintln(w, "# HELP vaultdrop_worker_active_tasks Tasks currently being processed.")
+	fmt.Fprintln(w, "# TYPE vaultdrop_worker_active_tasks gauge")
+	fmt.Fprintf(w, "vaultdrop_worker_active_tasks %d\n", active)
+	fmt.Fprintln(w, "# HELP vaultdrop_worker_tasks_total Tasks processed, by type and result.")
+	fmt.Fprintln(w, "# TYPE vaultdrop_worker_tasks_total counter")
+	for _, t := range types {
+		fmt.Fprintf(w, "vaultdrop_worker_tasks_total{type=%q,result=\"success\"} %d\n", t, stats[t].succeeded)
+		fmt.Fprintf(w, "vaultdrop_worker_tasks_total{type=%q,result=\"failure\"} %d\n", t, stats[t].failed)
+	}
+	fmt.Fprintln(w, "# HELP vaultdrop_worker_task_seconds_total Time spent processing tasks, by type.")
+	fmt.Fprintln(w, "# TYPE vaultdrop_worker_task_seconds_total counter")
+	for _, t := range types {
+		fmt.Fprintf(w, "vaultdrop_worker_task_seconds_total{type=%q} %g\n", t, stats[t].seconds)
+	}
+	fmt.Fprintln(w, "# HELP vaultdrop_worker_active_tasks_by_type Tasks currently being processed, by type.")
+	fmt.Fprintln(w, "# TYPE vaultdrop_worker_active_tasks_by_type gauge")
+	for _, t := range sortedKeys(activeByType) {
+		fmt.Fprintf(w, "vaultdrop_worker_active_tasks_by_type{type=%q} %d\n", t, activeByType[t])
+	}
+	fmt.Fprintln(w, "# HELP vaultdrop_extract_pages_total Pages in completed extractions.")
+	fmt.Fprintln(w, "# TYPE vaultdrop_extract_pages_total counter")
+	fmt.Fprintf(w, "vaultdrop_extract_pages_total %d\n", quality.pages)
+	fmt.Fprintln(w, "# HELP vaultdrop_extract_characters_total Non-whitespace characters extracted.")
+	fmt.Fprintln(w, "# TYPE vaultdrop_extract_characters_total counter")
+	fmt.Fprintf(w, "vaultdrop_extract_characters_total %d\n", quality.characters)
+	fmt.Fprintln(w, "# HELP vaultdrop_extract_coverage Share of pages that yielded text, per completed extraction.")
+	fmt.Fprintln(w, "# TYPE vaultdrop_extract_coverage histogram")
+	quality.coverage.write(w, "vaultdrop_extract_coverage")
+	fmt.Fprintln(w, "# HELP vaultdrop_extract_duration_seconds Time spent extracting text, per completed extraction.")
+	fmt.Fprintln(w, "# TYPE vaultdrop_extract_duration_seconds histogram")
+	quality.duration.write(w, "vaultdrop_extract_duration_seconds")
+	for _, collect := range m.collectors {
+		collect(w)
+	}
+
+	// The backlog is shared by every worker; a failed read leaves the
+	// gauges out rather than failing the scrape.
+	backlogs, err := queue.Backlogs(m.inspector)
+	if err != nil {
+		log.Printf("metrics: %v", err)
+		return
+	}
+	fmt.Fprintln(w, "# HELP vaultdrop_queue_tasks Tasks in each asynq queue, by state.")
+	fmt.Fprintln(w, "# TYPE vaultdrop_queue_tasks gauge")
+	for _, b := range backlogs {
+		for _, st := range []struct {
+			state string
+			n     int
+		}{{"pending", b.Pending}, {"active", b.Active}, {"scheduled", b.Scheduled}, {"retry", b.Retry}, {"archived", b.Archived}} {
+			fmt.Fprintf(w, "vaultdrop_queue_tasks{queue=%q,state=%q} %d\n", b.Queue, st.state, st.n)
+		}
+	}
+	fmt.Fprintln(w, "# HELP vaultdrop_queue_oldest_pending_seconds Age of the oldest pending task in each queue.")
+	fmt.Fprintln(w, "# TYPE vaultdrop_queue_oldest_pending_seconds gauge")
+	for _, b := range backlogs {
+		fmt.Fprintf(w, "vaultdrop_queue_oldest_pending_seconds{queue=%q} %g\n", b.Queue, float64(b.LatencyMS)/1000)
+	}
+}
+
+func sortedKeys(m map[string]int) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func writeJSON(w http.ResponseWriter, status int, payload interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(payload); err != nil {
+		log.Printf("encode response: %v", err)
+	}
+}
@@ -0,0 +1,64 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hibiken/asynq"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/queue"
+)
+
+// TaskPolicy holds the per-task-type limits from the configuration.
+type TaskPolicy struct {
+	// Concurrency caps concurrent tasks of a type in this process. A task
+	// over its cap waits for a slot while holding one of the server's
+	// workers, so caps should stay well below the server concurrency.
+	Concurrency map[string]int
+	// MaxRetry lowers the retries of a type; a task is archived once it
+	// failed this many retries even if it was enqueued with more.
+	MaxRetry map[string]int
+}
+
+// Queues returns the asynq queue weights for weights, adding the legacy queue
+// so tasks from older builds are drained.
+func Queues(weights map[string]int) map[string]int {
+	out := make(map[string]int, len(weights)+1)
+	for name, weight := range weights {
+		out[name] = weight
+	}
+	if _, ok := out[queue.LegacyQueue]; !ok {
+		out[queue.LegacyQueue] = 1
+	}
+	return out
+}
+
+// Middleware enforces the policy around every task handler.
+func (p TaskPolicy) Middleware() asynq.MiddlewareFunc {
+	slots := make(map[string]chan struct{}, len(p.Concurrency))
+	for taskType, n := range p.Concurrency {
+		slots[taskType] = make(chan struct{}, n)
+	}
+	return func(next asynq.Handler) asynq.Handler {
+		return asynq.HandlerFunc(func(ctx context.Context, task *asynq.Task) error {
+			if slot, ok := slots[task.Type()]; ok {
+				select {
+				case slot <- struct{}{}:
+					defer func() { <-slot }()
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+			err := next.ProcessTask(ctx, task)
+			if err == nil {
+				return nil
+			}
+			if max, ok := p.MaxRetry[task.Type()]; ok {
+				if retry, _ := asynq.GetRetryCount(ctx); retry >= max {
+					return fmt.Errorf("%w (retry limit %d reached; %w)", err, max, asynq.SkipRetry)
+				}
+			}
+			return err
+		})
+	}
+}
@@ -3,14 +3,24 @@ package worker
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"mime"
+	"net/http"
+	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/hibiken/asynq"
 
+	"github.com/dharsanguruparan/VaultDrop/internal/events"
+	"github.com/dharsanguruparan/VaultDrop/internal/keys"
+	"github.com/dharsanguruparan/VaultDrop/internal/metadata"
 	pdfutil "github.com/dharsanguruparan/VaultDrop/internal/pdf"
+	"github.com/dharsanguruparan/VaultDrop/internal/progress"
 	"github.com/dharsanguruparan/VaultDrop/internal/queue"
 	"github.com/dharsanguruparan/VaultDrop/internal/repository"
 	"github.com/dharsanguruparan/VaultDrop/internal/s3storage"
@@ -18,54 +28,321 @@ import (
 
 // Processor is plugged into the asynq worker loop.
 type Processor struct {
-	repo  *repository.DocumentRepository
-	store *s3storage.Storage
+	repo *repository.DocumentRepository
+	// status records the processing and failed transitions through the
+	// store interface the standalone processor uses too.
+	status      metadata.Store
+	store       *s3storage.Storage
+	retention   RetentionPolicy
+	progress    *progress.Tracker
+	extractOpts ExtractOptions
+	events      events.Publisher
+	keys        *keys.Keyring
+	// cleanupGrace is zero unless UseCleanup turned the orphan cleanup on.
+	cleanupGrace  time.Duration
+	cleanupDryRun bool
+	// workerID identifies this process in the attempt history.
+	workerID string
 }
 
 // NewProcessor constructs a worker processor.
-func NewProcessor(repo *repository.DocumentRepository, store *s3storage.Storage) *Processor {
-	return &Processor{repo: repo, store: store}
+func NewProcessor(repo *repository.DocumentRepository, store *s3storage.Storage, retention RetentionPolicy, tracker *progress.Tracker, extract ExtractOptions) *Processor {
+	return &Processor{repo: repo, status: metadata.FromRepository(repo), store: store, retention: retention, progress: tracker, extractOpts: extract, events: events.Nop{}, workerID: workerIdentity()}
+}
+
+// UseEvents publishes the processing, completed and failed transitions of
+// extractions to pub.
+func (p *Processor) UseEvents(pub events.Publisher) {
+	p.events = pub
+}
+
+// UseKeys lets the worker read raw uploads encrypted with data keys wrapped
+// under the master keys in k.
+func (p *Processor) UseKeys(k *keys.Keyring) {
+	p.keys = k
+}
+
+// spoolRaw downloads the raw upload of document id, decrypting it with its
+// data key when it has one.
+func (p *Processor) spoolRaw(ctx context.Context, id, objectKey string) (*s3storage.SpooledObject, error) {
+	wrapped, err := p.repo.DataKey(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	var dataKey []byte
+	if wrapped != nil {
+		if dataKey, err = p.keys.Unwrap(id, *wrapped); err != nil {
+			return nil, err
+		}
+	}
+	return p.store.SpoolRaw(ctx, objectKey, dataKey)
+}
+
+// ExtractOptions controls how extraction runs.
+type ExtractOptions struct {
+	// Extractor is the PDF backend.
+	Extractor pdfutil.Extractor
+	// Timeout bounds one extraction run.
+	Timeout time.Duration
+	// Sandbox runs the parser in a child process limited to MemoryLimit
+	// bytes of address space (zero for no limit); the worker binary must
+	// dispatch pdfutil.SandboxCommand to pdfutil.RunSandbox.
+	Sandbox     bool
+	MemoryLimit int64
+	// Limits are checked before a PDF's text is extracted; a PDF over any
+	// of them fails without retries.
+	Limits pdfutil.Limits
+	// Layout also writes layout-preserving text after each extraction.
+	Layout bool
+	// Observe, when set, receives the stats of every completed extraction.
+	Observe func(repository.ExtractionStats)
+}
+
+// errExtractTimeout marks a run that exceeded the extraction deadline. It is
+// stored as the document's error message prefix so timeouts stand out from
+// corrupt files.
+var errExtractTimeout = errors.New("timeout")
+
+// pageReportInterval throttles page-by-page progress writes for long PDFs.
+const pageReportInterval = time.Second
+
+func workerIdentity() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s:%d", host, os.Getpid())
 }
 
 // Handler registers the extract job handler.
 func (p *Processor) Handler() *asynq.ServeMux {
 	mux := asynq.NewServeMux()
 	mux.HandleFunc(queue.ExtractDocumentTask, p.handleExtract)
+	mux.HandleFunc(queue.EraseDocumentsTask, p.handleErase)
+	mux.HandleFunc(queue.RetentionSweepTask, p.handleRetention)
+	mux.HandleFunc(queue.ArchiveSweepTask, p.handleArchive)
+	mux.HandleFunc(queue.CleanupOrphansTask, p.handleCleanup)
+	mux.HandleFunc(queue.ExportBuildTask, p.handleExport)
+	mux.HandleFunc(queue.ExportExpireTask, p.handleExportExpire)
+	for stage, derive := range derivers {
+		mux.HandleFunc(queue.DeriveTask(stage), p.handleDerive(stage, derive))
+	}
 	return mux
 }
 
+// handleExtract runs an extraction and records it in the attempt history.
+// History is best effort: failing to write it never fails the extraction. A
+// run stopped by cancellation is recorded with that error but completes the
+// task so asynq does not retry it. A run that exceeds the extraction deadline
+// fails the document and is not retried either: a PDF that is too slow once
+// will be too slow again. The same holds for a PDF over the extraction limits.
 func (p *Processor) handleExtract(ctx context.Context, task *asynq.Task) error {
 	var payload queue.ExtractPayload
 	if err := json.Unmarshal(task.Payload(), &payload); err != nil {
 		return fmt.Errorf("decode payload: %w", err)
 	}
+	// The task carries only the document id; see queue.ExtractPayload.
+	doc, err := p.repo.ExtractSource(ctx, payload.DocumentID)
+	if errors.Is(err, repository.ErrNotFound) {
+		log.Printf("document %s is gone, extraction skipped", payload.DocumentID)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	taskID, _ := asynq.GetTaskID(ctx)
+	retry, _ := asynq.GetRetryCount(ctx)
+	attempt, err := p.repo.StartAttempt(ctx, payload.DocumentID, taskID, retry, p.workerID)
+	if err != nil {
+		log.Printf("record attempt for %s: %v", payload.DocumentID, err)
+	}
+	runCtx, cancel := context.WithTimeout(ctx, p.extractOpts.Timeout)
+	runErr := p.extract(runCtx, doc)
+	cancel()
+	if err := p.progress.Clear(context.WithoutCancel(ctx), payload.DocumentID); err != nil {
+		log.Printf("clear progress for %s: %v", payload.DocumentID, err)
+	}
+	if attempt != 0 {
+		// The task context may already be cancelled when extraction timed out;
+		// the outcome is still worth recording.
+		if err := p.repo.FinishAttempt(context.WithoutCancel(ctx), attempt, runErr); err != nil {
+			log.Printf("finish attempt for %s: %v", payload.DocumentID, err)
+		}
+	}
+	if errors.Is(runErr, repository.ErrCancelled) {
+		log.Printf("document %s cancelled, extraction stopped", payload.DocumentID)
+		return nil
+	}
+	// A retry would download the same bytes again, so these are final.
+	if errors.Is(runErr, errExtractTimeout) || errors.Is(runErr, pdfutil.ErrLimitExceeded) || errors.Is(runErr, s3storage.ErrCorrupt) {
+		return fmt.Errorf("%w (%w)", runErr, asynq.SkipRetry)
+	}
+	return runErr
+}
+
+func (p *Processor) extract(ctx context.Context, doc *repository.ExtractSource) error {
 	failure := func(err error) error {
-		log.Printf("extract failed for %s: %v", payload.DocumentID, err)
-		_ = p.repo.MarkFailed(ctx, payload.DocumentID, err.Error())
+		if errors.Is(err, repository.ErrCancelled) {
+			return err
+		}
+		// Whatever failed first, a passed deadline is the real cause.
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			err = fmt.Errorf("%w: extraction exceeded %s: %v", errExtractTimeout, p.extractOpts.Timeout, err)
+		}
+		log.Printf("extract failed for %s: %v", doc.DocumentID, err)
+		// The run context may be past its deadline by now.
+		if p.status.MarkFailed(context.WithoutCancel(ctx), doc.DocumentID, err.Error()) == nil {
+			p.emit(ctx, events.DocumentFailed, doc, err.Error())
+		}
 		return err
 	}
-	if err := p.repo.MarkProcessing(ctx, payload.DocumentID); err != nil {
+	if err := p.status.MarkProcessing(ctx, doc.DocumentID); err != nil {
 		return failure(err)
 	}
-	data, err := p.store.DownloadRaw(ctx, payload.ObjectKey)
+	p.emit(ctx, events.DocumentProcessing, doc, "")
+	p.report(ctx, doc.DocumentID, progress.Report{Stage: progress.StageDownloading})
+	raw, err := p.spoolRaw(ctx, doc.DocumentID, doc.ObjectKey)
 	if err != nil {
 		return failure(err)
 	}
-	text, err := pdfutil.ExtractText(data)
+	defer raw.Close()
+	checksum, err := p.repo.RawChecksum(ctx, doc.DocumentID)
 	if err != nil {
 		return failure(err)
 	}
-	processedKey := processedObjectKey(payload.ObjectKey)
+	if err := raw.Verify(checksum); err != nil {
+		return failure(err)
+	}
+	if err := p.checkCancelled(ctx, doc.DocumentID); err != nil {
+		return failure(err)
+	}
+	var lastReport time.Time
+	onPage := func(page, total int) {
+		if page > 1 && page < total && time.Since(lastReport) < pageReportInterval {
+			return
+		}
+		lastReport = time.Now()
+		p.report(ctx, doc.DocumentID, progress.Report{Stage: progress.StageExtracting, Page: page, Pages: total})
+	}
+	mediaType, err := sniffMediaType(raw)
+	if err != nil {
+		return failure(err)
+	}
+	var text string
+	started := time.Now()
+	switch mediaType {
+	case "application/pdf":
+		text, err = p.extractPDF(ctx, raw, pdfutil.ModeText, doc.Pipeline, onPage)
+	case "text/plain":
+		text, err = plainText(raw)
+	default:
+		err = fmt.Errorf("no extractor for %s", mediaType)
+	}
+	if err != nil {
+		return failure(err)
+	}
+	stats := measureText(text, time.Since(started))
+	if err := p.checkCancelled(ctx, doc.DocumentID); err != nil {
+		return failure(err)
+	}
+	p.report(ctx, doc.DocumentID, progress.Report{Stage: progress.StageUploading})
+	processedKey := processedObjectKey(doc.ObjectKey)
 	if err := p.store.UploadProcessed(ctx, processedKey, []byte(text)); err != nil {
 		return failure(err)
 	}
-	if err := p.repo.MarkCompleted(ctx, payload.DocumentID, processedKey, text); err != nil {
+	if err := p.repo.MarkCompleted(ctx, doc.DocumentID, processedKey, text, stats); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			// Erased or deleted while extracting; the text just uploaded
+			// must not outlive it.
+			if err := p.store.RemoveProcessed(context.WithoutCancel(ctx), processedKey); err != nil {
+				log.Printf("remove text of deleted document %s: %v", doc.DocumentID, err)
+			}
+		}
 		return failure(err)
 	}
-	log.Printf("document %s processed (%d bytes)", payload.DocumentID, len(text))
+	p.emit(ctx, events.DocumentCompleted, doc, "")
+	if p.extractOpts.Observe != nil {
+		p.extractOpts.Observe(stats)
+	}
+	log.Printf("document %s processed (%d bytes, %d pages, coverage %.2f)", doc.DocumentID, len(text), stats.Pages, stats.Coverage)
+	if p.extractOpts.Layout {
+		// The layout text is an extra; without it the document is still
+		// complete, and `vaultdrop admin backfill --stage layout` retries.
+		if err := p.storeLayout(ctx, raw, processedKey, doc.Pipeline); err != nil {
+			log.Printf("layout text for %s: %v", doc.DocumentID, err)
+		} else if err := p.repo.RecordArtifact(ctx, doc.DocumentID, LayoutStage); err != nil {
+			log.Printf("record layout text for %s: %v", doc.DocumentID, err)
+		}
+	}
 	return nil
 }
 
+// emit publishes a lifecycle event for the document being extracted.
+func (p *Processor) emit(ctx context.Context, typ string, doc *repository.ExtractSource, errMsg string) {
+	e := events.NewEvent(typ, doc.DocumentID)
+	e.FileName = doc.FileName
+	e.Error = errMsg
+	events.Emit(context.WithoutCancel(ctx), p.events, e)
+}
+
+// extractPDF extracts a spooled PDF in mode, in a child process when the
+// worker or the pipeline asks for the sandbox.
+func (p *Processor) extractPDF(ctx context.Context, raw *s3storage.SpooledObject, mode, pipeline string, onPage func(page, total int)) (string, error) {
+	if p.extractOpts.Sandbox || pipeline == queue.PipelineSandbox {
+		return pdfutil.ExtractInSandbox(ctx, raw.Name(), p.extractOpts.MemoryLimit, p.extractOpts.Extractor, p.extractOpts.Limits, mode, onPage)
+	}
+	if mode == pdfutil.ModeLayout {
+		return pdfutil.ExtractLayout(ctx, p.extractOpts.Extractor, raw.Name(), p.extractOpts.Limits, onPage)
+	}
+	return pdfutil.Extract(ctx, p.extractOpts.Extractor, raw.Name(), p.extractOpts.Limits, onPage)
+}
+
+// sniffMediaType classifies a raw upload the way the API did when accepting
+// it, so objects that arrived through bucket notifications are handled too.
+func sniffMediaType(raw *s3storage.SpooledObject) (string, error) {
+	head := make([]byte, 512)
+	n, err := raw.ReadAt(head, 0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("read raw upload: %w", err)
+	}
+	mediaType, _, err := mime.ParseMediaType(http.DetectContentType(head[:n]))
+	if err != nil {
+		return "", fmt.Errorf("detect content type: %w", err)
+	}
+	return mediaType, nil
+}
+
+// plainText returns a text upload as is, with invalid UTF-8 replaced.
+func plainText(raw *s3storage.SpooledObject) (string, error) {
+	data, err := io.ReadAll(io.NewSectionReader(raw, 0, raw.Size))
+	if err != nil {
+		return "", fmt.Errorf("read raw upload: %w", err)
+	}
+	return strings.ToValidUTF8(string(data), "\uFFFD"), nil
+}
+
+// checkCancelled returns repository.ErrCancelled once the document was
+// cancelled. It runs between stages, so a cancellation takes effect when the
+// current stage finishes.
+func (p *Processor) checkCancelled(ctx context.Context, documentID string) error {
+	cancelled, err := p.repo.IsCancelled(ctx, documentID)
+	if err != nil {
+		return err
+	}
+	if cancelled {
+		return repository.ErrCancelled
+	}
+	return nil
+}
+
+// report publishes progress. It is informational only, so failures are logged
+// and extraction carries on.
+func (p *Processor) report(ctx context.Context, documentID string, report progress.Report) {
+	if err := p.progress.Set(ctx, documentID, report); err != nil {
+		log.Printf("report progress for %s: %v", documentID, err)
+	}
+}
+
 func processedObjectKey(objectKey string) string {
 	base := strings.TrimSuffix(objectKey, filepath.Ext(objectKey))
 	return fmt.Sprintf("%s.txt", base)
@@ -0,0 +1,35 @@
+package worker
+
+import (
+	"strings"
+	"time"
+	"unicode"
+
+	pdfutil "github.com/dharsanguruparan/VaultDrop/internal/pdf"
+	"github.com/dharsanguruparan/VaultDrop/internal/repository"
+)
+
+// minPageChars is how many non-whitespace characters a page needs to count
+// as covered; a lone page number or header does not.
+const minPageChars = 16
+
+// measureText computes the quality stats of extracted text.
+func measureText(text string, took time.Duration) repository.ExtractionStats {
+	pages := strings.Split(text, pdfutil.PageBreak)
+	stats := repository.ExtractionStats{Pages: len(pages), DurationMS: took.Milliseconds()}
+	covered := 0
+	for _, page := range pages {
+		n := 0
+		for _, r := range page {
+			if !unicode.IsSpace(r) {
+				n++
+			}
+		}
+		stats.Characters += n
+		if n >= minPageChars {
+			covered++
+		}
+	}
+	stats.Coverage = float64(covered) / float64(len(pages))
+	return stats
+}
@@ -0,0 +1,118 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/hibiken/asynq"
+
+	"github.com/dharsanguruparan/VaultDrop/internal/repository"
+)
+
+const retentionBatch = 200
+
+// RetentionPolicy holds how long each artifact is kept; zero keeps it forever.
+// Archive is how long extracted text stays in Postgres before the archive
+// sweep moves it to S3; zero keeps it in Postgres.
+type RetentionPolicy struct {
+	Raw       time.Duration
+	Text      time.Duration
+	Documents time.Duration
+	Archive   time.Duration
+}
+
+// handleRetention purges every artifact whose retention period has passed.
+// Whole documents go first so their objects are not purged twice. Upload
+// rejections older than the rejection report can show are deleted too.
+func (p *Processor) handleRetention(ctx context.Context, _ *asynq.Task) error {
+	rules := []struct {
+		artifact repository.Artifact
+		keep     time.Duration
+		purge    func(context.Context, repository.ExpiredDocument) error
+	}{
+		{repository.ArtifactDocument, p.retention.Documents, p.purgeDocument},
+		{repository.ArtifactRaw, p.retention.Raw, p.purgeRaw},
+		{repository.ArtifactText, p.retention.Text, p.purgeText},
+	}
+	now := time.Now().UTC()
+	for _, rule := range rules {
+		if rule.keep <= 0 {
+			continue
+		}
+		purged, err := p.sweep(ctx, rule.artifact, now.Add(-rule.keep), rule.purge)
+		if purged > 0 {
+			log.Printf("retention: purged %s for %d documents", rule.artifact, purged)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	deleted, err := p.repo.DeleteRejectionsBefore(ctx, now.Add(-repository.RejectionRetention))
+	if deleted > 0 {
+		log.Printf("retention: deleted %d upload rejections", deleted)
+	}
+	return err
+}
+
+func (p *Processor) sweep(ctx context.Context, artifact repository.Artifact, cutoff time.Time, purge func(context.Context, repository.ExpiredDocument) error) (int, error) {
+	purged := 0
+	for {
+		docs, err := p.repo.ListExpired(ctx, artifact, cutoff, retentionBatch)
+		if err != nil {
+			return purged, err
+		}
+		for _, doc := range docs {
+			if err := purge(ctx, doc); err != nil {
+				return purged, fmt.Errorf("purge %s of %s: %w", artifact, doc.ID, err)
+			}
+			purged++
+		}
+		if len(docs) < retentionBatch {
+			return purged, nil
+		}
+	}
+}
+
+func (p *Processor) purgeRaw(ctx context.Context, doc repository.ExpiredDocument) error {
+	if err := p.store.RemoveRaw(ctx, doc.ObjectKey); err != nil {
+		return err
+	}
+	return p.repo.MarkRawPurged(ctx, doc.ID)
+}
+
+func (p *Processor) purgeText(ctx context.Context, doc repository.ExpiredDocument) error {
+	if err := p.removeText(ctx, doc); err != nil {
+		return err
+	}
+	return p.repo.PurgeText(ctx, doc.ID)
+}
+
+// removeText deletes the processed and archived text objects of doc, those of
+// its versions included.
+func (p *Processor) removeText(ctx context.Context, doc repository.ExpiredDocument) error {
+	if doc.ProcessedKey != nil {
+		if err := p.store.RemoveProcessed(ctx, *doc.ProcessedKey); err != nil {
+			return err
+		}
+	}
+	if doc.ArchiveKey != nil {
+		if err := p.store.RemoveArchive(ctx, *doc.ArchiveKey); err != nil {
+			return err
+		}
+	}
+	// Versions archived before a reprocess stay archived after the document
+	// no longer is, so they are looked for either way.
+	return p.store.RemoveVersionArchives(ctx, doc.ID)
+}
+
+func (p *Processor) purgeDocument(ctx context.Context, doc repository.ExpiredDocument) error {
+	if err := p.store.RemoveRaw(ctx, doc.ObjectKey); err != nil {
+		return err
+	}
+	if err := p.removeText(ctx, doc); err != nil {
+		return err
+	}
+	return p.repo.DeleteDocument(ctx, doc.ID)
+}